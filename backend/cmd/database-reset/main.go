@@ -4,25 +4,37 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"claudeee-backend/internal/config"
 )
 
 func main() {
-	homeDir, err := os.UserHomeDir()
+	cfg, err := config.Load("")
 	if err != nil {
-		fmt.Printf("Error getting home directory: %v\n", err)
+		fmt.Printf("Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	dbDir := filepath.Join(homeDir, ".claudeee")
-	
-	// Remove all database files
-	err = os.RemoveAll(dbDir)
-	if err != nil {
-		fmt.Printf("Error removing database directory: %v\n", err)
+	dbDir := cfg.DataDir
+
+	// Remove all database files, keeping the profile configuration and any
+	// named profiles stored under the default data directory
+	entries, err := os.ReadDir(dbDir)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error reading database directory: %v\n", err)
 		os.Exit(1)
 	}
+	for _, entry := range entries {
+		if entry.Name() == "profile.json" || (cfg.Profile == config.DefaultProfile && entry.Name() == "profiles") {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dbDir, entry.Name())); err != nil {
+			fmt.Printf("Error removing database directory: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
 	fmt.Println("Database reset completed. All database files have been removed.")
 	fmt.Printf("Database directory: %s\n", dbDir)
 	fmt.Println("The database will be recreated when the server next starts.")
-}
\ No newline at end of file
+}
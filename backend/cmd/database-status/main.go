@@ -4,9 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"path/filepath"
 
 	_ "github.com/marcboeker/go-duckdb"
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/database"
 )
 
 func main() {
@@ -16,13 +17,13 @@ func main() {
 		return
 	}
 
-	homeDir, err := os.UserHomeDir()
+	cfg, err := config.Load("")
 	if err != nil {
-		fmt.Printf("Error getting home directory: %v\n", err)
+		fmt.Printf("Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	dbPath := filepath.Join(homeDir, ".claudeee", "claudeee.db")
+	dbPath := cfg.DatabasePath()
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		fmt.Println("Database does not exist.")
 		fmt.Printf("Expected location: %s\n", dbPath)
@@ -36,7 +37,12 @@ func main() {
 	}
 	defer db.Close()
 
-	fmt.Printf("Database: %s\n\n", dbPath)
+	fmt.Printf("Database: %s\n", dbPath)
+	if version, err := database.SchemaVersion(db); err != nil {
+		fmt.Printf("Schema version: unknown (not migrated yet)\n\n")
+	} else {
+		fmt.Printf("Schema version: %d\n\n", version)
+	}
 
 	// Check main tables
 	tables := []struct {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	"claudeee-backend/internal/config"
 	"claudeee-backend/internal/database"
 	"claudeee-backend/internal/services"
 )
@@ -11,7 +12,12 @@ import (
 func main() {
 	fmt.Println("Starting session start time fix...")
 
-	db, err := database.Initialize()
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+
+	db, err := database.Initialize(cfg.DBDriver, cfg.DatabaseDSN())
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
@@ -46,7 +46,6 @@ func main() {
 
 		CREATE INDEX IF NOT EXISTS idx_session_windows_times ON session_windows(window_start, window_end);
 		CREATE INDEX IF NOT EXISTS idx_session_windows_active ON session_windows(is_active);
-		CREATE INDEX IF NOT EXISTS idx_session_windows_reset_time ON session_windows(reset_time);
 
 		ALTER TABLE messages ADD COLUMN IF NOT EXISTS session_window_id TEXT;
 		CREATE INDEX IF NOT EXISTS idx_messages_session_window_id ON messages(session_window_id);
@@ -4,9 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"path/filepath"
+	"time"
 
 	_ "github.com/marcboeker/go-duckdb"
+	"claudeee-backend/internal/config"
 	"claudeee-backend/internal/services"
 )
 
@@ -18,13 +19,13 @@ func main() {
 		return
 	}
 
-	homeDir, err := os.UserHomeDir()
+	cfg, err := config.Load("")
 	if err != nil {
-		fmt.Printf("Error getting home directory: %v\n", err)
+		fmt.Printf("Error loading configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	dbPath := filepath.Join(homeDir, ".claudeee", "claudeee.db")
+	dbPath := cfg.DatabasePath()
 	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
 		fmt.Println("Database does not exist.")
 		return
@@ -67,6 +68,10 @@ func main() {
 	}
 
 	// Use the service to recalculate windows
+	services.SetWindowPolicy(services.WindowPolicy{
+		Duration: time.Duration(cfg.WindowHours) * time.Hour,
+		Anchor:   services.WindowAnchor(cfg.WindowAnchor),
+	})
 	windowService := services.NewSessionWindowService(db)
 	err = windowService.RecalculateAllWindows()
 	if err != nil {
@@ -1,75 +1,1389 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+	"claudeee-backend/internal/agent"
+	"claudeee-backend/internal/apiversion"
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/backup"
+	"claudeee-backend/internal/cli"
+	"claudeee-backend/internal/config"
 	"claudeee-backend/internal/database"
 	"claudeee-backend/internal/handlers"
+	"claudeee-backend/internal/instance"
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/metrics"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/notify"
+	"claudeee-backend/internal/offboard"
 	"claudeee-backend/internal/services"
+	"claudeee-backend/internal/webhook"
 )
 
+// shutdownTimeout bounds how long a stopping server waits for the running
+// sync and open requests
+const shutdownTimeout = 30 * time.Second
+
+// serveOptions are the flags of the serve command
+type serveOptions struct {
+	exportAndWipe bool
+	archivePath   string
+	readOnlyAPI   bool
+	readOnly      bool
+}
+
+func (o *serveOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&o.exportAndWipe, "export-and-wipe", false, "export all data to an archive, then delete the data directory and exit")
+	cmd.Flags().StringVar(&o.archivePath, "archive", "", "archive path for --export-and-wipe (default: ~/claudeee-export-<profile>-<time>.zip)")
+	cmd.Flags().BoolVar(&o.readOnlyAPI, "read-only-api", false, "reject sync triggers, config changes and admin operations with 403")
+	cmd.Flags().BoolVar(&o.readOnly, "read-only", false, "open the database read-only and run no syncs or background jobs, to serve a dashboard of a database another instance writes")
+}
+
 func main() {
-	db, err := database.Initialize()
+	var profile string
+	var serve serveOptions
+	root := &cobra.Command{
+		Use:   "claudeee",
+		Short: "Track Claude Code token usage, sessions and costs",
+		Long:  "Track Claude Code token usage, sessions and costs. Without a command, claudeee starts the API server.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runServer(loadConfig(profile), serve)
+		},
+		SilenceUsage: true,
+	}
+	root.PersistentFlags().StringVar(&profile, "profile", "", "profile name (separate database and settings)")
+	serve.addFlags(root)
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start the API server",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runServer(loadConfig(profile), serve)
+		},
+	}
+	serve.addFlags(serveCmd)
+
+	var force bool
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Read new Claude log lines into the database and exit",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := cli.Open(loadConfig(profile))
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			// Ctrl-C stops after the lines already read, which are kept
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			stats, err := cli.Sync(ctx, store, force)
+			if err != nil {
+				if ctx.Err() != nil && stats != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Interrupted; kept %d new lines from %d files\n", stats.NewLines, stats.ProcessedFiles)
+				}
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Synced %d new lines from %d of %d files in %s (%d parse errors)\n",
+				stats.NewLines, stats.ProcessedFiles, stats.TotalFiles, stats.ProcessingTime.Round(time.Millisecond), stats.ParseErrors)
+			if stats.Redactions > 0 {
+				kinds := make([]string, 0, len(stats.RedactionsByKind))
+				for kind := range stats.RedactionsByKind {
+					kinds = append(kinds, kind)
+				}
+				sort.Strings(kinds)
+				for i, kind := range kinds {
+					kinds[i] = fmt.Sprintf("%d %s", stats.RedactionsByKind[kind], kind)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Redacted %d secrets: %s\n", stats.Redactions, strings.Join(kinds, ", "))
+			}
+			return nil
+		},
+	}
+
+	syncCmd.Flags().BoolVar(&force, "force", false, "delete all synced sessions and messages and rebuild them from the logs")
+
+	var today, asJSON, activeBlock bool
+	var since, until, reportFormat, period string
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Print usage, cost and window capacity tables without starting the server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if asJSON {
+				reportFormat = "json"
+			}
+			if reportFormat != "table" && reportFormat != "json" && reportFormat != "ccusage" {
+				return fmt.Errorf("unknown format %q (expected table, json or ccusage)", reportFormat)
+			}
+			store, err := cli.Open(loadConfig(profile))
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+			encoder := json.NewEncoder(cmd.OutOrStdout())
+			encoder.SetIndent("", "  ")
+
+			if reportFormat == "ccusage" {
+				// Like ccusage, the report covers all time unless limited
+				var from, to time.Time
+				if today {
+					from, to = cli.Today(time.Now(), store.Location())
+				} else if from, to, err = parseRange(since, until, store.Location()); err != nil {
+					return err
+				}
+				report, err := services.NewCCUsageService(store.DB).Report(services.CCUsageQuery{
+					Period: period, Since: from, Until: to, Active: activeBlock, Location: store.Location(),
+				})
+				if err != nil {
+					return err
+				}
+				return encoder.Encode(report)
+			}
+
+			from, to := cli.Today(time.Now(), store.Location())
+			if !today && (since != "" || until != "") {
+				if from, to, err = parseRange(since, until, store.Location()); err != nil {
+					return err
+				}
+			}
+			report, err := cli.BuildReport(store, from, to)
+			if err != nil {
+				return err
+			}
+			if reportFormat == "json" {
+				return encoder.Encode(report)
+			}
+			report.Print(cmd.OutOrStdout())
+			return nil
+		},
+	}
+	reportCmd.Flags().BoolVar(&asJSON, "json", false, "print the report as JSON (same as --format json)")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "table", "table, json, or ccusage for ccusage's JSON output")
+	reportCmd.Flags().StringVar(&period, "period", services.CCUsageDaily, "daily, monthly or blocks; the ccusage report to print")
+	reportCmd.Flags().BoolVar(&activeBlock, "active", false, "only print the active block of a ccusage blocks report")
+	reportCmd.Flags().BoolVar(&today, "today", false, "report today's usage (the default without --since, except for ccusage)")
+	reportCmd.Flags().StringVar(&since, "since", "", "start of the range (RFC3339 or YYYY-MM-DD)")
+	reportCmd.Flags().StringVar(&until, "until", "", "end of the range, exclusive (RFC3339 or YYYY-MM-DD; default now)")
+
+	var format, output, project, bundlePath string
+	var content bool
+	exportCmd := &cobra.Command{
+		Use:   "export sessions|messages",
+		Short: "Write sessions or messages as CSV, JSON or JSON lines, or a bundle for another machine",
+		Long: "Write sessions or messages as CSV, JSON or JSON lines. With --bundle, write the database, or the usage " +
+			"between --since and --until, with the sync state to a .tar.zst bundle that claudeee import merges into another database.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if bundlePath != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		ValidArgs: []string{cli.ExportSessions, cli.ExportMessages},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := cli.Open(loadConfig(profile))
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			q := services.ExportQuery{Project: project, Content: content}
+			if since != "" || until != "" {
+				if q.From, q.To, err = parseRange(since, until, store.Location()); err != nil {
+					return err
+				}
+			}
+			if bundlePath != "" {
+				manifest, err := cli.ExportBundle(store, bundlePath, q.From, q.To)
+				if err != nil {
+					return err
+				}
+				var rows int64
+				for _, table := range manifest.Tables {
+					rows += table.Rows
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d rows of %d tables to %s\n", rows, len(manifest.Tables), bundlePath)
+				return nil
+			}
+			out := cmd.OutOrStdout()
+			if output != "" && output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+			count, err := cli.Export(store, out, args[0], format, q)
+			if err != nil {
+				return err
+			}
+			if output != "" && output != "-" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d %s to %s\n", count, args[0], output)
+			}
+			return nil
+		},
+	}
+	exportCmd.Flags().StringVar(&format, "format", services.ExportCSV, "csv, json or jsonl")
+	exportCmd.Flags().StringVarP(&output, "output", "o", "", "file to write (default stdout)")
+	exportCmd.Flags().StringVar(&project, "project", "", "only export this project")
+	exportCmd.Flags().StringVar(&since, "since", "", "start of the range (RFC3339 or YYYY-MM-DD)")
+	exportCmd.Flags().StringVar(&until, "until", "", "end of the range, exclusive (RFC3339 or YYYY-MM-DD)")
+	exportCmd.Flags().BoolVar(&content, "content", false, "include message content in message exports")
+	exportCmd.Flags().StringVar(&bundlePath, "bundle", "", "write a bundle of the database to this file, e.g. claudeee.tar.zst")
+
+	var agentServer, agentToken string
+	var agentInterval time.Duration
+	var once bool
+	agentCmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Push new Claude log entries to a central claudeee server",
+		Long:  "Push new Claude log entries to a central claudeee server's /api/ingest endpoint. The agent keeps no database; it records how far each log file was pushed in agent-state.json in the data directory.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig(profile)
+			if agentServer == "" {
+				agentServer = cfg.Agent.Server
+			}
+			if agentToken == "" {
+				agentToken = cfg.Agent.Token
+			}
+			if agentServer == "" || agentToken == "" {
+				return fmt.Errorf("a server and token are required (--server and --token, or CLAUDEEE_AGENT_SERVER and CLAUDEEE_AGENT_TOKEN)")
+			}
+			if agentInterval <= 0 {
+				agentInterval = time.Duration(cfg.Agent.IntervalSeconds) * time.Second
+			}
+
+			pusher := agent.New(strings.TrimRight(agentServer, "/"), agentToken, services.LogSourceConfig{
+				Roots:           cfg.ClaudeDirs,
+				IncludeProjects: cfg.IncludeProjects,
+				ExcludeProjects: cfg.ExcludeProjects,
+			}, filepath.Join(cfg.DataDir, agent.StateFileName))
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if !once {
+				log.Printf("Pushing log entries to %s every %s", pusher.Server, agentInterval)
+				return pusher.Run(ctx, agentInterval)
+			}
+			stats, err := pusher.RunOnce(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Pushed %d entries from %d files (%d new, %d already stored)\n",
+				stats.Entries, stats.Files, stats.Ingested, stats.Duplicates)
+			return nil
+		},
+	}
+	agentCmd.Flags().StringVar(&agentServer, "server", "", "base URL of the claudeee server (default $CLAUDEEE_AGENT_SERVER)")
+	agentCmd.Flags().StringVar(&agentToken, "token", "", "ingest token for the server (default $CLAUDEEE_AGENT_TOKEN)")
+	agentCmd.Flags().DurationVar(&agentInterval, "interval", 0, "time between pushes (default $CLAUDEEE_AGENT_INTERVAL_SECONDS or 1m)")
+	agentCmd.Flags().BoolVar(&once, "once", false, "push once and exit")
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore <backup>",
+		Short: "Replace the database with a backup while the server is stopped",
+		Long:  "Replace the database with a backup, given by name from the backup directory or by path. The current database is kept next to the restored one as claudeee.db.pre-restore-<time>.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			restored, err := cli.Restore(loadConfig(profile), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Restored backup %s from %s\n", restored.Backup.Name, restored.Backup.CreatedAt.Local().Format(time.RFC3339))
+			if restored.Previous != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "The previous database was moved to %s\n", restored.Previous)
+			}
+			return nil
+		},
+	}
+
+	var onConflict string
+	importCmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Merge a bundle, a ccusage report or a claudeee message export into the database",
+		Long: "Merge data from elsewhere into the database: a bundle written by claudeee export --bundle, a ccusage daily or " +
+			"monthly JSON report (ccusage daily --json), or messages written by claudeee export messages as json or jsonl. " +
+			"Days of a ccusage report the database already has Claude usage for are left out, since ccusage read the same logs.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := cli.Open(loadConfig(profile))
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			if cli.IsBundle(args[0]) {
+				result, err := cli.ImportBundle(store, args[0], onConflict)
+				if err != nil {
+					return err
+				}
+				var imported int64
+				for _, table := range result.Tables {
+					imported += table.Imported
+					if table.Skipped != "" && table.Rows > 0 {
+						fmt.Fprintf(cmd.ErrOrStderr(), "Skipped %s: %s\n", table.Name, table.Skipped)
+					}
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Imported %d rows of %d tables from a bundle written %s\n",
+					imported, len(result.Tables), result.Manifest.CreatedAt.Local().Format(time.RFC3339))
+				return nil
+			}
+
+			result, err := cli.Import(store, args[0], onConflict)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Imported %d of %d records from a %s file (%d replaced, %d already stored)\n",
+				result.Imported+result.Replaced, result.Received, result.Format, result.Replaced, result.Skipped)
+			if len(result.Overlapping) > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Left out %d periods with synced usage: %s\n", len(result.Overlapping), strings.Join(result.Overlapping, ", "))
+			}
+			return nil
+		},
+	}
+	importCmd.Flags().StringVar(&onConflict, "on-conflict", services.ImportSkip, "skip keeps messages already stored, replace overwrites them")
+
+	statuslineCmd := &cobra.Command{
+		Use:   "statusline",
+		Short: "Print window utilization and cost for Claude Code's status line",
+		Long: "Print window utilization, reset time and today's cost on one line. Set it as Claude Code's statusLine command; " +
+			"the session's model and cost that Claude Code passes on stdin are added. The numbers come from the running server, or from the database when none runs.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig(profile)
+			input := &cli.StatuslineInput{}
+			// Claude Code pipes its context; run by hand, stdin is the terminal
+			if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+				if input, err = cli.ReadStatuslineInput(cmd.InOrStdin()); err != nil {
+					slog.Warn("Ignoring statusline input", "err", err)
+					input = &cli.StatuslineInput{}
+				}
+			}
+
+			line, err := cli.Statusline(cmd.Context(), cfg, time.Now())
+			if err != nil {
+				// The status line shows stdout only, so say something there too
+				fmt.Fprintln(cmd.OutOrStdout(), "⚡ claudeee unavailable")
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), cli.FormatStatusline(input, line))
+			return nil
+		},
+	}
+
+	mcpCmd := &cobra.Command{
+		Use:   "mcp",
+		Short: "Serve usage tools to Claude Code and other agents over the Model Context Protocol",
+		Long: "Speak the Model Context Protocol on stdin and stdout, offering the tools get_remaining_tokens, get_session_cost and get_usage_summary. " +
+			"Register it with `claude mcp add claudeee -- /path/to/claudeee-server mcp`. The tools read the running server, or the database when none runs.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig(profile)
+			// stdout carries the protocol; logs go to stderr
+			return cli.NewMCPServer(cfg).Serve(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	root.AddCommand(serveCmd, syncCmd, reportCmd, exportCmd, importCmd, agentCmd, restoreCmd, statuslineCmd, mcpCmd)
+	root.SetArgs(cli.NormalizeArgs(os.Args[1:]))
+	if err := root.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func loadConfig(profile string) *config.Config {
+	cfg, err := config.Load(profile)
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+	// Logs go to stderr so command output on stdout stays clean
+	if err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		log.Fatal("Failed to set up logging:", err)
+	}
+	if cfg.File != "" {
+		slog.Debug("Read configuration file", "file", cfg.File)
+	}
+	if err := pricingWatcher(cfg).Load(); err != nil {
+		log.Fatal("Failed to load pricing:", err)
+	}
+	if cfg.PricingUpdates {
+		if _, err := pricingUpdater(cfg).LoadCache(); err != nil {
+			slog.Warn("Failed to load cached registry prices", "err", err)
+		}
+	}
+	return cfg
+}
+
+// pricingUpdater fetches registry prices into the data directory's cache
+func pricingUpdater(cfg *config.Config) *services.PricingUpdater {
+	return services.NewPricingUpdater(cfg.PricingURL, filepath.Join(cfg.DataDir, services.PricingRegistryCacheName))
+}
+
+// pricingWatcher applies the prices of the configuration and the pricing file
+func pricingWatcher(cfg *config.Config) *services.PricingWatcher {
+	prices := make(map[string]services.ModelPrice, len(cfg.Pricing))
+	for model, price := range cfg.Pricing {
+		prices[model] = services.ModelPrice(price)
+	}
+	return services.NewPricingWatcher(cfg.PricingFile, prices)
+}
+
+// parseRange reads RFC3339 times or YYYY-MM-DD dates in loc. A date as the
+// end includes that whole day; an empty end means now.
+func parseRange(since, until string, loc *time.Location) (time.Time, time.Time, error) {
+	parse := func(value string, end bool) (time.Time, error) {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339 or YYYY-MM-DD", value)
+		}
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	var from, to time.Time
+	var err error
+	if since != "" {
+		if from, err = parse(since, false); err != nil {
+			return from, to, err
+		}
+	}
+	to = time.Now().In(loc)
+	if until != "" {
+		if to, err = parse(until, true); err != nil {
+			return from, to, err
+		}
+	}
+	if !from.IsZero() && !to.After(from) {
+		return from, to, fmt.Errorf("--until must be after --since")
+	}
+	return from, to, nil
+}
+
+// runServer starts the API server and blocks until it stops
+func runServer(cfg *config.Config, opts serveOptions) {
+	if opts.readOnlyAPI {
+		cfg.ReadOnlyAPI = true
+	}
+	if opts.readOnly {
+		cfg.ReadOnly = true
+	}
+	if cfg.ReadOnly {
+		if opts.exportAndWipe {
+			log.Fatal("--export-and-wipe cannot be used with a read-only database")
+		}
+		// Nothing can be written, so every mutating request is rejected
+		cfg.ReadOnlyAPI = true
+	}
+
+	if cfg.Log.File != "" {
+		logWriter, err := logging.NewRotatingWriter(cfg.Log.File, cfg.Log.MaxSizeMB, cfg.Log.MaxAgeDays, cfg.Log.MaxBackups)
+		if err != nil {
+			log.Fatal("Failed to open log file:", err)
+		}
+		defer logWriter.Close()
+
+		out := io.MultiWriter(os.Stdout, logWriter)
+		if err := logging.Setup(out, cfg.Log.Level, cfg.Log.Format); err != nil {
+			log.Fatal("Failed to set up logging:", err)
+		}
+		gin.DefaultWriter = out
+		gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, logWriter)
+		log.Printf("Writing logs to %s", cfg.Log.File)
+	}
+
+	// Read-only instances write nothing, so any number may share a profile
+	var lock *instance.Lock
+	if !cfg.ReadOnly {
+		lock = acquireInstanceLock(cfg)
+		if lock == nil {
+			return
+		}
+		defer lock.Release()
+	}
+
+	if cfg.Profile != config.DefaultProfile {
+		log.Printf("Using profile %q (%s)", cfg.Profile, cfg.DataDir)
+	}
+
+	if cfg.DBDriver != database.DriverDuckDB {
+		log.Printf("Using %s database", cfg.DBDriver)
+	}
+	var db *sql.DB
+	var err error
+	if cfg.ReadOnly {
+		log.Printf("Opening the database read-only: syncs and background jobs are off")
+		db, err = database.OpenReadOnly(cfg.DBDriver, cfg.DatabaseDSN())
+	} else {
+		db, err = database.Initialize(cfg.DBDriver, cfg.DatabaseDSN())
+	}
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 	defer db.Close()
 
+	if opts.exportAndWipe {
+		if cfg.DBDriver != database.DriverDuckDB {
+			log.Fatalf("--export-and-wipe requires the %s database driver", database.DriverDuckDB)
+		}
+		if err := runExportAndWipe(cfg, db, opts.archivePath); err != nil {
+			log.Fatal("Export and wipe failed:", err)
+		}
+		lock.Release()
+		return
+	}
+
+	// All writes go through a single writer so concurrent syncs and jobs don't conflict
+	writes := services.NewWriteQueue(64)
+	defer writes.Close()
+
 	tokenService := services.NewTokenService(db)
 	sessionService := services.NewSessionService(db)
+	services.SetWindowPolicy(services.WindowPolicy{
+		Duration: time.Duration(cfg.WindowHours) * time.Hour,
+		Anchor:   services.WindowAnchor(cfg.WindowAnchor),
+	})
+	services.SetSessionIdleTimeout(time.Duration(cfg.SessionIdleMinutes) * time.Minute)
 	sessionWindowService := services.NewSessionWindowService(db)
+
+	featureFlags := services.NewFeatureFlagService(db, cfg.Features)
+	featureFlags.SetWriteQueue(writes)
+	if err := featureFlags.LoadOverrides(); err != nil {
+		log.Fatal("Failed to initialize feature flags:", err)
+	}
 	
+	defaults := services.DefaultRuntimeSettings()
+	defaults.Plan = cfg.Plan
+	defaults.PlanTokenLimit = cfg.PlanTokenLimit
+	defaults.Timezone = cfg.Timezone
+	defaults.SyncIntervalMinutes = cfg.SyncIntervalMinutes
+	defaults.ContentPolicy = cfg.ContentPolicy
+	defaults.ContentMaxKB = cfg.ContentMaxKB
+	defaults.ContentCompression = cfg.ContentCompression
+	defaults.ContentRetentionDays = cfg.ContentRetentionDays
+	defaults.RedactSecrets = cfg.RedactSecrets
+	if cfg.PrivacyMode {
+		defaults.PrivacyMode = true
+		defaults.ContentPolicy = services.ContentPolicyMetadata
+	}
+	if err := defaults.Validate(); err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+	settingsService := services.NewSettingsService(db, defaults)
+	settingsService.SetWriteQueue(writes)
+	if err := settingsService.Load(); err != nil {
+		log.Fatal("Failed to initialize settings:", err)
+	}
+	contentCipher, err := newContentCipher(cfg)
+	if err != nil {
+		log.Fatal("Invalid content key:", err)
+	}
+	sessionService.SetContentCipher(contentCipher)
+	// The writing instance checks the key, encrypts or strips stored content
+	// and titles sessions; a read-only one only decrypts what it reads
+	if !cfg.ReadOnly {
+		prepareStoredContent(cfg, db, writes, sessionService, settingsService, contentCipher)
+	}
 	handler := handlers.NewHandler(tokenService, sessionService, sessionWindowService)
+	handler.SetWriteQueue(writes)
+	handler.SetContentCipher(contentCipher)
+	redactor, err := cli.NewRedactor(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	handler.SetRedactor(redactor)
+	handler.SetForecastService(services.NewForecastService(db))
+	// Costs follow edits to the pricing file without a restart
+	pricing := pricingWatcher(cfg)
+	pricing.OnReload(handler.InvalidateCache)
+	if err := pricing.Start(); err != nil {
+		slog.Warn("Failed to watch pricing file", "file", cfg.PricingFile, "err", err)
+	}
+	defer pricing.Stop()
+	if cfg.PricingUpdates {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			models, err := pricingUpdater(cfg).Update(ctx)
+			if err != nil {
+				slog.Warn("Failed to update model prices; using cached or built-in prices", "url", cfg.PricingURL, "err", err)
+				return
+			}
+			log.Printf("Updated prices of %d models from %s", models, cfg.PricingURL)
+			handler.InvalidateCache()
+		}()
+	}
+	settingsService.Subscribe(func(settings services.RuntimeSettings) {
+		if err := tokenService.SetPlanLimit(settings.Plan, settings.UsageLimit()); err != nil {
+			slog.Warn("Failed to apply plan limit", "err", err)
+		}
+		handler.SetContentPolicy(settings.ContentStoragePolicy())
+		handler.SetRedactSecrets(settings.RedactSecrets)
+		// The usage limit is part of cached token usage
+		handler.InvalidateCache()
+	})
+	// Sessions belong to the user whose log directory they were read from
+	if !cfg.ReadOnly {
+		if err := cli.RegisterUsers(db, cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+	userService := services.NewUserService(db)
+	handler.SetLogSources(services.LogSourceConfig{
+		Roots:           cfg.LogRoots(),
+		IncludeProjects: cfg.IncludeProjects,
+		ExcludeProjects: cfg.ExcludeProjects,
+		CodexDirs:       cfg.CodexDirs,
+		GeminiLogs:      cfg.GeminiLogs,
+		RootUsers:       cfg.RootUsers(),
+		DefaultUser:     cfg.User,
+	})
+	handler.SetSyncWorkers(cfg.SyncWorkers)
+	handler.SetFlagMissingSources(cfg.FlagMissingSources)
+	syncJobs := services.NewSyncJobQueue(func(ctx context.Context) (*models.SyncStats, error) {
+		return handler.RunSync(ctx, db)
+	})
+	handler.SetSyncJobQueue(syncJobs)
+	syncProgress := services.NewSyncProgressBroker()
+	handler.SetSyncProgress(func(p services.SyncProgress) {
+		syncJobs.RecordProgress(p)
+		syncProgress.Publish(p)
+	})
+	syncJobs.OnStarted(syncProgress.JobStarted)
+	syncJobs.OnFinished(syncProgress.JobFinished)
+
+	// Keep usage rollups current shortly after each sync
+	rollupService := services.NewRollupService(db)
+	rollups := services.NewRollupRefresher(rollupService, writes, 2*time.Second)
+	if !cfg.ReadOnly {
+		rollups.Start()
+		defer rollups.Stop()
+	}
+	syncJobs.OnFinished(func(services.SyncJob) {
+		rollups.Notify()
+	})
+
+	// Check budgets against the newly synced usage and notify webhooks
+	budgetService := services.NewBudgetService(db)
+	costForecasts := services.NewCostForecastService(db)
+	costForecasts.SetDefaultBilling(cfg.Billing)
+	statusline := services.NewStatuslineService(db)
+	ccusage := services.NewCCUsageService(db)
+	pricing.OnReload(statusline.Invalidate)
+	syncJobs.OnFinished(func(services.SyncJob) {
+		statusline.Invalidate()
+	})
+	settingsService.Subscribe(func(settings services.RuntimeSettings) {
+		costForecasts.SetPlan(settings.Plan)
+		if loc, err := time.LoadLocation(settings.Timezone); err == nil {
+			budgetService.SetLocation(loc)
+			costForecasts.SetLocation(loc)
+			statusline.SetLocation(loc)
+			ccusage.SetLocation(loc)
+			rollupService.SetLocation(loc)
+			handler.SetLocation(loc)
+		}
+	})
+	webhookService := services.NewWebhookService(db, webhook.NewSender())
+	webhookService.SetWriteQueue(writes)
+	defer webhookService.Close()
+	dispatch := func(event services.WebhookEvent) {
+		if err := webhookService.Dispatch(event); err != nil {
+			slog.Warn("Failed to send webhooks", "event", event.Type, "err", err)
+		}
+	}
+	syncJobs.OnFinished(func(job services.SyncJob) {
+		go func() {
+			if job.Status != services.SyncJobCompleted {
+				dispatch(services.SyncFailedEvent(job))
+				return
+			}
+
+			var alerts []services.BudgetAlert
+			err := writes.Do(func() error {
+				var err error
+				alerts, err = budgetService.Evaluate(time.Now())
+				return err
+			})
+			if err != nil {
+				slog.Warn("Failed to evaluate budgets", "err", err)
+			}
+			for _, alert := range alerts {
+				log.Printf("Budget %q reached %.0f%% (%g of %g %s)", alert.BudgetName, alert.Threshold*100, alert.Spent, alert.Amount, alert.Metric)
+				if event, ok := services.BudgetExceededEvent(alert); ok {
+					dispatch(event)
+				}
+			}
+
+			usage, err := tokenService.GetCurrentTokenUsage()
+			if err != nil {
+				slog.Warn("Failed to check window utilization", "err", err)
+				return
+			}
+			settings := settingsService.Get()
+			for _, event := range services.WindowThresholdEvents(usage, []float64{settings.WarningThreshold, settings.CriticalThreshold}) {
+				dispatch(event)
+			}
+		}()
+	})
+
+	// Notify the desktop as the window fills up and when it resets
+	notifications := services.NewNotificationService(tokenService.GetCurrentTokenUsage)
+	if desktop := cfg.Notifications.Desktop; desktop.Enabled {
+		if notifier, err := notify.New(); err != nil {
+			slog.Warn("Desktop notifications are off", "err", err)
+		} else {
+			notifications.AddChannel("desktop", notifier, services.NotificationChannelSettings{
+				Thresholds:  desktop.Thresholds,
+				WindowReset: desktop.WindowReset,
+			})
+		}
+	}
+	if notifications.Enabled() {
+		settingsService.Subscribe(func(settings services.RuntimeSettings) {
+			notifications.SetDefaultThresholds(settings.WarningThreshold, settings.CriticalThreshold)
+		})
+		syncJobs.OnFinished(func(job services.SyncJob) {
+			if job.Status == services.SyncJobCompleted {
+				go notifications.Check(time.Now())
+			}
+		})
+		notifications.Start()
+		defer notifications.Stop()
+	}
+
+	// Sync automatically when Claude writes to its logs
+	logWatcher := services.NewLogWatcher(services.LogSourceConfig{
+		Roots:           cfg.LogRoots(),
+		IncludeProjects: cfg.IncludeProjects,
+		ExcludeProjects: cfg.ExcludeProjects,
+		CodexDirs:       cfg.CodexDirs,
+		GeminiLogs:      cfg.GeminiLogs,
+		RootUsers:       cfg.RootUsers(),
+		DefaultUser:     cfg.User,
+	}, syncJobs, time.Duration(cfg.WatchDebounceMillis)*time.Millisecond)
+	if cfg.WatchLogs && !cfg.ReadOnly {
+		if err := logWatcher.Start(); err != nil {
+			slog.Warn("Failed to start log watcher", "err", err)
+		}
+	}
+	defer logWatcher.Stop()
+
+	// Sync every sync_interval_minutes as well, catching whatever the
+	// watcher misses or everything when it is off
+	syncScheduler := services.NewSyncScheduler(syncJobs)
+	syncJobs.OnFinished(syncScheduler.JobFinished)
+	if !featureFlags.IsEnabled(services.FeatureScheduler) {
+		// Start paused; POST /api/scheduler/start still resumes it
+		syncScheduler.Stop()
+	}
+	// Follow the scheduler flag when an admin flips it at runtime
+	featureFlags.OnChange(func(name string, enabled bool) {
+		if name != services.FeatureScheduler {
+			return
+		}
+		if !enabled {
+			syncScheduler.Stop()
+		} else if err := syncScheduler.Start(); err != nil {
+			slog.Warn("Failed to start sync scheduler", "err", err)
+		}
+	})
+	if !cfg.ReadOnly {
+		settingsService.Subscribe(func(settings services.RuntimeSettings) {
+			syncScheduler.SetInterval(time.Duration(settings.SyncIntervalMinutes) * time.Minute)
+		})
+	}
+	defer syncScheduler.Stop()
+
+	auditService := services.NewAuditService(db)
+	var auditLogger *services.AuditLogger
+	if cfg.AuditLog && !cfg.ReadOnly {
+		auditLogger = services.NewAuditLogger(auditService, writes, 5*time.Second, time.Duration(cfg.AuditRetentionDays)*24*time.Hour)
+		auditLogger.Start()
+		defer auditLogger.Stop()
+	}
+	// Clear message content past the retention period; token counts stay
+	retention := services.NewRetentionPruner(db, writes, time.Hour)
+	settingsService.Subscribe(func(settings services.RuntimeSettings) {
+		retention.SetRetentionDays(settings.ContentRetentionDays)
+	})
+	if !cfg.ReadOnly {
+		retention.Start()
+		defer retention.Stop()
+		// Complete sessions that have gone quiet
+		sessionCloser := services.NewSessionCloser(db, writes, time.Minute)
+		sessionCloser.Start()
+		defer sessionCloser.Stop()
+	}
+	featureHandler := handlers.NewFeatureHandler(featureFlags)
+	configHandler := handlers.NewConfigHandler(settingsService)
+	messageHandler := handlers.NewMessageHandler(sessionService)
+	searchService := services.NewSearchService(db)
+	searchService.SetContentCipher(contentCipher)
+	searchHandler := handlers.NewSearchHandler(searchService)
+	exportService := services.NewExportService(db)
+	exportService.SetContentCipher(contentCipher)
+	exportHandler := handlers.NewExportHandler(exportService)
+	usageHandler := handlers.NewUsageHandler(rollupService, writes)
+	toolUsageHandler := handlers.NewToolUsageHandler(services.NewToolUsageService(db))
+	grafanaHandler := handlers.NewGrafanaHandler(services.NewGrafanaService(db))
+	modelUsageHandler := handlers.NewModelUsageHandler(services.NewModelUsageService(db))
+	transcriptHandler := handlers.NewTranscriptHandler(sessionService)
+	sessionTagHandler := handlers.NewSessionTagHandler(sessionService, services.NewTagService(db), writes)
+	costForecastHandler := handlers.NewCostForecastHandler(costForecasts)
+	statuslineHandler := handlers.NewStatuslineHandler(statusline, handler.CurrentTokenUsage)
+	reportHandler := handlers.NewReportHandler(ccusage)
+	statisticsHandler := handlers.NewStatisticsHandler(services.NewStatisticsService(db))
+	taskService := services.NewTaskService(db)
+	taskHandler := handlers.NewTaskHandler(taskService, handler.CurrentTokenUsage, writes)
+	if cfg.TaskExecution && !cfg.ReadOnly {
+		executor := services.NewTaskExecutor(taskService, handler.CurrentTokenUsage, services.ClaudeRunner(cfg.ClaudeCommand), writes)
+		if err := executor.RecoverInterrupted(); err != nil {
+			slog.Warn("Failed to recover interrupted tasks", "err", err)
+		}
+		defer executor.Stop()
+		taskHandler.SetExecutor(executor)
+	}
+	projectHandler := handlers.NewProjectHandler(services.NewProjectService(db))
+	userHandler := handlers.NewUserHandler(userService)
+	// Agents on other machines push the log entries of the token's user
+	ingestHandler := handlers.NewIngestHandler(cfg.IngestTokens, func(entries []services.IngestEntry, userID string) (*services.IngestResult, error) {
+		result, err := handler.Ingest(db, entries, userID)
+		if result != nil && result.Ingested > 0 {
+			rollups.Notify()
+		}
+		return result, err
+	})
+	budgetHandler := handlers.NewBudgetHandler(budgetService, writes)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	indexHandler := handlers.NewIndexHandler(services.NewIndexAdvisor(db), writes)
+	auditHandler := handlers.NewAuditHandler(auditService, auditLogger)
+	retentionHandler := handlers.NewRetentionHandler(retention)
+	backups := &backup.Store{Dir: cfg.Backup.Dir, Keep: cfg.Backup.Keep}
+	backupHandler := handlers.NewBackupHandler(db, writes, backups)
+	apiDocs := handlers.NewOpenAPIHandler()
+	if cfg.DBDriver == database.DriverDuckDB && cfg.Backup.IntervalHours > 0 && !cfg.ReadOnly {
+		backupScheduler := backup.NewScheduler(time.Duration(cfg.Backup.IntervalHours)*time.Hour, func() {
+			err := writes.Do(func() error {
+				created, err := backups.Create(db, time.Now())
+				if created != nil {
+					log.Printf("Backed up the database to %s", created.Path)
+				}
+				return err
+			})
+			if err != nil {
+				slog.Warn("Failed to back up the database", "dir", backups.Dir, "err", err)
+			}
+		})
+		backupScheduler.Start()
+		defer backupScheduler.Stop()
+	}
+	watcherHandler := handlers.NewWatcherHandler(logWatcher)
+	schedulerHandler := handlers.NewSchedulerHandler(syncScheduler)
+	offboardHandler := handlers.NewOffboardHandler(db, writes, cfg.DataDir, cfg.Profile, func() {
+		writes.Close()
+		db.Close()
+		removed, err := offboard.Wipe(cfg.DataDir, wipeKeep(cfg)...)
+		if err != nil {
+			log.Fatal("Failed to delete data:", err)
+		}
+		log.Printf("Deleted %d files from %s; exiting", len(removed), cfg.DataDir)
+		lock.Release()
+		os.Exit(0)
+	})
+
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		log.Fatal("Failed to configure authentication:", err)
+	}
+	authHandler := handlers.NewAuthHandler(authenticator, cfg.FrontendURL)
 
 	r := gin.Default()
 	
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:3000"
+	allowOrigin, err := handlers.NewOriginMatcher(cfg.CORSOrigins)
+	if err != nil {
+		log.Fatal("Invalid CLAUDEEE_CORS_ORIGINS:", err)
+	}
+	liveHandler := handlers.NewLiveHandler(handler.LiveSnapshot, allowOrigin)
+	syncJobs.OnFinished(liveHandler.SyncFinished)
+	var serverMetrics *metrics.Metrics
+	if cfg.Metrics {
+		serverMetrics = metrics.New(db, handler.CurrentTokenUsage)
+		syncJobs.OnFinished(serverMetrics.SyncFinished)
+		// Measure every request, including those rejected by authentication
+		r.Use(serverMetrics.Middleware())
+	}
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOriginFunc = allowOrigin
+	// Credentials are never sent to every origin; config rejects * while
+	// authentication is enabled
+	corsConfig.AllowCredentials = !cfg.AnyCORSOrigin()
+	r.Use(cors.New(corsConfig))
+	if auditLogger != nil {
+		r.Use(auditHandler.Middleware())
+	}
+	r.Use(authenticator.Middleware())
+	r.Use(handlers.UserScope(cfg.UserLogins()))
+	if cfg.ReadOnlyAPI {
+		log.Printf("API is read-only: mutating requests are rejected")
+		r.Use(handlers.ReadOnlyMiddleware())
 	}
-	
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{frontendURL}
-	config.AllowCredentials = true
-	r.Use(cors.New(config))
 	
 	r.Use(func(c *gin.Context) {
 		c.Set("db", db)
 		c.Next()
 	})
 
-	api := r.Group("/api")
+	// Routes live under /api/v1; apiversion.Handler maps the unversioned
+	// /api paths of earlier releases onto them
+	api := r.Group(apiversion.Prefix)
 	{
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
 				"status": "healthy",
 				"message": "Claudeee API is running",
+				"pid": os.Getpid(),
+				"read_only": cfg.ReadOnlyAPI,
+				"read_only_database": cfg.ReadOnly,
 			})
 		})
+		api.GET("/openapi.json", apiDocs.Spec)
+		if cfg.APIDocs {
+			api.GET("/docs", apiDocs.Docs)
+		}
 		
-		api.GET("/token-usage", handler.GetTokenUsage)
+		authRoutes := api.Group("/auth")
+		{
+			authRoutes.GET("/status", authHandler.GetStatus)
+			authRoutes.POST("/login", authHandler.Login)
+			authRoutes.POST("/logout", authHandler.Logout)
+			authRoutes.GET("/oidc/login", authHandler.BeginOIDCLogin)
+			authRoutes.GET("/oidc/callback", authHandler.OIDCCallback)
+		}
+		api.POST("/ingest", handlers.RequireFeature(featureFlags, services.FeatureCentralMode), ingestHandler.Ingest)
+
+		// Windows, plans, budgets, rollups and limit annotations cover every
+		// user, so a login scoped to one user cannot read them
+		allUsers := handlers.RejectUserScope()
+
+		api.GET("/token-usage", allUsers, handler.GetTokenUsage)
 		api.GET("/sessions", handler.GetSessions)
-		api.GET("/sessions/:id", handler.GetSessionDetails)
-		api.GET("/sessions/:id/activity", handler.GetSessionActivityReport)
+		api.GET("/sessions/:id", userHandler.RequireSessionOwner(), handler.GetSessionDetails)
+		api.GET("/sessions/:id/activity", userHandler.RequireSessionOwner(), handler.GetSessionActivityReport)
+		api.GET("/sessions/:id/messages", userHandler.RequireSessionOwner(), transcriptHandler.GetTranscript)
+		api.PATCH("/sessions/:id", userHandler.RequireSessionOwner(), sessionTagHandler.UpdateSession)
+		api.POST("/sessions/:id/tags", userHandler.RequireSessionOwner(), sessionTagHandler.AddTags)
+		api.DELETE("/sessions/:id/tags/:tag", userHandler.RequireSessionOwner(), sessionTagHandler.RemoveTag)
+		api.GET("/tags", sessionTagHandler.GetTags)
+		api.GET("/messages", messageHandler.GetMessages)
+		api.GET("/messages/export", messageHandler.ExportMessages)
+		api.GET("/search", searchHandler.Search)
+		api.GET("/export/sessions", exportHandler.ExportSessions)
+		api.GET("/export/messages", exportHandler.ExportMessages)
 		api.GET("/claude/sessions/recent", handler.GetRecentSessions)
-		api.GET("/claude/available-tokens", handler.GetAvailableTokens)
-		api.GET("/costs/current-month", handler.GetCurrentMonthCosts)
-		api.GET("/tasks", handler.GetTasks)
-		api.GET("/session-windows", handler.GetSessionWindows)
+		api.GET("/claude/available-tokens", allUsers, handler.GetAvailableTokens)
+		api.GET("/plan/utilization", allUsers, handler.GetPlanUtilization)
+		api.GET("/forecast", allUsers, handler.GetForecast)
+		api.GET("/costs/current-month", costForecastHandler.GetCurrentMonthCosts)
+		api.GET("/costs/forecast", costForecastHandler.GetCostForecast)
+		api.GET("/statusline", statuslineHandler.GetStatusline)
+		api.GET("/report", reportHandler.GetReport)
+		api.GET("/stats/percentiles", statisticsHandler.GetPercentiles)
+		api.GET("/tasks", taskHandler.GetTasks)
+		api.GET("/tasks/schedule", taskHandler.GetTaskSchedule)
+		api.GET("/tasks/:id", taskHandler.GetTask)
+		// Task prompts run as Claude Code on this host, so only admins write them
+		api.POST("/tasks", auth.RequireAdmin(), taskHandler.CreateTask)
+		api.PUT("/tasks/:id", auth.RequireAdmin(), taskHandler.UpdateTask)
+		api.DELETE("/tasks/:id", auth.RequireAdmin(), taskHandler.DeleteTask)
+		api.POST("/tasks/:id/run", auth.RequireAdmin(), taskHandler.RunTask)
+		api.POST("/tasks/:id/cancel", auth.RequireAdmin(), taskHandler.CancelTask)
+		api.GET("/session-windows", allUsers, handler.GetSessionWindows)
+		api.GET("/limit-events", allUsers, handler.GetLimitEvents)
+		api.GET("/usage/daily", allUsers, usageHandler.GetDailyUsage)
+		api.GET("/usage/heatmap", allUsers, usageHandler.GetUsageHeatmap)
+		api.GET("/usage/projects", allUsers, usageHandler.GetProjectUsage)
+		api.GET("/usage/by-model", modelUsageHandler.GetModelUsage)
+		api.GET("/tool-usage", toolUsageHandler.GetToolUsage)
+		api.GET("/grafana/", grafanaHandler.TestConnection)
+		api.POST("/grafana/search", grafanaHandler.Search)
+		api.POST("/grafana/query", grafanaHandler.Query)
+		api.POST("/grafana/annotations", allUsers, grafanaHandler.Annotations)
+		api.GET("/users", userHandler.GetUsers)
+		api.GET("/projects", projectHandler.GetProjects)
+		api.GET("/projects/:name/usage", projectHandler.GetProjectUsage)
+		api.GET("/budgets", allUsers, budgetHandler.GetBudgets)
+		api.GET("/budgets/:id", allUsers, budgetHandler.GetBudget)
+		api.POST("/budgets", auth.RequireAdmin(), budgetHandler.CreateBudget)
+		api.PUT("/budgets/:id", auth.RequireAdmin(), budgetHandler.UpdateBudget)
+		api.DELETE("/budgets/:id", auth.RequireAdmin(), budgetHandler.DeleteBudget)
+		api.GET("/alerts", allUsers, budgetHandler.GetAlerts)
+		webhookRoutes := api.Group("/webhooks", auth.RequireAdmin())
+		{
+			webhookRoutes.GET("", webhookHandler.GetWebhooks)
+			webhookRoutes.POST("", webhookHandler.CreateWebhook)
+			webhookRoutes.PUT("/:id", webhookHandler.UpdateWebhook)
+			webhookRoutes.DELETE("/:id", webhookHandler.DeleteWebhook)
+			webhookRoutes.GET("/:id/deliveries", webhookHandler.GetDeliveries)
+		}
 		api.POST("/sync-logs", handler.SyncLogs)
+		api.GET("/sync-jobs", handler.GetSyncJobs)
+		api.GET("/sync-jobs/:id", handler.GetSyncJob)
+		api.GET("/sync/errors", handler.GetSyncErrors)
+		api.GET("/sync-logs/stream", handlers.NewSyncStreamHandler(syncProgress).Stream)
+		api.GET("/ws", allUsers, liveHandler.Serve)
+		api.GET("/watcher", watcherHandler.GetStatus)
+		api.POST("/watcher/start", auth.RequireAdmin(), watcherHandler.Start)
+		api.POST("/watcher/stop", auth.RequireAdmin(), watcherHandler.Stop)
+		api.GET("/scheduler", schedulerHandler.GetStatus)
+		api.POST("/scheduler/start", auth.RequireAdmin(), schedulerHandler.Start)
+		api.POST("/scheduler/stop", auth.RequireAdmin(), schedulerHandler.Stop)
+		api.GET("/config", configHandler.GetConfig)
+		api.PATCH("/config", auth.RequireAdmin(), configHandler.UpdateConfig)
+
+		admin := api.Group("/admin", auth.RequireAdmin())
+		{
+			admin.GET("/features", featureHandler.GetFeatures)
+			admin.PUT("/features/:name", featureHandler.UpdateFeature)
+			admin.POST("/content/strip", handler.StripContent)
+			admin.POST("/content/backfill", handler.BackfillContent)
+			admin.POST("/redact", handler.RedactStored)
+			admin.POST("/prune", retentionHandler.Prune)
+			admin.POST("/tool-usage/backfill", handler.BackfillToolCalls)
+			admin.POST("/recompute", handler.RecomputeAggregates)
+			admin.GET("/redactions", handler.GetRedactionReport)
+			admin.GET("/duplicates", handler.GetDuplicateReport)
+			admin.POST("/rollups/rebuild", usageHandler.RebuildRollups)
+			admin.GET("/audit", auditHandler.GetAuditLog)
+			// Index advice, export-and-wipe and backups work on the local DuckDB file only
+			if cfg.DBDriver == database.DriverDuckDB {
+				admin.GET("/indexes", indexHandler.GetIndexReport)
+				admin.POST("/indexes/apply", indexHandler.ApplyIndexes)
+				admin.POST("/export-and-wipe", offboardHandler.ExportAndWipe)
+				admin.POST("/backup", backupHandler.Create)
+				admin.GET("/backups", backupHandler.List)
+			}
+		}
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if serverMetrics != nil {
+		r.GET("/metrics", serverMetrics.Handler())
 	}
-	
-	log.Printf("Server starting on :%s", port)
-	if err := r.Run(":" + port); err != nil {
+
+	// Profiling is off unless the pprof feature flag is enabled. Profiles hold
+	// the content key and auth secret, so only admins may read them.
+	debug := r.Group("/debug/pprof", handlers.RequireFeature(featureFlags, services.FeaturePprof), auth.RequireAdmin())
+	handlers.RegisterPprof(debug)
+
+	// The OpenAPI document describes the routes registered above
+	undocumented, err := apiDocs.Build(r.Routes())
+	if err != nil {
+		log.Fatal("Failed to build OpenAPI document:", err)
+	}
+	for _, route := range undocumented {
+		slog.Warn("API route is missing from the OpenAPI document", "method", route.Method, "path", route.Path)
+	}
+
+	listener, err := instance.Listen("", cfg.Port, cfg.PortFallbackAttempts)
+	if err != nil {
+		log.Fatal("Failed to start server:", err)
+	}
+	port := instance.ListenerPort(listener)
+	if port != cfg.Port {
+		log.Printf("Port %d is already in use, falling back to port %d", cfg.Port, port)
+	}
+
+	// Publish the bound address so the CLI and frontend can find this server
+	serverInfo := instance.ServerInfo{
+		PID:       os.Getpid(),
+		Port:      port,
+		URL:       fmt.Sprintf("http://localhost:%d", port),
+		StartedAt: time.Now().UTC(),
+	}
+	if err := instance.WriteServerInfo(cfg.DataDir, serverInfo); err != nil {
+		slog.Warn("Failed to write discovery file", "err", err)
+	}
+	defer instance.RemoveServerInfo(cfg.DataDir, serverInfo.PID)
+
+	server := &http.Server{Handler: apiversion.Handler(r)}
+	// Server-Sent Event streams would otherwise hold Shutdown open
+	server.RegisterOnShutdown(syncProgress.Close)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+	log.Printf("Server starting on :%d", port)
+
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err := <-serveErr:
 		log.Fatal("Failed to start server:", err)
+	case <-signals.Done():
+	}
+	stop()
+
+	// Stop new syncs, let the running one save its progress, then drain
+	// requests; the deferred closes flush the remaining writes
+	log.Printf("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	logWatcher.Stop()
+	if err := syncJobs.Shutdown(ctx); err != nil {
+		slog.Warn("Sync did not stop in time", "err", err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Warn("Failed to finish open requests", "err", err)
+	}
+}
+
+// prepareStoredContent brings stored content in line with the content key and
+// privacy mode and titles sessions synced before titles existed
+func prepareStoredContent(cfg *config.Config, db *sql.DB, writes *services.WriteQueue, sessionService *services.SessionService, settingsService *services.SettingsService, contentCipher *services.ContentCipher) {
+	var contentEncrypted bool
+	err := writes.Do(func() error {
+		var err error
+		contentEncrypted, err = services.CheckContentKey(db, contentCipher)
+		return err
+	})
+	if err != nil {
+		log.Fatal("Failed to check content key:", err)
+	}
+	if contentCipher != nil {
+		var encrypted int64
+		err := writes.Do(func() error {
+			var err error
+			encrypted, err = services.EncryptStoredContent(db, contentCipher)
+			return err
+		})
+		if err != nil {
+			log.Fatal("Failed to encrypt stored content:", err)
+		}
+		log.Printf("Content encryption enabled (encrypted %d previously stored messages)", encrypted)
+	} else if contentEncrypted {
+		slog.Warn("Stored content is encrypted but no content key is configured", "placeholder", services.EncryptedContentPlaceholder)
+	}
+	if cfg.PrivacyMode {
+		// Remove any conversation text stored before privacy mode was enabled
+		var stripped int64
+		err := writes.Do(func() error {
+			var err error
+			stripped, err = services.StripContent(db, settingsService.Get().ContentStoragePolicy(), contentCipher)
+			return err
+		})
+		if err != nil {
+			log.Fatal("Failed to remove stored content for privacy mode:", err)
+		}
+		log.Printf("Privacy mode enabled: message content is not stored (removed content from %d messages)", stripped)
+	}
+	// Title sessions synced before titles existed; new ones are titled as they sync
+	go func() {
+		var titled int
+		err := writes.Do(func() error {
+			var err error
+			titled, err = sessionService.BackfillSessionTitles()
+			return err
+		})
+		if err != nil {
+			slog.Warn("Failed to title sessions", "err", err)
+		} else if titled > 0 {
+			log.Printf("Titled %d previously synced sessions", titled)
+		}
+	}()
+}
+
+// acquireInstanceLock makes sure only one server uses the database at a time.
+// It returns nil when this process must not start a server of its own.
+func acquireInstanceLock(cfg *config.Config) *instance.Lock {
+	lock, err := instance.AcquireLock(cfg.DataDir)
+	if err == nil {
+		return lock
+	}
+	if !errors.Is(err, instance.ErrAlreadyRunning) {
+		log.Fatal("Failed to acquire instance lock:", err)
+	}
+
+	running, inspectErr := instance.Inspect(cfg.DataDir)
+	if inspectErr != nil || running == nil {
+		log.Fatal("Failed to acquire instance lock:", err)
+	}
+
+	address := "an unknown address"
+	if running.Info != nil {
+		address = running.Info.URL
+	}
+
+	switch cfg.InstanceMode {
+	case config.InstanceModeTakeover:
+		// Only stop a process that proves it is the server; the PID in a
+		// stale lock may since have been reused by something else
+		if running.Verified {
+			log.Printf("Stopping running instance (pid %d) at %s", running.PID, address)
+			if err := instance.Terminate(running.PID, 10*time.Second); err != nil {
+				log.Fatal("Failed to take over running instance:", err)
+			}
+		} else {
+			log.Printf("Instance lock names pid %d, which is not answering as claudeee at %s; removing the stale lock", running.PID, address)
+			if err := instance.RemoveStaleLock(cfg.DataDir, running.PID); err != nil {
+				log.Fatal("Failed to take over running instance:", err)
+			}
+			instance.RemoveServerInfo(cfg.DataDir, running.PID)
+		}
+		lock, err = instance.AcquireLock(cfg.DataDir)
+		if err != nil {
+			log.Fatal("Failed to acquire instance lock after takeover:", err)
+		}
+		log.Printf("Took over from previous instance (pid %d)", running.PID)
+		return lock
+
+	case config.InstanceModeProxy:
+		if !running.Healthy {
+			log.Fatalf("claudeee is already running (pid %d) but is not responding; set CLAUDEEE_INSTANCE_MODE=takeover to replace it", running.PID)
+		}
+		runProxy(cfg, running.Info)
+		return nil
+
+	default:
+		if running.Healthy {
+			log.Fatalf("claudeee is already running (pid %d) at %s; set CLAUDEEE_INSTANCE_MODE=takeover to replace it or proxy to forward to it", running.PID, address)
+		}
+		log.Fatalf("claudeee is already running (pid %d) but is not responding; set CLAUDEEE_INSTANCE_MODE=takeover to replace it", running.PID)
+	}
+	return nil
+}
+
+// runProxy serves the requested port by forwarding to the running instance
+func runProxy(cfg *config.Config, target *instance.ServerInfo) {
+	if target.Port == cfg.Port {
+		log.Printf("claudeee is already serving port %d (pid %d), nothing to do", cfg.Port, target.PID)
+		return
+	}
+
+	proxy, err := instance.NewProxy(target.URL)
+	if err != nil {
+		log.Fatal("Failed to create proxy:", err)
+	}
+
+	listener, err := instance.Listen("", cfg.Port, 0)
+	if err != nil {
+		log.Fatal("Failed to start proxy:", err)
+	}
+
+	log.Printf("Proxying :%d to running instance at %s (pid %d)", cfg.Port, target.URL, target.PID)
+	if err := http.Serve(listener, proxy); err != nil {
+		log.Fatal("Proxy stopped:", err)
+	}
+}
+
+// newAuthenticator builds the optional login from the configuration
+func newAuthenticator(cfg *config.Config) (*auth.Authenticator, error) {
+	opts := auth.Options{
+		Mode:           cfg.Auth.Mode,
+		Username:       cfg.Auth.Username,
+		Password:       cfg.Auth.Password,
+		ViewerUsername: cfg.Auth.ViewerUsername,
+		ViewerPassword: cfg.Auth.ViewerPassword,
+		SessionTTL:     time.Duration(cfg.Auth.SessionTTLHours) * time.Hour,
+		SecureCookies:  cfg.Auth.SecureCookies,
+		OIDC: auth.OIDCOptions{
+			Issuer:        cfg.Auth.OIDCIssuer,
+			ClientID:      cfg.Auth.OIDCClientID,
+			ClientSecret:  cfg.Auth.OIDCClientSecret,
+			RedirectURL:   cfg.Auth.OIDCRedirectURL,
+			AllowedEmails: cfg.Auth.OIDCAllowedEmails,
+			AdminEmails:   cfg.Auth.OIDCAdminEmails,
+		},
+	}
+
+	if opts.Mode != auth.ModeNone && opts.Mode != "" {
+		if cfg.Auth.Secret != "" {
+			opts.Secret = []byte(cfg.Auth.Secret)
+		} else {
+			secret, err := auth.LoadOrCreateSecret(filepath.Join(cfg.DataDir, "auth_secret"))
+			if err != nil {
+				return nil, err
+			}
+			opts.Secret = secret
+		}
+		log.Printf("Authentication enabled (%s)", opts.Mode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return auth.New(ctx, opts)
+}
+
+// runExportAndWipe archives all data of the profile, then deletes its data directory
+func runExportAndWipe(cfg *config.Config, db *sql.DB, archivePath string) error {
+	if archivePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		archivePath = offboard.DefaultArchivePath(homeDir, cfg.Profile, time.Now())
+	}
+
+	manifest, err := offboard.Export(db, cfg.DataDir, cfg.Profile, archivePath)
+	if err != nil {
+		return fmt.Errorf("export failed, nothing was deleted: %w", err)
+	}
+	log.Printf("Exported %d tables to %s (sha256 %s)", len(manifest.Tables), archivePath, manifest.SHA256)
+
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	removed, err := offboard.Wipe(cfg.DataDir, wipeKeep(cfg)...)
+	if err != nil {
+		return err
+	}
+	log.Printf("Deleted %d files from %s", len(removed), cfg.DataDir)
+	return nil
+}
+
+// wipeKeep lists data directory entries that survive a wipe: the lock file,
+// released on exit, and for the default profile the other profiles' data
+func wipeKeep(cfg *config.Config) []string {
+	keep := []string{filepath.Base(instance.LockPath(cfg.DataDir))}
+	if cfg.Profile == config.DefaultProfile {
+		keep = append(keep, "profiles")
+	}
+	return keep
+}
+
+// newContentCipher returns the cipher for the configured content key, or nil
+// when content is stored in plain text
+func newContentCipher(cfg *config.Config) (*services.ContentCipher, error) {
+	key, err := services.LoadContentKey(cfg.ContentKey, cfg.ContentKeyFile)
+	if err != nil || key == nil {
+		return nil, err
 	}
-}
\ No newline at end of file
+	return services.NewContentCipher(key)
+}
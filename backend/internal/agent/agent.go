@@ -0,0 +1,243 @@
+// Package agent pushes log entries parsed from local Claude logs to a
+// central claudeee server's /api/ingest endpoint.
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+)
+
+// Push defaults
+const (
+	DefaultBatchSize = 500
+	// DefaultBatchBytes keeps requests well under the server's body limit
+	DefaultBatchBytes = 8 << 20
+	DefaultTimeout    = time.Minute
+)
+
+// StateFileName is the file in the data directory that records how far each
+// log file has been pushed
+const StateFileName = "agent-state.json"
+
+// Agent pushes the log entries appended since its previous pass. A file's
+// position only advances once the server has accepted its entries, and the
+// server ignores entries it already has, so a failed pass is simply repeated.
+type Agent struct {
+	Client *http.Client
+	// Server is the base URL of the claudeee server
+	Server string
+	// Token is one of the server's ingest tokens
+	Token string
+	// Host names this machine in the server's logs
+	Host       string
+	Sources    services.LogSourceConfig
+	StatePath  string
+	BatchSize  int
+	BatchBytes int
+}
+
+// Stats counts what one pass pushed
+type Stats struct {
+	Files      int
+	Entries    int
+	Ingested   int
+	Duplicates int
+}
+
+func New(server, token string, sources services.LogSourceConfig, statePath string) *Agent {
+	host, _ := os.Hostname()
+	return &Agent{
+		Client:     &http.Client{Timeout: DefaultTimeout},
+		Server:     server,
+		Token:      token,
+		Host:       host,
+		Sources:    sources,
+		StatePath:  statePath,
+		BatchSize:  DefaultBatchSize,
+		BatchBytes: DefaultBatchBytes,
+	}
+}
+
+// Run pushes new entries every interval until ctx is canceled. Failed passes
+// are logged and retried at the next interval.
+func (a *Agent) Run(ctx context.Context, interval time.Duration) error {
+	logger := logging.Component("agent")
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		stats, err := a.RunOnce(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			logger.Warn("Failed to push log entries", "server", a.Server, "err", err)
+		} else if stats.Entries > 0 {
+			logger.Info("Pushed log entries",
+				"files", stats.Files,
+				"entries", stats.Entries,
+				"ingested", stats.Ingested,
+				"duplicates", stats.Duplicates)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pushError is a failure to deliver a batch, which ends the pass, as opposed
+// to a file that could not be read, which is skipped
+type pushError struct {
+	err error
+}
+
+func (e *pushError) Error() string { return e.err.Error() }
+func (e *pushError) Unwrap() error { return e.err }
+
+// RunOnce pushes the entries appended to every log file since the last pass
+func (a *Agent) RunOnce(ctx context.Context) (*Stats, error) {
+	stats := &Stats{}
+	logger := logging.Component("agent")
+
+	st, err := loadState(a.StatePath, a.Server)
+	if err != nil {
+		return stats, err
+	}
+	files, err := services.DiscoverLogFiles(a.Sources)
+	if err != nil {
+		return stats, fmt.Errorf("failed to discover log files: %w", err)
+	}
+	st.prune(files)
+
+	var batch []json.RawMessage
+	batchBytes := 0
+	pushed := make(map[string]services.LogPosition)
+	flush := func() error {
+		if len(batch) > 0 {
+			result, err := a.push(ctx, batch)
+			if err != nil {
+				return &pushError{err}
+			}
+			stats.Entries += len(batch)
+			stats.Ingested += result.Ingested
+			stats.Duplicates += result.Duplicates
+			batch, batchBytes = nil, 0
+		}
+		for path, pos := range pushed {
+			st.Files[path] = pos
+			delete(pushed, path)
+		}
+		return st.save(a.StatePath)
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		start := st.Files[file.Path]
+		if start.Offset == file.Size {
+			continue
+		}
+		stats.Files++
+
+		project := filepath.Base(filepath.Dir(file.Path))
+		_, err := services.ReadLogEntries(file.Path, start, func(entries []models.LogEntry, end services.LogPosition) error {
+			for _, entry := range entries {
+				raw, err := json.Marshal(services.IngestEntry{Project: project, Entry: entry})
+				if err != nil {
+					return fmt.Errorf("failed to encode log entry: %w", err)
+				}
+				batch = append(batch, raw)
+				batchBytes += len(raw)
+				// Entries pushed before the end of the chunk are sent again
+				// if a later push fails, which the server ignores
+				if len(batch) >= a.BatchSize || batchBytes >= a.BatchBytes {
+					if err := flush(); err != nil {
+						return err
+					}
+				}
+			}
+			pushed[file.Path] = end
+			return nil
+		})
+		var failed *pushError
+		if errors.As(err, &failed) {
+			return stats, failed.err
+		}
+		if err != nil {
+			logger.Warn("Failed to read log file", "file", file.Path, "err", err)
+		}
+	}
+
+	if err := flush(); err != nil {
+		var failed *pushError
+		if errors.As(err, &failed) {
+			return stats, failed.err
+		}
+		return stats, err
+	}
+	return stats, nil
+}
+
+// ingestBody is services.IngestRequest with entries encoded ahead of time
+type ingestBody struct {
+	Host    string            `json:"host"`
+	Entries []json.RawMessage `json:"entries"`
+}
+
+// push posts a batch to the server and returns its result
+func (a *Agent) push(ctx context.Context, entries []json.RawMessage) (*services.IngestResult, error) {
+	body, err := json.Marshal(ingestBody{Host: a.Host, Entries: entries})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch: %w", err)
+	}
+	// The unversioned path also reaches servers older than /api/v1
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Server+"/api/ingest", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to push batch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error   string `json:"error"`
+			Details string `json:"details"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, &failure) == nil && failure.Error != "" {
+			if failure.Details != "" {
+				return nil, fmt.Errorf("server answered %d: %s: %s", resp.StatusCode, failure.Error, failure.Details)
+			}
+			return nil, fmt.Errorf("server answered %d: %s", resp.StatusCode, failure.Error)
+		}
+		return nil, fmt.Errorf("server answered %d", resp.StatusCode)
+	}
+
+	var result services.IngestResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode ingest result: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"claudeee-backend/internal/services"
+)
+
+// ingestServer records pushed entries, failing requests while fail is set
+type ingestServer struct {
+	mu       sync.Mutex
+	fail     bool
+	requests int
+	uuids    []string
+	projects []string
+}
+
+func (s *ingestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.URL.Path != "/api/ingest" || r.Header.Get("Authorization") != "Bearer secret" {
+		http.Error(w, `{"error":"Invalid ingest token"}`, http.StatusUnauthorized)
+		return
+	}
+	s.requests++
+	if s.fail {
+		http.Error(w, `{"error":"Failed to ingest log entries"}`, http.StatusInternalServerError)
+		return
+	}
+	var req services.IngestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, e := range req.Entries {
+		s.uuids = append(s.uuids, e.Entry.UUID)
+		s.projects = append(s.projects, e.Project)
+	}
+	json.NewEncoder(w).Encode(services.IngestResult{Received: len(req.Entries), Ingested: len(req.Entries)})
+}
+
+func logLine(n int) string {
+	return fmt.Sprintf(`{"uuid":"u-%d","sessionId":"s1","timestamp":"2024-01-02T09:00:%02dZ","message":{"role":"user","content":"hi"}}`+"\n", n, n)
+}
+
+func appendLines(t *testing.T, path string, from, to int) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for n := from; n < to; n++ {
+		if _, err := f.WriteString(logLine(n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunOncePushesNewEntries(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "-work-api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(root, "-work-api", "s1.jsonl")
+	appendLines(t, logPath, 0, 5)
+
+	server := &ingestServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	a := New(ts.URL, "secret", services.LogSourceConfig{Roots: []string{root}}, filepath.Join(t.TempDir(), StateFileName))
+	a.BatchSize = 2
+	ctx := context.Background()
+
+	stats, err := a.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if stats.Entries != 5 || stats.Ingested != 5 || len(server.uuids) != 5 || server.requests != 3 {
+		t.Errorf("Expected 5 entries in 3 batches, got %+v after %d requests", stats, server.requests)
+	}
+	if server.projects[0] != "-work-api" {
+		t.Errorf("Expected the project directory, got %q", server.projects[0])
+	}
+
+	// Nothing new, nothing sent
+	if stats, err := a.RunOnce(ctx); err != nil || stats.Entries != 0 || server.requests != 3 {
+		t.Errorf("Expected no push for unchanged logs, got %+v (%v)", stats, err)
+	}
+
+	// A failed push keeps the position, so the entries go out next pass
+	appendLines(t, logPath, 5, 6)
+	server.fail = true
+	if _, err := a.RunOnce(ctx); err == nil {
+		t.Error("Expected an error while the server fails")
+	}
+	server.fail = false
+	if stats, err := a.RunOnce(ctx); err != nil || stats.Entries != 1 {
+		t.Errorf("Expected the appended entry to be retried, got %+v (%v)", stats, err)
+	}
+	if last := server.uuids[len(server.uuids)-1]; len(server.uuids) != 6 || last != "u-5" {
+		t.Errorf("Expected u-5 pushed once more, got %v", server.uuids)
+	}
+
+	// Positions belong to a server; a new one gets everything
+	other := New(ts.URL+"/", "secret", a.Sources, a.StatePath)
+	if _, err := other.RunOnce(ctx); err == nil {
+		t.Error("Expected a bad server URL to fail")
+	}
+	if st, err := loadState(a.StatePath, ts.URL); err != nil || st.Files[logPath].Line != 6 {
+		t.Errorf("Expected the state of the first server to be kept, got %+v (%v)", st, err)
+	}
+}
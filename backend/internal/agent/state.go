@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+)
+
+// state records how far each log file has been pushed to one server
+type state struct {
+	// Server is the server the positions belong to; pushing to another
+	// server starts every file from the beginning
+	Server string                          `json:"server"`
+	Files  map[string]services.LogPosition `json:"files"`
+}
+
+// loadState reads the state file, starting afresh when it is missing or
+// belongs to another server
+func loadState(path, server string) (*state, error) {
+	fresh := &state{Server: server, Files: make(map[string]services.LogPosition)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fresh, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent state: %w", err)
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse agent state %s: %w", path, err)
+	}
+	if st.Server != server || st.Files == nil {
+		return fresh, nil
+	}
+	return &st, nil
+}
+
+// prune forgets files that no longer exist
+func (s *state) prune(files []models.FileInfo) {
+	exists := make(map[string]bool, len(files))
+	for _, file := range files {
+		exists[file.Path] = true
+	}
+	for path := range s.Files {
+		if !exists[path] {
+			delete(s.Files, path)
+		}
+	}
+}
+
+// save writes the state through a temporary file so a crash never leaves it half written
+func (s *state) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode agent state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create agent state directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write agent state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write agent state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package apiversion serves the API under versioned paths such as /api/v1.
+// Requests to the unversioned /api paths of earlier releases are still
+// answered, by the version named in their API-Version header or the oldest
+// one, and are marked deprecated so clients can move to a versioned path
+// before a breaking change reaches them.
+package apiversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Header names the version of a request to an unversioned path and, on every
+// API response, the version that answered it
+const Header = "API-Version"
+
+// Supported lists the API versions the server answers, oldest first
+var Supported = []string{"v1"}
+
+// Current is the version the server's routes are registered under
+const Current = "v1"
+
+// Prefix is the path of the current API version
+const Prefix = "/api/" + Current
+
+// Path returns the path of an API route under the current version, e.g.
+// Path("/health") is /api/v1/health
+func Path(route string) string {
+	return Prefix + route
+}
+
+// Handler routes API requests to their version. Requests to /api/vN pass
+// through when vN is supported; requests to other /api paths are rewritten
+// to the negotiated version and answered with Deprecation and Link headers
+// pointing at the versioned path.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api" && !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		route := strings.TrimPrefix(r.URL.Path, "/api")
+
+		if version, ok := pathVersion(route); ok {
+			if !isSupported(version) {
+				writeError(w, http.StatusNotFound, "Unknown API version "+version)
+				return
+			}
+			w.Header().Set(Header, version)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		version := Supported[0]
+		if requested := r.Header.Get(Header); requested != "" {
+			version = normalize(requested)
+			if !isSupported(version) {
+				writeError(w, http.StatusBadRequest, "Unknown API version "+requested)
+				return
+			}
+		}
+		versioned := "/api/" + version + route
+		w.Header().Set(Header, version)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", versioned))
+
+		r.URL.Path = versioned
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pathVersion returns the version segment of a route like /v1/sessions
+func pathVersion(route string) (string, bool) {
+	segment := strings.TrimPrefix(route, "/")
+	if i := strings.IndexByte(segment, '/'); i >= 0 {
+		segment = segment[:i]
+	}
+	if len(segment) < 2 || segment[0] != 'v' {
+		return "", false
+	}
+	for _, ch := range segment[1:] {
+		if ch < '0' || ch > '9' {
+			return "", false
+		}
+	}
+	return segment, true
+}
+
+// normalize accepts a version header of 1 as well as v1
+func normalize(version string) string {
+	version = strings.ToLower(strings.TrimSpace(version))
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version
+}
+
+func isSupported(version string) bool {
+	for _, v := range Supported {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   message,
+		"details": "supported versions: " + strings.Join(Supported, ", "),
+	})
+}
@@ -0,0 +1,51 @@
+package apiversion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var served string
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = r.URL.Path
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		wantStatus int
+		wantPath   string
+		deprecated bool
+	}{
+		{"versioned path", "/api/v1/sessions", "", http.StatusOK, "/api/v1/sessions", false},
+		{"unversioned path", "/api/sessions/abc", "", http.StatusOK, "/api/v1/sessions/abc", true},
+		{"version header", "/api/sessions", "1", http.StatusOK, "/api/v1/sessions", true},
+		{"unknown version header", "/api/sessions", "v9", http.StatusBadRequest, "", false},
+		{"unknown version path", "/api/v9/sessions", "", http.StatusNotFound, "", false},
+		{"outside the API", "/metrics", "", http.StatusOK, "/metrics", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			served = ""
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus || served != tt.wantPath {
+				t.Errorf("Expected %d serving %q, got %d serving %q", tt.wantStatus, tt.wantPath, w.Code, served)
+			}
+			if got := w.Header().Get("Deprecation") != ""; got != tt.deprecated {
+				t.Errorf("Expected deprecated %v, got headers %v", tt.deprecated, w.Header())
+			}
+			if tt.deprecated && w.Header().Get("Link") != "<"+tt.wantPath+">; rel=\"successor-version\"" {
+				t.Errorf("Expected a link to %s, got %q", tt.wantPath, w.Header().Get("Link"))
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+// Package auth provides optional login for the API and web UI, either with a
+// static username and password or through an OpenID Connect provider.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/apiversion"
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication modes
+const (
+	ModeNone  = "none"
+	ModeBasic = "basic"
+	ModeOIDC  = "oidc"
+)
+
+// SessionCookieName is the cookie holding the signed session of a logged-in user
+const SessionCookieName = "claudeee_session"
+
+// UserKey is the gin context key of the authenticated user name
+const UserKey = "auth_user"
+
+// Roles decide what an authenticated user may see. Viewers get usage and
+// token data; message content and admin operations are reserved for admins.
+const (
+	RoleAdmin  = "admin"
+	RoleViewer = "viewer"
+)
+
+// RoleKey is the gin context key of the authenticated user's role
+const RoleKey = "auth_role"
+
+// ErrInvalidCredentials is returned for a wrong username or password
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Options configure an Authenticator
+type Options struct {
+	Mode     string
+	Username string
+	Password string
+	// ViewerUsername and ViewerPassword optionally add a second static
+	// account with the viewer role
+	ViewerUsername string
+	ViewerPassword string
+	// Secret signs session cookies
+	Secret     []byte
+	SessionTTL time.Duration
+	// SecureCookies marks cookies Secure; enable when served over HTTPS
+	SecureCookies bool
+	OIDC          OIDCOptions
+}
+
+// OIDCOptions configure login through an OpenID Connect provider
+type OIDCOptions struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// AllowedEmails restricts login to these addresses, or to a domain with
+	// an "@example.com" entry; it must not be empty
+	AllowedEmails []string
+	// AdminEmails get the admin role and everyone else the viewer role; at
+	// least one is required
+	AdminEmails []string
+}
+
+// Authenticator checks credentials and issues session cookies
+type Authenticator struct {
+	opts Options
+	oidc *oidcProvider
+}
+
+// New validates opts and, for OIDC, discovers the provider configuration
+func New(ctx context.Context, opts Options) (*Authenticator, error) {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = 7 * 24 * time.Hour
+	}
+
+	a := &Authenticator{opts: opts}
+	switch opts.Mode {
+	case "", ModeNone:
+		a.opts.Mode = ModeNone
+		return a, nil
+	case ModeBasic:
+		if opts.Username == "" || opts.Password == "" {
+			return nil, fmt.Errorf("basic auth requires a username and password")
+		}
+		if opts.ViewerUsername != "" && (opts.ViewerPassword == "" || opts.ViewerUsername == opts.Username) {
+			return nil, fmt.Errorf("the viewer account needs its own username and a password")
+		}
+	case ModeOIDC:
+		provider, err := newOIDCProvider(ctx, opts.OIDC)
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = provider
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q (expected none, basic or oidc)", opts.Mode)
+	}
+
+	if len(opts.Secret) < 32 {
+		return nil, fmt.Errorf("auth secret must be at least 32 bytes")
+	}
+	return a, nil
+}
+
+// Mode returns the configured authentication mode
+func (a *Authenticator) Mode() string {
+	return a.opts.Mode
+}
+
+// Enabled reports whether requests must be authenticated
+func (a *Authenticator) Enabled() bool {
+	return a.opts.Mode != ModeNone
+}
+
+// CheckPassword verifies static credentials in constant time
+func (a *Authenticator) CheckPassword(username, password string) error {
+	if a.opts.Mode != ModeBasic {
+		return ErrInvalidCredentials
+	}
+	adminOK := matchCredentials(username, password, a.opts.Username, a.opts.Password)
+	viewerOK := 0
+	if a.opts.ViewerUsername != "" {
+		viewerOK = matchCredentials(username, password, a.opts.ViewerUsername, a.opts.ViewerPassword)
+	}
+	if adminOK|viewerOK != 1 {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func matchCredentials(username, password, wantUsername, wantPassword string) int {
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(wantUsername))
+	passOK := subtle.ConstantTimeCompare([]byte(password), []byte(wantPassword))
+	return userOK & passOK
+}
+
+// Role returns the role of an authenticated user. Without authentication
+// everyone is an admin.
+func (a *Authenticator) Role(user string) string {
+	switch a.opts.Mode {
+	case ModeBasic:
+		if user == a.opts.Username {
+			return RoleAdmin
+		}
+		return RoleViewer
+	case ModeOIDC:
+		for _, admin := range a.opts.OIDC.AdminEmails {
+			if strings.EqualFold(admin, user) {
+				return RoleAdmin
+			}
+		}
+		return RoleViewer
+	}
+	return RoleAdmin
+}
+
+// Middleware rejects unauthenticated requests with 401. The health check,
+// CORS preflights and the /api/auth endpoints stay public.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Enabled() {
+			c.Set(RoleKey, RoleAdmin)
+			c.Next()
+			return
+		}
+		if c.Request.Method == http.MethodOptions || isPublicPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		if user, ok := a.User(c.Request); ok {
+			c.Set(UserKey, user)
+			c.Set(RoleKey, a.Role(user))
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Authentication required",
+		})
+	}
+}
+
+// RequireAdmin rejects requests from users without the admin role with 403
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Admin role required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsAdmin reports whether the request was made by an admin, which includes
+// every request when authentication is disabled
+func IsAdmin(c *gin.Context) bool {
+	return c.GetString(RoleKey) == RoleAdmin
+}
+
+// isPublicPath reports whether a path skips login. The ingest route checks
+// the agent's ingest token itself.
+func isPublicPath(path string) bool {
+	return path == apiversion.Path("/health") || path == apiversion.Path("/ingest") || strings.HasPrefix(path, apiversion.Path("/auth/"))
+}
+
+// User returns the user authenticated by the request's session cookie or,
+// in basic mode, its Authorization header
+func (a *Authenticator) User(r *http.Request) (string, bool) {
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		if user, ok := a.verifySession(cookie.Value, time.Now()); ok {
+			return user, true
+		}
+	}
+	if a.opts.Mode == ModeBasic {
+		if username, password, ok := r.BasicAuth(); ok && a.CheckPassword(username, password) == nil {
+			return username, true
+		}
+	}
+	return "", false
+}
+
+// SessionCookie returns a cookie that logs user in for the session lifetime
+func (a *Authenticator) SessionCookie(user string) *http.Cookie {
+	expires := time.Now().Add(a.opts.SessionTTL)
+	return a.cookie(SessionCookieName, a.signSession(user, expires), expires)
+}
+
+// ClearSessionCookie returns a cookie that logs the user out
+func (a *Authenticator) ClearSessionCookie() *http.Cookie {
+	cookie := a.cookie(SessionCookieName, "", time.Unix(0, 0))
+	cookie.MaxAge = -1
+	return cookie
+}
+
+func (a *Authenticator) cookie(name, value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   a.opts.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// signSession encodes user and expiry as "user.expiry.signature"
+func (a *Authenticator) signSession(user string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(user)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	return payload + "." + a.sign(payload)
+}
+
+func (a *Authenticator) verifySession(value string, now time.Time) (string, bool) {
+	i := strings.LastIndex(value, ".")
+	if i < 0 {
+		return "", false
+	}
+	payload, signature := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(a.sign(payload))) {
+		return "", false
+	}
+
+	parts := strings.SplitN(payload, ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return "", false
+	}
+	user, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", false
+	}
+	return string(user), true
+}
+
+func (a *Authenticator) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.opts.Secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// LoadOrCreateSecret reads the session signing secret from path, creating a
+// random one on first use so sessions survive restarts
+func LoadOrCreateSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		secret, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(secret) < 32 {
+			return nil, fmt.Errorf("invalid auth secret in %s", path)
+		}
+		return secret, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read auth secret: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate auth secret: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(secret)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write auth secret: %w", err)
+	}
+	return secret, nil
+}
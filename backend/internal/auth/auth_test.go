@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newBasicAuthenticator(t *testing.T) *Authenticator {
+	a, err := New(context.Background(), Options{
+		Mode:     ModeBasic,
+		Username: "admin",
+		Password: "correct horse",
+		Secret:   []byte(strings.Repeat("s", 32)),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return a
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	invalid := []Options{
+		{Mode: "ldap"},
+		{Mode: ModeBasic, Username: "admin", Secret: []byte(strings.Repeat("s", 32))},
+		{Mode: ModeBasic, Username: "admin", Password: "pw", Secret: []byte("short")},
+		{Mode: ModeOIDC, Secret: []byte(strings.Repeat("s", 32))},
+		{Mode: ModeOIDC, Secret: []byte(strings.Repeat("s", 32)), OIDC: OIDCOptions{
+			Issuer: "https://idp.example.com", ClientID: "id", RedirectURL: "http://localhost/cb",
+			AdminEmails: []string{"lead@example.com"},
+		}},
+		{Mode: ModeOIDC, Secret: []byte(strings.Repeat("s", 32)), OIDC: OIDCOptions{
+			Issuer: "https://idp.example.com", ClientID: "id", RedirectURL: "http://localhost/cb",
+			AllowedEmails: []string{"@example.com"},
+		}},
+	}
+	for _, opts := range invalid {
+		if _, err := New(context.Background(), opts); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+
+	a, err := New(context.Background(), Options{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if a.Enabled() || a.Mode() != ModeNone {
+		t.Errorf("Expected auth to be disabled by default, got mode %q", a.Mode())
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	a := newBasicAuthenticator(t)
+	now := time.Now()
+
+	value := a.signSession("admin", now.Add(time.Hour))
+	if user, ok := a.verifySession(value, now); !ok || user != "admin" {
+		t.Errorf("Expected valid session for admin, got %q %v", user, ok)
+	}
+	if _, ok := a.verifySession(value, now.Add(2*time.Hour)); ok {
+		t.Error("Expected expired session to be rejected")
+	}
+
+	tampered := a.signSession("admin", now.Add(time.Hour))
+	tampered = strings.Replace(tampered, tampered[:5], "ZZZZZ", 1)
+	if _, ok := a.verifySession(tampered, now); ok {
+		t.Error("Expected tampered session to be rejected")
+	}
+
+	other, _ := New(context.Background(), Options{Mode: ModeBasic, Username: "admin", Password: "pw", Secret: []byte(strings.Repeat("x", 32))})
+	if _, ok := other.verifySession(value, now); ok {
+		t.Error("Expected session signed with another secret to be rejected")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := newBasicAuthenticator(t)
+
+	r := gin.New()
+	r.Use(a.Middleware())
+	r.GET("/api/v1/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/v1/auth/status", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/api/v1/sessions", func(c *gin.Context) { c.String(http.StatusOK, c.GetString(UserKey)) })
+
+	tests := []struct {
+		name   string
+		path   string
+		setup  func(*http.Request)
+		status int
+	}{
+		{"health is public", "/api/v1/health", nil, http.StatusOK},
+		{"auth endpoints are public", "/api/v1/auth/status", nil, http.StatusOK},
+		{"anonymous is rejected", "/api/v1/sessions", nil, http.StatusUnauthorized},
+		{"basic credentials", "/api/v1/sessions", func(r *http.Request) { r.SetBasicAuth("admin", "correct horse") }, http.StatusOK},
+		{"wrong password", "/api/v1/sessions", func(r *http.Request) { r.SetBasicAuth("admin", "wrong") }, http.StatusUnauthorized},
+		{"session cookie", "/api/v1/sessions", func(r *http.Request) { r.AddCookie(a.SessionCookie("admin")) }, http.StatusOK},
+		{"forged cookie", "/api/v1/sessions", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "YWRtaW4.9999999999.forged"})
+		}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestLoadOrCreateSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth_secret")
+
+	first, err := LoadOrCreateSecret(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateSecret failed: %v", err)
+	}
+	if len(first) != 32 {
+		t.Errorf("Expected 32 byte secret, got %d", len(first))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected secret file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected secret file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	second, err := LoadOrCreateSecret(path)
+	if err != nil {
+		t.Fatalf("LoadOrCreateSecret failed: %v", err)
+	}
+	if string(first) != string(second) {
+		t.Error("Expected the stored secret to be reused")
+	}
+}
+
+func TestRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a, err := New(context.Background(), Options{
+		Mode:           ModeBasic,
+		Username:       "admin",
+		Password:       "correct horse",
+		ViewerUsername: "team",
+		ViewerPassword: "battery staple",
+		Secret:         []byte(strings.Repeat("s", 32)),
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(a.Middleware())
+	r.GET("/api/v1/sessions", func(c *gin.Context) { c.String(http.StatusOK, c.GetString(RoleKey)) })
+	r.GET("/api/v1/admin/audit", RequireAdmin(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		path     string
+		status   int
+		body     string
+	}{
+		{"admin reads usage", "admin", "correct horse", "/api/v1/sessions", http.StatusOK, RoleAdmin},
+		{"viewer reads usage", "team", "battery staple", "/api/v1/sessions", http.StatusOK, RoleViewer},
+		{"admin uses admin endpoints", "admin", "correct horse", "/api/v1/admin/audit", http.StatusOK, ""},
+		{"viewer is kept out of admin endpoints", "team", "battery staple", "/api/v1/admin/audit", http.StatusForbidden, ""},
+		{"viewer name with admin password", "team", "correct horse", "/api/v1/sessions", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.SetBasicAuth(tt.user, tt.password)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("Expected role %q, got %q", tt.body, w.Body.String())
+			}
+		})
+	}
+
+	oidc := &Authenticator{opts: Options{Mode: ModeOIDC, OIDC: OIDCOptions{AdminEmails: []string{"Lead@example.com"}}}}
+	if oidc.Role("lead@example.com") != RoleAdmin || oidc.Role("dev@example.com") != RoleViewer {
+		t.Error("Expected OIDC roles to follow the admin email list")
+	}
+	disabled, _ := New(context.Background(), Options{})
+	if disabled.Role("") != RoleAdmin {
+		t.Error("Expected everyone to be an admin without authentication")
+	}
+}
+
+func TestOIDCAllowed(t *testing.T) {
+	p := &oidcProvider{allowedEmails: []string{"Me@example.org", "@example.com"}}
+	for email, want := range map[string]bool{
+		"me@example.org":       true,
+		"dev@Example.com":      true,
+		"dev@evil-example.com": false,
+		"@example.com":         false,
+		"other@example.org":    false,
+	} {
+		if got := p.allowed(email); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", email, got, want)
+		}
+	}
+	if (&oidcProvider{}).allowed("me@example.org") {
+		t.Error("Expected an empty allow-list to admit nobody")
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// stateCookie carries the OAuth state and nonce between login and callback
+const stateCookie = "claudeee_oidc_state"
+
+// ErrLoginDenied is returned when the provider account may not use claudeee
+var ErrLoginDenied = errors.New("login denied")
+
+type oidcProvider struct {
+	verifier      *oidc.IDTokenVerifier
+	oauth         oauth2.Config
+	allowedEmails []string
+}
+
+func newOIDCProvider(ctx context.Context, opts OIDCOptions) (*oidcProvider, error) {
+	if opts.Issuer == "" || opts.ClientID == "" || opts.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC requires an issuer, client ID and redirect URL")
+	}
+	if len(opts.AllowedEmails) == 0 {
+		return nil, fmt.Errorf("OIDC requires allowed emails or @domains; a public provider would otherwise admit any account")
+	}
+	if len(opts.AdminEmails) == 0 {
+		return nil, fmt.Errorf("OIDC requires at least one admin email")
+	}
+
+	provider, err := oidc.NewProvider(ctx, opts.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider %s: %w", opts.Issuer, err)
+	}
+
+	return &oidcProvider{
+		verifier: provider.Verifier(&oidc.Config{ClientID: opts.ClientID}),
+		oauth: oauth2.Config{
+			ClientID:     opts.ClientID,
+			ClientSecret: opts.ClientSecret,
+			RedirectURL:  opts.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		allowedEmails: opts.AllowedEmails,
+	}, nil
+}
+
+// BeginLogin returns the provider URL to redirect to and a cookie binding the
+// browser to this login attempt
+func (a *Authenticator) BeginLogin() (string, *http.Cookie, error) {
+	if a.oidc == nil {
+		return "", nil, fmt.Errorf("OIDC is not configured")
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	expires := time.Now().Add(10 * time.Minute)
+	cookie := a.cookie(stateCookie, a.signSession(state+":"+nonce, expires), expires)
+	return a.oidc.oauth.AuthCodeURL(state, oidc.Nonce(nonce)), cookie, nil
+}
+
+// FinishLogin validates the provider callback and returns the user's email
+func (a *Authenticator) FinishLogin(ctx context.Context, r *http.Request) (string, error) {
+	if a.oidc == nil {
+		return "", fmt.Errorf("OIDC is not configured")
+	}
+
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		return "", fmt.Errorf("login session expired")
+	}
+	pair, ok := a.verifySession(cookie.Value, time.Now())
+	state, nonce, found := strings.Cut(pair, ":")
+	if !ok || !found || r.URL.Query().Get("state") != state {
+		return "", fmt.Errorf("invalid login state")
+	}
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		return "", fmt.Errorf("provider returned %s", errParam)
+	}
+
+	token, err := a.oidc.oauth.Exchange(ctx, r.URL.Query().Get("code"))
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", fmt.Errorf("provider response has no id_token")
+	}
+	idToken, err := a.oidc.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return "", fmt.Errorf("invalid login nonce")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return "", fmt.Errorf("failed to read claims: %w", err)
+	}
+	if claims.Email == "" || (claims.EmailVerified != nil && !*claims.EmailVerified) {
+		return "", fmt.Errorf("%w: account has no verified email", ErrLoginDenied)
+	}
+	if !a.oidc.allowed(claims.Email) {
+		return "", fmt.Errorf("%w: %s is not allowed", ErrLoginDenied, claims.Email)
+	}
+	return claims.Email, nil
+}
+
+// ClearStateCookie removes the login attempt cookie
+func (a *Authenticator) ClearStateCookie() *http.Cookie {
+	cookie := a.cookie(stateCookie, "", time.Unix(0, 0))
+	cookie.MaxAge = -1
+	return cookie
+}
+
+// allowed reports whether email matches an allowed address or, for entries
+// starting with "@", an allowed domain. An empty list admits nobody.
+func (p *oidcProvider) allowed(email string) bool {
+	for _, allowed := range p.allowedEmails {
+		if strings.HasPrefix(allowed, "@") {
+			if len(email) > len(allowed) && strings.EqualFold(email[len(email)-len(allowed):], allowed) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(allowed, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
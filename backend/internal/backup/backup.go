@@ -0,0 +1,314 @@
+// Package backup writes point-in-time copies of the DuckDB database to a
+// backup directory and restores the database from them. A backup is a DuckDB
+// dump (schema.sql, load.sql and one Parquet file per table) with a
+// manifest, so it can be restored by a newer DuckDB version as well.
+package backup
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/offboard"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+// ManifestName is the file in a backup directory that describes it
+const ManifestName = "backup.json"
+
+// ErrNotFound is returned for a backup that does not exist or has no manifest
+var ErrNotFound = errors.New("backup not found")
+
+// Backup describes one backup; all but Path and SizeBytes is stored in its
+// manifest
+type Backup struct {
+	Name      string                 `json:"name"`
+	CreatedAt time.Time              `json:"created_at"`
+	Tables    []offboard.TableExport `json:"tables"`
+	Path      string                 `json:"path,omitempty"`
+	SizeBytes int64                  `json:"size_bytes,omitempty"`
+}
+
+// Store keeps the backups of one database in a directory
+type Store struct {
+	Dir string
+	// Keep is how many backups Create leaves; older ones are deleted
+	Keep int
+}
+
+// Create dumps db into a new timestamped backup and deletes the backups
+// beyond Keep. The dump is written under a temporary name, so a failed
+// backup never looks like a complete one.
+func (s *Store) Create(db *sql.DB, now time.Time) (*Backup, error) {
+	b := &Backup{
+		Name:      "claudeee-" + now.UTC().Format("20060102-150405"),
+		CreatedAt: now.UTC(),
+	}
+	b.Path = filepath.Join(s.Dir, b.Name)
+	if _, err := os.Stat(b.Path); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", b.Name)
+	}
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	tables, err := offboard.CountTables(db)
+	if err != nil {
+		return nil, err
+	}
+	b.Tables = tables
+
+	partial := b.Path + ".partial"
+	os.RemoveAll(partial)
+	if _, err := db.Exec(fmt.Sprintf("EXPORT DATABASE '%s' (FORMAT PARQUET)", strings.ReplaceAll(partial, "'", "''"))); err != nil {
+		os.RemoveAll(partial)
+		return nil, fmt.Errorf("failed to export database: %w", err)
+	}
+	// load.sql refers to the Parquet files by absolute path; make it relative
+	// so the backup can be moved or renamed
+	loadPath := filepath.Join(partial, "load.sql")
+	if data, err := os.ReadFile(loadPath); err == nil {
+		data = []byte(strings.ReplaceAll(string(data), partial+string(filepath.Separator), ""))
+		if err := os.WriteFile(loadPath, data, 0600); err != nil {
+			os.RemoveAll(partial)
+			return nil, fmt.Errorf("failed to rewrite load.sql: %w", err)
+		}
+	}
+	if err := writeManifest(partial, b); err != nil {
+		os.RemoveAll(partial)
+		return nil, err
+	}
+	if err := os.Rename(partial, b.Path); err != nil {
+		os.RemoveAll(partial)
+		return nil, fmt.Errorf("failed to finish backup: %w", err)
+	}
+	b.SizeBytes = dirSize(b.Path)
+
+	if _, err := s.Prune(); err != nil {
+		return b, err
+	}
+	return b, nil
+}
+
+// List returns the complete backups, newest first
+func (s *Store) List() ([]Backup, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Backup{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasSuffix(entry.Name(), ".partial") {
+			continue
+		}
+		b, err := Read(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, *b)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Prune deletes all but the newest Keep backups and returns their names
+func (s *Store) Prune() ([]string, error) {
+	if s.Keep <= 0 {
+		return nil, nil
+	}
+	backups, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for i := s.Keep; i < len(backups); i++ {
+		if err := os.RemoveAll(backups[i].Path); err != nil {
+			return removed, fmt.Errorf("failed to delete backup %s: %w", backups[i].Name, err)
+		}
+		removed = append(removed, backups[i].Name)
+	}
+	return removed, nil
+}
+
+// Resolve finds a backup by name in the store, or by path
+func (s *Store) Resolve(nameOrPath string) (*Backup, error) {
+	if !strings.ContainsRune(nameOrPath, filepath.Separator) {
+		if b, err := Read(filepath.Join(s.Dir, nameOrPath)); err == nil {
+			return b, nil
+		}
+	}
+	return Read(nameOrPath)
+}
+
+// Read loads the manifest of the backup at path
+func Read(path string) (*Backup, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup path: %w", err)
+	}
+	data, err := os.ReadFile(filepath.Join(path, ManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+	var b Backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest in %s: %w", path, err)
+	}
+	b.Path = path
+	b.SizeBytes = dirSize(path)
+	return &b, nil
+}
+
+// Restored reports a restore
+type Restored struct {
+	Backup *Backup `json:"backup"`
+	// Previous is where the replaced database was moved, if there was one
+	Previous string `json:"previous,omitempty"`
+}
+
+// Restore replaces the database file at dbPath with the backup. The backup
+// is imported into a new file and its row counts are checked against the
+// manifest first; the old database is kept next to it as
+// <dbPath>.pre-restore-<time> rather than deleted. No process may have
+// dbPath open.
+func Restore(b *Backup, dbPath string, now time.Time) (*Restored, error) {
+	restoring := dbPath + ".restoring"
+	removeDatabase(restoring)
+	if err := importBackup(b, restoring); err != nil {
+		removeDatabase(restoring)
+		return nil, err
+	}
+
+	restored := &Restored{Backup: b}
+	if _, err := os.Stat(dbPath); err == nil {
+		restored.Previous = dbPath + ".pre-restore-" + now.UTC().Format("20060102-150405")
+		if err := os.Rename(dbPath, restored.Previous); err != nil {
+			removeDatabase(restoring)
+			return nil, fmt.Errorf("failed to move the current database aside: %w", err)
+		}
+		if err := os.Rename(dbPath+".wal", restored.Previous+".wal"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to move the current write-ahead log aside: %w", err)
+		}
+	}
+	if err := os.Rename(restoring, dbPath); err != nil {
+		return nil, fmt.Errorf("failed to put the restored database in place: %w", err)
+	}
+	return restored, nil
+}
+
+func importBackup(b *Backup, path string) error {
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		return fmt.Errorf("failed to create database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("IMPORT DATABASE '%s'", strings.ReplaceAll(b.Path, "'", "''"))); err != nil {
+		return fmt.Errorf("failed to import backup: %w", err)
+	}
+	tables, err := offboard.CountTables(db)
+	if err != nil {
+		return err
+	}
+	rows := make(map[string]int64, len(tables))
+	for _, t := range tables {
+		rows[t.Name] = t.Rows
+	}
+	for _, t := range b.Tables {
+		if got, ok := rows[t.Name]; !ok || got != t.Rows {
+			return fmt.Errorf("restored table %s has %d rows, backup has %d", t.Name, got, t.Rows)
+		}
+	}
+	// Fold the write-ahead log into the file before it is moved
+	if _, err := db.Exec("CHECKPOINT"); err != nil {
+		return fmt.Errorf("failed to checkpoint restored database: %w", err)
+	}
+	return nil
+}
+
+func writeManifest(dir string, b *Backup) error {
+	manifest := *b
+	manifest.Path, manifest.SizeBytes = "", 0
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+	return nil
+}
+
+func removeDatabase(path string) {
+	os.Remove(path)
+	os.Remove(path + ".wal")
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// Scheduler runs a backup every interval until stopped
+type Scheduler struct {
+	interval time.Duration
+	run      func()
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewScheduler creates a scheduler that calls run every interval once started
+func NewScheduler(interval time.Duration, run func()) *Scheduler {
+	return &Scheduler{
+		interval: interval,
+		run:      run,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start launches the scheduling goroutine
+func (s *Scheduler) Start() {
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.run()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduling goroutine after a running backup finishes
+func (s *Scheduler) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
@@ -0,0 +1,114 @@
+package backup
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setupDatabase(t *testing.T, path string) *sql.DB {
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE sessions (id VARCHAR PRIMARY KEY, project_name VARCHAR);
+		INSERT INTO sessions VALUES ('s1', 'alpha'), ('s2', 'beta');
+		CREATE TABLE messages (id VARCHAR, content VARCHAR);
+		INSERT INTO messages VALUES ('m1', 'it''s here');
+	`)
+	if err != nil {
+		db.Close()
+		t.Fatalf("Failed to seed database: %v", err)
+	}
+	return db
+}
+
+func TestCreateListAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	db := setupDatabase(t, filepath.Join(dir, "claudeee.db"))
+	defer db.Close()
+	store := &Store{Dir: filepath.Join(dir, "backups"), Keep: 2}
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		b, err := store.Create(db, start.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to create backup: %v", err)
+		}
+		if b.SizeBytes == 0 || len(b.Tables) != 2 {
+			t.Errorf("Expected a non-empty backup of 2 tables, got %+v", b)
+		}
+	}
+
+	backups, err := store.List()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups to be kept, got %d", len(backups))
+	}
+	if backups[0].Name != "claudeee-20240101-120000" || backups[1].Name != "claudeee-20240101-110000" {
+		t.Errorf("Expected the newest backups first, got %s and %s", backups[0].Name, backups[1].Name)
+	}
+
+	if _, err := store.Resolve("claudeee-20240101-100000"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the oldest backup to be deleted, got %v", err)
+	}
+	if b, err := store.Resolve(backups[1].Name); err != nil || b.Path != backups[1].Path {
+		t.Errorf("Expected to resolve a backup by name, got %v (%v)", b, err)
+	}
+}
+
+func TestRestoreKeepsPreviousDatabase(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "claudeee.db")
+	db := setupDatabase(t, dbPath)
+	store := &Store{Dir: filepath.Join(dir, "backups"), Keep: 7}
+	b, err := store.Create(db, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create backup: %v", err)
+	}
+	// Changes after the backup are undone by the restore
+	if _, err := db.Exec(`DELETE FROM sessions`); err != nil {
+		t.Fatalf("Failed to delete sessions: %v", err)
+	}
+	db.Close()
+
+	// The backup can be moved before it is restored
+	moved := filepath.Join(dir, "moved")
+	if err := os.Rename(b.Path, moved); err != nil {
+		t.Fatalf("Failed to move backup: %v", err)
+	}
+	b, err = Read(moved)
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+
+	restored, err := Restore(b, dbPath, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if restored.Previous == "" {
+		t.Fatal("Expected the previous database to be kept")
+	}
+	if _, err := os.Stat(restored.Previous); err != nil {
+		t.Errorf("Expected the previous database at %s: %v", restored.Previous, err)
+	}
+
+	db, err = sql.Open("duckdb", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open restored database: %v", err)
+	}
+	defer db.Close()
+	var sessions int
+	var content string
+	db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&sessions)
+	db.QueryRow(`SELECT content FROM messages WHERE id = 'm1'`).Scan(&content)
+	if sessions != 2 || content != "it's here" {
+		t.Errorf("Expected the backed up rows, got %d sessions and content %q", sessions, content)
+	}
+}
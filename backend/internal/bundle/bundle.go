@@ -0,0 +1,481 @@
+// Package bundle moves claudeee's data between machines: it writes the
+// database, or the usage in a date range, together with the sync state into
+// a .tar.zst file, and merges such a file into another database. Unlike a
+// backup the bundle survives the loss of the original logs and merges into
+// a database that already has data.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// FormatVersion is the version of the bundle layout written by Write
+const FormatVersion = 1
+
+// What Read does with rows the database already has
+const (
+	Skip    = "skip"
+	Replace = "replace"
+)
+
+// manifestName is the first entry of a bundle; tables follow as
+// tables/<name>.parquet
+const manifestName = "manifest.json"
+
+// zstdMagic starts every zstd frame
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// excludedTables are not bundled: the migration ledger and sync lease belong
+// to the database, the content key ID travels in the manifest, and rollups
+// are rebuilt from the messages
+var excludedTables = map[string]bool{
+	"schema_migrations":     true,
+	"sync_lease":            true,
+	"content_encryption":    true,
+	"usage_buckets":         true,
+	"project_usage_rollups": true,
+	"rollup_state":          true,
+}
+
+// tableOrder lists the tables rows refer to before the ones referring to them
+var tableOrder = []string{"users", "sessions", "session_windows", "messages"}
+
+// rangeFilters select the rows of a date-ranged bundle, by table. Tables
+// without a filter are left out of such bundles, except for wholeTables.
+// %[1]s is the range condition on a column named timestamp.
+var rangeFilters = map[string]string{
+	"messages":           "%[1]s",
+	"sessions":           "id IN (SELECT session_id FROM messages WHERE %[1]s)",
+	"session_windows":    "id IN (SELECT session_window_id FROM messages WHERE %[1]s)",
+	"tool_calls":         "message_id IN (SELECT id FROM messages WHERE %[1]s)",
+	"message_usage_keys": "message_id IN (SELECT id FROM messages WHERE %[1]s)",
+	"session_tags":       "session_id IN (SELECT session_id FROM messages WHERE %[1]s)",
+	"session_notes":      "session_id IN (SELECT session_id FROM messages WHERE %[1]s)",
+	"session_sources":    "session_id IN (SELECT session_id FROM messages WHERE %[1]s)",
+	"duplicate_messages": "%[1]s",
+	"limit_events":       "%[1]s",
+}
+
+// wholeTables are bundled whole even for a date range: the sync state and
+// the users sessions belong to
+var wholeTables = map[string]bool{"file_sync_state": true, "users": true}
+
+// Manifest describes a bundle
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// SchemaVersion is the database migration the bundle was written at
+	SchemaVersion int `json:"schema_version"`
+	// From and To bound the usage in the bundle; unset for the whole database
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+	// ContentKeyID identifies the key message content is encrypted with
+	ContentKeyID string  `json:"content_key_id,omitempty"`
+	Tables       []Table `json:"tables"`
+}
+
+// Table is a table in a bundle and its number of rows
+type Table struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// Options select what Write bundles
+type Options struct {
+	// From and To bound the usage bundled; zero for no bound
+	From time.Time
+	To   time.Time
+}
+
+// TableResult is what Read did with a table of the bundle
+type TableResult struct {
+	Name     string `json:"name"`
+	Rows     int64  `json:"rows"`
+	Imported int64  `json:"imported"`
+	// Skipped says why the table was left out, if it was
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Result is what Read did with a bundle
+type Result struct {
+	Manifest *Manifest     `json:"manifest"`
+	Tables   []TableResult `json:"tables"`
+}
+
+// IsBundle reports whether the file at path is a bundle, by its zstd header
+func IsBundle(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	header := make([]byte, len(zstdMagic))
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false
+	}
+	return bytes.Equal(header, zstdMagic)
+}
+
+// Write bundles the DuckDB database db into w: every table, or with a range
+// the usage in it, as Parquet, plus the sync state
+func Write(db *sql.DB, w io.Writer, opts Options) (*Manifest, error) {
+	manifest := &Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC()}
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&manifest.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	keyID, err := contentKeyID(db)
+	if err != nil {
+		return nil, err
+	}
+	manifest.ContentKeyID = keyID
+
+	var conditions []string
+	if !opts.From.IsZero() {
+		from := opts.From.UTC()
+		manifest.From = &from
+		conditions = append(conditions, fmt.Sprintf("timestamp >= TIMESTAMP '%s'", from.Format("2006-01-02 15:04:05.999999")))
+	}
+	if !opts.To.IsZero() {
+		to := opts.To.UTC()
+		manifest.To = &to
+		conditions = append(conditions, fmt.Sprintf("timestamp < TIMESTAMP '%s'", to.Format("2006-01-02 15:04:05.999999")))
+	}
+	ranged := len(conditions) > 0
+
+	names, err := tableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "claudeee-bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range names {
+		query := fmt.Sprintf(`SELECT * FROM "%s"`, name)
+		if ranged && !wholeTables[name] {
+			filter, ok := rangeFilters[name]
+			if !ok {
+				continue
+			}
+			query += " WHERE " + fmt.Sprintf(filter, strings.Join(conditions, " AND "))
+		}
+		table := Table{Name: name}
+		if err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM (%s)`, query)).Scan(&table.Rows); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", name, err)
+		}
+		if _, err := db.Exec(fmt.Sprintf(`COPY (%s) TO '%s' (FORMAT PARQUET)`, query, quote(tablePath(dir, name)))); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		manifest.Tables = append(manifest.Tables, table)
+	}
+
+	if err := writeArchive(w, dir, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeArchive(w io.Writer, dir string, manifest *Manifest) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return fmt.Errorf("failed to compress bundle: %w", err)
+	}
+	tw := tar.NewWriter(zw)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := writeEntry(tw, manifestName, bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+	for _, table := range manifest.Tables {
+		f, err := os.Open(tablePath(dir, table.Name))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", table.Name, err)
+		}
+		info, err := f.Stat()
+		if err == nil {
+			err = writeEntry(tw, "tables/"+table.Name+".parquet", f, info.Size())
+		}
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish bundle: %w", err)
+	}
+	return nil
+}
+
+func writeEntry(tw *tar.Writer, name string, r io.Reader, size int64) error {
+	header := &tar.Header{Name: name, Mode: 0600, Size: size, ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	return nil
+}
+
+// Read merges the bundle in r into the DuckDB database db in one
+// transaction. Rows whose key the database has are kept, or with Replace
+// overwritten. Tables without a key are only read into an empty table, or
+// with Replace replace its rows. Tables the database does not have are
+// skipped.
+func Read(db *sql.DB, r io.Reader, onConflict string) (*Result, error) {
+	if onConflict == "" {
+		onConflict = Skip
+	}
+	if onConflict != Skip && onConflict != Replace {
+		return nil, fmt.Errorf("unknown conflict strategy %q (expected skip or replace)", onConflict)
+	}
+
+	dir, err := os.MkdirTemp("", "claudeee-bundle-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	manifest, err := extract(r, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&schema); err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if manifest.SchemaVersion > schema {
+		return nil, fmt.Errorf("the bundle was written by a newer claudeee (schema %d, this database has %d): upgrade first", manifest.SchemaVersion, schema)
+	}
+	keyID, err := contentKeyID(db)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.ContentKeyID != "" && manifest.ContentKeyID != keyID {
+		return nil, fmt.Errorf("the bundle's message content is encrypted with key %s: configure the same content key before importing", manifest.ContentKeyID)
+	}
+
+	existing, err := tableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	keyed, err := keyedTables(db)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		known[name] = true
+	}
+
+	tables := append([]Table(nil), manifest.Tables...)
+	sort.SliceStable(tables, func(i, j int) bool { return rank(tables[i].Name) < rank(tables[j].Name) })
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := &Result{Manifest: manifest}
+	for _, table := range tables {
+		tr := TableResult{Name: table.Name, Rows: table.Rows}
+		source := fmt.Sprintf(`SELECT * FROM read_parquet('%s')`, quote(tablePath(dir, table.Name)))
+		switch {
+		case excludedTables[table.Name] || !known[table.Name]:
+			tr.Skipped = "not in this database"
+		case table.Rows == 0:
+		case keyed[table.Name]:
+			verb := "INSERT OR IGNORE"
+			if onConflict == Replace {
+				verb = "INSERT OR REPLACE"
+			}
+			res, err := tx.Exec(fmt.Sprintf(`%s INTO "%s" BY NAME %s`, verb, table.Name, source))
+			if err != nil {
+				return nil, fmt.Errorf("failed to import %s: %w", table.Name, err)
+			}
+			tr.Imported, _ = res.RowsAffected()
+		default:
+			var rows int64
+			if err := tx.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, table.Name)).Scan(&rows); err != nil {
+				return nil, fmt.Errorf("failed to count %s: %w", table.Name, err)
+			}
+			if rows > 0 && onConflict != Replace {
+				tr.Skipped = "has rows and no key to merge them by"
+				break
+			}
+			if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM "%s"`, table.Name)); err != nil {
+				return nil, fmt.Errorf("failed to replace %s: %w", table.Name, err)
+			}
+			res, err := tx.Exec(fmt.Sprintf(`INSERT INTO "%s" BY NAME %s`, table.Name, source))
+			if err != nil {
+				return nil, fmt.Errorf("failed to import %s: %w", table.Name, err)
+			}
+			tr.Imported, _ = res.RowsAffected()
+		}
+		result.Tables = append(result.Tables, tr)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return result, nil
+}
+
+// extract unpacks the bundle in r into dir and returns its manifest
+func extract(r io.Reader, dir string) (*Manifest, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+
+	var manifest *Manifest
+	files := make(map[string]bool)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bundle: %w", err)
+		}
+		if header.Name == manifestName {
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("failed to read manifest: %w", err)
+			}
+			continue
+		}
+		name, ok := strings.CutPrefix(header.Name, "tables/")
+		name, parquet := strings.CutSuffix(name, ".parquet")
+		// Names come from the bundle: keep them inside dir
+		if !ok || !parquet || name == "" || strings.ContainsAny(name, `/\'"`) || name != filepath.Base(name) {
+			continue
+		}
+		f, err := os.OpenFile(tablePath(dir, name), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		files[name] = true
+	}
+
+	if manifest == nil {
+		return nil, errors.New("not a claudeee bundle: it has no manifest")
+	}
+	if manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("the bundle has format %d; this claudeee reads up to %d", manifest.Version, FormatVersion)
+	}
+	for _, table := range manifest.Tables {
+		if !files[table.Name] {
+			return nil, fmt.Errorf("the bundle is missing table %s", table.Name)
+		}
+	}
+	return manifest, nil
+}
+
+// tableNames lists the tables of db to bundle
+func tableNames(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT table_name FROM duckdb_tables() WHERE NOT internal ORDER BY table_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		if !excludedTables[name] {
+			names = append(names, name)
+		}
+	}
+	return names, rows.Err()
+}
+
+// keyedTables lists the tables of db with a primary key
+func keyedTables(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT DISTINCT table_name FROM duckdb_constraints() WHERE constraint_type = 'PRIMARY KEY'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list primary keys: %w", err)
+	}
+	defer rows.Close()
+	keyed := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		keyed[name] = true
+	}
+	return keyed, rows.Err()
+}
+
+// contentKeyID returns the ID of the key message content is encrypted with,
+// or "" when content is not encrypted
+func contentKeyID(db *sql.DB) (string, error) {
+	var exists bool
+	if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM duckdb_tables() WHERE table_name = 'content_encryption'`).Scan(&exists); err != nil {
+		return "", fmt.Errorf("failed to look up content key: %w", err)
+	}
+	if !exists {
+		return "", nil
+	}
+	var keyID string
+	err := db.QueryRow(`SELECT key_id FROM content_encryption WHERE id = 1`).Scan(&keyID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read content key: %w", err)
+	}
+	return keyID, nil
+}
+
+func rank(name string) int {
+	for i, ordered := range tableOrder {
+		if name == ordered {
+			return i
+		}
+	}
+	return len(tableOrder)
+}
+
+func tablePath(dir, name string) string {
+	return filepath.Join(dir, name+".parquet")
+}
+
+func quote(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
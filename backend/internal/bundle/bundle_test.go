@@ -0,0 +1,139 @@
+package bundle
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func setupDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("duckdb", filepath.Join(t.TempDir(), "claudeee.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return db
+}
+
+func seed(t *testing.T, db *sql.DB) {
+	t.Helper()
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES
+			('may', 'alpha', '/alpha', '2025-05-10 09:00:00'),
+			('june', 'beta', '/beta', '2025-06-10 09:00:00');
+		INSERT INTO messages (id, session_id, message_role, content, input_tokens, timestamp) VALUES
+			('m1', 'may', 'assistant', 'in may', 100, '2025-05-10 09:00:00'),
+			('m2', 'june', 'assistant', 'in june', 200, '2025-06-10 09:00:00'),
+			('m3', 'june', 'user', 'also june', 0, '2025-06-10 09:01:00');
+		INSERT INTO tool_calls (id, message_id, session_id, tool_name, called_at) VALUES
+			('t1', 'm2', 'june', 'Read', '2025-06-10 09:00:00');
+		INSERT INTO file_sync_state (file_path, last_modified, file_size, last_processed_line) VALUES
+			('/home/me/.claude/projects/-beta/june.jsonl', '2025-06-10 09:01:00', 4096, 3);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to seed database: %v", err)
+	}
+}
+
+func count(t *testing.T, db *sql.DB, query string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		t.Fatalf("Failed to count: %v", err)
+	}
+	return n
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	source := setupDB(t)
+	seed(t, source)
+
+	var buf bytes.Buffer
+	manifest, err := Write(source, &buf, Options{
+		From: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	rows := make(map[string]int64)
+	for _, table := range manifest.Tables {
+		rows[table.Name] = table.Rows
+	}
+	// June's session and messages, and the whole sync state; no budgets
+	if rows["sessions"] != 1 || rows["messages"] != 2 || rows["tool_calls"] != 1 || rows["file_sync_state"] != 1 {
+		t.Errorf("Unexpected tables %+v", manifest.Tables)
+	}
+	if _, ok := rows["budgets"]; ok {
+		t.Error("Expected tables without a range filter to be left out of a ranged bundle")
+	}
+
+	// The new machine already has a message of its own
+	target := setupDB(t)
+	if _, err := target.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('local', 'gamma', '/gamma', '2025-06-11 09:00:00');
+		INSERT INTO messages (id, session_id, timestamp) VALUES ('m9', 'local', '2025-06-11 09:00:00');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	bundle := buf.Bytes()
+	result, err := Read(target, bytes.NewReader(bundle), Skip)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if result.Manifest.From == nil || len(result.Tables) != len(manifest.Tables) {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if n := count(t, target, `SELECT COUNT(*) FROM messages`); n != 3 {
+		t.Errorf("Expected June's 2 messages next to the local one, got %d", n)
+	}
+	if n := count(t, target, `SELECT last_processed_line FROM file_sync_state`); n != 3 {
+		t.Errorf("Expected the sync state to survive, got line %d", n)
+	}
+
+	// A second read keeps what is there; replace overwrites it
+	if _, err := target.Exec(`UPDATE messages SET content = 'edited' WHERE id = 'm2'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(target, bytes.NewReader(bundle), Skip); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if n := count(t, target, `SELECT COUNT(*) FROM messages WHERE content = 'edited'`); n != 1 {
+		t.Error("Expected skip to keep the edited message")
+	}
+	if _, err := Read(target, bytes.NewReader(bundle), Replace); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if n := count(t, target, `SELECT COUNT(*) FROM messages WHERE content = 'in june'`); n != 1 {
+		t.Error("Expected replace to restore the bundled message")
+	}
+	if n := count(t, target, `SELECT COUNT(*) FROM messages`); n != 3 {
+		t.Errorf("Expected 3 messages after replacing, got %d", n)
+	}
+}
+
+func TestReadRejectsNewerSchema(t *testing.T) {
+	source := setupDB(t)
+	if _, err := source.Exec(`INSERT INTO schema_migrations VALUES (9999, 'from_the_future', CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := Write(source, &buf, Options{}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := Read(setupDB(t), &buf, Skip); err == nil {
+		t.Error("Expected an error for a bundle of a newer schema")
+	}
+	if _, err := Read(setupDB(t), bytes.NewReader([]byte("not a bundle")), Skip); err == nil {
+		t.Error("Expected an error for a file that is no bundle")
+	}
+}
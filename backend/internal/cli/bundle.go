@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"claudeee-backend/internal/bundle"
+	"claudeee-backend/internal/database"
+)
+
+// ExportBundle writes the database, or the usage in [from, to) when either
+// is set, with the sync state to a bundle at path for another machine
+func ExportBundle(store *Store, path string, from, to time.Time) (*bundle.Manifest, error) {
+	if store.Config.DBDriver != database.DriverDuckDB {
+		return nil, fmt.Errorf("bundles work on the DuckDB database only, not %s", store.Config.DBDriver)
+	}
+	tmpPath := path + ".partial"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle: %w", err)
+	}
+	manifest, err := bundle.Write(store.DB, f, bundle.Options{From: from, To: to})
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write bundle: %w", closeErr)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ImportBundle merges the bundle at path into the database and rebuilds the
+// usage rollups. onConflict is bundle.Skip or bundle.Replace.
+func ImportBundle(store *Store, path, onConflict string) (*bundle.Result, error) {
+	if store.Config.DBDriver != database.DriverDuckDB {
+		return nil, fmt.Errorf("bundles work on the DuckDB database only, not %s", store.Config.DBDriver)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	result, err := bundle.Read(store.DB, f, onConflict)
+	if err != nil {
+		return nil, err
+	}
+	return result, refreshRollups(store, true)
+}
+
+// IsBundle reports whether the file at path is a bundle rather than a
+// ccusage report or a message export
+func IsBundle(path string) bool {
+	return bundle.IsBundle(path)
+}
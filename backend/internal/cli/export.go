@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"claudeee-backend/internal/services"
+)
+
+// Export kinds
+const (
+	ExportSessions = "sessions"
+	ExportMessages = "messages"
+)
+
+// Export writes sessions or messages in format to out and returns the number
+// of records written
+func Export(store *Store, out io.Writer, kind, format string, q services.ExportQuery) (int, error) {
+	if err := services.ValidateExportFormat(format); err != nil {
+		return 0, err
+	}
+	exports := services.NewExportService(store.DB)
+	exports.SetContentCipher(store.Cipher)
+
+	var w *services.RecordWriter
+	var err error
+	switch kind {
+	case ExportSessions:
+		if w, err = services.NewRecordWriter(out, format, services.SessionExportHeader); err != nil {
+			return 0, err
+		}
+		err = exports.StreamSessions(q, func(s services.ExportedSession) error {
+			return w.Write(s, s.Row())
+		})
+	case ExportMessages:
+		if w, err = services.NewRecordWriter(out, format, services.MessageExportHeader(q.Content)); err != nil {
+			return 0, err
+		}
+		err = exports.StreamMessages(q, func(m services.ExportedMessage) error {
+			return w.Write(m, m.Row(q.Content))
+		})
+	default:
+		return 0, fmt.Errorf("unknown export %q: must be %s or %s", kind, ExportSessions, ExportMessages)
+	}
+	if err != nil {
+		w.Flush()
+		return w.Count(), err
+	}
+	return w.Count(), w.Close()
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"claudeee-backend/internal/services"
+)
+
+// Import merges a ccusage report or a claudeee message export at path into
+// the database, as the configured user, and brings the rollups up to date.
+// onConflict is services.ImportSkip or services.ImportReplace.
+func Import(store *Store, path, onConflict string) (*services.ImportResult, error) {
+	if err := RegisterUsers(store.DB, store.Config); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import: %w", err)
+	}
+	defer f.Close()
+
+	result, err := newDiffSync(store).Import(f, services.ImportOptions{
+		OnConflict: onConflict,
+		User:       store.Config.User,
+		Location:   store.Location(),
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, refreshRollups(store, false)
+}
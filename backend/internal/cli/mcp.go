@@ -0,0 +1,257 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"claudeee-backend/internal/apiversion"
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/mcp"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+)
+
+// RemainingTokens is the current window's capacity, the answer of
+// get_remaining_tokens
+type RemainingTokens struct {
+	UsedTokens  int       `json:"used_tokens"`
+	Limit       int       `json:"limit"`
+	Remaining   int       `json:"remaining"`
+	Utilization float64   `json:"utilization"`
+	WindowStart time.Time `json:"window_start"`
+	ResetsAt    time.Time `json:"resets_at"`
+	WindowCost  float64   `json:"window_cost"`
+}
+
+// SessionCost is the tokens and cost of one session, the answer of
+// get_session_cost
+type SessionCost struct {
+	SessionID    string    `json:"session_id"`
+	Project      string    `json:"project"`
+	StartTime    time.Time `json:"start_time"`
+	Active       bool      `json:"active"`
+	Messages     int       `json:"messages"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	TotalTokens  int       `json:"total_tokens"`
+	Cost         float64   `json:"cost"`
+}
+
+// UsageSummary is the usage per model over a range, the answer of
+// get_usage_summary
+type UsageSummary struct {
+	From   time.Time                    `json:"from"`
+	To     time.Time                    `json:"to"`
+	Total  services.ModelUsageSummary   `json:"total"`
+	Models []services.ModelUsageSummary `json:"models"`
+}
+
+// NewMCPServer returns an MCP server whose tools answer from the profile's
+// running server, or from its database when none runs
+func NewMCPServer(cfg *config.Config) *mcp.Server {
+	tools := &mcpTools{cfg: cfg, now: time.Now}
+	server := mcp.NewServer("claudeee", apiversion.Current)
+	server.AddTool(mcp.Tool{
+		Name:        "get_remaining_tokens",
+		Description: "Tokens used and remaining in the current 5-hour Claude usage window, its utilization, reset time and cost so far.",
+		Handler:     tools.remainingTokens,
+	})
+	server.AddTool(mcp.Tool{
+		Name:        "get_session_cost",
+		Description: "Tokens and cost of a Claude Code session; the most recently started session without session_id.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"session_id": map[string]interface{}{"type": "string", "description": "Session ID"},
+			},
+		},
+		Handler: tools.sessionCost,
+	})
+	server.AddTool(mcp.Tool{
+		Name:        "get_usage_summary",
+		Description: "Tokens, cost and messages per model between since and until, which default to the start of today and now.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"since": map[string]interface{}{"type": "string", "description": "RFC 3339 time or YYYY-MM-DD date"},
+				"until": map[string]interface{}{"type": "string", "description": "RFC 3339 time or YYYY-MM-DD date, included"},
+			},
+		},
+		Handler: tools.usageSummary,
+	})
+	return server
+}
+
+type mcpTools struct {
+	cfg *config.Config
+	now func() time.Time
+}
+
+func (t *mcpTools) remainingTokens(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	var usage *models.TokenUsage
+	err := fromServerOrStore(ctx, t.cfg, func(server *serverClient) error {
+		usage = &models.TokenUsage{}
+		return server.get(ctx, "/token-usage", nil, usage)
+	}, func(store *Store) error {
+		var err error
+		usage, err = currentUsage(store)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := usage.UsageLimit - usage.TotalTokens
+	if remaining < 0 {
+		remaining = 0
+	}
+	return RemainingTokens{
+		UsedTokens:  usage.TotalTokens,
+		Limit:       usage.UsageLimit,
+		Remaining:   remaining,
+		Utilization: usage.UsageRate,
+		WindowStart: usage.WindowStart,
+		ResetsAt:    usage.WindowEnd,
+		WindowCost:  usage.TotalCost,
+	}, nil
+}
+
+func (t *mcpTools) sessionCost(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	var session *models.SessionSummary
+	err := fromServerOrStore(ctx, t.cfg, func(server *serverClient) error {
+		id := args.SessionID
+		if id == "" {
+			var latest struct {
+				Sessions []models.SessionSummary `json:"sessions"`
+			}
+			if err := server.get(ctx, "/sessions", url.Values{"limit": {"1"}}, &latest); err != nil {
+				return err
+			}
+			if len(latest.Sessions) == 0 {
+				return errNoSessions
+			}
+			id = latest.Sessions[0].ID
+		}
+		// One message page keeps the answer small
+		var details struct {
+			Session models.SessionSummary `json:"session"`
+		}
+		if err := server.get(ctx, "/sessions/"+url.PathEscape(id), url.Values{"page": {"1"}, "page_size": {"1"}}, &details); err != nil {
+			return err
+		}
+		session = &details.Session
+		return nil
+	}, func(store *Store) error {
+		var err error
+		session, err = localSession(store, args.SessionID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return SessionCost{
+		SessionID:    session.ID,
+		Project:      session.ProjectName,
+		StartTime:    session.StartTime,
+		Active:       session.IsActive,
+		Messages:     session.MessageCount,
+		InputTokens:  session.TotalInputTokens,
+		OutputTokens: session.TotalOutputTokens,
+		TotalTokens:  session.TotalTokens,
+		Cost:         session.TotalCost,
+	}, nil
+}
+
+var errNoSessions = errors.New("no sessions have been synced yet")
+
+// localSession reads the session with id from store's database, with its
+// cost; the most recently started session without id
+func localSession(store *Store, id string) (*models.SessionSummary, error) {
+	sessions := services.NewSessionService(store.DB)
+	if id == "" {
+		page, err := sessions.QuerySessions(services.SessionQuery{Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Sessions) == 0 {
+			return nil, errNoSessions
+		}
+		id = page.Sessions[0].ID
+	}
+	session, err := sessions.GetSessionByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.TotalCost, err = services.NewTokenService(store.DB).CalculateSessionCost(id); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (t *mcpTools) usageSummary(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		Since string `json:"since"`
+		Until string `json:"until"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, fmt.Errorf("invalid arguments: %w", err)
+	}
+	now := t.now()
+	since, _ := Today(now, time.Local)
+	until := now
+	var err error
+	if args.Since != "" {
+		if since, err = parseToolTime(args.Since, false); err != nil {
+			return nil, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if args.Until != "" {
+		if until, err = parseToolTime(args.Until, true); err != nil {
+			return nil, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	if !until.After(since) {
+		return nil, errors.New("until must be after since")
+	}
+
+	var report *services.ModelUsageReport
+	err = fromServerOrStore(ctx, t.cfg, func(server *serverClient) error {
+		report = &services.ModelUsageReport{}
+		return server.get(ctx, "/usage/by-model", url.Values{
+			"from": {since.Format(time.RFC3339)},
+			"to":   {until.Format(time.RFC3339)},
+		}, report)
+	}, func(store *Store) error {
+		var err error
+		report, err = services.NewModelUsageService(store.DB).GetModelUsage(services.ModelUsageQuery{Since: since, Until: until})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return UsageSummary{From: since, To: until, Total: report.Total, Models: report.Models}, nil
+}
+
+// parseToolTime reads an RFC 3339 time or a local date, which as an end
+// bound includes the whole day
+func parseToolTime(raw string, end bool) (time.Time, error) {
+	if day, err := time.ParseInLocation(time.DateOnly, raw, time.Local); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/instance"
+)
+
+func TestLocalSession(t *testing.T) {
+	store := setupStore(t)
+	_, err := store.DB.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, total_input_tokens, total_output_tokens, total_tokens, message_count) VALUES
+			('old', 'alpha', '/alpha', '2024-03-01 09:00:00', 0, 0, 0, 0),
+			('new', 'beta', '/beta', '2024-03-01 12:00:00', 1000000, 0, 1000000, 1);
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp) VALUES
+			('m1', 'new', 'assistant', 'claude-sonnet-4-20250514', 1000000, 0, '2024-03-01 12:30:00');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	session, err := localSession(store, "")
+	if err != nil {
+		t.Fatalf("localSession failed: %v", err)
+	}
+	if session.ID != "new" || session.TotalCost != 3 || session.TotalTokens != 1000000 {
+		t.Errorf("Expected the newest session at $3, got %+v", session)
+	}
+	if session, err = localSession(store, "old"); err != nil || session.TotalCost != 0 {
+		t.Errorf("Expected the old session without cost, got %+v, %v", session, err)
+	}
+	if _, err := localSession(store, "missing"); err == nil {
+		t.Error("Expected an error for an unknown session")
+	}
+}
+
+func TestMCPToolsFromServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/token-usage":
+			w.Write([]byte(`{"total_tokens": 700, "usage_limit": 1000, "usage_rate": 0.7, "total_cost": 1.5}`))
+		case "/api/v1/sessions":
+			w.Write([]byte(`{"sessions": [{"id": "s1"}]}`))
+		case "/api/v1/sessions/s1":
+			w.Write([]byte(`{"session": {"id": "s1", "project_name": "alpha", "total_tokens": 42, "total_cost": 0.25}}`))
+		case "/api/v1/usage/by-model":
+			if r.URL.Query().Get("from") != "2024-03-01T00:00:00Z" || r.URL.Query().Get("to") != "2024-03-02T00:00:00Z" {
+				http.Error(w, `{"error": "unexpected range"}`, http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"models": [{"model": "claude-sonnet-4-20250514", "cost": 2}], "total": {"model": "total", "cost": 2}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{DataDir: t.TempDir()}
+	if err := instance.WriteServerInfo(cfg.DataDir, instance.ServerInfo{PID: 1, URL: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	tools := &mcpTools{cfg: cfg, now: time.Now}
+	ctx := context.Background()
+
+	result, err := tools.remainingTokens(ctx, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("remainingTokens failed: %v", err)
+	}
+	if remaining := result.(RemainingTokens); remaining.Remaining != 300 || remaining.WindowCost != 1.5 {
+		t.Errorf("Unexpected remaining tokens %+v", remaining)
+	}
+
+	result, err = tools.sessionCost(ctx, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("sessionCost failed: %v", err)
+	}
+	if cost := result.(SessionCost); cost.SessionID != "s1" || cost.Cost != 0.25 || cost.Project != "alpha" {
+		t.Errorf("Unexpected session cost %+v", cost)
+	}
+
+	result, err = tools.usageSummary(ctx, json.RawMessage(`{"since": "2024-03-01T00:00:00Z", "until": "2024-03-02T00:00:00Z"}`))
+	if err != nil {
+		t.Fatalf("usageSummary failed: %v", err)
+	}
+	if summary := result.(UsageSummary); summary.Total.Cost != 2 || len(summary.Models) != 1 {
+		t.Errorf("Unexpected usage summary %+v", summary)
+	}
+
+	if _, err := tools.usageSummary(ctx, json.RawMessage(`{"since": "yesterday"}`)); err == nil {
+		t.Error("Expected an error for an invalid since")
+	}
+}
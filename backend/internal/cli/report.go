@@ -0,0 +1,238 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"claudeee-backend/internal/services"
+)
+
+// Usage is a token and cost total over assistant messages
+type Usage struct {
+	Messages                 int     `json:"messages"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	TotalTokens              int64   `json:"total_tokens"`
+	Cost                     float64 `json:"cost"`
+}
+
+func (u *Usage) add(m services.ExportedMessage) {
+	u.Messages++
+	u.InputTokens += m.InputTokens
+	u.OutputTokens += m.OutputTokens
+	u.CacheCreationInputTokens += m.CacheCreationInputTokens
+	u.CacheReadInputTokens += m.CacheReadInputTokens
+	u.TotalTokens += m.InputTokens + m.OutputTokens
+	u.Cost += m.Cost
+}
+
+// DayUsage is the usage on one day in the report's time zone
+type DayUsage struct {
+	Date   string   `json:"date"`
+	Models []string `json:"models"`
+	Usage
+}
+
+// ModelUsage is the usage of one model over the report range
+type ModelUsage struct {
+	Model string `json:"model"`
+	Usage
+}
+
+// WindowUsage is the current session window and its remaining capacity
+type WindowUsage struct {
+	Active      bool      `json:"active"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Tokens      int       `json:"tokens"`
+	Limit       int       `json:"limit"`
+	Remaining   int       `json:"remaining"`
+	Utilization float64   `json:"utilization"`
+	Messages    int       `json:"messages"`
+	Cost        float64   `json:"cost"`
+}
+
+// Report summarizes usage over a period and the current session window
+type Report struct {
+	From     time.Time    `json:"from"`
+	To       time.Time    `json:"to"`
+	Timezone string       `json:"timezone"`
+	Sessions int          `json:"sessions"`
+	Totals   Usage        `json:"totals"`
+	Days     []DayUsage   `json:"days"`
+	Models   []ModelUsage `json:"models"`
+	Window   WindowUsage  `json:"window"`
+}
+
+// Today returns the bounds of the day containing now in loc
+func Today(now time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := now.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// BuildReport totals the usage in [from, to) by day and model and reads the
+// current window. Days follow the location of from.
+func BuildReport(store *Store, from, to time.Time) (*Report, error) {
+	loc := from.Location()
+	report := &Report{From: from, To: to, Timezone: loc.String(), Days: []DayUsage{}, Models: []ModelUsage{}}
+	sessions := make(map[string]bool)
+	days := make(map[string]*DayUsage)
+	dayModels := make(map[string]map[string]bool)
+	models := make(map[string]*ModelUsage)
+
+	exports := services.NewExportService(store.DB)
+	err := exports.StreamMessages(services.ExportQuery{From: from, To: to}, func(m services.ExportedMessage) error {
+		if m.Role != "assistant" {
+			return nil
+		}
+		sessions[m.SessionID] = true
+		report.Totals.add(m)
+
+		date := m.Timestamp.In(loc).Format("2006-01-02")
+		day, ok := days[date]
+		if !ok {
+			day = &DayUsage{Date: date}
+			days[date] = day
+			dayModels[date] = make(map[string]bool)
+		}
+		day.add(m)
+
+		model := m.Model
+		if model == "" {
+			model = "unknown"
+		}
+		dayModels[date][model] = true
+		usage, ok := models[model]
+		if !ok {
+			usage = &ModelUsage{Model: model}
+			models[model] = usage
+		}
+		usage.add(m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Sessions = len(sessions)
+
+	for date, day := range days {
+		for model := range dayModels[date] {
+			day.Models = append(day.Models, model)
+		}
+		sort.Strings(day.Models)
+		report.Days = append(report.Days, *day)
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Date < report.Days[j].Date })
+	for _, usage := range models {
+		report.Models = append(report.Models, *usage)
+	}
+	sort.Slice(report.Models, func(i, j int) bool {
+		if report.Models[i].Cost != report.Models[j].Cost {
+			return report.Models[i].Cost > report.Models[j].Cost
+		}
+		return report.Models[i].Model < report.Models[j].Model
+	})
+
+	tokens := services.NewTokenService(store.DB)
+	if err := tokens.SetPlanLimit(store.Settings.Plan, store.Settings.UsageLimit()); err != nil {
+		return nil, err
+	}
+	current, err := tokens.GetCurrentTokenUsage()
+	if err != nil {
+		return nil, err
+	}
+	report.Window = WindowUsage{
+		Active:      current.TotalMessages > 0 || current.TotalTokens > 0,
+		Start:       current.WindowStart,
+		End:         current.WindowEnd,
+		Tokens:      current.TotalTokens,
+		Limit:       current.UsageLimit,
+		Utilization: current.UsageRate,
+		Messages:    current.TotalMessages,
+		Cost:        current.TotalCost,
+	}
+	if remaining := current.UsageLimit - current.TotalTokens; remaining > 0 {
+		report.Window.Remaining = remaining
+	}
+	return report, nil
+}
+
+// usageRow formats the token and cost columns shared by the report tables
+func usageRow(label string, u Usage) []string {
+	return []string{
+		label,
+		formatCount(u.InputTokens),
+		formatCount(u.OutputTokens),
+		formatCount(u.CacheCreationInputTokens),
+		formatCount(u.CacheReadInputTokens),
+		formatCount(u.TotalTokens),
+		formatDollars(u.Cost),
+	}
+}
+
+var usageHeaders = []string{"Input", "Output", "Cache create", "Cache read", "Total tokens", "Cost"}
+
+// Print writes the report as terminal tables
+func (r *Report) Print(w io.Writer) {
+	loc := r.From.Location()
+	fmt.Fprintf(w, "Usage from %s to %s (%s, %s)\n\n",
+		r.From.Format("2006-01-02 15:04"), r.To.In(loc).Format("2006-01-02 15:04 MST"),
+		plural(r.Sessions, "session"), plural(r.Totals.Messages, "message"))
+
+	if len(r.Days) == 0 {
+		fmt.Fprintln(w, "No usage in this period")
+	} else {
+		daily := &table{headers: append([]string{"Date"}, usageHeaders...)}
+		for _, day := range r.Days {
+			daily.add(usageRow(day.Date, day.Usage)...)
+		}
+		daily.footer = usageRow("Total", r.Totals)
+		daily.render(w)
+
+		fmt.Fprintln(w)
+		byModel := &table{headers: append([]string{"Model", "Messages"}, usageHeaders...)}
+		for _, model := range r.Models {
+			row := usageRow(model.Model, model.Usage)
+			byModel.add(append([]string{row[0], formatCount(int64(model.Messages))}, row[1:]...)...)
+		}
+		byModel.render(w)
+	}
+
+	fmt.Fprintln(w)
+	if !r.Window.Active {
+		fmt.Fprintln(w, "No active session window")
+		return
+	}
+	window := &table{headers: []string{"Window", "Used", "Limit", "Remaining", "Used %", "Cost", "Resets"}}
+	window.add(
+		r.Window.Start.In(loc).Format("15:04")+"–"+r.Window.End.In(loc).Format("15:04"),
+		formatCount(int64(r.Window.Tokens)),
+		formatCount(int64(r.Window.Limit)),
+		formatCount(int64(r.Window.Remaining)),
+		fmt.Sprintf("%.1f%%", r.Window.Utilization*100),
+		formatDollars(r.Window.Cost),
+		"in "+formatDuration(time.Until(r.Window.End)),
+	)
+	window.render(w)
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return formatCount(int64(n)) + " " + noun + "s"
+}
+
+// formatDuration formats d as hours and minutes, rounded down
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Truncate(time.Minute)
+	return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+}
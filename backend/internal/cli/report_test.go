@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/services"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func setupStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return &Store{DB: db, Settings: services.DefaultRuntimeSettings()}
+}
+
+func TestToday(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*60*60)
+	from, to := Today(time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC), tokyo)
+	if !from.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, tokyo)) || to.Sub(from) != 24*time.Hour {
+		t.Errorf("Expected March 2 in Tokyo, got %v to %v", from, to)
+	}
+}
+
+func TestBuildReportAndExport(t *testing.T) {
+	store := setupStore(t)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := store.DB.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'alpha', '/alpha', ?)`, day); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	_, err := store.DB.Exec(`
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp) VALUES
+			('m1', 's1', 'assistant', 'claude-sonnet-4-20250514', 1000, 100, ?),
+			('m2', 's1', 'assistant', 'claude-sonnet-4-20250514', 5000, 500, ?)
+	`, day.Add(time.Hour), day.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to insert messages: %v", err)
+	}
+
+	report, err := BuildReport(store, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("BuildReport failed: %v", err)
+	}
+	if report.Sessions != 1 || report.Totals.Messages != 1 || report.Totals.TotalTokens != 1100 || report.Totals.Cost <= 0 {
+		t.Errorf("Expected only m1, got %+v", report)
+	}
+	if len(report.Days) != 1 || report.Days[0].Date != "2024-03-01" || len(report.Models) != 1 || report.Models[0].TotalTokens != 1100 {
+		t.Errorf("Unexpected breakdown: %+v %+v", report.Days, report.Models)
+	}
+	if report.Window.Active {
+		t.Errorf("Expected no active window, got %+v", report.Window)
+	}
+	var out bytes.Buffer
+	report.Print(&out)
+	for _, want := range []string{"│ 2024-03-01 │", "│ Total      │", "claude-sonnet-4-20250514", "1,100", "No active session window"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in report:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	count, err := Export(store, &out, ExportMessages, services.ExportCSV, services.ExportQuery{From: day})
+	if err != nil || count != 1 || !strings.HasPrefix(out.String(), "message_id,") || !strings.Contains(out.String(), "m1,s1,alpha") {
+		t.Errorf("Unexpected export (%d, %v):\n%s", count, err, out.String())
+	}
+	if _, err := Export(store, &out, "tools", services.ExportCSV, services.ExportQuery{}); err == nil {
+		t.Error("Expected an error for an unknown export")
+	}
+}
+
+func TestTable(t *testing.T) {
+	tbl := &table{headers: []string{"Name", "Count"}}
+	tbl.add("a", "1")
+	tbl.add("longer", "1,234")
+	tbl.footer = []string{"Total", "1,235"}
+	var out bytes.Buffer
+	tbl.render(&out)
+	want := `┌────────┬───────┐
+│ Name   │ Count │
+├────────┼───────┤
+│ a      │     1 │
+│ longer │ 1,234 │
+├────────┼───────┤
+│ Total  │ 1,235 │
+└────────┴───────┘
+`
+	if out.String() != want {
+		t.Errorf("Unexpected table:\n%s", out.String())
+	}
+
+	for n, want := range map[int64]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567", -4200: "-4,200"} {
+		if got := formatCount(n); got != want {
+			t.Errorf("formatCount(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
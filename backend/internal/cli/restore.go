@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/backup"
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/instance"
+)
+
+// Restore replaces the profile's database with a backup, given by name from
+// the backup directory or by path. Like the other commands it needs the
+// server to be stopped.
+func Restore(cfg *config.Config, nameOrPath string) (*backup.Restored, error) {
+	if cfg.DBDriver != database.DriverDuckDB {
+		return nil, fmt.Errorf("restore works on the DuckDB database only, not %s", cfg.DBDriver)
+	}
+	dbPath := cfg.DatabaseDSN()
+	if dbPath == ":memory:" || strings.Contains(dbPath, "?") {
+		return nil, fmt.Errorf("restore needs a plain database file, not %s", dbPath)
+	}
+
+	store := &backup.Store{Dir: cfg.Backup.Dir, Keep: cfg.Backup.Keep}
+	b, err := store.Resolve(nameOrPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := instance.AcquireLock(cfg.DataDir)
+	if errors.Is(err, instance.ErrAlreadyRunning) {
+		return nil, runningServerError(cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire instance lock: %w", err)
+	}
+	defer lock.Release()
+	return backup.Restore(b, dbPath, time.Now())
+}
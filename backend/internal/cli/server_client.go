@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/instance"
+)
+
+// ServerTimeout bounds one request to the running server
+const ServerTimeout = 2 * time.Second
+
+// serverClient reads the API of the profile's running server, for commands
+// that also work while the server holds the database
+type serverClient struct {
+	cfg     *config.Config
+	baseURL string
+}
+
+// get decodes the JSON answer to GET /api/v1<path>
+func (c *serverClient) get(ctx context.Context, path string, query url.Values, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, ServerTimeout)
+	defer cancel()
+	target := strings.TrimRight(c.baseURL, "/") + "/api/v1" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.cfg.Auth.Mode == "basic" {
+		req.SetBasicAuth(c.cfg.Auth.Username, c.cfg.Auth.Password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the server at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		if body.Error != "" {
+			return fmt.Errorf("server at %s returned %d: %s", c.baseURL, resp.StatusCode, body.Error)
+		}
+		return fmt.Errorf("server at %s returned %d", c.baseURL, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse the answer of %s: %w", path, err)
+	}
+	return nil
+}
+
+// fromServerOrStore calls server when the profile's server is running, and
+// local with the opened database when none is or it does not answer
+func fromServerOrStore(ctx context.Context, cfg *config.Config, server func(*serverClient) error, local func(*Store) error) error {
+	info, err := instance.ReadServerInfo(cfg.DataDir)
+	if err == nil && info != nil {
+		serverErr := server(&serverClient{cfg: cfg, baseURL: info.URL})
+		if serverErr == nil {
+			return nil
+		}
+		// A server that crashed leaves its discovery file behind
+		err = serverErr
+	}
+
+	store, openErr := Open(cfg)
+	if openErr != nil {
+		if err != nil {
+			return fmt.Errorf("%v; %w", err, openErr)
+		}
+		return openErr
+	}
+	defer store.Close()
+	return local(store)
+}
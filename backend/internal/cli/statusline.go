@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+)
+
+// StatuslineInput is the session context Claude Code writes to the stdin of
+// a statusLine command. Fields it leaves out stay empty.
+type StatuslineInput struct {
+	SessionID string `json:"session_id"`
+	Model     struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+	} `json:"model"`
+	Cost struct {
+		// TotalCostUSD is what the session has cost so far
+		TotalCostUSD float64 `json:"total_cost_usd"`
+	} `json:"cost"`
+}
+
+// ReadStatuslineInput decodes Claude Code's context. Empty input, as when the
+// command runs by hand, is no context rather than an error.
+func ReadStatuslineInput(r io.Reader) (*StatuslineInput, error) {
+	data, err := io.ReadAll(io.LimitReader(r, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read statusline input: %w", err)
+	}
+	var input StatuslineInput
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return &input, nil
+	}
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to parse statusline input: %w", err)
+	}
+	return &input, nil
+}
+
+// Statusline returns the status line of the profile: from the running
+// server when there is one, which holds the database, else from the
+// database itself
+func Statusline(ctx context.Context, cfg *config.Config, now time.Time) (*services.Statusline, error) {
+	var line *services.Statusline
+	err := fromServerOrStore(ctx, cfg, func(server *serverClient) error {
+		line = &services.Statusline{}
+		return server.get(ctx, "/statusline", url.Values{"format": {"json"}}, line)
+	}, func(store *Store) error {
+		var err error
+		line, err = LocalStatusline(store, now)
+		return err
+	})
+	return line, err
+}
+
+// LocalStatusline builds the status line from store's database
+func LocalStatusline(store *Store, now time.Time) (*services.Statusline, error) {
+	usage, err := currentUsage(store)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewStatuslineService(store.DB).Statusline(usage, "", now, store.Location())
+}
+
+// currentUsage reads the current window's usage against the configured plan
+func currentUsage(store *Store) (*models.TokenUsage, error) {
+	tokens := services.NewTokenService(store.DB)
+	if err := tokens.SetPlanLimit(store.Settings.Plan, store.Settings.UsageLimit()); err != nil {
+		return nil, err
+	}
+	return tokens.GetCurrentTokenUsage()
+}
+
+// FormatStatusline prefixes the status line with the session's model and
+// appends its cost when Claude Code passed them, e.g.
+// "[Opus] ⚡ 62% | resets 14:00 | $3.20 today | $0.45 session"
+func FormatStatusline(input *StatuslineInput, line *services.Statusline) string {
+	text := line.Text
+	if input == nil {
+		return text
+	}
+	if input.Model.DisplayName != "" {
+		text = "[" + input.Model.DisplayName + "] " + text
+	}
+	if input.SessionID != "" {
+		text += fmt.Sprintf(" | $%.2f session", input.Cost.TotalCostUSD)
+	}
+	return text
+}
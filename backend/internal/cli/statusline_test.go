@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/instance"
+	"claudeee-backend/internal/services"
+)
+
+func TestStatuslineInputAndFormat(t *testing.T) {
+	input, err := ReadStatuslineInput(strings.NewReader(`{
+		"hook_event_name": "Status",
+		"session_id": "abc",
+		"model": {"id": "claude-opus-4-1", "display_name": "Opus"},
+		"cost": {"total_cost_usd": 0.4512}
+	}`))
+	if err != nil {
+		t.Fatalf("ReadStatuslineInput failed: %v", err)
+	}
+	line := &services.Statusline{Text: "⚡ 62% | resets 14:00 | $3.20 today"}
+	if got := FormatStatusline(input, line); got != "[Opus] ⚡ 62% | resets 14:00 | $3.20 today | $0.45 session" {
+		t.Errorf("Unexpected status line %q", got)
+	}
+
+	empty, err := ReadStatuslineInput(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("Expected empty input to be no context, got %v", err)
+	}
+	if got := FormatStatusline(empty, line); got != line.Text {
+		t.Errorf("Expected the plain line, got %q", got)
+	}
+	if _, err := ReadStatuslineInput(strings.NewReader("{")); err == nil {
+		t.Error("Expected an error for invalid input")
+	}
+}
+
+func TestStatuslineFromServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/statusline" || r.URL.Query().Get("format") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"utilization": 0.5, "text": "⚡ 50% | $1.00 today"}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{DataDir: t.TempDir()}
+	if err := instance.WriteServerInfo(cfg.DataDir, instance.ServerInfo{PID: 1, URL: server.URL}); err != nil {
+		t.Fatal(err)
+	}
+	line, err := Statusline(context.Background(), cfg, time.Now())
+	if err != nil {
+		t.Fatalf("Statusline failed: %v", err)
+	}
+	if line.Text != "⚡ 50% | $1.00 today" || line.Utilization != 0.5 {
+		t.Errorf("Expected the server's status line, got %+v", line)
+	}
+}
+
+func TestLocalStatusline(t *testing.T) {
+	store := setupStore(t)
+	line, err := LocalStatusline(store, time.Now())
+	if err != nil {
+		t.Fatalf("LocalStatusline failed: %v", err)
+	}
+	if line.Text != "⚡ 0% | $0.00 today" {
+		t.Errorf("Expected an empty status line, got %q", line.Text)
+	}
+}
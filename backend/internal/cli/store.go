@@ -0,0 +1,142 @@
+// Package cli implements the one-shot claudeee commands. They open the
+// profile's database directly, so they run while no server is using it.
+package cli
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/instance"
+	"claudeee-backend/internal/services"
+)
+
+// Store is a profile's database opened for a one-shot command
+type Store struct {
+	Config   *config.Config
+	DB       *sql.DB
+	Settings services.RuntimeSettings
+	Cipher   *services.ContentCipher
+	Redactor *services.Redactor
+	lock     *instance.Lock
+}
+
+// Open takes the instance lock and opens the database. It fails when a
+// server is running, since the database allows a single process.
+func Open(cfg *config.Config) (*Store, error) {
+	lock, err := instance.AcquireLock(cfg.DataDir)
+	if errors.Is(err, instance.ErrAlreadyRunning) {
+		return nil, runningServerError(cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire instance lock: %w", err)
+	}
+
+	store, err := open(cfg)
+	if err != nil {
+		lock.Release()
+		return nil, err
+	}
+	store.lock = lock
+	return store, nil
+}
+
+func open(cfg *config.Config) (*Store, error) {
+	db, err := database.Initialize(cfg.DBDriver, cfg.DatabaseDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	services.SetWindowPolicy(services.WindowPolicy{
+		Duration: time.Duration(cfg.WindowHours) * time.Hour,
+		Anchor:   services.WindowAnchor(cfg.WindowAnchor),
+	})
+	services.SetSessionIdleTimeout(time.Duration(cfg.SessionIdleMinutes) * time.Minute)
+
+	defaults := services.DefaultRuntimeSettings()
+	defaults.Plan = cfg.Plan
+	defaults.PlanTokenLimit = cfg.PlanTokenLimit
+	defaults.Timezone = cfg.Timezone
+	defaults.ContentPolicy = cfg.ContentPolicy
+	defaults.ContentMaxKB = cfg.ContentMaxKB
+	defaults.ContentCompression = cfg.ContentCompression
+	defaults.ContentRetentionDays = cfg.ContentRetentionDays
+	defaults.RedactSecrets = cfg.RedactSecrets
+	if cfg.PrivacyMode {
+		defaults.PrivacyMode = true
+		defaults.ContentPolicy = services.ContentPolicyMetadata
+	}
+	settings := services.NewSettingsService(db, defaults)
+	if err := settings.Load(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize settings: %w", err)
+	}
+
+	store := &Store{Config: cfg, DB: db, Settings: settings.Get()}
+	key, err := services.LoadContentKey(cfg.ContentKey, cfg.ContentKeyFile)
+	if err == nil && key != nil {
+		store.Cipher, err = services.NewContentCipher(key)
+	}
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("invalid content key: %w", err)
+	}
+	if store.Redactor, err = NewRedactor(cfg); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// Close closes the database and releases the instance lock
+func (s *Store) Close() error {
+	err := s.DB.Close()
+	if s.lock != nil {
+		s.lock.Release()
+	}
+	return err
+}
+
+// Location is the configured time zone for day boundaries
+func (s *Store) Location() *time.Location {
+	loc, err := time.LoadLocation(s.Settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func runningServerError(cfg *config.Config) error {
+	running, err := instance.Inspect(cfg.DataDir)
+	if err != nil || running == nil {
+		return instance.ErrAlreadyRunning
+	}
+	if running.Info != nil {
+		return fmt.Errorf("%w (pid %d) at %s and is using the database; stop it or use its API",
+			instance.ErrAlreadyRunning, running.PID, running.Info.URL)
+	}
+	return fmt.Errorf("%w (pid %d) and is using the database; stop it first", instance.ErrAlreadyRunning, running.PID)
+}
+
+// NormalizeArgs rewrites Go-style single-dash long flags such as -profile to
+// --profile, so scripts written for the old flag parser keep working
+func NormalizeArgs(args []string) []string {
+	normalized := make([]string, 0, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			return append(normalized, args[i:]...)
+		}
+		if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' {
+			name, _, _ := strings.Cut(arg[1:], "=")
+			if len(name) > 1 {
+				arg = "-" + arg
+			}
+		}
+		normalized = append(normalized, arg)
+	}
+	return normalized
+}
@@ -0,0 +1,14 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeArgs(t *testing.T) {
+	got := NormalizeArgs([]string{"-profile", "work", "-read-only-api", "-o", "out.csv", "-h", "--since=2024-01-01", "-archive=/tmp/a.zip", "--", "-raw"})
+	want := []string{"--profile", "work", "--read-only-api", "-o", "out.csv", "-h", "--since=2024-01-01", "--archive=/tmp/a.zip", "--", "-raw"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
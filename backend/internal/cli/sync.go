@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+)
+
+// Sync reads new lines from the Claude logs into the database and brings the
+// usage rollups up to date. Canceling ctx keeps the lines read so far. With
+// force, everything synced before is removed first and rebuilt from the logs.
+func Sync(ctx context.Context, store *Store, force bool) (*models.SyncStats, error) {
+	cfg := store.Config
+	if err := RegisterUsers(store.DB, cfg); err != nil {
+		return nil, err
+	}
+	diffSync := newDiffSync(store)
+	diffSync.SetLogSources(services.LogSourceConfig{
+		Roots:           cfg.LogRoots(),
+		IncludeProjects: cfg.IncludeProjects,
+		ExcludeProjects: cfg.ExcludeProjects,
+		CodexDirs:       cfg.CodexDirs,
+		GeminiLogs:      cfg.GeminiLogs,
+		RootUsers:       cfg.RootUsers(),
+		DefaultUser:     cfg.User,
+	})
+	diffSync.SetWorkers(cfg.SyncWorkers)
+	diffSync.SetFlagMissingSources(cfg.FlagMissingSources)
+	if force {
+		if err := diffSync.ResetSyncedData(); err != nil {
+			return nil, err
+		}
+	}
+	stats, err := diffSync.SyncAllLogs(ctx)
+	if err != nil {
+		return stats, err
+	}
+	return stats, refreshRollups(store, force)
+}
+
+// newDiffSync returns a sync service storing content under the store's
+// content policy, redaction and encryption settings
+func newDiffSync(store *Store) *services.DiffSyncService {
+	diffSync := services.NewDiffSyncService(store.DB, services.NewTokenService(store.DB), services.NewSessionService(store.DB))
+	diffSync.SetContentPolicy(store.Settings.ContentStoragePolicy())
+	diffSync.SetContentCipher(store.Cipher)
+	if store.Settings.RedactSecrets {
+		diffSync.SetRedactor(store.Redactor)
+	}
+	return diffSync
+}
+
+// NewRedactor returns a redactor with the configured redaction rules
+func NewRedactor(cfg *config.Config) (*services.Redactor, error) {
+	rules := make([]services.CustomRedactionRule, 0, len(cfg.RedactionRules))
+	for _, rule := range cfg.RedactionRules {
+		rules = append(rules, services.CustomRedactionRule{Kind: rule.Name, Pattern: rule.Pattern})
+	}
+	return services.NewCustomRedactor(rules)
+}
+
+// refreshRollups brings the usage rollups up to date with the messages, or
+// with rebuild recomputes them all
+func refreshRollups(store *Store, rebuild bool) error {
+	rollups := services.NewRollupService(store.DB)
+	refresh := rollups.Refresh
+	if rebuild {
+		refresh = rollups.Rebuild
+	}
+	if err := refresh(); err != nil {
+		return fmt.Errorf("failed to refresh rollups: %w", err)
+	}
+	return nil
+}
+
+// RegisterUsers stores the configured users so synced sessions can refer to them
+func RegisterUsers(db *sql.DB, cfg *config.Config) error {
+	var users []services.User
+	for _, u := range cfg.AllUsers() {
+		users = append(users, services.User{ID: u.ID, Name: u.Name})
+	}
+	if err := services.NewUserService(db).Register(users); err != nil {
+		return fmt.Errorf("failed to register users: %w", err)
+	}
+	return nil
+}
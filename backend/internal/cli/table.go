@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// table renders rows in a box drawn with Unicode line characters. Columns
+// after the first are right-aligned, as they hold numbers.
+type table struct {
+	headers []string
+	rows    [][]string
+	// footer is an optional totals row set off by a rule
+	footer []string
+}
+
+func (t *table) add(row ...string) {
+	t.rows = append(t.rows, row)
+}
+
+func (t *table) render(w io.Writer) {
+	widths := make([]int, len(t.headers))
+	for _, row := range append(append([][]string{t.headers}, t.rows...), t.footer) {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	rule := func(left, mid, right string) {
+		parts := make([]string, len(widths))
+		for i, width := range widths {
+			parts[i] = strings.Repeat("─", width+2)
+		}
+		fmt.Fprintln(w, left+strings.Join(parts, mid)+right)
+	}
+	line := func(row []string) {
+		cells := make([]string, len(widths))
+		for i, width := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			pad := strings.Repeat(" ", width-utf8.RuneCountInString(cell))
+			if i == 0 {
+				cells[i] = " " + cell + pad + " "
+			} else {
+				cells[i] = " " + pad + cell + " "
+			}
+		}
+		fmt.Fprintln(w, "│"+strings.Join(cells, "│")+"│")
+	}
+
+	rule("┌", "┬", "┐")
+	line(t.headers)
+	rule("├", "┼", "┤")
+	for _, row := range t.rows {
+		line(row)
+	}
+	if t.footer != nil {
+		rule("├", "┼", "┤")
+		line(t.footer)
+	}
+	rule("└", "┴", "┘")
+}
+
+// formatCount formats n with thousands separators
+func formatCount(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+func formatDollars(cost float64) string {
+	return fmt.Sprintf("$%.2f", cost)
+}
@@ -0,0 +1,779 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultProfile is the profile whose data lives directly in ~/.claudeee
+const DefaultProfile = "default"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+var userIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.@-]*$`)
+
+// Instance modes decide what happens when another server already owns the data directory
+const (
+	InstanceModeExit     = "exit"
+	InstanceModeTakeover = "takeover"
+	InstanceModeProxy    = "proxy"
+)
+
+// Config holds the server settings resolved at startup
+type Config struct {
+	Port int
+	// PortFallbackAttempts is how many following ports to try when Port is taken
+	PortFallbackAttempts int
+	FrontendURL          string
+	// CORSOrigins are the browser origins allowed to call the API; defaults to FrontendURL
+	CORSOrigins []string
+	// Profile selects an isolated data directory with its own database
+	Profile      string
+	DataDir      string
+	InstanceMode string
+	// DBDriver selects the database: duckdb (a file in DataDir) or postgres
+	DBDriver string
+	// DBDSN is the connection string; for duckdb it overrides the database file
+	DBDSN string
+	// ClaudeDirs are the Claude projects directories to sync logs from, from
+	// CLAUDEEE_CLAUDE_DIRS, else profile.json, else the configuration file,
+	// else ~/.claude/projects
+	ClaudeDirs []string
+	// CodexDirs are the Codex CLI sessions directories to sync logs from, from
+	// CLAUDEEE_CODEX_DIRS, else the configuration file, else
+	// $CODEX_HOME/sessions or ~/.codex/sessions; none when SyncCodex is off
+	CodexDirs []string
+	// GeminiLogs are the Gemini CLI telemetry files, or directories searched
+	// for them, to sync, from CLAUDEEE_GEMINI_LOGS, else the configuration
+	// file, else ~/.gemini; none when SyncGemini is off
+	GeminiLogs []string
+	// User is the ID of the user that ClaudeDirs belong to
+	User string
+	// Users are further people whose logs this server syncs
+	Users []UserConfig
+	// IncludeProjects and ExcludeProjects filter project directories by glob pattern
+	IncludeProjects []string
+	ExcludeProjects []string
+	// WatchLogs syncs automatically when log files change
+	WatchLogs           bool
+	WatchDebounceMillis int
+	Log                 LogConfig
+	Auth                AuthConfig
+	// ReadOnlyAPI rejects every mutating API request
+	ReadOnlyAPI bool
+	// ReadOnly opens the database read-only and runs no syncs or background
+	// jobs, so the instance can share a database another one writes
+	ReadOnly bool
+	// Metrics serves Prometheus metrics at /metrics
+	Metrics bool
+	// APIDocs serves interactive API documentation at /api/docs
+	APIDocs bool
+	// AuditLog records API access; defaults to on when authentication is enabled
+	AuditLog           bool
+	AuditRetentionDays int
+	// Backup configures database backups
+	Backup BackupConfig
+	// Notifications configures the channels notified of window utilization
+	Notifications NotificationsConfig
+	// Defaults for settings that can later be changed through /api/config
+	Plan string
+	// PlanTokenLimit is the per-window token limit of the custom plan
+	PlanTokenLimit      int
+	Timezone            string
+	SyncIntervalMinutes int
+	// ContentPolicy is full, truncated or metadata, and can be set as
+	// store_content with none for metadata
+	ContentPolicy string
+	ContentMaxKB  int
+	// ContentCompression stores message content compressed with zstd
+	ContentCompression bool
+	// ContentRetentionDays removes message content older than this many
+	// days; 0 keeps it forever
+	ContentRetentionDays int
+	RedactSecrets        bool
+	// RedactionRules add to or change the built-in secret redaction rules
+	RedactionRules []RedactionRule
+	// ContentKey and ContentKeyFile supply the key that encrypts stored message content
+	ContentKey     string
+	ContentKeyFile string
+	// PrivacyMode forces the metadata content policy and removes stored content
+	PrivacyMode bool
+	// Features holds feature flag values from CLAUDEEE_FEATURES
+	Features map[string]bool
+	// SyncWorkers is the number of log files parsed at once; 0 uses GOMAXPROCS
+	SyncWorkers int
+	// WindowHours is the length of a usage window
+	WindowHours int
+	// WindowAnchor is first_message, where a window starts at its first
+	// message, or clock, for fixed windows from midnight UTC
+	WindowAnchor string
+	// FlagMissingSources marks sessions whose log files were all deleted
+	// instead of leaving them looking current
+	FlagMissingSources bool
+	// SessionIdleMinutes completes sessions without messages for this many
+	// minutes; 0 leaves them active
+	SessionIdleMinutes int
+	// Billing is how usage is billed when its log entries do not show
+	// pay-as-you-go API billing: subscription or api
+	Billing string
+	// TaskExecution lets POST /api/tasks/:id/run start Claude Code
+	TaskExecution bool
+	// ClaudeCommand is the Claude Code executable tasks run with
+	ClaudeCommand string
+	// IngestTokens maps each token accepted by /api/ingest to the user whose
+	// logs an agent pushes with it
+	IngestTokens map[string]string
+	// Agent configures the agent command, which pushes local logs to a server
+	Agent AgentConfig
+	// Pricing overrides the built-in price of models or model families
+	Pricing map[string]ModelPrice
+	// PricingFile is a pricing.json whose prices win over Pricing; it is
+	// reloaded when it changes
+	PricingFile string
+	// PricingUpdates fetches model prices from PricingURL, a LiteLLM price
+	// registry, at startup
+	PricingUpdates bool
+	PricingURL     string
+	// File is the configuration file that was read, if any
+	File string
+}
+
+// AgentConfig says where the agent command pushes log entries
+type AgentConfig struct {
+	// Server is the base URL of the claudeee server, e.g. https://claudeee.example.com
+	Server string
+	// Token is one of the server's ingest tokens
+	Token           string
+	IntervalSeconds int
+}
+
+// BackupConfig says where database backups go and how often they are made
+type BackupConfig struct {
+	Dir string
+	// IntervalHours between scheduled backups; 0 only backs up on request
+	IntervalHours int
+	// Keep is how many backups are kept; older ones are deleted
+	Keep int
+}
+
+// NotificationsConfig holds the settings of each notification channel
+type NotificationsConfig struct {
+	// Desktop shows notifications with the system's notifier: osascript,
+	// notify-send or a Windows toast
+	Desktop NotificationChannelConfig
+}
+
+// NotificationChannelConfig says when a channel notifies
+type NotificationChannelConfig struct {
+	Enabled bool
+	// Thresholds are window utilizations, e.g. 0.8 for 80%; empty uses the
+	// warning and critical thresholds of /api/config
+	Thresholds  []float64
+	WindowReset bool
+}
+
+// LogConfig controls log output and optional file logging
+type LogConfig struct {
+	// Level is the minimum level written: debug, info, warn or error
+	Level string
+	// Format is text or json
+	Format string
+	// File is the log file path. Empty disables file logging.
+	File       string
+	MaxSizeMB  int
+	MaxAgeDays int
+	MaxBackups int
+}
+
+// AuthConfig controls optional login for the API and web UI
+type AuthConfig struct {
+	// Mode is none, basic or oidc
+	Mode     string
+	Username string
+	Password string
+	// ViewerUsername and ViewerPassword add an account that sees usage but no message content
+	ViewerUsername string
+	ViewerPassword string
+	// Secret signs session cookies; generated and stored in the data directory when empty
+	Secret            string
+	SessionTTLHours   int
+	SecureCookies     bool
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCAllowedEmails []string
+	OIDCAdminEmails   []string
+}
+
+// ProfileConfig is read from profile.json in a profile's data directory
+type ProfileConfig struct {
+	ClaudeDirs      []string     `json:"claude_dirs"`
+	IncludeProjects []string     `json:"include_projects"`
+	ExcludeProjects []string     `json:"exclude_projects"`
+	Users           []UserConfig `json:"users"`
+}
+
+// UserConfig is a person whose logs are synced from their own Claude projects directories
+type UserConfig struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// ClaudeDirs are the Claude projects directories holding this user's logs
+	ClaudeDirs []string `json:"claude_dirs"`
+	// Logins are the sign-in user names or OIDC emails of this user; without
+	// the admin role they only see their own data
+	Logins []string `json:"logins"`
+}
+
+// Load builds the configuration from the profile's configuration file and
+// environment variables, which override it. profile selects a named profile;
+// when empty, CLAUDEEE_PROFILE is used.
+func Load(profile string) (*Config, error) {
+	if profile == "" {
+		profile = os.Getenv("CLAUDEEE_PROFILE")
+	}
+	if profile == "" {
+		profile = DefaultProfile
+	}
+	if !profileNamePattern.MatchString(profile) {
+		return nil, fmt.Errorf("invalid profile name %q", profile)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	dataDir := ProfileDataDir(homeDir, profile)
+
+	// The configuration file supplies defaults that environment variables override
+	file, filePath, err := loadFileConfig(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	dbDSN := or(file.DBDSN, "")
+	if file.DBPath != nil && dbDSN == "" {
+		dbDSN = expandHome(*file.DBPath, homeDir)
+	}
+
+	cfg := &Config{
+		Port:                 getEnvInt("PORT", or(file.Port, 8080)),
+		PortFallbackAttempts: getEnvInt("CLAUDEEE_PORT_FALLBACK_ATTEMPTS", 20),
+		FrontendURL:          getEnv("FRONTEND_URL", or(file.FrontendURL, "http://localhost:3000")),
+		CORSOrigins:          splitList(os.Getenv("CLAUDEEE_CORS_ORIGINS")),
+		Profile:              profile,
+		DataDir:              dataDir,
+		InstanceMode:         InstanceModeExit,
+		DBDriver:             strings.ToLower(getEnv("CLAUDEEE_DB_DRIVER", or(file.DBDriver, "duckdb"))),
+		DBDSN:                getEnv("CLAUDEEE_DB_DSN", dbDSN),
+		ClaudeDirs:           []string{filepath.Join(homeDir, ".claude", "projects")},
+		User:                 getEnv("CLAUDEEE_USER", or(file.User, defaultUser())),
+		WatchLogs:            getEnvBool("CLAUDEEE_WATCH_LOGS", or(file.WatchLogs, true)),
+		WatchDebounceMillis:  getEnvInt("CLAUDEEE_WATCH_DEBOUNCE_MS", 2000),
+		Log: LogConfig{
+			Level:      strings.ToLower(getEnv("CLAUDEEE_LOG_LEVEL", or(file.LogLevel, "info"))),
+			Format:     strings.ToLower(getEnv("CLAUDEEE_LOG_FORMAT", or(file.LogFormat, "text"))),
+			MaxSizeMB:  getEnvInt("CLAUDEEE_LOG_MAX_SIZE_MB", 10),
+			MaxAgeDays: getEnvInt("CLAUDEEE_LOG_MAX_AGE_DAYS", 14),
+			MaxBackups: getEnvInt("CLAUDEEE_LOG_MAX_BACKUPS", 5),
+		},
+		Auth: AuthConfig{
+			Mode:              strings.ToLower(getEnv("CLAUDEEE_AUTH_MODE", "none")),
+			Username:          os.Getenv("CLAUDEEE_AUTH_USERNAME"),
+			Password:          os.Getenv("CLAUDEEE_AUTH_PASSWORD"),
+			ViewerUsername:    os.Getenv("CLAUDEEE_AUTH_VIEWER_USERNAME"),
+			ViewerPassword:    os.Getenv("CLAUDEEE_AUTH_VIEWER_PASSWORD"),
+			Secret:            os.Getenv("CLAUDEEE_AUTH_SECRET"),
+			SessionTTLHours:   getEnvInt("CLAUDEEE_AUTH_SESSION_TTL_HOURS", 168),
+			SecureCookies:     getEnvBool("CLAUDEEE_AUTH_SECURE_COOKIES", false),
+			OIDCIssuer:        os.Getenv("CLAUDEEE_OIDC_ISSUER"),
+			OIDCClientID:      os.Getenv("CLAUDEEE_OIDC_CLIENT_ID"),
+			OIDCClientSecret:  os.Getenv("CLAUDEEE_OIDC_CLIENT_SECRET"),
+			OIDCRedirectURL:   os.Getenv("CLAUDEEE_OIDC_REDIRECT_URL"),
+			OIDCAllowedEmails: splitList(os.Getenv("CLAUDEEE_OIDC_ALLOWED_EMAILS")),
+			OIDCAdminEmails:   splitList(os.Getenv("CLAUDEEE_OIDC_ADMIN_EMAILS")),
+		},
+		Features:             parseFeatures(os.Getenv("CLAUDEEE_FEATURES")),
+		Plan:                 strings.ToLower(getEnv("CLAUDEEE_PLAN", or(file.Plan, "pro"))),
+		PlanTokenLimit:       getEnvInt("CLAUDEEE_PLAN_TOKEN_LIMIT", or(file.PlanTokenLimit, 0)),
+		Timezone:             getEnv("CLAUDEEE_TIMEZONE", or(file.Timezone, "UTC")),
+		SyncIntervalMinutes:  getEnvInt("CLAUDEEE_SYNC_INTERVAL_MINUTES", or(file.SyncIntervalMinutes, 5)),
+		ContentPolicy:        ContentPolicyMode(getEnv("CLAUDEEE_STORE_CONTENT", getEnv("CLAUDEEE_CONTENT_POLICY", or(file.StoreContent, or(file.ContentPolicy, "full"))))),
+		ContentMaxKB:         getEnvInt("CLAUDEEE_CONTENT_MAX_KB", or(file.ContentMaxKB, 16)),
+		ContentCompression:   getEnvBool("CLAUDEEE_CONTENT_COMPRESSION", or(file.ContentCompression, false)),
+		ContentRetentionDays: getEnvInt("CLAUDEEE_CONTENT_RETENTION_DAYS", or(file.ContentRetention, 0)),
+		RedactSecrets:        getEnvBool("CLAUDEEE_REDACT_SECRETS", or(file.RedactSecrets, true)),
+		RedactionRules:       file.RedactionRules,
+		PrivacyMode:          getEnvBool("CLAUDEEE_PRIVACY_MODE", or(file.PrivacyMode, false)),
+		ReadOnlyAPI:          getEnvBool("CLAUDEEE_READ_ONLY_API", or(file.ReadOnlyAPI, false)),
+		ReadOnly:             getEnvBool("CLAUDEEE_READ_ONLY", or(file.ReadOnly, false)),
+		Metrics:              getEnvBool("CLAUDEEE_METRICS", or(file.Metrics, true)),
+		APIDocs:              getEnvBool("CLAUDEEE_API_DOCS", or(file.APIDocs, true)),
+		ContentKey:           os.Getenv("CLAUDEEE_CONTENT_KEY"),
+		ContentKeyFile:       os.Getenv("CLAUDEEE_CONTENT_KEY_FILE"),
+		SyncWorkers:          getEnvInt("CLAUDEEE_SYNC_WORKERS", or(file.SyncWorkers, 0)),
+		WindowHours:          getEnvInt("CLAUDEEE_WINDOW_HOURS", or(file.WindowHours, 5)),
+		WindowAnchor:         strings.ToLower(getEnv("CLAUDEEE_WINDOW_ANCHOR", or(file.WindowAnchor, "first_message"))),
+		FlagMissingSources:   getEnvBool("CLAUDEEE_FLAG_MISSING_SOURCES", or(file.FlagMissingSources, true)),
+		SessionIdleMinutes:   getEnvInt("CLAUDEEE_SESSION_IDLE_MINUTES", or(file.SessionIdleMinutes, 30)),
+		Billing:              strings.ToLower(getEnv("CLAUDEEE_BILLING", or(file.Billing, "subscription"))),
+		TaskExecution:        getEnvBool("CLAUDEEE_TASK_EXECUTION", or(file.TaskExecution, false)),
+		ClaudeCommand:        getEnv("CLAUDEEE_CLAUDE_COMMAND", or(file.ClaudeCommand, "claude")),
+		Pricing:              file.Pricing,
+		PricingFile:          getEnv("CLAUDEEE_PRICING_FILE", or(file.PricingFile, filepath.Join(dataDir, "pricing.json"))),
+		PricingUpdates:       getEnvBool("CLAUDEEE_PRICING_UPDATES", or(file.PricingUpdates, false)),
+		PricingURL:           getEnv("CLAUDEEE_PRICING_URL", or(file.PricingURL, "https://raw.githubusercontent.com/BerriAI/litellm/main/model_prices_and_context_window.json")),
+		File:                 filePath,
+		Backup: BackupConfig{
+			Dir:           expandHome(getEnv("CLAUDEEE_BACKUP_DIR", or(file.BackupDir, filepath.Join(dataDir, "backups"))), homeDir),
+			IntervalHours: getEnvInt("CLAUDEEE_BACKUP_INTERVAL_HOURS", or(file.BackupIntervalHours, 0)),
+			Keep:          getEnvInt("CLAUDEEE_BACKUP_KEEP", or(file.BackupKeep, 7)),
+		},
+		Notifications: NotificationsConfig{
+			Desktop: NotificationChannelConfig{
+				Enabled:     getEnvBool("CLAUDEEE_NOTIFY_DESKTOP", or(file.Notifications.desktop().Enabled, false)),
+				WindowReset: getEnvBool("CLAUDEEE_NOTIFY_DESKTOP_WINDOW_RESET", or(file.Notifications.desktop().WindowReset, true)),
+			},
+		},
+		Agent: AgentConfig{
+			Server:          strings.TrimRight(os.Getenv("CLAUDEEE_AGENT_SERVER"), "/"),
+			Token:           os.Getenv("CLAUDEEE_AGENT_TOKEN"),
+			IntervalSeconds: getEnvInt("CLAUDEEE_AGENT_INTERVAL_SECONDS", 60),
+		},
+	}
+
+	cfg.AuditLog = getEnvBool("CLAUDEEE_AUDIT_LOG", cfg.Auth.Mode != "none")
+	cfg.AuditRetentionDays = getEnvInt("CLAUDEEE_AUDIT_RETENTION_DAYS", 90)
+
+	if len(cfg.CORSOrigins) == 0 {
+		cfg.CORSOrigins = file.CORSOrigins
+	}
+	if len(file.ClaudeDirs) > 0 {
+		cfg.ClaudeDirs = claudeDirs(file.ClaudeDirs, homeDir)
+	}
+	if getEnvBool("CLAUDEEE_SYNC_CODEX", or(file.SyncCodex, true)) {
+		cfg.CodexDirs = codexDirs(file.CodexDirs, homeDir)
+	}
+	if getEnvBool("CLAUDEEE_SYNC_GEMINI", or(file.SyncGemini, true)) {
+		cfg.GeminiLogs = geminiLogs(file.GeminiLogs, homeDir)
+	}
+	if len(cfg.CORSOrigins) == 0 {
+		cfg.CORSOrigins = []string{cfg.FrontendURL}
+	}
+	// Browsers send the login cookie with cross-origin requests, so any site
+	// could read the API on behalf of a signed-in user
+	if cfg.Auth.Mode != "" && cfg.Auth.Mode != "none" && cfg.AnyCORSOrigin() {
+		return nil, fmt.Errorf("CLAUDEEE_CORS_ORIGINS must not contain * when authentication is enabled")
+	}
+
+	// CLAUDEEE_LOG_FILE accepts either a boolean or an explicit path
+	switch logFile := strings.TrimSpace(getEnv("CLAUDEEE_LOG_FILE", or(file.LogFile, ""))); strings.ToLower(logFile) {
+	case "", "0", "false", "off":
+	case "1", "true", "on":
+		cfg.Log.File = filepath.Join(cfg.DataDir, "logs", "server.log")
+	default:
+		cfg.Log.File = logFile
+	}
+
+	switch cfg.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return nil, fmt.Errorf("invalid CLAUDEEE_LOG_LEVEL %q (expected debug, info, warn or error)", cfg.Log.Level)
+	}
+	switch cfg.Log.Format {
+	case "text", "json":
+	default:
+		return nil, fmt.Errorf("invalid CLAUDEEE_LOG_FORMAT %q (expected text or json)", cfg.Log.Format)
+	}
+
+	switch mode := strings.ToLower(os.Getenv("CLAUDEEE_INSTANCE_MODE")); mode {
+	case "":
+	case InstanceModeExit, InstanceModeTakeover, InstanceModeProxy:
+		cfg.InstanceMode = mode
+	default:
+		return nil, fmt.Errorf("invalid CLAUDEEE_INSTANCE_MODE %q (expected exit, takeover or proxy)", mode)
+	}
+
+	if cfg.Plan == "custom" && cfg.PlanTokenLimit <= 0 {
+		return nil, fmt.Errorf("CLAUDEEE_PLAN_TOKEN_LIMIT is required when CLAUDEEE_PLAN is custom")
+	}
+
+	if cfg.WindowHours < 1 || cfg.WindowHours > 24 {
+		return nil, fmt.Errorf("invalid CLAUDEEE_WINDOW_HOURS %d (expected 1 to 24)", cfg.WindowHours)
+	}
+	switch cfg.WindowAnchor {
+	case "first_message", "clock":
+	default:
+		return nil, fmt.Errorf("invalid CLAUDEEE_WINDOW_ANCHOR %q (expected first_message or clock)", cfg.WindowAnchor)
+	}
+
+	switch cfg.Billing {
+	case "subscription", "api":
+	default:
+		return nil, fmt.Errorf("invalid CLAUDEEE_BILLING %q (expected subscription or api)", cfg.Billing)
+	}
+
+	if cfg.SessionIdleMinutes < 0 || cfg.SessionIdleMinutes > 10080 {
+		return nil, fmt.Errorf("invalid CLAUDEEE_SESSION_IDLE_MINUTES %d (expected 0 to 10080)", cfg.SessionIdleMinutes)
+	}
+
+	if cfg.Backup.IntervalHours < 0 {
+		return nil, fmt.Errorf("invalid CLAUDEEE_BACKUP_INTERVAL_HOURS %d (expected 0 or more)", cfg.Backup.IntervalHours)
+	}
+	if cfg.Backup.Keep < 1 {
+		return nil, fmt.Errorf("invalid CLAUDEEE_BACKUP_KEEP %d (expected 1 or more)", cfg.Backup.Keep)
+	}
+
+	cfg.Notifications.Desktop.Thresholds = file.Notifications.desktop().Thresholds
+	if value := os.Getenv("CLAUDEEE_NOTIFY_DESKTOP_THRESHOLDS"); value != "" {
+		if cfg.Notifications.Desktop.Thresholds, err = parseThresholds(value); err != nil {
+			return nil, fmt.Errorf("invalid CLAUDEEE_NOTIFY_DESKTOP_THRESHOLDS: %w", err)
+		}
+	}
+	for _, threshold := range cfg.Notifications.Desktop.Thresholds {
+		if threshold <= 0 || threshold > 2 {
+			return nil, fmt.Errorf("invalid desktop notification threshold %g (expected more than 0 and at most 2)", threshold)
+		}
+	}
+
+	switch cfg.DBDriver {
+	case "duckdb":
+	case "postgres":
+		if cfg.DBDSN == "" {
+			return nil, fmt.Errorf("CLAUDEEE_DB_DSN is required when CLAUDEEE_DB_DRIVER is postgres")
+		}
+	default:
+		return nil, fmt.Errorf("invalid CLAUDEEE_DB_DRIVER %q (expected duckdb or postgres)", cfg.DBDriver)
+	}
+
+	profileConfig, err := loadProfileConfig(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if profileConfig != nil {
+		if len(profileConfig.ClaudeDirs) > 0 {
+			cfg.ClaudeDirs = claudeDirs(profileConfig.ClaudeDirs, homeDir)
+		}
+		cfg.IncludeProjects = profileConfig.IncludeProjects
+		cfg.ExcludeProjects = profileConfig.ExcludeProjects
+		for _, u := range profileConfig.Users {
+			for i, dir := range u.ClaudeDirs {
+				u.ClaudeDirs[i] = expandHome(dir, homeDir)
+			}
+			cfg.Users = append(cfg.Users, u)
+		}
+	}
+	// CLAUDEEE_CLAUDE_DIRS wins over profile.json, like every other variable
+	if dirs := splitList(os.Getenv("CLAUDEEE_CLAUDE_DIRS")); len(dirs) > 0 {
+		cfg.ClaudeDirs = claudeDirs(dirs, homeDir)
+	}
+	if err := validateUsers(cfg.User, cfg.Users); err != nil {
+		return nil, err
+	}
+	if cfg.IngestTokens, err = parseIngestTokens(os.Getenv("CLAUDEEE_INGEST_TOKENS")); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// parseIngestTokens reads a comma-separated list of user:token pairs
+func parseIngestTokens(value string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, pair := range splitList(value) {
+		userID, token, ok := strings.Cut(pair, ":")
+		if !ok || token == "" || !userIDPattern.MatchString(userID) {
+			return nil, fmt.Errorf("invalid CLAUDEEE_INGEST_TOKENS entry %q (expected user:token)", userID)
+		}
+		if _, ok := tokens[token]; ok {
+			return nil, fmt.Errorf("CLAUDEEE_INGEST_TOKENS uses the same token twice")
+		}
+		tokens[token] = userID
+	}
+	return tokens, nil
+}
+
+// parseThresholds reads a comma-separated list of utilizations
+func parseThresholds(value string) ([]float64, error) {
+	var thresholds []float64
+	for _, item := range splitList(value) {
+		threshold, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", item)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	return thresholds, nil
+}
+
+// AnyCORSOrigin reports whether CORSOrigins allows every origin
+func (c *Config) AnyCORSOrigin() bool {
+	for _, origin := range c.CORSOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// LogRoots returns every Claude projects directory to sync: ClaudeDirs and
+// the directories of each configured user
+func (c *Config) LogRoots() []string {
+	roots := append([]string(nil), c.ClaudeDirs...)
+	for _, u := range c.Users {
+		roots = append(roots, u.ClaudeDirs...)
+	}
+	return roots
+}
+
+// RootUsers maps the directories of configured users to their IDs; logs in
+// any other root belong to User
+func (c *Config) RootUsers() map[string]string {
+	users := make(map[string]string)
+	for _, u := range c.Users {
+		for _, dir := range u.ClaudeDirs {
+			users[dir] = u.ID
+		}
+	}
+	return users
+}
+
+// UserLogins maps each login of the configured users to the user's ID
+func (c *Config) UserLogins() map[string]string {
+	logins := make(map[string]string)
+	for _, u := range c.Users {
+		for _, login := range u.Logins {
+			logins[login] = u.ID
+		}
+	}
+	return logins
+}
+
+// AllUsers returns User followed by the configured users and then those that
+// only push logs with an ingest token
+func (c *Config) AllUsers() []UserConfig {
+	users := []UserConfig{{ID: c.User, Name: c.User}}
+	seen := map[string]bool{c.User: true}
+	for _, u := range c.Users {
+		seen[u.ID] = true
+		if u.ID == c.User {
+			users[0] = u
+			continue
+		}
+		users = append(users, u)
+	}
+	var pushing []string
+	for _, userID := range c.IngestTokens {
+		if !seen[userID] {
+			seen[userID] = true
+			pushing = append(pushing, userID)
+		}
+	}
+	sort.Strings(pushing)
+	for _, userID := range pushing {
+		users = append(users, UserConfig{ID: userID})
+	}
+	for i := range users {
+		if users[i].Name == "" {
+			users[i].Name = users[i].ID
+		}
+	}
+	return users
+}
+
+func validateUsers(defaultID string, users []UserConfig) error {
+	if !userIDPattern.MatchString(defaultID) {
+		return fmt.Errorf("invalid CLAUDEEE_USER %q", defaultID)
+	}
+	seen := make(map[string]bool)
+	logins := make(map[string]string)
+	for _, u := range users {
+		if !userIDPattern.MatchString(u.ID) {
+			return fmt.Errorf("invalid user id %q in profile.json", u.ID)
+		}
+		if seen[u.ID] {
+			return fmt.Errorf("duplicate user id %q in profile.json", u.ID)
+		}
+		seen[u.ID] = true
+		for _, login := range u.Logins {
+			if other, ok := logins[login]; ok {
+				return fmt.Errorf("login %q belongs to both %q and %q in profile.json", login, other, u.ID)
+			}
+			logins[login] = u.ID
+		}
+	}
+	return nil
+}
+
+// defaultUser is the name of the account running the server
+func defaultUser() string {
+	if current, err := user.Current(); err == nil && userIDPattern.MatchString(current.Username) {
+		return current.Username
+	}
+	return "local"
+}
+
+// DatabasePath returns the DuckDB file for the selected profile
+func (c *Config) DatabasePath() string {
+	return filepath.Join(c.DataDir, "claudeee.db")
+}
+
+// DatabaseDSN returns the connection string for DBDriver
+func (c *Config) DatabaseDSN() string {
+	if c.DBDSN == "" && c.DBDriver == "duckdb" {
+		return c.DatabasePath()
+	}
+	return c.DBDSN
+}
+
+// ProfileDataDir returns the data directory of a profile
+func ProfileDataDir(homeDir, profile string) string {
+	base := filepath.Join(homeDir, ".claudeee")
+	if profile == "" || profile == DefaultProfile {
+		return base
+	}
+	return filepath.Join(base, "profiles", profile)
+}
+
+func loadProfileConfig(dataDir string) (*ProfileConfig, error) {
+	path := filepath.Join(dataDir, "profile.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var profileConfig ProfileConfig
+	if err := json.Unmarshal(data, &profileConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &profileConfig, nil
+}
+
+func expandHome(path, homeDir string) string {
+	if path == "~" {
+		return homeDir
+	}
+	if strings.HasPrefix(path, "~/") {
+		return filepath.Join(homeDir, path[2:])
+	}
+	return path
+}
+
+// claudeDirs expands ~ in dirs and drops repeats, so no log file is read
+// through two roots
+func claudeDirs(dirs []string, homeDir string) []string {
+	var expanded []string
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		dir = filepath.Clean(expandHome(dir, homeDir))
+		if !seen[dir] {
+			seen[dir] = true
+			expanded = append(expanded, dir)
+		}
+	}
+	return expanded
+}
+
+// codexDirs returns the Codex sessions directories to sync: those of
+// CLAUDEEE_CODEX_DIRS, else fileDirs, else Codex's own default
+func codexDirs(fileDirs []string, homeDir string) []string {
+	if dirs := splitList(os.Getenv("CLAUDEEE_CODEX_DIRS")); len(dirs) > 0 {
+		return claudeDirs(dirs, homeDir)
+	}
+	if len(fileDirs) > 0 {
+		return claudeDirs(fileDirs, homeDir)
+	}
+	if codexHome := os.Getenv("CODEX_HOME"); codexHome != "" {
+		return []string{filepath.Join(expandHome(codexHome, homeDir), "sessions")}
+	}
+	return []string{filepath.Join(homeDir, ".codex", "sessions")}
+}
+
+// geminiLogs returns the Gemini telemetry paths to sync: those of
+// CLAUDEEE_GEMINI_LOGS, else fileLogs, else Gemini's settings directory
+func geminiLogs(fileLogs []string, homeDir string) []string {
+	if logs := splitList(os.Getenv("CLAUDEEE_GEMINI_LOGS")); len(logs) > 0 {
+		return claudeDirs(logs, homeDir)
+	}
+	if len(fileLogs) > 0 {
+		return claudeDirs(fileLogs, homeDir)
+	}
+	return []string{filepath.Join(homeDir, ".gemini")}
+}
+
+// ContentPolicyMode normalizes a content_policy or store_content value; the
+// latter calls the metadata policy none
+func ContentPolicyMode(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "none" {
+		return "metadata"
+	}
+	return value
+}
+
+// splitList parses a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseFeatures parses a comma-separated flag list such as "scheduler,-central_mode".
+// A leading "-" disables the flag, anything else enables it.
+func parseFeatures(value string) map[string]bool {
+	features := map[string]bool{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.HasPrefix(item, "-") {
+			features[strings.TrimPrefix(item, "-")] = false
+		} else {
+			features[strings.TrimPrefix(item, "+")] = true
+		}
+	}
+	return features
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(key))) {
+	case "1", "true", "on", "yes":
+		return true
+	case "0", "false", "off", "no":
+		return false
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,413 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func setupHome(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLAUDEEE_PROFILE", "")
+	return home
+}
+
+func TestLoadDefaultProfile(t *testing.T) {
+	home := setupHome(t)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.Profile != DefaultProfile {
+		t.Errorf("Expected profile %q, got %q", DefaultProfile, cfg.Profile)
+	}
+	if expected := filepath.Join(home, ".claudeee", "claudeee.db"); cfg.DatabasePath() != expected {
+		t.Errorf("Expected database %s, got %s", expected, cfg.DatabasePath())
+	}
+	if expected := []string{filepath.Join(home, ".claude", "projects")}; !reflect.DeepEqual(cfg.ClaudeDirs, expected) {
+		t.Errorf("Expected claude dirs %v, got %v", expected, cfg.ClaudeDirs)
+	}
+}
+
+func TestLoadNamedProfile(t *testing.T) {
+	home := setupHome(t)
+
+	dataDir := filepath.Join(home, ".claudeee", "profiles", "work")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	profileJSON := `{"claude_dirs": ["~/work/projects"], "exclude_projects": ["*-tmp"]}`
+	if err := os.WriteFile(filepath.Join(dataDir, "profile.json"), []byte(profileJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CLAUDEEE_PROFILE", "work")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.DataDir != dataDir {
+		t.Errorf("Expected data dir %s, got %s", dataDir, cfg.DataDir)
+	}
+	if expected := []string{filepath.Join(home, "work", "projects")}; !reflect.DeepEqual(cfg.ClaudeDirs, expected) {
+		t.Errorf("Expected claude dirs %v, got %v", expected, cfg.ClaudeDirs)
+	}
+	if !reflect.DeepEqual(cfg.ExcludeProjects, []string{"*-tmp"}) {
+		t.Errorf("Expected exclude patterns [*-tmp], got %v", cfg.ExcludeProjects)
+	}
+}
+
+func TestLoadInvalidProfile(t *testing.T) {
+	setupHome(t)
+
+	for _, name := range []string{"../etc", "a/b", "-x"} {
+		if _, err := Load(name); err == nil {
+			t.Errorf("Expected error for profile %q", name)
+		}
+	}
+}
+
+func TestLoadCORSOrigins(t *testing.T) {
+	setupHome(t)
+	t.Setenv("FRONTEND_URL", "http://localhost:3100")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if expected := []string{"http://localhost:3100"}; !reflect.DeepEqual(cfg.CORSOrigins, expected) {
+		t.Errorf("Expected CORS origins %v, got %v", expected, cfg.CORSOrigins)
+	}
+
+	t.Setenv("CLAUDEEE_CORS_ORIGINS", "http://localhost:3100, https://*.ts.net,")
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if expected := []string{"http://localhost:3100", "https://*.ts.net"}; !reflect.DeepEqual(cfg.CORSOrigins, expected) {
+		t.Errorf("Expected CORS origins %v, got %v", expected, cfg.CORSOrigins)
+	}
+
+	// Any origin is only allowed while nobody signs in
+	t.Setenv("CLAUDEEE_CORS_ORIGINS", "*")
+	cfg, err = Load("")
+	if err != nil || !cfg.AnyCORSOrigin() {
+		t.Fatalf("Expected * to allow any origin, got %v", err)
+	}
+	t.Setenv("CLAUDEEE_AUTH_MODE", "basic")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "CLAUDEEE_CORS_ORIGINS") {
+		t.Errorf("Expected an error for * with authentication enabled, got %v", err)
+	}
+}
+
+func TestLoadDatabaseDriver(t *testing.T) {
+	home := setupHome(t)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.DBDriver != "duckdb" || cfg.DatabaseDSN() != filepath.Join(home, ".claudeee", "claudeee.db") {
+		t.Errorf("Expected the profile's DuckDB file by default, got %s %s", cfg.DBDriver, cfg.DatabaseDSN())
+	}
+
+	t.Setenv("CLAUDEEE_DB_DRIVER", "postgres")
+	if _, err := Load(""); err == nil {
+		t.Error("Expected an error for postgres without a DSN")
+	}
+
+	dsn := "postgres://claudeee@db.internal/usage?sslmode=require"
+	t.Setenv("CLAUDEEE_DB_DSN", dsn)
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.DBDriver != "postgres" || cfg.DatabaseDSN() != dsn {
+		t.Errorf("Expected postgres at %s, got %s %s", dsn, cfg.DBDriver, cfg.DatabaseDSN())
+	}
+
+	t.Setenv("CLAUDEEE_DB_DRIVER", "mysql")
+	if _, err := Load(""); err == nil {
+		t.Error("Expected an error for an unknown driver")
+	}
+}
+
+func TestLoadCustomPlan(t *testing.T) {
+	setupHome(t)
+
+	t.Setenv("CLAUDEEE_PLAN", "custom")
+	if _, err := Load(""); err == nil {
+		t.Error("Expected an error for the custom plan without a token limit")
+	}
+
+	t.Setenv("CLAUDEEE_PLAN_TOKEN_LIMIT", "50000")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Plan != "custom" || cfg.PlanTokenLimit != 50000 {
+		t.Errorf("Expected custom plan with 50000 tokens, got %s %d", cfg.Plan, cfg.PlanTokenLimit)
+	}
+}
+
+func TestLoadUsers(t *testing.T) {
+	home := setupHome(t)
+	t.Setenv("CLAUDEEE_USER", "alice")
+
+	dataDir := filepath.Join(home, ".claudeee")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	profileJSON := `{"users": [{"id": "bob", "name": "Bob", "claude_dirs": ["~/bob/projects"], "logins": ["bob@example.com"]}]}`
+	if err := os.WriteFile(filepath.Join(dataDir, "profile.json"), []byte(profileJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	bobDir := filepath.Join(home, "bob", "projects")
+	if expected := []string{filepath.Join(home, ".claude", "projects"), bobDir}; !reflect.DeepEqual(cfg.LogRoots(), expected) {
+		t.Errorf("Expected log roots %v, got %v", expected, cfg.LogRoots())
+	}
+	if expected := map[string]string{bobDir: "bob"}; !reflect.DeepEqual(cfg.RootUsers(), expected) {
+		t.Errorf("Expected root users %v, got %v", expected, cfg.RootUsers())
+	}
+	if cfg.UserLogins()["bob@example.com"] != "bob" {
+		t.Errorf("Expected bob's login, got %v", cfg.UserLogins())
+	}
+	if users := cfg.AllUsers(); len(users) != 2 || users[0].ID != "alice" || users[0].Name != "alice" || users[1].Name != "Bob" {
+		t.Errorf("Unexpected users %+v", users)
+	}
+
+	profileJSON = `{"users": [{"id": "bob"}, {"id": "bob"}]}`
+	if err := os.WriteFile(filepath.Join(dataDir, "profile.json"), []byte(profileJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(""); err == nil {
+		t.Error("Expected an error for a duplicate user")
+	}
+}
+
+func TestLoadIngestTokens(t *testing.T) {
+	setupHome(t)
+	t.Setenv("CLAUDEEE_USER", "alice")
+	t.Setenv("CLAUDEEE_INGEST_TOKENS", "carol:c-token, alice:a-token")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if expected := map[string]string{"c-token": "carol", "a-token": "alice"}; !reflect.DeepEqual(cfg.IngestTokens, expected) {
+		t.Errorf("Expected ingest tokens %v, got %v", expected, cfg.IngestTokens)
+	}
+	// Users that only push logs are registered too
+	if users := cfg.AllUsers(); len(users) != 2 || users[1].ID != "carol" || users[1].Name != "carol" {
+		t.Errorf("Unexpected users %+v", users)
+	}
+
+	for _, value := range []string{"carol", "carol:", "-bad:token", "carol:same,dave:same"} {
+		t.Setenv("CLAUDEEE_INGEST_TOKENS", value)
+		if _, err := Load(""); err == nil {
+			t.Errorf("Expected an error for CLAUDEEE_INGEST_TOKENS=%q", value)
+		}
+	}
+}
+
+func TestLoadClaudeDirsFromEnv(t *testing.T) {
+	home := setupHome(t)
+	dataDir := filepath.Join(home, ".claudeee")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "profile.json"), []byte(`{"claude_dirs": ["~/profile/projects"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLAUDEEE_CLAUDE_DIRS", "/Volumes/logs/projects/, ~/copied/projects, /Volumes/logs/projects")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	expected := []string{"/Volumes/logs/projects", filepath.Join(home, "copied", "projects")}
+	if !reflect.DeepEqual(cfg.ClaudeDirs, expected) {
+		t.Errorf("Expected claude dirs %v, got %v", expected, cfg.ClaudeDirs)
+	}
+}
+
+func TestLoadCodexDirs(t *testing.T) {
+	home := setupHome(t)
+	t.Setenv("CODEX_HOME", "")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if expected := []string{filepath.Join(home, ".codex", "sessions")}; !reflect.DeepEqual(cfg.CodexDirs, expected) {
+		t.Errorf("Expected codex dirs %v, got %v", expected, cfg.CodexDirs)
+	}
+
+	t.Setenv("CODEX_HOME", "/opt/codex")
+	if cfg, err = Load(""); err != nil || !reflect.DeepEqual(cfg.CodexDirs, []string{"/opt/codex/sessions"}) {
+		t.Errorf("Expected the sessions directory of CODEX_HOME, got %v (%v)", cfg.CodexDirs, err)
+	}
+
+	t.Setenv("CLAUDEEE_CODEX_DIRS", "~/archive/codex")
+	if cfg, err = Load(""); err != nil || !reflect.DeepEqual(cfg.CodexDirs, []string{filepath.Join(home, "archive", "codex")}) {
+		t.Errorf("Expected the directories of CLAUDEEE_CODEX_DIRS, got %v (%v)", cfg.CodexDirs, err)
+	}
+
+	t.Setenv("CLAUDEEE_SYNC_CODEX", "false")
+	if cfg, err = Load(""); err != nil || len(cfg.CodexDirs) != 0 {
+		t.Errorf("Expected no codex dirs with CLAUDEEE_SYNC_CODEX=false, got %v (%v)", cfg.CodexDirs, err)
+	}
+}
+
+func TestLoadGeminiLogs(t *testing.T) {
+	home := setupHome(t)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if expected := []string{filepath.Join(home, ".gemini")}; !reflect.DeepEqual(cfg.GeminiLogs, expected) {
+		t.Errorf("Expected gemini logs %v, got %v", expected, cfg.GeminiLogs)
+	}
+
+	t.Setenv("CLAUDEEE_GEMINI_LOGS", "~/work/app/.gemini/telemetry.log")
+	if cfg, err = Load(""); err != nil || !reflect.DeepEqual(cfg.GeminiLogs, []string{filepath.Join(home, "work", "app", ".gemini", "telemetry.log")}) {
+		t.Errorf("Expected the paths of CLAUDEEE_GEMINI_LOGS, got %v (%v)", cfg.GeminiLogs, err)
+	}
+
+	t.Setenv("CLAUDEEE_SYNC_GEMINI", "false")
+	if cfg, err = Load(""); err != nil || len(cfg.GeminiLogs) != 0 {
+		t.Errorf("Expected no gemini logs with CLAUDEEE_SYNC_GEMINI=false, got %v (%v)", cfg.GeminiLogs, err)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	home := setupHome(t)
+	dataDir := filepath.Join(home, ".claudeee")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	yamlConfig := `
+port: 9090
+frontend_url: http://dash.local
+db_path: ~/data/usage.db
+plan: max5
+sync_interval_minutes: 10
+log_level: debug
+store_content: none
+redaction_rules:
+  - {name: internal_token, pattern: 'itk_[0-9a-f]{32}'}
+claude_dirs: [~/archive/projects]
+pricing:
+  opus: {input: 10, output: 50, cache_creation: 12.5, cache_read: 1}
+`
+	yamlPath := filepath.Join(dataDir, "config.yaml")
+	if err := os.WriteFile(yamlPath, []byte(yamlConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Environment variables override the file
+	t.Setenv("CLAUDEEE_PLAN", "max20")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.File != yamlPath || cfg.Port != 9090 || cfg.FrontendURL != "http://dash.local" || cfg.SyncIntervalMinutes != 10 || cfg.Log.Level != "debug" {
+		t.Errorf("Expected settings from the file, got %+v", cfg)
+	}
+	if cfg.Plan != "max20" {
+		t.Errorf("Expected CLAUDEEE_PLAN to win, got %q", cfg.Plan)
+	}
+	if len(cfg.RedactionRules) != 1 || cfg.RedactionRules[0].Name != "internal_token" {
+		t.Errorf("Expected a redaction rule from the file, got %+v", cfg.RedactionRules)
+	}
+	if cfg.ContentPolicy != "metadata" {
+		t.Errorf("Expected store_content none to mean the metadata policy, got %q", cfg.ContentPolicy)
+	}
+	if expected := filepath.Join(home, "data", "usage.db"); cfg.DatabaseDSN() != expected {
+		t.Errorf("Expected database %s, got %s", expected, cfg.DatabaseDSN())
+	}
+	if expected := []string{filepath.Join(home, "archive", "projects")}; !reflect.DeepEqual(cfg.ClaudeDirs, expected) {
+		t.Errorf("Expected claude dirs %v, got %v", expected, cfg.ClaudeDirs)
+	}
+	if cfg.Pricing["opus"].Output != 50 {
+		t.Errorf("Expected opus pricing, got %+v", cfg.Pricing)
+	}
+
+	if err := os.WriteFile(filepath.Join(dataDir, "config.toml"), []byte("port = 7070\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(""); err == nil {
+		t.Error("Expected an error for two configuration files")
+	}
+	if err := os.Remove(yamlPath); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := Load(""); err != nil || cfg.Port != 7070 {
+		t.Errorf("Expected port 7070 from config.toml, got %v (%v)", cfg, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dataDir, "config.toml"), []byte("prot = 7070\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(""); err == nil {
+		t.Error("Expected an error for an unknown key")
+	}
+}
+
+func TestLoadNotifications(t *testing.T) {
+	home := setupHome(t)
+	dataDir := filepath.Join(home, ".claudeee")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Notifications.Desktop.Enabled || !cfg.Notifications.Desktop.WindowReset || cfg.Notifications.Desktop.Thresholds != nil {
+		t.Errorf("Expected desktop notifications off by default, got %+v", cfg.Notifications.Desktop)
+	}
+
+	yamlConfig := `
+notifications:
+  desktop:
+    enabled: true
+    thresholds: [0.5, 0.9]
+    window_reset: false
+`
+	if err := os.WriteFile(filepath.Join(dataDir, "config.yaml"), []byte(yamlConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = Load("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	desktop := cfg.Notifications.Desktop
+	if !desktop.Enabled || desktop.WindowReset || !reflect.DeepEqual(desktop.Thresholds, []float64{0.5, 0.9}) {
+		t.Errorf("Expected desktop settings from the file, got %+v", desktop)
+	}
+
+	t.Setenv("CLAUDEEE_NOTIFY_DESKTOP_THRESHOLDS", "0.75, 1")
+	if cfg, err := Load(""); err != nil || !reflect.DeepEqual(cfg.Notifications.Desktop.Thresholds, []float64{0.75, 1}) {
+		t.Errorf("Expected thresholds from the environment, got %v (%v)", cfg, err)
+	}
+	for _, value := range []string{"high", "0", "3"} {
+		t.Setenv("CLAUDEEE_NOTIFY_DESKTOP_THRESHOLDS", value)
+		if _, err := Load(""); err == nil {
+			t.Errorf("Expected an error for threshold %q", value)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileNames are the configuration files looked for in a profile's data
+// directory; at most one of them may exist
+var ConfigFileNames = []string{"config.yaml", "config.yml", "config.toml"}
+
+// FileConfig is read from config.yaml or config.toml in a profile's data
+// directory. Every setting is optional and its environment variable, when
+// set, takes precedence.
+type FileConfig struct {
+	Port                *int                  `yaml:"port" toml:"port"`
+	FrontendURL         *string               `yaml:"frontend_url" toml:"frontend_url"`
+	CORSOrigins         []string              `yaml:"cors_origins" toml:"cors_origins"`
+	DBDriver            *string               `yaml:"db_driver" toml:"db_driver"`
+	DBDSN               *string               `yaml:"db_dsn" toml:"db_dsn"`
+	DBPath              *string               `yaml:"db_path" toml:"db_path"`
+	ClaudeDirs          []string              `yaml:"claude_dirs" toml:"claude_dirs"`
+	CodexDirs           []string              `yaml:"codex_dirs" toml:"codex_dirs"`
+	SyncCodex           *bool                 `yaml:"sync_codex" toml:"sync_codex"`
+	GeminiLogs          []string              `yaml:"gemini_logs" toml:"gemini_logs"`
+	SyncGemini          *bool                 `yaml:"sync_gemini" toml:"sync_gemini"`
+	User                *string               `yaml:"user" toml:"user"`
+	Plan                *string               `yaml:"plan" toml:"plan"`
+	PlanTokenLimit      *int                  `yaml:"plan_token_limit" toml:"plan_token_limit"`
+	Pricing             map[string]ModelPrice `yaml:"pricing" toml:"pricing"`
+	PricingFile         *string               `yaml:"pricing_file" toml:"pricing_file"`
+	PricingUpdates      *bool                 `yaml:"pricing_updates" toml:"pricing_updates"`
+	PricingURL          *string               `yaml:"pricing_url" toml:"pricing_url"`
+	Timezone            *string               `yaml:"timezone" toml:"timezone"`
+	SyncIntervalMinutes *int                  `yaml:"sync_interval_minutes" toml:"sync_interval_minutes"`
+	SyncWorkers         *int                  `yaml:"sync_workers" toml:"sync_workers"`
+	WindowHours         *int                  `yaml:"window_hours" toml:"window_hours"`
+	WindowAnchor        *string               `yaml:"window_anchor" toml:"window_anchor"`
+	FlagMissingSources  *bool                 `yaml:"flag_missing_sources" toml:"flag_missing_sources"`
+	SessionIdleMinutes  *int                  `yaml:"session_idle_minutes" toml:"session_idle_minutes"`
+	Billing             *string               `yaml:"billing" toml:"billing"`
+	TaskExecution       *bool                 `yaml:"task_execution" toml:"task_execution"`
+	ClaudeCommand       *string               `yaml:"claude_command" toml:"claude_command"`
+	WatchLogs           *bool                 `yaml:"watch_logs" toml:"watch_logs"`
+	ContentPolicy       *string               `yaml:"content_policy" toml:"content_policy"`
+	StoreContent        *string               `yaml:"store_content" toml:"store_content"`
+	ContentMaxKB        *int                  `yaml:"content_max_kb" toml:"content_max_kb"`
+	ContentCompression  *bool                 `yaml:"content_compression" toml:"content_compression"`
+	ContentRetention    *int                  `yaml:"content_retention_days" toml:"content_retention_days"`
+	RedactSecrets       *bool                 `yaml:"redact_secrets" toml:"redact_secrets"`
+	RedactionRules      []RedactionRule       `yaml:"redaction_rules" toml:"redaction_rules"`
+	PrivacyMode         *bool                 `yaml:"privacy_mode" toml:"privacy_mode"`
+	ReadOnlyAPI         *bool                 `yaml:"read_only_api" toml:"read_only_api"`
+	ReadOnly            *bool                 `yaml:"read_only" toml:"read_only"`
+	Metrics             *bool                 `yaml:"metrics" toml:"metrics"`
+	APIDocs             *bool                 `yaml:"api_docs" toml:"api_docs"`
+	LogLevel            *string               `yaml:"log_level" toml:"log_level"`
+	LogFormat           *string               `yaml:"log_format" toml:"log_format"`
+	LogFile             *string               `yaml:"log_file" toml:"log_file"`
+	BackupDir           *string               `yaml:"backup_dir" toml:"backup_dir"`
+	BackupIntervalHours *int                  `yaml:"backup_interval_hours" toml:"backup_interval_hours"`
+	BackupKeep          *int                  `yaml:"backup_keep" toml:"backup_keep"`
+	Notifications       *NotificationsFile    `yaml:"notifications" toml:"notifications"`
+}
+
+// NotificationsFile holds the settings of each notification channel
+type NotificationsFile struct {
+	Desktop *NotificationChannelFile `yaml:"desktop" toml:"desktop"`
+}
+
+// NotificationChannelFile says when a channel notifies
+type NotificationChannelFile struct {
+	Enabled     *bool     `yaml:"enabled" toml:"enabled"`
+	Thresholds  []float64 `yaml:"thresholds" toml:"thresholds"`
+	WindowReset *bool     `yaml:"window_reset" toml:"window_reset"`
+}
+
+// desktop returns the desktop channel's settings, empty when the file has
+// none
+func (n *NotificationsFile) desktop() *NotificationChannelFile {
+	if n == nil || n.Desktop == nil {
+		return &NotificationChannelFile{}
+	}
+	return n.Desktop
+}
+
+// RedactionRule is a secret pattern redacted from message content as Name.
+// A rule named after a built-in one replaces it; an empty pattern disables it.
+type RedactionRule struct {
+	Name    string `yaml:"name" toml:"name"`
+	Pattern string `yaml:"pattern" toml:"pattern"`
+}
+
+// ModelPrice is the USD price per million tokens of a model
+type ModelPrice struct {
+	Input         float64 `yaml:"input" toml:"input"`
+	Output        float64 `yaml:"output" toml:"output"`
+	CacheCreation float64 `yaml:"cache_creation" toml:"cache_creation"`
+	CacheRead     float64 `yaml:"cache_read" toml:"cache_read"`
+}
+
+// loadFileConfig reads the configuration file of dataDir. It returns an
+// empty FileConfig when there is none, and rejects unknown keys so a typo
+// does not silently leave a default in place.
+func loadFileConfig(dataDir string) (*FileConfig, string, error) {
+	var found []string
+	for _, name := range ConfigFileNames {
+		path := filepath.Join(dataDir, name)
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return &FileConfig{}, "", nil
+	case 1:
+	default:
+		return nil, "", fmt.Errorf("found several configuration files (%s); keep one", strings.Join(found, ", "))
+	}
+
+	path := found[0]
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var file FileConfig
+	if strings.HasSuffix(path, ".toml") {
+		decoder := toml.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		// An empty file decodes to io.EOF
+		if err = decoder.Decode(&file); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for model, price := range file.Pricing {
+		if price.Input < 0 || price.Output < 0 || price.CacheCreation < 0 || price.CacheRead < 0 {
+			return nil, "", fmt.Errorf("invalid pricing for %q in %s: prices cannot be negative", model, path)
+		}
+	}
+	return &file, path, nil
+}
+
+// or returns *value, or fallback when the file leaves the setting out
+func or[T any](value *T, fallback T) T {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
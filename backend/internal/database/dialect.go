@@ -0,0 +1,8 @@
+package database
+
+// QuarterHour returns SQL that truncates the timestamp column to the start of
+// its quarter hour. It stands in for DuckDB's time_bucket, which PostgreSQL
+// lacks, and runs unchanged on both databases.
+func QuarterHour(column string) string {
+	return "(date_trunc('hour', " + column + ") + CAST(floor(extract(minute FROM " + column + ") / 15) AS INTEGER) * INTERVAL '15 minutes')"
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+// Driver names accepted by CLAUDEEE_DB_DRIVER
+const (
+	DriverDuckDB   = "duckdb"
+	DriverPostgres = "postgres"
+)
+
+// Driver opens one kind of database. The schema and queries are written for
+// DuckDB with ? placeholders; other drivers translate what their database
+// does not understand.
+type Driver interface {
+	// Name is the CLAUDEEE_DB_DRIVER value that selects the driver
+	Name() string
+	// Open connects to the database described by dsn
+	Open(dsn string) (*sql.DB, error)
+	// OpenReadOnly connects to an existing database without writing to it
+	OpenReadOnly(dsn string) (*sql.DB, error)
+}
+
+var drivers = map[string]Driver{
+	DriverDuckDB:   duckDBDriver{},
+	DriverPostgres: postgresDriver{},
+}
+
+// Lookup returns the driver with the given name
+func Lookup(name string) (Driver, error) {
+	driver, ok := drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database driver %q (expected %s or %s)", name, DriverDuckDB, DriverPostgres)
+	}
+	return driver, nil
+}
+
+// duckDBDriver stores everything in a local DuckDB file; the DSN is its path
+type duckDBDriver struct{}
+
+func (duckDBDriver) Name() string {
+	return DriverDuckDB
+}
+
+func (duckDBDriver) Open(dsn string) (*sql.DB, error) {
+	if dsn != "" && dsn != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(dsn), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("duckdb", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
+
+// OpenReadOnly opens the file with access_mode=READ_ONLY. DuckDB lets several
+// processes read a file at once, but none of them while another writes it.
+func (duckDBDriver) OpenReadOnly(dsn string) (*sql.DB, error) {
+	if dsn == "" || dsn == ":memory:" {
+		return nil, fmt.Errorf("a read-only database needs a file")
+	}
+	if _, err := os.Stat(dsn); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("duckdb", dsn+separator+"access_mode=READ_ONLY")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database read-only: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database read-only: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT * FROM messages WHERE session_id = ? AND timestamp > ?", "SELECT * FROM messages WHERE session_id = $1 AND timestamp > $2"},
+		{"INSERT INTO settings (key, value) VALUES ('runtime?', ?)", "INSERT INTO settings (key, value) VALUES ('runtime?', $1)"},
+		{`SELECT "odd?column", 'it''s ?' FROM t WHERE a = ?`, `SELECT "odd?column", 'it''s ?' FROM t WHERE a = $1`},
+	}
+	for _, tt := range tests {
+		if got := rebind(tt.query); got != tt.expected {
+			t.Errorf("rebind(%q) = %q, expected %q", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestTranslateUpsert(t *testing.T) {
+	query := `
+		INSERT OR REPLACE INTO feature_flags (name, enabled, updated_at)
+		VALUES (?, ?, ?)
+	`
+	expected := "INSERT INTO feature_flags (name, enabled, updated_at)\n\t\tVALUES ($1, $2, $3) " +
+		"ON CONFLICT (name) DO UPDATE SET enabled = excluded.enabled, updated_at = excluded.updated_at"
+	if got := translate(query); got != expected {
+		t.Errorf("translate() = %q, expected %q", got, expected)
+	}
+
+	// Columns of a composite key are not updated
+	query = "INSERT OR REPLACE INTO usage_buckets (bucket, model, input_tokens) SELECT ?, ?, ?"
+	expected = "INSERT INTO usage_buckets (bucket, model, input_tokens) SELECT $1, $2, $3 " +
+		"ON CONFLICT (bucket, model) DO UPDATE SET input_tokens = excluded.input_tokens"
+	if got := translate(query); got != expected {
+		t.Errorf("translate() = %q, expected %q", got, expected)
+	}
+
+	// Statements on tables without a known key are passed through
+	query = "INSERT OR REPLACE INTO unknown (a) VALUES (?)"
+	if got := translateUpsert(query); got != query {
+		t.Errorf("Expected %q unchanged, got %q", query, got)
+	}
+}
+
+func TestLookupDriver(t *testing.T) {
+	for _, name := range []string{DriverDuckDB, DriverPostgres} {
+		driver, err := Lookup(name)
+		if err != nil || driver.Name() != name {
+			t.Errorf("Expected driver %s, got %v %v", name, driver, err)
+		}
+	}
+	if _, err := Lookup("sqlite"); err == nil {
+		t.Error("Expected an error for an unknown driver")
+	}
+	if _, err := (postgresDriver{}).Open(""); err == nil {
+		t.Error("Expected postgres to require a DSN")
+	}
+}
+
+func TestQuarterHour(t *testing.T) {
+	// The rollup refresh statement reaches PostgreSQL with nothing DuckDB-only
+	query := "SELECT DISTINCT " + QuarterHour("timestamp") + " FROM messages WHERE created_at > ?"
+	expected := "SELECT DISTINCT (date_trunc('hour', timestamp) + CAST(floor(extract(minute FROM timestamp) / 15) AS INTEGER) * INTERVAL '15 minutes') FROM messages WHERE created_at > $1"
+	translated := translate(query)
+	if translated != expected {
+		t.Errorf("translate() = %q, expected %q", translated, expected)
+	}
+	if strings.Contains(translated, "time_bucket") {
+		t.Errorf("Expected no time_bucket in %q", translated)
+	}
+
+	// and matches time_bucket on DuckDB
+	db := openTestDB(t)
+	for _, ts := range []string{"2024-03-01 09:00:00", "2024-03-01 09:14:59.9", "2024-03-01 09:15:00", "2024-03-01 23:59:59"} {
+		var got, want time.Time
+		err := db.QueryRow("SELECT "+QuarterHour("ts")+", time_bucket(INTERVAL 15 MINUTE, ts) FROM (SELECT CAST(? AS TIMESTAMP) AS ts)", ts).Scan(&got, &want)
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("QuarterHour(%s) = %v, expected %v", ts, got, want)
+		}
+	}
+}
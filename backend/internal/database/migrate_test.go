@@ -0,0 +1,210 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := (duckDBDriver{}).Open(filepath.Join(t.TempDir(), "claudeee.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func latestVersion(t *testing.T) int {
+	t.Helper()
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+func TestInitializeMigratesNewDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "data", "claudeee.db")
+	db, err := Initialize(DriverDuckDB, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatalf("Failed to read schema version: %v", err)
+	}
+	if version != latestVersion(t) {
+		t.Errorf("Expected schema version %d, got %d", latestVersion(t), version)
+	}
+	tables := []string{"sessions", "messages", "session_windows", "file_sync_state", "feature_flags", "settings",
+		"audit_log", "content_encryption", "usage_buckets", "project_usage_rollups", "rollup_state"}
+	for _, table := range tables {
+		if _, err := db.Exec("SELECT COUNT(*) FROM " + table); err != nil {
+			t.Errorf("Expected table %s: %v", table, err)
+		}
+	}
+	db.Close()
+
+	// Opening again applies nothing
+	db, err = Initialize(DriverDuckDB, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	var applied int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&applied); err != nil {
+		t.Fatalf("Failed to count migrations: %v", err)
+	}
+	if applied != latestVersion(t) {
+		t.Errorf("Expected %d recorded migrations, got %d", latestVersion(t), applied)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "claudeee.db")
+	if _, err := OpenReadOnly(DriverDuckDB, dbPath); err == nil {
+		t.Fatal("Expected a missing database to be rejected")
+	}
+
+	db, err := Initialize(DriverDuckDB, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'p', '/p', '2024-01-01 09:00:00')"); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	db.Close()
+
+	db, err = OpenReadOnly(DriverDuckDB, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database read-only: %v", err)
+	}
+	defer db.Close()
+	var sessions int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&sessions); err != nil || sessions != 1 {
+		t.Errorf("Expected to read 1 session, got %d, %v", sessions, err)
+	}
+	if _, err := db.Exec("DELETE FROM sessions"); err == nil {
+		t.Error("Expected writes to a read-only database to fail")
+	}
+}
+
+func TestOpenReadOnlyRequiresCurrentSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "claudeee.db")
+	db, err := (duckDBDriver{}).Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if err := migrate(db, migrations[:len(migrations)-1]); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	db.Close()
+
+	if _, err := OpenReadOnly(DriverDuckDB, dbPath); err == nil {
+		t.Error("Expected an outdated schema to be rejected")
+	}
+}
+
+func TestMigrateKeepsDataOfUnversionedDatabase(t *testing.T) {
+	db := openTestDB(t)
+
+	// Schema of a database created before session windows and migrations
+	_, err := db.Exec(`
+		CREATE TABLE sessions (
+			id VARCHAR PRIMARY KEY,
+			project_name VARCHAR NOT NULL,
+			project_path VARCHAR NOT NULL,
+			start_time TIMESTAMP NOT NULL,
+			end_time TIMESTAMP,
+			total_input_tokens INTEGER DEFAULT 0,
+			total_output_tokens INTEGER DEFAULT 0,
+			total_tokens INTEGER DEFAULT 0,
+			message_count INTEGER DEFAULT 0,
+			status VARCHAR DEFAULT 'active',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE messages (
+			id VARCHAR PRIMARY KEY,
+			session_id VARCHAR NOT NULL,
+			message_role VARCHAR,
+			input_tokens INTEGER DEFAULT 0,
+			output_tokens INTEGER DEFAULT 0,
+			timestamp TIMESTAMP NOT NULL
+		);
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'p', '/p', '2024-01-01 09:00:00');
+		INSERT INTO messages (id, session_id, message_role, input_tokens, timestamp) VALUES ('m1', 's1', 'assistant', 42, '2024-01-01 09:00:00');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	var tokens int
+	var windowID sql.NullString
+	if err := db.QueryRow("SELECT input_tokens, session_window_id FROM messages WHERE id = 'm1'").Scan(&tokens, &windowID); err != nil {
+		t.Fatalf("Failed to read migrated message: %v", err)
+	}
+	if tokens != 42 || windowID.Valid {
+		t.Errorf("Expected message kept with an empty window, got %d tokens and window %v", tokens, windowID)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	if err := Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_migrations VALUES (9999, 'from_the_future', CURRENT_TIMESTAMP)"); err != nil {
+		t.Fatalf("Failed to record migration: %v", err)
+	}
+	if err := Migrate(db); err == nil {
+		t.Error("Expected an error for a schema newer than the server")
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	db := openTestDB(t)
+	migrations, err := loadMigrations(fstest.MapFS{
+		"m/0001_widgets.sql": {Data: []byte("-- Widgets\nCREATE TABLE widgets (id INTEGER PRIMARY KEY);\n")},
+		"m/0002_broken.sql":  {Data: []byte("CREATE TABLE gadgets (id INTEGER);\nINSERT INTO missing VALUES (1);\n")},
+	}, "m")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if len(migrations) != 2 || len(migrations[0].Statements) != 1 || migrations[1].Name != "broken" {
+		t.Fatalf("Unexpected migrations: %+v", migrations)
+	}
+
+	if err := migrate(db, migrations); err == nil {
+		t.Fatal("Expected the broken migration to fail")
+	}
+	if version, _ := SchemaVersion(db); version != 1 {
+		t.Errorf("Expected schema version 1, got %d", version)
+	}
+	if _, err := db.Exec("SELECT COUNT(*) FROM gadgets"); err == nil {
+		t.Error("Expected the broken migration's table to be rolled back")
+	}
+}
+
+func TestLoadMigrationsRejectsBadFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad name":          {"m/widgets.sql": {Data: []byte("SELECT 1")}},
+		"duplicate version": {"m/0001_a.sql": {Data: []byte("SELECT 1")}, "m/001_b.sql": {Data: []byte("SELECT 1")}},
+	}
+	for name, fsys := range cases {
+		if _, err := loadMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
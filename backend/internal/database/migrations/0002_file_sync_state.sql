@@ -0,0 +1,22 @@
+-- Per-file position of the differential sync
+CREATE TABLE IF NOT EXISTS file_sync_state (
+	file_path VARCHAR PRIMARY KEY,
+	last_modified TIMESTAMP NOT NULL,
+	file_size BIGINT NOT NULL,
+	last_processed_line INTEGER DEFAULT 0,
+	last_processed_offset BIGINT DEFAULT 0,
+	processed_until TIMESTAMP,
+	checksum VARCHAR(64),
+	sync_status VARCHAR DEFAULT 'pending',
+	last_sync_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	error_message TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Databases created before offsets were tracked resume by line count
+ALTER TABLE file_sync_state ADD COLUMN IF NOT EXISTS last_processed_offset BIGINT DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_file_sync_state_path ON file_sync_state (file_path);
+CREATE INDEX IF NOT EXISTS idx_file_sync_state_status ON file_sync_state (sync_status);
+CREATE INDEX IF NOT EXISTS idx_file_sync_state_modified ON file_sync_state (last_modified);
@@ -0,0 +1,17 @@
+-- Tool calls parsed from tool_use blocks, completed by their tool_result
+CREATE TABLE IF NOT EXISTS tool_calls (
+	id VARCHAR PRIMARY KEY,
+	message_id VARCHAR NOT NULL,
+	session_id VARCHAR NOT NULL,
+	tool_name VARCHAR NOT NULL,
+	input_size INTEGER DEFAULT 0,
+	called_at TIMESTAMP NOT NULL,
+	result_message_id VARCHAR,
+	completed_at TIMESTAMP,
+	duration_ms BIGINT,
+	status VARCHAR DEFAULT 'pending'
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_calls_tool_name ON tool_calls (tool_name);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_message_id ON tool_calls (message_id);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_called_at ON tool_calls (called_at);
@@ -0,0 +1,31 @@
+-- Spending limits, global when project_name is NULL
+CREATE TABLE IF NOT EXISTS budgets (
+	id VARCHAR PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	project_name VARCHAR,
+	period VARCHAR NOT NULL,
+	metric VARCHAR NOT NULL,
+	amount DOUBLE PRECISION NOT NULL,
+	thresholds VARCHAR NOT NULL,
+	enabled BOOLEAN DEFAULT true,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Thresholds crossed by a budget, at most once per budget period
+CREATE TABLE IF NOT EXISTS budget_alerts (
+	id VARCHAR PRIMARY KEY,
+	budget_id VARCHAR NOT NULL,
+	budget_name VARCHAR NOT NULL,
+	project_name VARCHAR,
+	metric VARCHAR NOT NULL,
+	threshold DOUBLE PRECISION NOT NULL,
+	amount DOUBLE PRECISION NOT NULL,
+	spent DOUBLE PRECISION NOT NULL,
+	period_start TIMESTAMP NOT NULL,
+	period_end TIMESTAMP NOT NULL,
+	triggered_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_budget_alerts_budget_id ON budget_alerts (budget_id);
+CREATE INDEX IF NOT EXISTS idx_budget_alerts_triggered_at ON budget_alerts (triggered_at);
@@ -0,0 +1,28 @@
+-- Outbound webhook subscriptions; events is a comma separated list of event types
+CREATE TABLE IF NOT EXISTS webhooks (
+	id VARCHAR PRIMARY KEY,
+	url VARCHAR NOT NULL,
+	secret VARCHAR NOT NULL,
+	events VARCHAR NOT NULL,
+	enabled BOOLEAN DEFAULT true,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One row per event sent to a webhook. event_key is set for events that must
+-- be delivered at most once, such as a threshold crossed in a session window.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id VARCHAR PRIMARY KEY,
+	webhook_id VARCHAR NOT NULL,
+	event VARCHAR NOT NULL,
+	event_key VARCHAR,
+	status VARCHAR NOT NULL,
+	attempts INTEGER DEFAULT 0,
+	response_status INTEGER,
+	error TEXT,
+	created_at TIMESTAMP NOT NULL,
+	completed_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event_key ON webhook_deliveries (event_key);
@@ -0,0 +1,11 @@
+-- People whose Claude logs are synced into a shared server
+CREATE TABLE IF NOT EXISTS users (
+	id VARCHAR PRIMARY KEY,
+	name VARCHAR NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The user whose log root a session was read from. Sessions synced before
+-- users existed are assigned to the default user at startup. Not indexed:
+-- DuckDB rejects updates to indexed columns of rows with a primary key.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_id VARCHAR;
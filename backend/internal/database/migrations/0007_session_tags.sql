@@ -0,0 +1,12 @@
+-- Labels put on sessions, such as a client to bill or an experiment
+CREATE TABLE IF NOT EXISTS session_tags (
+	session_id VARCHAR NOT NULL,
+	tag VARCHAR NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (session_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_tags_tag ON session_tags (tag);
+
+-- Free-form notes on a session. Not indexed, like user_id.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS notes TEXT;
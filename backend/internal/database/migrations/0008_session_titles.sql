@@ -0,0 +1,3 @@
+-- A readable session name taken from its first prompt, encrypted like
+-- message content when a content key is configured
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS title TEXT;
@@ -0,0 +1,27 @@
+-- The message counted for each (request_id, API message id). Claude Code can
+-- log one response several times, e.g. on retries or once per content block,
+-- each entry repeating the usage of the response.
+CREATE TABLE IF NOT EXISTS message_usage_keys (
+	request_id VARCHAR NOT NULL,
+	api_message_id VARCHAR NOT NULL,
+	message_id VARCHAR NOT NULL,
+	PRIMARY KEY (request_id, api_message_id)
+);
+
+-- Entries whose usage was not counted because it repeated that of the
+-- counted message; the usage they carried is kept for the report
+CREATE TABLE IF NOT EXISTS duplicate_messages (
+	message_id VARCHAR PRIMARY KEY,
+	duplicate_of VARCHAR NOT NULL,
+	request_id VARCHAR NOT NULL,
+	api_message_id VARCHAR NOT NULL,
+	session_id VARCHAR NOT NULL,
+	input_tokens INTEGER DEFAULT 0,
+	cache_creation_input_tokens INTEGER DEFAULT 0,
+	cache_read_input_tokens INTEGER DEFAULT 0,
+	output_tokens INTEGER DEFAULT 0,
+	timestamp TIMESTAMP NOT NULL,
+	detected_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_duplicate_messages_detected_at ON duplicate_messages (detected_at);
@@ -0,0 +1,12 @@
+-- Log lines that could not be parsed, with the raw line as far as the
+-- content policy allows, so missing data can be traced and reported upstream
+CREATE TABLE IF NOT EXISTS parse_errors (
+	file_path VARCHAR NOT NULL,
+	line_number INTEGER NOT NULL,
+	error VARCHAR NOT NULL,
+	raw_content TEXT,
+	detected_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (file_path, line_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_parse_errors_detected_at ON parse_errors (detected_at);
@@ -0,0 +1,18 @@
+-- INSERT OR REPLACE leaves indexed columns unchanged in DuckDB, which kept
+-- sync_status and last_modified stale; the table is small enough to scan
+DROP INDEX IF EXISTS idx_file_sync_state_status;
+DROP INDEX IF EXISTS idx_file_sync_state_modified;
+
+-- Device and inode of each synced log file, to follow renames and notice
+-- files replaced at the same path
+ALTER TABLE file_sync_state ADD COLUMN IF NOT EXISTS file_id VARCHAR;
+
+-- The log files each session was read from
+CREATE TABLE IF NOT EXISTS session_sources (
+	session_id VARCHAR NOT NULL,
+	file_path VARCHAR NOT NULL,
+	PRIMARY KEY (session_id, file_path)
+);
+
+-- Set when every log file of the session was deleted
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS source_missing_at TIMESTAMP;
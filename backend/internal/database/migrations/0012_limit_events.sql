@@ -0,0 +1,16 @@
+-- Usage and rate limits Claude Code reported in session logs. The reset
+-- Claude announced calibrates the reset_time of the window it happened in.
+CREATE TABLE IF NOT EXISTS limit_events (
+	id VARCHAR PRIMARY KEY,
+	session_id VARCHAR NOT NULL,
+	kind VARCHAR NOT NULL,
+	timestamp TIMESTAMP NOT NULL,
+	reset_at TIMESTAMP,
+	message VARCHAR NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_limit_events_timestamp ON limit_events (timestamp);
+
+-- Calibration updates reset_time, which DuckDB cannot do to an indexed
+-- column; nothing looks windows up by it
+DROP INDEX IF EXISTS idx_session_windows_reset_time;
@@ -0,0 +1,13 @@
+-- Work queued to run with Claude Code. Not indexed: tasks are few, and
+-- DuckDB cannot update indexed columns such as status.
+CREATE TABLE IF NOT EXISTS tasks (
+	id VARCHAR PRIMARY KEY,
+	title VARCHAR NOT NULL,
+	prompt TEXT,
+	project_path VARCHAR,
+	estimated_tokens BIGINT DEFAULT 0,
+	priority VARCHAR NOT NULL,
+	status VARCHAR NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+-- The last run of a task: the Claude Code session it started and how it ended
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS session_id VARCHAR;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS started_at TIMESTAMP;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS error TEXT;
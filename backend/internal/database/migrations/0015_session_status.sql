@@ -0,0 +1,3 @@
+-- Sessions now move between active and completed as they go idle and get
+-- new messages; DuckDB cannot update an indexed column
+DROP INDEX IF EXISTS idx_sessions_status;
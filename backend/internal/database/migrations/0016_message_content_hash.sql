@@ -0,0 +1,3 @@
+-- SHA-256 of each stored log entry, so a line read again, by a re-sync or
+-- another agent, is recognized whether or not it has a uuid
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_hash VARCHAR;
@@ -0,0 +1,7 @@
+-- Secrets redacted per kind, created here rather than by the first sync so
+-- read-only instances can list them
+CREATE TABLE IF NOT EXISTS redaction_stats (
+	kind TEXT PRIMARY KEY,
+	count BIGINT NOT NULL DEFAULT 0,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,8 @@
+-- The process syncing into the database; instances sharing it take turns
+CREATE TABLE IF NOT EXISTS sync_lease (
+	id INTEGER PRIMARY KEY,
+	holder VARCHAR NOT NULL,
+	job_id VARCHAR,
+	acquired_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
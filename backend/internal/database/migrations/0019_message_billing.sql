@@ -0,0 +1,3 @@
+-- How a response is billed when its log entry says so: 'api' for
+-- pay-as-you-go usage, NULL when the configured default billing applies
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS billing VARCHAR;
@@ -0,0 +1,4 @@
+-- The tool whose logs a session or message was read from: claude, or codex
+-- for OpenAI Codex CLI sessions
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS provider VARCHAR DEFAULT 'claude';
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS provider VARCHAR DEFAULT 'claude';
@@ -0,0 +1,12 @@
+-- Session notes move out of sessions so that a forced re-sync, which
+-- rebuilds sessions from the logs, keeps them like it keeps tags. The old
+-- sessions.notes column is left in place but no longer read or written.
+CREATE TABLE IF NOT EXISTS session_notes (
+	session_id VARCHAR PRIMARY KEY,
+	notes TEXT NOT NULL,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO session_notes (session_id, notes)
+SELECT id, notes FROM sessions WHERE notes IS NOT NULL AND TRIM(notes) <> ''
+ON CONFLICT (session_id) DO NOTHING;
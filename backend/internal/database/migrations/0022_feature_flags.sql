@@ -0,0 +1,6 @@
+-- Per-installation overrides of the feature flags
+CREATE TABLE IF NOT EXISTS feature_flags (
+	name VARCHAR PRIMARY KEY,
+	enabled BOOLEAN NOT NULL,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
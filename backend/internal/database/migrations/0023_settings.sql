@@ -0,0 +1,7 @@
+-- Runtime settings changed through the API; the configuration file and
+-- environment provide the rest
+CREATE TABLE IF NOT EXISTS settings (
+	key VARCHAR PRIMARY KEY,
+	value VARCHAR NOT NULL,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,13 @@
+-- API requests, recorded when the audit log is enabled
+CREATE TABLE IF NOT EXISTS audit_log (
+	accessed_at TIMESTAMP NOT NULL,
+	user_name VARCHAR,
+	method VARCHAR NOT NULL,
+	path VARCHAR NOT NULL,
+	status INTEGER,
+	client_ip VARCHAR,
+	user_agent VARCHAR,
+	duration_ms BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_accessed_at ON audit_log (accessed_at);
@@ -0,0 +1,7 @@
+-- Fingerprint of the key that encrypts message content, so a different key
+-- is refused
+CREATE TABLE IF NOT EXISTS content_encryption (
+	id INTEGER PRIMARY KEY,
+	key_id VARCHAR NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,41 @@
+-- Assistant message totals per quarter hour (UTC) and model. Session counts
+-- are not stored since they cannot be summed across buckets.
+CREATE TABLE IF NOT EXISTS usage_buckets (
+	bucket TIMESTAMP NOT NULL,
+	model TEXT NOT NULL,
+	input_tokens BIGINT DEFAULT 0,
+	output_tokens BIGINT DEFAULT 0,
+	cache_creation_input_tokens BIGINT DEFAULT 0,
+	cache_read_input_tokens BIGINT DEFAULT 0,
+	total_tokens BIGINT DEFAULT 0,
+	message_count INTEGER DEFAULT 0,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (bucket, model)
+);
+
+CREATE TABLE IF NOT EXISTS project_usage_rollups (
+	project_name TEXT PRIMARY KEY,
+	input_tokens BIGINT DEFAULT 0,
+	output_tokens BIGINT DEFAULT 0,
+	cache_creation_input_tokens BIGINT DEFAULT 0,
+	cache_read_input_tokens BIGINT DEFAULT 0,
+	total_tokens BIGINT DEFAULT 0,
+	message_count INTEGER DEFAULT 0,
+	session_count INTEGER DEFAULT 0,
+	last_activity TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- How far the rollups have been refreshed
+CREATE TABLE IF NOT EXISTS rollup_state (
+	name TEXT PRIMARY KEY,
+	watermark TIMESTAMP NOT NULL
+);
+
+-- Rollups built before usage_buckets existed, per UTC day, are recomputed
+-- on the next refresh
+DELETE FROM rollup_state
+WHERE name = 'usage' AND NOT EXISTS (SELECT 1 FROM usage_buckets);
+
+DROP TABLE IF EXISTS daily_usage_rollups;
+DROP TABLE IF EXISTS daily_usage;
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// postgresDriver stores data in a PostgreSQL database shared by several
+// machines. The DSN is a lib/pq connection string or postgres:// URL.
+type postgresDriver struct{}
+
+func (postgresDriver) Name() string {
+	return DriverPostgres
+}
+
+func (d postgresDriver) Open(dsn string) (*sql.DB, error) {
+	return d.open(dsn, false)
+}
+
+// OpenReadOnly makes every transaction of its connections read-only
+func (d postgresDriver) OpenReadOnly(dsn string) (*sql.DB, error) {
+	return d.open(dsn, true)
+}
+
+func (postgresDriver) open(dsn string, readOnly bool) (*sql.DB, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("CLAUDEEE_DB_DSN is required for the %s driver", DriverPostgres)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database DSN: %w", err)
+	}
+
+	db := sql.OpenDB(&rebindConnector{base: connector, readOnly: readOnly})
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return db, nil
+}
+
+// pgConn is what database/sql uses of a lib/pq connection
+type pgConn interface {
+	driver.Conn
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// rebindConnector hands out connections that accept the DuckDB flavoured
+// statements the services are written with
+type rebindConnector struct {
+	base     driver.Connector
+	readOnly bool
+}
+
+func (c *rebindConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pg, ok := conn.(pgConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unsupported postgres connection type %T", conn)
+	}
+	if c.readOnly {
+		if _, err := pg.ExecContext(ctx, `SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY`, nil); err != nil {
+			pg.Close()
+			return nil, fmt.Errorf("failed to make connection read-only: %w", err)
+		}
+	}
+	return &rebindConn{pgConn: pg}, nil
+}
+
+func (c *rebindConnector) Driver() driver.Driver {
+	return c.base.Driver()
+}
+
+type rebindConn struct {
+	pgConn
+}
+
+func (c *rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.pgConn.Prepare(translate(query))
+}
+
+func (c *rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.pgConn.PrepareContext(ctx, translate(query))
+}
+
+func (c *rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.pgConn.ExecContext(ctx, translate(query), args)
+}
+
+func (c *rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.pgConn.QueryContext(ctx, translate(query), args)
+}
+
+// CheckNamedValue converts times to UTC. Columns are TIMESTAMP without time
+// zone, which keeps the wall clock of the value, and DuckDB stores UTC.
+func (c *rebindConn) CheckNamedValue(nv *driver.NamedValue) error {
+	value, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC()
+	}
+	nv.Value = value
+	return nil
+}
+
+// upsertKeys are the conflict targets of the tables written with INSERT OR
+// REPLACE; composite keys are comma separated
+var upsertKeys = map[string]string{
+	"messages":              "id",
+	"file_sync_state":       "file_path",
+	"feature_flags":         "name",
+	"settings":              "key",
+	"usage_buckets":         "bucket, model",
+	"project_usage_rollups": "project_name",
+	"rollup_state":          "name",
+	"duplicate_messages":    "message_id",
+	"parse_errors":          "file_path, line_number",
+	"session_notes":         "session_id",
+}
+
+var insertOrReplace = regexp.MustCompile(`(?is)^\s*INSERT\s+OR\s+REPLACE\s+INTO\s+(\w+)\s*\(([^)]*)\)`)
+
+// translate turns a DuckDB statement into its PostgreSQL form
+func translate(query string) string {
+	return rebind(translateUpsert(query))
+}
+
+// translateUpsert rewrites INSERT OR REPLACE, which PostgreSQL lacks, to
+// INSERT ... ON CONFLICT DO UPDATE of every listed column
+func translateUpsert(query string) string {
+	match := insertOrReplace.FindStringSubmatchIndex(query)
+	if match == nil {
+		return query
+	}
+	table := query[match[2]:match[3]]
+	key, ok := upsertKeys[table]
+	if !ok {
+		return query
+	}
+
+	keyColumns := make(map[string]bool)
+	for _, column := range strings.Split(key, ",") {
+		keyColumns[strings.TrimSpace(column)] = true
+	}
+	var updates []string
+	for _, column := range strings.Split(query[match[4]:match[5]], ",") {
+		if column = strings.TrimSpace(column); !keyColumns[column] {
+			updates = append(updates, column+" = excluded."+column)
+		}
+	}
+
+	statement := "INSERT INTO " + table + query[match[3]:]
+	statement = strings.TrimRight(statement, "; \t\r\n")
+	return statement + " ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+// rebind rewrites ? placeholders to the $1, $2, ... form PostgreSQL expects.
+// Question marks inside quoted strings and identifiers are left alone.
+func rebind(query string) string {
+	if !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claudeee-backend/internal/backup"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/offboard"
+	"claudeee-backend/internal/openapi"
+	"claudeee-backend/internal/services"
+)
+
+// Query parameters shared by several routes
+var (
+	userParam  = openapi.Param{Name: "user", Description: "Only this user's data; ignored for requests scoped to a user"}
+	sinceParam = openapi.Param{Name: "since", Description: "Start of the range (RFC3339 or YYYY-MM-DD)"}
+	untilParam = openapi.Param{Name: "until", Description: "End of the range, exclusive (RFC3339, or YYYY-MM-DD for the whole day)"}
+	fromParam  = openapi.Param{Name: "from", Description: "Start of the range (RFC3339 or YYYY-MM-DD)"}
+	toParam    = openapi.Param{Name: "to", Description: "End of the range, exclusive (RFC3339, or YYYY-MM-DD for the whole day)"}
+	limitParam = openapi.Param{Name: "limit", Type: "integer", Description: "Maximum number of results"}
+	planParam  = openapi.Param{Name: "plan", Description: "Answer for this plan instead of the configured one"}
+	tzParam    = openapi.Param{Name: "tz", Description: "IANA time zone, e.g. Europe/Berlin, instead of the configured timezone"}
+)
+
+// APIRoutes documents the API for the OpenAPI document. Add a route here when
+// registering one; the server logs routes missing from this table.
+func APIRoutes() []openapi.Route {
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/health", Tag: "system", Summary: "Report that the server is running", Public: true,
+			Response: openapi.Object{"status": "", "message": "", "pid": 0, "read_only": false, "read_only_database": false}},
+		{Method: http.MethodGet, Path: "/openapi.json", Tag: "system", Summary: "This OpenAPI document", Response: openapi.Object{}},
+		{Method: http.MethodGet, Path: "/docs", Tag: "system", Summary: "Interactive API documentation", ContentType: "text/html"},
+
+		{Method: http.MethodGet, Path: "/auth/status", Tag: "auth", Summary: "Report the auth mode and the signed-in user", Public: true,
+			Response: openapi.Object{"mode": "", "authenticated": false, "user": "", "role": ""}},
+		{Method: http.MethodPost, Path: "/auth/login", Tag: "auth", Summary: "Sign in and set the session cookie", Public: true,
+			Body: loginRequest{}, Response: openapi.Object{"user": ""}},
+		{Method: http.MethodPost, Path: "/auth/logout", Tag: "auth", Summary: "Clear the session cookie", Public: true,
+			Response: openapi.Object{"message": ""}},
+		{Method: http.MethodGet, Path: "/auth/oidc/login", Tag: "auth", Summary: "Redirect to the OIDC provider", Public: true, Status: http.StatusFound},
+		{Method: http.MethodGet, Path: "/auth/oidc/callback", Tag: "auth", Summary: "Complete an OIDC login", Public: true, Status: http.StatusFound},
+
+		{Method: http.MethodPost, Path: "/ingest", Tag: "ingest", Summary: "Store log entries pushed by an agent",
+			Description: "Authenticated with an ingest token as `Authorization: Bearer <token>` instead of a login.", Public: true,
+			Body: services.IngestRequest{}, Response: services.IngestResult{}},
+
+		{Method: http.MethodGet, Path: "/token-usage", Tag: "usage", Summary: "Token usage of the current session window",
+			Query: []openapi.Param{tzParam}, Response: models.TokenUsage{}},
+		{Method: http.MethodGet, Path: "/sessions", Tag: "sessions", Summary: "List sessions",
+			Query: []openapi.Param{
+				{Name: "limit", Type: "integer", Description: "Page size, 1 to 500 (default 50)"},
+				{Name: "offset", Type: "integer"},
+				{Name: "sort", Description: "start_time, end_time, total_tokens, message_count or project_name; prefix - for descending (default -start_time)"},
+				{Name: "project"}, {Name: "model"}, {Name: "status"},
+				{Name: "provider", Description: "claude, codex or gemini"},
+				{Name: "tag", Description: "Only sessions with every tag; repeat or separate with commas"},
+				fromParam, toParam, userParam,
+			},
+			Response: openapi.Object{"sessions": []models.SessionSummary{}, "count": 0, "total": 0, "limit": 0, "offset": 0, "has_more": false}},
+		{Method: http.MethodGet, Path: "/sessions/:id", Tag: "sessions", Summary: "A session with its messages and token usage",
+			Description: "The session's total_cost is at API prices. With page or page_size, messages is a page of messages instead of all of them.",
+			Query:       []openapi.Param{{Name: "page", Type: "integer"}, {Name: "page_size", Type: "integer"}},
+			Response:    openapi.Object{"session": models.SessionSummary{}, "messages": services.PaginatedMessagesResult{}, "token_usage": models.TokenUsage{}}},
+		{Method: http.MethodPatch, Path: "/sessions/:id", Tag: "sessions", Summary: "Replace a session's tags or notes",
+			Body: sessionUpdateRequest{}, Response: models.SessionSummary{}},
+		{Method: http.MethodGet, Path: "/sessions/:id/activity", Tag: "sessions", Summary: "Activity analysis of a session with its timeline of bursts, idle gaps and hourly usage",
+			Query:    []openapi.Param{{Name: "gap_minutes", Type: "integer", Description: "Pause that ends a burst, 1 to 1440 (default 5)"}, tzParam},
+			Response: map[string]interface{}{}},
+		{Method: http.MethodGet, Path: "/sessions/:id/messages", Tag: "sessions", Summary: "A page of a session's threaded transcript",
+			Query:    []openapi.Param{{Name: "page", Type: "integer"}, {Name: "page_size", Type: "integer"}},
+			Response: services.Transcript{}},
+		{Method: http.MethodPost, Path: "/sessions/:id/tags", Tag: "sessions", Summary: "Add tags to a session",
+			Body: sessionTagsRequest{}, Response: models.SessionSummary{}},
+		{Method: http.MethodDelete, Path: "/sessions/:id/tags/:tag", Tag: "sessions", Summary: "Remove a tag from a session", Response: models.SessionSummary{}},
+		{Method: http.MethodGet, Path: "/tags", Tag: "sessions", Summary: "Tags in use with the tokens and cost of their sessions",
+			Query:    []openapi.Param{sinceParam, untilParam, userParam},
+			Response: openapi.Object{"tags": []services.TagSummary{}, "count": 0}},
+		{Method: http.MethodGet, Path: "/claude/sessions/recent", Tag: "sessions", Summary: "All sessions",
+			Query:    []openapi.Param{{Name: "hours", Description: "Echoed back; does not filter"}, userParam},
+			Response: openapi.Object{"sessions": []models.SessionSummary{}, "hours": ""}},
+
+		{Method: http.MethodGet, Path: "/messages", Tag: "messages", Summary: "A page of messages; pass next_cursor back as cursor",
+			Query:    []openapi.Param{{Name: "session_id"}, sinceParam, untilParam, {Name: "cursor"}, limitParam, userParam},
+			Response: services.MessagePage{}},
+		{Method: http.MethodGet, Path: "/messages/export", Tag: "messages", Summary: "Stream every matching message as a JSON array or NDJSON",
+			Query:       []openapi.Param{{Name: "session_id"}, sinceParam, untilParam, userParam, {Name: "format", Description: "ndjson for one message per line"}},
+			ContentType: "application/json"},
+		{Method: http.MethodGet, Path: "/search", Tag: "messages", Summary: "Find sessions and messages containing every word of q",
+			Query:    []openapi.Param{{Name: "q", Required: true}, {Name: "project"}, limitParam, userParam},
+			Response: services.SearchResults{}},
+		{Method: http.MethodGet, Path: "/export/sessions", Tag: "export", Summary: "Export each session's usage",
+			Query:       []openapi.Param{{Name: "format", Description: "csv (default), json or jsonl"}, {Name: "project"}, fromParam, toParam, userParam},
+			ContentType: "text/csv"},
+		{Method: http.MethodGet, Path: "/export/messages", Tag: "export", Summary: "Export every message with its cost",
+			Query:       []openapi.Param{{Name: "format", Description: "csv (default), json or jsonl"}, {Name: "project"}, fromParam, toParam, userParam, {Name: "content", Type: "boolean", Description: "Include message content (admins only)"}},
+			ContentType: "text/csv"},
+
+		{Method: http.MethodGet, Path: "/claude/available-tokens", Tag: "usage", Summary: "Tokens left in the current window",
+			Query:    []openapi.Param{planParam},
+			Response: openapi.Object{"available_tokens": 0, "plan": "", "usage_limit": 0, "used_tokens": 0, "forecast": services.Forecast{}}},
+		{Method: http.MethodGet, Path: "/plan/utilization", Tag: "usage", Summary: "Share of the plan limit used in the current window",
+			Query: []openapi.Param{tzParam}, Response: services.PlanUtilization{}},
+		{Method: http.MethodGet, Path: "/forecast", Tag: "usage", Summary: "When the plan limit is reached at the current burn rate",
+			Query:    []openapi.Param{planParam},
+			Response: openapi.Object{"plan": "", "forecast": services.Forecast{}}},
+		{Method: http.MethodGet, Path: "/costs/current-month", Tag: "costs", Summary: "This month's spend, optionally split into subscription and API usage",
+			Query:    []openapi.Param{{Name: "breakdown", Description: "billing splits the cost into subscription and API usage"}, userParam, tzParam},
+			Response: services.MonthCosts{}},
+		{Method: http.MethodGet, Path: "/statusline", Tag: "usage", Summary: "One-line summary of window utilization, reset time and today's cost",
+			Description: "Plain text such as \"⚡ 62% | resets 14:00 | $3.20 today\" for tmux status bars, shell prompts and menu-bar apps; ?format=json returns the parts. Today's cost is cached until the next sync.",
+			Query:       []openapi.Param{{Name: "format", Description: "text (default) or json"}, userParam, tzParam},
+			Response:    services.Statusline{}, ContentType: "text/plain"},
+		{Method: http.MethodGet, Path: "/report", Tag: "usage", Summary: "Usage report in ccusage's daily, monthly or blocks JSON",
+			Description: "Output shaped like ccusage's --json output, for scripts and status lines written for ccusage. Only Claude Code usage is counted. Without from and to the report covers all time.",
+			Query: []openapi.Param{{Name: "format", Description: "ccusage (default)"}, {Name: "period", Description: "daily (default), monthly or blocks"},
+				{Name: "active", Type: "boolean", Description: "Only the active block of a blocks report"}, fromParam, toParam, userParam, tzParam},
+			Response: services.CCUsageDailyReport{}},
+		{Method: http.MethodGet, Path: "/costs/forecast", Tag: "costs", Summary: "This month's projected spend",
+			Query: []openapi.Param{userParam, tzParam}, Response: services.CostForecast{}},
+		{Method: http.MethodGet, Path: "/stats/percentiles", Tag: "usage", Summary: "p50, p90 and p99 tokens per window and per session over lookback periods",
+			Query: []openapi.Param{{Name: "days", Type: "integer", Description: "Lookback in days, repeated or comma separated (default 7, 30 and 90)"}, userParam}, Response: services.PercentileReport{}},
+		{Method: http.MethodGet, Path: "/tasks", Tag: "tasks", Summary: "Tasks by priority",
+			Query:    []openapi.Param{{Name: "status", Description: "queued, running, completed, failed or cancelled"}},
+			Response: openapi.Object{"tasks": []services.Task{}, "count": 0}},
+		{Method: http.MethodGet, Path: "/tasks/schedule", Tag: "tasks", Summary: "Queued tasks that fit in the current window and those that wait for a reset",
+			Response: services.TaskSchedule{}},
+		{Method: http.MethodGet, Path: "/tasks/:id", Tag: "tasks", Summary: "A task", Response: services.Task{}},
+		{Method: http.MethodPost, Path: "/tasks", Tag: "tasks", Summary: "Queue a task", Admin: true,
+			Body: taskRequest{}, Response: services.Task{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/tasks/:id", Tag: "tasks", Summary: "Replace a task", Admin: true,
+			Body: taskRequest{}, Response: services.Task{}},
+		{Method: http.MethodDelete, Path: "/tasks/:id", Tag: "tasks", Summary: "Delete a task", Admin: true,
+			Response: openapi.Object{"message": "", "id": ""}},
+		{Method: http.MethodPost, Path: "/tasks/:id/run", Tag: "tasks", Summary: "Run a queued task with Claude Code in its project directory", Admin: true,
+			Response: services.Task{}, Status: http.StatusAccepted},
+		{Method: http.MethodPost, Path: "/tasks/:id/cancel", Tag: "tasks", Summary: "Stop a running task", Admin: true,
+			Response: openapi.Object{"message": "", "id": ""}},
+		{Method: http.MethodGet, Path: "/session-windows", Tag: "usage", Summary: "Recent 5-hour session windows",
+			Query:    []openapi.Param{{Name: "limit", Type: "integer", Description: "At most 100 (default 50)"}, tzParam},
+			Response: openapi.Object{"windows": []services.SessionWindow{}, "count": 0}},
+		{Method: http.MethodGet, Path: "/limit-events", Tag: "usage", Summary: "Usage and rate limits hit, newest first, and when a usage limit was last reached",
+			Query: []openapi.Param{{Name: "limit", Type: "integer", Description: "At most 1000 (default 50)"}}, Response: services.LimitEventReport{}},
+		{Method: http.MethodGet, Path: "/usage/daily", Tag: "usage", Summary: "Daily totals and day, week or month rollups",
+			Query:    []openapi.Param{{Name: "days", Type: "integer", Description: "Days back when from is missing (default 30)"}, fromParam, toParam, {Name: "granularity", Description: "day (default), week or month"}, tzParam},
+			Response: openapi.Object{"days": []services.DailyUsage{}, "count": 0, "granularity": "", "timezone": "", "from": "", "to": "", "periods": []services.UsagePeriod{}}},
+		{Method: http.MethodGet, Path: "/usage/heatmap", Tag: "usage", Summary: "Tokens, messages and cost by weekday and hour of the day",
+			Query:    []openapi.Param{{Name: "days", Type: "integer", Description: "Days back when from is missing (default 30)"}, fromParam, toParam, tzParam},
+			Response: services.UsageHeatmap{}},
+		{Method: http.MethodGet, Path: "/usage/projects", Tag: "usage", Summary: "Totals per project from the rollups",
+			Response: openapi.Object{"projects": []services.ProjectUsage{}, "count": 0}},
+		{Method: http.MethodGet, Path: "/usage/by-model", Tag: "usage", Summary: "Tokens, cost and cache hit ratios per model, family and tool",
+			Query: []openapi.Param{{Name: "project"}, fromParam, toParam, userParam}, Response: services.ModelUsageReport{}},
+		{Method: http.MethodGet, Path: "/tool-usage", Tag: "usage", Summary: "Calls and cost per tool",
+			Query:    []openapi.Param{sinceParam, untilParam, userParam},
+			Response: openapi.Object{"tools": []services.ToolUsage{}, "total_calls": 0, "total_cost": 0.0}},
+		{Method: http.MethodGet, Path: "/grafana/", Tag: "grafana", Summary: "Connection test of the Grafana JSON datasource",
+			Response: openapi.Object{"status": ""}},
+		{Method: http.MethodPost, Path: "/grafana/search", Tag: "grafana", Summary: "Targets the Grafana JSON datasource can query",
+			Description: "Targets are a metric (tokens, input_tokens, output_tokens, cache_creation_input_tokens, cache_read_input_tokens, cost or messages), optionally split with \" by model\" or \" by project\".",
+			Body:        grafanaSearchRequest{}, Response: []string{}},
+		{Method: http.MethodPost, Path: "/grafana/query", Tag: "grafana", Summary: "Time series of the queried targets",
+			Description: "Buckets are multiples of 15 minutes; buckets without usage are 0.",
+			Body:        GrafanaQueryRequest{}, Response: []services.GrafanaSeries{}},
+		{Method: http.MethodPost, Path: "/grafana/annotations", Tag: "grafana", Summary: "Limit events or usage windows as Grafana annotations",
+			Description: "The annotation query is \"limits\" (default) or \"windows\".",
+			Body:        GrafanaAnnotationRequest{}, Response: []grafanaAnnotationResult{}},
+		{Method: http.MethodGet, Path: "/users", Tag: "usage", Summary: "Users with their totals",
+			Response: openapi.Object{"users": []services.UserSummary{}, "count": 0}},
+		{Method: http.MethodGet, Path: "/projects", Tag: "usage", Summary: "Projects with their totals",
+			Query:    []openapi.Param{sinceParam, untilParam, userParam},
+			Response: openapi.Object{"projects": []services.ProjectSummary{}, "count": 0}},
+		{Method: http.MethodGet, Path: "/projects/:name/usage", Tag: "usage", Summary: "One project's totals and model mix",
+			Query: []openapi.Param{sinceParam, untilParam, userParam}, Response: services.ProjectUsageDetail{}},
+
+		{Method: http.MethodGet, Path: "/budgets", Tag: "budgets", Summary: "Budgets with their spending in the current period",
+			Response: openapi.Object{"budgets": []services.BudgetStatus{}, "count": 0}},
+		{Method: http.MethodGet, Path: "/budgets/:id", Tag: "budgets", Summary: "A budget with its spending in the current period", Response: services.BudgetStatus{}},
+		{Method: http.MethodPost, Path: "/budgets", Tag: "budgets", Summary: "Add a budget", Admin: true,
+			Body: budgetRequest{}, Response: services.Budget{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/budgets/:id", Tag: "budgets", Summary: "Replace a budget", Admin: true,
+			Body: budgetRequest{}, Response: services.Budget{}},
+		{Method: http.MethodDelete, Path: "/budgets/:id", Tag: "budgets", Summary: "Delete a budget, keeping its alerts", Admin: true,
+			Response: openapi.Object{"message": "", "id": ""}},
+		{Method: http.MethodGet, Path: "/alerts", Tag: "budgets", Summary: "Budget alerts, newest first",
+			Query:    []openapi.Param{{Name: "budget_id"}, {Name: "since", Description: "RFC3339 time"}, limitParam},
+			Response: openapi.Object{"alerts": []services.BudgetAlert{}, "count": 0}},
+
+		{Method: http.MethodGet, Path: "/webhooks", Tag: "webhooks", Summary: "Webhooks without their secrets", Admin: true,
+			Response: openapi.Object{"webhooks": []services.Webhook{}, "count": 0, "event_types": []string{}}},
+		{Method: http.MethodPost, Path: "/webhooks", Tag: "webhooks", Summary: "Add a webhook; the only response with its secret", Admin: true,
+			Body: webhookRequest{}, Response: services.Webhook{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/webhooks/:id", Tag: "webhooks", Summary: "Replace a webhook; an empty secret keeps the current one", Admin: true,
+			Body: webhookRequest{}, Response: services.Webhook{}},
+		{Method: http.MethodDelete, Path: "/webhooks/:id", Tag: "webhooks", Summary: "Delete a webhook with its deliveries", Admin: true,
+			Response: openapi.Object{"message": "", "id": ""}},
+		{Method: http.MethodGet, Path: "/webhooks/:id/deliveries", Tag: "webhooks", Summary: "A webhook's recent deliveries", Admin: true,
+			Query: []openapi.Param{limitParam}, Response: openapi.Object{"deliveries": []services.WebhookDelivery{}, "count": 0}},
+
+		{Method: http.MethodPost, Path: "/sync-logs", Tag: "sync", Summary: "Queue a log sync",
+			Description: "Returns 202 with the queued job; with wait=true, 200 once the sync has finished. " +
+				"A sync requested while one runs is queued behind it; while another instance sharing the database syncs, 409 with its active_job_id.",
+			Query:    []openapi.Param{{Name: "wait", Type: "boolean"}},
+			Response: openapi.Object{"message": "", "job": services.SyncJob{}, "stats": models.SyncStats{}}, Status: http.StatusAccepted},
+		{Method: http.MethodGet, Path: "/sync-jobs", Tag: "sync", Summary: "Recent sync jobs, newest first",
+			Response: openapi.Object{"jobs": []services.SyncJob{}, "count": 0}},
+		{Method: http.MethodGet, Path: "/sync-jobs/:id", Tag: "sync", Summary: "A sync job", Response: services.SyncJob{}},
+		{Method: http.MethodGet, Path: "/sync/errors", Tag: "sync", Summary: "Log lines sync could not parse, most recently found first",
+			Description: "raw_content is only returned to admins and follows the content policy.",
+			Query:       []openapi.Param{{Name: "file", Description: "Part of the log file path"}, {Name: "limit", Type: "integer", Description: "At most 1000 (default 100)"}, {Name: "offset", Type: "integer"}},
+			Response:    openapi.Object{"errors": []services.ParseError{}, "count": 0, "total": 0}},
+		{Method: http.MethodGet, Path: "/sync-logs/stream", Tag: "sync", Summary: "Sync progress as server-sent events", ContentType: "text/event-stream"},
+		{Method: http.MethodGet, Path: "/ws", Tag: "sync", Summary: "Dashboard updates over WebSocket",
+			Description: "Sends a LiveUpdate on connect and after every sync.", Status: http.StatusSwitchingProtocols, Response: LiveUpdate{}},
+		{Method: http.MethodGet, Path: "/watcher", Tag: "sync", Summary: "Log watcher status", Response: services.WatcherStatus{}},
+		{Method: http.MethodPost, Path: "/watcher/start", Tag: "sync", Summary: "Start watching the logs", Admin: true, Response: services.WatcherStatus{}},
+		{Method: http.MethodPost, Path: "/watcher/stop", Tag: "sync", Summary: "Stop watching the logs", Admin: true, Response: services.WatcherStatus{}},
+		{Method: http.MethodGet, Path: "/scheduler", Tag: "sync", Summary: "Sync scheduler status", Response: services.SchedulerStatus{}},
+		{Method: http.MethodPost, Path: "/scheduler/start", Tag: "sync", Summary: "Resume scheduled syncs", Admin: true, Response: services.SchedulerStatus{}},
+		{Method: http.MethodPost, Path: "/scheduler/stop", Tag: "sync", Summary: "Pause scheduled syncs", Admin: true, Response: services.SchedulerStatus{}},
+
+		{Method: http.MethodGet, Path: "/config", Tag: "config", Summary: "Runtime settings", Response: services.RuntimeSettings{}},
+		{Method: http.MethodPatch, Path: "/config", Tag: "config", Summary: "Change runtime settings", Admin: true,
+			Body: services.SettingsUpdate{}, Response: services.RuntimeSettings{}},
+
+		{Method: http.MethodGet, Path: "/admin/features", Tag: "admin", Summary: "Feature flags", Admin: true,
+			Response: openapi.Object{"features": []services.FeatureFlag{}, "count": 0}},
+		{Method: http.MethodPut, Path: "/admin/features/:name", Tag: "admin", Summary: "Enable or disable a feature; null removes the override", Admin: true,
+			Body: updateFeatureRequest{}, Response: openapi.Object{"name": "", "enabled": false}},
+		{Method: http.MethodPost, Path: "/admin/content/strip", Tag: "admin", Summary: "Apply the content policy, including compression, to stored messages", Admin: true,
+			Response: openapi.Object{"policy": services.ContentPolicy{}, "updated_messages": int64(0)}},
+		{Method: http.MethodPost, Path: "/admin/content/backfill", Tag: "admin", Summary: "Restore content from the logs up to the content policy", Admin: true,
+			Response: openapi.Object{"policy": services.ContentPolicy{}, "updated_messages": int64(0)}},
+		{Method: http.MethodPost, Path: "/admin/redact", Tag: "admin", Summary: "Apply the content policy and secret redaction to stored messages", Admin: true,
+			Response: services.StoredRedaction{}},
+		{Method: http.MethodPost, Path: "/admin/prune", Tag: "admin", Summary: "Clear message content past the retention period", Admin: true,
+			Query: []openapi.Param{{Name: "days", Type: "integer", Description: "Retention in days instead of content_retention_days"}}, Response: services.PruneResult{}},
+		{Method: http.MethodPost, Path: "/admin/tool-usage/backfill", Tag: "admin", Summary: "Record tool calls of messages synced before tool tracking", Admin: true,
+			Response: openapi.Object{"added_calls": 0}},
+		{Method: http.MethodPost, Path: "/admin/recompute", Tag: "admin", Summary: "Rebuild session and window totals from their messages and report the discrepancies", Admin: true,
+			Query: []openapi.Param{{Name: "dry_run", Type: "boolean", Description: "Only report the discrepancies"}}, Response: services.AggregateReport{}},
+		{Method: http.MethodGet, Path: "/admin/redactions", Tag: "admin", Summary: "Secrets redacted at ingest by kind", Admin: true,
+			Response: openapi.Object{"enabled": false, "counts": []services.RedactionCount{}, "total": int64(0)}},
+		{Method: http.MethodGet, Path: "/admin/duplicates", Tag: "admin", Summary: "Log entries whose repeated usage was not counted, newest first", Admin: true,
+			Query:    []openapi.Param{{Name: "limit", Type: "integer", Description: "At most 1000 (default 50)"}},
+			Response: services.DuplicateReport{}},
+		{Method: http.MethodPost, Path: "/admin/rollups/rebuild", Tag: "admin", Summary: "Recompute all usage rollups", Admin: true,
+			Response: openapi.Object{"message": ""}},
+		{Method: http.MethodGet, Path: "/admin/audit", Tag: "admin", Summary: "Audit log, newest first", Admin: true,
+			Query:    []openapi.Param{{Name: "user"}, {Name: "path", Description: "Path prefix"}, {Name: "since", Description: "RFC3339 time"}, limitParam, {Name: "offset", Type: "integer"}},
+			Response: openapi.Object{"enabled": false, "entries": []services.AuditEntry{}, "count": 0, "total": 0, "dropped": int64(0)}},
+		{Method: http.MethodGet, Path: "/admin/indexes", Tag: "admin", Summary: "Missing and unused indexes of the hot queries", Admin: true, Response: services.IndexReport{}},
+		{Method: http.MethodPost, Path: "/admin/indexes/apply", Tag: "admin", Summary: "Create the given or all recommended indexes", Admin: true,
+			Body: applyIndexesRequest{}, Response: openapi.Object{"created": []string{}, "count": 0}},
+		{Method: http.MethodPost, Path: "/admin/export-and-wipe", Tag: "admin", Summary: "Archive all data, then delete it and stop the server", Admin: true,
+			Body: exportAndWipeRequest{}, Response: openapi.Object{"message": "", "archive_path": "", "manifest": offboard.Manifest{}}},
+		{Method: http.MethodPost, Path: "/admin/backup", Tag: "admin", Summary: "Back up the database", Admin: true,
+			Response: openapi.Object{"backup": backup.Backup{}, "warning": ""}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/admin/backups", Tag: "admin", Summary: "Backups, newest first", Admin: true,
+			Response: openapi.Object{"directory": "", "backups": []backup.Backup{}, "count": 0}},
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/apiversion"
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler records API access and serves the audit log. logger is nil
+// when audit logging is disabled.
+type AuditHandler struct {
+	audit  *services.AuditService
+	logger *services.AuditLogger
+}
+
+func NewAuditHandler(audit *services.AuditService, logger *services.AuditLogger) *AuditHandler {
+	return &AuditHandler{audit: audit, logger: logger}
+}
+
+// Middleware records every /api request after it completes. It must run
+// before the auth middleware so rejected requests are recorded as well.
+func (h *AuditHandler) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		path := c.Request.URL.Path
+		if c.Request.Method == http.MethodOptions || path == apiversion.Path("/health") || !strings.HasPrefix(path, apiversion.Path("/")) {
+			return
+		}
+		h.logger.Record(services.AuditEntry{
+			AccessedAt: start.UTC(),
+			User:       c.GetString(auth.UserKey),
+			Method:     c.Request.Method,
+			Path:       path,
+			Status:     c.Writer.Status(),
+			ClientIP:   c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			DurationMs: time.Since(start).Milliseconds(),
+		})
+	}
+}
+
+// GetAuditLog returns audit entries, newest first. Supports ?user=, ?path=
+// (prefix), ?since= (RFC3339), ?limit= and ?offset=.
+func (h *AuditHandler) GetAuditLog(c *gin.Context) {
+	q := services.AuditQuery{
+		User:       c.Query("user"),
+		PathPrefix: c.Query("path"),
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid since",
+				"details": err.Error(),
+			})
+			return
+		}
+		q.Since = &t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > services.MaxAuditPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and 1000",
+			})
+			return
+		}
+		q.Limit = n
+	}
+	if offset := c.Query("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "offset must be a non-negative integer",
+			})
+			return
+		}
+		q.Offset = n
+	}
+
+	entries, total, err := h.audit.Query(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get audit log",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var dropped int64
+	if h.logger != nil {
+		dropped = h.logger.Dropped()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.logger != nil,
+		"entries": entries,
+		"count":   len(entries),
+		"total":   total,
+		"dropped": dropped,
+	})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// AuthHandler serves login and logout for the optional authentication
+type AuthHandler struct {
+	auth        *auth.Authenticator
+	frontendURL string
+}
+
+func NewAuthHandler(authenticator *auth.Authenticator, frontendURL string) *AuthHandler {
+	return &AuthHandler{auth: authenticator, frontendURL: frontendURL}
+}
+
+// GetStatus reports the auth mode and whether the caller is logged in
+func (h *AuthHandler) GetStatus(c *gin.Context) {
+	user, authenticated := h.auth.User(c.Request)
+	if !h.auth.Enabled() {
+		authenticated = true
+	}
+
+	role := ""
+	if authenticated {
+		role = h.auth.Role(user)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mode":          h.auth.Mode(),
+		"authenticated": authenticated,
+		"user":          user,
+		"role":          role,
+	})
+}
+
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Login checks static credentials and sets the session cookie
+func (h *AuthHandler) Login(c *gin.Context) {
+	if h.auth.Mode() != auth.ModeBasic {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Password login is not enabled",
+		})
+		return
+	}
+
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Attribute the attempt in the audit log, whether or not it succeeds
+	c.Set(auth.UserKey, req.Username)
+	if err := h.auth.CheckPassword(req.Username, req.Password); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid username or password",
+		})
+		return
+	}
+
+	http.SetCookie(c.Writer, h.auth.SessionCookie(req.Username))
+	c.JSON(http.StatusOK, gin.H{
+		"user": req.Username,
+	})
+}
+
+// BeginOIDCLogin redirects the browser to the OIDC provider
+func (h *AuthHandler) BeginOIDCLogin(c *gin.Context) {
+	url, cookie, err := h.auth.BeginLogin()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "OIDC login is not available",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	http.SetCookie(c.Writer, cookie)
+	c.Redirect(http.StatusFound, url)
+}
+
+// OIDCCallback completes the provider login and returns to the dashboard
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	http.SetCookie(c.Writer, h.auth.ClearStateCookie())
+
+	user, err := h.auth.FinishLogin(c.Request.Context(), c.Request)
+	if err != nil {
+		logging.Component("auth").Warn("OIDC login failed", "err", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, auth.ErrLoginDenied) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
+			"error":   "Login failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Set(auth.UserKey, user)
+	http.SetCookie(c.Writer, h.auth.SessionCookie(user))
+	c.Redirect(http.StatusFound, h.frontendURL)
+}
+
+// Logout clears the session cookie
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if user, ok := h.auth.User(c.Request); ok {
+		c.Set(auth.UserKey, user)
+	}
+	http.SetCookie(c.Writer, h.auth.ClearSessionCookie())
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out",
+	})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"claudeee-backend/internal/backup"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// BackupHandler writes database backups to the configured backup directory
+type BackupHandler struct {
+	db     *sql.DB
+	writes *services.WriteQueue
+	store  *backup.Store
+}
+
+func NewBackupHandler(db *sql.DB, writes *services.WriteQueue, store *backup.Store) *BackupHandler {
+	return &BackupHandler{db: db, writes: writes, store: store}
+}
+
+// Create backs up the database through the write queue, so no sync writes
+// while the dump runs, and deletes the backups beyond backup_keep
+func (h *BackupHandler) Create(c *gin.Context) {
+	var created *backup.Backup
+	err := h.writes.Do(func() error {
+		var err error
+		created, err = h.store.Create(h.db, time.Now())
+		return err
+	})
+	if err != nil && created == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to back up database",
+			"details": err.Error(),
+		})
+		return
+	}
+	// The backup exists even when deleting old ones failed
+	response := gin.H{"backup": created}
+	if err != nil {
+		response["warning"] = err.Error()
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// List returns the backups in the backup directory, newest first
+func (h *BackupHandler) List(c *gin.Context) {
+	backups, err := h.store.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list backups",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"directory": h.store.Dir,
+		"backups":   backups,
+		"count":     len(backups),
+	})
+}
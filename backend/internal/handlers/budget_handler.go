@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// BudgetHandler serves budgets and the alerts they raise
+type BudgetHandler struct {
+	budgets *services.BudgetService
+	writes  *services.WriteQueue
+}
+
+func NewBudgetHandler(budgets *services.BudgetService, writes *services.WriteQueue) *BudgetHandler {
+	return &BudgetHandler{budgets: budgets, writes: writes}
+}
+
+type budgetRequest struct {
+	Name       string    `json:"name"`
+	Project    string    `json:"project"`
+	Period     string    `json:"period"`
+	Metric     string    `json:"metric"`
+	Amount     float64   `json:"amount"`
+	Thresholds []float64 `json:"thresholds"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+func (r budgetRequest) budget() services.Budget {
+	b := services.Budget{
+		Name:       r.Name,
+		Project:    r.Project,
+		Period:     r.Period,
+		Metric:     r.Metric,
+		Amount:     r.Amount,
+		Thresholds: r.Thresholds,
+		Enabled:    true,
+	}
+	if r.Enabled != nil {
+		b.Enabled = *r.Enabled
+	}
+	return b
+}
+
+// budgetError responds to a failed budget operation
+func budgetError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrInvalidBudget) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, services.ErrBudgetNotFound) {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+// GetBudgets lists every budget with its spending in the current period
+func (h *BudgetHandler) GetBudgets(c *gin.Context) {
+	statuses, err := h.budgets.Statuses(time.Now())
+	if err != nil {
+		budgetError(c, "Failed to get budgets", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"budgets": statuses,
+		"count":   len(statuses),
+	})
+}
+
+// GetBudget returns one budget with its spending in the current period
+func (h *BudgetHandler) GetBudget(c *gin.Context) {
+	b, err := h.budgets.Get(c.Param("id"))
+	if err != nil {
+		budgetError(c, "Failed to get budget", err)
+		return
+	}
+	status, err := h.budgets.Status(*b, time.Now())
+	if err != nil {
+		budgetError(c, "Failed to get budget", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// CreateBudget adds a budget
+func (h *BudgetHandler) CreateBudget(c *gin.Context) {
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var created *services.Budget
+	err := h.writes.Do(func() error {
+		var err error
+		created, err = h.budgets.Create(req.budget())
+		return err
+	})
+	if err != nil {
+		budgetError(c, "Failed to create budget", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateBudget replaces a budget. Omitted fields take their defaults.
+func (h *BudgetHandler) UpdateBudget(c *gin.Context) {
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var updated *services.Budget
+	err := h.writes.Do(func() error {
+		var err error
+		updated, err = h.budgets.Update(c.Param("id"), req.budget())
+		return err
+	})
+	if err != nil {
+		budgetError(c, "Failed to update budget", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteBudget removes a budget, keeping its alerts
+func (h *BudgetHandler) DeleteBudget(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.writes.Do(func() error { return h.budgets.Delete(id) }); err != nil {
+		budgetError(c, "Failed to delete budget", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Budget deleted",
+		"id":      id,
+	})
+}
+
+// GetAlerts returns recorded budget alerts, newest first. Supports
+// ?budget_id=, ?since= (RFC3339) and ?limit=.
+func (h *BudgetHandler) GetAlerts(c *gin.Context) {
+	q := services.AlertQuery{BudgetID: c.Query("budget_id")}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid since",
+				"details": "since must be an RFC3339 timestamp",
+			})
+			return
+		}
+		q.Since = t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > services.MaxAlertLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid limit",
+				"details": "limit must be between 1 and " + strconv.Itoa(services.MaxAlertLimit),
+			})
+			return
+		}
+		q.Limit = n
+	}
+
+	alerts, err := h.budgets.Alerts(q)
+	if err != nil {
+		budgetError(c, "Failed to get alerts", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": alerts,
+		"count":  len(alerts),
+	})
+}
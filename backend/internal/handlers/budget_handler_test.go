@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func TestBudgetHandler(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	writes := services.NewWriteQueue(1)
+	defer writes.Close()
+	h := NewBudgetHandler(services.NewBudgetService(db), writes)
+	r := gin.New()
+	r.GET("/api/budgets", h.GetBudgets)
+	r.POST("/api/budgets", h.CreateBudget)
+	r.PUT("/api/budgets/:id", h.UpdateBudget)
+	r.GET("/api/alerts", h.GetAlerts)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := send(http.MethodPost, "/api/budgets", `{"name": "team", "period": "yearly", "metric": "cost", "amount": 10}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown period, got %d", w.Code)
+	}
+	w := send(http.MethodPost, "/api/budgets", `{"name": "team", "period": "monthly", "metric": "cost", "amount": 10}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created services.Budget
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || !created.Enabled || len(created.Thresholds) != 2 {
+		t.Errorf("Expected an enabled budget with default thresholds, got %+v (%v)", created, err)
+	}
+	if w := send(http.MethodPut, "/api/budgets/missing", `{"name": "team", "period": "monthly", "metric": "cost", "amount": 10}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown budget, got %d", w.Code)
+	}
+
+	w = send(http.MethodGet, "/api/budgets", "")
+	var list struct {
+		Budgets []services.BudgetStatus `json:"budgets"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Budgets) != 1 || list.Budgets[0].PeriodStart == nil {
+		t.Errorf("Expected the budget with its current month, got %s", w.Body.String())
+	}
+	if w := send(http.MethodGet, "/api/alerts?limit=0", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for limit=0, got %d", w.Code)
+	}
+}
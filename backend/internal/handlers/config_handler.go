@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ConfigHandler exposes the runtime settings that are safe to change while running
+type ConfigHandler struct {
+	settings *services.SettingsService
+}
+
+func NewConfigHandler(settings *services.SettingsService) *ConfigHandler {
+	return &ConfigHandler{settings: settings}
+}
+
+// GetConfig returns the current runtime settings
+func (h *ConfigHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, h.settings.Get())
+}
+
+// UpdateConfig validates and applies a partial settings update
+func (h *ConfigHandler) UpdateConfig(c *gin.Context) {
+	var update services.SettingsUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.settings.Update(update)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidSettings) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to update config",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// regexOriginPrefix marks an allowed origin entry as a regular expression
+const regexOriginPrefix = "regex:"
+
+// wildcardOriginSegment is what a * in an allowed origin may stand for: host
+// labels or a port, but never a path or another scheme separator
+const wildcardOriginSegment = `[A-Za-z0-9.-]+`
+
+// NewOriginMatcher builds a CORS origin check from a list of allowed origins.
+// Each entry is one of:
+//
+//	http://localhost:3000        exact origin
+//	https://*.ts.net             wildcard; * matches host labels or a port
+//	regex:^https://dash-\d+\.lan$  regular expression, matched against the whole origin
+//	*                            any origin
+func NewOriginMatcher(origins []string) (func(origin string) bool, error) {
+	exact := make(map[string]bool)
+	var patterns []*regexp.Regexp
+	allowAll := false
+
+	for _, entry := range origins {
+		entry = strings.TrimSpace(entry)
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			allowAll = true
+		case strings.HasPrefix(entry, regexOriginPrefix):
+			expr := strings.TrimPrefix(entry, regexOriginPrefix)
+			re, err := regexp.Compile(`^(?:` + expr + `)$`)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CORS origin pattern %q: %w", expr, err)
+			}
+			patterns = append(patterns, re)
+		case strings.Contains(entry, "*"):
+			parts := strings.Split(normalizeOrigin(entry), "*")
+			for i, part := range parts {
+				parts[i] = regexp.QuoteMeta(part)
+			}
+			patterns = append(patterns, regexp.MustCompile(`^`+strings.Join(parts, wildcardOriginSegment)+`$`))
+		default:
+			exact[normalizeOrigin(entry)] = true
+		}
+	}
+
+	if !allowAll && len(exact) == 0 && len(patterns) == 0 {
+		return nil, fmt.Errorf("no CORS origins configured")
+	}
+
+	return func(origin string) bool {
+		if allowAll {
+			return true
+		}
+		origin = normalizeOrigin(origin)
+		if exact[origin] {
+			return true
+		}
+		for _, re := range patterns {
+			if re.MatchString(origin) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// normalizeOrigin lowercases an origin and drops a trailing slash, which is
+// commonly left over when FRONTEND_URL is copied from the address bar
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+}
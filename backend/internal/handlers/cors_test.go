@@ -0,0 +1,53 @@
+package handlers
+
+import "testing"
+
+func TestNewOriginMatcher(t *testing.T) {
+	allow, err := NewOriginMatcher([]string{
+		"http://localhost:3000/",
+		"https://*.ts.net",
+		"http://127.0.0.1:*",
+		`regex:https://dash-\d+\.example\.com`,
+	})
+	if err != nil {
+		t.Fatalf("NewOriginMatcher failed: %v", err)
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"http://localhost:3000", true},
+		{"HTTP://LOCALHOST:3000", true},
+		{"http://localhost:3001", false},
+		{"https://laptop.tail1234.ts.net", true},
+		{"http://laptop.tail1234.ts.net", false},
+		{"https://ts.net", false},
+		{"https://evil.com/.ts.net", false},
+		{"http://127.0.0.1:8081", true},
+		{"https://dash-42.example.com", true},
+		{"https://dash-42.example.com.evil.com", false},
+	}
+	for _, tt := range tests {
+		if got := allow(tt.origin); got != tt.allowed {
+			t.Errorf("Expected %s allowed=%v, got %v", tt.origin, tt.allowed, got)
+		}
+	}
+}
+
+func TestNewOriginMatcherRejectsInvalidConfig(t *testing.T) {
+	if _, err := NewOriginMatcher([]string{"regex:("}); err == nil {
+		t.Error("Expected invalid regex to be rejected")
+	}
+	if _, err := NewOriginMatcher([]string{" "}); err == nil {
+		t.Error("Expected empty origin list to be rejected")
+	}
+
+	allow, err := NewOriginMatcher([]string{"*"})
+	if err != nil {
+		t.Fatalf("NewOriginMatcher failed: %v", err)
+	}
+	if !allow("https://anything.example") {
+		t.Error("Expected * to allow any origin")
+	}
+}
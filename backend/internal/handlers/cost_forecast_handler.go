@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CostForecastHandler reports and projects monthly spend
+type CostForecastHandler struct {
+	forecasts *services.CostForecastService
+}
+
+func NewCostForecastHandler(forecasts *services.CostForecastService) *CostForecastHandler {
+	return &CostForecastHandler{forecasts: forecasts}
+}
+
+// GetCostForecast projects this month's spend at API prices from the trend
+// of the last 7 and 30 days, in total and by model and project. Days and the
+// month follow ?tz= or the configured timezone.
+func (h *CostForecastHandler) GetCostForecast(c *gin.Context) {
+	loc, err := parseLocation(c, h.forecasts.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time zone",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	forecast, err := h.forecasts.Forecast(requestUser(c), time.Now(), loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to forecast costs",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, forecast)
+}
+
+// GetCurrentMonthCosts returns this month's spend at API prices. With
+// ?breakdown=billing it is split into subscription and pay-as-you-go API
+// usage, and billed_cost is what the month is actually charged.
+func (h *CostForecastHandler) GetCurrentMonthCosts(c *gin.Context) {
+	breakdown := c.Query("breakdown")
+	if breakdown != "" && breakdown != "billing" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid breakdown",
+			"details": "breakdown must be billing",
+		})
+		return
+	}
+	loc, err := parseLocation(c, h.forecasts.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time zone",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	costs, err := h.forecasts.MonthCosts(requestUser(c), time.Now(), loc, breakdown == "billing")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get month costs",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, costs)
+}
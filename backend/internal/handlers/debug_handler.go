@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireFeature hides routes behind a feature flag, answering 404 while it is disabled
+func RequireFeature(featureFlags *services.FeatureFlagService, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !featureFlags.IsEnabled(name) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Not found",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RegisterPprof mounts the net/http/pprof handlers on group, which must be
+// rooted at /debug/pprof since pprof.Index derives profile names from the path
+func RegisterPprof(group *gin.RouterGroup) {
+	group.GET("/", gin.WrapF(pprof.Index))
+	group.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	group.GET("/profile", gin.WrapF(pprof.Profile))
+	group.GET("/symbol", gin.WrapF(pprof.Symbol))
+	group.POST("/symbol", gin.WrapF(pprof.Symbol))
+	group.GET("/trace", gin.WrapF(pprof.Trace))
+	group.GET("/:profile", gin.WrapF(pprof.Index))
+}
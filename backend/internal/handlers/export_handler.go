@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler streams usage data as CSV, a JSON array or JSON lines
+type ExportHandler struct {
+	exports *services.ExportService
+}
+
+func NewExportHandler(exports *services.ExportService) *ExportHandler {
+	return &ExportHandler{exports: exports}
+}
+
+// startExport sends the download headers and returns a writer that flushes
+// periodically so large exports reach the client while they are produced
+func startExport(c *gin.Context, format, name string, header []string) *services.RecordWriter {
+	filename := fmt.Sprintf("claudeee-%s-%s.%s", name, time.Now().UTC().Format("20060102"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	switch format {
+	case services.ExportCSV:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	case services.ExportJSONL:
+		c.Header("Content-Type", "application/x-ndjson")
+	default:
+		c.Header("Content-Type", "application/json")
+	}
+	c.Status(http.StatusOK)
+	w, _ := services.NewRecordWriter(c.Writer, format, header)
+	w.FlushEvery(exportFlushEvery, c.Writer.Flush)
+	return w
+}
+
+// finishExport completes the export. Headers are already sent, so a failure
+// can only be reported by cutting the stream short; a truncated JSON array
+// makes it visible to clients.
+func finishExport(c *gin.Context, w *services.RecordWriter, err error) {
+	if err != nil {
+		w.Flush()
+		c.Error(err)
+		return
+	}
+	w.Close()
+}
+
+// parseExportQuery reads ?format=, ?project=, ?from= and ?to=
+func parseExportQuery(c *gin.Context) (string, services.ExportQuery, error) {
+	format := c.DefaultQuery("format", services.ExportCSV)
+	if err := services.ValidateExportFormat(format); err != nil {
+		return "", services.ExportQuery{}, err
+	}
+	from, to, err := parseNamedTimeRange(c, "from", "to")
+	if err != nil {
+		return "", services.ExportQuery{}, err
+	}
+	return format, services.ExportQuery{Project: c.Query("project"), User: requestUser(c), From: from, To: to}, nil
+}
+
+// ExportSessions streams each session's usage within the range
+func (h *ExportHandler) ExportSessions(c *gin.Context) {
+	format, q, err := parseExportQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid export query",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	w := startExport(c, format, "sessions", services.SessionExportHeader)
+	err = h.exports.StreamSessions(q, func(s services.ExportedSession) error {
+		return w.Write(s, s.Row())
+	})
+	finishExport(c, w, err)
+}
+
+// ExportMessages streams every message in the range with its cost. Admins
+// can add message content with ?content=true.
+func (h *ExportHandler) ExportMessages(c *gin.Context) {
+	format, q, err := parseExportQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid export query",
+			"details": err.Error(),
+		})
+		return
+	}
+	q.Content = c.Query("content") == "true"
+	if q.Content && !auth.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Only admins can export message content",
+		})
+		return
+	}
+
+	w := startExport(c, format, "messages", services.MessageExportHeader(q.Content))
+	err = h.exports.StreamMessages(q, func(m services.ExportedMessage) error {
+		return w.Write(m, m.Row(q.Content))
+	})
+	finishExport(c, w, err)
+}
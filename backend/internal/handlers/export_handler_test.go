@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func TestExportHandler(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'alpha', '/alpha', '2024-01-01 10:00:00');
+		INSERT INTO messages (id, session_id, message_role, model, content, input_tokens, output_tokens, timestamp)
+		VALUES ('m1', 's1', 'assistant', 'claude-sonnet-4-20250514', 'a "quoted", multi
+line answer', 1000, 100, '2024-01-01 10:00:00');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert data: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	h := NewExportHandler(services.NewExportService(db))
+	role := auth.RoleAdmin
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(auth.RoleKey, role) })
+	r.GET("/api/export/sessions", h.ExportSessions)
+	r.GET("/api/export/messages", h.ExportMessages)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/export/messages?content=true&project=alpha&from=2024-01-01&to=2024-01-31")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Expected a CSV export, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Export is not valid CSV: %v", err)
+	}
+	if len(records) != 2 || records[0][len(records[0])-1] != "content" || records[1][len(records[1])-1] != "a \"quoted\", multi\nline answer" {
+		t.Errorf("Unexpected CSV records: %q", records)
+	}
+
+	w = get("/api/export/sessions?format=json")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), `[{"session_id":"s1"`) || !strings.HasSuffix(w.Body.String(), "]\n") {
+		t.Errorf("Expected a JSON array of sessions, got %s", w.Body.String())
+	}
+
+	if w = get("/api/export/sessions?format=xml"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown format, got %d", w.Code)
+	}
+
+	role = auth.RoleViewer
+	if w = get("/api/export/messages?content=true"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected viewers to be refused content, got %d", w.Code)
+	}
+	if w = get("/api/export/messages?format=jsonl"); w.Code != http.StatusOK || strings.Count(w.Body.String(), "\n") != 1 {
+		t.Errorf("Expected one JSON line, got %d %s", w.Code, w.Body.String())
+	}
+}
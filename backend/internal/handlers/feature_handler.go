@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// FeatureHandler serves the feature flag admin API
+type FeatureHandler struct {
+	featureFlags *services.FeatureFlagService
+}
+
+func NewFeatureHandler(featureFlags *services.FeatureFlagService) *FeatureHandler {
+	return &FeatureHandler{featureFlags: featureFlags}
+}
+
+// GetFeatures lists all feature flags with their resolved state
+func (h *FeatureHandler) GetFeatures(c *gin.Context) {
+	flags, err := h.featureFlags.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get features",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"features": flags,
+		"count":    len(flags),
+	})
+}
+
+type updateFeatureRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// UpdateFeature enables or disables a flag for this installation.
+// Sending {"enabled": null} removes the override.
+func (h *FeatureHandler) UpdateFeature(c *gin.Context) {
+	name := c.Param("name")
+
+	var req updateFeatureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var err error
+	if req.Enabled == nil {
+		err = h.featureFlags.Reset(name)
+	} else {
+		err = h.featureFlags.Set(name, *req.Enabled)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnknownFeature) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to update feature",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":    name,
+		"enabled": h.featureFlags.IsEnabled(name),
+	})
+}
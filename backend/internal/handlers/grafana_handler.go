@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GrafanaHandler implements the contract of Grafana's JSON datasource plugin
+// (simpod-json-datasource), so usage can be graphed without exporting it.
+// Point the datasource at /api/v1/grafana.
+type GrafanaHandler struct {
+	grafana *services.GrafanaService
+}
+
+func NewGrafanaHandler(grafana *services.GrafanaService) *GrafanaHandler {
+	return &GrafanaHandler{grafana: grafana}
+}
+
+// grafanaRange is the dashboard's time range
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// grafanaSearchRequest is the body Grafana posts to /search
+type grafanaSearchRequest struct {
+	Target string `json:"target"`
+}
+
+// GrafanaQueryRequest is the body Grafana posts to /query
+type GrafanaQueryRequest struct {
+	Range         grafanaRange `json:"range"`
+	IntervalMs    int64        `json:"intervalMs"`
+	MaxDataPoints int          `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// GrafanaAnnotationRequest is the body Grafana posts to /annotations
+type GrafanaAnnotationRequest struct {
+	Range      grafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+// grafanaAnnotationResult echoes the annotation query, as older versions of
+// the datasource expect
+type grafanaAnnotationResult struct {
+	services.GrafanaAnnotation
+	Annotation json.RawMessage `json:"annotation,omitempty"`
+}
+
+// TestConnection answers the datasource's connection test
+func (h *GrafanaHandler) TestConnection(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Search lists the targets whose name contains the posted target
+func (h *GrafanaHandler) Search(c *gin.Context) {
+	var req grafanaSearchRequest
+	// An empty body lists every target
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid search",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	targets := []string{}
+	for _, target := range services.GrafanaMetrics() {
+		if strings.Contains(target, req.Target) {
+			targets = append(targets, target)
+		}
+	}
+	c.JSON(http.StatusOK, targets)
+}
+
+// Query returns a time series per target and, for targets split by model or
+// project, per model or project
+func (h *GrafanaHandler) Query(c *gin.Context) {
+	var req GrafanaQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query",
+			"details": err.Error(),
+		})
+		return
+	}
+	if !req.Range.From.Before(req.Range.To) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query",
+			"details": "range.from must be before range.to",
+		})
+		return
+	}
+
+	var targets []string
+	for _, target := range req.Targets {
+		if !target.Hide && target.Target != "" {
+			targets = append(targets, target.Target)
+		}
+	}
+	interval := services.GrafanaInterval(req.Range.From, req.Range.To, time.Duration(req.IntervalMs)*time.Millisecond, req.MaxDataPoints)
+	series, err := h.grafana.Query(targets, requestUser(c), req.Range.From, req.Range.To, interval)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnknownGrafanaTarget) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to query usage",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, series)
+}
+
+// Annotations returns limit events, or usage windows when the annotation's
+// query is "windows"
+func (h *GrafanaHandler) Annotations(c *gin.Context) {
+	var req GrafanaAnnotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid annotation query",
+			"details": err.Error(),
+		})
+		return
+	}
+	var annotation struct {
+		Query string `json:"query"`
+	}
+	if len(req.Annotation) > 0 {
+		if err := json.Unmarshal(req.Annotation, &annotation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid annotation query",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	annotations, err := h.grafana.Annotations(annotation.Query, req.Range.From, req.Range.To)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnknownGrafanaTarget) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to get annotations",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	results := make([]grafanaAnnotationResult, len(annotations))
+	for i, a := range annotations {
+		results[i] = grafanaAnnotationResult{GrafanaAnnotation: a, Annotation: req.Annotation}
+	}
+	c.JSON(http.StatusOK, results)
+}
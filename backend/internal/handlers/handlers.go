@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
+	"sync/atomic"
+	"time"
 	
 	"github.com/gin-gonic/gin"
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/models"
 	"claudeee-backend/internal/services"
 )
 
@@ -13,18 +20,160 @@ type Handler struct {
 	tokenService        *services.TokenService
 	sessionService      *services.SessionService
 	sessionWindowService *services.SessionWindowService
+	logSources          services.LogSourceConfig
+	queryCache          *services.QueryCache
+	writes              *services.WriteQueue
+	syncJobs            *services.SyncJobQueue
+	syncProgress        func(services.SyncProgress)
+	syncWorkers         int
+	flagMissingSources  bool
+	contentPolicy       atomic.Value // services.ContentPolicy
+	redactSecrets       atomic.Bool
+	redactor            *services.Redactor
+	contentCipher       *services.ContentCipher
+	forecastService     *services.ForecastService
+	location            atomic.Pointer[time.Location]
 }
 
 func NewHandler(tokenService *services.TokenService, sessionService *services.SessionService, sessionWindowService *services.SessionWindowService) *Handler {
-	return &Handler{
+	h := &Handler{
 		tokenService:        tokenService,
 		sessionService:      sessionService,
 		sessionWindowService: sessionWindowService,
+		logSources:          services.DefaultLogSourceConfig(),
+		queryCache:          services.NewQueryCache(services.DefaultQueryCacheMaxAge),
+		flagMissingSources:  true,
 	}
+	h.contentPolicy.Store(services.DefaultContentPolicy())
+	h.redactSecrets.Store(true)
+	h.redactor = services.NewRedactor()
+	h.location.Store(time.UTC)
+	return h
+}
+
+// InvalidateCache drops cached query results, e.g. after settings change
+func (h *Handler) InvalidateCache() {
+	h.queryCache.MarkIngested()
+}
+
+// CurrentTokenUsage returns the token usage of the active window, cached until the next sync
+func (h *Handler) CurrentTokenUsage() (*models.TokenUsage, error) {
+	usage, err := h.queryCache.GetOrLoad("token-usage", func() (interface{}, error) {
+		return h.tokenService.GetCurrentTokenUsage()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usage.(*models.TokenUsage), nil
+}
+
+// allSessions returns session summaries, cached until the next sync
+func (h *Handler) allSessions() ([]models.SessionSummary, error) {
+	sessions, err := h.queryCache.GetOrLoad("sessions", func() (interface{}, error) {
+		return h.sessionService.GetAllSessions()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions.([]models.SessionSummary), nil
+}
+
+// SetWriteQueue routes sync writes through the shared writer
+func (h *Handler) SetWriteQueue(writes *services.WriteQueue) {
+	h.writes = writes
+}
+
+// SetSyncJobQueue sets the queue SyncLogs submits to
+func (h *Handler) SetSyncJobQueue(syncJobs *services.SyncJobQueue) {
+	h.syncJobs = syncJobs
+}
+
+// SetSyncWorkers sets how many log files a sync parses at once; 0 uses the default
+func (h *Handler) SetSyncWorkers(n int) {
+	h.syncWorkers = n
+}
+
+// SetFlagMissingSources sets whether syncs flag sessions whose log files were deleted
+func (h *Handler) SetFlagMissingSources(enabled bool) {
+	h.flagMissingSources = enabled
+}
+
+// SetSyncProgress sets the function sync passes report their progress to
+func (h *Handler) SetSyncProgress(fn func(services.SyncProgress)) {
+	h.syncProgress = fn
+}
+
+// SetContentPolicy sets how much message content future syncs store
+func (h *Handler) SetContentPolicy(policy services.ContentPolicy) {
+	h.contentPolicy.Store(policy)
+}
+
+// SetForecastService enables the burn rate forecast of GetForecast and GetAvailableTokens
+// SetLocation sets the time zone window times are reported in unless a
+// request names another with ?tz=
+func (h *Handler) SetLocation(loc *time.Location) {
+	h.location.Store(loc)
+}
+
+// requestLocation returns the time zone of ?tz= or the configured one,
+// writing an error response when it is unknown
+func (h *Handler) requestLocation(c *gin.Context) (*time.Location, bool) {
+	loc, err := parseLocation(c, h.location.Load())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid time zone",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return loc, true
+}
+
+func (h *Handler) SetForecastService(forecast *services.ForecastService) {
+	h.forecastService = forecast
+}
+
+// SetContentCipher encrypts message content written by syncs and content maintenance
+func (h *Handler) SetContentCipher(c *services.ContentCipher) {
+	h.contentCipher = c
+}
+
+// SetRedactSecrets turns secret redaction at ingest on or off for future syncs
+func (h *Handler) SetRedactSecrets(enabled bool) {
+	h.redactSecrets.Store(enabled)
+}
+
+// SetRedactor sets the rules secret redaction uses
+func (h *Handler) SetRedactor(redactor *services.Redactor) {
+	h.redactor = redactor
+}
+
+func (h *Handler) newDiffSyncService(db *sql.DB) *services.DiffSyncService {
+	diffSyncService := services.NewDiffSyncService(db, h.tokenService, h.sessionService)
+	diffSyncService.SetLogSources(h.logSources)
+	diffSyncService.SetWriteQueue(h.writes)
+	diffSyncService.SetContentPolicy(h.contentPolicy.Load().(services.ContentPolicy))
+	diffSyncService.SetContentCipher(h.contentCipher)
+	diffSyncService.SetProgress(h.syncProgress)
+	diffSyncService.SetWorkers(h.syncWorkers)
+	diffSyncService.SetFlagMissingSources(h.flagMissingSources)
+	if h.redactSecrets.Load() {
+		diffSyncService.SetRedactor(h.redactor)
+	}
+	return diffSyncService
+}
+
+// SetLogSources configures which Claude log directories SyncLogs reads
+func (h *Handler) SetLogSources(sources services.LogSourceConfig) {
+	h.logSources = sources
 }
 
 func (h *Handler) GetTokenUsage(c *gin.Context) {
-	usage, err := h.tokenService.GetCurrentTokenUsage()
+	loc, ok := h.requestLocation(c)
+	if !ok {
+		return
+	}
+	usage, err := h.CurrentTokenUsage()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get token usage",
@@ -33,11 +182,62 @@ func (h *Handler) GetTokenUsage(c *gin.Context) {
 		return
 	}
 	
-	c.JSON(http.StatusOK, usage)
+	// The cached usage is shared, so the window times are set on a copy
+	local := *usage
+	local.WindowStart = usage.WindowStart.In(loc)
+	local.WindowEnd = usage.WindowEnd.In(loc)
+	c.JSON(http.StatusOK, &local)
 }
 
+// GetSessions returns one page of sessions. ?limit= (default 50, at most
+// 500) and ?offset= page the list, ?sort= orders it (start_time, end_time,
+// total_tokens, message_count or project_name; "-" for descending, default
+// -start_time), and ?project=, ?model=, ?status=, ?provider=, ?from= and
+// ?to= filter it.
 func (h *Handler) GetSessions(c *gin.Context) {
-	sessions, err := h.sessionService.GetAllSessions()
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be between 1 and 500",
+		})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset must not be negative",
+		})
+		return
+	}
+	from, to, err := parseNamedTimeRange(c, "from", "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid time range",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	page, err := h.sessionService.QuerySessions(services.SessionQuery{
+		Project: c.Query("project"),
+		User:    requestUser(c),
+		Model:   c.Query("model"),
+		Status:  c.Query("status"),
+		Provider: c.Query("provider"),
+		Tags:    queryTags(c),
+		From:    from,
+		To:      to,
+		Sort:    c.Query("sort"),
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if errors.Is(err, services.ErrInvalidSessionQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid sort",
+			"details": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get sessions",
@@ -46,9 +246,15 @@ func (h *Handler) GetSessions(c *gin.Context) {
 		return
 	}
 	
+	hideTitlesUnlessAdmin(c, page.Sessions)
+	
 	c.JSON(http.StatusOK, gin.H{
-		"sessions": sessions,
-		"count": len(sessions),
+		"sessions": page.Sessions,
+		"count": len(page.Sessions),
+		"total": page.Total,
+		"limit": limit,
+		"offset": offset,
+		"has_more": offset+len(page.Sessions) < page.Total,
 	})
 }
 
@@ -69,7 +275,19 @@ func (h *Handler) GetSessionDetails(c *gin.Context) {
 		})
 		return
 	}
-	
+	if !auth.IsAdmin(c) {
+		session.GeneratedCode = nil
+		session.Title = nil
+	}
+	session.TotalCost, err = h.tokenService.CalculateSessionCost(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get session cost",
+			"details": err.Error(),
+		})
+		return
+	}
+
 	// Check if pagination is requested
 	pageStr := c.Query("page")
 	pageSizeStr := c.Query("page_size")
@@ -100,6 +318,8 @@ func (h *Handler) GetSessionDetails(c *gin.Context) {
 			return
 		}
 		
+		hideContentUnlessAdmin(c, paginatedMessages.Messages)
+		
 		tokenUsage, err := h.tokenService.GetTokenUsageBySession(sessionID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -125,6 +345,8 @@ func (h *Handler) GetSessionDetails(c *gin.Context) {
 			return
 		}
 		
+		hideContentUnlessAdmin(c, messages)
+		
 		tokenUsage, err := h.tokenService.GetTokenUsageBySession(sessionID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -142,52 +364,380 @@ func (h *Handler) GetSessionDetails(c *gin.Context) {
 	}
 }
 
+// SyncLogs queues a log synchronization and returns immediately with the job.
+// Pass wait=true to block until the sync finishes, as older clients expect.
 func (h *Handler) SyncLogs(c *gin.Context) {
 	db := c.MustGet("db").(*sql.DB)
 	
+	// Another instance sharing the database is syncing
+	lease, err := services.ActiveSyncLease(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check for a running sync",
+			"details": err.Error(),
+		})
+		return
+	}
+	if lease != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Another sync is in progress",
+			"details": (&services.SyncInProgressError{Lease: *lease}).Error(),
+			"active_job_id": lease.JobID,
+			"lease": lease,
+		})
+		return
+	}
+	
+	job, created := h.syncJobs.Enqueue("api")
+	
+	if c.Query("wait") != "true" {
+		message := "Sync queued"
+		if !created {
+			message = "Sync already queued"
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": message,
+			"job": job,
+		})
+		return
+	}
+	
+	job, err = h.syncJobs.Wait(c.Request.Context(), job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to wait for sync",
+			"details": err.Error(),
+		})
+		return
+	}
+	if job.Status == services.SyncJobFailed {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to sync logs",
+			"details": job.Error,
+			"job": job,
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logs synced successfully (differential)",
+		"stats": job.Stats,
+		"job": job,
+	})
+}
+
+// GetSyncJobs lists recent sync jobs, newest first
+func (h *Handler) GetSyncJobs(c *gin.Context) {
+	jobs := h.syncJobs.List()
+	c.JSON(http.StatusOK, gin.H{
+		"jobs": jobs,
+		"count": len(jobs),
+	})
+}
+
+// GetSyncErrors lists the log lines sync could not parse, most recently
+// found first; only admins see the quarantined lines themselves
+func (h *Handler) GetSyncErrors(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	
+	q := services.ParseErrorQuery{FilePath: c.Query("file")}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > services.MaxParseErrorPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and 1000",
+			})
+			return
+		}
+		q.Limit = n
+	}
+	if offset := c.Query("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "offset must be a non-negative integer",
+			})
+			return
+		}
+		q.Offset = n
+	}
+	
+	errs, total, err := services.GetParseErrors(db, q, h.contentCipher)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get sync errors",
+			"details": err.Error(),
+		})
+		return
+	}
+	if !auth.IsAdmin(c) {
+		for i := range errs {
+			errs[i].RawContent = nil
+		}
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"errors": errs,
+		"count": len(errs),
+		"total": total,
+	})
+}
+
+// GetSyncJob returns the status of a single sync job
+func (h *Handler) GetSyncJob(c *gin.Context) {
+	job, ok := h.syncJobs.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Sync job not found",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, job)
+}
+
+// RunSync performs one synchronization pass; it is the work done by each sync job
+func (h *Handler) RunSync(ctx context.Context, db *sql.DB) (*models.SyncStats, error) {
 	// Enable differential sync to fix partial log reading issues
 	useDiffSync := true
 	
 	if useDiffSync {
 		// Use new differential sync service
-		diffSyncService := services.NewDiffSyncService(db, h.tokenService, h.sessionService)
+		diffSyncService := h.newDiffSyncService(db)
 		
-		stats, err := diffSyncService.SyncAllLogs()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to sync logs",
-				"details": err.Error(),
+		stats, err := diffSyncService.SyncAllLogs(ctx)
+		if stats != nil && stats.NewLines > 0 {
+			h.queryCache.MarkIngested()
+		}
+		return stats, err
+	}
+	
+	// Use legacy full sync
+	parser := services.NewJSONLParser(db, h.tokenService, h.sessionService)
+	parser.SetContentPolicy(h.contentPolicy.Load().(services.ContentPolicy))
+	parser.SetContentCipher(h.contentCipher)
+	err := parser.SyncAllLogs(ctx)
+	h.queryCache.MarkIngested()
+	return nil, err
+}
+
+// StripContent applies the current content policy to messages already stored
+func (h *Handler) StripContent(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	policy := h.contentPolicy.Load().(services.ContentPolicy)
+	
+	var changed int64
+	err := h.writes.Do(func() error {
+		var err error
+		changed, err = services.StripContent(db, policy, h.contentCipher)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to strip content",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	h.queryCache.MarkIngested()
+	c.JSON(http.StatusOK, gin.H{
+		"policy": policy,
+		"updated_messages": changed,
+	})
+}
+
+// RedactStored applies the current content policy and secret redaction to
+// messages stored before they were configured
+func (h *Handler) RedactStored(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+
+	result, err := h.newDiffSyncService(db).RedactStored()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to redact stored content",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.queryCache.MarkIngested()
+	c.JSON(http.StatusOK, result)
+}
+
+// BackfillContent restores message content from the logs up to the current content policy
+func (h *Handler) BackfillContent(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	policy := h.contentPolicy.Load().(services.ContentPolicy)
+	
+	updated, err := h.newDiffSyncService(db).BackfillContent()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to backfill content",
+			"details": err.Error(),
+			"updated_messages": updated,
+		})
+		return
+	}
+	
+	h.queryCache.MarkIngested()
+	c.JSON(http.StatusOK, gin.H{
+		"policy": policy,
+		"updated_messages": updated,
+	})
+}
+
+// BackfillToolCalls records the tool calls of messages synced before tool
+// tracking existed by re-reading the logs
+func (h *Handler) BackfillToolCalls(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	
+	added, err := h.newDiffSyncService(db).BackfillToolCalls()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to backfill tool calls",
+			"details": err.Error(),
+			"added_calls": added,
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"added_calls": added,
+	})
+}
+
+// RecomputeAggregates rebuilds session and window totals from the messages
+// table and reports the discrepancies it found; ?dry_run=true only reports them
+func (h *Handler) RecomputeAggregates(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	dryRun := c.Query("dry_run") == "true"
+	
+	var report *services.AggregateReport
+	err := h.writes.Do(func() error {
+		var err error
+		report, err = services.RecomputeAggregates(db, dryRun)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to recompute totals",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if !dryRun && report.DiscrepancyCount > 0 {
+		h.queryCache.MarkIngested()
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetRedactionReport returns how many secrets of each kind were redacted at ingest
+func (h *Handler) GetRedactionReport(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	
+	counts, err := services.GetRedactionCounts(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get redaction report",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	var total int64
+	for _, count := range counts {
+		total += count.Count
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.redactSecrets.Load(),
+		"counts": counts,
+		"total": total,
+	})
+}
+
+// GetDuplicateReport returns the log entries whose usage was not counted
+// because it repeated that of an earlier entry for the same request
+func (h *Handler) GetDuplicateReport(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and 1000",
 			})
 			return
 		}
-		
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Logs synced successfully (differential)",
-			"stats": stats,
+		limit = n
+	}
+	
+	report, err := services.GetDuplicateReport(db, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get duplicate report",
+			"details": err.Error(),
 		})
-	} else {
-		// Use legacy full sync
-		parser := services.NewJSONLParser(db, h.tokenService, h.sessionService)
-		
-		if err := parser.SyncAllLogs(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to sync logs",
-				"details": err.Error(),
+		return
+	}
+	
+	c.JSON(http.StatusOK, report)
+}
+
+// GetLimitEvents returns the usage and rate limits found in the logs, newest
+// first, and when a usage limit was last reached
+func (h *Handler) GetLimitEvents(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	
+	limit := 50
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and 1000",
 			})
 			return
 		}
-		
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Logs synced successfully (full)",
+		limit = n
+	}
+	
+	report, err := services.GetLimitEvents(db, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get limit events",
+			"details": err.Error(),
 		})
+		return
 	}
+	
+	c.JSON(http.StatusOK, report)
 }
 
 // GetSessionActivityReport returns detailed activity analysis for a session
+// and its timeline. ?gap_minutes= is the pause that ends a burst (default 5);
+// hours follow ?tz= or the configured timezone.
 func (h *Handler) GetSessionActivityReport(c *gin.Context) {
 	sessionID := c.Param("id")
 	
-	report, err := h.sessionService.GetSessionActivityReport(sessionID)
+	idleGap := services.DefaultIdleGap
+	if raw := c.Query("gap_minutes"); raw != "" {
+		minutes, err := strconv.Atoi(raw)
+		if err != nil || minutes < 1 || minutes > 1440 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "gap_minutes must be between 1 and 1440",
+			})
+			return
+		}
+		idleGap = time.Duration(minutes) * time.Minute
+	}
+	loc, ok := h.requestLocation(c)
+	if !ok {
+		return
+	}
+	
+	report, err := h.sessionService.GetSessionActivityReport(sessionID, idleGap, loc)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get session activity report",
@@ -202,7 +752,19 @@ func (h *Handler) GetSessionActivityReport(c *gin.Context) {
 func (h *Handler) GetRecentSessions(c *gin.Context) {
 	hours := c.DefaultQuery("hours", "720")
 	
-	sessions, err := h.sessionService.GetAllSessions()
+	var sessions []models.SessionSummary
+	var err error
+	if user := requestUser(c); user != "" {
+		var page *services.SessionPage
+		page, err = h.sessionService.QuerySessions(services.SessionQuery{User: user, Limit: math.MaxInt32})
+		if err == nil {
+			sessions = page.Sessions
+		}
+	} else {
+		sessions, err = h.allSessions()
+		// Titles are hidden on a copy so the cached sessions keep them
+		sessions = append([]models.SessionSummary(nil), sessions...)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get recent sessions",
@@ -211,54 +773,131 @@ func (h *Handler) GetRecentSessions(c *gin.Context) {
 		return
 	}
 	
+	hideTitlesUnlessAdmin(c, sessions)
+	
 	c.JSON(http.StatusOK, gin.H{
 		"sessions": sessions,
 		"hours": hours,
 	})
 }
 
+// GetAvailableTokens returns the tokens left in the current window. ?plan=
+// answers for another built-in plan instead of the configured one.
 func (h *Handler) GetAvailableTokens(c *gin.Context) {
-	plan := c.DefaultQuery("plan", "pro")
-	
-	usage, err := h.tokenService.GetCurrentTokenUsage()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get token usage",
-			"details": err.Error(),
-		})
+	usage, plan, usageLimit, ok := h.planUsage(c)
+	if !ok {
 		return
 	}
 	
-	availableTokens := usage.UsageLimit - usage.TotalTokens
+	availableTokens := usageLimit - usage.TotalTokens
 	if availableTokens < 0 {
 		availableTokens = 0
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"available_tokens": availableTokens,
 		"plan": plan,
-		"usage_limit": usage.UsageLimit,
+		"usage_limit": usageLimit,
 		"used_tokens": usage.TotalTokens,
-	})
+	}
+	if h.forecastService != nil {
+		forecast, err := h.forecastService.Forecast(usage, usageLimit, time.Now().UTC())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to forecast token usage",
+				"details": err.Error(),
+			})
+			return
+		}
+		response["forecast"] = forecast
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-func (h *Handler) GetCurrentMonthCosts(c *gin.Context) {
+// GetForecast projects when the plan limit (of ?plan=, default the current
+// plan) is reached at the burn rate of the last minutes of the active window
+func (h *Handler) GetForecast(c *gin.Context) {
+	if h.forecastService == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Forecasting is not enabled",
+		})
+		return
+	}
+	usage, plan, usageLimit, ok := h.planUsage(c)
+	if !ok {
+		return
+	}
+	
+	forecast, err := h.forecastService.Forecast(usage, usageLimit, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to forecast token usage",
+			"details": err.Error(),
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"current_month_cost": 0.0,
-		"currency": "USD",
-		"note": "Cost tracking not implemented yet",
+		"plan": plan,
+		"forecast": forecast,
 	})
 }
 
-func (h *Handler) GetTasks(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"tasks": []interface{}{},
-		"count": 0,
-		"note": "Task scheduling not implemented yet",
-	})
+// planUsage returns the active window's usage with the plan of ?plan= and
+// its limit, writing an error response when it fails
+func (h *Handler) planUsage(c *gin.Context) (*models.TokenUsage, string, int, bool) {
+	plan := c.DefaultQuery("plan", h.tokenService.CurrentPlan())
+	
+	usage, err := h.CurrentTokenUsage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get token usage",
+			"details": err.Error(),
+		})
+		return nil, "", 0, false
+	}
+	
+	usageLimit := usage.UsageLimit
+	if plan != h.tokenService.CurrentPlan() {
+		limit, ok := services.PlanUsageLimit(plan)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Unknown plan",
+				"details": plan,
+			})
+			return nil, "", 0, false
+		}
+		usageLimit = limit
+	}
+	return usage, plan, usageLimit, true
+}
+
+// GetPlanUtilization reports the share of the plan limit used in the current
+// window and the estimated time until the limit is reached
+func (h *Handler) GetPlanUtilization(c *gin.Context) {
+	loc, ok := h.requestLocation(c)
+	if !ok {
+		return
+	}
+	usage, err := h.CurrentTokenUsage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get token usage",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	utilization := services.ComputePlanUtilization(h.tokenService.CurrentPlan(), usage, time.Now().In(loc))
+	utilization.WindowStart = utilization.WindowStart.In(loc)
+	utilization.WindowEnd = utilization.WindowEnd.In(loc)
+	c.JSON(http.StatusOK, utilization)
 }
 
 func (h *Handler) GetSessionWindows(c *gin.Context) {
+	loc, ok := h.requestLocation(c)
+	if !ok {
+		return
+	}
 	limitStr := c.DefaultQuery("limit", "50")
 	
 	limit, err := strconv.Atoi(limitStr)
@@ -278,6 +917,12 @@ func (h *Handler) GetSessionWindows(c *gin.Context) {
 		return
 	}
 	
+	for _, window := range windows {
+		window.WindowStart = window.WindowStart.In(loc)
+		window.WindowEnd = window.WindowEnd.In(loc)
+		window.ResetTime = window.ResetTime.In(loc)
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"windows": windows,
 		"count": len(windows),
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// IndexHandler serves the index health admin API
+type IndexHandler struct {
+	advisor *services.IndexAdvisor
+	writes  *services.WriteQueue
+}
+
+func NewIndexHandler(advisor *services.IndexAdvisor, writes *services.WriteQueue) *IndexHandler {
+	return &IndexHandler{advisor: advisor, writes: writes}
+}
+
+// GetIndexReport explains the hot queries and reports missing and unused indexes
+func (h *IndexHandler) GetIndexReport(c *gin.Context) {
+	report, err := h.advisor.Analyze()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to analyze indexes",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+type applyIndexesRequest struct {
+	Indexes []string `json:"indexes"`
+}
+
+// ApplyIndexes creates the requested indexes, or all recommended ones when
+// the body is empty
+func (h *IndexHandler) ApplyIndexes(c *gin.Context) {
+	var req applyIndexesRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var created []string
+	err := h.writes.Do(func() error {
+		var err error
+		created, err = h.advisor.Apply(req.Indexes)
+		return err
+	})
+	if errors.Is(err, services.ErrUnknownIndex) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Unknown index",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to apply indexes",
+			"details": err.Error(),
+			"created": created,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"created": created,
+		"count":   len(created),
+	})
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxIngestBodyBytes bounds the body of one ingest request
+const maxIngestBodyBytes = 32 << 20
+
+// IngestFunc stores pushed log entries as messages of userID
+type IngestFunc func(entries []services.IngestEntry, userID string) (*services.IngestResult, error)
+
+// IngestHandler accepts log entries pushed by remote agents. It is outside
+// the login middleware and authenticates agents by their ingest token.
+type IngestHandler struct {
+	tokens map[string]string
+	ingest IngestFunc
+}
+
+// NewIngestHandler accepts the tokens in tokens, each mapped to the user
+// whose logs are pushed with it
+func NewIngestHandler(tokens map[string]string, ingest IngestFunc) *IngestHandler {
+	return &IngestHandler{tokens: tokens, ingest: ingest}
+}
+
+// Ingest stores a batch of log entries. Entries already stored are counted
+// as duplicates, so agents retry a batch after any failure.
+func (h *IngestHandler) Ingest(c *gin.Context) {
+	if len(h.tokens) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Ingest is disabled",
+			"details": "set CLAUDEEE_INGEST_TOKENS to accept log entries from agents",
+		})
+		return
+	}
+	userID, ok := h.userFor(c.GetHeader("Authorization"))
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid ingest token",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxIngestBodyBytes)
+	var req services.IngestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(req.Entries) > services.MaxIngestEntries {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Too many log entries",
+			"details": fmt.Sprintf("send at most %d entries per request", services.MaxIngestEntries),
+		})
+		return
+	}
+
+	result, err := h.ingest(req.Entries, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to ingest log entries",
+			"details": err.Error(),
+		})
+		return
+	}
+	logging.Component("api").Debug("Ingested log entries",
+		"user", userID,
+		"host", req.Host,
+		"ingested", result.Ingested,
+		"duplicates", result.Duplicates,
+		"skipped", result.Skipped)
+	c.JSON(http.StatusOK, result)
+}
+
+// userFor returns the user of a "Bearer <token>" header. Every token is
+// compared in constant time so the response time does not reveal a prefix.
+func (h *IngestHandler) userFor(header string) (string, bool) {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	userID := ""
+	for candidate, id := range h.tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			userID = id
+		}
+	}
+	return userID, userID != ""
+}
+
+// Ingest stores log entries pushed by an agent with the same content policy,
+// redaction and encryption as a sync
+func (h *Handler) Ingest(db *sql.DB, entries []services.IngestEntry, userID string) (*services.IngestResult, error) {
+	result, err := h.newDiffSyncService(db).Ingest(entries, userID)
+	if result != nil && result.Ingested > 0 {
+		h.queryCache.MarkIngested()
+	}
+	return result, err
+}
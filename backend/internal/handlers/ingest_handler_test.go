@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIngestAuthenticatesAgents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotUser string
+	var gotEntries int
+	ingest := func(entries []services.IngestEntry, userID string) (*services.IngestResult, error) {
+		gotUser, gotEntries = userID, len(entries)
+		return &services.IngestResult{Received: len(entries), Ingested: len(entries)}, nil
+	}
+
+	post := func(h *IngestHandler, header, body string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.POST("/api/ingest", h.Ingest)
+		req := httptest.NewRequest(http.MethodPost, "/api/ingest", strings.NewReader(body))
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"host":"laptop","entries":[{"project":"-work-api","entry":{"uuid":"u1","sessionId":"s1","timestamp":"2024-01-02T09:00:00Z"}}]}`
+	if w := post(NewIngestHandler(nil, ingest), "Bearer secret", body); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without configured tokens, got %d", w.Code)
+	}
+
+	h := NewIngestHandler(map[string]string{"secret": "bob"}, ingest)
+	for _, header := range []string{"", "Bearer wrong", "secret", "Bearer "} {
+		if w := post(h, header, body); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for Authorization %q, got %d", header, w.Code)
+		}
+	}
+	if w := post(h, "Bearer secret", `{"entries":`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a malformed body, got %d", w.Code)
+	}
+
+	w := post(h, "Bearer secret", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if gotUser != "bob" || gotEntries != 1 {
+		t.Errorf("Expected 1 entry for bob, got %d for %q", gotEntries, gotUser)
+	}
+	if !strings.Contains(w.Body.String(), `"ingested":1`) {
+		t.Errorf("Expected the ingest result, got %s", w.Body.String())
+	}
+}
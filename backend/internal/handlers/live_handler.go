@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	liveWriteTimeout = 10 * time.Second
+	livePingInterval = 30 * time.Second
+	// livePongTimeout must exceed the ping interval
+	livePongTimeout = 60 * time.Second
+	// liveSendBuffer is how many updates a slow client may fall behind before it is dropped
+	liveSendBuffer = 8
+)
+
+// LiveUpdate is pushed to WebSocket clients on connect and after every sync
+type LiveUpdate struct {
+	Type          string                  `json:"type"`
+	SyncJobID     string                  `json:"sync_job_id,omitempty"`
+	TokenUsage    *models.TokenUsage      `json:"token_usage"`
+	SessionWindow *services.SessionWindow `json:"session_window"`
+	Cost          LiveCost                `json:"cost"`
+	SentAt        time.Time               `json:"sent_at"`
+}
+
+// LiveCost is the cost of the current session window
+type LiveCost struct {
+	WindowCost float64 `json:"window_cost"`
+	Currency   string  `json:"currency"`
+}
+
+// LiveSnapshot collects the dashboard figures sent to WebSocket clients
+func (h *Handler) LiveSnapshot() (*LiveUpdate, error) {
+	usage, err := h.CurrentTokenUsage()
+	if err != nil {
+		return nil, err
+	}
+	window, err := h.sessionWindowService.GetCurrentActiveWindow()
+	if err != nil {
+		return nil, err
+	}
+	return &LiveUpdate{
+		TokenUsage:    usage,
+		SessionWindow: window,
+		Cost:          LiveCost{WindowCost: usage.TotalCost, Currency: "USD"},
+	}, nil
+}
+
+// LiveHandler pushes dashboard updates over WebSocket so the frontend does
+// not have to poll GET /token-usage
+type LiveHandler struct {
+	snapshot func() (*LiveUpdate, error)
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[*liveClient]struct{}
+}
+
+type liveClient struct {
+	send chan []byte
+}
+
+// NewLiveHandler creates a handler that sends the result of snapshot.
+// allowOrigin is the CORS origin check; requests without an Origin header,
+// which do not come from a browser, are always accepted.
+func NewLiveHandler(snapshot func() (*LiveUpdate, error), allowOrigin func(string) bool) *LiveHandler {
+	return &LiveHandler{
+		snapshot: snapshot,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || allowOrigin(origin)
+			},
+		},
+		clients: make(map[*liveClient]struct{}),
+	}
+}
+
+// Serve upgrades the request to a WebSocket and sends updates until the
+// client disconnects. The current figures are sent right away.
+func (l *LiveHandler) Serve(c *gin.Context) {
+	conn, err := l.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written the error response
+		return
+	}
+
+	client := &liveClient{send: make(chan []byte, liveSendBuffer)}
+	if data, err := l.encode("snapshot", ""); err == nil {
+		client.send <- data
+	} else {
+		logging.Component("live").Warn("Failed to build live update", "err", err)
+	}
+
+	l.mu.Lock()
+	l.clients[client] = struct{}{}
+	l.mu.Unlock()
+
+	go l.writeLoop(conn, client)
+	l.readLoop(conn)
+
+	l.remove(client)
+}
+
+// Clients returns the number of connected clients
+func (l *LiveHandler) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.clients)
+}
+
+// SyncFinished sends fresh figures to every client after a completed sync
+func (l *LiveHandler) SyncFinished(job services.SyncJob) {
+	if job.Status != services.SyncJobCompleted || l.Clients() == 0 {
+		return
+	}
+	data, err := l.encode("sync", job.ID)
+	if err != nil {
+		logging.Component("live").Warn("Failed to build live update", "err", err)
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for client := range l.clients {
+		select {
+		case client.send <- data:
+		default:
+			// The client is not keeping up; closing send ends its connection
+			delete(l.clients, client)
+			close(client.send)
+		}
+	}
+}
+
+func (l *LiveHandler) encode(kind, jobID string) ([]byte, error) {
+	update, err := l.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	update.Type = kind
+	update.SyncJobID = jobID
+	update.SentAt = time.Now().UTC()
+	return json.Marshal(update)
+}
+
+func (l *LiveHandler) remove(client *liveClient) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.clients[client]; ok {
+		delete(l.clients, client)
+		close(client.send)
+	}
+}
+
+// readLoop discards client messages and returns once the connection fails,
+// which is how a disconnect is noticed
+func (l *LiveHandler) readLoop(conn *websocket.Conn) {
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writeLoop is the only writer on conn; it ends when send is closed
+func (l *LiveHandler) writeLoop(conn *websocket.Conn, client *liveClient) {
+	ticker := time.NewTicker(livePingInterval)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-client.send:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
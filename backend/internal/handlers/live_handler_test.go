@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestLiveHandlerPushesUpdates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var tokens atomic.Int64
+	tokens.Store(100)
+	live := NewLiveHandler(func() (*LiveUpdate, error) {
+		usage := &models.TokenUsage{TotalTokens: int(tokens.Load()), TotalCost: 1.5}
+		return &LiveUpdate{TokenUsage: usage, Cost: LiveCost{WindowCost: usage.TotalCost, Currency: "USD"}}, nil
+	}, func(origin string) bool { return origin == "http://localhost:3000" })
+
+	r := gin.New()
+	r.GET("/api/ws", live.Serve)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/ws"
+
+	// Browsers from other origins are refused
+	header := http.Header{"Origin": {"http://evil.example"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(url, header); err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a foreign origin, got %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://localhost:3000"}})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var update LiveUpdate
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if update.Type != "snapshot" || update.TokenUsage.TotalTokens != 100 {
+		t.Errorf("Expected initial snapshot with 100 tokens, got %+v", update)
+	}
+
+	// Failed syncs change nothing and are not pushed
+	live.SyncFinished(services.SyncJob{ID: "job-1", Status: services.SyncJobFailed})
+	tokens.Store(250)
+	live.SyncFinished(services.SyncJob{ID: "job-2", Status: services.SyncJobCompleted})
+
+	if err := conn.ReadJSON(&update); err != nil {
+		t.Fatalf("Failed to read update: %v", err)
+	}
+	if update.Type != "sync" || update.SyncJobID != "job-2" || update.TokenUsage.TotalTokens != 250 {
+		t.Errorf("Expected update for job-2 with 250 tokens, got %+v", update)
+	}
+	if update.Cost.WindowCost != 1.5 || update.Cost.Currency != "USD" {
+		t.Errorf("Expected window cost, got %+v", update.Cost)
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for live.Clients() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if live.Clients() != 0 {
+		t.Errorf("Expected disconnected client to be removed, got %d", live.Clients())
+	}
+}
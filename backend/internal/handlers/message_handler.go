@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// exportFlushEvery controls how often streamed exports are flushed to the client
+const exportFlushEvery = 200
+
+// MessageHandler serves cursor-paginated and streamed message listings
+type MessageHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewMessageHandler(sessionService *services.SessionService) *MessageHandler {
+	return &MessageHandler{sessionService: sessionService}
+}
+
+// hideContentUnlessAdmin drops message content for viewers, who may see
+// usage and structure but not the conversations themselves
+func hideContentUnlessAdmin(c *gin.Context, messages []models.Message) {
+	if auth.IsAdmin(c) {
+		return
+	}
+	for i := range messages {
+		messages[i].Content = nil
+	}
+}
+
+// hideTitlesUnlessAdmin removes session titles, which quote the first
+// prompt, from responses to viewers
+func hideTitlesUnlessAdmin(c *gin.Context, sessions []models.SessionSummary) {
+	if auth.IsAdmin(c) {
+		return
+	}
+	for i := range sessions {
+		sessions[i].Title = nil
+	}
+}
+
+// parseMessageQuery reads session_id, since, until, cursor and limit query parameters
+func parseMessageQuery(c *gin.Context) (services.MessageQuery, error) {
+	q := services.MessageQuery{SessionID: c.Query("session_id"), User: requestUser(c)}
+
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return q, fmt.Errorf("invalid since: %w", err)
+		}
+		q.Since = &t
+	}
+	if until := c.Query("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return q, fmt.Errorf("invalid until: %w", err)
+		}
+		q.Until = &t
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := services.DecodeMessageCursor(cursor)
+		if err != nil {
+			return q, err
+		}
+		q.After = after
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > services.MaxMessagePageSize {
+			return q, fmt.Errorf("limit must be between 1 and %d", services.MaxMessagePageSize)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// GetMessages returns one page of messages; pass next_cursor back as cursor for the next page
+func (h *MessageHandler) GetMessages(c *gin.Context) {
+	q, err := parseMessageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	page, err := h.sessionService.GetMessagesPage(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get messages",
+			"details": err.Error(),
+		})
+		return
+	}
+	hideContentUnlessAdmin(c, page.Messages)
+
+	c.JSON(http.StatusOK, page)
+}
+
+// ExportMessages streams every matching message as a JSON array, or as
+// newline-delimited JSON with format=ndjson, without buffering the result
+func (h *MessageHandler) ExportMessages(c *gin.Context) {
+	q, err := parseMessageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query",
+			"details": err.Error(),
+		})
+		return
+	}
+	ndjson := c.Query("format") == "ndjson"
+	showContent := auth.IsAdmin(c)
+
+	if ndjson {
+		c.Header("Content-Type", "application/x-ndjson")
+	} else {
+		c.Header("Content-Type", "application/json")
+	}
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	count := 0
+	if !ndjson {
+		c.Writer.WriteString("[")
+	}
+
+	err = h.sessionService.StreamMessages(q, func(message models.Message) error {
+		if !ndjson && count > 0 {
+			if _, err := c.Writer.WriteString(","); err != nil {
+				return err
+			}
+		}
+		if !showContent {
+			message.Content = nil
+		}
+		if err := encoder.Encode(message); err != nil {
+			return err
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	// Headers are already sent, so an error can only be reported by cutting
+	// the stream short; a truncated array makes the failure visible to clients
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !ndjson {
+		c.Writer.WriteString("]\n")
+	}
+	c.Writer.Flush()
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ModelUsageHandler reports usage per model
+type ModelUsageHandler struct {
+	modelUsage *services.ModelUsageService
+}
+
+func NewModelUsageHandler(modelUsage *services.ModelUsageService) *ModelUsageHandler {
+	return &ModelUsageHandler{modelUsage: modelUsage}
+}
+
+// GetModelUsage returns tokens, cost, message counts and cache hit ratios per
+// model and per family, optionally for one ?project= and limited to messages
+// between ?from= and ?to=
+func (h *ModelUsageHandler) GetModelUsage(c *gin.Context) {
+	from, to, err := parseNamedTimeRange(c, "from", "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time range",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	report, err := h.modelUsage.GetModelUsage(services.ModelUsageQuery{
+		Project: c.Query("project"),
+		User:    requestUser(c),
+		Since:   from,
+		Until:   to,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get model usage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
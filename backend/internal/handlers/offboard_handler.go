@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"claudeee-backend/internal/offboard"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// OffboardHandler exports all data to an archive and then wipes the data
+// directory. wipe closes the database, deletes the data and exits the server.
+type OffboardHandler struct {
+	db      *sql.DB
+	writes  *services.WriteQueue
+	dataDir string
+	profile string
+	wipe    func()
+}
+
+func NewOffboardHandler(db *sql.DB, writes *services.WriteQueue, dataDir, profile string, wipe func()) *OffboardHandler {
+	return &OffboardHandler{db: db, writes: writes, dataDir: dataDir, profile: profile, wipe: wipe}
+}
+
+type exportAndWipeRequest struct {
+	// Confirm must repeat the profile name
+	Confirm     string `json:"confirm" binding:"required"`
+	ArchivePath string `json:"archive_path"`
+}
+
+// ExportAndWipe writes the archive on the server, responds with its location,
+// then deletes all data and shuts the server down
+func (h *OffboardHandler) ExportAndWipe(c *gin.Context) {
+	var req exportAndWipeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	if req.Confirm != h.profile {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "confirm must be the profile name (" + h.profile + ")",
+		})
+		return
+	}
+
+	archivePath := req.ArchivePath
+	if archivePath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to resolve home directory",
+				"details": err.Error(),
+			})
+			return
+		}
+		archivePath = offboard.DefaultArchivePath(homeDir, h.profile, time.Now())
+	}
+
+	// Export through the write queue so no sync writes while the dump runs
+	var manifest *offboard.Manifest
+	err := h.writes.Do(func() error {
+		var err error
+		manifest, err = offboard.Export(h.db, h.dataDir, h.profile, archivePath)
+		return err
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, offboard.ErrArchiveInsideDataDir) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to export data; nothing was deleted",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Data exported; deleting all data and stopping the server",
+		"archive_path": archivePath,
+		"manifest":     manifest,
+	})
+
+	// Give the response a moment to reach the client before the server exits
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		h.wipe()
+	}()
+}
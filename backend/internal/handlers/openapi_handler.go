@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"claudeee-backend/internal/apiversion"
+	"claudeee-backend/internal/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUIVersion pins the Swagger UI release the docs page loads
+const swaggerUIVersion = "5.17.14"
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Claudeee API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui", withCredentials: true});
+</script>
+</body>
+</html>
+`
+
+// OpenAPIHandler serves the OpenAPI document of the API and a page browsing it
+type OpenAPIHandler struct {
+	spec []byte
+}
+
+func NewOpenAPIHandler() *OpenAPIHandler {
+	return &OpenAPIHandler{}
+}
+
+// Build documents the registered routes with APIRoutes. Call it once every
+// route is registered; it returns the API routes APIRoutes does not describe.
+func (h *OpenAPIHandler) Build(registered gin.RoutesInfo) (gin.RoutesInfo, error) {
+	info := openapi.Info{
+		Title:       "Claudeee API",
+		Version:     apiversion.Current,
+		Description: "Token usage, sessions and costs of Claude Code.",
+	}
+	doc, undocumented := openapi.Build(info, apiversion.Prefix, registered, APIRoutes())
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OpenAPI document: %w", err)
+	}
+	h.spec = spec
+	return undocumented, nil
+}
+
+// Spec returns the OpenAPI document
+func (h *OpenAPIHandler) Spec(c *gin.Context) {
+	if h.spec == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "OpenAPI document is not available",
+			"details": "the server is still starting",
+		})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// Docs returns a Swagger UI page for the OpenAPI document
+func (h *OpenAPIHandler) Docs(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(http.StatusOK, docsPage, swaggerUIVersion)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ProjectHandler serves token and cost breakdowns by project
+type ProjectHandler struct {
+	projects *services.ProjectService
+}
+
+func NewProjectHandler(projects *services.ProjectService) *ProjectHandler {
+	return &ProjectHandler{projects: projects}
+}
+
+// GetProjects lists projects with their totals, optionally limited to
+// ?since=, ?until= and ?user=
+func (h *ProjectHandler) GetProjects(c *gin.Context) {
+	since, until, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time range",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	projects, err := h.projects.GetProjects(requestUser(c), since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get projects",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects": projects,
+		"count":    len(projects),
+	})
+}
+
+// GetProjectUsage returns one project's totals and model mix
+func (h *ProjectHandler) GetProjectUsage(c *gin.Context) {
+	since, until, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time range",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	usage, err := h.projects.GetProjectUsage(c.Param("name"), requestUser(c), since, until)
+	if errors.Is(err, services.ErrProjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get project usage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
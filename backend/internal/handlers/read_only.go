@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"claudeee-backend/internal/apiversion"
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// ReadOnlyMiddleware rejects every mutating API request with 403 so an
+// instance can be shared with viewers. Login and logout stay available, as do
+// the Grafana datasource's queries, which are posted but only read.
+// Register it after the auth middleware so blocked attempts name the user.
+func ReadOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		path := c.Request.URL.Path
+		if !strings.HasPrefix(path, apiversion.Path("/")) || strings.HasPrefix(path, apiversion.Path("/auth/")) || strings.HasPrefix(path, apiversion.Path("/grafana/")) {
+			c.Next()
+			return
+		}
+
+		logging.Component("api").Info("Blocked request in read-only mode", "method", c.Request.Method, "path", path, "user", c.GetString(auth.UserKey), "client", c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "The API is read-only on this instance",
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ReadOnlyMiddleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/v1/sessions", ok)
+	r.POST("/api/v1/sync-logs", ok)
+	r.PATCH("/api/v1/config", ok)
+	r.POST("/api/v1/auth/login", ok)
+	r.POST("/api/v1/grafana/query", ok)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/api/v1/sessions", http.StatusOK},
+		{http.MethodPost, "/api/v1/sync-logs", http.StatusForbidden},
+		{http.MethodPatch, "/api/v1/config", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/auth/login", http.StatusOK},
+		{http.MethodPost, "/api/v1/grafana/query", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("Expected %s %s to return %d, got %d", tt.method, tt.path, tt.status, w.Code)
+		}
+	}
+}
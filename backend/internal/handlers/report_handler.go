@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ReportHandler serves usage reports in other tools' formats, so their
+// scripts and status lines can read claudeee
+type ReportHandler struct {
+	ccusage *services.CCUsageService
+}
+
+func NewReportHandler(ccusage *services.CCUsageService) *ReportHandler {
+	return &ReportHandler{ccusage: ccusage}
+}
+
+// GetReport returns the ccusage daily, monthly or blocks JSON of the range
+// from ?from= to ?to=, all time without them. Days follow ?tz= or the
+// configured timezone.
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	if format := c.DefaultQuery("format", "ccusage"); format != "ccusage" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format",
+			"details": "format must be ccusage",
+		})
+		return
+	}
+	loc, err := parseLocation(c, h.ccusage.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time zone",
+			"details": err.Error(),
+		})
+		return
+	}
+	from, to, err := parseNamedTimeRangeIn(c, "from", "to", loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time range",
+			"details": err.Error(),
+		})
+		return
+	}
+	period := c.DefaultQuery("period", services.CCUsageDaily)
+	if period != services.CCUsageDaily && period != services.CCUsageMonthly && period != services.CCUsageBlocks {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid period",
+			"details": "period must be daily, monthly or blocks",
+		})
+		return
+	}
+
+	report, err := h.ccusage.Report(services.CCUsageQuery{
+		Period:   period,
+		User:     requestUser(c),
+		Since:    from,
+		Until:    to,
+		Active:   c.Query("active") == "true",
+		Location: loc,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build report",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
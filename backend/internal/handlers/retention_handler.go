@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler prunes message content past the retention period
+type RetentionHandler struct {
+	pruner *services.RetentionPruner
+}
+
+func NewRetentionHandler(pruner *services.RetentionPruner) *RetentionHandler {
+	return &RetentionHandler{pruner: pruner}
+}
+
+// Prune clears the content of messages older than content_retention_days,
+// or than ?days= when given, and reports how many were cleared
+func (h *RetentionHandler) Prune(c *gin.Context) {
+	days := 0
+	if value := c.Query("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > services.MaxRetentionDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid days",
+				"details": fmt.Sprintf("days must be between 1 and %d", services.MaxRetentionDays),
+			})
+			return
+		}
+		days = n
+	}
+
+	result, err := h.pruner.Prune(days)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrNoRetention) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to prune message content",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
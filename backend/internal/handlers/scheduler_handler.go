@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SchedulerHandler controls the scheduled sync
+type SchedulerHandler struct {
+	scheduler *services.SyncScheduler
+}
+
+func NewSchedulerHandler(scheduler *services.SyncScheduler) *SchedulerHandler {
+	return &SchedulerHandler{scheduler: scheduler}
+}
+
+// GetStatus reports the schedule and the outcome of the last scheduled sync
+func (h *SchedulerHandler) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.scheduler.Status())
+}
+
+// Start resumes scheduled syncs
+func (h *SchedulerHandler) Start(c *gin.Context) {
+	if err := h.scheduler.Start(); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrSchedulerNoInterval) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to start sync scheduler",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, h.scheduler.Status())
+}
+
+// Stop pauses scheduled syncs until Start or a restart of the server
+func (h *SchedulerHandler) Stop(c *gin.Context) {
+	h.scheduler.Stop()
+	c.JSON(http.StatusOK, h.scheduler.Status())
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SearchHandler serves text search over messages and sessions
+type SearchHandler struct {
+	search *services.SearchService
+}
+
+func NewSearchHandler(search *services.SearchService) *SearchHandler {
+	return &SearchHandler{search: search}
+}
+
+// Search finds the sessions and messages containing every word of ?q=,
+// optionally within ?project=. Viewers, who may not see message content,
+// only search session ids and project names.
+func (h *SearchHandler) Search(c *gin.Context) {
+	limit := services.DefaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > services.MaxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be between 1 and " + strconv.Itoa(services.MaxSearchLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.search.Search(services.SearchQuery{
+		Text:    c.Query("q"),
+		Project: c.Query("project"),
+		User:    requestUser(c),
+		Limit:   limit,
+		Content: auth.IsAdmin(c),
+	})
+	if errors.Is(err, services.ErrEmptySearch) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "q is required",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to search",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionTagHandler serves session tags and notes and the usage per tag
+type SessionTagHandler struct {
+	sessions *services.SessionService
+	tags     *services.TagService
+	writes   *services.WriteQueue
+}
+
+func NewSessionTagHandler(sessions *services.SessionService, tags *services.TagService, writes *services.WriteQueue) *SessionTagHandler {
+	return &SessionTagHandler{sessions: sessions, tags: tags, writes: writes}
+}
+
+type sessionTagsRequest struct {
+	Tags []string `json:"tags" binding:"required"`
+}
+
+type sessionUpdateRequest struct {
+	Tags  *[]string `json:"tags"`
+	Notes *string   `json:"notes"`
+}
+
+// sessionTagError responds to a failed session update
+func sessionTagError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrInvalidTag) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, services.ErrSessionNotFound) {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+// queryTags reads the ?tag= filter, repeated or comma separated
+func queryTags(c *gin.Context) []string {
+	var tags []string
+	for _, value := range c.QueryArray("tag") {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// AddTags adds the body's tags to a session and returns the session
+func (h *SessionTagHandler) AddTags(c *gin.Context) {
+	var req sessionTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	err := h.writes.Do(func() error {
+		return h.sessions.AddSessionTags(c.Param("id"), req.Tags)
+	})
+	if err != nil {
+		sessionTagError(c, "Failed to tag session", err)
+		return
+	}
+	h.respondSession(c)
+}
+
+// RemoveTag removes one tag from a session and returns the session
+func (h *SessionTagHandler) RemoveTag(c *gin.Context) {
+	err := h.writes.Do(func() error {
+		return h.sessions.RemoveSessionTag(c.Param("id"), c.Param("tag"))
+	})
+	if err != nil {
+		sessionTagError(c, "Failed to untag session", err)
+		return
+	}
+	h.respondSession(c)
+}
+
+// UpdateSession replaces a session's tags and notes, whichever the body
+// has, and returns the session
+func (h *SessionTagHandler) UpdateSession(c *gin.Context) {
+	var req sessionUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	err := h.writes.Do(func() error {
+		return h.sessions.UpdateSession(c.Param("id"), services.SessionUpdate{Tags: req.Tags, Notes: req.Notes})
+	})
+	if err != nil {
+		sessionTagError(c, "Failed to update session", err)
+		return
+	}
+	h.respondSession(c)
+}
+
+func (h *SessionTagHandler) respondSession(c *gin.Context) {
+	session, err := h.sessions.GetSessionByID(c.Param("id"))
+	if err != nil {
+		sessionTagError(c, "Failed to get session", err)
+		return
+	}
+	session.GeneratedCode = nil
+	if !auth.IsAdmin(c) {
+		session.Title = nil
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// GetTags lists the tags in use with the tokens and cost of their sessions,
+// optionally limited to ?since=, ?until= and ?user=
+func (h *SessionTagHandler) GetTags(c *gin.Context) {
+	since, until, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time range",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	tags, err := h.tags.GetTags(requestUser(c), since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get tags",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags":  tags,
+		"count": len(tags),
+	})
+}
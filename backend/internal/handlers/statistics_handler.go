@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPercentileLookbacks bounds the periods one request computes
+const maxPercentileLookbacks = 10
+
+// StatisticsHandler serves usage distributions
+type StatisticsHandler struct {
+	statistics *services.StatisticsService
+}
+
+func NewStatisticsHandler(statistics *services.StatisticsService) *StatisticsHandler {
+	return &StatisticsHandler{statistics: statistics}
+}
+
+// GetPercentiles returns p50, p90 and p99 tokens per window and per session
+// over each ?days= lookback, repeated or comma separated (default 7, 30 and
+// 90 days)
+func (h *StatisticsHandler) GetPercentiles(c *gin.Context) {
+	var lookbacks []int
+	for _, value := range c.QueryArray("days") {
+		for _, raw := range strings.Split(value, ",") {
+			if raw = strings.TrimSpace(raw); raw == "" {
+				continue
+			}
+			days, err := strconv.Atoi(raw)
+			if err != nil || days < 1 || days > 3650 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "days must be between 1 and 3650",
+				})
+				return
+			}
+			lookbacks = append(lookbacks, days)
+		}
+	}
+	if len(lookbacks) > maxPercentileLookbacks {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "at most 10 lookback periods are allowed",
+		})
+		return
+	}
+
+	report, err := h.statistics.GetPercentiles(lookbacks, requestUser(c), time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get percentiles",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
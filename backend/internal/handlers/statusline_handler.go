@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// StatuslineHandler serves a one-line usage summary for tmux status bars,
+// shell prompts and menu-bar apps
+type StatuslineHandler struct {
+	statusline *services.StatuslineService
+	usage      func() (*models.TokenUsage, error)
+}
+
+func NewStatuslineHandler(statusline *services.StatuslineService, usage func() (*models.TokenUsage, error)) *StatuslineHandler {
+	return &StatuslineHandler{statusline: statusline, usage: usage}
+}
+
+// GetStatusline returns the window's utilization, its reset time and
+// today's cost as plain text, or as JSON with ?format=json. Times follow
+// ?tz= or the configured timezone.
+func (h *StatuslineHandler) GetStatusline(c *gin.Context) {
+	format := c.DefaultQuery("format", "text")
+	if format != "text" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format",
+			"details": "format must be text or json",
+		})
+		return
+	}
+	loc, err := parseLocation(c, h.statusline.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time zone",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	usage, err := h.usage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get token usage",
+			"details": err.Error(),
+		})
+		return
+	}
+	line, err := h.statusline.Statusline(usage, requestUser(c), time.Now(), loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get today's cost",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, line)
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(line.Text+"\n"))
+}
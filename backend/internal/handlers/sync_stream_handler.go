@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// syncStreamHeartbeat keeps idle connections open through proxies
+const syncStreamHeartbeat = 15 * time.Second
+
+// SyncStreamHandler streams sync progress as Server-Sent Events
+type SyncStreamHandler struct {
+	progress  *services.SyncProgressBroker
+	heartbeat time.Duration
+}
+
+func NewSyncStreamHandler(progress *services.SyncProgressBroker) *SyncStreamHandler {
+	return &SyncStreamHandler{progress: progress, heartbeat: syncStreamHeartbeat}
+}
+
+// Stream sends an event for each step of every sync until the client
+// disconnects. The event name is the progress type. A client that connects
+// during a sync first receives the latest event of that sync.
+func (h *SyncStreamHandler) Stream(c *gin.Context) {
+	events, current, cancel := h.progress.Subscribe()
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if current != nil {
+		c.SSEvent(current.Type, current)
+	} else {
+		io.WriteString(c.Writer, ": connected\n\n")
+	}
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// catches up from the latest event
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-ticker.C:
+			_, err := io.WriteString(w, ": heartbeat\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSyncStreamHandlerSendsProgress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	broker := services.NewSyncProgressBroker()
+	broker.JobStarted(services.SyncJob{ID: "job-1", Status: services.SyncJobRunning})
+
+	r := gin.New()
+	r.GET("/api/sync-logs/stream", NewSyncStreamHandler(broker).Stream)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/sync-logs/stream")
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("Expected an event stream, got %q", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	next := func(prefix string) string {
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatalf("Stream ended while waiting for %q", prefix)
+				}
+				if strings.HasPrefix(line, prefix) {
+					return line
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Timed out waiting for %q", prefix)
+			}
+		}
+	}
+
+	// The running job's latest event is sent on connect
+	if line := next("event:"); line != "event:started" {
+		t.Errorf("Expected the started event, got %q", line)
+	}
+	if line := next("data:"); !strings.Contains(line, `"job_id":"job-1"`) {
+		t.Errorf("Expected job-1 in %q", line)
+	}
+
+	broker.Publish(services.SyncProgress{Type: services.SyncProgressFile, File: "a.jsonl", FilesFound: 4, FilesDone: 1})
+	if line := next("event:"); line != "event:file" {
+		t.Errorf("Expected a file event, got %q", line)
+	}
+	if line := next("data:"); !strings.Contains(line, `"files_done":1`) || !strings.Contains(line, `"files_found":4`) {
+		t.Errorf("Unexpected file event %q", line)
+	}
+}
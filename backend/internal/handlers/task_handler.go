@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// TaskHandler serves tasks and their schedule against the current window
+type TaskHandler struct {
+	tasks    *services.TaskService
+	usage    func() (*models.TokenUsage, error)
+	writes   *services.WriteQueue
+	executor *services.TaskExecutor
+}
+
+// NewTaskHandler serves tasks; usage returns the current window's token usage
+func NewTaskHandler(tasks *services.TaskService, usage func() (*models.TokenUsage, error), writes *services.WriteQueue) *TaskHandler {
+	return &TaskHandler{tasks: tasks, usage: usage, writes: writes}
+}
+
+// SetExecutor enables RunTask and CancelTask
+func (h *TaskHandler) SetExecutor(executor *services.TaskExecutor) {
+	h.executor = executor
+}
+
+type taskRequest struct {
+	Title           string `json:"title"`
+	Prompt          string `json:"prompt"`
+	ProjectPath     string `json:"project_path"`
+	EstimatedTokens int64  `json:"estimated_tokens"`
+	// Priority defaults to medium and Status to queued
+	Priority string `json:"priority"`
+	Status   string `json:"status"`
+}
+
+func (r taskRequest) task() services.Task {
+	return services.Task{
+		Title:           r.Title,
+		Prompt:          r.Prompt,
+		ProjectPath:     r.ProjectPath,
+		EstimatedTokens: r.EstimatedTokens,
+		Priority:        r.Priority,
+		Status:          r.Status,
+	}
+}
+
+// taskError responds to a failed task operation
+func taskError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidTask):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrTaskNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrTaskNotQueued), errors.Is(err, services.ErrTaskNotRunning),
+		errors.Is(err, services.ErrInsufficientCapacity):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+// GetTasks lists tasks by priority, only those with ?status= if given
+func (h *TaskHandler) GetTasks(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && !services.IsValidTaskStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid status",
+		})
+		return
+	}
+
+	tasks, err := h.tasks.List(status)
+	if err != nil {
+		taskError(c, "Failed to get tasks", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tasks": tasks,
+		"count": len(tasks),
+	})
+}
+
+// GetTask returns one task
+func (h *TaskHandler) GetTask(c *gin.Context) {
+	task, err := h.tasks.Get(c.Param("id"))
+	if err != nil {
+		taskError(c, "Failed to get task", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// CreateTask queues a task
+func (h *TaskHandler) CreateTask(c *gin.Context) {
+	var req taskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var created *services.Task
+	err := h.writes.Do(func() error {
+		var err error
+		created, err = h.tasks.Create(req.task())
+		return err
+	})
+	if err != nil {
+		taskError(c, "Failed to create task", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateTask replaces a task. Omitted fields take their defaults.
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	var req taskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var updated *services.Task
+	err := h.writes.Do(func() error {
+		var err error
+		updated, err = h.tasks.Update(c.Param("id"), req.task())
+		return err
+	})
+	if err != nil {
+		taskError(c, "Failed to update task", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteTask removes a task
+func (h *TaskHandler) DeleteTask(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.writes.Do(func() error { return h.tasks.Delete(id) }); err != nil {
+		taskError(c, "Failed to delete task", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task deleted",
+		"id":      id,
+	})
+}
+
+// GetTaskSchedule suggests which queued tasks fit in the remaining tokens of
+// the current window and which should wait for a reset
+func (h *TaskHandler) GetTaskSchedule(c *gin.Context) {
+	usage, err := h.usage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get token usage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	schedule, err := h.tasks.Schedule(usage)
+	if err != nil {
+		taskError(c, "Failed to schedule tasks", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// RunTask starts a queued task with Claude Code in its project directory and
+// returns it as running. It fails with 409 when the task's estimate exceeds
+// the tokens left in the current window.
+func (h *TaskHandler) RunTask(c *gin.Context) {
+	if h.executor == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Task execution is not enabled",
+		})
+		return
+	}
+
+	task, err := h.executor.Start(c.Param("id"))
+	if err != nil {
+		taskError(c, "Failed to run task", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, task)
+}
+
+// CancelTask stops a running task
+func (h *TaskHandler) CancelTask(c *gin.Context) {
+	if h.executor == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Task execution is not enabled",
+		})
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.executor.Cancel(id); err != nil {
+		taskError(c, "Failed to cancel task", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Task cancellation requested",
+		"id":      id,
+	})
+}
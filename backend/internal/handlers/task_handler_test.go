@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestTaskHandler(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	writes := services.NewWriteQueue(1)
+	defer writes.Close()
+	usage := func() (*models.TokenUsage, error) {
+		return &models.TokenUsage{UsageLimit: 1000, TotalTokens: 900, WindowEnd: time.Now().Add(time.Hour)}, nil
+	}
+	h := NewTaskHandler(services.NewTaskService(db), usage, writes)
+	r := gin.New()
+	r.GET("/api/tasks", h.GetTasks)
+	r.GET("/api/tasks/schedule", h.GetTaskSchedule)
+	r.POST("/api/tasks", h.CreateTask)
+	r.DELETE("/api/tasks/:id", h.DeleteTask)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	if w := send(http.MethodPost, "/api/tasks", `{"estimated_tokens": 10}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a title, got %d", w.Code)
+	}
+	for _, body := range []string{`{"title": "small", "estimated_tokens": 50}`, `{"title": "big", "estimated_tokens": 500, "priority": "high"}`} {
+		if w := send(http.MethodPost, "/api/tasks", body); w.Code != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if w := send(http.MethodGet, "/api/tasks?status=done", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown status, got %d", w.Code)
+	}
+
+	w := send(http.MethodGet, "/api/tasks/schedule", "")
+	var schedule services.TaskSchedule
+	if err := json.Unmarshal(w.Body.Bytes(), &schedule); err != nil || len(schedule.RunNow) != 1 || schedule.RunNow[0].Title != "small" ||
+		len(schedule.Deferred) != 1 || schedule.Deferred[0].Title != "big" {
+		t.Errorf("Expected the small task now and the big one after the reset, got %s", w.Body.String())
+	}
+	if w := send(http.MethodDelete, "/api/tasks/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown task, got %d", w.Code)
+	}
+}
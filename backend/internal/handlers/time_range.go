@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTimeRange reads the ?since= and ?until= query parameters. Each is an
+// RFC3339 time or a YYYY-MM-DD date (UTC); a date as until includes that whole
+// day. Missing parameters are returned as zero times.
+func parseTimeRange(c *gin.Context) (since, until time.Time, err error) {
+	return parseNamedTimeRange(c, "since", "until")
+}
+
+// parseNamedTimeRange is parseTimeRange for other parameter names
+func parseNamedTimeRange(c *gin.Context, startParam, endParam string) (start, end time.Time, err error) {
+	return parseNamedTimeRangeIn(c, startParam, endParam, time.UTC)
+}
+
+// parseNamedTimeRangeIn is parseNamedTimeRange with dates taken as days of loc
+func parseNamedTimeRangeIn(c *gin.Context, startParam, endParam string, loc *time.Location) (start, end time.Time, err error) {
+	if start, err = parseRangeBound(c.Query(startParam), false, loc); err != nil {
+		return start, end, fmt.Errorf("invalid %s: %w", startParam, err)
+	}
+	if end, err = parseRangeBound(c.Query(endParam), true, loc); err != nil {
+		return start, end, fmt.Errorf("invalid %s: %w", endParam, err)
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return start, end, fmt.Errorf("%s must be after %s", endParam, startParam)
+	}
+	return start, end, nil
+}
+
+func parseRangeBound(raw string, end bool, loc *time.Location) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.ParseInLocation(time.DateOnly, raw, loc); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+// parseLocation reads the ?tz= query parameter, an IANA time zone such as
+// Europe/Berlin, returning fallback, the reporting time zone, without it
+func parseLocation(c *gin.Context, fallback *time.Location) (*time.Location, error) {
+	name := c.Query("tz")
+	if name == "" {
+		return fallback, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
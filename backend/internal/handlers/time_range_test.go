@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	parse := func(query string) (time.Time, time.Time, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+		return parseTimeRange(c)
+	}
+
+	since, until, err := parse("since=2024-03-01&until=2024-03-31")
+	if err != nil {
+		t.Fatalf("Failed to parse dates: %v", err)
+	}
+	if !since.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !until.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected all of March, got %v to %v", since, until)
+	}
+
+	since, until, err = parse("since=2024-03-01T09:00:00%2B09:00")
+	if err != nil || !since.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !until.IsZero() {
+		t.Errorf("Expected open range from midnight UTC, got %v to %v (%v)", since, until, err)
+	}
+
+	for _, query := range []string{"since=yesterday", "until=2024-13-01", "since=2024-03-02&until=2024-03-01"} {
+		if _, _, err := parse(query); err == nil {
+			t.Errorf("Expected an error for %q", query)
+		}
+	}
+}
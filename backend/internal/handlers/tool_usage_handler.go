@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ToolUsageHandler reports which tools the assistant calls and what they cost
+type ToolUsageHandler struct {
+	toolUsage *services.ToolUsageService
+}
+
+func NewToolUsageHandler(toolUsage *services.ToolUsageService) *ToolUsageHandler {
+	return &ToolUsageHandler{toolUsage: toolUsage}
+}
+
+// GetToolUsage returns per-tool aggregates, optionally limited to calls
+// between ?since= and ?until=
+func (h *ToolUsageHandler) GetToolUsage(c *gin.Context) {
+	since, until, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time range",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	tools, err := h.toolUsage.GetToolUsage(requestUser(c), since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get tool usage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var calls int
+	var cost float64
+	for _, tool := range tools {
+		calls += tool.Calls
+		cost += tool.Cost
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tools":       tools,
+		"total_calls": calls,
+		"total_cost":  cost,
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// TranscriptHandler serves session conversations in reading order
+type TranscriptHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewTranscriptHandler(sessionService *services.SessionService) *TranscriptHandler {
+	return &TranscriptHandler{sessionService: sessionService}
+}
+
+// GetTranscript returns a ?page= of a session's conversation, ?page_size=
+// main messages long, threaded along parent_uuid with subagent sidechains
+// nested in the message that started them
+func (h *TranscriptHandler) GetTranscript(c *gin.Context) {
+	page := 1
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	pageSize := services.DefaultTranscriptPageSize
+	if ps, err := strconv.Atoi(c.Query("page_size")); err == nil && ps > 0 && ps <= services.MaxTranscriptPageSize {
+		pageSize = ps
+	}
+
+	transcript, err := h.sessionService.GetTranscript(c.Param("id"), page, pageSize)
+	if errors.Is(err, services.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Session not found",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get session transcript",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !auth.IsAdmin(c) {
+		hideTranscriptContent(transcript.Messages)
+	}
+	c.JSON(http.StatusOK, transcript)
+}
+
+func hideTranscriptContent(messages []services.TranscriptMessage) {
+	for i := range messages {
+		messages[i].Content = nil
+		hideTranscriptContent(messages[i].Sidechain)
+	}
+}
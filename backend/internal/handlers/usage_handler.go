@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// UsageHandler serves precomputed usage rollups
+type UsageHandler struct {
+	rollups *services.RollupService
+	writes  *services.WriteQueue
+}
+
+func NewUsageHandler(rollups *services.RollupService, writes *services.WriteQueue) *UsageHandler {
+	return &UsageHandler{rollups: rollups, writes: writes}
+}
+
+// GetDailyUsage returns per-day totals and day, week or month rollups with a
+// per-model breakdown. The range is ?from= to ?to= (RFC3339 or YYYY-MM-DD),
+// or the last ?days= days (default 30) when from is missing; ?granularity=
+// is day (default), week or month. Days follow ?tz= or the configured
+// timezone.
+func (h *UsageHandler) GetDailyUsage(c *gin.Context) {
+	from, to, loc, ok := h.usageRange(c)
+	if !ok {
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", services.GranularityDay)
+	periods, err := h.rollups.GetUsageRollups(granularity, from, to, loc)
+	if errors.Is(err, services.ErrInvalidGranularity) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get usage rollups",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	usage, err := h.rollups.GetDailyUsageBetween(from, to, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get daily usage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"days":        usage,
+		"count":       len(usage),
+		"granularity": granularity,
+		"timezone":    loc.String(),
+		"from":        from,
+		"to":          to,
+		"periods":     periods,
+	})
+}
+
+// usageRange reads the range of a usage report from ?from= and ?to=, or
+// ?days= (default 30) up to the end of today, in ?tz= or the configured
+// timezone. It responds with an error and returns false if they are invalid.
+func (h *UsageHandler) usageRange(c *gin.Context) (from, to time.Time, loc *time.Location, ok bool) {
+	days := 30
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 3650 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "days must be between 1 and 3650",
+			})
+			return from, to, loc, false
+		}
+		days = parsed
+	}
+
+	loc, err := parseLocation(c, h.rollups.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time zone",
+			"details": err.Error(),
+		})
+		return from, to, loc, false
+	}
+	from, to, err = parseNamedTimeRangeIn(c, "from", "to", loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid time range",
+			"details": err.Error(),
+		})
+		return from, to, loc, false
+	}
+	if to.IsZero() {
+		now := time.Now().In(loc)
+		to = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -days-1)
+	}
+	if !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid time range",
+		})
+		return from, to, loc, false
+	}
+	return from, to, loc, true
+}
+
+// GetUsageHeatmap returns tokens, messages and cost by weekday and hour of
+// the day over the same range as GetDailyUsage, with the busiest hour
+func (h *UsageHandler) GetUsageHeatmap(c *gin.Context) {
+	from, to, loc, ok := h.usageRange(c)
+	if !ok {
+		return
+	}
+
+	heatmap, err := h.rollups.GetUsageHeatmap(from, to, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get usage heatmap",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, heatmap)
+}
+
+// GetProjectUsage returns per-project totals
+func (h *UsageHandler) GetProjectUsage(c *gin.Context) {
+	usage, err := h.rollups.GetProjectUsage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get project usage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"projects": usage,
+		"count":    len(usage),
+	})
+}
+
+// RebuildRollups recomputes all rollups from scratch
+func (h *UsageHandler) RebuildRollups(c *gin.Context) {
+	if err := h.writes.Do(h.rollups.Rebuild); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to rebuild rollups",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Rollups rebuilt",
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// userScopeKey is the gin context key of the only user whose data a request may read
+const userScopeKey = "user_scope"
+
+// UserScope limits requests signed in with one of a user's logins to that
+// user's data. Admins are not limited and choose a user with ?user=.
+func UserScope(logins map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID, ok := logins[c.GetString(auth.UserKey)]; ok && !auth.IsAdmin(c) {
+			c.Set(userScopeKey, userID)
+		}
+		c.Next()
+	}
+}
+
+// RejectUserScope refuses scoped requests to routes whose data covers every
+// user, such as plan windows, budgets and the usage rollups
+func RejectUserScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(userScopeKey) != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Not available to a user's login",
+				"details": "this endpoint covers every user's usage",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requestUser returns the user whose data a request reads: the scoped user,
+// else ?user=, else empty for everyone
+func requestUser(c *gin.Context) string {
+	if userID := c.GetString(userScopeKey); userID != "" {
+		return userID
+	}
+	return c.Query("user")
+}
+
+// UserHandler lists users and guards per-session routes
+type UserHandler struct {
+	users *services.UserService
+}
+
+func NewUserHandler(users *services.UserService) *UserHandler {
+	return &UserHandler{users: users}
+}
+
+// GetUsers lists users with their totals; a scoped request only sees its own user
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	users, err := h.users.GetUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get users",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if scoped := c.GetString(userScopeKey); scoped != "" {
+		visible := []services.UserSummary{}
+		for _, u := range users {
+			if u.ID == scoped {
+				visible = append(visible, u)
+			}
+		}
+		users = visible
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"count": len(users),
+	})
+}
+
+// RequireSessionOwner answers 404 for the :id session of another user, so a
+// scoped request cannot tell whether it exists
+func (h *UserHandler) RequireSessionOwner() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := requestUser(c)
+		if userID == "" {
+			c.Next()
+			return
+		}
+		owns, err := h.users.OwnsSession(userID, c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to get session",
+				"details": err.Error(),
+			})
+			return
+		}
+		if !owns {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"error": "Session not found",
+			})
+			return
+		}
+		c.Next()
+	}
+}
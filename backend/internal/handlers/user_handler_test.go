@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"claudeee-backend/internal/auth"
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUserScope(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, user_id) VALUES
+			('a1', 'api', '/work/api', '2024-03-01 09:00:00', 'alice'),
+			('b1', 'web', '/work/web', '2024-03-02 09:00:00', 'bob');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+	users := services.NewUserService(db)
+	if err := users.Register([]services.User{{ID: "alice", Name: "Alice"}, {ID: "bob", Name: "Bob"}}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	h := NewUserHandler(users)
+	r := gin.New()
+	// Stands in for the authenticator: ?as= signs in, admins via ?admin=1
+	r.Use(func(c *gin.Context) {
+		c.Set(auth.UserKey, c.Query("as"))
+		role := auth.RoleViewer
+		if c.Query("admin") == "1" {
+			role = auth.RoleAdmin
+		}
+		c.Set(auth.RoleKey, role)
+	})
+	r.Use(UserScope(map[string]string{"bob@example.com": "bob"}))
+	r.GET("/api/users", h.GetUsers)
+	r.GET("/api/sessions/:id", h.RequireSessionOwner(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "user": requestUser(c)})
+	})
+	r.GET("/api/session-windows", RejectUserScope(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"windows": []string{}})
+	})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// Bob only sees himself and his sessions, whatever ?user= says
+	var body struct {
+		Users []services.UserSummary `json:"users"`
+	}
+	w := get("/api/users?as=bob@example.com")
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Users) != 1 || body.Users[0].ID != "bob" {
+		t.Errorf("Expected only bob, got %s", w.Body.String())
+	}
+	if w := get("/api/sessions/a1?as=bob@example.com&user=alice"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for alice's session, got %d", w.Code)
+	}
+	if w := get("/api/sessions/b1?as=bob@example.com"); w.Code != http.StatusOK {
+		t.Errorf("Expected bob's session, got %d", w.Code)
+	}
+
+	// Admins and unmapped logins see everyone and may pick a user
+	w = get("/api/users?as=bob@example.com&admin=1")
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || len(body.Users) != 2 {
+		t.Errorf("Expected both users for an admin, got %s", w.Body.String())
+	}
+	if w := get("/api/sessions/a1?as=someone"); w.Code != http.StatusOK {
+		t.Errorf("Expected an unscoped request to see a1, got %d", w.Code)
+	}
+	if w := get("/api/sessions/a1?user=bob"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected ?user=bob to hide a1, got %d", w.Code)
+	}
+
+	// Endpoints covering every user are closed to scoped logins only
+	if w := get("/api/session-windows?as=bob@example.com"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a scoped login, got %d", w.Code)
+	}
+	if w := get("/api/session-windows?as=bob@example.com&admin=1"); w.Code != http.StatusOK {
+		t.Errorf("Expected an admin to see session windows, got %d", w.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// WatcherHandler controls the log file watcher
+type WatcherHandler struct {
+	watcher *services.LogWatcher
+}
+
+func NewWatcherHandler(watcher *services.LogWatcher) *WatcherHandler {
+	return &WatcherHandler{watcher: watcher}
+}
+
+// GetStatus reports whether the watcher is running
+func (h *WatcherHandler) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.watcher.Status())
+}
+
+// Start begins watching the log directories
+func (h *WatcherHandler) Start(c *gin.Context) {
+	if err := h.watcher.Start(); err != nil && !errors.Is(err, services.ErrWatcherRunning) {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to start log watcher",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, h.watcher.Status())
+}
+
+// Stop stops watching; logs are then only synced on request or by the scheduler
+func (h *WatcherHandler) Stop(c *gin.Context) {
+	h.watcher.Stop()
+	c.JSON(http.StatusOK, h.watcher.Status())
+}
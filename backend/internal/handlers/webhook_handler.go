@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler serves the outbound webhook configuration
+type WebhookHandler struct {
+	webhooks *services.WebhookService
+}
+
+func NewWebhookHandler(webhooks *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks}
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	// Enabled defaults to true
+	Enabled *bool `json:"enabled"`
+}
+
+func (r webhookRequest) webhook() services.Webhook {
+	w := services.Webhook{URL: r.URL, Secret: r.Secret, Events: r.Events, Enabled: true}
+	if r.Enabled != nil {
+		w.Enabled = *r.Enabled
+	}
+	return w
+}
+
+// webhookError responds to a failed webhook operation
+func webhookError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrInvalidWebhook) {
+		status = http.StatusBadRequest
+	} else if errors.Is(err, services.ErrWebhookNotFound) {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+// GetWebhooks lists webhooks without their secrets
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	webhooks, err := h.webhooks.List()
+	if err != nil {
+		webhookError(c, "Failed to get webhooks", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks":    webhooks,
+		"count":       len(webhooks),
+		"event_types": services.WebhookEventTypes,
+	})
+}
+
+// CreateWebhook adds a webhook. The response is the only one that includes
+// the signing secret.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	created, err := h.webhooks.Create(req.webhook())
+	if err != nil {
+		webhookError(c, "Failed to create webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateWebhook replaces a webhook; an empty secret keeps the current one
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	updated, err := h.webhooks.Update(c.Param("id"), req.webhook())
+	if err != nil {
+		webhookError(c, "Failed to update webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteWebhook removes a webhook with its delivery history
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.webhooks.Delete(id); err != nil {
+		webhookError(c, "Failed to delete webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook deleted",
+		"id":      id,
+	})
+}
+
+// GetDeliveries returns a webhook's recent deliveries, newest first (?limit=)
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.webhooks.Get(id); err != nil {
+		webhookError(c, "Failed to get deliveries", err)
+		return
+	}
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > services.MaxWebhookDeliveryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid limit",
+				"details": "limit must be between 1 and " + strconv.Itoa(services.MaxWebhookDeliveryLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.webhooks.Deliveries(id, limit)
+	if err != nil {
+		webhookError(c, "Failed to get deliveries", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
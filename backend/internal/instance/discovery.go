@@ -0,0 +1,80 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const discoveryFile = "server.json"
+
+// ServerInfo is written to ~/.claudeee/server.json so the CLI and frontend can
+// find the address the running server actually bound to
+type ServerInfo struct {
+	PID       int       `json:"pid"`
+	Port      int       `json:"port"`
+	URL       string    `json:"url"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// DiscoveryPath returns the location of the discovery file inside dataDir
+func DiscoveryPath(dataDir string) string {
+	return filepath.Join(dataDir, discoveryFile)
+}
+
+// WriteServerInfo atomically writes the discovery file
+func WriteServerInfo(dataDir string, info ServerInfo) error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode server info: %w", err)
+	}
+
+	path := DiscoveryPath(dataDir)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write server info: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write server info: %w", err)
+	}
+	return nil
+}
+
+// ReadServerInfo reads the discovery file. It returns nil when no file exists.
+func ReadServerInfo(dataDir string) (*ServerInfo, error) {
+	data, err := os.ReadFile(DiscoveryPath(dataDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server info: %w", err)
+	}
+
+	var info ServerInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse server info: %w", err)
+	}
+	return &info, nil
+}
+
+// RemoveServerInfo deletes the discovery file if it still belongs to pid
+func RemoveServerInfo(dataDir string, pid int) error {
+	info, err := ReadServerInfo(dataDir)
+	if err != nil || info == nil {
+		return err
+	}
+	if info.PID != pid {
+		return nil
+	}
+	if err := os.Remove(DiscoveryPath(dataDir)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove server info: %w", err)
+	}
+	return nil
+}
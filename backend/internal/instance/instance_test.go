@@ -0,0 +1,169 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestListen_FallsBackWhenPortInUse(t *testing.T) {
+	taken, err := Listen("127.0.0.1", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to bind initial listener: %v", err)
+	}
+	defer taken.Close()
+	port := ListenerPort(taken)
+
+	listener, err := Listen("127.0.0.1", port, 10)
+	if err != nil {
+		t.Fatalf("Expected fallback to succeed, got %v", err)
+	}
+	defer listener.Close()
+
+	got := ListenerPort(listener)
+	if got == port {
+		t.Errorf("Expected a different port than %d", port)
+	}
+	if got < port || got > port+10 {
+		t.Errorf("Expected fallback port within range, got %d", got)
+	}
+}
+
+func TestListen_NoFallback(t *testing.T) {
+	taken, err := Listen("127.0.0.1", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to bind initial listener: %v", err)
+	}
+	defer taken.Close()
+
+	if _, err := Listen("127.0.0.1", ListenerPort(taken), 0); err == nil {
+		t.Error("Expected an error when fallback is disabled")
+	}
+}
+
+func TestServerInfoRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	info, err := ReadServerInfo(dir)
+	if err != nil || info != nil {
+		t.Fatalf("Expected no server info, got %v, %v", info, err)
+	}
+
+	written := ServerInfo{PID: os.Getpid(), Port: 8081, URL: "http://localhost:8081", StartedAt: time.Now().UTC()}
+	if err := WriteServerInfo(dir, written); err != nil {
+		t.Fatalf("WriteServerInfo failed: %v", err)
+	}
+
+	info, err = ReadServerInfo(dir)
+	if err != nil {
+		t.Fatalf("ReadServerInfo failed: %v", err)
+	}
+	if info.Port != 8081 || info.PID != written.PID {
+		t.Errorf("Unexpected server info: %+v", info)
+	}
+
+	// A different PID must not remove another instance's file
+	RemoveServerInfo(dir, written.PID+1)
+	if info, _ := ReadServerInfo(dir); info == nil {
+		t.Error("Server info removed by a foreign PID")
+	}
+
+	RemoveServerInfo(dir, written.PID)
+	if info, _ := ReadServerInfo(dir); info != nil {
+		t.Error("Expected server info to be removed")
+	}
+}
+
+func TestAcquireLock_Exclusive(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := AcquireLock(dir)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+
+	// Simulate another live process holding the lock (our parent)
+	os.WriteFile(LockPath(dir), []byte(strconv.Itoa(os.Getppid())), 0644)
+	if _, err := AcquireLock(dir); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("Expected ErrAlreadyRunning, got %v", err)
+	}
+
+	// Lock owned by someone else must not be released by us
+	lock.Release()
+	if _, err := os.Stat(LockPath(dir)); err != nil {
+		t.Error("Lock file of another process was removed")
+	}
+}
+
+func TestAcquireLock_TakesOverStaleLock(t *testing.T) {
+	dir := t.TempDir()
+
+	// PIDs are far below this on every supported platform
+	os.WriteFile(LockPath(dir), []byte("2147483000"), 0644)
+
+	lock, err := AcquireLock(dir)
+	if err != nil {
+		t.Fatalf("Expected stale lock to be taken over, got %v", err)
+	}
+
+	running, err := Inspect(dir)
+	if err != nil || running == nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if running.PID != os.Getpid() || !running.Alive {
+		t.Errorf("Unexpected running instance: %+v", running)
+	}
+
+	lock.Release()
+	if running, _ := Inspect(dir); running != nil {
+		t.Error("Expected lock to be released")
+	}
+}
+
+func TestInspect_VerifiesPID(t *testing.T) {
+	dir := t.TempDir()
+	pid := os.Getpid()
+	reported := pid
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"status":"healthy","pid":%d}`, reported)
+	}))
+	defer server.Close()
+
+	lock, err := AcquireLock(dir)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	defer lock.Release()
+	if err := WriteServerInfo(dir, ServerInfo{PID: pid, URL: server.URL}); err != nil {
+		t.Fatalf("WriteServerInfo failed: %v", err)
+	}
+
+	running, err := Inspect(dir)
+	if err != nil || running == nil || !running.Healthy || !running.Verified {
+		t.Fatalf("Expected a verified instance, got %+v, %v", running, err)
+	}
+
+	// Something else answering at the address does not verify the lock
+	reported = pid + 1
+	running, _ = Inspect(dir)
+	if !running.Healthy || running.Verified {
+		t.Errorf("Expected an unverified instance, got %+v", running)
+	}
+
+	// Only the PID the lock names is removed
+	RemoveStaleLock(dir, pid+1)
+	if _, err := os.Stat(LockPath(dir)); err != nil {
+		t.Error("Lock removed for a different PID")
+	}
+	if err := RemoveStaleLock(dir, pid); err != nil {
+		t.Fatalf("RemoveStaleLock failed: %v", err)
+	}
+	if running, _ := Inspect(dir); running != nil {
+		t.Error("Expected the stale lock to be removed")
+	}
+}
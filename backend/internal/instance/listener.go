@@ -0,0 +1,41 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// Listen binds a TCP listener on port. If the port is already in use it tries
+// the following ports, up to fallbackAttempts additional ports. A
+// fallbackAttempts of zero disables the fallback.
+func Listen(host string, port, fallbackAttempts int) (net.Listener, error) {
+	var lastErr error
+	for offset := 0; offset <= fallbackAttempts; offset++ {
+		candidate := port + offset
+		if candidate > 65535 {
+			break
+		}
+
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(candidate)))
+		if err == nil {
+			return listener, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return nil, fmt.Errorf("failed to listen on port %d: %w", candidate, err)
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("no free port found in range %d-%d: %w", port, port+fallbackAttempts, lastErr)
+}
+
+// ListenerPort returns the TCP port a listener is bound to
+func ListenerPort(listener net.Listener) int {
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
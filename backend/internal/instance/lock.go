@@ -0,0 +1,181 @@
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lockFile = "claudeee.lock"
+
+// ErrAlreadyRunning is returned by AcquireLock when another live process holds the lock
+var ErrAlreadyRunning = errors.New("claudeee is already running")
+
+// Lock is an exclusive lock on the data directory held by the running server
+type Lock struct {
+	path string
+	pid  int
+}
+
+// RunningInstance describes the process currently holding the lock
+type RunningInstance struct {
+	PID     int
+	Alive   bool
+	Healthy bool
+	// Verified is set when the server at Info.URL reports PID as its own, so
+	// the lock really names a running claudeee and not a reused PID
+	Verified bool
+	Info     *ServerInfo
+}
+
+// LockPath returns the location of the lock file inside dataDir
+func LockPath(dataDir string) string {
+	return filepath.Join(dataDir, lockFile)
+}
+
+// AcquireLock takes the single-instance lock. A lock left behind by a process
+// that no longer exists is removed and taken over.
+func AcquireLock(dataDir string) (*Lock, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	path := LockPath(dataDir)
+	pid := os.Getpid()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, werr := file.WriteString(strconv.Itoa(pid))
+			file.Close()
+			if werr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file: %w", werr)
+			}
+			return &Lock{path: path, pid: pid}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		holder, err := readLockPID(path)
+		if err == nil && holder != pid && processAlive(holder) {
+			return nil, fmt.Errorf("%w (pid %d)", ErrAlreadyRunning, holder)
+		}
+
+		// Stale lock from a crashed process
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to acquire lock file %s", path)
+}
+
+// Release removes the lock file if it is still owned by this process
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	holder, err := readLockPID(l.path)
+	if err != nil || holder != l.pid {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release lock file: %w", err)
+	}
+	return nil
+}
+
+// Inspect reports on the instance holding the lock in dataDir, or nil if none
+func Inspect(dataDir string) (*RunningInstance, error) {
+	pid, err := readLockPID(LockPath(dataDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	running := &RunningInstance{PID: pid, Alive: processAlive(pid)}
+
+	info, err := ReadServerInfo(dataDir)
+	if err == nil && info != nil && info.PID == pid {
+		running.Info = info
+		reported, healthy := Ping(info.URL, 2*time.Second)
+		running.Healthy = healthy
+		running.Verified = healthy && reported == pid
+	}
+	return running, nil
+}
+
+// Ping reports whether the server at baseURL answers its health endpoint, and
+// the PID it reports. The PID is 0 for servers that do not report one.
+func Ping(baseURL string, timeout time.Duration) (int, bool) {
+	client := &http.Client{Timeout: timeout}
+	// The unversioned path also answers on instances older than /api/v1
+	resp, err := client.Get(strings.TrimRight(baseURL, "/") + "/api/health")
+	if err != nil {
+		return 0, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false
+	}
+	var health struct {
+		PID int `json:"pid"`
+	}
+	json.NewDecoder(resp.Body).Decode(&health)
+	return health.PID, true
+}
+
+// RemoveStaleLock deletes the lock file if it still names pid. Use it for a
+// lock whose holder could not be verified, instead of stopping that process.
+func RemoveStaleLock(dataDir string, pid int) error {
+	path := LockPath(dataDir)
+	holder, err := readLockPID(path)
+	if err != nil || holder != pid {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale lock file: %w", err)
+	}
+	return nil
+}
+
+// Terminate asks pid to exit and waits until it is gone or timeout elapses
+func Terminate(pid int, timeout time.Duration) error {
+	if !processAlive(pid) {
+		return nil
+	}
+	if err := terminateProcess(pid); err != nil {
+		return fmt.Errorf("failed to stop process %d: %w", pid, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !processAlive(pid) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("process %d did not exit within %v", pid, timeout)
+}
+
+func readLockPID(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid lock file contents: %w", err)
+	}
+	return pid, nil
+}
@@ -0,0 +1,17 @@
+package instance
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// NewProxy returns a handler forwarding every request to the running instance
+func NewProxy(targetURL string) (http.Handler, error) {
+	target, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy target: %w", err)
+	}
+	return httputil.NewSingleHostReverseProxy(target), nil
+}
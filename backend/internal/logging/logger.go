@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Log output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", value)
+	}
+	return level, nil
+}
+
+// NewLogger creates a logger that writes records at level and above to w as
+// text or JSON
+func NewLogger(w io.Writer, level slog.Level, format string) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q (expected text or json)", format)
+}
+
+// Setup makes a logger for w the default for slog and the log package, so
+// messages from both share the level filter and format
+func Setup(w io.Writer, level, format string) error {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logger, err := NewLogger(w, parsed, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	// log.Printf output becomes info records
+	log.SetFlags(0)
+	return nil
+}
+
+// Component returns the default logger with a component field, such as sync
+// or webhooks, for filtering
+func Component(name string) *slog.Logger {
+	return slog.Default().With("component", name)
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for value, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		level, err := ParseLevel(value)
+		if err != nil || level != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", value, level, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+	if _, err := NewLogger(&bytes.Buffer{}, slog.LevelInfo, "xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestSetupJSON(t *testing.T) {
+	previous, flags := slog.Default(), log.Flags()
+	defer func() {
+		slog.SetDefault(previous)
+		log.SetFlags(flags)
+	}()
+
+	var buf bytes.Buffer
+	if err := Setup(&buf, "warn", FormatJSON); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	Component("sync").Info("Dropped")
+	Component("sync").Warn("Failed to stat log file", "file", "a.jsonl")
+	log.Printf("Server starting on :%d", 8080)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the warning, got %q", buf.String())
+	}
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Expected a JSON record: %v", err)
+	}
+	if record["level"] != "WARN" || record["component"] != "sync" || record["file"] != "a.jsonl" {
+		t.Errorf("Unexpected record %v", record)
+	}
+
+	// log package output is an info record
+	buf.Reset()
+	if err := Setup(&buf, "info", FormatText); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	log.Printf("Server starting on :%d", 8080)
+	if out := buf.String(); !strings.Contains(out, "level=INFO") || !strings.Contains(out, `msg="Server starting on :8080"`) {
+		t.Errorf("Unexpected log output %q", out)
+	}
+}
@@ -0,0 +1,198 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102-150405"
+
+// RotatingWriter is an io.WriteCloser that writes to a log file and rotates it
+// when it grows past MaxSize or when the calendar day changes. Rotated files are
+// renamed with a timestamp suffix and pruned according to MaxBackups and MaxAge.
+type RotatingWriter struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedOn time.Time
+	now      func() time.Time
+}
+
+// NewRotatingWriter opens (or creates) the log file at path.
+// maxSizeMB, maxAgeDays and maxBackups disable the respective limit when zero.
+func NewRotatingWriter(path string, maxSizeMB, maxAgeDays, maxBackups int) (*RotatingWriter, error) {
+	w := &RotatingWriter{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxAge:     time.Duration(maxAgeDays) * 24 * time.Hour,
+		maxBackups: maxBackups,
+		now:        time.Now,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	if err := w.openExisting(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends p to the current log file, rotating first if needed
+func (w *RotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.openNew(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close closes the current log file
+func (w *RotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *RotatingWriter) shouldRotate(incoming int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.maxSize > 0 && w.size+incoming > w.maxSize {
+		return true
+	}
+	return !sameDay(w.openedOn, w.now())
+}
+
+func (w *RotatingWriter) openExisting() error {
+	info, err := os.Stat(w.path)
+	if os.IsNotExist(err) {
+		return w.openNew()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	w.openedOn = info.ModTime()
+	return nil
+}
+
+func (w *RotatingWriter) openNew() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	w.file = file
+	w.size = 0
+	w.openedOn = w.now()
+	return nil
+}
+
+func (w *RotatingWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+		w.file = nil
+	}
+
+	backupPath := w.backupName(w.now())
+	if err := os.Rename(w.path, backupPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := w.openNew(); err != nil {
+		return err
+	}
+
+	w.prune()
+	return nil
+}
+
+func (w *RotatingWriter) backupName(t time.Time) string {
+	ext := filepath.Ext(w.path)
+	base := strings.TrimSuffix(w.path, ext)
+	name := fmt.Sprintf("%s-%s%s", base, t.Format(backupTimeFormat), ext)
+
+	// Avoid clobbering a backup created within the same second
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%s.%d%s", base, t.Format(backupTimeFormat), i, ext)
+	}
+}
+
+// backups returns rotated log files, newest first
+func (w *RotatingWriter) backups() ([]string, error) {
+	ext := filepath.Ext(w.path)
+	pattern := strings.TrimSuffix(w.path, ext) + "-*" + ext
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+	return matches, nil
+}
+
+// prune removes rotated files beyond the retention limits
+func (w *RotatingWriter) prune() {
+	files, err := w.backups()
+	if err != nil {
+		return
+	}
+
+	cutoff := w.now().Add(-w.maxAge)
+	for i, file := range files {
+		remove := w.maxBackups > 0 && i >= w.maxBackups
+		if !remove && w.maxAge > 0 {
+			if info, err := os.Stat(file); err == nil && info.ModTime().Before(cutoff) {
+				remove = true
+			}
+		}
+		if remove {
+			os.Remove(file)
+		}
+	}
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
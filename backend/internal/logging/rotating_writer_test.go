@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingWriter_RotatesOnSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+
+	w, err := NewRotatingWriter(path, 1, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	chunk := []byte(strings.Repeat("x", 600*1024))
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	backups, err := w.backups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 rotated files, got %d", len(backups))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Current log file missing: %v", err)
+	}
+	if info.Size() != int64(len(chunk)) {
+		t.Errorf("Expected current log size %d, got %d", len(chunk), info.Size())
+	}
+}
+
+func TestRotatingWriter_RotatesOnDayChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+
+	w, err := NewRotatingWriter(path, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	current := time.Date(2025, 7, 1, 23, 59, 0, 0, time.Local)
+	w.now = func() time.Time { return current }
+	w.openedOn = current
+
+	w.Write([]byte("day one\n"))
+	current = current.Add(2 * time.Minute)
+	w.Write([]byte("day two\n"))
+
+	backups, _ := w.backups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected 1 rotated file, got %d", len(backups))
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "day two\n" {
+		t.Errorf("Expected current log to contain only day two, got %q", string(data))
+	}
+}
+
+func TestRotatingWriter_PrunesBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+
+	w, err := NewRotatingWriter(path, 1, 0, 2)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	defer w.Close()
+
+	current := time.Date(2025, 7, 1, 10, 0, 0, 0, time.Local)
+	w.now = func() time.Time { return current }
+	w.openedOn = current
+
+	chunk := []byte(strings.Repeat("x", 700*1024))
+	for i := 0; i < 5; i++ {
+		current = current.Add(time.Second)
+		w.Write(chunk)
+	}
+
+	backups, _ := w.backups()
+	if len(backups) != 2 {
+		t.Errorf("Expected backups to be pruned to 2, got %d", len(backups))
+	}
+}
@@ -0,0 +1,195 @@
+// Package mcp serves tools over the Model Context Protocol's stdio
+// transport: JSON-RPC 2.0 messages, one per line.
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ProtocolVersion is the newest protocol version the server speaks
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the protocol versions a client may ask for
+var supportedVersions = []string{"2024-11-05", "2025-03-26", ProtocolVersion}
+
+// maxMessageSize bounds one line read from the client
+const maxMessageSize = 4 << 20
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// Tool is a function the client can call. Handler receives the call's
+// arguments and returns a value sent back as JSON text; an error is reported
+// to the client as a failed call rather than a protocol error.
+type Tool struct {
+	Name        string                                                                    `json:"name"`
+	Description string                                                                    `json:"description"`
+	InputSchema map[string]interface{}                                                    `json:"inputSchema"`
+	Handler     func(ctx context.Context, arguments json.RawMessage) (interface{}, error) `json:"-"`
+}
+
+// Server answers a client's requests for its tools
+type Server struct {
+	name    string
+	version string
+	tools   []Tool
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewServer(name, version string) *Server {
+	return &Server{name: name, version: version}
+}
+
+// AddTool offers tool to the client
+func (s *Server) AddTool(tool Tool) {
+	if tool.InputSchema == nil {
+		tool.InputSchema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+	}
+	s.tools = append(s.tools, tool)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// textContent is a tool result's text
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError,omitempty"`
+}
+
+// Serve answers the requests read from r on w until r ends or ctx is done
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.w = w
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxMessageSize)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.send(response{ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+		// Notifications, such as notifications/initialized, get no response
+		if len(req.ID) == 0 {
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			s.send(response{ID: req.ID, Error: &rpcError{Code: codeInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}})
+			continue
+		}
+		result, rpcErr := s.handle(ctx, req)
+		s.send(response{ID: req.ID, Result: result, Error: rpcErr})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read MCP request: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handle(ctx context.Context, req request) (interface{}, *rpcError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := ProtocolVersion
+		for _, supported := range supportedVersions {
+			if params.ProtocolVersion == supported {
+				version = supported
+			}
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		}, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": s.tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		for _, tool := range s.tools {
+			if tool.Name == params.Name {
+				return callTool(ctx, tool, params.Arguments), nil
+			}
+		}
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+}
+
+func callTool(ctx context.Context, tool Tool, arguments json.RawMessage) toolResult {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+	value, err := tool.Handler(ctx, arguments)
+	if err != nil {
+		return toolResult{Content: []textContent{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+	text, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return toolResult{Content: []textContent{{Type: "text", Text: err.Error()}}, IsError: true}
+	}
+	return toolResult{Content: []textContent{{Type: "text", Text: string(text)}}}
+}
+
+// send writes one response line
+func (s *Server) send(resp response) {
+	resp.JSONRPC = "2.0"
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(response{JSONRPC: "2.0", ID: resp.ID, Error: &rpcError{Code: codeInternalError, Message: err.Error()}})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,103 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// serve runs the server over the request lines and returns its responses
+func serve(t *testing.T, server *Server, lines ...string) []map[string]interface{} {
+	t.Helper()
+	var out bytes.Buffer
+	if err := server.Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")+"\n"), &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	var responses []map[string]interface{}
+	decoder := json.NewDecoder(&out)
+	for decoder.More() {
+		var resp map[string]interface{}
+		if err := decoder.Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestServe(t *testing.T) {
+	server := NewServer("test", "v1")
+	server.AddTool(Tool{
+		Name: "echo",
+		Handler: func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+			var args struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(arguments, &args)
+			if args.Text == "" {
+				return nil, errors.New("text is required")
+			}
+			return map[string]string{"text": args.Text}, nil
+		},
+	})
+
+	responses := serve(t, server,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"echo"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"missing"}}`,
+		`{"jsonrpc":"2.0","id":"six","method":"resources/list"}`,
+		`not json`,
+	)
+	// The notification gets no response
+	if len(responses) != 7 {
+		t.Fatalf("Expected 7 responses, got %d: %v", len(responses), responses)
+	}
+
+	initialize := responses[0]["result"].(map[string]interface{})
+	if initialize["protocolVersion"] != "2024-11-05" {
+		t.Errorf("Expected the client's protocol version, got %v", initialize["protocolVersion"])
+	}
+	if info := initialize["serverInfo"].(map[string]interface{}); info["name"] != "test" {
+		t.Errorf("Unexpected server info %v", info)
+	}
+
+	tools := responses[1]["result"].(map[string]interface{})["tools"].([]interface{})
+	if len(tools) != 1 || tools[0].(map[string]interface{})["name"] != "echo" || tools[0].(map[string]interface{})["inputSchema"] == nil {
+		t.Errorf("Unexpected tools %v", tools)
+	}
+
+	call := responses[2]["result"].(map[string]interface{})
+	text := call["content"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	if call["isError"] != nil || !strings.Contains(text, `"text": "hi"`) {
+		t.Errorf("Unexpected tool result %v", call)
+	}
+
+	// A failing tool is a result, not a protocol error
+	failed := responses[3]["result"].(map[string]interface{})
+	if failed["isError"] != true || failed["content"].([]interface{})[0].(map[string]interface{})["text"] != "text is required" {
+		t.Errorf("Expected a failed call, got %v", responses[3])
+	}
+
+	for i, code := range map[int]float64{4: codeInvalidParams, 5: codeMethodNotFound, 6: codeParseError} {
+		rpcErr, ok := responses[i]["error"].(map[string]interface{})
+		if !ok || rpcErr["code"] != code {
+			t.Errorf("Expected error %v in response %d, got %v", code, i, responses[i])
+		}
+	}
+	if responses[5]["id"] != "six" {
+		t.Errorf("Expected the request's id, got %v", responses[5]["id"])
+	}
+}
+
+func TestServeUnknownProtocolVersion(t *testing.T) {
+	responses := serve(t, NewServer("test", "v1"), `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"1999-01-01"}}`)
+	if version := responses[0]["result"].(map[string]interface{})["protocolVersion"]; version != ProtocolVersion {
+		t.Errorf("Expected %s, got %v", ProtocolVersion, version)
+	}
+}
@@ -0,0 +1,212 @@
+// Package metrics exposes token usage, sync and API figures in the Prometheus
+// text format so claudeee can be scraped into an existing monitoring stack.
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "claudeee"
+
+// Metrics holds the collectors of one server
+type Metrics struct {
+	registry        *prometheus.Registry
+	syncDuration    prometheus.Histogram
+	syncRuns        *prometheus.CounterVec
+	syncedLines     prometheus.Counter
+	parseErrors     prometheus.Counter
+	requestDuration *prometheus.HistogramVec
+}
+
+// New creates the metrics. Token totals are read from db and window figures
+// from usage when scraped, so they are always current.
+func New(db *sql.DB, usage func() (*models.TokenUsage, error)) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		syncDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sync_duration_seconds",
+			Help:      "Duration of log sync passes.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		}),
+		syncRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sync_runs_total",
+			Help:      "Log sync passes by result.",
+		}, []string{"status"}),
+		syncedLines: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sync_lines_total",
+			Help:      "Log lines ingested by sync passes.",
+		}),
+		parseErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sync_parse_errors_total",
+			Help:      "Log lines that could not be parsed.",
+		}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "API request latency by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+
+	m.registry.MustRegister(
+		m.syncDuration,
+		m.syncRuns,
+		m.syncedLines,
+		m.parseErrors,
+		m.requestDuration,
+		&tokenCollector{db: db},
+		&windowCollector{usage: usage},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// SyncFinished records a finished sync job
+func (m *Metrics) SyncFinished(job services.SyncJob) {
+	m.syncRuns.WithLabelValues(job.Status).Inc()
+	if job.Stats == nil {
+		return
+	}
+	m.syncDuration.Observe(job.Stats.ProcessingTime.Seconds())
+	m.syncedLines.Add(float64(job.Stats.NewLines))
+	m.parseErrors.Add(float64(job.Stats.ParseErrors))
+}
+
+// Middleware records the latency of every request. Routes are labelled by
+// their pattern, e.g. /api/sessions/:id, to keep the label set small.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return gin.WrapH(h)
+}
+
+var tokensDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "tokens_total"),
+	"Tokens recorded in the logs by model and token type.",
+	[]string{"model", "type"}, nil,
+)
+
+// tokenCollector reports token totals per model straight from the messages table
+type tokenCollector struct {
+	db *sql.DB
+}
+
+func (t *tokenCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tokensDesc
+}
+
+func (t *tokenCollector) Collect(ch chan<- prometheus.Metric) {
+	rows, err := t.db.Query(`
+		SELECT
+			COALESCE(model, 'unknown'),
+			COALESCE(SUM(input_tokens), 0),
+			COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_creation_input_tokens), 0),
+			COALESCE(SUM(cache_read_input_tokens), 0)
+		FROM messages
+		GROUP BY 1
+	`)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(tokensDesc, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var model string
+		var input, output, cacheCreation, cacheRead float64
+		if err := rows.Scan(&model, &input, &output, &cacheCreation, &cacheRead); err != nil {
+			ch <- prometheus.NewInvalidMetric(tokensDesc, err)
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(tokensDesc, prometheus.CounterValue, input, model, "input")
+		ch <- prometheus.MustNewConstMetric(tokensDesc, prometheus.CounterValue, output, model, "output")
+		ch <- prometheus.MustNewConstMetric(tokensDesc, prometheus.CounterValue, cacheCreation, model, "cache_creation")
+		ch <- prometheus.MustNewConstMetric(tokensDesc, prometheus.CounterValue, cacheRead, model, "cache_read")
+	}
+	if err := rows.Err(); err != nil {
+		ch <- prometheus.NewInvalidMetric(tokensDesc, err)
+	}
+}
+
+var (
+	windowTokensDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "window", "tokens"),
+		"Tokens used in the current session window.", nil, nil,
+	)
+	windowLimitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "window", "token_limit"),
+		"Token limit of the current session window for the configured plan.", nil, nil,
+	)
+	windowUtilizationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "window", "utilization_ratio"),
+		"Share of the window token limit used, from 0 to 1 or above when exceeded.", nil, nil,
+	)
+	windowCostDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "window", "cost_usd"),
+		"Estimated cost of the current session window in USD.", nil, nil,
+	)
+	windowSessionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "window", "active_sessions"),
+		"Sessions active in the current session window.", nil, nil,
+	)
+)
+
+// windowCollector reports the usage of the active session window
+type windowCollector struct {
+	usage func() (*models.TokenUsage, error)
+}
+
+func (w *windowCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- windowTokensDesc
+	ch <- windowLimitDesc
+	ch <- windowUtilizationDesc
+	ch <- windowCostDesc
+	ch <- windowSessionsDesc
+}
+
+func (w *windowCollector) Collect(ch chan<- prometheus.Metric) {
+	usage, err := w.usage()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(windowTokensDesc, err)
+		return
+	}
+
+	utilization := 0.0
+	if usage.UsageLimit > 0 {
+		utilization = float64(usage.TotalTokens) / float64(usage.UsageLimit)
+	}
+	ch <- prometheus.MustNewConstMetric(windowTokensDesc, prometheus.GaugeValue, float64(usage.TotalTokens))
+	ch <- prometheus.MustNewConstMetric(windowLimitDesc, prometheus.GaugeValue, float64(usage.UsageLimit))
+	ch <- prometheus.MustNewConstMetric(windowUtilizationDesc, prometheus.GaugeValue, utilization)
+	ch <- prometheus.MustNewConstMetric(windowCostDesc, prometheus.GaugeValue, usage.TotalCost)
+	ch <- prometheus.MustNewConstMetric(windowSessionsDesc, prometheus.GaugeValue, float64(usage.ActiveSessions))
+}
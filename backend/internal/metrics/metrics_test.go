@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/services"
+	"github.com/gin-gonic/gin"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE messages (
+			model VARCHAR,
+			input_tokens INTEGER,
+			output_tokens INTEGER,
+			cache_creation_input_tokens INTEGER,
+			cache_read_input_tokens INTEGER
+		);
+		INSERT INTO messages VALUES
+			('claude-sonnet-4', 100, 20, 5, 1),
+			('claude-sonnet-4', 50, 10, 0, 0),
+			(NULL, 7, 0, 0, 0);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create messages: %v", err)
+	}
+
+	m := New(db, func() (*models.TokenUsage, error) {
+		return &models.TokenUsage{TotalTokens: 4000, UsageLimit: 8000, TotalCost: 0.25, ActiveSessions: 2}, nil
+	})
+	m.SyncFinished(services.SyncJob{
+		Status: services.SyncJobCompleted,
+		Stats:  &models.SyncStats{NewLines: 12, ParseErrors: 3, ProcessingTime: 2 * time.Second},
+	})
+
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/api/sessions/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/metrics", m.Handler())
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/sessions/abc", nil))
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read metrics: %v", err)
+	}
+	text := string(body)
+
+	for _, want := range []string{
+		`claudeee_tokens_total{model="claude-sonnet-4",type="input"} 150`,
+		`claudeee_tokens_total{model="claude-sonnet-4",type="output"} 30`,
+		`claudeee_tokens_total{model="unknown",type="input"} 7`,
+		`claudeee_window_utilization_ratio 0.5`,
+		`claudeee_window_active_sessions 2`,
+		`claudeee_sync_runs_total{status="completed"} 1`,
+		`claudeee_sync_lines_total 12`,
+		`claudeee_sync_parse_errors_total 3`,
+		`claudeee_sync_duration_seconds_sum 2`,
+		`claudeee_http_request_duration_seconds_count{method="GET",route="/api/sessions/:id",status="200"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected metrics to contain %q", want)
+		}
+	}
+}
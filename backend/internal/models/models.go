@@ -17,6 +17,8 @@ type Session struct {
 	Status           string    `json:"status" db:"status"`
 	CreatedAt        time.Time `json:"created_at" db:"created_at"`
 	TotalCost        float64   `json:"total_cost" db:"total_cost"`
+	// Provider is the tool whose logs the session was read from
+	Provider         string    `json:"provider" db:"provider"`
 }
 
 type Message struct {
@@ -36,6 +38,12 @@ type Message struct {
 	OutputTokens             int       `json:"output_tokens" db:"output_tokens"`
 	ServiceTier              *string   `json:"service_tier" db:"service_tier"`
 	RequestID                *string   `json:"request_id" db:"request_id"`
+	// ContentHash is the SHA-256 of the log entry the message was read from
+	ContentHash              *string   `json:"-" db:"content_hash"`
+	// Billing is "api" when the log entry shows pay-as-you-go usage, or nil
+	Billing                  *string   `json:"-" db:"billing"`
+	// Provider is the tool whose log the message was read from
+	Provider                 string    `json:"provider" db:"provider"`
 	Timestamp                time.Time `json:"timestamp" db:"timestamp"`
 	CreatedAt                time.Time `json:"created_at" db:"created_at"`
 }
@@ -59,6 +67,11 @@ type SessionSummary struct {
 	IsActive        bool          `json:"is_active"`
 	LastActivity    time.Time     `json:"last_activity"`
 	GeneratedCode   []string      `json:"generated_code"`
+	Title           *string       `json:"title"`
+	Tags            []string      `json:"tags"`
+	Notes           *string       `json:"notes"`
+	// SourceMissingAt is when the session's last log file was found deleted
+	SourceMissingAt *time.Time    `json:"source_missing_at"`
 }
 
 type LogEntry struct {
@@ -73,6 +86,10 @@ type LogEntry struct {
 	RequestID    *string               `json:"requestId"`
 	UUID         string                `json:"uuid"`
 	Timestamp    time.Time             `json:"timestamp"`
+	// IsAPIErrorMessage marks an entry Claude Code wrote for a failed API call
+	IsAPIErrorMessage bool             `json:"isApiErrorMessage"`
+	// Provider is the tool that wrote the entry; empty for Claude Code
+	Provider     string                `json:"provider,omitempty"`
 }
 
 type LogMessage struct {
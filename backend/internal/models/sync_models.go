@@ -10,8 +10,13 @@ type FileProcessingState struct {
 	LastModified      time.Time `json:"last_modified" db:"last_modified"`
 	FileSize          int64     `json:"file_size" db:"file_size"`
 	LastProcessedLine int       `json:"last_processed_line" db:"last_processed_line"`
+	LastProcessedOffset int64   `json:"last_processed_offset" db:"last_processed_offset"`
 	ProcessedUntil    *time.Time `json:"processed_until" db:"processed_until"`
+	// Checksum is the SHA-256 of the first line, which changes when the file
+	// is rewritten in place
 	Checksum          *string   `json:"checksum" db:"checksum"`
+	// FileID is the device and inode, which change when the file is replaced
+	FileID            *string   `json:"file_id" db:"file_id"`
 	SyncStatus        string    `json:"sync_status" db:"sync_status"` // pending, processing, completed, error
 	LastSyncTime      time.Time `json:"last_sync_time" db:"last_sync_time"`
 	ErrorMessage      *string   `json:"error_message" db:"error_message"`
@@ -24,6 +29,8 @@ type FileInfo struct {
 	Path    string
 	ModTime time.Time
 	Size    int64
+	// ID identifies the file across renames; empty where unsupported
+	ID      string
 }
 
 // SyncStats represents synchronization statistics
@@ -31,7 +38,14 @@ type SyncStats struct {
 	TotalFiles       int           `json:"total_files"`
 	ProcessedFiles   int           `json:"processed_files"`
 	SkippedFiles     int           `json:"skipped_files"`
+	FailedFiles      int           `json:"failed_files"`
+	RemovedFiles     int           `json:"removed_files"`
 	NewLines         int           `json:"new_lines"`
+	DuplicateLines   int           `json:"duplicate_lines"`
+	Redactions       int           `json:"redactions"`
+	// RedactionsByKind splits Redactions by the kind of secret
+	RedactionsByKind map[string]int `json:"redactions_by_kind,omitempty"`
+	ParseErrors      int           `json:"parse_errors"`
 	ProcessingTime   time.Duration `json:"processing_time"`
 	StartTime        time.Time     `json:"start_time"`
 	EndTime          time.Time     `json:"end_time"`
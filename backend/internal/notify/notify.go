@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Timeout bounds how long showing one notification may take
+const Timeout = 10 * time.Second
+
+// ErrUnsupported is returned by New on systems without a known notifier
+var ErrUnsupported = errors.New("desktop notifications are not supported on this system")
+
+// Notifier shows desktop notifications
+type Notifier interface {
+	Notify(ctx context.Context, title, message string) error
+}
+
+// CommandNotifier shows notifications by running a command, such as
+// osascript or notify-send
+type CommandNotifier struct {
+	Command string
+	// Args returns the command's arguments for a notification
+	Args func(title, message string) []string
+}
+
+// New returns the notifier of the running system: osascript on macOS,
+// notify-send on Linux and the BSDs, and a PowerShell toast on Windows. It
+// fails when the command is not installed.
+func New() (*CommandNotifier, error) {
+	var n *CommandNotifier
+	switch runtime.GOOS {
+	case "darwin":
+		n = &CommandNotifier{Command: "osascript", Args: osascriptArgs}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		n = &CommandNotifier{Command: "notify-send", Args: notifySendArgs}
+	case "windows":
+		n = &CommandNotifier{Command: "powershell", Args: powershellArgs}
+	default:
+		return nil, fmt.Errorf("%w (%s)", ErrUnsupported, runtime.GOOS)
+	}
+	if _, err := exec.LookPath(n.Command); err != nil {
+		return nil, fmt.Errorf("%w: %s not found: %v", ErrUnsupported, n.Command, err)
+	}
+	return n, nil
+}
+
+// Notify runs the command, giving up after Timeout
+func (n *CommandNotifier) Notify(ctx context.Context, title, message string) error {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, n.Command, n.Args(title, message)...).CombinedOutput()
+	if err != nil {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return fmt.Errorf("failed to show notification: %w: %s", err, text)
+		}
+		return fmt.Errorf("failed to show notification: %w", err)
+	}
+	return nil
+}
+
+func osascriptArgs(title, message string) []string {
+	script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString(title))
+	return []string{"-e", script}
+}
+
+// appleScriptString quotes s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func notifySendArgs(title, message string) []string {
+	// -- keeps a title starting with a dash from being read as an option
+	return []string{"--app-name=claudeee", "--", title, message}
+}
+
+// powershellAppID is the application a toast is shown for; Windows only
+// shows toasts of registered applications, and PowerShell is one
+const powershellAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+func powershellArgs(title, message string) []string {
+	script := strings.Join([]string{
+		`[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null`,
+		`$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)`,
+		`$text = $template.GetElementsByTagName('text')`,
+		`$text.Item(0).AppendChild($template.CreateTextNode(` + powershellString(title) + `)) > $null`,
+		`$text.Item(1).AppendChild($template.CreateTextNode(` + powershellString(message) + `)) > $null`,
+		`[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier(` + powershellString(powershellAppID) + `).Show([Windows.UI.Notifications.ToastNotification]::new($template))`,
+	}, "; ")
+	return []string{"-NoProfile", "-NonInteractive", "-Command", script}
+}
+
+// powershellString quotes s as a verbatim PowerShell string literal
+func powershellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNotifierArgs(t *testing.T) {
+	title := `Usage at "80%"`
+	message := `It's C:\work`
+
+	if got := osascriptArgs(title, message); !reflect.DeepEqual(got, []string{"-e", `display notification "It's C:\\work" with title "Usage at \"80%\""`}) {
+		t.Errorf("Unexpected osascript arguments %q", got)
+	}
+	if got := notifySendArgs("-x", message); !reflect.DeepEqual(got, []string{"--app-name=claudeee", "--", "-x", message}) {
+		t.Errorf("Unexpected notify-send arguments %q", got)
+	}
+	script := powershellArgs(title, message)[3]
+	if !strings.Contains(script, `CreateTextNode('It''s C:\work')`) || !strings.Contains(script, `CreateTextNode('Usage at "80%"')`) {
+		t.Errorf("Expected quoted PowerShell strings, got %s", script)
+	}
+}
+
+func TestCommandNotifierReportsFailures(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	n := &CommandNotifier{Command: "sh", Args: func(title, message string) []string {
+		return []string{"-c", `echo "$1: $2" >&2; exit 1`, "sh", title, message}
+	}}
+	err := n.Notify(context.Background(), "title", "message")
+	if err == nil || !strings.Contains(err.Error(), "title: message") {
+		t.Errorf("Expected the command's output in the error, got %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+// Package offboard exports everything claudeee has stored into a single
+// archive and then deletes the data directory, for users leaving a machine or
+// exercising a right to erasure.
+package offboard
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrArchiveInsideDataDir is returned when the archive would be deleted by the wipe
+var ErrArchiveInsideDataDir = errors.New("archive must be written outside the data directory")
+
+// configFiles are the files from the data directory copied into the archive
+var configFiles = []string{"profile.json"}
+
+// Manifest describes an archive; it is stored in the archive as manifest.json
+type Manifest struct {
+	CreatedAt time.Time     `json:"created_at"`
+	Profile   string        `json:"profile"`
+	Tables    []TableExport `json:"tables"`
+	Files     []string      `json:"files"`
+	// SHA256 is the checksum of the archive file; it is not part of manifest.json
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// TableExport records how many rows of a table were exported
+type TableExport struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// DefaultArchivePath returns where to write the archive when none is given:
+// the home directory, outside any claudeee data directory
+func DefaultArchivePath(homeDir, profile string, now time.Time) string {
+	return filepath.Join(homeDir, fmt.Sprintf("claudeee-export-%s-%s.zip", profile, now.UTC().Format("20060102-150405")))
+}
+
+// Export writes a zip archive holding a DuckDB dump (schema.sql, load.sql and
+// one Parquet file per table), the profile's config files and a manifest.
+// The archive is read back and checked before Export returns, since callers
+// delete the originals next.
+func Export(db *sql.DB, dataDir, profile, archivePath string) (*Manifest, error) {
+	archivePath, err := filepath.Abs(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive path: %w", err)
+	}
+	if within(archivePath, dataDir) {
+		return nil, ErrArchiveInsideDataDir
+	}
+
+	tables, err := CountTables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	dumpDir, err := os.MkdirTemp("", "claudeee-export-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	defer os.RemoveAll(dumpDir)
+
+	// EXPORT DATABASE wants to create the directory itself
+	exportDir := filepath.Join(dumpDir, "database")
+	if _, err := db.Exec(fmt.Sprintf("EXPORT DATABASE '%s' (FORMAT PARQUET)", strings.ReplaceAll(exportDir, "'", "''"))); err != nil {
+		return nil, fmt.Errorf("failed to export database: %w", err)
+	}
+	// load.sql refers to the Parquet files by absolute path; make it relative
+	// to the database directory so the dump can be restored anywhere
+	loadPath := filepath.Join(exportDir, "load.sql")
+	if data, err := os.ReadFile(loadPath); err == nil {
+		data = []byte(strings.ReplaceAll(string(data), exportDir+string(filepath.Separator), ""))
+		if err := os.WriteFile(loadPath, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to rewrite load.sql: %w", err)
+		}
+	}
+
+	manifest := &Manifest{
+		CreatedAt: time.Now().UTC(),
+		Profile:   profile,
+		Tables:    tables,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	tmpPath := archivePath + ".partial"
+	if err := writeArchive(tmpPath, exportDir, dataDir, manifest); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := verifyArchive(tmpPath, manifest); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	sum, err := fileSHA256(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	manifest.SHA256 = sum
+	return manifest, nil
+}
+
+// CountTables lists the tables of db with their number of rows
+func CountTables(db *sql.DB) ([]TableExport, error) {
+	rows, err := db.Query(`SELECT table_name FROM duckdb_tables() WHERE NOT internal ORDER BY table_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tables := make([]TableExport, 0, len(names))
+	for _, name := range names {
+		t := TableExport{Name: name}
+		if err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, name)).Scan(&t.Rows); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", name, err)
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+func writeArchive(path, exportDir, dataDir string, manifest *Manifest) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+
+	entries, err := os.ReadDir(exportDir)
+	if err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+	for _, entry := range entries {
+		if err := addFile(zw, filepath.Join(exportDir, entry.Name()), "database/"+entry.Name()); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, "database/"+entry.Name())
+	}
+	for _, name := range configFiles {
+		src := filepath.Join(dataDir, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := addFile(zw, src, "config/"+name); err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, "config/"+name)
+	}
+	sort.Strings(manifest.Files)
+
+	w, err := zw.Create("manifest.json")
+	if err != nil {
+		return fmt.Errorf("failed to add manifest: %w", err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to flush archive: %w", err)
+	}
+	return nil
+}
+
+func addFile(zw *zip.Writer, src, name string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// verifyArchive reads every entry back so a truncated or corrupt archive is
+// caught before anything is deleted
+func verifyArchive(path string, manifest *Manifest) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to verify archive: %w", err)
+	}
+	defer zr.Close()
+
+	found := make(map[string]bool)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", f.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", f.Name, err)
+		}
+		found[f.Name] = true
+	}
+	for _, name := range append(manifest.Files, "manifest.json") {
+		if !found[name] {
+			return fmt.Errorf("archive is missing %s", name)
+		}
+	}
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash archive: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Wipe overwrites every file in dataDir with random bytes, removes it, and
+// returns the removed paths. Entries named in keep (such as the lock file or
+// the profiles directory of the default profile) are left alone. Overwriting
+// is best effort: SSDs and copy-on-write filesystems may keep old blocks.
+func Wipe(dataDir string, keep ...string) ([]string, error) {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if containsName(keep, entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dataDir, entry.Name())
+		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				if err := overwrite(p, info.Size()); err != nil {
+					return err
+				}
+				removed = append(removed, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, fmt.Errorf("failed to overwrite %s: %w", path, err)
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return removed, nil
+}
+
+func overwrite(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.CopyN(f, rand.Reader, size); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func within(path, dir string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package offboard
+
+import (
+	"archive/zip"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func setupDataDir(t *testing.T) (string, *sql.DB) {
+	dataDir := t.TempDir()
+	db, err := sql.Open("duckdb", filepath.Join(dataDir, "claudeee.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+		CREATE TABLE sessions (id VARCHAR PRIMARY KEY, project_name VARCHAR);
+		INSERT INTO sessions VALUES ('s1', 'alpha'), ('s2', 'beta');
+		CREATE TABLE messages (id VARCHAR, content VARCHAR);
+		INSERT INTO messages VALUES ('m1', 'it''s here');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to seed database: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "profile.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatalf("Failed to write profile.json: %v", err)
+	}
+	return dataDir, db
+}
+
+func TestExportWritesVerifiedArchive(t *testing.T) {
+	dataDir, db := setupDataDir(t)
+	archivePath := filepath.Join(t.TempDir(), "export.zip")
+
+	manifest, err := Export(db, dataDir, "default", archivePath)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(manifest.Tables) != 2 || manifest.Tables[1].Name != "sessions" || manifest.Tables[1].Rows != 2 {
+		t.Errorf("Expected messages and sessions tables, got %+v", manifest.Tables)
+	}
+	if manifest.SHA256 == "" {
+		t.Error("Expected archive checksum")
+	}
+
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	defer zr.Close()
+	names := make(map[string]bool)
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	for _, expected := range []string{"manifest.json", "config/profile.json", "database/schema.sql", "database/load.sql", "database/sessions.parquet"} {
+		if !names[expected] {
+			t.Errorf("Expected %s in archive, got %v", expected, names)
+		}
+	}
+	if _, err := os.Stat(archivePath + ".partial"); !os.IsNotExist(err) {
+		t.Error("Expected partial archive to be renamed")
+	}
+}
+
+func TestExportRejectsArchiveInsideDataDir(t *testing.T) {
+	dataDir, db := setupDataDir(t)
+
+	_, err := Export(db, dataDir, "default", filepath.Join(dataDir, "export.zip"))
+	if !errors.Is(err, ErrArchiveInsideDataDir) {
+		t.Errorf("Expected ErrArchiveInsideDataDir, got %v", err)
+	}
+}
+
+func TestWipeKeepsListedEntries(t *testing.T) {
+	dataDir := t.TempDir()
+	files := map[string]string{
+		"claudeee.db":               "data",
+		"claudeee.lock":             "123",
+		"logs/server.log":           "log line",
+		"profiles/work/claudeee.db": "other profile",
+	}
+	for name, content := range files {
+		path := filepath.Join(dataDir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	removed, err := Wipe(dataDir, "claudeee.lock", "profiles")
+	if err != nil {
+		t.Fatalf("Wipe failed: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Expected 2 removed files, got %v", removed)
+	}
+	for _, name := range []string{"claudeee.db", "logs"} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted", name)
+		}
+	}
+	for _, name := range []string{"claudeee.lock", "profiles/work/claudeee.db"} {
+		if _, err := os.Stat(filepath.Join(dataDir, name)); err != nil {
+			t.Errorf("Expected %s to be kept: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+// Package openapi builds an OpenAPI 3 description of the API from the routes
+// the server registers and a table documenting them. Schemas are derived from
+// the Go types the handlers encode, so they change with the code rather than
+// drifting from it.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// Version is the OpenAPI version of the generated document
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+}
+
+// Server is a base URL the document's paths are relative to
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Tag groups operations
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem holds the operations of one path by lowercase HTTP method
+type PathItem map[string]*Operation
+
+// Operation is one method on one path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is the response for one status code
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the named schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a way of authenticating
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Schema is a JSON schema in the OpenAPI dialect
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Object documents a response built as gin.H: each value is a value of the
+// type encoded under its key
+type Object map[string]interface{}
+
+// Param documents a query parameter
+type Param struct {
+	Name        string
+	Type        string // string (default), integer, number or boolean
+	Description string
+	Required    bool
+}
+
+// Route documents one route of the API
+type Route struct {
+	Method string
+	// Path uses gin syntax and is relative to the prefix, e.g. /sessions/:id
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	Query       []Param
+	// Body and Response are values of the types decoded and encoded; a nil
+	// Response documents a response without a fixed schema
+	Body     interface{}
+	Response interface{}
+	// Status is the success status, 200 by default
+	Status int
+	// ContentType is the response type when it is not JSON; with a Response
+	// too, the route answers with either
+	ContentType string
+	// Admin routes need the admin role when authentication is enabled
+	Admin bool
+	// Public routes need no login
+	Public bool
+}
+
+// Build describes the registered routes under prefix, such as /api/v1, with
+// the documentation in routes. Documented routes that are not registered,
+// such as those left out for the configured database, are left out of the
+// document; registered routes without documentation are described without
+// schemas and returned so the caller can report them.
+func Build(info Info, prefix string, registered gin.RoutesInfo, routes []Route) (*Document, gin.RoutesInfo) {
+	docs := make(map[string]Route, len(routes))
+	for _, route := range routes {
+		docs[route.Method+" "+route.Path] = route
+	}
+
+	g := &generator{schemas: map[string]*Schema{}, types: map[string]reflect.Type{}}
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Servers: []Server{{URL: prefix}},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				"session": {Type: "apiKey", In: "cookie", Name: auth.SessionCookieName},
+				"basic":   {Type: "http", Scheme: "basic"},
+			},
+		},
+		Security: []map[string][]string{{"session": {}}, {"basic": {}}},
+	}
+	errorSchema := g.schema(reflect.TypeOf(ErrorResponse{}))
+
+	var undocumented gin.RoutesInfo
+	tags := map[string]bool{}
+	for _, rr := range registered {
+		if !strings.HasPrefix(rr.Path, prefix+"/") {
+			continue
+		}
+		routePath := strings.TrimPrefix(rr.Path, prefix)
+		route, ok := docs[rr.Method+" "+routePath]
+		if !ok {
+			undocumented = append(undocumented, rr)
+			route = Route{Method: rr.Method, Path: routePath, Summary: "Undocumented"}
+		}
+
+		op := &Operation{
+			OperationID: operationID(route.Method, route.Path),
+			Summary:     route.Summary,
+			Description: route.Description,
+			Responses:   map[string]Response{},
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+			tags[route.Tag] = true
+		}
+		if route.Admin {
+			op.Description = strings.TrimSpace(op.Description + "\n\nRequires the admin role.")
+		}
+		if route.Public {
+			op.Security = []map[string][]string{{}}
+		}
+		apiPath, names := openAPIPath(route.Path)
+		for _, name := range names {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		for _, p := range route.Query {
+			typ := p.Type
+			if typ == "" {
+				typ = "string"
+			}
+			op.Parameters = append(op.Parameters, Parameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: &Schema{Type: typ}})
+		}
+		if route.Body != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: g.value(route.Body)}},
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := Response{Description: http.StatusText(status)}
+		switch {
+		case route.ContentType != "":
+			success.Content = map[string]MediaType{route.ContentType: {Schema: &Schema{Type: "string"}}}
+			if route.Response != nil {
+				success.Content["application/json"] = MediaType{Schema: g.value(route.Response)}
+			}
+		case route.Response != nil:
+			success.Content = map[string]MediaType{"application/json": {Schema: g.value(route.Response)}}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+		op.Responses["default"] = Response{
+			Description: "Error",
+			Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+		}
+
+		item := doc.Paths[apiPath]
+		if item == nil {
+			item = PathItem{}
+			doc.Paths[apiPath] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	for name := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: name})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	return doc, undocumented
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Details string `json:"details,omitempty"`
+}
+
+// openAPIPath turns gin's :name segments into {name} and returns the names
+func openAPIPath(path string) (string, []string) {
+	segments := strings.Split(path, "/")
+	var names []string
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), names
+}
+
+// operationID names an operation after its method and path, e.g.
+// getSessionsById for GET /sessions/:id
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" {
+			continue
+		}
+		if strings.HasPrefix(segment, ":") {
+			b.WriteString("By")
+			segment = segment[1:]
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+var (
+	timeType   = reflect.TypeOf(time.Time{})
+	rawType    = reflect.TypeOf(json.RawMessage{})
+	objectType = reflect.TypeOf(Object{})
+)
+
+// generator derives schemas from Go types, collecting named structs as
+// components
+type generator struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+func (g *generator) value(v interface{}) *Schema {
+	if obj, ok := v.(Object); ok {
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for key, value := range obj {
+			if value == nil {
+				s.Properties[key] = &Schema{}
+				continue
+			}
+			s.Properties[key] = g.value(value)
+		}
+		return s
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawType, objectType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := g.schema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name := g.componentName(t)
+		if _, ok := g.schemas[name]; !ok {
+			// Register before descending so recursive types end in a $ref
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	// Interfaces and anything else can hold any value
+	return &Schema{}
+}
+
+// componentName is the type's name, capitalized for unexported request
+// types and qualified by its package when a type of another package took the
+// name first
+func (g *generator) componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if other, ok := g.types[name]; ok && other != t {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	g.types[name] = t
+	return name
+}
+
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(t, s)
+	return s
+}
+
+// fields adds the JSON fields of struct t to s, flattening embedded structs
+// the way encoding/json does
+func (g *generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, s)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(opts, "string") {
+			s.Properties[name] = &Schema{Type: "string"}
+			continue
+		}
+		s.Properties[name] = g.schema(field.Type)
+	}
+}
@@ -0,0 +1,107 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type item struct {
+	ID      string     `json:"id"`
+	Count   int64      `json:"count,string"`
+	Created time.Time  `json:"created_at"`
+	Ended   *time.Time `json:"ended_at"`
+	Parent  *item      `json:"parent,omitempty"`
+	Tags    []string   `json:"tags"`
+	secret  string
+	Skipped string `json:"-"`
+	embedded
+}
+
+type embedded struct {
+	Note string `json:"note"`
+}
+
+func TestBuildDocumentsRegisteredRoutes(t *testing.T) {
+	registered := gin.RoutesInfo{
+		{Method: http.MethodGet, Path: "/api/v1/items/:id"},
+		{Method: http.MethodPost, Path: "/api/v1/items"},
+		{Method: http.MethodGet, Path: "/api/v1/health"},
+		{Method: http.MethodDelete, Path: "/api/v1/items/:id"},
+		{Method: http.MethodGet, Path: "/metrics"},
+	}
+	routes := []Route{
+		{Method: http.MethodGet, Path: "/items/:id", Tag: "items", Summary: "An item", Response: item{}},
+		{Method: http.MethodPost, Path: "/items", Tag: "items", Summary: "Add an item", Admin: true,
+			Query: []Param{{Name: "dry_run", Type: "boolean"}}, Body: item{}, Response: Object{"item": item{}}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/health", Tag: "system", Summary: "Health", Public: true},
+		{Method: http.MethodGet, Path: "/gone", Summary: "Not registered"},
+	}
+
+	doc, undocumented := Build(Info{Title: "Test", Version: "1"}, "/api/v1", registered, routes)
+
+	if len(undocumented) != 1 || undocumented[0].Path != "/api/v1/items/:id" || undocumented[0].Method != http.MethodDelete {
+		t.Errorf("Expected DELETE /api/v1/items/:id to be undocumented, got %v", undocumented)
+	}
+	if _, ok := doc.Paths["/metrics"]; ok {
+		t.Error("Expected routes outside the prefix to be left out")
+	}
+	if _, ok := doc.Paths["/gone"]; ok {
+		t.Error("Expected unregistered routes to be left out")
+	}
+
+	get := doc.Paths["/items/{id}"]["get"]
+	if get == nil || len(get.Parameters) != 1 || get.Parameters[0].In != "path" || !get.Parameters[0].Required {
+		t.Fatalf("Expected a required id path parameter, got %+v", get)
+	}
+	if ref := get.Responses["200"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/Item" {
+		t.Errorf("Expected the response to reference Item, got %q", ref)
+	}
+	if del := doc.Paths["/items/{id}"]["delete"]; del == nil || del.Summary != "Undocumented" {
+		t.Errorf("Expected undocumented routes to still be listed, got %+v", del)
+	}
+
+	post := doc.Paths["/items"]["post"]
+	if _, ok := post.Responses["201"]; !ok || post.RequestBody == nil || len(post.Parameters) != 1 {
+		t.Errorf("Expected a 201 response, a body and a query parameter, got %+v", post)
+	}
+	if post.Description == "" {
+		t.Error("Expected admin routes to say they need the admin role")
+	}
+	if health := doc.Paths["/health"]["get"]; len(health.Security) != 1 || len(health.Security[0]) != 0 {
+		t.Errorf("Expected public routes to need no credentials, got %v", health.Security)
+	}
+}
+
+func TestSchemaFollowsJSONEncoding(t *testing.T) {
+	g := &generator{schemas: map[string]*Schema{}, types: map[string]reflect.Type{}}
+	g.value(item{})
+	s := g.schemas["Item"]
+	if s == nil {
+		t.Fatal("Expected an Item component")
+	}
+
+	for _, name := range []string{"secret", "Skipped", "embedded"} {
+		if _, ok := s.Properties[name]; ok {
+			t.Errorf("Expected %s not to be a property", name)
+		}
+	}
+	checks := map[string]string{"id": "string", "count": "string", "created_at": "string", "note": "string", "tags": "array"}
+	for name, typ := range checks {
+		if p := s.Properties[name]; p == nil || p.Type != typ {
+			t.Errorf("Expected %s to be a %s, got %+v", name, typ, p)
+		}
+	}
+	if p := s.Properties["created_at"]; p.Format != "date-time" {
+		t.Errorf("Expected created_at to be a date-time, got %q", p.Format)
+	}
+	if p := s.Properties["ended_at"]; !p.Nullable {
+		t.Error("Expected a pointer field to be nullable")
+	}
+	if p := s.Properties["parent"]; p.Ref != "#/components/schemas/Item" {
+		t.Errorf("Expected a recursive field to reference its component, got %+v", p)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// maxReportedDiscrepancies bounds the discrepancies listed in a report; all
+// of them are counted and fixed
+const maxReportedDiscrepancies = 500
+
+// AggregateDiscrepancy is a stored total that differs from the one its
+// messages add up to
+type AggregateDiscrepancy struct {
+	// Table is sessions or session_windows
+	Table    string `json:"table"`
+	ID       string `json:"id"`
+	Field    string `json:"field"`
+	Stored   int64  `json:"stored"`
+	Expected int64  `json:"expected"`
+}
+
+// AggregateReport is the result of checking, and unless it was a dry run
+// repairing, the session and window totals
+type AggregateReport struct {
+	DryRun          bool `json:"dry_run"`
+	SessionsChecked int  `json:"sessions_checked"`
+	WindowsChecked  int  `json:"windows_checked"`
+	// SessionsFixed and WindowsFixed are the rows with at least one
+	// discrepancy; they are rewritten unless DryRun is set
+	SessionsFixed    int                    `json:"sessions_fixed"`
+	WindowsFixed     int                    `json:"windows_fixed"`
+	DiscrepancyCount int                    `json:"discrepancy_count"`
+	Discrepancies    []AggregateDiscrepancy `json:"discrepancies"`
+}
+
+func (r *AggregateReport) add(table, id string, fields []string, stored, expected []int64) bool {
+	found := false
+	for i, field := range fields {
+		if stored[i] == expected[i] {
+			continue
+		}
+		found = true
+		r.DiscrepancyCount++
+		if len(r.Discrepancies) < maxReportedDiscrepancies {
+			r.Discrepancies = append(r.Discrepancies, AggregateDiscrepancy{
+				Table: table, ID: id, Field: field, Stored: stored[i], Expected: expected[i],
+			})
+		}
+	}
+	return found
+}
+
+var (
+	sessionAggregateFields = []string{"total_input_tokens", "total_output_tokens", "total_tokens", "message_count"}
+	windowAggregateFields  = []string{"total_input_tokens", "total_output_tokens", "total_tokens", "message_count", "session_count"}
+)
+
+// RecomputeAggregates compares the totals of every session and session
+// window with what their messages add up to, counted as syncs count them,
+// and rewrites those that differ in a single transaction. With dryRun it
+// only reports the discrepancies.
+func RecomputeAggregates(db *sql.DB, dryRun bool) (*AggregateReport, error) {
+	report := &AggregateReport{DryRun: dryRun, Discrepancies: []AggregateDiscrepancy{}}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Session totals count assistant messages
+	sessions, err := collectAggregates(tx, `
+		SELECT s.id,
+			COALESCE(s.total_input_tokens, 0), COALESCE(s.total_output_tokens, 0),
+			COALESCE(s.total_tokens, 0), COALESCE(s.message_count, 0),
+			COALESCE(SUM(m.input_tokens), 0), COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.input_tokens + m.output_tokens), 0), COUNT(m.id)
+		FROM sessions s
+		LEFT JOIN messages m ON m.session_id = s.id AND m.message_role = 'assistant'
+		GROUP BY s.id, s.total_input_tokens, s.total_output_tokens, s.total_tokens, s.message_count
+		ORDER BY s.id
+	`, len(sessionAggregateFields))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check session totals: %w", err)
+	}
+	report.SessionsChecked = len(sessions)
+
+	// Window tokens count every Claude message in the window, its message
+	// count only assistant messages; other providers have no plan windows
+	windows, err := collectAggregates(tx, `
+		SELECT w.id,
+			COALESCE(w.total_input_tokens, 0), COALESCE(w.total_output_tokens, 0),
+			COALESCE(w.total_tokens, 0), COALESCE(w.message_count, 0), COALESCE(w.session_count, 0),
+			COALESCE(SUM(m.input_tokens), 0), COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.input_tokens + m.output_tokens), 0),
+			COALESCE(SUM(CASE WHEN m.message_role = 'assistant' THEN 1 ELSE 0 END), 0),
+			COUNT(DISTINCT m.session_id)
+		FROM session_windows w
+		LEFT JOIN messages m ON m.timestamp >= w.window_start AND m.timestamp < w.window_end
+			AND COALESCE(m.provider, 'claude') = 'claude'
+		GROUP BY w.id, w.total_input_tokens, w.total_output_tokens, w.total_tokens, w.message_count, w.session_count
+		ORDER BY w.id
+	`, len(windowAggregateFields))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check window totals: %w", err)
+	}
+	report.WindowsChecked = len(windows)
+
+	for _, s := range sessions {
+		if !report.add("sessions", s.id, sessionAggregateFields, s.stored, s.expected) {
+			continue
+		}
+		report.SessionsFixed++
+		if dryRun {
+			continue
+		}
+		_, err := tx.Exec(`
+			UPDATE sessions SET total_input_tokens = ?, total_output_tokens = ?, total_tokens = ?, message_count = ?
+			WHERE id = ?
+		`, s.expected[0], s.expected[1], s.expected[2], s.expected[3], s.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fix session %s: %w", s.id, err)
+		}
+	}
+	for _, w := range windows {
+		if !report.add("session_windows", w.id, windowAggregateFields, w.stored, w.expected) {
+			continue
+		}
+		report.WindowsFixed++
+		if dryRun {
+			continue
+		}
+		_, err := tx.Exec(`
+			UPDATE session_windows
+			SET total_input_tokens = ?, total_output_tokens = ?, total_tokens = ?, message_count = ?, session_count = ?,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?
+		`, w.expected[0], w.expected[1], w.expected[2], w.expected[3], w.expected[4], w.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fix window %s: %w", w.id, err)
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit totals: %w", err)
+	}
+	return report, nil
+}
+
+// aggregateRow is a row's stored totals and those its messages add up to
+type aggregateRow struct {
+	id               string
+	stored, expected []int64
+}
+
+// collectAggregates reads rows of an id followed by n stored and n expected totals
+func collectAggregates(tx *sql.Tx, query string, n int) ([]aggregateRow, error) {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []aggregateRow
+	for rows.Next() {
+		row := aggregateRow{stored: make([]int64, n), expected: make([]int64, n)}
+		dest := []interface{}{&row.id}
+		for i := range row.stored {
+			dest = append(dest, &row.stored[i])
+		}
+		for i := range row.expected {
+			dest = append(dest, &row.expected[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
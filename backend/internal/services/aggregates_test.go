@@ -0,0 +1,100 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestRecomputeAggregates(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	if _, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, total_input_tokens, total_output_tokens, total_tokens, message_count)
+		VALUES ('good', 'app', '/work/app', ?, 100, 50, 150, 1), ('drifted', 'app', '/work/app', ?, 300, 100, 400, 3),
+			('codex', 'app', '/work/app', ?, 500, 0, 500, 1)
+	`, start, start, start); err != nil {
+		t.Fatalf("Failed to insert sessions: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, total_input_tokens, total_output_tokens, total_tokens, message_count, session_count)
+		VALUES ('w1', ?, ?, ?, 999, 0, 999, 9, 9)
+	`, start, start.Add(5*time.Hour), start.Add(5*time.Hour)); err != nil {
+		t.Fatalf("Failed to insert window: %v", err)
+	}
+	// The Codex message counts towards its session but not the Claude window
+	for _, m := range []struct {
+		id, session, role, provider string
+		input, output               int
+	}{
+		{"g-1", "good", "user", "claude", 0, 0},
+		{"g-2", "good", "assistant", "claude", 100, 50},
+		{"d-1", "drifted", "assistant", "claude", 150, 50},
+		{"c-1", "codex", "assistant", "codex", 500, 0},
+	} {
+		if _, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, provider, input_tokens, output_tokens, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, m.id, m.session, m.role, m.provider, m.input, m.output, start.Add(time.Minute)); err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	report, err := RecomputeAggregates(db, true)
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if report.SessionsChecked != 3 || report.WindowsChecked != 1 || report.SessionsFixed != 1 || report.WindowsFixed != 1 {
+		t.Errorf("Unexpected dry run report %+v", report)
+	}
+	// Four fields of the drifted session and all five of the window
+	if report.DiscrepancyCount != 9 {
+		t.Errorf("Expected 9 discrepancies, got %d: %+v", report.DiscrepancyCount, report.Discrepancies)
+	}
+	var total int64
+	if err := db.QueryRow(`SELECT total_tokens FROM sessions WHERE id = 'drifted'`).Scan(&total); err != nil || total != 400 {
+		t.Errorf("Expected a dry run to leave totals alone, got %d, %v", total, err)
+	}
+
+	report, err = RecomputeAggregates(db, false)
+	if err != nil {
+		t.Fatalf("Recompute failed: %v", err)
+	}
+	if report.DiscrepancyCount != 9 {
+		t.Errorf("Expected 9 discrepancies fixed, got %+v", report)
+	}
+	var input, output, count int64
+	if err := db.QueryRow(`SELECT total_input_tokens, total_output_tokens, total_tokens, message_count FROM sessions WHERE id = 'drifted'`).
+		Scan(&input, &output, &total, &count); err != nil {
+		t.Fatal(err)
+	}
+	if input != 150 || output != 50 || total != 200 || count != 1 {
+		t.Errorf("Expected the drifted session rebuilt to 150/50/200/1, got %d/%d/%d/%d", input, output, total, count)
+	}
+	var sessions int64
+	if err := db.QueryRow(`SELECT total_tokens, message_count, session_count FROM session_windows WHERE id = 'w1'`).
+		Scan(&total, &count, &sessions); err != nil {
+		t.Fatal(err)
+	}
+	if total != 350 || count != 2 || sessions != 2 {
+		t.Errorf("Expected the window rebuilt to 350 tokens, 2 messages, 2 sessions, got %d, %d, %d", total, count, sessions)
+	}
+
+	report, err = RecomputeAggregates(db, false)
+	if err != nil {
+		t.Fatalf("Recompute failed: %v", err)
+	}
+	if report.DiscrepancyCount != 0 {
+		t.Errorf("Expected consistent totals after the repair, got %+v", report.Discrepancies)
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+)
+
+// MaxAuditPageSize caps how many audit entries one request may return
+const MaxAuditPageSize = 1000
+
+// AuditEntry is one recorded API request
+type AuditEntry struct {
+	AccessedAt time.Time `json:"accessed_at"`
+	User       string    `json:"user"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	ClientIP   string    `json:"client_ip"`
+	UserAgent  string    `json:"user_agent"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// AuditQuery filters the audit log. Zero values mean no filter.
+type AuditQuery struct {
+	User       string
+	PathPrefix string
+	Since      *time.Time
+	Limit      int
+	Offset     int
+}
+
+// AuditService stores and queries the API access audit log
+type AuditService struct {
+	db *sql.DB
+}
+
+func NewAuditService(db *sql.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// Insert stores a batch of entries in one transaction
+func (a *AuditService) Insert(entries []AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO audit_log
+		(accessed_at, user_name, method, path, status, client_ip, user_agent, duration_ms)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare audit insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		if _, err := stmt.Exec(e.AccessedAt, e.User, e.Method, e.Path, e.Status, e.ClientIP, e.UserAgent, e.DurationMs); err != nil {
+			return fmt.Errorf("failed to insert audit entry: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Prune deletes entries older than cutoff and returns how many were removed
+func (a *AuditService) Prune(cutoff time.Time) (int64, error) {
+	result, err := a.db.Exec(`DELETE FROM audit_log WHERE accessed_at < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune audit log: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Query returns matching entries, newest first, and the total number of matches
+func (a *AuditService) Query(q AuditQuery) ([]AuditEntry, int, error) {
+	var conditions []string
+	var args []interface{}
+	if q.User != "" {
+		conditions = append(conditions, "user_name = ?")
+		args = append(args, q.User)
+	}
+	if q.PathPrefix != "" {
+		conditions = append(conditions, "starts_with(path, ?)")
+		args = append(args, q.PathPrefix)
+	}
+	if q.Since != nil {
+		conditions = append(conditions, "accessed_at >= ?")
+		args = append(args, *q.Since)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := a.db.QueryRow("SELECT COUNT(*) FROM audit_log "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	limit := q.Limit
+	if limit <= 0 || limit > MaxAuditPageSize {
+		limit = 100
+	}
+	rows, err := a.db.Query(`SELECT accessed_at, COALESCE(user_name, ''), method, path, COALESCE(status, 0),
+			COALESCE(client_ip, ''), COALESCE(user_agent, ''), COALESCE(duration_ms, 0)
+		FROM audit_log `+where+`
+		ORDER BY accessed_at DESC
+		LIMIT ? OFFSET ?`, append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.AccessedAt, &e.User, &e.Method, &e.Path, &e.Status, &e.ClientIP, &e.UserAgent, &e.DurationMs); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// AuditLogger buffers audit entries and writes them in batches through the
+// write queue, so recording a request never waits on the database. Entries
+// are dropped, and counted, if the buffer fills up.
+type AuditLogger struct {
+	audit     *AuditService
+	writes    *WriteQueue
+	interval  time.Duration
+	retention time.Duration
+
+	entries chan AuditEntry
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	mu      sync.Mutex
+	dropped int64
+}
+
+// NewAuditLogger creates a logger that flushes every interval and deletes
+// entries older than retention; a zero retention keeps everything
+func NewAuditLogger(audit *AuditService, writes *WriteQueue, interval, retention time.Duration) *AuditLogger {
+	return &AuditLogger{
+		audit:     audit,
+		writes:    writes,
+		interval:  interval,
+		retention: retention,
+		entries:   make(chan AuditEntry, 1024),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start launches the flush goroutine
+func (l *AuditLogger) Start() {
+	go l.run()
+}
+
+// Record queues an entry without blocking
+func (l *AuditLogger) Record(entry AuditEntry) {
+	select {
+	case l.entries <- entry:
+	default:
+		l.mu.Lock()
+		l.dropped++
+		l.mu.Unlock()
+	}
+}
+
+// Dropped returns how many entries were discarded because the buffer was full
+func (l *AuditLogger) Dropped() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.dropped
+}
+
+// Stop flushes buffered entries and ends the flush goroutine
+func (l *AuditLogger) Stop() {
+	l.once.Do(func() { close(l.stop) })
+	<-l.done
+}
+
+func (l *AuditLogger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	var batch []AuditEntry
+	lastPrune := time.Time{}
+	flush := func() {
+		prune := l.retention > 0 && time.Since(lastPrune) >= time.Hour
+		if len(batch) == 0 && !prune {
+			return
+		}
+		err := l.writes.Do(func() error {
+			if err := l.audit.Insert(batch); err != nil {
+				return err
+			}
+			if prune {
+				if _, err := l.audit.Prune(time.Now().Add(-l.retention)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			logging.Component("audit").Warn("Failed to write audit log", "err", err)
+		}
+		if prune {
+			lastPrune = time.Now()
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-l.entries:
+			batch = append(batch, entry)
+			if len(batch) >= 200 {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-l.stop:
+			for {
+				select {
+				case entry := <-l.entries:
+					batch = append(batch, entry)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func setupAuditTest(t *testing.T) *AuditService {
+	db, _ := setupTestDBForDiffSync(t)
+	t.Cleanup(func() { db.Close() })
+	return NewAuditService(db)
+}
+
+func TestAuditQueryFiltersAndPrune(t *testing.T) {
+	audit := setupAuditTest(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	err := audit.Insert([]AuditEntry{
+		{AccessedAt: now.Add(-48 * time.Hour), User: "alice", Method: "GET", Path: "/api/sessions", Status: 200},
+		{AccessedAt: now.Add(-time.Hour), User: "bob", Method: "GET", Path: "/api/admin/audit", Status: 200},
+		{AccessedAt: now, User: "alice", Method: "POST", Path: "/api/sync-logs", Status: 202, ClientIP: "10.0.0.5"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to insert audit entries: %v", err)
+	}
+
+	entries, total, err := audit.Query(AuditQuery{User: "alice"})
+	if err != nil {
+		t.Fatalf("Failed to query audit log: %v", err)
+	}
+	if total != 2 || len(entries) != 2 {
+		t.Fatalf("Expected 2 entries for alice, got %d (total %d)", len(entries), total)
+	}
+	if entries[0].Path != "/api/sync-logs" || entries[0].ClientIP != "10.0.0.5" {
+		t.Errorf("Expected newest entry first, got %+v", entries[0])
+	}
+
+	since := now.Add(-2 * time.Hour)
+	if _, total, _ := audit.Query(AuditQuery{PathPrefix: "/api/admin", Since: &since}); total != 1 {
+		t.Errorf("Expected 1 recent admin entry, got %d", total)
+	}
+	if entries, total, _ := audit.Query(AuditQuery{Limit: 1, Offset: 1}); total != 3 || len(entries) != 1 || entries[0].User != "bob" {
+		t.Errorf("Expected second page to hold bob's entry, got %+v (total %d)", entries, total)
+	}
+
+	pruned, err := audit.Prune(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to prune audit log: %v", err)
+	}
+	if pruned != 1 {
+		t.Errorf("Expected 1 pruned entry, got %d", pruned)
+	}
+}
+
+func TestAuditLoggerFlushesOnStop(t *testing.T) {
+	audit := setupAuditTest(t)
+	writes := NewWriteQueue(4)
+	defer writes.Close()
+
+	logger := NewAuditLogger(audit, writes, time.Hour, 0)
+	logger.Start()
+	for i := 0; i < 3; i++ {
+		logger.Record(AuditEntry{AccessedAt: time.Now(), User: "alice", Method: "GET", Path: "/api/sessions", Status: 200})
+	}
+	logger.Stop()
+
+	if _, total, _ := audit.Query(AuditQuery{}); total != 3 {
+		t.Errorf("Expected 3 entries after stop, got %d", total)
+	}
+}
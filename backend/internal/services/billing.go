@@ -0,0 +1,53 @@
+package services
+
+import (
+	"strings"
+
+	"claudeee-backend/internal/models"
+)
+
+// How usage is billed
+const (
+	// BillingSubscription is usage covered by a Pro or Max plan's monthly fee
+	BillingSubscription = "subscription"
+	// BillingAPI is pay-as-you-go usage charged at API prices
+	BillingAPI = "api"
+)
+
+// apiServiceTiers are service tiers only pay-as-you-go API keys can use
+var apiServiceTiers = map[string]bool{
+	"priority": true,
+	"batch":    true,
+}
+
+// cloudMessageIDPrefixes mark responses served through Amazon Bedrock or
+// Google Vertex AI, which the cloud provider bills per token
+var cloudMessageIDPrefixes = []string{"msg_bdrk_", "msg_vrtx_"}
+
+// planMonthlyFees are the list prices of the plans in USD; a custom plan has
+// no known fee
+var planMonthlyFees = map[string]float64{
+	PlanPro:   20,
+	PlanMax5:  100,
+	PlanMax20: 200,
+}
+
+// logEntryBilling returns "api" for an entry whose usage was billed per token
+// and nil when the entry does not say, leaving it to the default billing
+func logEntryBilling(entry *models.LogEntry) *string {
+	if entry.Message.Usage == nil {
+		return nil
+	}
+	api := BillingAPI
+	if apiServiceTiers[strings.ToLower(entry.Message.Usage.ServiceTier)] {
+		return &api
+	}
+	if entry.Message.ID != nil {
+		for _, prefix := range cloudMessageIDPrefixes {
+			if strings.HasPrefix(*entry.Message.ID, prefix) {
+				return &api
+			}
+		}
+	}
+	return nil
+}
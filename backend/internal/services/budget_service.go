@@ -0,0 +1,493 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Budget periods
+const (
+	// BudgetPeriodMonthly resets on the first day of each calendar month
+	BudgetPeriodMonthly = "monthly"
+	// BudgetPeriodWindow resets with each 5-hour session window
+	BudgetPeriodWindow = "window"
+)
+
+// Budget metrics
+const (
+	BudgetMetricCost   = "cost"
+	BudgetMetricTokens = "tokens"
+)
+
+// Alert limits
+const (
+	DefaultAlertLimit = 100
+	MaxAlertLimit     = 1000
+)
+
+// DefaultBudgetThresholds alert at 80% and 100% of a budget
+var DefaultBudgetThresholds = []float64{0.8, 1}
+
+var (
+	// ErrInvalidBudget is returned for a budget that fails validation
+	ErrInvalidBudget = errors.New("invalid budget")
+	// ErrBudgetNotFound is returned for an unknown budget id
+	ErrBudgetNotFound = errors.New("budget not found")
+)
+
+// Budget limits the cost or tokens spent per period, for all projects or one
+type Budget struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Project limits the budget to one project; empty means all projects
+	Project string  `json:"project"`
+	Period  string  `json:"period"`
+	Metric  string  `json:"metric"`
+	Amount  float64 `json:"amount"`
+	// Thresholds are the fractions of Amount that raise an alert
+	Thresholds []float64 `json:"thresholds"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Validate normalizes the thresholds and checks the remaining fields
+func (b *Budget) Validate() error {
+	b.Name = strings.TrimSpace(b.Name)
+	if b.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidBudget)
+	}
+	if b.Period != BudgetPeriodMonthly && b.Period != BudgetPeriodWindow {
+		return fmt.Errorf("%w: period must be %s or %s", ErrInvalidBudget, BudgetPeriodMonthly, BudgetPeriodWindow)
+	}
+	if b.Metric != BudgetMetricCost && b.Metric != BudgetMetricTokens {
+		return fmt.Errorf("%w: metric must be %s or %s", ErrInvalidBudget, BudgetMetricCost, BudgetMetricTokens)
+	}
+	if b.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidBudget)
+	}
+	if len(b.Thresholds) == 0 {
+		b.Thresholds = append([]float64(nil), DefaultBudgetThresholds...)
+	}
+	sort.Float64s(b.Thresholds)
+	var thresholds []float64
+	for _, threshold := range b.Thresholds {
+		if threshold <= 0 || threshold > 10 {
+			return fmt.Errorf("%w: thresholds must be between 0 and 10, got %g", ErrInvalidBudget, threshold)
+		}
+		if len(thresholds) == 0 || thresholds[len(thresholds)-1] != threshold {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	b.Thresholds = thresholds
+	return nil
+}
+
+// BudgetStatus is a budget's spending in its current period. The period is
+// nil for a window budget when no session window is active.
+type BudgetStatus struct {
+	Budget
+	PeriodStart *time.Time `json:"period_start"`
+	PeriodEnd   *time.Time `json:"period_end"`
+	Spent       float64    `json:"spent"`
+	// Utilization is Spent as a fraction of Amount
+	Utilization float64 `json:"utilization"`
+	Exceeded    bool    `json:"exceeded"`
+}
+
+// BudgetAlert records a budget crossing one of its thresholds
+type BudgetAlert struct {
+	ID          string    `json:"id"`
+	BudgetID    string    `json:"budget_id"`
+	BudgetName  string    `json:"budget_name"`
+	Project     string    `json:"project"`
+	Metric      string    `json:"metric"`
+	Threshold   float64   `json:"threshold"`
+	Amount      float64   `json:"amount"`
+	Spent       float64   `json:"spent"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// AlertQuery filters the alert history. Empty fields do not filter.
+type AlertQuery struct {
+	BudgetID string
+	Since    time.Time
+	Limit    int
+}
+
+// BudgetService manages budgets and records the alerts they raise
+type BudgetService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+
+	mu       sync.RWMutex
+	location *time.Location
+}
+
+func NewBudgetService(db *sql.DB) *BudgetService {
+	return &BudgetService{db: db, pricing: NewPricingCalculator(), location: time.UTC}
+}
+
+// SetLocation sets the time zone that monthly budget periods follow
+func (s *BudgetService) SetLocation(loc *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.location = loc
+}
+
+func formatThresholds(thresholds []float64) string {
+	parts := make([]string, len(thresholds))
+	for i, threshold := range thresholds {
+		parts[i] = strconv.FormatFloat(threshold, 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseThresholds(value string) []float64 {
+	var thresholds []float64
+	for _, part := range strings.Split(value, ",") {
+		if threshold, err := strconv.ParseFloat(strings.TrimSpace(part), 64); err == nil {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	return thresholds
+}
+
+// nullableProject stores an empty project as NULL
+func nullableProject(project string) interface{} {
+	if project == "" {
+		return nil
+	}
+	return project
+}
+
+// Create validates and stores a new budget
+func (s *BudgetService) Create(b Budget) (*Budget, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	b.ID = uuid.New().String()
+	b.CreatedAt = now
+	b.UpdatedAt = now
+	_, err := s.db.Exec(`
+		INSERT INTO budgets (id, name, project_name, period, metric, amount, thresholds, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, b.ID, b.Name, nullableProject(b.Project), b.Period, b.Metric, b.Amount, formatThresholds(b.Thresholds), b.Enabled, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create budget: %w", err)
+	}
+	return &b, nil
+}
+
+// Update replaces every field of an existing budget
+func (s *BudgetService) Update(id string, b Budget) (*Budget, error) {
+	existing, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	b.ID = id
+	b.CreatedAt = existing.CreatedAt
+	b.UpdatedAt = time.Now().UTC()
+	_, err = s.db.Exec(`
+		UPDATE budgets
+		SET name = ?, project_name = ?, period = ?, metric = ?, amount = ?, thresholds = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+	`, b.Name, nullableProject(b.Project), b.Period, b.Metric, b.Amount, formatThresholds(b.Thresholds), b.Enabled, b.UpdatedAt, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update budget: %w", err)
+	}
+	return &b, nil
+}
+
+// Delete removes a budget. Its alerts are kept as history.
+func (s *BudgetService) Delete(id string) error {
+	result, err := s.db.Exec(`DELETE FROM budgets WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrBudgetNotFound
+	}
+	return nil
+}
+
+const budgetColumns = `id, name, COALESCE(project_name, ''), period, metric, amount, thresholds, COALESCE(enabled, true), created_at, updated_at`
+
+func scanBudget(scan func(...interface{}) error) (*Budget, error) {
+	var b Budget
+	var thresholds string
+	if err := scan(&b.ID, &b.Name, &b.Project, &b.Period, &b.Metric, &b.Amount, &thresholds, &b.Enabled, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		return nil, err
+	}
+	b.Thresholds = parseThresholds(thresholds)
+	return &b, nil
+}
+
+// Get returns one budget
+func (s *BudgetService) Get(id string) (*Budget, error) {
+	b, err := scanBudget(s.db.QueryRow(`SELECT `+budgetColumns+` FROM budgets WHERE id = ?`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrBudgetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget: %w", err)
+	}
+	return b, nil
+}
+
+// List returns every budget in order of creation
+func (s *BudgetService) List() ([]Budget, error) {
+	rows, err := s.db.Query(`SELECT ` + budgetColumns + ` FROM budgets ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+	defer rows.Close()
+
+	budgets := []Budget{}
+	for rows.Next() {
+		b, err := scanBudget(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		budgets = append(budgets, *b)
+	}
+	return budgets, rows.Err()
+}
+
+// Statuses returns every budget with its spending at now
+func (s *BudgetService) Statuses(now time.Time) ([]BudgetStatus, error) {
+	budgets, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]BudgetStatus, 0, len(budgets))
+	for _, b := range budgets {
+		status, err := s.Status(b, now)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+// Status returns the budget's spending in the period containing now
+func (s *BudgetService) Status(b Budget, now time.Time) (*BudgetStatus, error) {
+	status := &BudgetStatus{Budget: b}
+	start, end, err := s.period(b.Period, now)
+	if err != nil {
+		return nil, err
+	}
+	if start == nil {
+		return status, nil
+	}
+	status.PeriodStart = start
+	status.PeriodEnd = end
+
+	spent, err := s.spent(b, *start, *end)
+	if err != nil {
+		return nil, err
+	}
+	status.Spent = spent
+	status.Utilization = roundToDecimals(spent/b.Amount, 4)
+	status.Exceeded = spent >= b.Amount
+	return status, nil
+}
+
+// period returns the bounds of the budget period containing now
+func (s *BudgetService) period(period string, now time.Time) (*time.Time, *time.Time, error) {
+	if period == BudgetPeriodMonthly {
+		s.mu.RLock()
+		loc := s.location
+		s.mu.RUnlock()
+		local := now.In(loc)
+		start := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+		end := start.AddDate(0, 1, 0)
+		return &start, &end, nil
+	}
+
+	var start, end time.Time
+	err := s.db.QueryRow(`
+		SELECT window_start, window_end
+		FROM session_windows
+		WHERE window_start <= ? AND window_end > ?
+		ORDER BY window_start DESC
+		LIMIT 1
+	`, now, now).Scan(&start, &end)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get session window: %w", err)
+	}
+	return &start, &end, nil
+}
+
+// spent sums the budget's metric over assistant messages in [start, end)
+func (s *BudgetService) spent(b Budget, start, end time.Time) (float64, error) {
+	query := `
+		SELECT
+			COALESCE(m.model, ''),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0)
+		FROM messages m
+		JOIN sessions s ON s.id = m.session_id
+		WHERE m.message_role = 'assistant' AND m.timestamp >= ? AND m.timestamp < ?`
+	args := []interface{}{start, end}
+	if b.Project != "" {
+		query += ` AND s.project_name = ?`
+		args = append(args, b.Project)
+	}
+	query += ` GROUP BY COALESCE(m.model, '')`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get budget spending: %w", err)
+	}
+	defer rows.Close()
+
+	var spent float64
+	for rows.Next() {
+		var model string
+		var input, output, cacheCreation, cacheRead int64
+		if err := rows.Scan(&model, &input, &output, &cacheCreation, &cacheRead); err != nil {
+			return 0, fmt.Errorf("failed to scan budget spending: %w", err)
+		}
+		if b.Metric == BudgetMetricTokens {
+			spent += float64(input + output)
+		} else if model != "" {
+			spent += s.pricing.CalculateCost(model, int(input), int(output), int(cacheCreation), int(cacheRead))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get budget spending: %w", err)
+	}
+	return roundToDecimals(spent, 6), nil
+}
+
+// alertID identifies a threshold crossing so each is recorded once per period
+func alertID(budgetID string, threshold float64, periodStart time.Time) string {
+	return fmt.Sprintf("%s:%s:%s", budgetID, strconv.FormatFloat(threshold, 'g', -1, 64), periodStart.UTC().Format(time.RFC3339))
+}
+
+// Evaluate checks every enabled budget at now and records an alert for each
+// threshold crossed for the first time in the current period. It returns the
+// new alerts.
+func (s *BudgetService) Evaluate(now time.Time) ([]BudgetAlert, error) {
+	statuses, err := s.Statuses(now)
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []BudgetAlert
+	for _, status := range statuses {
+		if !status.Enabled || status.PeriodStart == nil {
+			continue
+		}
+		for _, threshold := range status.Thresholds {
+			if status.Spent < threshold*status.Amount {
+				break
+			}
+			alert := BudgetAlert{
+				ID:          alertID(status.ID, threshold, *status.PeriodStart),
+				BudgetID:    status.ID,
+				BudgetName:  status.Name,
+				Project:     status.Project,
+				Metric:      status.Metric,
+				Threshold:   threshold,
+				Amount:      status.Amount,
+				Spent:       status.Spent,
+				PeriodStart: *status.PeriodStart,
+				PeriodEnd:   *status.PeriodEnd,
+				TriggeredAt: now.UTC(),
+			}
+			created, err := s.recordAlert(alert)
+			if err != nil {
+				return alerts, err
+			}
+			if created {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	return alerts, nil
+}
+
+// recordAlert stores an alert unless it was already recorded
+func (s *BudgetService) recordAlert(alert BudgetAlert) (bool, error) {
+	var exists int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM budget_alerts WHERE id = ?`, alert.ID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check budget alert: %w", err)
+	}
+	if exists > 0 {
+		return false, nil
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO budget_alerts (id, budget_id, budget_name, project_name, metric, threshold, amount, spent, period_start, period_end, triggered_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING
+	`, alert.ID, alert.BudgetID, alert.BudgetName, nullableProject(alert.Project), alert.Metric, alert.Threshold,
+		alert.Amount, alert.Spent, alert.PeriodStart, alert.PeriodEnd, alert.TriggeredAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to record budget alert: %w", err)
+	}
+	return true, nil
+}
+
+// Alerts returns recorded alerts, newest first
+func (s *BudgetService) Alerts(q AlertQuery) ([]BudgetAlert, error) {
+	if q.Limit < 1 || q.Limit > MaxAlertLimit {
+		q.Limit = DefaultAlertLimit
+	}
+	var conditions []string
+	var args []interface{}
+	if q.BudgetID != "" {
+		conditions = append(conditions, "budget_id = ?")
+		args = append(args, q.BudgetID)
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "triggered_at >= ?")
+		args = append(args, q.Since)
+	}
+	query := `
+		SELECT id, budget_id, budget_name, COALESCE(project_name, ''), metric, threshold, amount, spent,
+			period_start, period_end, triggered_at
+		FROM budget_alerts`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY triggered_at DESC, threshold DESC, id LIMIT ?"
+
+	rows, err := s.db.Query(query, append(args, q.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get budget alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []BudgetAlert{}
+	for rows.Next() {
+		var alert BudgetAlert
+		if err := rows.Scan(&alert.ID, &alert.BudgetID, &alert.BudgetName, &alert.Project, &alert.Metric,
+			&alert.Threshold, &alert.Amount, &alert.Spent, &alert.PeriodStart, &alert.PeriodEnd, &alert.TriggeredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan budget alert: %w", err)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func setupBudgetTest(t *testing.T) (*sql.DB, *BudgetService) {
+	t.Helper()
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	for _, s := range [][2]string{{"s1", "alpha"}, {"s2", "beta"}} {
+		if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES (?, ?, ?, '2024-03-01')`,
+			s[0], s[1], "/"+s[1]); err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+	}
+	return db, NewBudgetService(db)
+}
+
+func insertBudgetMessage(t *testing.T, db *sql.DB, id, sessionID string, timestamp time.Time, input, output int) {
+	t.Helper()
+	_, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp)
+		VALUES (?, ?, 'assistant', 'claude-sonnet-4-20250514', ?, ?, ?)
+	`, id, sessionID, input, output, timestamp)
+	if err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+}
+
+func TestBudgetValidation(t *testing.T) {
+	_, budgets := setupBudgetTest(t)
+
+	for name, b := range map[string]Budget{
+		"no name":   {Period: BudgetPeriodMonthly, Metric: BudgetMetricCost, Amount: 10},
+		"period":    {Name: "b", Period: "weekly", Metric: BudgetMetricCost, Amount: 10},
+		"metric":    {Name: "b", Period: BudgetPeriodMonthly, Metric: "requests", Amount: 10},
+		"amount":    {Name: "b", Period: BudgetPeriodMonthly, Metric: BudgetMetricCost},
+		"threshold": {Name: "b", Period: BudgetPeriodMonthly, Metric: BudgetMetricCost, Amount: 10, Thresholds: []float64{-1}},
+	} {
+		if _, err := budgets.Create(b); !errors.Is(err, ErrInvalidBudget) {
+			t.Errorf("%s: expected ErrInvalidBudget, got %v", name, err)
+		}
+	}
+
+	created, err := budgets.Create(Budget{Name: " Team ", Period: BudgetPeriodMonthly, Metric: BudgetMetricCost, Amount: 10,
+		Thresholds: []float64{1, 0.5, 1}, Enabled: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	got, err := budgets.Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Name != "Team" || len(got.Thresholds) != 2 || got.Thresholds[0] != 0.5 || got.Project != "" {
+		t.Errorf("Unexpected budget: %+v", got)
+	}
+
+	updated, err := budgets.Update(created.ID, Budget{Name: "Alpha", Project: "alpha", Period: BudgetPeriodWindow,
+		Metric: BudgetMetricTokens, Amount: 1000})
+	if err != nil || updated.Project != "alpha" || len(updated.Thresholds) != len(DefaultBudgetThresholds) {
+		t.Errorf("Unexpected update: %+v (%v)", updated, err)
+	}
+	if _, err := budgets.Update("missing", *updated); !errors.Is(err, ErrBudgetNotFound) {
+		t.Errorf("Expected ErrBudgetNotFound, got %v", err)
+	}
+	if err := budgets.Delete(created.ID); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if err := budgets.Delete(created.ID); !errors.Is(err, ErrBudgetNotFound) {
+		t.Errorf("Expected ErrBudgetNotFound, got %v", err)
+	}
+}
+
+func TestBudgetEvaluateRecordsEachThresholdOnce(t *testing.T) {
+	db, budgets := setupBudgetTest(t)
+	march := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	insertBudgetMessage(t, db, "m1", "s1", march, 600, 200)
+	insertBudgetMessage(t, db, "m2", "s2", march, 5000, 0)
+	// Outside the monthly period
+	insertBudgetMessage(t, db, "m3", "s1", time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), 5000, 0)
+
+	alpha, err := budgets.Create(Budget{Name: "alpha tokens", Project: "alpha", Period: BudgetPeriodMonthly,
+		Metric: BudgetMetricTokens, Amount: 1000, Enabled: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := budgets.Create(Budget{Name: "disabled", Period: BudgetPeriodMonthly, Metric: BudgetMetricTokens, Amount: 1}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	alerts, err := budgets.Evaluate(march)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if len(alerts) != 1 || alerts[0].BudgetID != alpha.ID || alerts[0].Threshold != 0.8 || alerts[0].Spent != 800 {
+		t.Fatalf("Expected the 80%% alpha alert, got %+v", alerts)
+	}
+
+	insertBudgetMessage(t, db, "m4", "s1", march.Add(time.Hour), 300, 0)
+	alerts, err = budgets.Evaluate(march.Add(2 * time.Hour))
+	if err != nil || len(alerts) != 1 || alerts[0].Threshold != 1 {
+		t.Fatalf("Expected only the 100%% alert, got %+v (%v)", alerts, err)
+	}
+
+	// A new month starts a new period
+	alerts, err = budgets.Evaluate(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(alerts) != 0 {
+		t.Errorf("Expected no April alerts, got %+v (%v)", alerts, err)
+	}
+
+	history, err := budgets.Alerts(AlertQuery{BudgetID: alpha.ID})
+	if err != nil || len(history) != 2 || history[0].Threshold != 1 {
+		t.Errorf("Expected two alerts, newest first, got %+v (%v)", history, err)
+	}
+}
+
+func TestBudgetWindowPeriod(t *testing.T) {
+	db, budgets := setupBudgetTest(t)
+	start := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	_, err := db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, is_active)
+		VALUES ('w1', ?, ?, ?, true)
+	`, start, start.Add(5*time.Hour), start.Add(5*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to insert window: %v", err)
+	}
+	insertBudgetMessage(t, db, "m1", "s1", start.Add(-time.Hour), 1000000, 0)
+	insertBudgetMessage(t, db, "m2", "s1", start.Add(time.Hour), 1000000, 0)
+
+	b, err := budgets.Create(Budget{Name: "window cost", Period: BudgetPeriodWindow, Metric: BudgetMetricCost, Amount: 2, Enabled: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	status, err := budgets.Status(*b, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.PeriodStart == nil || !status.PeriodStart.Equal(start) || status.Spent != 3 || !status.Exceeded {
+		t.Errorf("Expected $3 spent in the window, got %+v", status)
+	}
+
+	status, err = budgets.Status(*b, start.Add(6*time.Hour))
+	if err != nil || status.PeriodStart != nil || status.Spent != 0 {
+		t.Errorf("Expected no period outside a window, got %+v (%v)", status, err)
+	}
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Periods of a ccusage report, named after ccusage's commands
+const (
+	CCUsageDaily   = "daily"
+	CCUsageMonthly = "monthly"
+	CCUsageBlocks  = "blocks"
+)
+
+// CCUsageTokens are the token and cost totals of ccusage's JSON output,
+// whose total tokens include cache reads and writes
+type CCUsageTokens struct {
+	InputTokens         int64   `json:"inputTokens"`
+	OutputTokens        int64   `json:"outputTokens"`
+	CacheCreationTokens int64   `json:"cacheCreationTokens"`
+	CacheReadTokens     int64   `json:"cacheReadTokens"`
+	TotalTokens         int64   `json:"totalTokens"`
+	TotalCost           float64 `json:"totalCost"`
+}
+
+func (t *CCUsageTokens) add(m ExportedMessage) {
+	t.InputTokens += m.InputTokens
+	t.OutputTokens += m.OutputTokens
+	t.CacheCreationTokens += m.CacheCreationInputTokens
+	t.CacheReadTokens += m.CacheReadInputTokens
+	t.TotalTokens += m.InputTokens + m.OutputTokens + m.CacheCreationInputTokens + m.CacheReadInputTokens
+	t.TotalCost += m.Cost
+}
+
+// CCUsageModelBreakdown is one model's share of a day or month
+type CCUsageModelBreakdown struct {
+	ModelName           string  `json:"modelName"`
+	InputTokens         int64   `json:"inputTokens"`
+	OutputTokens        int64   `json:"outputTokens"`
+	CacheCreationTokens int64   `json:"cacheCreationTokens"`
+	CacheReadTokens     int64   `json:"cacheReadTokens"`
+	Cost                float64 `json:"cost"`
+}
+
+// CCUsagePeriod is a day or a month of usage, as in ccusage's daily and
+// monthly output
+type CCUsagePeriod struct {
+	Date  string `json:"date,omitempty"`
+	Month string `json:"month,omitempty"`
+	CCUsageTokens
+	ModelsUsed      []string                `json:"modelsUsed"`
+	ModelBreakdowns []CCUsageModelBreakdown `json:"modelBreakdowns"`
+}
+
+// CCUsageDailyReport is the output of ccusage daily --json
+type CCUsageDailyReport struct {
+	Daily  []CCUsagePeriod `json:"daily"`
+	Totals CCUsageTokens   `json:"totals"`
+}
+
+// CCUsageMonthlyReport is the output of ccusage monthly --json
+type CCUsageMonthlyReport struct {
+	Monthly []CCUsagePeriod `json:"monthly"`
+	Totals  CCUsageTokens   `json:"totals"`
+}
+
+// CCUsageTokenCounts are a block's tokens by kind
+type CCUsageTokenCounts struct {
+	InputTokens              int64 `json:"inputTokens"`
+	OutputTokens             int64 `json:"outputTokens"`
+	CacheCreationInputTokens int64 `json:"cacheCreationInputTokens"`
+	CacheReadInputTokens     int64 `json:"cacheReadInputTokens"`
+}
+
+// CCUsageBurnRate is an active block's rate of use so far
+type CCUsageBurnRate struct {
+	TokensPerMinute float64 `json:"tokensPerMinute"`
+	CostPerHour     float64 `json:"costPerHour"`
+}
+
+// CCUsageProjection is an active block's usage at its end at the burn rate
+type CCUsageProjection struct {
+	TotalTokens      int64   `json:"totalTokens"`
+	TotalCost        float64 `json:"totalCost"`
+	RemainingMinutes int     `json:"remainingMinutes"`
+}
+
+// CCUsageBlock is a billing block, as in ccusage's blocks output
+type CCUsageBlock struct {
+	ID            string             `json:"id"`
+	StartTime     time.Time          `json:"startTime"`
+	EndTime       time.Time          `json:"endTime"`
+	ActualEndTime *time.Time         `json:"actualEndTime"`
+	IsActive      bool               `json:"isActive"`
+	IsGap         bool               `json:"isGap"`
+	Entries       int                `json:"entries"`
+	TokenCounts   CCUsageTokenCounts `json:"tokenCounts"`
+	TotalTokens   int64              `json:"totalTokens"`
+	CostUSD       float64            `json:"costUSD"`
+	Models        []string           `json:"models"`
+	BurnRate      *CCUsageBurnRate   `json:"burnRate"`
+	Projection    *CCUsageProjection `json:"projection"`
+}
+
+// CCUsageBlocksReport is the output of ccusage blocks --json
+type CCUsageBlocksReport struct {
+	Blocks []CCUsageBlock `json:"blocks"`
+}
+
+// CCUsageQuery selects a ccusage report. Zero times leave the range open.
+type CCUsageQuery struct {
+	Period string
+	User   string
+	Since  time.Time
+	Until  time.Time
+	// Active limits a blocks report to the active block
+	Active bool
+	// Location sets the days and months of the report
+	Location *time.Location
+	Now      time.Time
+}
+
+// CCUsageService reports Claude Code usage in ccusage's JSON formats, so
+// scripts and status lines built for ccusage can read claudeee's database
+type CCUsageService struct {
+	exports *ExportService
+	mu      sync.RWMutex
+	loc     *time.Location
+}
+
+func NewCCUsageService(db *sql.DB) *CCUsageService {
+	return &CCUsageService{exports: NewExportService(db), loc: time.UTC}
+}
+
+// SetLocation sets the time zone of days and months without ?tz=
+func (s *CCUsageService) SetLocation(loc *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loc = loc
+}
+
+// Location returns the time zone set with SetLocation
+func (s *CCUsageService) Location() *time.Location {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loc
+}
+
+// Report returns the daily, monthly or blocks report of q. Like ccusage it
+// only covers Claude Code's assistant messages.
+func (s *CCUsageService) Report(q CCUsageQuery) (interface{}, error) {
+	if q.Location == nil {
+		q.Location = s.Location()
+	}
+	if q.Now.IsZero() {
+		q.Now = time.Now()
+	}
+
+	var messages []ExportedMessage
+	err := s.exports.StreamMessages(ExportQuery{User: q.User, From: q.Since, To: q.Until, Provider: ProviderClaude}, func(m ExportedMessage) error {
+		if m.Role == "assistant" {
+			messages = append(messages, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch q.Period {
+	case CCUsageDaily:
+		periods, totals := ccusagePeriods(messages, func(t time.Time) string { return t.In(q.Location).Format("2006-01-02") })
+		for i := range periods {
+			periods[i].Date, periods[i].Month = periods[i].Month, ""
+		}
+		return &CCUsageDailyReport{Daily: periods, Totals: totals}, nil
+	case CCUsageMonthly:
+		periods, totals := ccusagePeriods(messages, func(t time.Time) string { return t.In(q.Location).Format("2006-01") })
+		return &CCUsageMonthlyReport{Monthly: periods, Totals: totals}, nil
+	case CCUsageBlocks:
+		blocks := ccusageBlocks(messages, currentWindowPolicy().Duration, q.Now)
+		if q.Active {
+			active := []CCUsageBlock{}
+			for _, block := range blocks {
+				if block.IsActive {
+					active = append(active, block)
+				}
+			}
+			blocks = active
+		}
+		return &CCUsageBlocksReport{Blocks: blocks}, nil
+	}
+	return nil, fmt.Errorf("unknown ccusage period %q (expected daily, monthly or blocks)", q.Period)
+}
+
+// ccusagePeriods totals messages by the period key returns for their
+// timestamps, in order; the key is stored as the period's month
+func ccusagePeriods(messages []ExportedMessage, key func(time.Time) string) ([]CCUsagePeriod, CCUsageTokens) {
+	var totals CCUsageTokens
+	byKey := make(map[string]*CCUsagePeriod)
+	breakdowns := make(map[string]map[string]*CCUsageModelBreakdown)
+	for _, m := range messages {
+		totals.add(m)
+		k := key(m.Timestamp)
+		period, ok := byKey[k]
+		if !ok {
+			period = &CCUsagePeriod{Month: k}
+			byKey[k] = period
+			breakdowns[k] = make(map[string]*CCUsageModelBreakdown)
+		}
+		period.add(m)
+
+		model := m.Model
+		if model == "" {
+			model = "unknown"
+		}
+		breakdown, ok := breakdowns[k][model]
+		if !ok {
+			breakdown = &CCUsageModelBreakdown{ModelName: model}
+			breakdowns[k][model] = breakdown
+		}
+		breakdown.InputTokens += m.InputTokens
+		breakdown.OutputTokens += m.OutputTokens
+		breakdown.CacheCreationTokens += m.CacheCreationInputTokens
+		breakdown.CacheReadTokens += m.CacheReadInputTokens
+		breakdown.Cost += m.Cost
+	}
+
+	periods := []CCUsagePeriod{}
+	for k, period := range byKey {
+		period.ModelsUsed = []string{}
+		period.ModelBreakdowns = []CCUsageModelBreakdown{}
+		for model, breakdown := range breakdowns[k] {
+			breakdown.Cost = roundToDecimals(breakdown.Cost, 6)
+			period.ModelsUsed = append(period.ModelsUsed, model)
+			period.ModelBreakdowns = append(period.ModelBreakdowns, *breakdown)
+		}
+		sort.Strings(period.ModelsUsed)
+		sort.Slice(period.ModelBreakdowns, func(i, j int) bool {
+			return period.ModelBreakdowns[i].Cost > period.ModelBreakdowns[j].Cost
+		})
+		period.TotalCost = roundToDecimals(period.TotalCost, 6)
+		periods = append(periods, *period)
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Month < periods[j].Month })
+	totals.TotalCost = roundToDecimals(totals.TotalCost, 6)
+	return periods, totals
+}
+
+// ccusageBlocks groups messages, in time order, into blocks the way ccusage
+// does: a block starts on the hour of its first message and lasts duration;
+// a message after its end, or after a pause as long, starts the next one
+func ccusageBlocks(messages []ExportedMessage, duration time.Duration, now time.Time) []CCUsageBlock {
+	blocks := []CCUsageBlock{}
+	var block *CCUsageBlock
+	var first, last time.Time
+	models := make(map[string]bool)
+	finish := func() {
+		if block == nil {
+			return
+		}
+		end := last
+		block.ActualEndTime = &end
+		block.IsActive = now.Sub(last) < duration && now.Before(block.EndTime)
+		for model := range models {
+			block.Models = append(block.Models, model)
+		}
+		sort.Strings(block.Models)
+		if elapsed := last.Sub(first).Minutes(); block.IsActive && elapsed > 0 {
+			rate := &CCUsageBurnRate{
+				TokensPerMinute: float64(block.TotalTokens) / elapsed,
+				CostPerHour:     block.CostUSD / elapsed * 60,
+			}
+			remaining := block.EndTime.Sub(now).Minutes()
+			block.BurnRate = rate
+			block.Projection = &CCUsageProjection{
+				TotalTokens:      block.TotalTokens + int64(rate.TokensPerMinute*remaining),
+				TotalCost:        roundToDecimals(block.CostUSD+rate.CostPerHour*remaining/60, 6),
+				RemainingMinutes: int(remaining),
+			}
+		}
+		block.CostUSD = roundToDecimals(block.CostUSD, 6)
+		blocks = append(blocks, *block)
+		block = nil
+	}
+
+	for _, m := range messages {
+		t := m.Timestamp.UTC()
+		if block != nil && (t.Sub(block.StartTime) >= duration || t.Sub(last) >= duration) {
+			finish()
+		}
+		if block == nil {
+			start := t.Truncate(time.Hour)
+			block = &CCUsageBlock{
+				ID:        start.Format("2006-01-02T15:04:05.000Z"),
+				StartTime: start,
+				EndTime:   start.Add(duration),
+				Models:    []string{},
+			}
+			first = t
+			models = make(map[string]bool)
+		}
+		last = t
+		block.Entries++
+		block.TokenCounts.InputTokens += m.InputTokens
+		block.TokenCounts.OutputTokens += m.OutputTokens
+		block.TokenCounts.CacheCreationInputTokens += m.CacheCreationInputTokens
+		block.TokenCounts.CacheReadInputTokens += m.CacheReadInputTokens
+		block.TotalTokens += m.InputTokens + m.OutputTokens + m.CacheCreationInputTokens + m.CacheReadInputTokens
+		block.CostUSD += m.Cost
+		if m.Model != "" {
+			models[m.Model] = true
+		}
+	}
+	finish()
+	return blocks
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCCUsageReports(t *testing.T) {
+	db, _ := setupExportTest(t)
+	start := time.Date(2025, 1, 31, 22, 10, 0, 0, time.UTC)
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'alpha', '/alpha', ?)`, start); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	insertExportMessage(t, db, "m1", "s1", "assistant", "claude-sonnet-4-20250514", start, 1000, 100)
+	insertExportMessage(t, db, "m2", "s1", "user", "", start.Add(time.Minute), 0, 0)
+	insertExportMessage(t, db, "m3", "s1", "assistant", "claude-opus-4-20250514", start.Add(2*time.Hour), 2000, 200)
+	// Past the end of the first block
+	insertExportMessage(t, db, "m4", "s1", "assistant", "claude-sonnet-4-20250514", start.Add(6*time.Hour), 500, 50)
+	insertExportMessage(t, db, "m6", "s1", "assistant", "claude-sonnet-4-20250514", start.Add(6*time.Hour+20*time.Minute), 100, 10)
+	// Other tools' usage is not ccusage's
+	insertExportMessage(t, db, "m5", "s1", "assistant", "gpt-5-codex", start.Add(6*time.Hour), 9000, 900)
+	if _, err := db.Exec(`UPDATE messages SET provider = 'codex' WHERE id = 'm5'`); err != nil {
+		t.Fatal(err)
+	}
+
+	ccusage := NewCCUsageService(db)
+	report, err := ccusage.Report(CCUsageQuery{Period: CCUsageDaily})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	daily := report.(*CCUsageDailyReport)
+	if len(daily.Daily) != 2 || daily.Daily[0].Date != "2025-01-31" || daily.Daily[1].Date != "2025-02-01" {
+		t.Fatalf("Expected two days in UTC, got %+v", daily.Daily)
+	}
+	if daily.Totals.InputTokens != 3600 || daily.Totals.TotalTokens != 3960 || daily.Totals.TotalCost <= 0 {
+		t.Errorf("Unexpected totals %+v", daily.Totals)
+	}
+	if models := daily.Daily[1].ModelsUsed; len(models) != 2 || models[0] != "claude-opus-4-20250514" {
+		t.Errorf("Unexpected models %v", models)
+	}
+	encoded, _ := json.Marshal(daily.Daily[0])
+	for _, key := range []string{`"date":"2025-01-31"`, `"cacheCreationTokens":0`, `"modelBreakdowns":[{"modelName"`} {
+		if !strings.Contains(string(encoded), key) {
+			t.Errorf("Expected %s in %s", key, encoded)
+		}
+	}
+	if strings.Contains(string(encoded), `"month"`) {
+		t.Errorf("Expected no month in a daily entry: %s", encoded)
+	}
+
+	// Tokyo is nine hours ahead: all of it falls on February 1st
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	report, err = ccusage.Report(CCUsageQuery{Period: CCUsageMonthly, Location: tokyo})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if monthly := report.(*CCUsageMonthlyReport).Monthly; len(monthly) != 1 || monthly[0].Month != "2025-02" {
+		t.Errorf("Expected February only, got %+v", monthly)
+	}
+
+	now := start.Add(6*time.Hour + 30*time.Minute)
+	report, err = ccusage.Report(CCUsageQuery{Period: CCUsageBlocks, Now: now})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	blocks := report.(*CCUsageBlocksReport).Blocks
+	if len(blocks) != 2 {
+		t.Fatalf("Expected two blocks, got %+v", blocks)
+	}
+	first := blocks[0]
+	if first.ID != "2025-01-31T22:00:00.000Z" || !first.EndTime.Equal(start.Add(-10*time.Minute+5*time.Hour)) {
+		t.Errorf("Expected a block from 22:00 to 03:00, got %+v", first)
+	}
+	if first.Entries != 2 || first.IsActive || first.BurnRate != nil || !first.ActualEndTime.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("Unexpected first block %+v", first)
+	}
+	if second := blocks[1]; !second.IsActive || second.TotalTokens != 660 || second.BurnRate == nil || second.BurnRate.TokensPerMinute != 33 {
+		t.Errorf("Expected the active block at 33 tokens a minute, got %+v", second)
+	} else if second.Projection == nil || second.Projection.RemainingMinutes != 260 || second.Projection.TotalTokens != 660+33*260 {
+		t.Errorf("Unexpected projection %+v", second.Projection)
+	}
+
+	report, err = ccusage.Report(CCUsageQuery{Period: CCUsageBlocks, Now: now, Active: true})
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if blocks := report.(*CCUsageBlocksReport).Blocks; len(blocks) != 1 || !blocks[0].IsActive {
+		t.Errorf("Expected only the active block, got %+v", blocks)
+	}
+
+	if _, err := ccusage.Report(CCUsageQuery{Period: "weekly"}); err == nil {
+		t.Error("Expected an error for an unknown period")
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+)
+
+// codexLogPrefix starts the name of every Codex CLI session log, e.g.
+// rollout-2025-09-10T14-23-45-<session id>.jsonl
+const codexLogPrefix = "rollout-"
+
+// codexLogSource reads the session logs of OpenAI's Codex CLI, which keeps
+// one file per session in dated directories below its sessions directory
+type codexLogSource struct {
+	dirs []string
+}
+
+func (s codexLogSource) Provider() string { return ProviderCodex }
+
+// Discover lists the session logs below each directory. Directories that do
+// not exist are skipped: most users do not run Codex.
+func (s codexLogSource) Discover() ([]models.FileInfo, error) {
+	logger := logging.Component("sync")
+	var files []models.FileInfo
+	for _, dir := range s.dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			logger.Debug("Codex sessions directory not found", "dir", dir)
+			continue
+		}
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !isLogFile(entry.Name()) || !strings.HasPrefix(entry.Name(), codexLogPrefix) {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				logger.Warn("Failed to stat log file", "file", path, "err", err)
+				return nil
+			}
+			files = append(files, models.FileInfo{
+				Path:    path,
+				ModTime: info.ModTime(),
+				Size:    info.Size(),
+				ID:      fileIdentity(info),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (s codexLogSource) NewDecoder(path string) LogDecoder {
+	return &codexDecoder{sessionID: codexSessionID(path)}
+}
+
+// codexSessionID takes the session ID from the end of a log's file name, so
+// lines read after a resume position belong to the session too
+func codexSessionID(path string) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".jsonl")
+	// Session IDs are UUIDs, 36 characters
+	if len(name) > len(codexLogPrefix)+36 {
+		return name[len(name)-36:]
+	}
+	return strings.TrimPrefix(name, codexLogPrefix)
+}
+
+// codexLine is one line of a Codex session log
+type codexLine struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// codexPayload holds the fields used from the payloads of session_meta,
+// turn_context, response_item and event_msg lines
+type codexPayload struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Cwd        string `json:"cwd"`
+	Model      string `json:"model"`
+	CLIVersion string `json:"cli_version"`
+	Role       string `json:"role"`
+	Content    []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Info *struct {
+		TotalTokenUsage *codexTokenUsage `json:"total_token_usage"`
+		LastTokenUsage  *codexTokenUsage `json:"last_token_usage"`
+	} `json:"info"`
+}
+
+// codexTokenUsage is the usage of OpenAI's API: input tokens include the
+// cached ones, output tokens the reasoning ones
+type codexTokenUsage struct {
+	InputTokens       int `json:"input_tokens"`
+	CachedInputTokens int `json:"cached_input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+	TotalTokens       int `json:"total_tokens"`
+}
+
+// codexDecoder turns a Codex session log into log entries: the user's and
+// the model's messages, and an assistant entry with the usage of each model
+// call. The session, working directory and model are set by earlier lines.
+type codexDecoder struct {
+	sessionID string
+	cwd       string
+	model     string
+	version   string
+	// total is the session's token count at the last usage event; Codex
+	// repeats the event when nothing new was used
+	total int
+}
+
+func (d *codexDecoder) Observe(line []byte) {
+	d.Decode(line)
+}
+
+func (d *codexDecoder) Decode(line []byte) ([]models.LogEntry, error) {
+	var record codexLine
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, err
+	}
+	// Logs of Codex versions before session_meta carry no usage
+	if record.Type == "" || len(record.Payload) == 0 {
+		return nil, nil
+	}
+	var payload codexPayload
+	if err := json.Unmarshal(record.Payload, &payload); err != nil {
+		return nil, err
+	}
+
+	switch record.Type {
+	case "session_meta":
+		if payload.ID != "" {
+			d.sessionID = payload.ID
+		}
+		if payload.Cwd != "" {
+			d.cwd = payload.Cwd
+		}
+		d.version = payload.CLIVersion
+	case "turn_context":
+		if payload.Cwd != "" {
+			d.cwd = payload.Cwd
+		}
+		if payload.Model != "" {
+			d.model = payload.Model
+		}
+	case "response_item":
+		if payload.Type != "message" || payload.Role != "user" && payload.Role != "assistant" {
+			return nil, nil
+		}
+		var texts []string
+		for _, content := range payload.Content {
+			if content.Text != "" {
+				texts = append(texts, content.Text)
+			}
+		}
+		text := strings.Join(texts, "\n")
+		// Codex passes its environment and AGENTS.md to the model as user messages
+		if text == "" || strings.HasPrefix(text, "<environment_context>") || strings.HasPrefix(text, "<user_instructions>") {
+			return nil, nil
+		}
+		entry := d.entry(record.Timestamp, payload.Role)
+		entry.Message.Content = text
+		return []models.LogEntry{entry}, nil
+	case "event_msg":
+		if payload.Type != "token_count" || payload.Info == nil || payload.Info.LastTokenUsage == nil {
+			return nil, nil
+		}
+		if total := payload.Info.TotalTokenUsage; total != nil {
+			if total.TotalTokens == d.total {
+				return nil, nil
+			}
+			d.total = total.TotalTokens
+		}
+		usage := payload.Info.LastTokenUsage
+		input := usage.InputTokens - usage.CachedInputTokens
+		if input < 0 {
+			input = 0
+		}
+		entry := d.entry(record.Timestamp, "assistant")
+		entry.Message.Usage = &models.Usage{
+			InputTokens:          input,
+			CacheReadInputTokens: usage.CachedInputTokens,
+			OutputTokens:         usage.OutputTokens,
+		}
+		return []models.LogEntry{entry}, nil
+	}
+	return nil, nil
+}
+
+// entry returns an entry of the session at timestamp
+func (d *codexDecoder) entry(timestamp time.Time, role string) models.LogEntry {
+	messageType := "message"
+	entry := models.LogEntry{
+		UserType:  "external",
+		Cwd:       d.cwd,
+		SessionID: d.sessionID,
+		Version:   d.version,
+		Type:      role,
+		Message:   models.LogMessage{Type: &messageType, Role: role},
+		Timestamp: timestamp,
+		Provider:  ProviderCodex,
+	}
+	if d.model != "" {
+		model := d.model
+		entry.Message.Model = &model
+	}
+	return entry
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const codexSessionLog = `{"timestamp":"2025-09-10T14:23:45.000Z","type":"session_meta","payload":{"id":"0199332c-1111-7222-8333-444455556666","timestamp":"2025-09-10T14:23:45.000Z","cwd":"/work/api","originator":"codex_cli_rs","cli_version":"0.34.0"}}
+{"timestamp":"2025-09-10T14:23:46.000Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>\n  <cwd>/work/api</cwd>\n</environment_context>"}]}}
+{"timestamp":"2025-09-10T14:23:46.500Z","type":"turn_context","payload":{"cwd":"/work/api","approval_policy":"on-request","model":"gpt-5-codex"}}
+{"timestamp":"2025-09-10T14:23:47.000Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"Add a health check"}]}}
+{"timestamp":"2025-09-10T14:23:50.000Z","type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"input_tokens":1000000,"cached_input_tokens":400000,"output_tokens":100000,"reasoning_output_tokens":50000,"total_tokens":1100000},"last_token_usage":{"input_tokens":1000000,"cached_input_tokens":400000,"output_tokens":100000,"reasoning_output_tokens":50000,"total_tokens":1100000}}}}
+{"timestamp":"2025-09-10T14:23:50.100Z","type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"input_tokens":1000000,"cached_input_tokens":400000,"output_tokens":100000,"reasoning_output_tokens":50000,"total_tokens":1100000},"last_token_usage":{"input_tokens":1000000,"cached_input_tokens":400000,"output_tokens":100000,"reasoning_output_tokens":50000,"total_tokens":1100000}}}}
+{"timestamp":"2025-09-10T14:23:51.000Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"Added GET /healthz."}]}}
+`
+
+func TestCodexDecoder(t *testing.T) {
+	source := codexLogSource{}
+	decoder := source.NewDecoder("/logs/2025/09/10/rollout-2025-09-10T14-23-45-0199332c-1111-7222-8333-444455556666.jsonl")
+
+	var roles []string
+	for _, line := range strings.Split(strings.TrimSpace(codexSessionLog), "\n") {
+		entries, err := decoder.Decode([]byte(line))
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		for _, entry := range entries {
+			roles = append(roles, entry.Message.Role)
+			if entry.SessionID != "0199332c-1111-7222-8333-444455556666" || entry.Cwd != "/work/api" || entry.Provider != ProviderCodex {
+				t.Errorf("Unexpected entry %+v", entry)
+			}
+			if usage := entry.Message.Usage; usage != nil {
+				if usage.InputTokens != 600000 || usage.CacheReadInputTokens != 400000 || usage.OutputTokens != 100000 {
+					t.Errorf("Unexpected usage %+v", usage)
+				}
+				if entry.Message.Model == nil || *entry.Message.Model != "gpt-5-codex" {
+					t.Errorf("Expected the turn's model, got %v", entry.Message.Model)
+				}
+			}
+		}
+	}
+	// The environment context and the repeated usage event are skipped
+	if strings.Join(roles, ",") != "user,assistant,assistant" {
+		t.Errorf("Unexpected entries %v", roles)
+	}
+
+	if _, err := decoder.Decode([]byte(`{"type":`)); err == nil {
+		t.Error("Expected an error for a malformed line")
+	}
+}
+
+func TestSyncCodexLogs(t *testing.T) {
+	claudeRoot := t.TempDir()
+	codexDir := t.TempDir()
+	day := filepath.Join(codexDir, "2025", "09", "10")
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(day, "rollout-2025-09-10T14-23-45-0199332c-1111-7222-8333-444455556666.jsonl")
+	lines := strings.SplitAfter(codexSessionLog, "\n")
+	// The file is synced in two passes, the second resuming after the usage
+	if err := os.WriteFile(logPath, []byte(strings.Join(lines[:5], "")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, diffSync := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+	diffSync.SetLogSources(LogSourceConfig{Roots: []string{claudeRoot}, CodexDirs: []string{codexDir}})
+	if _, err := diffSync.SyncAllLogs(context.Background()); err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+	if err := os.WriteFile(logPath, []byte(codexSessionLog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diffSync.SyncAllLogs(context.Background()); err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+
+	var provider, project string
+	if err := db.QueryRow(`SELECT provider, project_name FROM sessions WHERE id = '0199332c-1111-7222-8333-444455556666'`).Scan(&provider, &project); err != nil {
+		t.Fatalf("Failed to read session: %v", err)
+	}
+	if provider != ProviderCodex || project != "api" {
+		t.Errorf("Expected a codex session of project api, got %s, %s", provider, project)
+	}
+
+	var messages, codexMessages, windowed, input, cached int
+	err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE provider = 'codex'), COUNT(session_window_id),
+			SUM(input_tokens), SUM(cache_read_input_tokens)
+		FROM messages WHERE session_id = '0199332c-1111-7222-8333-444455556666'`).Scan(&messages, &codexMessages, &windowed, &input, &cached)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	// The resumed pass knows the session from the lines before it
+	if messages != 3 || codexMessages != 3 {
+		t.Errorf("Expected 3 codex messages, got %d (%d codex)", messages, codexMessages)
+	}
+	if windowed != 0 {
+		t.Errorf("Expected codex messages outside Claude's windows, got %d in windows", windowed)
+	}
+	if input != 600000 || cached != 400000 {
+		t.Errorf("Expected 600000 input and 400000 cached tokens, got %d and %d", input, cached)
+	}
+
+	// $0.75 input, $0.05 cached input and $1 output at gpt-5-codex prices
+	if cost := NewPricingCalculator().CalculateCost("gpt-5-codex", input, 100000, 0, cached); cost != 1.8 {
+		t.Errorf("Expected $1.80, got %v", cost)
+	}
+}
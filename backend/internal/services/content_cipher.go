@@ -0,0 +1,249 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// encryptedContentPrefix marks content sealed by a ContentCipher. The version
+// leaves room for a different scheme later.
+const encryptedContentPrefix = "enc:v1:"
+
+// EncryptedContentPlaceholder is returned in place of content that cannot be
+// decrypted, e.g. when the server was started without the content key
+const EncryptedContentPlaceholder = "[encrypted]"
+
+// ErrContentKeyMismatch is returned when the configured key is not the one
+// existing content was encrypted with
+var ErrContentKeyMismatch = errors.New("content key does not match the key used for stored content")
+
+// ContentCipher encrypts message content with AES-256-GCM so a copied
+// database file reveals nothing but token counts and metadata. A nil
+// *ContentCipher stores content in plain text.
+type ContentCipher struct {
+	aead  cipher.AEAD
+	keyID string
+}
+
+// NewContentCipher creates a cipher from a 32 byte key
+func NewContentCipher(key []byte) (*ContentCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("content key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create content cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create content cipher: %w", err)
+	}
+
+	// Identifies the key without revealing it, so a wrong key is caught at startup
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("claudeee content key"))
+	return &ContentCipher{aead: aead, keyID: hex.EncodeToString(mac.Sum(nil))[:16]}, nil
+}
+
+// ParseContentKey decodes a key given as 64 hex characters or base64
+func ParseContentKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if key, err := hex.DecodeString(value); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(value); err == nil && len(key) == 32 {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("content key must be 32 bytes encoded as hex or base64")
+}
+
+// LoadContentKey reads the key from value or, when value is empty, from
+// keyFile. It returns nil when neither is set.
+func LoadContentKey(value, keyFile string) ([]byte, error) {
+	if value == "" && keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read content key file: %w", err)
+		}
+		value = string(data)
+	}
+	if value == "" {
+		return nil, nil
+	}
+	return ParseContentKey(value)
+}
+
+// Seal encrypts content; nil stays nil
+func (c *ContentCipher) Seal(content *string) *string {
+	if c == nil || content == nil {
+		return content
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("failed to generate nonce: %v", err))
+	}
+	sealed := encryptedContentPrefix + base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(*content), nil))
+	return &sealed
+}
+
+// Open decrypts content sealed with Seal and decompresses content compressed
+// by the content policy, which needs no cipher. Plain text content is
+// returned unchanged; content that cannot be decrypted becomes the
+// placeholder.
+func (c *ContentCipher) Open(content *string) *string {
+	if content == nil {
+		return nil
+	}
+	opened, ok := c.decrypt(*content)
+	if !ok {
+		placeholder := EncryptedContentPlaceholder
+		return &placeholder
+	}
+	if opened == *content && !IsCompressedContent(opened) {
+		return content
+	}
+	opened = decompressContent(opened)
+	return &opened
+}
+
+// decrypt returns sealed content decrypted, still compressed if it was, and
+// other content unchanged. It fails when content cannot be decrypted.
+func (c *ContentCipher) decrypt(content string) (string, bool) {
+	if !IsEncryptedContent(content) {
+		return content, true
+	}
+	if c == nil {
+		return "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(content, encryptedContentPrefix))
+	if err != nil || len(data) < c.aead.NonceSize() {
+		return "", false
+	}
+	nonce, ciphertext := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", false
+	}
+	return string(plain), true
+}
+
+// IsEncryptedContent reports whether stored content was sealed by a ContentCipher
+func IsEncryptedContent(content string) bool {
+	return strings.HasPrefix(content, encryptedContentPrefix)
+}
+
+// CheckContentKey records which key encrypts content on first use and
+// afterwards refuses a different one, so content is never encrypted with a
+// mix of keys. Without a cipher it reports whether encrypted content exists.
+func CheckContentKey(db *sql.DB, c *ContentCipher) (encrypted bool, err error) {
+	var keyID string
+	err = db.QueryRow(`SELECT key_id FROM content_encryption WHERE id = 1`).Scan(&keyID)
+	switch {
+	case err == sql.ErrNoRows:
+		if c == nil {
+			return false, nil
+		}
+		if _, err := db.Exec(`INSERT INTO content_encryption (id, key_id) VALUES (1, ?)`, c.keyID); err != nil {
+			return false, fmt.Errorf("failed to record content key: %w", err)
+		}
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to read content key: %w", err)
+	case c != nil && keyID != c.keyID:
+		return true, ErrContentKeyMismatch
+	}
+	return true, nil
+}
+
+// EncryptStoredContent encrypts plain text content, session titles and
+// quarantined log lines stored before encryption was enabled and returns the
+// number of messages encrypted
+func EncryptStoredContent(db *sql.DB, c *ContentCipher) (int64, error) {
+	if c == nil {
+		return 0, nil
+	}
+	if err := encryptStoredTitles(db, c); err != nil {
+		return 0, err
+	}
+	_, err := rewriteParseErrors(db, "NOT starts_with(raw_content, ?)", []interface{}{encryptedContentPrefix},
+		func(raw string) (*string, bool) { return c.Seal(&raw), true })
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt parse errors: %w", err)
+	}
+
+	var changed int64
+	// Encrypted rows no longer match, so each query sees the next batch
+	for {
+		rows, err := db.Query(`
+			SELECT id, content FROM messages
+			WHERE content IS NOT NULL AND NOT starts_with(content, ?)
+			LIMIT 500
+		`, encryptedContentPrefix)
+		if err != nil {
+			return changed, fmt.Errorf("failed to find plain text content: %w", err)
+		}
+		sealed := map[string]string{}
+		for rows.Next() {
+			var id, content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return changed, fmt.Errorf("failed to scan message: %w", err)
+			}
+			sealed[id] = *c.Seal(&content)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return changed, fmt.Errorf("failed to find plain text content: %w", err)
+		}
+		if len(sealed) == 0 {
+			return changed, nil
+		}
+
+		for id, content := range sealed {
+			if _, err := db.Exec(`UPDATE messages SET content = ? WHERE id = ?`, content, id); err != nil {
+				return changed, fmt.Errorf("failed to encrypt message %s: %w", id, err)
+			}
+			changed++
+		}
+	}
+}
+
+// encryptStoredTitles encrypts plain text session titles
+func encryptStoredTitles(db *sql.DB, c *ContentCipher) error {
+	rows, err := db.Query(`SELECT id, title FROM sessions WHERE title IS NOT NULL AND NOT starts_with(title, ?)`, encryptedContentPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to find plain text titles: %w", err)
+	}
+	sealed := map[string]string{}
+	for rows.Next() {
+		var id, title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan session title: %w", err)
+		}
+		sealed[id] = *c.Seal(&title)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to find plain text titles: %w", err)
+	}
+	for id, title := range sealed {
+		if _, err := db.Exec(`UPDATE sessions SET title = ? WHERE id = ?`, title, id); err != nil {
+			return fmt.Errorf("failed to encrypt title of session %s: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCipher(t *testing.T, fill byte) *ContentCipher {
+	c, err := NewContentCipher(bytes.Repeat([]byte{fill}, 32))
+	if err != nil {
+		t.Fatalf("NewContentCipher failed: %v", err)
+	}
+	return c
+}
+
+func TestContentCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, 1)
+	content := "let me show you the config file"
+
+	sealed := c.Seal(&content)
+	if !IsEncryptedContent(*sealed) || strings.Contains(*sealed, "config") {
+		t.Fatalf("Expected sealed content, got %q", *sealed)
+	}
+	if opened := c.Open(sealed); *opened != content {
+		t.Errorf("Expected %q, got %q", content, *opened)
+	}
+	if again := c.Seal(&content); *again == *sealed {
+		t.Error("Expected a fresh nonce for every seal")
+	}
+
+	if opened := newTestCipher(t, 2).Open(sealed); *opened != EncryptedContentPlaceholder {
+		t.Errorf("Expected placeholder with the wrong key, got %q", *opened)
+	}
+	var none *ContentCipher
+	if opened := none.Open(sealed); *opened != EncryptedContentPlaceholder {
+		t.Errorf("Expected placeholder without a key, got %q", *opened)
+	}
+	plain := "stored before encryption"
+	if opened := c.Open(&plain); *opened != plain {
+		t.Errorf("Expected plain text to pass through, got %q", *opened)
+	}
+	if c.Seal(nil) != nil || none.Seal(&plain) != &plain {
+		t.Error("Expected nil content and a nil cipher to leave content as is")
+	}
+}
+
+func TestParseContentKey(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	for _, value := range []string{hex.EncodeToString(key), base64.StdEncoding.EncodeToString(key) + "\n", base64.RawURLEncoding.EncodeToString(key)} {
+		got, err := ParseContentKey(value)
+		if err != nil || !bytes.Equal(got, key) {
+			t.Errorf("Expected %q to decode to the key, got %v (%v)", value, got, err)
+		}
+	}
+	if _, err := ParseContentKey("too short"); err == nil {
+		t.Error("Expected short key to be rejected")
+	}
+}
+
+func TestCheckContentKeyRejectsOtherKey(t *testing.T) {
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	if encrypted, err := CheckContentKey(db, nil); err != nil || encrypted {
+		t.Fatalf("Expected no encryption yet, got %v (%v)", encrypted, err)
+	}
+	if _, err := CheckContentKey(db, newTestCipher(t, 1)); err != nil {
+		t.Fatalf("Expected first key to be recorded: %v", err)
+	}
+	if _, err := CheckContentKey(db, newTestCipher(t, 1)); err != nil {
+		t.Errorf("Expected same key to be accepted: %v", err)
+	}
+	if _, err := CheckContentKey(db, newTestCipher(t, 2)); !errors.Is(err, ErrContentKeyMismatch) {
+		t.Errorf("Expected ErrContentKeyMismatch, got %v", err)
+	}
+	if encrypted, err := CheckContentKey(db, nil); err != nil || !encrypted {
+		t.Errorf("Expected encryption to be reported without a key, got %v (%v)", encrypted, err)
+	}
+}
+
+func TestEncryptedContentSyncAndRead(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+	c := newTestCipher(t, 1)
+	diffSyncService.SetContentCipher(c)
+
+	if _, err := db.Exec(`INSERT INTO messages (id, session_id, content, timestamp) VALUES ('old', 'session1', 'stored in plain text', '2024-01-01 09:00:00')`); err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+	if n, err := EncryptStoredContent(db, c); err != nil || n != 1 {
+		t.Fatalf("Expected 1 encrypted message, got %d (%v)", n, err)
+	}
+
+	tmpFile, err := os.CreateTemp("", "test-encrypt-*.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(`{"uuid":"e1","sessionId":"session1","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"top secret plan"}}` + "\n")
+	tmpFile.Close()
+	if _, _, err := diffSyncService.processFileFromLine(tmpFile.Name(), 0); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+
+	var stored string
+	if err := db.QueryRow(`SELECT content FROM messages WHERE id = 'e1'`).Scan(&stored); err != nil {
+		t.Fatalf("Failed to query message: %v", err)
+	}
+	if !IsEncryptedContent(stored) {
+		t.Errorf("Expected encrypted content in the database, got %q", stored)
+	}
+
+	sessionService := NewSessionService(db)
+	sessionService.SetContentCipher(c)
+	messages, err := sessionService.GetSessionMessages("session1")
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	found := map[string]string{}
+	for _, m := range messages {
+		if m.Content != nil {
+			found[m.ID] = *m.Content
+		}
+	}
+	if found["e1"] != "top secret plan" || found["old"] != "stored in plain text" {
+		t.Errorf("Expected decrypted content, got %v", found)
+	}
+}
+
+func TestEncryptStoredContentEncryptsParseErrors(t *testing.T) {
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+	c := newTestCipher(t, 1)
+	insertParseError(t, db, 1, `{"message":"top secret plan"`, time.Now())
+
+	if _, err := EncryptStoredContent(db, c); err != nil {
+		t.Fatalf("Failed to encrypt stored content: %v", err)
+	}
+	raw := storedParseError(t, db, 1)
+	if raw == nil || !IsEncryptedContent(*raw) || *c.Open(raw) != `{"message":"top secret plan"` {
+		t.Errorf("Expected the quarantined line encrypted, got %v", raw)
+	}
+	sealed := *raw
+
+	// Lines already encrypted are left alone
+	if _, err := EncryptStoredContent(db, c); err != nil {
+		t.Fatalf("Failed to encrypt stored content: %v", err)
+	}
+	if raw := storedParseError(t, db, 1); *raw != sealed {
+		t.Error("Expected the encrypted line not to be encrypted again")
+	}
+}
+
+func TestStripContentTruncatesEncryptedContent(t *testing.T) {
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+	c := newTestCipher(t, 1)
+
+	long, short := strings.Repeat("x", 3000), strings.Repeat("y", 1000)
+	for id, content := range map[string]string{"long": long, "short": short} {
+		if _, err := db.Exec(`INSERT INTO messages (id, session_id, content) VALUES (?, 's', ?)`, id, *c.Seal(&content)); err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	changed, err := StripContent(db, ContentPolicy{Mode: ContentPolicyTruncated, MaxKB: 1}, c)
+	if err != nil {
+		t.Fatalf("Failed to strip content: %v", err)
+	}
+	if changed != 1 {
+		t.Errorf("Expected 1 truncated message, got %d", changed)
+	}
+
+	var stored string
+	db.QueryRow(`SELECT content FROM messages WHERE id = 'long'`).Scan(&stored)
+	if opened := c.Open(&stored); len(*opened) != 1024 {
+		t.Errorf("Expected content truncated to 1024 bytes, got %d", len(*opened))
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressedContentPrefix marks content compressed with zstd and encoded
+// as base64, so it stays text in both databases
+const compressedContentPrefix = "zst:v1:"
+
+// minCompressBytes is the size below which content is stored as it is; the
+// frame and base64 overhead would outweigh the savings
+const minCompressBytes = 512
+
+// EncodeAll and DecodeAll are safe for concurrent use
+var (
+	contentEncoder *zstd.Encoder
+	contentDecoder *zstd.Decoder
+)
+
+// The options are fixed, so a constructor error is a programming error
+func init() {
+	var err error
+	if contentEncoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault)); err != nil {
+		panic("failed to create zstd encoder: " + err.Error())
+	}
+	if contentDecoder, err = zstd.NewReader(nil); err != nil {
+		panic("failed to create zstd decoder: " + err.Error())
+	}
+}
+
+// IsCompressedContent reports whether stored content was compressed by the
+// content policy
+func IsCompressedContent(content string) bool {
+	return strings.HasPrefix(content, compressedContentPrefix)
+}
+
+// compressContent returns content compressed, or unchanged when that does
+// not make it smaller
+func compressContent(content string) string {
+	if len(content) < minCompressBytes || IsCompressedContent(content) {
+		return content
+	}
+	compressed := compressedContentPrefix + base64.StdEncoding.EncodeToString(contentEncoder.EncodeAll([]byte(content), nil))
+	if len(compressed) >= len(content) {
+		return content
+	}
+	return compressed
+}
+
+// decompressContent returns compressed content as it was before, and other
+// content unchanged. Content that cannot be decompressed is returned as is.
+func decompressContent(content string) string {
+	if !IsCompressedContent(content) {
+		return content
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(content, compressedContentPrefix))
+	if err != nil {
+		return content
+	}
+	plain, err := contentDecoder.DecodeAll(data, nil)
+	if err != nil {
+		return content
+	}
+	return string(plain)
+}
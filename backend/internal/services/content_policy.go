@@ -0,0 +1,375 @@
+package services
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"claudeee-backend/internal/models"
+)
+
+// Content storage policies applied to message content at ingest
+const (
+	ContentPolicyFull      = "full"
+	ContentPolicyTruncated = "truncated"
+	ContentPolicyMetadata  = "metadata"
+)
+
+// ContentPolicy decides how much message content is kept in the database,
+// and whether it is compressed. Token counts and other metadata are always
+// stored.
+type ContentPolicy struct {
+	Mode     string `json:"mode"`
+	MaxKB    int    `json:"max_kb"`
+	Compress bool   `json:"compress"`
+}
+
+// DefaultContentPolicy keeps full content, matching the historical behavior
+func DefaultContentPolicy() ContentPolicy {
+	return ContentPolicy{Mode: ContentPolicyFull, MaxKB: 16}
+}
+
+// Validate checks the mode and, for truncation, the size limit
+func (p ContentPolicy) Validate() error {
+	switch p.Mode {
+	case ContentPolicyFull, ContentPolicyMetadata:
+	case ContentPolicyTruncated:
+		if p.MaxKB < 1 || p.MaxKB > 1024 {
+			return fmt.Errorf("%w: content_max_kb must be between 1 and 1024", ErrInvalidSettings)
+		}
+	default:
+		return fmt.Errorf("%w: unknown content policy %q", ErrInvalidSettings, p.Mode)
+	}
+	return nil
+}
+
+// maxBytes is the stored size limit, or 0 when content is not truncated
+func (p ContentPolicy) maxBytes() int {
+	if p.Mode != ContentPolicyTruncated {
+		return 0
+	}
+	return p.MaxKB * 1024
+}
+
+// Apply returns the content to store under this policy; nil stores no content
+func (p ContentPolicy) Apply(content string) *string {
+	if p.Mode == ContentPolicyMetadata {
+		return nil
+	}
+	content = p.compress(p.limit(content))
+	return &content
+}
+
+// limit truncates content under the truncated policy
+func (p ContentPolicy) limit(content string) string {
+	if p.Mode != ContentPolicyTruncated {
+		return content
+	}
+	return truncateUTF8(content, p.maxBytes())
+}
+
+// compress compresses content when the policy asks for it
+func (p ContentPolicy) compress(content string) string {
+	if !p.Compress {
+		return content
+	}
+	return compressContent(content)
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// StripContent rewrites stored messages and quarantined log lines to conform
+// to the policy, e.g. after switching from full to truncated or
+// metadata-only, or turning compression on or off. Encrypted content is
+// decrypted with c, rewritten and encrypted again. It returns the number of
+// messages changed.
+func StripContent(db *sql.DB, policy ContentPolicy, c *ContentCipher) (int64, error) {
+	if err := policy.Validate(); err != nil {
+		return 0, err
+	}
+	if err := stripParseErrors(db, policy, c); err != nil {
+		return 0, err
+	}
+
+	var result sql.Result
+	var err error
+	switch policy.Mode {
+	case ContentPolicyFull:
+		return recompressContent(db, policy, c)
+	case ContentPolicyMetadata:
+		// Titles are taken from prompts, so they go with the content
+		if _, err := db.Exec(`UPDATE sessions SET title = NULL WHERE title IS NOT NULL`); err != nil {
+			return 0, fmt.Errorf("failed to strip session titles: %w", err)
+		}
+		result, err = db.Exec(`UPDATE messages SET content = NULL WHERE content IS NOT NULL`)
+	case ContentPolicyTruncated:
+		// Truncate in Go so multi-byte characters are never split
+		truncated, err := stripToLimit(db, policy, c)
+		if err != nil {
+			return truncated, err
+		}
+		recompressed, err := recompressContent(db, policy, c)
+		return truncated + recompressed, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to strip content: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// stripParseErrors applies the policy to the raw lines of parse errors,
+// which are stored like message content
+func stripParseErrors(db *sql.DB, policy ContentPolicy, c *ContentCipher) error {
+	_, err := rewriteParseErrors(db, "", nil, func(raw string) (*string, bool) {
+		stored, ok := c.decrypt(raw)
+		if !ok {
+			return nil, false
+		}
+		next := policy.Apply(decompressContent(stored))
+		if next == nil {
+			return nil, true
+		}
+		if *next == stored {
+			return nil, false
+		}
+		if IsEncryptedContent(raw) {
+			next = c.Seal(next)
+		}
+		return next, true
+	})
+	if err != nil {
+		return fmt.Errorf("failed to strip parse errors: %w", err)
+	}
+	return nil
+}
+
+func stripToLimit(db *sql.DB, policy ContentPolicy, c *ContentCipher) (int64, error) {
+	limit := policy.maxBytes()
+	var changed int64
+
+	// Work in batches ordered by id. Stored encrypted content is longer than
+	// its plain text, so some candidates turn out to be within the limit and
+	// must be skipped rather than seen again. Compressed content may be
+	// shorter, so all of it is a candidate.
+	after := ""
+	for {
+		rows, err := db.Query(`
+			SELECT id, content FROM messages
+			WHERE (octet_length(encode(content)) > ? OR starts_with(content, ?)) AND id > ?
+			ORDER BY id
+			LIMIT 500
+		`, limit, compressedContentPrefix, after)
+		if err != nil {
+			return changed, fmt.Errorf("failed to find oversized content: %w", err)
+		}
+		truncated := map[string]string{}
+		found := 0
+		for rows.Next() {
+			var id, content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return changed, fmt.Errorf("failed to scan message: %w", err)
+			}
+			found++
+			after = id
+
+			encrypted := IsEncryptedContent(content)
+			if encrypted && c == nil {
+				continue
+			}
+			content = *c.Open(&content)
+			if len(content) <= limit {
+				continue
+			}
+			content = policy.compress(truncateUTF8(content, limit))
+			if encrypted {
+				content = *c.Seal(&content)
+			}
+			truncated[id] = content
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return changed, fmt.Errorf("failed to find oversized content: %w", err)
+		}
+		if found == 0 {
+			return changed, nil
+		}
+
+		for id, content := range truncated {
+			if _, err := db.Exec(`UPDATE messages SET content = ? WHERE id = ?`, content, id); err != nil {
+				return changed, fmt.Errorf("failed to truncate message %s: %w", id, err)
+			}
+			changed++
+		}
+	}
+}
+
+// recompressContent compresses stored content when the policy compresses
+// and decompresses it when the policy does not. Without a cipher the prefix
+// tells which messages to rewrite; encrypted content must be opened to find
+// out.
+func recompressContent(db *sql.DB, policy ContentPolicy, c *ContentCipher) (int64, error) {
+	condition := `starts_with(content, ?)`
+	args := []interface{}{compressedContentPrefix}
+	if policy.Compress {
+		condition = `NOT starts_with(content, ?) AND NOT starts_with(content, ?) AND octet_length(encode(content)) >= ?`
+		args = append(args, encryptedContentPrefix, minCompressBytes)
+	}
+	if c != nil {
+		condition = `(` + condition + `) OR starts_with(content, ?)`
+		args = append(args, encryptedContentPrefix)
+	}
+
+	var changed int64
+	after := ""
+	for {
+		rows, err := db.Query(`
+			SELECT id, content FROM messages
+			WHERE content IS NOT NULL AND (`+condition+`) AND id > ?
+			ORDER BY id
+			LIMIT 500
+		`, append(args, after)...)
+		if err != nil {
+			return changed, fmt.Errorf("failed to find content to recompress: %w", err)
+		}
+		rewritten := map[string]string{}
+		found := 0
+		for rows.Next() {
+			var id, content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return changed, fmt.Errorf("failed to scan message: %w", err)
+			}
+			found++
+			after = id
+
+			stored, ok := c.decrypt(content)
+			if !ok {
+				continue
+			}
+			next := policy.compress(decompressContent(stored))
+			if next == stored {
+				continue
+			}
+			if IsEncryptedContent(content) {
+				next = *c.Seal(&next)
+			}
+			rewritten[id] = next
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return changed, fmt.Errorf("failed to find content to recompress: %w", err)
+		}
+		if found == 0 {
+			return changed, nil
+		}
+
+		for id, content := range rewritten {
+			if _, err := db.Exec(`UPDATE messages SET content = ? WHERE id = ?`, content, id); err != nil {
+				return changed, fmt.Errorf("failed to recompress message %s: %w", id, err)
+			}
+			changed++
+		}
+	}
+}
+
+// BackfillContent re-reads every log file and restores content that was
+// dropped or truncated under a stricter policy, up to what the current policy
+// allows. It returns the number of messages updated.
+func (d *DiffSyncService) BackfillContent() (int64, error) {
+	if d.contentPolicy.Mode == ContentPolicyMetadata {
+		return 0, nil
+	}
+
+	files, err := d.discoverJSONLFiles()
+	if err != nil {
+		return 0, fmt.Errorf("failed to discover JSONL files: %w", err)
+	}
+
+	var updated int64
+	for _, file := range files {
+		n, err := d.backfillFile(file.Path)
+		updated += n
+		if err != nil {
+			return updated, fmt.Errorf("failed to backfill %s: %w", file.Path, err)
+		}
+	}
+	return updated, nil
+}
+
+func (d *DiffSyncService) backfillFile(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	const maxCapacity = 10 * 1024 * 1024 // 10MB
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+
+	var updated int64
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry models.LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.UUID == "" || entry.Message.Content == nil {
+			continue
+		}
+
+		contentStr := d.convertContentToString(entry.Message.Content)
+		if d.redactor != nil {
+			// Counted when the message was first ingested
+			contentStr, _ = d.redactor.Redact(contentStr)
+		}
+		restored := d.contentPolicy.limit(contentStr)
+
+		// Only grow stored content; never replace it with something shorter.
+		// Stored content is compared once opened, since compression does not
+		// keep lengths comparable.
+		err := d.writes.Do(func() error {
+			var stored sql.NullString
+			err := d.db.QueryRow(`SELECT content FROM messages WHERE id = ?`, entry.UUID).Scan(&stored)
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if stored.Valid {
+				if IsEncryptedContent(stored.String) && d.cipher == nil {
+					return nil
+				}
+				if len(*d.cipher.Open(&stored.String)) >= len(restored) {
+					return nil
+				}
+			}
+			content := d.contentPolicy.compress(restored)
+			if _, err := d.db.Exec(`UPDATE messages SET content = ? WHERE id = ?`, *d.cipher.Seal(&content), entry.UUID); err != nil {
+				return err
+			}
+			updated++
+			return nil
+		})
+		if err != nil {
+			return updated, fmt.Errorf("failed to update message %s: %w", entry.UUID, err)
+		}
+	}
+
+	return updated, scanner.Err()
+}
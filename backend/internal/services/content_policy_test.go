@@ -0,0 +1,204 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContentPolicyApply(t *testing.T) {
+	content := strings.Repeat("あ", 1000) // 3 bytes per character
+
+	if got := (ContentPolicy{Mode: ContentPolicyFull}).Apply(content); got == nil || *got != content {
+		t.Error("Expected full policy to keep content unchanged")
+	}
+	if got := (ContentPolicy{Mode: ContentPolicyMetadata}).Apply(content); got != nil {
+		t.Errorf("Expected metadata policy to drop content, got %d bytes", len(*got))
+	}
+
+	got := (ContentPolicy{Mode: ContentPolicyTruncated, MaxKB: 1}).Apply(content)
+	if got == nil {
+		t.Fatal("Expected truncated content")
+	}
+	if len(*got) > 1024 || len(*got) != 1023 {
+		t.Errorf("Expected 1023 bytes (whole characters within 1KB), got %d", len(*got))
+	}
+	if !strings.HasPrefix(content, *got) {
+		t.Error("Expected truncated content to be a prefix of the original")
+	}
+}
+
+func TestContentPolicyValidate(t *testing.T) {
+	valid := []ContentPolicy{
+		{Mode: ContentPolicyFull},
+		{Mode: ContentPolicyMetadata},
+		{Mode: ContentPolicyTruncated, MaxKB: 4},
+	}
+	for _, policy := range valid {
+		if err := policy.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", policy, err)
+		}
+	}
+
+	invalid := []ContentPolicy{
+		{Mode: "compressed"},
+		{Mode: ContentPolicyTruncated, MaxKB: 0},
+	}
+	for _, policy := range invalid {
+		if err := policy.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", policy)
+		}
+	}
+}
+
+func TestStripContent(t *testing.T) {
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	long := strings.Repeat("x", 3000)
+	for _, id := range []string{"long", "short"} {
+		content := long
+		if id == "short" {
+			content = "hello"
+		}
+		if _, err := db.Exec(`INSERT INTO messages (id, session_id, content) VALUES (?, 's', ?)`, id, content); err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	changed, err := StripContent(db, ContentPolicy{Mode: ContentPolicyTruncated, MaxKB: 1}, nil)
+	if err != nil {
+		t.Fatalf("Failed to strip content: %v", err)
+	}
+	if changed != 1 {
+		t.Errorf("Expected 1 truncated message, got %d", changed)
+	}
+	var length int
+	db.QueryRow(`SELECT length(content) FROM messages WHERE id = 'long'`).Scan(&length)
+	if length != 1024 {
+		t.Errorf("Expected content truncated to 1024 bytes, got %d", length)
+	}
+
+	changed, err = StripContent(db, ContentPolicy{Mode: ContentPolicyMetadata}, nil)
+	if err != nil {
+		t.Fatalf("Failed to strip content: %v", err)
+	}
+	if changed != 2 {
+		t.Errorf("Expected 2 stripped messages, got %d", changed)
+	}
+	var remaining int
+	db.QueryRow(`SELECT COUNT(*) FROM messages WHERE content IS NOT NULL`).Scan(&remaining)
+	if remaining != 0 {
+		t.Errorf("Expected no stored content, got %d messages", remaining)
+	}
+}
+
+func TestContentCompression(t *testing.T) {
+	long := strings.Repeat("the middleware now rejects expired tokens\n", 100)
+	compress := ContentPolicy{Mode: ContentPolicyFull, Compress: true}
+
+	stored := compress.Apply(long)
+	if stored == nil || !IsCompressedContent(*stored) || len(*stored) >= len(long)/4 {
+		t.Fatalf("Expected long content to be compressed")
+	}
+	var none *ContentCipher
+	if opened := none.Open(stored); *opened != long {
+		t.Error("Expected compressed content to open without a cipher")
+	}
+	if short := compress.Apply("hello"); *short != "hello" {
+		t.Errorf("Expected short content to be stored as is, got %q", *short)
+	}
+
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+	cipher := newTestCipher(t, 2)
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s', 'alpha', '/alpha', '2024-01-01 10:00:00')`); err != nil {
+		t.Fatal(err)
+	}
+	for id, content := range map[string]string{"plain": long, "sealed": *cipher.Seal(&long), "short": "hello"} {
+		if _, err := db.Exec(`INSERT INTO messages (id, session_id, content, timestamp) VALUES (?, 's', ?, '2024-01-01 10:00:00')`, id, content); err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	changed, err := StripContent(db, compress, cipher)
+	if err != nil {
+		t.Fatalf("Failed to compress content: %v", err)
+	}
+	if changed != 2 {
+		t.Errorf("Expected 2 compressed messages, got %d", changed)
+	}
+	var plain, sealed string
+	db.QueryRow(`SELECT content FROM messages WHERE id = 'plain'`).Scan(&plain)
+	db.QueryRow(`SELECT content FROM messages WHERE id = 'sealed'`).Scan(&sealed)
+	if !IsCompressedContent(plain) || !IsEncryptedContent(sealed) || *cipher.Open(&sealed) != long {
+		t.Error("Expected plain content compressed and encrypted content compressed inside its encryption")
+	}
+
+	// Search matches compressed messages once opened
+	search := NewSearchService(db)
+	results, err := search.Search(SearchQuery{Text: "expired tokens", Content: true, Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results.Messages) != 1 || results.Messages[0].MessageID != "plain" {
+		t.Errorf("Expected the compressed plain message to match, got %+v", results.Messages)
+	}
+
+	// Turning compression off restores the stored content
+	changed, err = StripContent(db, ContentPolicy{Mode: ContentPolicyFull}, cipher)
+	if err != nil {
+		t.Fatalf("Failed to decompress content: %v", err)
+	}
+	db.QueryRow(`SELECT content FROM messages WHERE id = 'plain'`).Scan(&plain)
+	if changed != 2 || plain != long {
+		t.Errorf("Expected 2 decompressed messages, got %d", changed)
+	}
+
+	// Truncation looks at the content as it was, not its compressed size
+	if _, err := StripContent(db, compress, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := StripContent(db, ContentPolicy{Mode: ContentPolicyTruncated, MaxKB: 1, Compress: true}, nil); err != nil {
+		t.Fatalf("Failed to truncate content: %v", err)
+	}
+	db.QueryRow(`SELECT content FROM messages WHERE id = 'plain'`).Scan(&plain)
+	if opened := none.Open(&plain); len(*opened) != 1024 {
+		t.Errorf("Expected compressed content truncated to 1024 bytes, got %d", len(*opened))
+	}
+}
+
+func TestStripContentRewritesParseErrors(t *testing.T) {
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+	c := newTestCipher(t, 1)
+
+	long := strings.Repeat("x", 3000)
+	now := time.Now()
+	insertParseError(t, db, 1, long, now)
+	insertParseError(t, db, 2, *c.Seal(&long), now)
+	insertParseError(t, db, 3, "{broken", now)
+
+	if _, err := StripContent(db, ContentPolicy{Mode: ContentPolicyTruncated, MaxKB: 1}, c); err != nil {
+		t.Fatalf("Failed to strip content: %v", err)
+	}
+	if raw := storedParseError(t, db, 1); raw == nil || len(*raw) != 1024 {
+		t.Errorf("Expected the plain line truncated to 1024 bytes, got %v", raw)
+	}
+	raw := storedParseError(t, db, 2)
+	if raw == nil || !IsEncryptedContent(*raw) || len(*c.Open(raw)) != 1024 {
+		t.Errorf("Expected the encrypted line truncated and still encrypted, got %v", raw)
+	}
+	if raw := storedParseError(t, db, 3); raw == nil || *raw != "{broken" {
+		t.Errorf("Expected the short line unchanged, got %v", raw)
+	}
+
+	if _, err := StripContent(db, ContentPolicy{Mode: ContentPolicyMetadata}, c); err != nil {
+		t.Fatalf("Failed to strip content: %v", err)
+	}
+	for line := 1; line <= 3; line++ {
+		if raw := storedParseError(t, db, line); raw != nil {
+			t.Errorf("Expected line %d cleared under the metadata policy, got %q", line, *raw)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"path"
 	"strings"
+	"sync"
 )
 
 // PricingCalculator provides cost calculation for Claude models
@@ -9,6 +11,39 @@ type PricingCalculator struct {
 	pricing map[string]map[string]float64
 }
 
+// ModelPrice is the USD price per million tokens of a model
+type ModelPrice struct {
+	Input         float64
+	Output        float64
+	CacheCreation float64
+	CacheRead     float64
+}
+
+var (
+	pricingOverridesMu sync.RWMutex
+	pricingOverrides   map[string]map[string]float64
+)
+
+// SetPricingOverrides replaces built-in prices in every calculator, including
+// those already created. Keys are exact model names, matched
+// case-insensitively; glob patterns such as claude-opus-5*, which match
+// models without an exact entry; or a family (opus, sonnet or haiku) that
+// covers every model of that family matched by neither.
+func SetPricingOverrides(prices map[string]ModelPrice) {
+	overrides := make(map[string]map[string]float64, len(prices))
+	for model, price := range prices {
+		overrides[strings.ToLower(strings.TrimSpace(model))] = map[string]float64{
+			"input":          price.Input,
+			"output":         price.Output,
+			"cache_creation": price.CacheCreation,
+			"cache_read":     price.CacheRead,
+		}
+	}
+	pricingOverridesMu.Lock()
+	pricingOverrides = overrides
+	pricingOverridesMu.Unlock()
+}
+
 // NewPricingCalculator creates a new pricing calculator with fallback pricing
 func NewPricingCalculator() *PricingCalculator {
 	// Fallback pricing per million tokens (USD)
@@ -42,6 +77,20 @@ func NewPricingCalculator() *PricingCalculator {
 		"claude-3-5-haiku":            fallbackPricing["haiku"],
 		"claude-sonnet-4-20250514":    fallbackPricing["sonnet"],
 		"claude-opus-4-20250514":      fallbackPricing["opus"],
+		// OpenAI models used by Codex CLI; cached input is read at a discount
+		// and OpenAI does not charge for cache writes
+		"gpt-5":             {"input": 1.25, "output": 10.0, "cache_creation": 0, "cache_read": 0.125},
+		"gpt-5-codex":       {"input": 1.25, "output": 10.0, "cache_creation": 0, "cache_read": 0.125},
+		"gpt-5-mini":        {"input": 0.25, "output": 2.0, "cache_creation": 0, "cache_read": 0.025},
+		"o3":                {"input": 2.0, "output": 8.0, "cache_creation": 0, "cache_read": 0.5},
+		"o4-mini":           {"input": 1.1, "output": 4.4, "cache_creation": 0, "cache_read": 0.275},
+		"codex-mini-latest": {"input": 1.5, "output": 6.0, "cache_creation": 0, "cache_read": 0.375},
+		// Google models used by Gemini CLI, at the rates for prompts of up to
+		// 200k tokens
+		"gemini-2.5-pro":        {"input": 1.25, "output": 10.0, "cache_creation": 0, "cache_read": 0.125},
+		"gemini-2.5-flash":      {"input": 0.3, "output": 2.5, "cache_creation": 0, "cache_read": 0.03},
+		"gemini-2.5-flash-lite": {"input": 0.1, "output": 0.4, "cache_creation": 0, "cache_read": 0.01},
+		"gemini-2.0-flash":      {"input": 0.1, "output": 0.4, "cache_creation": 0, "cache_read": 0.025},
 	}
 
 	return &PricingCalculator{
@@ -77,6 +126,15 @@ func (pc *PricingCalculator) CalculateCost(
 
 // getPricingForModel gets pricing for a model with fallback logic
 func (pc *PricingCalculator) getPricingForModel(model string) map[string]float64 {
+	// Configured prices win over the built-in ones
+	if pricing, ok := pc.overridePricing(model); ok {
+		return pricing
+	}
+	// Then those of the price registry, when updates are enabled
+	if pricing, ok := lookupRegistryPricing(model); ok {
+		return pricing
+	}
+
 	// Normalize model name
 	normalized := normalizeModelName(model)
 
@@ -92,6 +150,9 @@ func (pc *PricingCalculator) getPricingForModel(model string) map[string]float64
 
 	// Fallback to hardcoded pricing based on model type
 	modelLower := strings.ToLower(model)
+	if pricing, ok := pc.providerFallback(modelLower); ok {
+		return pricing
+	}
 	if strings.Contains(modelLower, "opus") {
 		return pc.pricing["claude-3-opus"]
 	}
@@ -102,6 +163,62 @@ func (pc *PricingCalculator) getPricingForModel(model string) map[string]float64
 	return pc.pricing["claude-3-sonnet"]
 }
 
+// providerFallback prices unknown models of other providers like their
+// provider's closest known model rather than like Claude
+func (pc *PricingCalculator) providerFallback(modelLower string) (map[string]float64, bool) {
+	switch {
+	case strings.HasPrefix(modelLower, "gemini"):
+		if strings.Contains(modelLower, "flash-lite") {
+			return pc.pricing["gemini-2.5-flash-lite"], true
+		}
+		if strings.Contains(modelLower, "flash") {
+			return pc.pricing["gemini-2.5-flash"], true
+		}
+		return pc.pricing["gemini-2.5-pro"], true
+	case strings.HasPrefix(modelLower, "gpt-"):
+		if strings.Contains(modelLower, "mini") {
+			return pc.pricing["gpt-5-mini"], true
+		}
+		return pc.pricing["gpt-5"], true
+	}
+	return nil, false
+}
+
+// overridePricing returns the configured price of a model, of the most
+// specific pattern matching it, or of its family
+func (pc *PricingCalculator) overridePricing(model string) (map[string]float64, bool) {
+	pricingOverridesMu.RLock()
+	overrides := pricingOverrides
+	pricingOverridesMu.RUnlock()
+	if len(overrides) == 0 {
+		return nil, false
+	}
+
+	modelLower := strings.ToLower(strings.TrimSpace(model))
+	if pricing, ok := overrides[modelLower]; ok {
+		return pricing, true
+	}
+	var best string
+	for pattern := range overrides {
+		if !strings.ContainsAny(pattern, "*?[") || len(pattern) < len(best) || (len(pattern) == len(best) && pattern > best) {
+			continue
+		}
+		if matched, _ := path.Match(pattern, modelLower); matched {
+			best = pattern
+		}
+	}
+	if best != "" {
+		return overrides[best], true
+	}
+	for _, family := range []string{"opus", "sonnet", "haiku"} {
+		if strings.Contains(modelLower, family) {
+			pricing, ok := overrides[family]
+			return pricing, ok
+		}
+	}
+	return nil, false
+}
+
 // normalizeModelName normalizes model names for consistent lookup
 func normalizeModelName(model string) string {
 	// Remove common prefixes and normalize
@@ -0,0 +1,26 @@
+package services
+
+import "testing"
+
+func TestPricingOverrides(t *testing.T) {
+	SetPricingOverrides(map[string]ModelPrice{
+		"Claude-Opus-4-1-20250805": {Input: 10, Output: 50},
+		"sonnet":                   {Input: 1, Output: 2, CacheCreation: 3, CacheRead: 4},
+	})
+	defer SetPricingOverrides(nil)
+	pc := NewPricingCalculator()
+
+	if cost := pc.CalculateCost("claude-opus-4-1-20250805", 1_000_000, 1_000_000, 0, 0); cost != 60 {
+		t.Errorf("Expected the exact model override, got %v", cost)
+	}
+	if cost := pc.CalculateCost("claude-sonnet-4-20250514", 1_000_000, 1_000_000, 1_000_000, 1_000_000); cost != 10 {
+		t.Errorf("Expected the sonnet family override, got %v", cost)
+	}
+	// Models without an override keep the built-in price
+	if cost := pc.CalculateCost("claude-opus-4-20250514", 1_000_000, 0, 0, 0); cost != 15 {
+		t.Errorf("Expected the built-in opus price, got %v", cost)
+	}
+	if cost := NewPricingCalculator().CalculateCost("<synthetic>", 1_000_000, 0, 0, 0); cost != 0 {
+		t.Errorf("Expected synthetic messages to be free, got %v", cost)
+	}
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+// Cost forecast methods
+const (
+	// ForecastLinear extends the least-squares trend of daily spend
+	ForecastLinear = "linear"
+	// ForecastExponential carries the exponentially smoothed daily spend forward
+	ForecastExponential = "exponential"
+)
+
+// CostForecastWindows are the trailing windows, in days, forecasts are fitted to
+var CostForecastWindows = []int{7, 30}
+
+// CostProjection is the end-of-month spend one method projects from one window
+type CostProjection struct {
+	Method     string `json:"method"`
+	WindowDays int    `json:"window_days"`
+	// DailyRate is the spend the method expects for today
+	DailyRate     float64 `json:"daily_rate"`
+	RemainingCost float64 `json:"remaining_cost"`
+	ProjectedCost float64 `json:"projected_cost"`
+}
+
+// CostForecastBreakdown is the month-to-date spend and projections of one
+// model or project
+type CostForecastBreakdown struct {
+	Name        string           `json:"name"`
+	MonthToDate float64          `json:"month_to_date"`
+	Projections []CostProjection `json:"projections"`
+}
+
+// CostForecast projects this month's API-priced spend from recent daily spend
+type CostForecast struct {
+	Currency      string                  `json:"currency"`
+	Timezone      string                  `json:"timezone"`
+	MonthStart    time.Time               `json:"month_start"`
+	MonthEnd      time.Time               `json:"month_end"`
+	DaysRemaining float64                 `json:"days_remaining"`
+	MonthToDate   float64                 `json:"month_to_date"`
+	Projections   []CostProjection        `json:"projections"`
+	Models        []CostForecastBreakdown `json:"models"`
+	Projects      []CostForecastBreakdown `json:"projects"`
+}
+
+// CostForecastService reports and forecasts monthly spend
+type CostForecastService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+
+	mu             sync.RWMutex
+	location       *time.Location
+	plan           string
+	defaultBilling string
+}
+
+func NewCostForecastService(db *sql.DB) *CostForecastService {
+	return &CostForecastService{
+		db:             db,
+		pricing:        NewPricingCalculator(),
+		location:       time.UTC,
+		plan:           PlanPro,
+		defaultBilling: BillingSubscription,
+	}
+}
+
+// SetLocation sets the reporting time zone, whose days and months forecasts
+// follow unless a request names another
+func (s *CostForecastService) SetLocation(loc *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.location = loc
+}
+
+// Location returns the time zone set with SetLocation
+func (s *CostForecastService) Location() *time.Location {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.location
+}
+
+// costSeries is the spend of one model, project or the total
+type costSeries struct {
+	monthToDate float64
+	// daily holds the spend of the complete days before today, oldest first
+	daily []float64
+}
+
+// forecastDay is a day left in the month: offset days after today, of which
+// fraction is still ahead
+type forecastDay struct {
+	offset   int
+	fraction float64
+}
+
+// Forecast projects the spend of the month of loc containing now, limited to
+// user's sessions when user is set. Fits use complete days only, so a quiet
+// morning does not drag today's rate down.
+func (s *CostForecastService) Forecast(user string, now time.Time, loc *time.Location) (*CostForecast, error) {
+	local := now.In(loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	history := CostForecastWindows[len(CostForecastWindows)-1]
+	historyStart := today.AddDate(0, 0, -history)
+
+	dayIndex := make(map[int64]int, history)
+	for i := 0; i < history; i++ {
+		dayIndex[historyStart.AddDate(0, 0, i).Unix()] = i
+	}
+	newSeries := func() *costSeries { return &costSeries{daily: make([]float64, history)} }
+	total := newSeries()
+	byModel := make(map[string]*costSeries)
+	byProject := make(map[string]*costSeries)
+
+	from := historyStart
+	if monthStart.Before(from) {
+		from = monthStart
+	}
+	where, args := rangeFilter("", user, from.UTC(), now.UTC())
+	// Quarter-hour buckets fall on a single local day in every time zone
+	rows, err := s.db.Query(`
+		SELECT
+			`+database.QuarterHour("m.timestamp")+`,
+			s.project_name,
+			COALESCE(m.model, ''),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+` AND m.message_role = 'assistant'
+		GROUP BY 1, 2, 3
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily costs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket time.Time
+		var project, model string
+		var input, output, cacheCreation, cacheRead int
+		if err := rows.Scan(&bucket, &project, &model, &input, &output, &cacheCreation, &cacheRead); err != nil {
+			return nil, fmt.Errorf("failed to scan daily costs: %w", err)
+		}
+		if model == "" {
+			continue
+		}
+		cost := s.pricing.CalculateCost(model, input, output, cacheCreation, cacheRead)
+
+		bucketLocal := bucket.In(loc)
+		day := time.Date(bucketLocal.Year(), bucketLocal.Month(), bucketLocal.Day(), 0, 0, 0, 0, loc)
+		index, inHistory := dayIndex[day.Unix()]
+		inMonth := !day.Before(monthStart)
+		for _, series := range []*costSeries{total, seriesFor(byModel, model, newSeries), seriesFor(byProject, project, newSeries)} {
+			if inHistory {
+				series.daily[index] += cost
+			}
+			if inMonth {
+				series.monthToDate += cost
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read daily costs: %w", err)
+	}
+
+	days := remainingDays(now, today, monthEnd)
+	forecast := &CostForecast{
+		Currency:    "USD",
+		Timezone:    loc.String(),
+		MonthStart:  monthStart,
+		MonthEnd:    monthEnd,
+		MonthToDate: roundToDecimals(total.monthToDate, 6),
+		Projections: projectSeries(total, days),
+		Models:      breakdownSeries(byModel, days),
+		Projects:    breakdownSeries(byProject, days),
+	}
+	for _, day := range days {
+		forecast.DaysRemaining += day.fraction
+	}
+	forecast.DaysRemaining = roundToDecimals(forecast.DaysRemaining, 2)
+	return forecast, nil
+}
+
+func seriesFor(m map[string]*costSeries, key string, create func() *costSeries) *costSeries {
+	series, ok := m[key]
+	if !ok {
+		series = create()
+		m[key] = series
+	}
+	return series
+}
+
+// remainingDays lists the rest of today and the days after it up to monthEnd
+func remainingDays(now, today, monthEnd time.Time) []forecastDay {
+	var days []forecastDay
+	for offset, day := 0, today; day.Before(monthEnd); offset, day = offset+1, day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		fraction := 1.0
+		if offset == 0 {
+			fraction = float64(next.Sub(now)) / float64(next.Sub(day))
+		}
+		days = append(days, forecastDay{offset: offset, fraction: fraction})
+	}
+	return days
+}
+
+func breakdownSeries(series map[string]*costSeries, days []forecastDay) []CostForecastBreakdown {
+	breakdowns := make([]CostForecastBreakdown, 0, len(series))
+	for name, s := range series {
+		breakdowns = append(breakdowns, CostForecastBreakdown{
+			Name:        name,
+			MonthToDate: roundToDecimals(s.monthToDate, 6),
+			Projections: projectSeries(s, days),
+		})
+	}
+	sort.Slice(breakdowns, func(i, j int) bool {
+		if breakdowns[i].MonthToDate != breakdowns[j].MonthToDate {
+			return breakdowns[i].MonthToDate > breakdowns[j].MonthToDate
+		}
+		return breakdowns[i].Name < breakdowns[j].Name
+	})
+	return breakdowns
+}
+
+// projectSeries applies every method to every window of a series
+func projectSeries(s *costSeries, days []forecastDay) []CostProjection {
+	var projections []CostProjection
+	for _, window := range CostForecastWindows {
+		daily := s.daily[len(s.daily)-window:]
+		for _, method := range []string{ForecastLinear, ForecastExponential} {
+			rate := dailyRate(method, daily)
+			var remaining float64
+			for _, day := range days {
+				remaining += rate(day.offset) * day.fraction
+			}
+			projections = append(projections, CostProjection{
+				Method:        method,
+				WindowDays:    window,
+				DailyRate:     roundToDecimals(rate(0), 6),
+				RemainingCost: roundToDecimals(remaining, 6),
+				ProjectedCost: roundToDecimals(s.monthToDate+remaining, 6),
+			})
+		}
+	}
+	return projections
+}
+
+// dailyRate fits method to daily and returns the spend it expects offset
+// days after today, which is never negative
+func dailyRate(method string, daily []float64) func(offset int) float64 {
+	n := float64(len(daily))
+	if method == ForecastExponential {
+		// Simple exponential smoothing with the span's usual alpha
+		alpha := 2 / (n + 1)
+		level := daily[0]
+		for _, cost := range daily[1:] {
+			level = alpha*cost + (1-alpha)*level
+		}
+		return func(int) float64 { return level }
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i, cost := range daily {
+		x := float64(i)
+		sumX += x
+		sumY += cost
+		sumXY += x * cost
+		sumXX += x * x
+	}
+	slope := 0.0
+	if denominator := n*sumXX - sumX*sumX; denominator != 0 {
+		slope = (n*sumXY - sumX*sumY) / denominator
+	}
+	intercept := (sumY - slope*sumX) / n
+	return func(offset int) float64 {
+		// Today is the day after the last one in the window
+		if rate := intercept + slope*(n+float64(offset)); rate > 0 {
+			return rate
+		}
+		return 0
+	}
+}
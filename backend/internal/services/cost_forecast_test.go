@@ -0,0 +1,92 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestCostForecastProjectsMonthEnd(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES
+			('s1', 'api', '/work/api', '2024-03-06 09:00:00'),
+			('s2', 'web', '/work/web', '2024-03-06 09:00:00');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert sessions: %v", err)
+	}
+	// $3 of sonnet input on api every day from March 6 through today, and
+	// a single $1.5 day on web
+	day := time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 15; i++ {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp)
+			VALUES (?, 's1', 'assistant', 'claude-sonnet-4-20250514', 1000000, 0, ?)
+		`, fmt.Sprintf("a%d", i), day.AddDate(0, 0, i))
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+	_, err = db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp) VALUES
+			('w1', 's2', 'assistant', 'claude-sonnet-4-20250514', 500000, 0, '2024-03-13 10:00:00'),
+			('u1', 's2', 'user', NULL, 0, 0, '2024-03-13 10:00:00');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+
+	now := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+	forecast, err := NewCostForecastService(db).Forecast("", now, time.UTC)
+	if err != nil {
+		t.Fatalf("Failed to forecast: %v", err)
+	}
+	if forecast.MonthToDate != 46.5 || forecast.DaysRemaining != 11.5 {
+		t.Errorf("Expected $46.5 so far with 11.5 days left, got %+v", forecast)
+	}
+	projections := make(map[string]CostProjection)
+	for _, p := range forecast.Projections {
+		projections[fmt.Sprintf("%s-%d", p.Method, p.WindowDays)] = p
+	}
+	if len(projections) != 4 {
+		t.Fatalf("Expected 2 methods over 2 windows, got %+v", forecast.Projections)
+	}
+	// The last 7 days are flat but for web's day, so both methods expect
+	// about $3 a day
+	for _, key := range []string{"linear-7", "exponential-7"} {
+		if p := projections[key]; p.DailyRate < 2.5 || p.DailyRate > 3.5 || math.Abs(p.ProjectedCost-(46.5+p.RemainingCost)) > 1e-6 {
+			t.Errorf("Unexpected %s projection: %+v", key, p)
+		}
+	}
+	// Over 30 days spend went from nothing to $3 a day: the trend keeps
+	// rising while the smoothed rate lags behind
+	if p := projections["linear-30"]; p.DailyRate <= projections["linear-7"].DailyRate {
+		t.Errorf("Expected the 30-day trend to rise above the 7-day rate, got %+v", p)
+	}
+	if p := projections["exponential-30"]; p.DailyRate >= 3 || p.DailyRate <= 0 {
+		t.Errorf("Expected the 30-day smoothed rate below $3, got %+v", p)
+	}
+
+	if len(forecast.Models) != 1 || forecast.Models[0].Name != "claude-sonnet-4-20250514" {
+		t.Errorf("Expected one model, got %+v", forecast.Models)
+	}
+	if len(forecast.Projects) != 2 || forecast.Projects[0].Name != "api" || forecast.Projects[0].MonthToDate != 45 {
+		t.Errorf("Expected api first with $45, got %+v", forecast.Projects)
+	}
+	if web := forecast.Projects[1]; web.MonthToDate != 1.5 || web.Projections[0].DailyRate != 0 {
+		t.Errorf("Expected web's 7-day trend to fall to nothing, got %+v", web)
+	}
+}
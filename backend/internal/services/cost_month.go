@@ -0,0 +1,140 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// BillingCost is the month's usage billed one way
+type BillingCost struct {
+	Messages int64 `json:"messages"`
+	// Tokens are input and output tokens
+	Tokens int64 `json:"tokens"`
+	// Cost is the usage at API prices; subscription usage is covered by the
+	// plan fee instead
+	Cost float64 `json:"cost"`
+}
+
+// BillingBreakdown splits the month's cost into subscription and
+// pay-as-you-go API usage
+type BillingBreakdown struct {
+	// DefaultBilling is how usage is counted when its log entries do not
+	// show pay-as-you-go billing
+	DefaultBilling string `json:"default_billing"`
+	Plan           string `json:"plan"`
+	// PlanFee is the plan's monthly price, charged once per subscriber: each
+	// user with subscription usage this month
+	PlanFee      float64     `json:"plan_fee"`
+	Subscribers  int         `json:"subscribers"`
+	Subscription BillingCost `json:"subscription"`
+	API          BillingCost `json:"api"`
+	// BilledCost is what the month is actually charged: the plan fees plus
+	// the API usage
+	BilledCost float64 `json:"billed_cost"`
+}
+
+// MonthCosts is the spend of a month so far
+type MonthCosts struct {
+	Currency   string    `json:"currency"`
+	Timezone   string    `json:"timezone"`
+	MonthStart time.Time `json:"month_start"`
+	MonthEnd   time.Time `json:"month_end"`
+	// CurrentMonthCost is all of the month's usage at API prices
+	CurrentMonthCost float64           `json:"current_month_cost"`
+	Billing          *BillingBreakdown `json:"billing,omitempty"`
+}
+
+// SetPlan sets the plan whose fee subscription usage is billed with
+func (s *CostForecastService) SetPlan(plan string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plan = plan
+}
+
+// SetDefaultBilling sets how usage is billed when its log entries do not say:
+// BillingSubscription or BillingAPI
+func (s *CostForecastService) SetDefaultBilling(billing string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultBilling = billing
+}
+
+// MonthCosts returns the spend of the month of loc containing now, limited to
+// user's sessions when user is set. With billing it is split into
+// subscription and API usage.
+func (s *CostForecastService) MonthCosts(user string, now time.Time, loc *time.Location, billing bool) (*MonthCosts, error) {
+	s.mu.RLock()
+	plan, defaultBilling := s.plan, s.defaultBilling
+	s.mu.RUnlock()
+
+	local := now.In(loc)
+	monthStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	where, args := rangeFilter("", user, monthStart.UTC(), monthEnd.UTC())
+	rows, err := s.db.Query(`
+		SELECT
+			COALESCE(m.model, ''),
+			COALESCE(m.billing, ?),
+			COALESCE(s.user_id, ''),
+			COUNT(*),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+` AND m.message_role = 'assistant'
+		GROUP BY 1, 2, 3
+	`, append([]interface{}{defaultBilling}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query month costs: %w", err)
+	}
+	defer rows.Close()
+
+	costs := &MonthCosts{
+		Currency:   "USD",
+		Timezone:   loc.String(),
+		MonthStart: monthStart,
+		MonthEnd:   monthEnd,
+	}
+	breakdown := &BillingBreakdown{DefaultBilling: defaultBilling, Plan: plan}
+	subscribers := make(map[string]struct{})
+	for rows.Next() {
+		var model, kind, userID string
+		var messages int64
+		var input, output, cacheCreation, cacheRead int
+		if err := rows.Scan(&model, &kind, &userID, &messages, &input, &output, &cacheCreation, &cacheRead); err != nil {
+			return nil, fmt.Errorf("failed to scan month costs: %w", err)
+		}
+		var cost float64
+		if model != "" {
+			cost = s.pricing.CalculateCost(model, input, output, cacheCreation, cacheRead)
+		}
+		costs.CurrentMonthCost += cost
+
+		target := &breakdown.Subscription
+		if kind == BillingAPI {
+			target = &breakdown.API
+		} else {
+			subscribers[userID] = struct{}{}
+		}
+		target.Messages += messages
+		target.Tokens += int64(input + output)
+		target.Cost += cost
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read month costs: %w", err)
+	}
+
+	costs.CurrentMonthCost = roundToDecimals(costs.CurrentMonthCost, 6)
+	if billing {
+		breakdown.PlanFee = planMonthlyFees[plan]
+		breakdown.Subscribers = len(subscribers)
+		breakdown.Subscription.Cost = roundToDecimals(breakdown.Subscription.Cost, 6)
+		breakdown.API.Cost = roundToDecimals(breakdown.API.Cost, 6)
+		breakdown.BilledCost = roundToDecimals(breakdown.PlanFee*float64(breakdown.Subscribers)+breakdown.API.Cost, 6)
+		costs.Billing = breakdown
+	}
+	return costs, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/models"
+)
+
+func TestMonthCostsBillingBreakdown(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, user_id, project_name, project_path, start_time) VALUES
+			('s1', 'alice', 'api', '/work/api', '2024-03-01 09:00:00'),
+			('s2', 'bob', 'web', '/work/web', '2024-03-01 09:00:00');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert sessions: %v", err)
+	}
+	// $3 of sonnet input each: two subscription messages of alice, one
+	// batch API message of bob and one of alice from February
+	for _, m := range []struct {
+		id, session, billing string
+		timestamp            string
+	}{
+		{"m1", "s1", "", "2024-03-02 10:00:00"},
+		{"m2", "s1", "", "2024-03-10 10:00:00"},
+		{"m3", "s2", BillingAPI, "2024-03-12 10:00:00"},
+		{"m4", "s1", "", "2024-02-20 10:00:00"},
+	} {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, billing, timestamp)
+			VALUES (?, ?, 'assistant', 'claude-sonnet-4-20250514', 1000000, 0, NULLIF(?, ''), ?)
+		`, m.id, m.session, m.billing, m.timestamp)
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	service := NewCostForecastService(db)
+	service.SetPlan(PlanMax5)
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+	costs, err := service.MonthCosts("", now, time.UTC, false)
+	if err != nil {
+		t.Fatalf("MonthCosts failed: %v", err)
+	}
+	if costs.CurrentMonthCost != 9 || costs.Billing != nil {
+		t.Errorf("Expected $9 without a breakdown, got %+v", costs)
+	}
+
+	costs, err = service.MonthCosts("", now, time.UTC, true)
+	if err != nil {
+		t.Fatalf("MonthCosts failed: %v", err)
+	}
+	billing := costs.Billing
+	if billing == nil {
+		t.Fatal("Expected a billing breakdown")
+	}
+	if billing.Subscription.Messages != 2 || billing.Subscription.Cost != 6 || billing.API.Messages != 1 || billing.API.Cost != 3 {
+		t.Errorf("Unexpected split %+v", billing)
+	}
+	// One Max 5x subscriber plus the API usage
+	if billing.Subscribers != 1 || billing.PlanFee != 100 || billing.BilledCost != 103 {
+		t.Errorf("Expected $103 billed to one subscriber, got %+v", billing)
+	}
+
+	// Without a plan every message is API usage
+	service.SetDefaultBilling(BillingAPI)
+	costs, err = service.MonthCosts("alice", now, time.UTC, true)
+	if err != nil {
+		t.Fatalf("MonthCosts failed: %v", err)
+	}
+	if costs.Billing.API.Messages != 2 || costs.Billing.Subscribers != 0 || costs.Billing.BilledCost != 6 {
+		t.Errorf("Expected alice's $6 billed as API usage, got %+v", costs.Billing)
+	}
+}
+
+func TestLogEntryBilling(t *testing.T) {
+	bedrock := "msg_bdrk_01abc"
+	anthropic := "msg_01abc"
+	tests := []struct {
+		name     string
+		entry    models.LogEntry
+		expected string
+	}{
+		{"no usage", models.LogEntry{}, ""},
+		{"standard tier", models.LogEntry{Message: models.LogMessage{ID: &anthropic, Usage: &models.Usage{ServiceTier: "standard"}}}, ""},
+		{"batch tier", models.LogEntry{Message: models.LogMessage{Usage: &models.Usage{ServiceTier: "batch"}}}, BillingAPI},
+		{"bedrock", models.LogEntry{Message: models.LogMessage{ID: &bedrock, Usage: &models.Usage{ServiceTier: "standard"}}}, BillingAPI},
+	}
+	for _, tt := range tests {
+		got := ""
+		if billing := logEntryBilling(&tt.entry); billing != nil {
+			got = *billing
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, got)
+		}
+	}
+}
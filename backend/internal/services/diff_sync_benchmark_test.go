@@ -0,0 +1,106 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// writeSyntheticJSONL writes a Claude-style log with the given number of
+// message lines spread over a few sessions and a couple of session windows
+func writeSyntheticJSONL(tb testing.TB, lines int) string {
+	path := filepath.Join(tb.TempDir(), "synthetic.jsonl")
+	file, err := os.Create(path)
+	if err != nil {
+		tb.Fatalf("Failed to create fixture: %v", err)
+	}
+	defer file.Close()
+
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < lines; i++ {
+		role := "user"
+		usage := ""
+		if i%2 == 1 {
+			role = "assistant"
+			usage = `,"usage":{"input_tokens":1200,"cache_creation_input_tokens":300,"cache_read_input_tokens":5000,"output_tokens":450,"service_tier":"standard"}`
+		}
+		fmt.Fprintf(file,
+			`{"uuid":"msg-%d","parentUuid":"msg-%d","sessionId":"session-%d","userType":"external","cwd":"/Users/dev/project","timestamp":"%s","requestId":"req-%d","message":{"role":"%s","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Synthetic message body number %d with some representative length to parse."}]%s}}`+"\n",
+			i, i-1, i/50, base.Add(time.Duration(i)*20*time.Second).Format(time.RFC3339), i, role, i, usage)
+	}
+	return path
+}
+
+func BenchmarkParseLogEntry(b *testing.B) {
+	path := writeSyntheticJSONL(b, 1)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var entry models.LogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			b.Fatalf("Failed to parse entry: %v", err)
+		}
+	}
+}
+
+func benchmarkSyncFile(b *testing.B, lines int) {
+	path := writeSyntheticJSONL(b, lines)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db, diffSyncService := setupTestDBForDiffSync(b)
+		addSessionWindowTables(b, db)
+		b.StartTimer()
+
+		processed, _, err := diffSyncService.processFileFromLine(path, 0)
+		if err != nil {
+			b.Fatalf("Failed to process file: %v", err)
+		}
+		if err := diffSyncService.flushWindowStats(); err != nil {
+			b.Fatalf("Failed to flush window stats: %v", err)
+		}
+		if processed != lines {
+			b.Fatalf("Expected %d processed lines, got %d", lines, processed)
+		}
+
+		b.StopTimer()
+		db.Close()
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(lines), "lines/op")
+}
+
+func BenchmarkSyncFile100(b *testing.B)  { benchmarkSyncFile(b, 100) }
+func BenchmarkSyncFile1000(b *testing.B) { benchmarkSyncFile(b, 1000) }
+
+// BenchmarkResyncDuplicates measures a second pass over already-ingested lines
+func BenchmarkResyncDuplicates(b *testing.B) {
+	const lines = 1000
+	path := writeSyntheticJSONL(b, lines)
+	db, diffSyncService := setupTestDBForDiffSync(b)
+	defer db.Close()
+	addSessionWindowTables(b, db)
+
+	if _, _, err := diffSyncService.processFileFromLine(path, 0); err != nil {
+		b.Fatalf("Failed to process file: %v", err)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := diffSyncService.processFileFromLine(path, 0); err != nil {
+			b.Fatalf("Failed to process file: %v", err)
+		}
+	}
+}
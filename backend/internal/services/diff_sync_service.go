@@ -1,8 +1,9 @@
 package services
 
 import (
-	"bufio"
+	"context"
 	"database/sql"
+	"errors"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -10,79 +11,286 @@ import (
 	"strings"
 	"time"
 
+	"claudeee-backend/internal/logging"
 	"claudeee-backend/internal/models"
 )
 
+// LogSourceConfig describes where Claude logs are read from
+type LogSourceConfig struct {
+	// Roots are Claude projects directories, each holding one directory per project
+	Roots []string
+	// IncludeProjects limits sync to project directories matching these glob patterns
+	IncludeProjects []string
+	// ExcludeProjects skips project directories matching these glob patterns
+	ExcludeProjects []string
+	// RootUsers maps a root to the ID of the user whose logs it holds
+	RootUsers map[string]string
+	// DefaultUser owns the logs of roots missing from RootUsers
+	DefaultUser string
+	// CodexDirs are OpenAI Codex CLI sessions directories, synced alongside
+	// the Claude logs; their logs belong to DefaultUser
+	CodexDirs []string
+	// GeminiLogs are Gemini CLI telemetry files, or directories holding
+	// them; their logs belong to DefaultUser too
+	GeminiLogs []string
+}
+
+// DefaultLogSourceConfig reads every project under ~/.claude/projects
+func DefaultLogSourceConfig() LogSourceConfig {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return LogSourceConfig{}
+	}
+	return LogSourceConfig{Roots: []string{filepath.Join(homeDir, ".claude", "projects")}}
+}
+
+// Matches reports whether a project directory name passes the include/exclude filters
+func (c LogSourceConfig) Matches(project string) bool {
+	if len(c.IncludeProjects) > 0 && !matchAny(c.IncludeProjects, project) {
+		return false
+	}
+	return !matchAny(c.ExcludeProjects, project)
+}
+
+// UserFor returns the ID of the user who owns a log file under one of the roots
+func (c LogSourceConfig) UserFor(filePath string) string {
+	for root, user := range c.RootUsers {
+		if rel, err := filepath.Rel(root, filePath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return user
+		}
+	}
+	return c.DefaultUser
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := filepath.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
 type DiffSyncService struct {
 	db             *sql.DB
 	tokenService   *TokenService
 	sessionService *SessionService
 	windowService  *SessionWindowService
 	stateManager   *FileSyncStateManager
+	sources        LogSourceConfig
+	writes         *WriteQueue
+	// dirtyWindows collects windows touched during a sync pass so their
+	// statistics are recalculated once instead of after every message
+	dirtyWindows map[string]struct{}
+	// dirtySessions collects sessions whose token totals need recalculating
+	// once the current file has been processed
+	dirtySessions map[string]struct{}
+	// knownIDs remembers ingested message UUIDs so duplicate lines, e.g. history
+	// copied into resumed session files, are skipped instead of rewritten
+	knownIDs       *messageIDFilter
+	duplicateLines int
+	parseErrors    int
+	// batch holds the messages of the current file until they are written
+	batch          *messageBatch
+	contentPolicy  ContentPolicy
+	redactor       *Redactor
+	cipher         *ContentCipher
+	// redactions counts secrets removed during the current pass, by kind
+	redactions map[string]int
+	// progress receives an event per project and file during a pass
+	progress func(SyncProgress)
+	// workers is the number of files parsed at once
+	workers int
+	// flagMissingSources flags sessions whose log files were all deleted
+	flagMissingSources bool
+	// fileSources maps the files of the last discovery to their source
+	fileSources map[string]LogSource
 }
 
 func NewDiffSyncService(db *sql.DB, tokenService *TokenService, sessionService *SessionService) *DiffSyncService {
 	stateManager := NewFileSyncStateManager(db)
 	windowService := NewSessionWindowService(db)
+	// Window lookups happen once per message, so keep windows in memory
+	windowService.EnableCache()
 	return &DiffSyncService{
 		db:             db,
 		tokenService:   tokenService,
 		sessionService: sessionService,
 		windowService:  windowService,
 		stateManager:   stateManager,
+		sources:        DefaultLogSourceConfig(),
+		dirtyWindows:   make(map[string]struct{}),
+		dirtySessions:  make(map[string]struct{}),
+		batch:          newMessageBatch(),
+		contentPolicy:  DefaultContentPolicy(),
+		redactions:     make(map[string]int),
+		workers:        DefaultSyncWorkers(),
+		flagMissingSources: true,
 	}
 }
 
-// InitializeSchema initializes the database schema for differential sync
-func (d *DiffSyncService) InitializeSchema() error {
-	return d.stateManager.InitializeSchema()
+// SetWriteQueue routes all sync writes through the shared writer
+func (d *DiffSyncService) SetWriteQueue(writes *WriteQueue) {
+	d.writes = writes
 }
 
-// SyncAllLogs performs differential synchronization of all logs
-func (d *DiffSyncService) SyncAllLogs() (*models.SyncStats, error) {
+// SetContentPolicy controls how much message content is stored at ingest
+func (d *DiffSyncService) SetContentPolicy(policy ContentPolicy) {
+	d.contentPolicy = policy
+}
+
+// SetRedactor enables secret redaction at ingest; nil stores content as logged
+func (d *DiffSyncService) SetRedactor(redactor *Redactor) {
+	d.redactor = redactor
+}
+
+// SetContentCipher encrypts stored content; nil stores it in plain text
+func (d *DiffSyncService) SetContentCipher(c *ContentCipher) {
+	d.cipher = c
+}
+
+// SetWorkers sets how many log files are parsed at once; n < 1 uses
+// DefaultSyncWorkers. Writes stay serialized whatever the count.
+func (d *DiffSyncService) SetWorkers(n int) {
+	if n < 1 {
+		n = DefaultSyncWorkers()
+	}
+	d.workers = n
+}
+
+// SetProgress reports each project and file of a pass to fn; nil disables it
+func (d *DiffSyncService) SetProgress(fn func(SyncProgress)) {
+	d.progress = fn
+}
+
+// reportProgress fills in the pass totals and sends p to the progress function
+func (d *DiffSyncService) reportProgress(p SyncProgress, stats *models.SyncStats) {
+	if d.progress == nil {
+		return
+	}
+	p.FilesFound = stats.TotalFiles
+	p.FilesDone = stats.ProcessedFiles + stats.SkippedFiles + stats.FailedFiles
+	p.LinesProcessed = stats.NewLines
+	p.Errors = stats.FailedFiles + d.parseErrors
+	d.progress(p)
+}
+
+// SetLogSources overrides the directories and project filters used for discovery
+func (d *DiffSyncService) SetLogSources(sources LogSourceConfig) {
+	d.sources = sources
+}
+
+// SyncAllLogs performs differential synchronization of all logs. When ctx is
+// canceled it stops after the lines already parsed, records how far each file
+// got so the next pass resumes there, and returns an error wrapping ctx.Err().
+func (d *DiffSyncService) SyncAllLogs(ctx context.Context) (*models.SyncStats, error) {
 	stats := &models.SyncStats{
 		StartTime: time.Now(),
 	}
-
-	// Initialize schema if needed
-	if err := d.InitializeSchema(); err != nil {
-		return stats, fmt.Errorf("failed to initialize schema: %w", err)
+	if err := ctx.Err(); err != nil {
+		return stats, fmt.Errorf("sync canceled: %w", err)
 	}
 
-	// Clean up old states for deleted files
-	if err := d.stateManager.CleanupOldStates(); err != nil {
-		fmt.Printf("Warning: failed to cleanup old states: %v\n", err)
+	logger := logging.Component("sync")
+
+	// Only one process syncs into a database at a time
+	release, err := acquireSyncLease(d.db, d.writes, syncJobID(ctx))
+	if err != nil {
+		return stats, err
+	}
+	defer release()
+	
+	// Reset states left stuck by an interrupted pass
+	if err := d.writes.Do(d.stateManager.CleanupOldStates); err != nil {
+		logger.Warn("Failed to clean up old file states", "err", err)
 	}
 
-	// Discover all JSONL files
-	files, err := d.discoverJSONLFiles()
+	// Pick up windows and messages written since the previous pass
+	d.windowService.InvalidateCache()
+	d.knownIDs = nil
+	d.duplicateLines = 0
+	d.parseErrors = 0
+
+	// Discover the log files of every source
+	files, err := d.discoverLogFiles()
 	if err != nil {
 		return stats, fmt.Errorf("failed to discover JSONL files: %w", err)
 	}
 
 	stats.TotalFiles = len(files)
-	fmt.Printf("Found %d JSONL files to check\n", len(files))
+	logger.Debug("Discovered log files", "files", len(files))
+
+	// Forget deleted files and follow renamed ones
+	err = d.writes.Do(func() error {
+		removed, err := d.reconcileLogFiles(files)
+		stats.RemovedFiles = removed
+		return err
+	})
+	if err != nil {
+		logger.Warn("Failed to reconcile deleted log files", "err", err)
+	}
+	d.reportProgress(SyncProgress{Type: SyncProgressDiscovered}, stats)
+
+	// Check every file, then parse the changed ones concurrently while
+	// writing them one at a time in discovery order
+	checks := make([]*fileCheck, len(files))
+	for i, file := range files {
+		check := &fileCheck{file: file, decoder: d.sourceFor(file.Path).NewDecoder(file.Path)}
+		check.needsSync, check.lastState, check.err = d.stateManager.NeedsProcessing(file.Path)
+		checks[i] = check
+	}
+	parsed := startParsers(checks, d.workers)
+	defer func() {
+		// Release the parsers of files not reached after a cancellation
+		for check := range parsed {
+			if check.parse != nil {
+				check.parse.stop()
+			}
+		}
+	}()
 
 	// Process each file
-	for _, file := range files {
-		fmt.Printf("Checking file: %s (size: %d, mod: %v)\n", file.Path, file.Size, file.ModTime)
-		needsSync, lastState, err := d.stateManager.NeedsProcessing(file.Path)
-		if err != nil {
-			fmt.Printf("Error checking file %s: %v\n", file.Path, err)
-			continue
+	project := ""
+	var canceled error
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			canceled = err
+			break
+		}
+		check := <-parsed
+		if name := d.extractProjectNameFromPath(file.Path); name != project {
+			project = name
+			projectFiles := 0
+			for _, next := range files[i:] {
+				if d.extractProjectNameFromPath(next.Path) != project {
+					break
+				}
+				projectFiles++
+			}
+			d.reportProgress(SyncProgress{Type: SyncProgressProject, Project: project, ProjectFiles: projectFiles}, stats)
 		}
+		fileProgress := SyncProgress{Type: SyncProgressFile, Project: project, File: file.Path}
 
-		if lastState != nil {
-			fmt.Printf("  Previous state: size=%d, mod=%v, status=%s\n", lastState.FileSize, lastState.LastModified, lastState.SyncStatus)
-		} else {
-			fmt.Printf("  No previous state found\n")
+		needsSync, lastState, err := check.needsSync, check.lastState, check.err
+		if err != nil {
+			logger.Error("Failed to check file", "file", file.Path, "err", err)
+			stats.FailedFiles++
+			fileProgress.FileStatus = SyncFileError
+			fileProgress.Error = err.Error()
+			d.reportProgress(fileProgress, stats)
+			continue
 		}
 
 		if needsSync {
-			fmt.Printf("Processing file: %s\n", file.Path)
-			newLines, err := d.syncFile(file, lastState)
+			newLines, err := d.syncParsedFile(ctx, file, resumePosition(lastState, file.Size), check.parse)
+			if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+				logger.Info("Sync canceled mid-file", "file", file.Path, "lines", newLines)
+				stats.NewLines += newLines
+				canceled = ctx.Err()
+				break
+			}
 			if err != nil {
-				fmt.Printf("Error syncing file %s: %v\n", file.Path, err)
+				logger.Error("Failed to sync file", "file", file.Path, "err", err)
 				// Update state with error
 				errorMsg := err.Error()
 				errorState := &models.FileProcessingState{
@@ -92,109 +300,197 @@ func (d *DiffSyncService) SyncAllLogs() (*models.SyncStats, error) {
 					SyncStatus:   "error",
 					ErrorMessage: &errorMsg,
 				}
-				d.stateManager.UpdateFileState(errorState)
+				d.writes.Do(func() error { return d.stateManager.UpdateFileState(errorState) })
+				stats.FailedFiles++
+				fileProgress.FileStatus = SyncFileError
+				fileProgress.Error = errorMsg
+				d.reportProgress(fileProgress, stats)
 				continue
 			}
+			logger.Debug("Synced file", "file", file.Path, "project", project, "lines", newLines)
 			stats.ProcessedFiles++
 			stats.NewLines += newLines
+			fileProgress.FileStatus = SyncFileProcessed
+			fileProgress.FileLines = newLines
 		} else {
-			fmt.Printf("Skipping unchanged file: %s\n", file.Path)
+			logger.Debug("Skipped unchanged file", "file", file.Path, "project", project)
 			stats.SkippedFiles++
+			fileProgress.FileStatus = SyncFileSkipped
 		}
+		d.reportProgress(fileProgress, stats)
+	}
+
+	if err := d.writes.Do(d.flushWindowStats); err != nil {
+		return stats, fmt.Errorf("failed to update window stats: %w", err)
+	}
+
+	stats.RedactionsByKind = d.redactionCounts()
+	for _, n := range stats.RedactionsByKind {
+		stats.Redactions += n
+	}
+	if err := d.writes.Do(d.flushRedactionCounts); err != nil {
+		logger.Warn("Failed to record redaction counts", "err", err)
 	}
 
+	stats.DuplicateLines = d.duplicateLines
+	stats.ParseErrors = d.parseErrors
 	stats.EndTime = time.Now()
 	stats.ProcessingTime = stats.EndTime.Sub(stats.StartTime)
 
-	fmt.Printf("Sync completed: %d files processed, %d skipped, %d new lines, took %v\n",
-		stats.ProcessedFiles, stats.SkippedFiles, stats.NewLines, stats.ProcessingTime)
+	if canceled != nil {
+		logger.Info("Sync canceled", "processed_files", stats.ProcessedFiles, "new_lines", stats.NewLines)
+		return stats, fmt.Errorf("sync canceled: %w", canceled)
+	}
+
+	logger.Info("Sync completed",
+		"processed_files", stats.ProcessedFiles,
+		"skipped_files", stats.SkippedFiles,
+		"failed_files", stats.FailedFiles,
+		"new_lines", stats.NewLines,
+		"duration", stats.ProcessingTime)
 
 	return stats, nil
 }
 
-// discoverJSONLFiles discovers all JSONL files in Claude projects directory
-func (d *DiffSyncService) discoverJSONLFiles() ([]models.FileInfo, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+// flushWindowStats recalculates statistics for every window touched since the last flush
+func (d *DiffSyncService) flushWindowStats() error {
+	for windowID := range d.dirtyWindows {
+		if err := d.windowService.UpdateWindowStats(windowID); err != nil {
+			return fmt.Errorf("window %s: %w", windowID, err)
+		}
+		delete(d.dirtyWindows, windowID)
 	}
+	return nil
+}
 
-	claudeDir := filepath.Join(homeDir, ".claude", "projects")
-	fmt.Printf("Looking for JSONL files in: %s\n", claudeDir)
-	if _, err := os.Stat(claudeDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("claude projects directory not found: %s", claudeDir)
+// flushSessionTokens recalculates token totals for every session touched
+// since the last flush and titles the new ones
+func (d *DiffSyncService) flushSessionTokens() error {
+	for sessionID := range d.dirtySessions {
+		if err := d.tokenService.UpdateSessionTokens(sessionID); err != nil {
+			return fmt.Errorf("session %s: %w", sessionID, err)
+		}
+		if err := d.sessionService.UpdateSessionTitle(sessionID); err != nil {
+			return fmt.Errorf("session %s: %w", sessionID, err)
+		}
+		delete(d.dirtySessions, sessionID)
 	}
+	return nil
+}
 
-	var files []models.FileInfo
+// discoverJSONLFiles discovers all JSONL files in the configured Claude projects directories
+func (d *DiffSyncService) discoverJSONLFiles() ([]models.FileInfo, error) {
+	return DiscoverLogFiles(d.sources)
+}
 
-	entries, err := os.ReadDir(claudeDir)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read claude projects directory: %w", err)
+// DiscoverLogFiles lists the JSONL files of every project in sources that
+// passes its filters, root by root. Gzip-compressed logs and logs in
+// directories below a project, such as archives, are included.
+func DiscoverLogFiles(sources LogSourceConfig) ([]models.FileInfo, error) {
+	if len(sources.Roots) == 0 {
+		return nil, fmt.Errorf("no claude projects directories configured")
 	}
 
-	fmt.Printf("Found %d entries in claude projects directory\n", len(entries))
+	logger := logging.Component("sync")
+	var files []models.FileInfo
+	found := false
 
-	for _, entry := range entries {
-		fmt.Printf("Processing entry: %s (isDir: %v)\n", entry.Name(), entry.IsDir())
-		if !entry.IsDir() {
+	for _, claudeDir := range sources.Roots {
+		if _, err := os.Stat(claudeDir); os.IsNotExist(err) {
+			logger.Warn("Claude projects directory not found", "dir", claudeDir)
 			continue
 		}
+		found = true
 
-		projectPath := filepath.Join(claudeDir, entry.Name())
-		jsonlFiles, err := filepath.Glob(filepath.Join(projectPath, "*.jsonl"))
+		entries, err := os.ReadDir(claudeDir)
 		if err != nil {
-			fmt.Printf("Warning: failed to glob files in %s: %v\n", projectPath, err)
-			continue
+			return nil, fmt.Errorf("failed to read claude projects directory: %w", err)
 		}
 
-		fmt.Printf("Found %d JSONL files in %s\n", len(jsonlFiles), projectPath)
+		for _, entry := range entries {
+			if !entry.IsDir() || !sources.Matches(entry.Name()) {
+				continue
+			}
 
-		for _, jsonlFile := range jsonlFiles {
-			fileInfo, err := os.Stat(jsonlFile)
+			projectPath := filepath.Join(claudeDir, entry.Name())
+			jsonlFiles, err := listLogFiles(projectPath)
 			if err != nil {
-				fmt.Printf("Warning: failed to stat file %s: %v\n", jsonlFile, err)
+				logger.Warn("Failed to list project log files", "dir", projectPath, "err", err)
 				continue
 			}
 
-			fmt.Printf("Adding file: %s (size: %d)\n", jsonlFile, fileInfo.Size())
-			files = append(files, models.FileInfo{
-				Path:    jsonlFile,
-				ModTime: fileInfo.ModTime(),
-				Size:    fileInfo.Size(),
-			})
+			for _, jsonlFile := range jsonlFiles {
+				fileInfo, err := os.Stat(jsonlFile)
+				if err != nil {
+					logger.Warn("Failed to stat log file", "file", jsonlFile, "err", err)
+					continue
+				}
+
+				files = append(files, models.FileInfo{
+					Path:    jsonlFile,
+					ModTime: fileInfo.ModTime(),
+					Size:    fileInfo.Size(),
+					ID:      fileIdentity(fileInfo),
+				})
+			}
 		}
 	}
 
+	if !found {
+		return nil, fmt.Errorf("claude projects directory not found: %s", strings.Join(sources.Roots, ", "))
+	}
+
 	return files, nil
 }
 
-// syncFile syncs a single file, processing only new lines
+// syncFile syncs a single file, reading only the lines appended since the last pass
 func (d *DiffSyncService) syncFile(file models.FileInfo, lastState *models.FileProcessingState) (int, error) {
-	// Update state to processing
-	processingState := &models.FileProcessingState{
-		FilePath:     file.Path,
-		LastModified: file.ModTime,
-		FileSize:     file.Size,
-		SyncStatus:   "processing",
+	start := resumePosition(lastState, file.Size)
+	return d.syncParsedFile(context.Background(), file, start, startDecode(file.Path, d.sourceFor(file.Path).NewDecoder(file.Path), start, func() {}))
+}
+
+// syncParsedFile writes a file being parsed from start and records its state.
+// If ctx is canceled part way, the lines written so far are kept.
+func (d *DiffSyncService) syncParsedFile(ctx context.Context, file models.FileInfo, start readPosition, parse *fileParse) (int, error) {
+	defer parse.stop()
+
+	// Remember what identifies the file so a replaced or rewritten file is
+	// read from the start instead of from this position
+	var fileID, checksum *string
+	if file.ID != "" {
+		fileID = &file.ID
+	}
+	if sum, err := firstLineChecksum(file.Path); err == nil && sum != "" {
+		checksum = &sum
 	}
 
-	// Keep the previous processed line count if available
-	if lastState != nil {
-		processingState.LastProcessedLine = lastState.LastProcessedLine
+	// Update state to processing, keeping the previous position
+	processingState := &models.FileProcessingState{
+		FilePath:            file.Path,
+		LastModified:        file.ModTime,
+		FileSize:            file.Size,
+		LastProcessedLine:   start.Line,
+		LastProcessedOffset: start.Offset,
+		Checksum:            checksum,
+		FileID:              fileID,
+		SyncStatus:          "processing",
 	}
 
-	err := d.stateManager.UpdateFileState(processingState)
+	err := d.writes.Do(func() error { return d.stateManager.UpdateFileState(processingState) })
 	if err != nil {
 		return 0, fmt.Errorf("failed to update processing state: %w", err)
 	}
 
-	// Process file from the last processed line
-	startLine := 0
-	if lastState != nil {
-		startLine = lastState.LastProcessedLine
+	newLines, end, err := d.processChunks(ctx, file.Path, start, parse)
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		// Leave the file in processing so the next pass resumes after these lines
+		processingState.LastProcessedLine = end.Line
+		processingState.LastProcessedOffset = end.Offset
+		if err := d.writes.Do(func() error { return d.stateManager.UpdateFileState(processingState) }); err != nil {
+			return newLines, fmt.Errorf("failed to record partial progress: %w", err)
+		}
+		return newLines, err
 	}
-
-	newLines, totalLines, err := d.processFileFromLine(file.Path, startLine)
 	if err != nil {
 		return 0, fmt.Errorf("failed to process file: %w", err)
 	}
@@ -202,15 +498,18 @@ func (d *DiffSyncService) syncFile(file models.FileInfo, lastState *models.FileP
 	// Update state to completed
 	now := time.Now()
 	completedState := &models.FileProcessingState{
-		FilePath:          file.Path,
-		LastModified:      file.ModTime,
-		FileSize:          file.Size,
-		LastProcessedLine: totalLines,
-		ProcessedUntil:    &now,
-		SyncStatus:        "completed",
-	}
-
-	err = d.stateManager.UpdateFileState(completedState)
+		FilePath:            file.Path,
+		LastModified:        file.ModTime,
+		FileSize:            file.Size,
+		LastProcessedLine:   end.Line,
+		LastProcessedOffset: end.Offset,
+		ProcessedUntil:      &now,
+		Checksum:            checksum,
+		FileID:              fileID,
+		SyncStatus:          "completed",
+	}
+
+	err = d.writes.Do(func() error { return d.stateManager.UpdateFileState(completedState) })
 	if err != nil {
 		return newLines, fmt.Errorf("failed to update completed state: %w", err)
 	}
@@ -218,72 +517,143 @@ func (d *DiffSyncService) syncFile(file models.FileInfo, lastState *models.FileP
 	return newLines, nil
 }
 
-// processFileFromLine processes a file starting from a specific line
+// processFileFromLine processes a file starting after startLine lines
 func (d *DiffSyncService) processFileFromLine(filePath string, startLine int) (int, int, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return 0, 0, fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
+	processed, end, err := d.processFileFrom(filePath, readPosition{Line: startLine})
+	return processed, end.Line, err
+}
 
-	scanner := bufio.NewScanner(file)
-	
-	// Increase buffer size to handle very long lines (up to 10MB)
-	const maxCapacity = 10 * 1024 * 1024 // 10MB
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
-	
-	lineCount := 0
-	processedCount := 0
+// processFileFrom processes the lines of a file after start and returns the
+// position to resume from next time
+func (d *DiffSyncService) processFileFrom(filePath string, start readPosition) (int, readPosition, error) {
+	return d.processChunks(context.Background(), filePath, start, startDecode(filePath, d.sourceFor(filePath).NewDecoder(filePath), start, func() {}))
+}
 
-	// Skip already processed lines
-	for lineCount < startLine && scanner.Scan() {
-		lineCount++
-	}
+// processChunks writes the entries parsed from a file and returns the
+// position to resume from next time. When ctx is canceled it stops at a chunk
+// boundary, writes what it has and returns ctx.Err().
+func (d *DiffSyncService) processChunks(ctx context.Context, filePath string, start readPosition, parse *fileParse) (int, readPosition, error) {
+	defer parse.stop()
 
-	// Process new lines
-	for scanner.Scan() {
-		lineCount++
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+	processedCount := 0
+	end := start
+	var readErr error
+	projectName := d.extractProjectNameFromPath(filePath)
+	userID := d.sources.UserFor(filePath)
+	// sessionIDs are the sessions with entries in this file, stored or not
+	sessionIDs := make(map[string]struct{})
+
+	for chunk := range parse.chunks {
+		if err := ctx.Err(); err != nil {
+			readErr = err
+			break
 		}
-
-		// First, try to parse as a basic JSON to check if it has required fields
-		var basicCheck map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &basicCheck); err != nil {
-			fmt.Printf("Error parsing JSON on line %d: %v\n", lineCount, err)
-			continue
+		if !chunk.opened {
+			return 0, start, chunk.err
 		}
+		d.parseErrors += len(chunk.parseErrors)
+		d.recordParseErrors(filePath, chunk.parseErrors)
+		for i := range chunk.entries {
+			entry := &chunk.entries[i]
+			sessionIDs[entry.SessionID] = struct{}{}
+			hash := logEntryHash(entry)
+			ingested, err := d.isIngested(logEntryID(entry, hash))
+			if err != nil {
+				logging.Component("sync").Error("Failed to check log entry", "file", filePath, "line", chunk.lines[i], "err", err)
+				continue
+			}
+			if ingested {
+				d.duplicateLines++
+				processedCount++
+				continue
+			}
 
-		// Check if this looks like a LogEntry (has sessionId and timestamp)
-		sessionId, hasSessionId := basicCheck["sessionId"]
-		timestamp, hasTimestamp := basicCheck["timestamp"]
-		if !hasSessionId || !hasTimestamp || sessionId == nil || timestamp == nil {
-			// Skip non-LogEntry entries (like summary entries)
-			continue
-		}
+			d.queueLogEntry(entry, hash, projectName, userID)
+			processedCount++
 
-		var entry models.LogEntry
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			fmt.Printf("Error unmarshaling LogEntry on line %d: %v\n", lineCount, err)
-			continue
+			if d.batch.full() {
+				if err := d.writes.Do(d.writeBatch); err != nil {
+					return 0, start, fmt.Errorf("failed to write messages: %w", err)
+				}
+			}
 		}
+		end = chunk.end
+		readErr = chunk.err
+	}
 
-		// Extract project name from file path
-		projectName := d.extractProjectNameFromPath(filePath)
-		if err := d.processLogEntry(&entry, projectName); err != nil {
-			fmt.Printf("Error processing log entry %d: %v\n", lineCount, err)
-			continue
+	if err := d.writes.Do(d.writeBatch); err != nil {
+		return 0, start, fmt.Errorf("failed to write messages: %w", err)
+	}
+
+	if err := d.writes.Do(d.flushSessionTokens); err != nil {
+		return processedCount, end, fmt.Errorf("failed to update session tokens: %w", err)
+	}
+
+	err := d.writes.Do(func() error { return d.recordSessionSources(filePath, sessionIDs) })
+	if err != nil {
+		logging.Component("sync").Warn("Failed to record session sources", "file", filePath, "err", err)
+	}
+
+	if readErr != nil {
+		return processedCount, end, readErr
+	}
+
+	return processedCount, end, nil
+}
+
+// isIngested reports whether a message with this UUID is already stored. The
+// Bloom filter rules out most new messages without a query.
+func (d *DiffSyncService) isIngested(id string) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+	if d.batch.contains(id) {
+		return true, nil
+	}
+	if d.knownIDs == nil {
+		if err := d.loadKnownMessageIDs(); err != nil {
+			return false, err
 		}
-		processedCount++
 	}
+	if !d.knownIDs.mayContain(id) {
+		return false, nil
+	}
+
+	var count int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE id = ?`, id).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to look up message: %w", err)
+	}
+	return count > 0, nil
+}
+
+// loadKnownMessageIDs builds the filter from the messages already in the database
+func (d *DiffSyncService) loadKnownMessageIDs() error {
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&total); err != nil {
+		return fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	// Leave headroom for the messages this pass will add
+	filter := newMessageIDFilter(total * 2)
+	rows, err := d.db.Query(`SELECT id FROM messages`)
+	if err != nil {
+		return fmt.Errorf("failed to load message ids: %w", err)
+	}
+	defer rows.Close()
 
-	if err := scanner.Err(); err != nil {
-		return processedCount, lineCount, fmt.Errorf("scanner error: %w", err)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("failed to scan message id: %w", err)
+		}
+		filter.add(id)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load message ids: %w", err)
 	}
 
-	return processedCount, lineCount, nil
+	d.knownIDs = filter
+	return nil
 }
 
 // extractProjectNameFromPath extracts project name from file path
@@ -292,8 +662,9 @@ func (d *DiffSyncService) extractProjectNameFromPath(filePath string) string {
 	return filepath.Base(dir)
 }
 
-// processLogEntry processes a single log entry (similar to existing logic)
-func (d *DiffSyncService) processLogEntry(entry *models.LogEntry, projectName string) error {
+// queueLogEntry adds a log entry, whose logEntryHash is hash, to the batch of
+// the current file
+func (d *DiffSyncService) queueLogEntry(entry *models.LogEntry, hash, projectName, userID string) {
 	// Use cwd from log entry if available, otherwise fall back to project name conversion
 	var actualProjectPath, actualProjectName string
 	if entry.Cwd != "" {
@@ -304,12 +675,8 @@ func (d *DiffSyncService) processLogEntry(entry *models.LogEntry, projectName st
 		actualProjectName = projectName
 	}
 
-	if err := d.sessionService.CreateOrUpdateSession(entry.SessionID, actualProjectName, actualProjectPath, entry.Timestamp); err != nil {
-		return fmt.Errorf("failed to create/update session: %w", err)
-	}
-
 	message := &models.Message{
-		ID:          entry.UUID,
+		ID:          logEntryID(entry, hash),
 		SessionID:   entry.SessionID,
 		ParentUUID:  entry.ParentUUID,
 		IsSidechain: entry.IsSidechain,
@@ -319,11 +686,20 @@ func (d *DiffSyncService) processLogEntry(entry *models.LogEntry, projectName st
 		Model:       entry.Message.Model,
 		Timestamp:   entry.Timestamp,
 		RequestID:   entry.RequestID,
+		Provider:    entry.Provider,
+	}
+	if message.Provider == "" {
+		message.Provider = ProviderClaude
+	}
+	if hash != "" {
+		message.ContentHash = &hash
 	}
 
-	if entry.Message.Content != nil {
-		contentStr := d.convertContentToString(entry.Message.Content)
-		message.Content = &contentStr
+	// Under the metadata policy content is never converted, redacted or stored
+	if entry.Message.Content != nil && d.contentPolicy.Mode != ContentPolicyMetadata {
+		// Redact before truncating so a secret is never stored half-cut
+		contentStr := d.redact(d.convertContentToString(entry.Message.Content))
+		message.Content = d.cipher.Seal(d.contentPolicy.Apply(contentStr))
 	}
 
 	if entry.Message.Usage != nil {
@@ -332,28 +708,44 @@ func (d *DiffSyncService) processLogEntry(entry *models.LogEntry, projectName st
 		message.CacheReadInputTokens = entry.Message.Usage.CacheReadInputTokens
 		message.OutputTokens = entry.Message.Usage.OutputTokens
 		message.ServiceTier = &entry.Message.Usage.ServiceTier
+		message.Billing = logEntryBilling(entry)
 	}
 
-	// Get or create appropriate session window for this message
-	window, err := d.windowService.GetOrCreateWindowForMessage(entry.Timestamp)
-	if err != nil {
-		return fmt.Errorf("failed to get/create session window: %w", err)
+	d.batch.add(message, actualProjectName, actualProjectPath, userID)
+	d.batch.addUsageKey(message, entry)
+	d.batch.addToolBlocks(entry)
+	// Limit events are Claude's usage limits
+	if message.Provider == ProviderClaude {
+		d.batch.addLimitEvent(entry)
 	}
-	message.SessionWindowID = &window.ID
+}
 
-	if err := d.insertMessage(message); err != nil {
-		return fmt.Errorf("failed to insert message: %w", err)
+// writeBatch stores the queued messages. Session totals are recalculated once
+// the whole file has been processed and window statistics at the end of the
+// sync pass.
+func (d *DiffSyncService) writeBatch() error {
+	ids := make([]string, 0, len(d.batch.messages))
+	for _, message := range d.batch.messages {
+		ids = append(ids, message.ID)
 	}
 
-	// Update window statistics after message insertion
-	if err := d.windowService.UpdateWindowStats(window.ID); err != nil {
-		return fmt.Errorf("failed to update window stats: %w", err)
+	sessionIDs, windowIDs, err := d.batch.write(d.db, d.windowService)
+	if err != nil {
+		return err
 	}
-
-	if err := d.tokenService.UpdateSessionTokens(entry.SessionID); err != nil {
-		return fmt.Errorf("failed to update session tokens: %w", err)
+	if d.knownIDs != nil {
+		for _, id := range ids {
+			if id != "" {
+				d.knownIDs.add(id)
+			}
+		}
+	}
+	for _, sessionID := range sessionIDs {
+		d.dirtySessions[sessionID] = struct{}{}
+	}
+	for windowID := range windowIDs {
+		d.dirtyWindows[windowID] = struct{}{}
 	}
-
 	return nil
 }
 
@@ -407,49 +799,6 @@ func (d *DiffSyncService) convertContentToString(content interface{}) string {
 	}
 }
 
-func (d *DiffSyncService) insertMessage(message *models.Message) error {
-	// Use INSERT OR REPLACE to handle both insert and update atomically
-	upsertQuery := `
-		INSERT OR REPLACE INTO messages (
-			id, session_id, session_window_id, parent_uuid, is_sidechain, user_type, message_type,
-			message_role, model, content, input_tokens, cache_creation_input_tokens,
-			cache_read_input_tokens, output_tokens, service_tier, request_id,
-			timestamp, created_at
-		) VALUES (
-			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
-			COALESCE((SELECT created_at FROM messages WHERE id = ?), ?)
-		)
-	`
-	
-	now := time.Now()
-	_, err := d.db.Exec(upsertQuery,
-		message.ID,
-		message.SessionID,
-		message.SessionWindowID,
-		message.ParentUUID,
-		message.IsSidechain,
-		message.UserType,
-		message.MessageType,
-		message.MessageRole,
-		message.Model,
-		message.Content,
-		message.InputTokens,
-		message.CacheCreationInputTokens,
-		message.CacheReadInputTokens,
-		message.OutputTokens,
-		message.ServiceTier,
-		message.RequestID,
-		message.Timestamp,
-		message.ID, // for COALESCE subquery
-		now,        // created_at for new records
-	)
-	if err != nil {
-		return fmt.Errorf("failed to upsert message: %w", err)
-	}
-
-	return nil
-}
-
 // GetSyncStats returns current synchronization statistics
 func (d *DiffSyncService) GetSyncStats() (*models.SyncStats, error) {
 	states, err := d.stateManager.GetAllFileStates()
@@ -1,16 +1,20 @@
 package services
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"claudeee-backend/internal/database"
 	"claudeee-backend/internal/models"
 	_ "github.com/marcboeker/go-duckdb"
 )
 
-func setupTestDBForDiffSync(t *testing.T) (*sql.DB, *DiffSyncService) {
+func setupTestDBForDiffSync(t testing.TB) (*sql.DB, *DiffSyncService) {
 	db, err := sql.Open("duckdb", ":memory:")
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
@@ -29,7 +33,9 @@ func setupTestDBForDiffSync(t *testing.T) (*sql.DB, *DiffSyncService) {
 			total_tokens INTEGER DEFAULT 0,
 			message_count INTEGER DEFAULT 0,
 			status TEXT DEFAULT 'active',
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			title TEXT,
+			provider TEXT DEFAULT 'claude'
 		);
 
 		CREATE TABLE IF NOT EXISTS messages (
@@ -48,6 +54,9 @@ func setupTestDBForDiffSync(t *testing.T) (*sql.DB, *DiffSyncService) {
 			output_tokens INTEGER DEFAULT 0,
 			service_tier TEXT,
 			request_id TEXT,
+			content_hash TEXT,
+			billing TEXT,
+			provider TEXT DEFAULT 'claude',
 			timestamp TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
@@ -57,16 +66,11 @@ func setupTestDBForDiffSync(t *testing.T) (*sql.DB, *DiffSyncService) {
 	if err != nil {
 		t.Fatalf("Failed to create test tables: %v", err)
 	}
+	applyMigrationsAfterCore(t, db)
 
 	tokenService := NewTokenService(db)
 	sessionService := NewSessionService(db)
 	diffSyncService := NewDiffSyncService(db, tokenService, sessionService)
-	
-	// Initialize schema
-	err = diffSyncService.InitializeSchema()
-	if err != nil {
-		t.Fatalf("Failed to initialize schema: %v", err)
-	}
 
 	return db, diffSyncService
 }
@@ -304,4 +308,260 @@ func TestGetSyncStats(t *testing.T) {
 	if stats.SkippedFiles != 0 {
 		t.Errorf("Expected 0 skipped files, got %d", stats.SkippedFiles)
 	}
-}
\ No newline at end of file
+}
+// applyMigrationsAfterCore runs every migration but the first, which holds
+// the core tables the tests create with looser constraints and no indexes
+func applyMigrationsAfterCore(t testing.TB, db *sql.DB) {
+	migrations, err := database.Migrations()
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.Version == 1 {
+			continue
+		}
+		for _, statement := range m.Statements {
+			if _, err := db.Exec(statement); err != nil {
+				t.Fatalf("Failed to apply migration %d_%s: %v", m.Version, m.Name, err)
+			}
+		}
+	}
+}
+
+func addSessionWindowTables(t testing.TB, db *sql.DB) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS session_windows (
+			id TEXT PRIMARY KEY,
+			window_start TIMESTAMP NOT NULL,
+			window_end TIMESTAMP NOT NULL,
+			reset_time TIMESTAMP NOT NULL,
+			total_input_tokens INTEGER DEFAULT 0,
+			total_output_tokens INTEGER DEFAULT 0,
+			total_tokens INTEGER DEFAULT 0,
+			message_count INTEGER DEFAULT 0,
+			session_count INTEGER DEFAULT 0,
+			is_active BOOLEAN DEFAULT true,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		ALTER TABLE messages ADD COLUMN IF NOT EXISTS session_window_id TEXT;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create session window tables: %v", err)
+	}
+}
+
+func TestWindowStatsUpdatedOncePerPass(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+
+	tmpFile, err := os.CreateTemp("", "test-window-*.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	testData := []string{
+		`{"uuid":"w1","sessionId":"session1","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:00:00Z","message":{"role":"assistant","content":"a","usage":{"input_tokens":10,"output_tokens":5}}}`,
+		`{"uuid":"w2","sessionId":"session1","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:30:00Z","message":{"role":"assistant","content":"b","usage":{"input_tokens":20,"output_tokens":5}}}`,
+	}
+	for _, data := range testData {
+		tmpFile.WriteString(data + "\n")
+	}
+	tmpFile.Close()
+
+	newLines, _, err := diffSyncService.processFileFromLine(tmpFile.Name(), 0)
+	if err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+	if newLines != 2 {
+		t.Fatalf("Expected 2 new lines, got %d", newLines)
+	}
+	if len(diffSyncService.dirtyWindows) != 1 {
+		t.Errorf("Expected 1 dirty window, got %d", len(diffSyncService.dirtyWindows))
+	}
+
+	if err := diffSyncService.flushWindowStats(); err != nil {
+		t.Fatalf("Failed to flush window stats: %v", err)
+	}
+	if len(diffSyncService.dirtyWindows) != 0 {
+		t.Errorf("Expected dirty windows to be cleared, got %d", len(diffSyncService.dirtyWindows))
+	}
+
+	var totalTokens, messageCount int
+	err = db.QueryRow("SELECT total_tokens, message_count FROM session_windows").Scan(&totalTokens, &messageCount)
+	if err != nil {
+		t.Fatalf("Failed to query window stats: %v", err)
+	}
+	if totalTokens != 40 {
+		t.Errorf("Expected 40 total tokens, got %d", totalTokens)
+	}
+	if messageCount != 2 {
+		t.Errorf("Expected 2 messages, got %d", messageCount)
+	}
+}
+
+func TestSessionTokensUpdatedOncePerFile(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+
+	tmpFile, err := os.CreateTemp("", "test-session-*.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	testData := []string{
+		`{"uuid":"s1","sessionId":"session1","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:00:00Z","message":{"role":"assistant","content":"a","usage":{"input_tokens":10,"output_tokens":5}}}`,
+		`{"uuid":"s2","sessionId":"session1","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:01:00Z","message":{"role":"assistant","content":"b","usage":{"input_tokens":20,"output_tokens":5}}}`,
+		`{"uuid":"s3","sessionId":"session2","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:02:00Z","message":{"role":"assistant","content":"c","usage":{"input_tokens":1,"output_tokens":1}}}`,
+	}
+	for _, data := range testData {
+		tmpFile.WriteString(data + "\n")
+	}
+	tmpFile.Close()
+
+	if _, _, err := diffSyncService.processFileFromLine(tmpFile.Name(), 0); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+	if len(diffSyncService.dirtySessions) != 0 {
+		t.Errorf("Expected dirty sessions to be flushed, got %d", len(diffSyncService.dirtySessions))
+	}
+
+	var totalTokens, messageCount int
+	err = db.QueryRow("SELECT total_tokens, message_count FROM sessions WHERE id = 'session1'").Scan(&totalTokens, &messageCount)
+	if err != nil {
+		t.Fatalf("Failed to query session totals: %v", err)
+	}
+	if totalTokens != 40 {
+		t.Errorf("Expected 40 total tokens, got %d", totalTokens)
+	}
+	if messageCount != 2 {
+		t.Errorf("Expected 2 messages, got %d", messageCount)
+	}
+}
+
+func TestDuplicateLinesAreSkipped(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+
+	line := `{"uuid":"dup-1","sessionId":"session1","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:00:00Z","message":{"role":"assistant","content":"a","usage":{"input_tokens":10,"output_tokens":5}}}`
+
+	// A resumed session copies earlier history into a new file
+	var paths []string
+	for i := 0; i < 2; i++ {
+		tmpFile, err := os.CreateTemp("", "test-dup-*.jsonl")
+		if err != nil {
+			t.Fatalf("Failed to create temp file: %v", err)
+		}
+		defer os.Remove(tmpFile.Name())
+		tmpFile.WriteString(line + "\n")
+		tmpFile.Close()
+		paths = append(paths, tmpFile.Name())
+	}
+
+	for _, path := range paths {
+		if _, _, err := diffSyncService.processFileFromLine(path, 0); err != nil {
+			t.Fatalf("Failed to process file: %v", err)
+		}
+	}
+
+	if diffSyncService.duplicateLines != 1 {
+		t.Errorf("Expected 1 duplicate line, got %d", diffSyncService.duplicateLines)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&count); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 message, got %d", count)
+	}
+}
+
+func TestLargeFileIsWrittenInBatches(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+
+	// More lines than one batch holds, across 50 sessions
+	path := writeSyntheticJSONL(t, 2*maxBatchMessages+500)
+	processed, end, err := diffSyncService.processFileFrom(path, readPosition{})
+	if err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+	if processed != 2500 || end.Line != 2500 {
+		t.Errorf("Expected 2500 processed lines, got %d (line %d)", processed, end.Line)
+	}
+	if len(diffSyncService.batch.messages) != 0 {
+		t.Errorf("Expected the batch to be written, %d messages left", len(diffSyncService.batch.messages))
+	}
+
+	var messages, sessions int
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages WHERE session_window_id IS NOT NULL").Scan(&messages); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&sessions); err != nil {
+		t.Fatalf("Failed to count sessions: %v", err)
+	}
+	if messages != 2500 {
+		t.Errorf("Expected 2500 messages with windows, got %d", messages)
+	}
+	if sessions != 50 {
+		t.Errorf("Expected 50 sessions, got %d", sessions)
+	}
+
+	var messageCount int
+	var start time.Time
+	err = db.QueryRow("SELECT message_count, start_time FROM sessions WHERE id = 'session-20'").Scan(&messageCount, &start)
+	if err != nil {
+		t.Fatalf("Failed to query session: %v", err)
+	}
+	// message_count counts assistant messages, every other line
+	if messageCount != 25 {
+		t.Errorf("Expected 25 assistant messages in session-20, got %d", messageCount)
+	}
+	// session-20 spans the first batch boundary; its start is its earliest message
+	if want := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC).Add(1000 * 20 * time.Second); !start.Equal(want) {
+		t.Errorf("Expected session start %v, got %v", want, start)
+	}
+}
+
+func TestSyncReadsArchivedLogs(t *testing.T) {
+	root := t.TempDir()
+	line := `{"uuid":"%s","sessionId":"%s","cwd":"/work/app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"hi"}}` + "\n"
+	writeGzip(t, filepath.Join(root, "-work-app", "old.jsonl.gz"), fmt.Sprintf(line, "a", "old"))
+	writeGzip(t, filepath.Join(root, "-work-app", "archive", "2023", "older.jsonl.gz"), fmt.Sprintf(line, "b", "older"))
+	if err := os.WriteFile(filepath.Join(root, "-work-app", "new.jsonl"), []byte(fmt.Sprintf(line, "c", "new")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+	diffSyncService.SetLogSources(LogSourceConfig{Roots: []string{root}})
+
+	stats, err := diffSyncService.SyncAllLogs(context.Background())
+	if err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+	if stats.TotalFiles != 3 || stats.NewLines != 3 {
+		t.Errorf("Expected 3 files with one line each, got %+v", stats)
+	}
+	var sessions int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions").Scan(&sessions); err != nil {
+		t.Fatalf("Failed to count sessions: %v", err)
+	}
+	if sessions != 3 {
+		t.Errorf("Expected 3 sessions, got %d", sessions)
+	}
+
+	// Compressed logs are not read again while unchanged
+	stats, err = diffSyncService.SyncAllLogs(context.Background())
+	if err != nil || stats.ProcessedFiles != 0 {
+		t.Errorf("Expected nothing to sync, got %+v (%v)", stats, err)
+	}
+}
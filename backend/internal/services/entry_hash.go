@@ -0,0 +1,34 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"claudeee-backend/internal/models"
+)
+
+// hashedIDPrefix marks message IDs derived from the content hash of an entry
+// that has no uuid
+const hashedIDPrefix = "sha256:"
+
+// logEntryHash returns the SHA-256 of a log entry as decoded, so a line
+// synced from a file and the same line pushed by an agent hash alike
+func logEntryHash(entry *models.LogEntry) string {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// logEntryID returns the ID a log entry is stored under: its uuid, or for
+// entries without one an ID derived from hash, so reading the entry again
+// finds it instead of storing it twice
+func logEntryID(entry *models.LogEntry, hash string) string {
+	if entry.UUID != "" || hash == "" {
+		return entry.UUID
+	}
+	return hashedIDPrefix + hash
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats
+const (
+	ExportCSV   = "csv"
+	ExportJSON  = "json"
+	ExportJSONL = "jsonl"
+)
+
+// ValidateExportFormat checks that format is one of the export formats
+func ValidateExportFormat(format string) error {
+	switch format {
+	case ExportCSV, ExportJSON, ExportJSONL:
+		return nil
+	}
+	return fmt.Errorf("format must be csv, json or jsonl")
+}
+
+// SessionExportHeader names the CSV columns of ExportedSession.Row
+var SessionExportHeader = []string{
+	"session_id", "project_name", "project_path", "status", "session_start", "first_message", "last_message",
+	"models", "input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens",
+	"total_tokens", "message_count", "cost",
+}
+
+// MessageExportHeader names the CSV columns of ExportedMessage.Row
+func MessageExportHeader(content bool) []string {
+	header := []string{
+		"message_id", "session_id", "project_name", "timestamp", "role", "model", "input_tokens", "output_tokens",
+		"cache_creation_input_tokens", "cache_read_input_tokens", "cost",
+	}
+	if content {
+		header = append(header, "content")
+	}
+	return header
+}
+
+func formatExportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatExportCost(cost float64) string {
+	return strconv.FormatFloat(cost, 'f', 6, 64)
+}
+
+// Row is the session's CSV row
+func (s ExportedSession) Row() []string {
+	start := ""
+	if s.SessionStart != nil {
+		start = formatExportTime(*s.SessionStart)
+	}
+	return []string{
+		s.SessionID, s.ProjectName, s.ProjectPath, s.Status, start, formatExportTime(s.FirstMessage), formatExportTime(s.LastMessage),
+		strings.Join(s.Models, " "),
+		strconv.FormatInt(s.InputTokens, 10), strconv.FormatInt(s.OutputTokens, 10),
+		strconv.FormatInt(s.CacheCreationInputTokens, 10), strconv.FormatInt(s.CacheReadInputTokens, 10),
+		strconv.FormatInt(s.TotalTokens, 10), strconv.Itoa(s.MessageCount), formatExportCost(s.Cost),
+	}
+}
+
+// Row is the message's CSV row, with content when the export includes it
+func (m ExportedMessage) Row(content bool) []string {
+	row := []string{
+		m.MessageID, m.SessionID, m.ProjectName, formatExportTime(m.Timestamp), m.Role, m.Model,
+		strconv.FormatInt(m.InputTokens, 10), strconv.FormatInt(m.OutputTokens, 10),
+		strconv.FormatInt(m.CacheCreationInputTokens, 10), strconv.FormatInt(m.CacheReadInputTokens, 10),
+		formatExportCost(m.Cost),
+	}
+	if content {
+		text := ""
+		if m.Content != nil {
+			text = *m.Content
+		}
+		row = append(row, text)
+	}
+	return row
+}
+
+// RecordWriter writes export records as CSV, a JSON array or JSON lines
+type RecordWriter struct {
+	out    io.Writer
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+	count  int
+	// flush is called every flushEvery records and when the export ends
+	flush      func()
+	flushEvery int
+}
+
+// NewRecordWriter starts an export to out; header is written for CSV
+func NewRecordWriter(out io.Writer, format string, header []string) (*RecordWriter, error) {
+	w := &RecordWriter{out: out, format: format}
+	var err error
+	switch format {
+	case ExportCSV:
+		w.csv = csv.NewWriter(out)
+		err = w.csv.Write(header)
+	case ExportJSONL:
+		w.json = json.NewEncoder(out)
+	default:
+		w.json = json.NewEncoder(out)
+		_, err = io.WriteString(out, "[")
+	}
+	return w, err
+}
+
+// FlushEvery calls fn after every n records, so streamed exports reach the
+// reader while they are produced
+func (w *RecordWriter) FlushEvery(n int, fn func()) {
+	w.flushEvery = n
+	w.flush = fn
+}
+
+// Write adds one record; row is its CSV form
+func (w *RecordWriter) Write(record interface{}, row []string) error {
+	var err error
+	switch w.format {
+	case ExportCSV:
+		err = w.csv.Write(row)
+	case ExportJSON:
+		if w.count > 0 {
+			if _, err := io.WriteString(w.out, ","); err != nil {
+				return err
+			}
+		}
+		err = w.json.Encode(record)
+	default:
+		err = w.json.Encode(record)
+	}
+	if err != nil {
+		return err
+	}
+	w.count++
+	if w.flushEvery > 0 && w.count%w.flushEvery == 0 {
+		w.Flush()
+	}
+	return nil
+}
+
+// Count is the number of records written
+func (w *RecordWriter) Count() int {
+	return w.count
+}
+
+// Flush writes buffered CSV rows and calls the flush function
+func (w *RecordWriter) Flush() {
+	if w.csv != nil {
+		w.csv.Flush()
+	}
+	if w.flush != nil {
+		w.flush()
+	}
+}
+
+// Close completes a successful export by closing the JSON array
+func (w *RecordWriter) Close() error {
+	if w.format == ExportJSON {
+		if _, err := io.WriteString(w.out, "]\n"); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if w.csv != nil {
+		return w.csv.Error()
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportQuery selects the usage to export. Zero times leave the range open.
+type ExportQuery struct {
+	Project string
+	User    string
+	From    time.Time
+	To      time.Time
+	// Provider limits the export to one tool's messages, e.g. claude
+	Provider string
+	// Content includes message content in message exports
+	Content bool
+}
+
+// ExportedSession is a session's usage within the export range
+type ExportedSession struct {
+	SessionID                string     `json:"session_id"`
+	ProjectName              string     `json:"project_name"`
+	ProjectPath              string     `json:"project_path"`
+	Status                   string     `json:"status"`
+	SessionStart             *time.Time `json:"session_start"`
+	FirstMessage             time.Time  `json:"first_message"`
+	LastMessage              time.Time  `json:"last_message"`
+	Models                   []string   `json:"models"`
+	InputTokens              int64      `json:"input_tokens"`
+	OutputTokens             int64      `json:"output_tokens"`
+	CacheCreationInputTokens int64      `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64      `json:"cache_read_input_tokens"`
+	TotalTokens              int64      `json:"total_tokens"`
+	MessageCount             int        `json:"message_count"`
+	Cost                     float64    `json:"cost"`
+}
+
+// ExportedMessage is one message with its project and cost
+type ExportedMessage struct {
+	MessageID                string    `json:"message_id"`
+	SessionID                string    `json:"session_id"`
+	ProjectName              string    `json:"project_name"`
+	Timestamp                time.Time `json:"timestamp"`
+	Role                     string    `json:"role"`
+	Model                    string    `json:"model"`
+	InputTokens              int64     `json:"input_tokens"`
+	OutputTokens             int64     `json:"output_tokens"`
+	CacheCreationInputTokens int64     `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64     `json:"cache_read_input_tokens"`
+	Cost                     float64   `json:"cost"`
+	Provider                 string    `json:"provider"`
+	Content                  *string   `json:"content,omitempty"`
+}
+
+// ExportService produces flat usage records for reports
+type ExportService struct {
+	db      *sql.DB
+	cipher  *ContentCipher
+	pricing *PricingCalculator
+}
+
+func NewExportService(db *sql.DB) *ExportService {
+	return &ExportService{db: db, pricing: NewPricingCalculator()}
+}
+
+// SetContentCipher decrypts stored content for exports that include it
+func (s *ExportService) SetContentCipher(c *ContentCipher) {
+	s.cipher = c
+}
+
+// exportFilter builds the conditions shared by the export queries
+func exportFilter(q ExportQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if q.Project != "" {
+		conditions = append(conditions, "s.project_name = ?")
+		args = append(args, q.Project)
+	}
+	if q.User != "" {
+		conditions = append(conditions, "s.user_id = ?")
+		args = append(args, q.User)
+	}
+	if q.Provider != "" {
+		conditions = append(conditions, "COALESCE(m.provider, 'claude') = ?")
+		args = append(args, q.Provider)
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "m.timestamp >= ?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "m.timestamp < ?")
+		args = append(args, q.To)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// StreamSessions calls fn for every session with messages in the range, in
+// order of first message. Tokens and cost only count messages in the range,
+// so exports of consecutive months add up.
+func (s *ExportService) StreamSessions(q ExportQuery, fn func(ExportedSession) error) error {
+	where, args := exportFilter(q)
+	if where != "" {
+		where = "WHERE " + where
+	}
+	rows, err := s.db.Query(`
+		SELECT
+			s.id,
+			COALESCE(s.project_name, ''),
+			COALESCE(s.project_path, ''),
+			COALESCE(s.status, ''),
+			s.start_time,
+			COALESCE(m.model, ''),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0),
+			COUNT(*) FILTER (WHERE m.message_role = 'assistant'),
+			MIN(m.timestamp),
+			MAX(m.timestamp)
+		FROM messages m
+		JOIN sessions s ON s.id = m.session_id
+		`+where+`
+		GROUP BY s.id, s.project_name, s.project_path, s.status, s.start_time, COALESCE(m.model, '')
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to export sessions: %w", err)
+	}
+
+	// One row per session and model; the rows are small enough to merge in memory
+	var order []string
+	sessions := make(map[string]*ExportedSession)
+	for rows.Next() {
+		var row ExportedSession
+		var start sql.NullTime
+		var model string
+		if err := rows.Scan(&row.SessionID, &row.ProjectName, &row.ProjectPath, &row.Status, &start, &model,
+			&row.InputTokens, &row.OutputTokens, &row.CacheCreationInputTokens, &row.CacheReadInputTokens,
+			&row.MessageCount, &row.FirstMessage, &row.LastMessage); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan session export: %w", err)
+		}
+
+		session, ok := sessions[row.SessionID]
+		if !ok {
+			session = &ExportedSession{
+				SessionID:    row.SessionID,
+				ProjectName:  row.ProjectName,
+				ProjectPath:  row.ProjectPath,
+				Status:       row.Status,
+				FirstMessage: row.FirstMessage,
+				LastMessage:  row.LastMessage,
+				Models:       []string{},
+			}
+			if start.Valid {
+				session.SessionStart = &start.Time
+			}
+			sessions[row.SessionID] = session
+			order = append(order, row.SessionID)
+		}
+		if row.FirstMessage.Before(session.FirstMessage) {
+			session.FirstMessage = row.FirstMessage
+		}
+		if row.LastMessage.After(session.LastMessage) {
+			session.LastMessage = row.LastMessage
+		}
+		session.InputTokens += row.InputTokens
+		session.OutputTokens += row.OutputTokens
+		session.CacheCreationInputTokens += row.CacheCreationInputTokens
+		session.CacheReadInputTokens += row.CacheReadInputTokens
+		session.TotalTokens += row.InputTokens + row.OutputTokens
+		session.MessageCount += row.MessageCount
+		if model != "" {
+			session.Models = append(session.Models, model)
+			session.Cost += s.pricing.CalculateCost(model, int(row.InputTokens), int(row.OutputTokens),
+				int(row.CacheCreationInputTokens), int(row.CacheReadInputTokens))
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to export sessions: %w", err)
+	}
+
+	exported := make([]*ExportedSession, 0, len(order))
+	for _, id := range order {
+		exported = append(exported, sessions[id])
+	}
+	sort.Slice(exported, func(i, j int) bool {
+		if !exported[i].FirstMessage.Equal(exported[j].FirstMessage) {
+			return exported[i].FirstMessage.Before(exported[j].FirstMessage)
+		}
+		return exported[i].SessionID < exported[j].SessionID
+	})
+	for _, session := range exported {
+		session.Cost = roundToDecimals(session.Cost, 6)
+		sort.Strings(session.Models)
+		if err := fn(*session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamMessages calls fn for every message in the range in timestamp order,
+// reading in batches so memory stays flat for any export size
+func (s *ExportService) StreamMessages(q ExportQuery, fn func(ExportedMessage) error) error {
+	var after *MessageCursor
+	for {
+		batch, err := s.messageBatch(q, after)
+		if err != nil {
+			return err
+		}
+		for _, message := range batch {
+			if err := fn(message); err != nil {
+				return err
+			}
+		}
+		if len(batch) < streamBatchSize {
+			return nil
+		}
+		last := batch[len(batch)-1]
+		after = &MessageCursor{Timestamp: last.Timestamp, ID: last.MessageID}
+	}
+}
+
+func (s *ExportService) messageBatch(q ExportQuery, after *MessageCursor) ([]ExportedMessage, error) {
+	where, args := exportFilter(q)
+	conditions := []string{}
+	if where != "" {
+		conditions = append(conditions, where)
+	}
+	if after != nil {
+		conditions = append(conditions, "(m.timestamp > ? OR (m.timestamp = ? AND m.id > ?))")
+		args = append(args, after.Timestamp, after.Timestamp, after.ID)
+	}
+	query := `
+		SELECT
+			m.id, m.session_id, COALESCE(s.project_name, ''), m.timestamp,
+			COALESCE(m.message_role, ''), COALESCE(m.model, ''),
+			COALESCE(m.input_tokens, 0), COALESCE(m.output_tokens, 0),
+			COALESCE(m.cache_creation_input_tokens, 0), COALESCE(m.cache_read_input_tokens, 0),
+			COALESCE(m.provider, 'claude'), m.content
+		FROM messages m
+		LEFT JOIN sessions s ON s.id = m.session_id
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY m.timestamp ASC, m.id ASC LIMIT ?"
+	args = append(args, streamBatchSize)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export messages: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []ExportedMessage
+	for rows.Next() {
+		var message ExportedMessage
+		var content *string
+		if err := rows.Scan(&message.MessageID, &message.SessionID, &message.ProjectName, &message.Timestamp,
+			&message.Role, &message.Model, &message.InputTokens, &message.OutputTokens,
+			&message.CacheCreationInputTokens, &message.CacheReadInputTokens, &message.Provider, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan message export: %w", err)
+		}
+		if message.Model != "" && message.Role == "assistant" {
+			message.Cost = s.pricing.CalculateCost(message.Model, int(message.InputTokens), int(message.OutputTokens),
+				int(message.CacheCreationInputTokens), int(message.CacheReadInputTokens))
+		}
+		if q.Content {
+			message.Content = s.cipher.Open(content)
+		}
+		batch = append(batch, message)
+	}
+	return batch, rows.Err()
+}
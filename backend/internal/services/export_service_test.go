@@ -0,0 +1,118 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func setupExportTest(t *testing.T) (*sql.DB, *ExportService) {
+	t.Helper()
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return db, NewExportService(db)
+}
+
+func insertExportMessage(t *testing.T, db *sql.DB, id, sessionID, role, model string, timestamp time.Time, input, output int) {
+	t.Helper()
+	var modelArg interface{}
+	if model != "" {
+		modelArg = model
+	}
+	_, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, model, content, input_tokens, output_tokens, timestamp)
+		VALUES (?, ?, ?, ?, 'hello', ?, ?, ?)
+	`, id, sessionID, role, modelArg, input, output, timestamp)
+	if err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+}
+
+func TestExportSessionsCountsOnlyTheRange(t *testing.T) {
+	db, exports := setupExportTest(t)
+	jan31 := time.Date(2024, 1, 31, 23, 0, 0, 0, time.UTC)
+	for _, s := range [][2]string{{"s1", "alpha"}, {"s2", "beta"}} {
+		if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES (?, ?, ?, ?)`,
+			s[0], s[1], "/"+s[1], jan31); err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+	}
+	// s1 runs past midnight into February with two models
+	insertExportMessage(t, db, "m1", "s1", "assistant", "claude-sonnet-4-20250514", jan31, 1000, 100)
+	insertExportMessage(t, db, "m2", "s1", "user", "", jan31.Add(90*time.Minute), 0, 0)
+	insertExportMessage(t, db, "m3", "s1", "assistant", "claude-opus-4-20250514", jan31.Add(2*time.Hour), 2000, 200)
+	insertExportMessage(t, db, "m4", "s2", "assistant", "claude-sonnet-4-20250514", jan31.Add(3*time.Hour), 500, 50)
+
+	var sessions []ExportedSession
+	err := exports.StreamSessions(ExportQuery{From: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}, func(s ExportedSession) error {
+		sessions = append(sessions, s)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamSessions failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].SessionID != "s1" {
+		t.Fatalf("Expected s1 then s2, got %+v", sessions)
+	}
+	s1 := sessions[0]
+	if s1.TotalTokens != 2200 || s1.MessageCount != 1 || len(s1.Models) != 1 || s1.Models[0] != "claude-opus-4-20250514" {
+		t.Errorf("Expected only the February opus message of s1, got %+v", s1)
+	}
+	if s1.Cost <= 0 || !s1.FirstMessage.Equal(jan31.Add(90*time.Minute)) {
+		t.Errorf("Unexpected cost or first message: %+v", s1)
+	}
+
+	sessions = nil
+	err = exports.StreamSessions(ExportQuery{Project: "beta"}, func(s ExportedSession) error {
+		sessions = append(sessions, s)
+		return nil
+	})
+	if err != nil || len(sessions) != 1 || sessions[0].ProjectName != "beta" {
+		t.Errorf("Expected only beta, got %+v (%v)", sessions, err)
+	}
+}
+
+func TestExportMessagesStreamsAllBatches(t *testing.T) {
+	db, exports := setupExportTest(t)
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'alpha', '/alpha', '2024-01-01')`); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	total := streamBatchSize + 3
+	for i := 0; i < total; i++ {
+		// Pairs share a timestamp to exercise the cursor's id tie-break
+		insertExportMessage(t, db, fmt.Sprintf("m%04d", i), "s1", "assistant", "claude-sonnet-4-20250514",
+			base.Add(time.Duration(i/2)*time.Second), 10, 1)
+	}
+
+	seen := make(map[string]bool)
+	var withContent int
+	err := exports.StreamMessages(ExportQuery{}, func(m ExportedMessage) error {
+		if seen[m.MessageID] {
+			t.Fatalf("Message %s exported twice", m.MessageID)
+		}
+		seen[m.MessageID] = true
+		if m.ProjectName != "alpha" || m.Cost <= 0 {
+			t.Fatalf("Unexpected message: %+v", m)
+		}
+		if m.Content != nil {
+			withContent++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamMessages failed: %v", err)
+	}
+	if len(seen) != total || withContent != 0 {
+		t.Errorf("Expected %d messages without content, got %d (%d with content)", total, len(seen), withContent)
+	}
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Known feature flags. Experimental subsystems check these before starting.
+const (
+	FeatureScheduler   = "scheduler"
+	FeatureCentralMode = "central_mode"
+	FeaturePprof       = "pprof"
+)
+
+// featureDescriptions lists every flag the server understands. Flags are
+// disabled unless configured or overridden on.
+var featureDescriptions = map[string]string{
+	FeatureScheduler:   "Run log synchronization automatically in the background",
+	FeatureCentralMode: "Accept usage data pushed from remote claudeee agents",
+	FeaturePprof:       "Expose Go profiling endpoints under /debug/pprof",
+}
+
+// ErrUnknownFeature is returned when a flag name is not registered
+var ErrUnknownFeature = errors.New("unknown feature")
+
+// FeatureFlag describes the resolved state of a single flag
+type FeatureFlag struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Enabled     bool       `json:"enabled"`
+	Source      string     `json:"source"` // default, config, override
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// FeatureFlagService resolves feature flags from built-in defaults, startup
+// configuration and per-installation overrides stored in the database
+type FeatureFlagService struct {
+	db         *sql.DB
+	writes     *WriteQueue
+	configured map[string]bool
+
+	mu        sync.RWMutex
+	overrides map[string]bool
+	changed   []func(name string, enabled bool)
+}
+
+// NewFeatureFlagService creates a service. configured holds values from the
+// startup configuration and takes precedence over the built-in defaults.
+func NewFeatureFlagService(db *sql.DB, configured map[string]bool) *FeatureFlagService {
+	if configured == nil {
+		configured = map[string]bool{}
+	}
+	return &FeatureFlagService{
+		db:         db,
+		configured: configured,
+		overrides:  map[string]bool{},
+	}
+}
+
+// SetWriteQueue routes override writes through the shared writer
+func (f *FeatureFlagService) SetWriteQueue(writes *WriteQueue) {
+	f.writes = writes
+}
+
+// OnChange registers fn to be called with the resolved state of a flag after
+// Set or Reset changes its override
+func (f *FeatureFlagService) OnChange(fn func(name string, enabled bool)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.changed = append(f.changed, fn)
+}
+
+// LoadOverrides reads the stored overrides
+func (f *FeatureFlagService) LoadOverrides() error {
+	rows, err := f.db.Query(`SELECT name, enabled FROM feature_flags`)
+	if err != nil {
+		return fmt.Errorf("failed to load feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	overrides := map[string]bool{}
+	for rows.Next() {
+		var name string
+		var enabled bool
+		if err := rows.Scan(&name, &enabled); err != nil {
+			return fmt.Errorf("failed to scan feature flag: %w", err)
+		}
+		overrides[name] = enabled
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate feature flags: %w", err)
+	}
+
+	f.mu.Lock()
+	f.overrides = overrides
+	f.mu.Unlock()
+	return nil
+}
+
+// IsEnabled reports whether a flag is enabled. Unknown flags are always disabled.
+func (f *FeatureFlagService) IsEnabled(name string) bool {
+	if _, ok := featureDescriptions[name]; !ok {
+		return false
+	}
+	enabled, _ := f.resolve(name)
+	return enabled
+}
+
+func (f *FeatureFlagService) resolve(name string) (bool, string) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if enabled, ok := f.overrides[name]; ok {
+		return enabled, "override"
+	}
+	if enabled, ok := f.configured[name]; ok {
+		return enabled, "config"
+	}
+	return false, "default"
+}
+
+// List returns every known flag with its resolved state
+func (f *FeatureFlagService) List() ([]FeatureFlag, error) {
+	updated := map[string]time.Time{}
+	rows, err := f.db.Query(`SELECT name, updated_at FROM feature_flags`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feature flags: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var updatedAt time.Time
+		if err := rows.Scan(&name, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feature flag: %w", err)
+		}
+		updated[name] = updatedAt
+	}
+
+	flags := make([]FeatureFlag, 0, len(featureDescriptions))
+	for name, description := range featureDescriptions {
+		enabled, source := f.resolve(name)
+		flag := FeatureFlag{
+			Name:        name,
+			Description: description,
+			Enabled:     enabled,
+			Source:      source,
+		}
+		if t, ok := updated[name]; ok {
+			flag.UpdatedAt = &t
+		}
+		flags = append(flags, flag)
+	}
+
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags, nil
+}
+
+// Set stores a per-installation override for a flag
+func (f *FeatureFlagService) Set(name string, enabled bool) error {
+	if _, ok := featureDescriptions[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownFeature, name)
+	}
+
+	err := f.writes.Do(func() error {
+		_, err := f.db.Exec(`
+			INSERT OR REPLACE INTO feature_flags (name, enabled, updated_at)
+			VALUES (?, ?, ?)
+		`, name, enabled, time.Now())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update feature flag: %w", err)
+	}
+
+	f.mu.Lock()
+	f.overrides[name] = enabled
+	f.mu.Unlock()
+	f.notify(name)
+	return nil
+}
+
+// Reset removes the stored override so the configured or default value applies
+func (f *FeatureFlagService) Reset(name string) error {
+	if _, ok := featureDescriptions[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownFeature, name)
+	}
+
+	err := f.writes.Do(func() error {
+		_, err := f.db.Exec(`DELETE FROM feature_flags WHERE name = ?`, name)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset feature flag: %w", err)
+	}
+
+	f.mu.Lock()
+	delete(f.overrides, name)
+	f.mu.Unlock()
+	f.notify(name)
+	return nil
+}
+
+// notify passes the resolved state of name to the OnChange listeners
+func (f *FeatureFlagService) notify(name string) {
+	enabled, _ := f.resolve(name)
+	f.mu.RLock()
+	listeners := f.changed
+	f.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(name, enabled)
+	}
+}
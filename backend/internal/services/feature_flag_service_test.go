@@ -0,0 +1,121 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"claudeee-backend/internal/database"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func setupFeatureFlagService(t *testing.T, configured map[string]bool) (*sql.DB, *FeatureFlagService) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	service := NewFeatureFlagService(db, configured)
+	if err := service.LoadOverrides(); err != nil {
+		t.Fatalf("Failed to load overrides: %v", err)
+	}
+	return db, service
+}
+
+func TestFeatureFlags_Defaults(t *testing.T) {
+	db, service := setupFeatureFlagService(t, nil)
+	defer db.Close()
+
+	flags, err := service.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(flags) != len(featureDescriptions) {
+		t.Errorf("Expected %d flags, got %d", len(featureDescriptions), len(flags))
+	}
+	for _, flag := range flags {
+		if flag.Enabled {
+			t.Errorf("Expected flag %s to be disabled by default, got %+v", flag.Name, flag)
+		}
+		if flag.Source != "default" {
+			t.Errorf("Expected source default for %s, got %s", flag.Name, flag.Source)
+		}
+	}
+}
+
+func TestFeatureFlags_ConfigAndOverride(t *testing.T) {
+	db, service := setupFeatureFlagService(t, map[string]bool{FeatureScheduler: true, FeatureCentralMode: false})
+	defer db.Close()
+
+	if !service.IsEnabled(FeatureScheduler) {
+		t.Error("Expected scheduler to be enabled from config")
+	}
+	if service.IsEnabled(FeatureCentralMode) {
+		t.Error("Expected config to disable central mode")
+	}
+
+	if err := service.Set(FeatureScheduler, false); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if service.IsEnabled(FeatureScheduler) {
+		t.Error("Expected override to disable scheduler")
+	}
+
+	// Overrides survive a reload from the database
+	reloaded := NewFeatureFlagService(db, map[string]bool{FeatureScheduler: true})
+	if err := reloaded.LoadOverrides(); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	if reloaded.IsEnabled(FeatureScheduler) {
+		t.Error("Expected persisted override to be loaded")
+	}
+
+	if err := reloaded.Reset(FeatureScheduler); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if !reloaded.IsEnabled(FeatureScheduler) {
+		t.Error("Expected config value after reset")
+	}
+}
+
+func TestFeatureFlags_OnChange(t *testing.T) {
+	db, service := setupFeatureFlagService(t, map[string]bool{FeatureScheduler: true})
+	defer db.Close()
+
+	var changes []string
+	service.OnChange(func(name string, enabled bool) {
+		changes = append(changes, fmt.Sprintf("%s=%v", name, enabled))
+	})
+
+	if err := service.Set(FeatureScheduler, false); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	// Resetting falls back to the configured value
+	if err := service.Reset(FeatureScheduler); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if err := service.Set("does_not_exist", true); err == nil {
+		t.Fatal("Expected unknown feature to fail")
+	}
+
+	expected := []string{"scheduler=false", "scheduler=true"}
+	if fmt.Sprint(changes) != fmt.Sprint(expected) {
+		t.Errorf("Expected changes %v, got %v", expected, changes)
+	}
+}
+
+func TestFeatureFlags_UnknownFeature(t *testing.T) {
+	db, service := setupFeatureFlagService(t, nil)
+	defer db.Close()
+
+	if err := service.Set("does_not_exist", true); !errors.Is(err, ErrUnknownFeature) {
+		t.Errorf("Expected ErrUnknownFeature, got %v", err)
+	}
+	if service.IsEnabled("does_not_exist") {
+		t.Error("Unknown features must never be enabled")
+	}
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package services
+
+import (
+	"os"
+)
+
+// fileIdentity is not tracked on Windows, where os.FileInfo carries no file
+// index; replaced files are still caught by their first line
+func fileIdentity(info os.FileInfo) string {
+	return ""
+}
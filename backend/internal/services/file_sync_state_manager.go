@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"database/sql"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"time"
 
+	"claudeee-backend/internal/logging"
 	"claudeee-backend/internal/models"
 )
 
@@ -19,51 +21,11 @@ func NewFileSyncStateManager(db *sql.DB) *FileSyncStateManager {
 	return &FileSyncStateManager{db: db}
 }
 
-// InitializeSchema creates the file_sync_state table if it doesn't exist
-func (f *FileSyncStateManager) InitializeSchema() error {
-	createTableQuery := `
-		CREATE TABLE IF NOT EXISTS file_sync_state (
-			file_path VARCHAR PRIMARY KEY,
-			last_modified TIMESTAMP NOT NULL,
-			file_size BIGINT NOT NULL,
-			last_processed_line INTEGER DEFAULT 0,
-			processed_until TIMESTAMP,
-			checksum VARCHAR(64),
-			sync_status VARCHAR DEFAULT 'pending',
-			last_sync_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			error_message TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-	`
-	
-	_, err := f.db.Exec(createTableQuery)
-	if err != nil {
-		return fmt.Errorf("failed to create file_sync_state table: %w", err)
-	}
-
-	// Create indexes
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_file_sync_state_path ON file_sync_state (file_path);",
-		"CREATE INDEX IF NOT EXISTS idx_file_sync_state_status ON file_sync_state (sync_status);",
-		"CREATE INDEX IF NOT EXISTS idx_file_sync_state_modified ON file_sync_state (last_modified);",
-	}
-
-	for _, indexQuery := range indexes {
-		_, err := f.db.Exec(indexQuery)
-		if err != nil {
-			return fmt.Errorf("failed to create index: %w", err)
-		}
-	}
-
-	return nil
-}
-
 // GetFileState retrieves the processing state of a file
 func (f *FileSyncStateManager) GetFileState(filePath string) (*models.FileProcessingState, error) {
 	query := `
 		SELECT file_path, last_modified, file_size, last_processed_line, 
-			   processed_until, checksum, sync_status, last_sync_time, 
+			   last_processed_offset, processed_until, checksum, file_id, sync_status, last_sync_time, 
 			   error_message, created_at, updated_at
 		FROM file_sync_state 
 		WHERE file_path = ?
@@ -75,8 +37,10 @@ func (f *FileSyncStateManager) GetFileState(filePath string) (*models.FileProces
 		&state.LastModified,
 		&state.FileSize,
 		&state.LastProcessedLine,
+		&state.LastProcessedOffset,
 		&state.ProcessedUntil,
 		&state.Checksum,
+		&state.FileID,
 		&state.SyncStatus,
 		&state.LastSyncTime,
 		&state.ErrorMessage,
@@ -104,10 +68,10 @@ func (f *FileSyncStateManager) UpdateFileState(state *models.FileProcessingState
 	query := `
 		INSERT OR REPLACE INTO file_sync_state (
 			file_path, last_modified, file_size, last_processed_line,
-			processed_until, checksum, sync_status, last_sync_time,
-			error_message, created_at, updated_at
+			last_processed_offset, processed_until, checksum, file_id, sync_status,
+			last_sync_time, error_message, created_at, updated_at
 		) VALUES (
-			?, ?, ?, ?, ?, ?, ?, ?, ?, 
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 
 			COALESCE((SELECT created_at FROM file_sync_state WHERE file_path = ?), ?),
 			?
 		)
@@ -123,8 +87,10 @@ func (f *FileSyncStateManager) UpdateFileState(state *models.FileProcessingState
 		state.LastModified,
 		state.FileSize,
 		state.LastProcessedLine,
+		state.LastProcessedOffset,
 		state.ProcessedUntil,
 		state.Checksum,
+		state.FileID,
 		state.SyncStatus,
 		state.LastSyncTime,
 		state.ErrorMessage,
@@ -159,14 +125,20 @@ func (f *FileSyncStateManager) NeedsProcessing(filePath string) (bool, *models.F
 		return true, nil, nil
 	}
 	
-	// Check if file has been modified
-	if fileInfo.ModTime().After(lastState.LastModified) {
-		return true, lastState, nil
+	// A different file at the same path, e.g. after rotation, is read from the start
+	if lastState.FileID != nil && *lastState.FileID != "" && *lastState.FileID != fileIdentity(fileInfo) {
+		logging.Component("sync").Info("Log file was replaced; reading it from the start", "file", filePath)
+		return true, nil, nil
+	}
+	
+	// Check if file has been modified; timestamps are stored to the microsecond
+	if fileInfo.ModTime().Truncate(time.Microsecond).After(lastState.LastModified) {
+		return f.checkRewritten(filePath, lastState)
 	}
 	
 	// Check if file size has changed
 	if fileInfo.Size() != lastState.FileSize {
-		return true, lastState, nil
+		return f.checkRewritten(filePath, lastState)
 	}
 	
 	// Check if last processing failed
@@ -177,6 +149,46 @@ func (f *FileSyncStateManager) NeedsProcessing(filePath string) (bool, *models.F
 	return false, lastState, nil
 }
 
+// checkRewritten reports a changed file for processing, dropping its state
+// when its first line differs, i.e. it was rewritten rather than appended to
+func (f *FileSyncStateManager) checkRewritten(filePath string, lastState *models.FileProcessingState) (bool, *models.FileProcessingState, error) {
+	if lastState.Checksum == nil || *lastState.Checksum == "" {
+		return true, lastState, nil
+	}
+	checksum, err := firstLineChecksum(filePath)
+	if err != nil {
+		return false, nil, err
+	}
+	if checksum != *lastState.Checksum {
+		logging.Component("sync").Info("Log file was rewritten; reading it from the start", "file", filePath)
+		return true, nil, nil
+	}
+	return true, lastState, nil
+}
+
+// firstLineChecksum returns the SHA-256 of the first line of a file, at most
+// its first 4 KiB; files only ever have lines appended, so it identifies a
+// file's content. It is empty while the first line is still being written.
+func firstLineChecksum(filePath string) (string, error) {
+	file, err := openLogFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for checksum: %w", err)
+	}
+	defer file.Close()
+
+	head := make([]byte, 4096)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("failed to read file for checksum: %w", err)
+	}
+	if i := bytes.IndexByte(head[:n], '\n'); i >= 0 {
+		head = head[:i]
+	} else if n < len(head) {
+		return "", nil
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(head)), nil
+}
+
 // CalculateFileChecksum calculates the SHA256 checksum of a file
 func (f *FileSyncStateManager) CalculateFileChecksum(filePath string) (string, error) {
 	file, err := os.Open(filePath)
@@ -197,7 +209,7 @@ func (f *FileSyncStateManager) CalculateFileChecksum(filePath string) (string, e
 func (f *FileSyncStateManager) GetAllFileStates() ([]models.FileProcessingState, error) {
 	query := `
 		SELECT file_path, last_modified, file_size, last_processed_line,
-			   processed_until, checksum, sync_status, last_sync_time,
+			   last_processed_offset, processed_until, checksum, file_id, sync_status, last_sync_time,
 			   error_message, created_at, updated_at
 		FROM file_sync_state
 		ORDER BY last_sync_time DESC
@@ -217,8 +229,10 @@ func (f *FileSyncStateManager) GetAllFileStates() ([]models.FileProcessingState,
 			&state.LastModified,
 			&state.FileSize,
 			&state.LastProcessedLine,
+			&state.LastProcessedOffset,
 			&state.ProcessedUntil,
 			&state.Checksum,
+			&state.FileID,
 			&state.SyncStatus,
 			&state.LastSyncTime,
 			&state.ErrorMessage,
@@ -234,9 +248,8 @@ func (f *FileSyncStateManager) GetAllFileStates() ([]models.FileProcessingState,
 	return states, nil
 }
 
-// CleanupOldStates removes state records for files that no longer exist and resets stuck processing states
+// CleanupOldStates resets processing states left stuck by an interrupted sync
 func (f *FileSyncStateManager) CleanupOldStates() error {
-	// First, reset any stuck "processing" states that are older than 5 minutes
 	fiveMinutesAgo := time.Now().Add(-5 * time.Minute)
 	_, err := f.db.Exec(`
 		UPDATE file_sync_state 
@@ -244,27 +257,54 @@ func (f *FileSyncStateManager) CleanupOldStates() error {
 		WHERE sync_status = 'processing' AND last_sync_time < ?
 	`, fiveMinutesAgo)
 	if err != nil {
-		fmt.Printf("Warning: failed to reset stuck processing states: %v\n", err)
+		logging.Component("sync").Warn("Failed to reset stuck processing states", "err", err)
 	}
+	return nil
+}
 
+// ReconcileFiles matches the stored states to the files on disk. The state of
+// a file that no longer exists moves to a discovered file with the same
+// identity, e.g. after a rename, and is removed otherwise. It returns the
+// moved paths, old to new, and the removed ones.
+func (f *FileSyncStateManager) ReconcileFiles(files []models.FileInfo) (map[string]string, []string, error) {
 	states, err := f.GetAllFileStates()
 	if err != nil {
-		return fmt.Errorf("failed to get file states for cleanup: %w", err)
+		return nil, nil, fmt.Errorf("failed to get file states for cleanup: %w", err)
 	}
-	
+
+	tracked := make(map[string]bool, len(states))
+	for _, state := range states {
+		tracked[state.FilePath] = true
+	}
+	untracked := make(map[string]string)
+	for _, file := range files {
+		if file.ID != "" && !tracked[file.Path] {
+			untracked[file.ID] = file.Path
+		}
+	}
+
+	moved := make(map[string]string)
+	var removed []string
 	for _, state := range states {
-		if _, err := os.Stat(state.FilePath); os.IsNotExist(err) {
-			// File no longer exists, remove from state table
-			_, err := f.db.Exec("DELETE FROM file_sync_state WHERE file_path = ?", state.FilePath)
-			if err != nil {
-				fmt.Printf("Warning: failed to remove old state for %s: %v\n", state.FilePath, err)
-			} else {
-				fmt.Printf("Removed old state for deleted file: %s\n", state.FilePath)
+		if _, err := os.Stat(state.FilePath); !os.IsNotExist(err) {
+			continue
+		}
+		if state.FileID != nil {
+			if path, ok := untracked[*state.FileID]; ok {
+				if _, err := f.db.Exec(`UPDATE file_sync_state SET file_path = ? WHERE file_path = ?`, path, state.FilePath); err != nil {
+					return moved, removed, fmt.Errorf("failed to move state of %s: %w", state.FilePath, err)
+				}
+				delete(untracked, *state.FileID)
+				moved[state.FilePath] = path
+				continue
 			}
 		}
+		if err := f.ResetFileState(state.FilePath); err != nil {
+			return moved, removed, err
+		}
+		removed = append(removed, state.FilePath)
 	}
-	
-	return nil
+	return moved, removed, nil
 }
 
 // ResetFileState resets the processing state of a file (forces reprocessing)
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"claudeee-backend/internal/database"
 	"claudeee-backend/internal/models"
 	_ "github.com/marcboeker/go-duckdb"
 )
@@ -16,13 +17,11 @@ func setupTestDBForStateManager(t *testing.T) (*sql.DB, *FileSyncStateManager) {
 		t.Fatalf("Failed to create test database: %v", err)
 	}
 
-	stateManager := NewFileSyncStateManager(db)
-	err = stateManager.InitializeSchema()
-	if err != nil {
-		t.Fatalf("Failed to initialize schema: %v", err)
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
 	}
 
-	return db, stateManager
+	return db, NewFileSyncStateManager(db)
 }
 
 func TestNewFileSyncStateManager(t *testing.T) {
@@ -37,22 +36,13 @@ func TestNewFileSyncStateManager(t *testing.T) {
 	}
 }
 
-func TestInitializeSchema(t *testing.T) {
-	db, err := sql.Open("duckdb", ":memory:")
-	if err != nil {
-		t.Fatalf("Failed to create test database: %v", err)
-	}
+func TestFileSyncStateSchema(t *testing.T) {
+	db, _ := setupTestDBForStateManager(t)
 	defer db.Close()
 
-	stateManager := NewFileSyncStateManager(db)
-	err = stateManager.InitializeSchema()
-	if err != nil {
-		t.Fatalf("Failed to initialize schema: %v", err)
-	}
-
-	// Verify table exists
+	// The migrations create the table with every column the manager reads
 	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM file_sync_state").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM file_sync_state WHERE last_processed_offset = 0 OR file_id IS NULL").Scan(&count)
 	if err != nil {
 		t.Fatalf("Failed to query file_sync_state table: %v", err)
 	}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// DefaultForecastLookback is how far back the current burn rate is measured
+const DefaultForecastLookback = 30 * time.Minute
+
+// Forecast projects when the plan limit is reached at the current pace
+type Forecast struct {
+	UsageLimit      int       `json:"usage_limit"`
+	UsedTokens      int       `json:"used_tokens"`
+	RemainingTokens int       `json:"remaining_tokens"`
+	WindowStart     time.Time `json:"window_start"`
+	WindowEnd       time.Time `json:"window_end"`
+	// LookbackMinutes is the span the current rate was measured over; it is
+	// shorter than the lookback early in a window
+	LookbackMinutes float64 `json:"lookback_minutes"`
+	// TokensPerMinute is the burn rate over the lookback
+	TokensPerMinute float64 `json:"tokens_per_minute"`
+	// AverageTokensPerMinute is the burn rate since the window started
+	AverageTokensPerMinute float64 `json:"average_tokens_per_minute"`
+	// ProjectedWindowTokens is the usage expected when the window resets
+	ProjectedWindowTokens int `json:"projected_window_tokens"`
+	// The estimates are nil while nothing has been used in the lookback
+	MinutesToLimit *float64   `json:"minutes_to_limit"`
+	LimitAt        *time.Time `json:"limit_at"`
+	// LimitBeforeReset reports whether the limit is reached before the window ends
+	LimitBeforeReset bool `json:"limit_before_reset"`
+}
+
+// ForecastService projects the active window's usage from its recent burn rate
+type ForecastService struct {
+	db       *sql.DB
+	lookback time.Duration
+}
+
+func NewForecastService(db *sql.DB) *ForecastService {
+	return &ForecastService{db: db, lookback: DefaultForecastLookback}
+}
+
+// SetLookback changes how far back the current burn rate is measured
+func (s *ForecastService) SetLookback(lookback time.Duration) {
+	s.lookback = lookback
+}
+
+// Forecast projects usage, the active window's token usage, against limit.
+// The current rate counts the tokens of the last lookback, since a pace that
+// changed an hour ago says little about the next hour.
+func (s *ForecastService) Forecast(usage *models.TokenUsage, limit int, now time.Time) (*Forecast, error) {
+	f := &Forecast{
+		UsageLimit:            limit,
+		UsedTokens:            usage.TotalTokens,
+		WindowStart:           usage.WindowStart,
+		WindowEnd:             usage.WindowEnd,
+		ProjectedWindowTokens: usage.TotalTokens,
+	}
+	f.RemainingTokens = limit - usage.TotalTokens
+	if f.RemainingTokens <= 0 {
+		f.RemainingTokens = 0
+		minutes := 0.0
+		f.MinutesToLimit = &minutes
+		f.LimitAt = &now
+		f.LimitBeforeReset = true
+	}
+
+	elapsed := now.Sub(usage.WindowStart)
+	if usage.TotalTokens == 0 || elapsed <= 0 {
+		return f, nil
+	}
+	f.AverageTokensPerMinute = roundToDecimals(float64(usage.TotalTokens)/elapsed.Minutes(), 2)
+
+	since := now.Add(-s.lookback)
+	if since.Before(usage.WindowStart) {
+		since = usage.WindowStart
+	}
+	var recent int64
+	// Window totals count Claude input and output tokens, and so does the rate
+	err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(input_tokens + output_tokens), 0)
+		FROM messages
+		WHERE timestamp >= ? AND timestamp < ? AND COALESCE(provider, 'claude') = 'claude'
+	`, since, now).Scan(&recent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent token usage: %w", err)
+	}
+	lookback := now.Sub(since)
+	f.LookbackMinutes = roundToDecimals(lookback.Minutes(), 1)
+	rate := float64(recent) / lookback.Minutes()
+	f.TokensPerMinute = roundToDecimals(rate, 2)
+
+	if remaining := usage.WindowEnd.Sub(now); remaining > 0 {
+		f.ProjectedWindowTokens = usage.TotalTokens + int(rate*remaining.Minutes())
+	}
+	if f.RemainingTokens == 0 || rate <= 0 {
+		return f, nil
+	}
+	minutes := float64(f.RemainingTokens) / rate
+	limitAt := now.Add(time.Duration(minutes * float64(time.Minute))).Truncate(time.Second)
+	minutes = roundToDecimals(minutes, 1)
+	f.MinutesToLimit = &minutes
+	f.LimitAt = &limitAt
+	f.LimitBeforeReset = limitAt.Before(usage.WindowEnd)
+	return f, nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/models"
+)
+
+func TestForecastUsesRecentBurnRate(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// 2000 tokens early in the window, then 3000 in the last half hour; the
+	// Codex message does not count towards the Claude limit
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES
+			('s1', 'api', '/work/api', '2024-03-01 09:00:00');
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp) VALUES
+			('a1', 's1', 'assistant', 'claude-sonnet-4-20250514', 1500, 500, '2024-03-01 09:05:00'),
+			('a2', 's1', 'assistant', 'claude-sonnet-4-20250514', 2000, 1000, '2024-03-01 10:45:00');
+		INSERT INTO messages (id, session_id, message_role, model, provider, input_tokens, output_tokens, timestamp) VALUES
+			('c1', 's1', 'assistant', 'gpt-5-codex', 'codex', 9000, 0, '2024-03-01 10:40:00');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := start.Add(time.Hour + 50*time.Minute)
+	usage := &models.TokenUsage{TotalTokens: 5000, WindowStart: start, WindowEnd: start.Add(5 * time.Hour)}
+	forecasts := NewForecastService(db)
+
+	f, err := forecasts.Forecast(usage, 8000, now)
+	if err != nil {
+		t.Fatalf("Failed to forecast: %v", err)
+	}
+	if f.TokensPerMinute != 100 || f.LookbackMinutes != 30 {
+		t.Errorf("Expected 100 tokens per minute over 30 minutes, got %+v", f)
+	}
+	if f.AverageTokensPerMinute != 45.45 {
+		t.Errorf("Expected an average of 45.45 tokens per minute, got %f", f.AverageTokensPerMinute)
+	}
+	// 3000 remaining tokens at 100 per minute
+	if f.MinutesToLimit == nil || *f.MinutesToLimit != 30 || !f.LimitAt.Equal(now.Add(30*time.Minute)) || !f.LimitBeforeReset {
+		t.Errorf("Expected the limit in 30 minutes, got %+v", f)
+	}
+	if f.ProjectedWindowTokens != 5000+100*190 {
+		t.Errorf("Expected %d tokens at reset, got %d", 5000+100*190, f.ProjectedWindowTokens)
+	}
+
+	// Nothing in the last half hour: no estimate
+	f, err = forecasts.Forecast(usage, 8000, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to forecast: %v", err)
+	}
+	if f.TokensPerMinute != 0 || f.MinutesToLimit != nil || f.LimitBeforeReset {
+		t.Errorf("Expected no estimate without recent usage, got %+v", f)
+	}
+
+	// A limit already reached
+	f, err = forecasts.Forecast(usage, 4000, now)
+	if err != nil {
+		t.Fatalf("Failed to forecast: %v", err)
+	}
+	if f.RemainingTokens != 0 || f.MinutesToLimit == nil || *f.MinutesToLimit != 0 || !f.LimitBeforeReset {
+		t.Errorf("Expected the limit reached now, got %+v", f)
+	}
+}
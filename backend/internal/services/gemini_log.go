@@ -0,0 +1,256 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+)
+
+// geminiLogNames are the telemetry files looked for in Gemini log
+// directories: the CLI's own outfile and the local OpenTelemetry collector's
+var geminiLogNames = map[string]bool{"telemetry.log": true, "collector.log": true}
+
+// geminiMaxRecord bounds the lines buffered for one telemetry record
+const geminiMaxRecord = 4 << 20
+
+// Gemini CLI telemetry events synced as messages
+const (
+	geminiUserPrompt  = "gemini_cli.user_prompt"
+	geminiAPIResponse = "gemini_cli.api_response"
+)
+
+// geminiLogSource reads the OpenTelemetry logs Gemini CLI writes when its
+// telemetry is enabled with a file target. Paths are telemetry files or
+// directories searched for them.
+type geminiLogSource struct {
+	paths []string
+}
+
+func (s geminiLogSource) Provider() string { return ProviderGemini }
+
+// Discover lists the telemetry files; paths that do not exist are skipped
+func (s geminiLogSource) Discover() ([]models.FileInfo, error) {
+	logger := logging.Component("sync")
+	var files []models.FileInfo
+	add := func(path string, info fs.FileInfo) {
+		files = append(files, models.FileInfo{
+			Path:    path,
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+			ID:      fileIdentity(info),
+		})
+	}
+	for _, path := range s.paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			logger.Debug("Gemini telemetry not found", "path", path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(path, info)
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || !geminiLogNames[entry.Name()] {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				logger.Warn("Failed to stat log file", "file", file, "err", err)
+				return nil
+			}
+			add(file, info)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (s geminiLogSource) NewDecoder(path string) LogDecoder {
+	return &geminiDecoder{cwd: geminiProjectDir(path)}
+}
+
+// geminiProjectDir returns the directory Gemini ran in for a telemetry file:
+// the parent of the .gemini directory holding it, else the file's directory
+func geminiProjectDir(path string) string {
+	for dir := filepath.Dir(path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == ".gemini" {
+			return filepath.Dir(dir)
+		}
+	}
+	return filepath.Dir(path)
+}
+
+// geminiDecoder turns telemetry records into log entries: a user entry per
+// prompt and an assistant entry with the usage of each API response. Records
+// are JSON documents, either log records of the CLI's file exporter, which
+// span several lines, or OTLP JSON batches of the collector, one per line.
+type geminiDecoder struct {
+	cwd string
+	// pending holds the lines of a record not yet complete
+	pending []byte
+}
+
+func (d *geminiDecoder) Observe(line []byte) {
+	d.Decode(line)
+}
+
+func (d *geminiDecoder) Decode(line []byte) ([]models.LogEntry, error) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, nil
+	}
+	if len(d.pending) == 0 && line[0] != '{' {
+		return nil, errors.New("not a telemetry record")
+	}
+	d.pending = append(append(d.pending, line...), '\n')
+	// A record ends with a closing brace, on its own line when indented
+	if (len(d.pending) > len(line)+1 && line[0] != '}') || !json.Valid(d.pending) {
+		if len(d.pending) > geminiMaxRecord {
+			d.pending = nil
+			return nil, errors.New("telemetry record too large")
+		}
+		return nil, nil
+	}
+	record := d.pending
+	d.pending = nil
+
+	var doc struct {
+		Attributes   map[string]interface{} `json:"attributes"`
+		ResourceLogs []struct {
+			ScopeLogs []struct {
+				LogRecords []struct {
+					Attributes []otlpAttribute `json:"attributes"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(record, &doc); err != nil {
+		return nil, err
+	}
+	var entries []models.LogEntry
+	if doc.Attributes != nil {
+		entries = d.appendEntry(entries, doc.Attributes)
+	}
+	for _, resource := range doc.ResourceLogs {
+		for _, scope := range resource.ScopeLogs {
+			for _, log := range scope.LogRecords {
+				attributes := make(map[string]interface{}, len(log.Attributes))
+				for _, attribute := range log.Attributes {
+					attributes[attribute.Key] = attribute.value()
+				}
+				entries = d.appendEntry(entries, attributes)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// appendEntry appends the entry of a prompt or API response event
+func (d *geminiDecoder) appendEntry(entries []models.LogEntry, attributes map[string]interface{}) []models.LogEntry {
+	event := attributeString(attributes, "event.name")
+	if event != geminiUserPrompt && event != geminiAPIResponse {
+		return entries
+	}
+	sessionID := attributeString(attributes, "session.id")
+	timestamp, err := time.Parse(time.RFC3339Nano, attributeString(attributes, "event.timestamp"))
+	if sessionID == "" || err != nil {
+		return entries
+	}
+
+	messageType := "message"
+	entry := models.LogEntry{
+		UserType:  "external",
+		Cwd:       d.cwd,
+		SessionID: sessionID,
+		Message:   models.LogMessage{Type: &messageType},
+		Timestamp: timestamp,
+		Provider:  ProviderGemini,
+	}
+	if event == geminiUserPrompt {
+		entry.Type = "user"
+		entry.Message.Role = "user"
+		// The prompt is only logged when the CLI's log_prompts setting is on
+		entry.Message.Content = attributeString(attributes, "prompt")
+		return append(entries, entry)
+	}
+
+	entry.Type = "assistant"
+	entry.Message.Role = "assistant"
+	entry.Message.Content = attributeString(attributes, "response_text")
+	if model := attributeString(attributes, "model"); model != "" {
+		entry.Message.Model = &model
+	}
+	// Prompt tokens include the cached ones; thoughts are billed as output
+	cached := attributeInt(attributes, "cached_content_token_count")
+	input := attributeInt(attributes, "input_token_count") - cached + attributeInt(attributes, "tool_token_count")
+	if input < 0 {
+		input = 0
+	}
+	entry.Message.Usage = &models.Usage{
+		InputTokens:          input,
+		CacheReadInputTokens: cached,
+		OutputTokens:         attributeInt(attributes, "output_token_count") + attributeInt(attributes, "thoughts_token_count"),
+	}
+	return append(entries, entry)
+}
+
+// otlpAttribute is an attribute in OTLP JSON, its value typed by field
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string         `json:"stringValue"`
+		IntValue    json.RawMessage `json:"intValue"`
+		DoubleValue *float64        `json:"doubleValue"`
+		BoolValue   *bool           `json:"boolValue"`
+	} `json:"value"`
+}
+
+func (v otlpAttribute) value() interface{} {
+	switch {
+	case v.Value.StringValue != nil:
+		return *v.Value.StringValue
+	case v.Value.IntValue != nil:
+		// 64-bit integers are encoded as strings
+		return strings.Trim(string(v.Value.IntValue), `"`)
+	case v.Value.DoubleValue != nil:
+		return *v.Value.DoubleValue
+	case v.Value.BoolValue != nil:
+		return *v.Value.BoolValue
+	}
+	return nil
+}
+
+func attributeString(attributes map[string]interface{}, key string) string {
+	value, _ := attributes[key].(string)
+	return value
+}
+
+func attributeInt(attributes map[string]interface{}, key string) int {
+	switch value := attributes[key].(type) {
+	case float64:
+		return int(value)
+	case string:
+		n, _ := strconv.Atoi(value)
+		return n
+	}
+	return 0
+}
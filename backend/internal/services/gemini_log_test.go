@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// geminiRecord returns a log record as Gemini CLI's file exporter writes it,
+// indented over several lines
+func geminiRecord(t *testing.T, attributes map[string]interface{}) string {
+	t.Helper()
+	record, err := json.MarshalIndent(map[string]interface{}{
+		"hrTime":     []int{1757514230, 0},
+		"attributes": attributes,
+		"_body":      "API response",
+	}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(record) + "\n"
+}
+
+func geminiTelemetry(t *testing.T) string {
+	return geminiRecord(t, map[string]interface{}{
+		"session.id":      "gem-1",
+		"event.name":      "gemini_cli.user_prompt",
+		"event.timestamp": "2025-09-10T14:23:45.000Z",
+		"prompt":          "Explain the router",
+		"prompt_length":   18,
+	}) + geminiRecord(t, map[string]interface{}{
+		"session.id":      "gem-1",
+		"event.name":      "gemini_cli.config",
+		"event.timestamp": "2025-09-10T14:23:45.500Z",
+	}) + geminiRecord(t, map[string]interface{}{
+		"session.id":                 "gem-1",
+		"event.name":                 "gemini_cli.api_response",
+		"event.timestamp":            "2025-09-10T14:23:50.123Z",
+		"model":                      "gemini-2.5-pro",
+		"input_token_count":          1000000,
+		"cached_content_token_count": 400000,
+		"tool_token_count":           0,
+		"output_token_count":         60000,
+		"thoughts_token_count":       40000,
+		"response_text":              "The router maps paths to handlers.",
+	})
+}
+
+func TestGeminiDecoder(t *testing.T) {
+	decoder := geminiLogSource{}.NewDecoder("/work/app/.gemini/telemetry.log")
+
+	var entries int
+	for _, line := range strings.Split(geminiTelemetry(t), "\n") {
+		decoded, err := decoder.Decode([]byte(line))
+		if err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		for _, entry := range decoded {
+			entries++
+			if entry.SessionID != "gem-1" || entry.Cwd != "/work/app" || entry.Provider != ProviderGemini {
+				t.Errorf("Unexpected entry %+v", entry)
+			}
+			if usage := entry.Message.Usage; usage != nil {
+				if usage.InputTokens != 600000 || usage.CacheReadInputTokens != 400000 || usage.OutputTokens != 100000 {
+					t.Errorf("Unexpected usage %+v", usage)
+				}
+			}
+		}
+	}
+	// The config event is no message
+	if entries != 2 {
+		t.Errorf("Expected 2 entries, got %d", entries)
+	}
+
+	// The collector writes OTLP JSON batches, one per line
+	batch := `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{"attributes":[` +
+		`{"key":"session.id","value":{"stringValue":"gem-2"}},` +
+		`{"key":"event.name","value":{"stringValue":"gemini_cli.api_response"}},` +
+		`{"key":"event.timestamp","value":{"stringValue":"2025-09-10T15:00:00Z"}},` +
+		`{"key":"model","value":{"stringValue":"gemini-2.5-flash"}},` +
+		`{"key":"input_token_count","value":{"intValue":"1200"}},` +
+		`{"key":"output_token_count","value":{"intValue":34}}]}]}]}]}`
+	decoded, err := decoder.Decode([]byte(batch))
+	if err != nil || len(decoded) != 1 {
+		t.Fatalf("Expected one entry from an OTLP batch, got %v (%v)", decoded, err)
+	}
+	if usage := decoded[0].Message.Usage; decoded[0].SessionID != "gem-2" || usage.InputTokens != 1200 || usage.OutputTokens != 34 {
+		t.Errorf("Unexpected OTLP entry %+v", decoded[0])
+	}
+
+	if _, err := decoder.Decode([]byte(`"text"`)); err == nil {
+		t.Error("Expected an error for a line outside a record")
+	}
+}
+
+func TestSyncGeminiTelemetry(t *testing.T) {
+	claudeRoot := t.TempDir()
+	project := filepath.Join(t.TempDir(), "app")
+	if err := os.MkdirAll(filepath.Join(project, ".gemini"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	telemetry := geminiTelemetry(t)
+	logPath := filepath.Join(project, ".gemini", "telemetry.log")
+	// The first pass ends in the middle of the API response record
+	cut := strings.Index(telemetry, `"model"`)
+	if err := os.WriteFile(logPath, []byte(telemetry[:cut]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, diffSync := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+	diffSync.SetLogSources(LogSourceConfig{Roots: []string{claudeRoot}, GeminiLogs: []string{filepath.Dir(project)}})
+	if _, err := diffSync.SyncAllLogs(context.Background()); err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+	if err := os.WriteFile(logPath, []byte(telemetry), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diffSync.SyncAllLogs(context.Background()); err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+
+	var provider, projectName string
+	if err := db.QueryRow(`SELECT provider, project_name FROM sessions WHERE id = 'gem-1'`).Scan(&provider, &projectName); err != nil {
+		t.Fatalf("Failed to read session: %v", err)
+	}
+	if provider != ProviderGemini || projectName != "app" {
+		t.Errorf("Expected a gemini session of project app, got %s, %s", provider, projectName)
+	}
+	var messages, windowed, output int
+	err := db.QueryRow(`SELECT COUNT(*), COUNT(session_window_id), COALESCE(SUM(output_tokens), 0) FROM messages WHERE session_id = 'gem-1'`).Scan(&messages, &windowed, &output)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	// The record split across passes is read whole on the second
+	if messages != 2 || windowed != 0 || output != 100000 {
+		t.Errorf("Expected 2 unwindowed messages with 100000 output tokens, got %d, %d in windows, %d", messages, windowed, output)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, provider, timestamp) VALUES
+			('c1', 'gem-1', 'assistant', 'claude-sonnet-4-20250514', 1000000, 0, 'claude', '2025-09-10 15:00:00')`)
+	if err != nil {
+		t.Fatalf("Failed to insert claude message: %v", err)
+	}
+	report, err := NewModelUsageService(db).GetModelUsage(ModelUsageQuery{})
+	if err != nil {
+		t.Fatalf("GetModelUsage failed: %v", err)
+	}
+	costs := make(map[string]float64)
+	for _, usage := range report.Providers {
+		costs[usage.Model] = usage.Cost
+	}
+	// $0.75 input, $0.05 cached and $1 output at gemini-2.5-pro prices
+	if len(costs) != 2 || costs[ProviderGemini] != 1.8 || costs[ProviderClaude] != 3 {
+		t.Errorf("Unexpected provider costs %v", costs)
+	}
+}
+
+func TestProviderPricingFallback(t *testing.T) {
+	pc := NewPricingCalculator()
+	if cost := pc.CalculateCost("gemini-2.5-flash-preview-09-2025", 1_000_000, 1_000_000, 0, 0); cost != 2.8 {
+		t.Errorf("Expected gemini-2.5-flash prices, got %v", cost)
+	}
+	if cost := pc.CalculateCost("gemini-3-pro", 1_000_000, 0, 0, 0); cost != 1.25 {
+		t.Errorf("Expected gemini-2.5-pro prices, got %v", cost)
+	}
+	if cost := pc.CalculateCost("gpt-5-codex-mini", 1_000_000, 0, 0, 0); cost != 0.25 {
+		t.Errorf("Expected gpt-5-mini prices, got %v", cost)
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+// ErrUnknownGrafanaTarget is returned for a query target GrafanaMetrics does
+// not list
+var ErrUnknownGrafanaTarget = errors.New("unknown target")
+
+// grafanaBucket is the resolution usage is read at; coarser intervals sum
+// whole buckets
+const grafanaBucket = 15 * time.Minute
+
+// grafanaMetrics are the values a target can plot
+var grafanaMetrics = []string{
+	"tokens",
+	"input_tokens",
+	"output_tokens",
+	"cache_creation_input_tokens",
+	"cache_read_input_tokens",
+	"cost",
+	"messages",
+}
+
+// grafanaGroupings split a metric into one series per model or project
+var grafanaGroupings = []string{"model", "project"}
+
+// Annotation queries
+const (
+	// GrafanaAnnotationLimits marks the usage and rate limits Claude reported
+	GrafanaAnnotationLimits = "limits"
+	// GrafanaAnnotationWindows marks usage windows from start to reset
+	GrafanaAnnotationWindows = "windows"
+)
+
+// GrafanaSeries is a time series in the JSON datasource format: each
+// datapoint is a value and a Unix time in milliseconds
+type GrafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// GrafanaAnnotation is an event shown on Grafana graphs; times are Unix
+// milliseconds
+type GrafanaAnnotation struct {
+	Time    int64    `json:"time"`
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Title   string   `json:"title"`
+	Text    string   `json:"text"`
+	Tags    []string `json:"tags"`
+}
+
+// GrafanaService answers the queries of Grafana's JSON datasource from the
+// stored messages
+type GrafanaService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+}
+
+func NewGrafanaService(db *sql.DB) *GrafanaService {
+	return &GrafanaService{db: db, pricing: NewPricingCalculator()}
+}
+
+// GrafanaMetrics lists the targets that can be queried: every metric in
+// total and split by model or by project, e.g. "cost by model"
+func GrafanaMetrics() []string {
+	targets := make([]string, 0, len(grafanaMetrics)*(len(grafanaGroupings)+1))
+	for _, metric := range grafanaMetrics {
+		targets = append(targets, metric)
+		for _, grouping := range grafanaGroupings {
+			targets = append(targets, metric+" by "+grouping)
+		}
+	}
+	return targets
+}
+
+// parseGrafanaTarget splits a target into its metric and grouping
+func parseGrafanaTarget(target string) (metric, grouping string, err error) {
+	metric, grouping, _ = strings.Cut(strings.TrimSpace(target), " by ")
+	for _, known := range grafanaMetrics {
+		if metric != known {
+			continue
+		}
+		if grouping == "" {
+			return metric, "", nil
+		}
+		for _, g := range grafanaGroupings {
+			if grouping == g {
+				return metric, grouping, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("%w %q", ErrUnknownGrafanaTarget, target)
+}
+
+// GrafanaInterval is the bucket width for plotting from..to in at most
+// maxPoints points at about interval apart: a multiple of 15 minutes
+func GrafanaInterval(from, to time.Time, interval time.Duration, maxPoints int) time.Duration {
+	if interval < grafanaBucket {
+		interval = grafanaBucket
+	}
+	if maxPoints > 0 {
+		if minimum := to.Sub(from) / time.Duration(maxPoints); interval < minimum {
+			interval = minimum
+		}
+	}
+	return (interval + grafanaBucket - 1) / grafanaBucket * grafanaBucket
+}
+
+// grafanaUsage is the usage of one quarter hour, project and model
+type grafanaUsage struct {
+	bucket         time.Time
+	project, model string
+	messages       int64
+	input, output  int
+	cacheCreation  int
+	cacheRead      int
+}
+
+// Query returns the series of targets over from..to in buckets of interval,
+// limited to user's sessions when user is set. Buckets without usage are 0.
+func (s *GrafanaService) Query(targets []string, user string, from, to time.Time, interval time.Duration) ([]GrafanaSeries, error) {
+	type parsedTarget struct{ name, metric, grouping string }
+	parsed := make([]parsedTarget, 0, len(targets))
+	for _, target := range targets {
+		metric, grouping, err := parseGrafanaTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, parsedTarget{name: target, metric: metric, grouping: grouping})
+	}
+	if len(parsed) == 0 {
+		return []GrafanaSeries{}, nil
+	}
+
+	usage, err := s.usage(user, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	start := from.UTC().Truncate(interval)
+	n := int(to.Sub(start)/interval) + 1
+	series := []GrafanaSeries{}
+	for _, target := range parsed {
+		values := make(map[string][]float64)
+		var keys []string
+		for _, u := range usage {
+			key := ""
+			switch target.grouping {
+			case "model":
+				key = u.model
+			case "project":
+				key = u.project
+			}
+			points, ok := values[key]
+			if !ok {
+				points = make([]float64, n)
+				values[key] = points
+				keys = append(keys, key)
+			}
+			index := int(u.bucket.Sub(start) / interval)
+			if index >= 0 && index < n {
+				points[index] += s.metricValue(target.metric, u)
+			}
+		}
+		if target.grouping == "" && len(keys) == 0 {
+			values[""] = make([]float64, n)
+			keys = append(keys, "")
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			name := target.name
+			if target.grouping != "" {
+				name = target.metric + " " + key
+			}
+			datapoints := make([][2]float64, n)
+			for i, value := range values[key] {
+				if target.metric == "cost" {
+					value = roundToDecimals(value, 6)
+				}
+				datapoints[i] = [2]float64{value, float64(start.Add(time.Duration(i) * interval).UnixMilli())}
+			}
+			series = append(series, GrafanaSeries{Target: name, Datapoints: datapoints})
+		}
+	}
+	return series, nil
+}
+
+func (s *GrafanaService) metricValue(metric string, u grafanaUsage) float64 {
+	switch metric {
+	case "tokens":
+		return float64(u.input + u.output)
+	case "input_tokens":
+		return float64(u.input)
+	case "output_tokens":
+		return float64(u.output)
+	case "cache_creation_input_tokens":
+		return float64(u.cacheCreation)
+	case "cache_read_input_tokens":
+		return float64(u.cacheRead)
+	case "cost":
+		if u.model == "" {
+			return 0
+		}
+		return s.pricing.CalculateCost(u.model, u.input, u.output, u.cacheCreation, u.cacheRead)
+	case "messages":
+		return float64(u.messages)
+	}
+	return 0
+}
+
+// usage reads assistant message totals per quarter hour, project and model
+func (s *GrafanaService) usage(user string, from, to time.Time) ([]grafanaUsage, error) {
+	where, args := rangeFilter("", user, from.UTC(), to.UTC())
+	rows, err := s.db.Query(`
+		SELECT
+			`+database.QuarterHour("m.timestamp")+`,
+			s.project_name,
+			COALESCE(m.model, ''),
+			COUNT(*),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+` AND m.message_role = 'assistant'
+		GROUP BY 1, 2, 3
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []grafanaUsage
+	for rows.Next() {
+		var u grafanaUsage
+		if err := rows.Scan(&u.bucket, &u.project, &u.model, &u.messages, &u.input, &u.output, &u.cacheCreation, &u.cacheRead); err != nil {
+			return nil, fmt.Errorf("failed to scan usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// Annotations returns the events of an annotation query between from and
+// to: GrafanaAnnotationLimits, the default, or GrafanaAnnotationWindows
+func (s *GrafanaService) Annotations(query string, from, to time.Time) ([]GrafanaAnnotation, error) {
+	switch strings.TrimSpace(query) {
+	case "", GrafanaAnnotationLimits:
+		return s.limitAnnotations(from, to)
+	case GrafanaAnnotationWindows:
+		return s.windowAnnotations(from, to)
+	}
+	return nil, fmt.Errorf("%w %q (expected %s or %s)", ErrUnknownGrafanaTarget, query, GrafanaAnnotationLimits, GrafanaAnnotationWindows)
+}
+
+func (s *GrafanaService) limitAnnotations(from, to time.Time) ([]GrafanaAnnotation, error) {
+	rows, err := s.db.Query(`
+		SELECT kind, timestamp, reset_at, message
+		FROM limit_events
+		WHERE timestamp >= ? AND timestamp < ?
+		ORDER BY timestamp
+	`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query limit events: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := []GrafanaAnnotation{}
+	for rows.Next() {
+		var kind, message string
+		var timestamp time.Time
+		var resetAt sql.NullTime
+		if err := rows.Scan(&kind, &timestamp, &resetAt, &message); err != nil {
+			return nil, fmt.Errorf("failed to scan limit event: %w", err)
+		}
+		annotation := GrafanaAnnotation{
+			Time:  timestamp.UnixMilli(),
+			Title: strings.ReplaceAll(kind, "_", " "),
+			Text:  message,
+			Tags:  []string{kind},
+		}
+		if resetAt.Valid && resetAt.Time.After(timestamp) {
+			annotation.TimeEnd = resetAt.Time.UnixMilli()
+		}
+		annotations = append(annotations, annotation)
+	}
+	return annotations, rows.Err()
+}
+
+func (s *GrafanaService) windowAnnotations(from, to time.Time) ([]GrafanaAnnotation, error) {
+	rows, err := s.db.Query(`
+		SELECT window_start, window_end, COALESCE(total_tokens, 0), COALESCE(message_count, 0)
+		FROM session_windows
+		WHERE window_end > ? AND window_start < ?
+		ORDER BY window_start
+	`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query windows: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := []GrafanaAnnotation{}
+	for rows.Next() {
+		var start, end time.Time
+		var tokens, messages int64
+		if err := rows.Scan(&start, &end, &tokens, &messages); err != nil {
+			return nil, fmt.Errorf("failed to scan window: %w", err)
+		}
+		annotations = append(annotations, GrafanaAnnotation{
+			Time:    start.UnixMilli(),
+			TimeEnd: end.UnixMilli(),
+			Title:   "usage window",
+			Text:    fmt.Sprintf("%d tokens in %d messages", tokens, messages),
+			Tags:    []string{"window"},
+		})
+	}
+	return annotations, rows.Err()
+}
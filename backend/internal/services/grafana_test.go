@@ -0,0 +1,106 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestGrafanaQuery(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, user_id, project_name, project_path, start_time) VALUES
+			('s1', 'alice', 'api', '/work/api', '2024-03-01 09:00:00'),
+			('s2', 'bob', 'web', '/work/web', '2024-03-01 09:00:00');
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp) VALUES
+			('m1', 's1', 'assistant', 'claude-sonnet-4-20250514', 100, 10, '2024-03-01 10:05:00'),
+			('m2', 's1', 'assistant', 'claude-sonnet-4-20250514', 200, 20, '2024-03-01 10:20:00'),
+			('m3', 's2', 'assistant', 'claude-opus-4-20250514', 300, 30, '2024-03-01 10:50:00'),
+			('m4', 's2', 'user', NULL, 5, 0, '2024-03-01 10:50:00');
+		INSERT INTO limit_events (id, session_id, kind, timestamp, reset_at, message) VALUES
+			('e1', 's1', 'usage_limit', '2024-03-01 10:30:00', '2024-03-01 13:00:00', 'Claude usage limit reached');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	service := NewGrafanaService(db)
+	from := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)
+
+	series, err := service.Query([]string{"tokens", "messages by project"}, "", from, to, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(series) != 3 {
+		t.Fatalf("Expected tokens and a messages series per project, got %+v", series)
+	}
+	tokens := series[0]
+	if tokens.Target != "tokens" || len(tokens.Datapoints) != 3 {
+		t.Fatalf("Expected 3 half-hour tokens points, got %+v", tokens)
+	}
+	if tokens.Datapoints[0][0] != 330 || tokens.Datapoints[1][0] != 330 || tokens.Datapoints[2][0] != 0 {
+		t.Errorf("Unexpected tokens %v", tokens.Datapoints)
+	}
+	if tokens.Datapoints[1][1] != float64(from.Add(30*time.Minute).UnixMilli()) {
+		t.Errorf("Expected the second point at 10:30, got %v", tokens.Datapoints[1][1])
+	}
+	if series[1].Target != "messages api" || series[1].Datapoints[0][0] != 2 || series[2].Target != "messages web" || series[2].Datapoints[1][0] != 1 {
+		t.Errorf("Unexpected per-project messages %+v", series[1:])
+	}
+
+	// Another user's usage is left out, and an empty range is all zeros
+	series, err = service.Query([]string{"tokens"}, "alice", from.Add(30*time.Minute), to, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if series[0].Datapoints[0][0] != 0 {
+		t.Errorf("Expected no usage of alice after 10:30, got %v", series[0].Datapoints)
+	}
+
+	if _, err := service.Query([]string{"tokens by day"}, "", from, to, time.Hour); !errors.Is(err, ErrUnknownGrafanaTarget) {
+		t.Errorf("Expected ErrUnknownGrafanaTarget, got %v", err)
+	}
+
+	annotations, err := service.Annotations("", from, to)
+	if err != nil {
+		t.Fatalf("Annotations failed: %v", err)
+	}
+	if len(annotations) != 1 || annotations[0].Title != "usage limit" || annotations[0].TimeEnd != time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC).UnixMilli() {
+		t.Errorf("Unexpected annotations %+v", annotations)
+	}
+	if _, err := service.Annotations("budgets", from, to); !errors.Is(err, ErrUnknownGrafanaTarget) {
+		t.Errorf("Expected ErrUnknownGrafanaTarget, got %v", err)
+	}
+}
+
+func TestGrafanaInterval(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		to        time.Time
+		interval  time.Duration
+		maxPoints int
+		expected  time.Duration
+	}{
+		{"below the bucket", from.Add(time.Hour), time.Minute, 0, 15 * time.Minute},
+		{"rounded up", from.Add(time.Hour), 20 * time.Minute, 0, 30 * time.Minute},
+		{"capped points", from.AddDate(0, 0, 1), 15 * time.Minute, 10, 2*time.Hour + 30*time.Minute},
+	}
+	for _, tt := range tests {
+		if got := GrafanaInterval(from, tt.to, tt.interval, tt.maxPoints); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
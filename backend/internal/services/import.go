@@ -0,0 +1,314 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// What an import does with records the database already has
+const (
+	ImportSkip    = "skip"
+	ImportReplace = "replace"
+)
+
+// Formats of an import file
+const (
+	ImportCCUsageDaily   = "ccusage-daily"
+	ImportCCUsageMonthly = "ccusage-monthly"
+	ImportMessages       = "messages"
+)
+
+// ccusageIDPrefix starts the IDs of messages imported from ccusage reports,
+// one per model and day or month
+const ccusageIDPrefix = "ccusage:"
+
+// ccusageImportProject names the project of usage imported from ccusage,
+// whose reports do not say which project it was
+const ccusageImportProject = "ccusage-import"
+
+// ImportOptions control an import
+type ImportOptions struct {
+	// OnConflict is skip or replace, for messages already in the database
+	OnConflict string
+	// User owns the sessions the import creates
+	User string
+	// Location places the days and months of ccusage reports
+	Location *time.Location
+}
+
+// ImportResult counts what happened to the records of an import
+type ImportResult struct {
+	Format   string `json:"format"`
+	Received int    `json:"received"`
+	Imported int    `json:"imported"`
+	// Skipped were already in the database and kept as they were
+	Skipped int `json:"skipped"`
+	// Replaced were already in the database and overwritten
+	Replaced int `json:"replaced"`
+	// Overlapping are days or months of a ccusage report the database has
+	// Claude Code usage for from logs; ccusage read the same logs, so
+	// importing them would count the usage twice
+	Overlapping []string `json:"overlapping,omitempty"`
+}
+
+// importPeriod is a day or month of a ccusage report and its messages
+type importPeriod struct {
+	name       string
+	start, end time.Time
+	entries    []IngestEntry
+}
+
+// Import merges a ccusage daily or monthly JSON report, or a claudeee
+// message export in JSON or JSON lines, into the database. Records are
+// stored like entries pushed by an agent, so sessions, windows and totals
+// follow. Messages already stored are kept or, with ImportReplace,
+// overwritten; ccusage periods with synced usage are left out.
+func (d *DiffSyncService) Import(r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.OnConflict == "" {
+		opts.OnConflict = ImportSkip
+	}
+	if opts.OnConflict != ImportSkip && opts.OnConflict != ImportReplace {
+		return nil, fmt.Errorf("unknown conflict strategy %q (expected skip or replace)", opts.OnConflict)
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	}
+
+	format, entries, periods, err := decodeImport(data, opts.Location)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImportResult{Format: format}
+	for _, period := range periods {
+		result.Received += len(period.entries)
+		synced, err := d.hasSyncedUsage(period.start, period.end)
+		if err != nil {
+			return nil, err
+		}
+		if synced {
+			result.Overlapping = append(result.Overlapping, period.name)
+			continue
+		}
+		entries = append(entries, period.entries...)
+	}
+	if periods == nil {
+		result.Received = len(entries)
+	}
+
+	if opts.OnConflict == ImportReplace {
+		ids := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.Entry.UUID)
+		}
+		if err := d.writes.Do(func() error {
+			result.Replaced, err = deleteMessages(d.db, ids)
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		// Pick up the deleted messages
+		d.knownIDs = nil
+	}
+
+	ingested, err := d.Ingest(entries, opts.User)
+	if err != nil {
+		return nil, err
+	}
+	result.Imported = ingested.Ingested - result.Replaced
+	result.Skipped = ingested.Duplicates + ingested.Skipped
+	return result, nil
+}
+
+// decodeImport returns the format of data and its records: messages, or
+// for ccusage reports messages by period
+func decodeImport(data []byte, loc *time.Location) (string, []IngestEntry, []importPeriod, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return "", nil, nil, errors.New("the import file is empty")
+	}
+
+	if data[0] == '[' {
+		var messages []ExportedMessage
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return "", nil, nil, fmt.Errorf("failed to read message export: %w", err)
+		}
+		return ImportMessages, exportedEntries(messages), nil, nil
+	}
+
+	var report struct {
+		Daily   []CCUsagePeriod `json:"daily"`
+		Monthly []CCUsagePeriod `json:"monthly"`
+		Blocks  json.RawMessage `json:"blocks"`
+		Session json.RawMessage `json:"sessions"`
+	}
+	// A JSON lines export fails here on its second line
+	if err := json.Unmarshal(data, &report); err == nil {
+		switch {
+		case report.Daily != nil:
+			periods, err := ccusageImportPeriods(report.Daily, "2006-01-02", loc)
+			return ImportCCUsageDaily, nil, periods, err
+		case report.Monthly != nil:
+			periods, err := ccusageImportPeriods(report.Monthly, "2006-01", loc)
+			return ImportCCUsageMonthly, nil, periods, err
+		case report.Blocks != nil || report.Session != nil:
+			return "", nil, nil, errors.New("ccusage blocks and session reports cannot be imported: use ccusage daily --json")
+		}
+	}
+
+	var messages []ExportedMessage
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var message ExportedMessage
+		if err := json.Unmarshal(line, &message); err != nil {
+			return "", nil, nil, fmt.Errorf("line %d is neither a ccusage report nor an exported message: %w", i+1, err)
+		}
+		if message.MessageID == "" || message.SessionID == "" {
+			return "", nil, nil, fmt.Errorf("line %d is not an exported message: it has no message_id or session_id", i+1)
+		}
+		messages = append(messages, message)
+	}
+	return ImportMessages, exportedEntries(messages), nil, nil
+}
+
+// exportedEntries turns exported messages back into log entries
+func exportedEntries(messages []ExportedMessage) []IngestEntry {
+	entries := make([]IngestEntry, 0, len(messages))
+	for _, m := range messages {
+		messageType := "message"
+		entry := models.LogEntry{
+			UUID:      m.MessageID,
+			SessionID: m.SessionID,
+			UserType:  "external",
+			Type:      m.Role,
+			Message:   models.LogMessage{Type: &messageType, Role: m.Role},
+			Timestamp: m.Timestamp,
+			Provider:  m.Provider,
+		}
+		if m.Model != "" {
+			model := m.Model
+			entry.Message.Model = &model
+		}
+		if m.Content != nil {
+			entry.Message.Content = *m.Content
+		}
+		if m.Role == "assistant" {
+			entry.Message.Usage = &models.Usage{
+				InputTokens:              int(m.InputTokens),
+				OutputTokens:             int(m.OutputTokens),
+				CacheCreationInputTokens: int(m.CacheCreationInputTokens),
+				CacheReadInputTokens:     int(m.CacheReadInputTokens),
+			}
+		}
+		entries = append(entries, IngestEntry{Project: m.ProjectName, Entry: entry})
+	}
+	return entries
+}
+
+// ccusageImportPeriods turns the days or months of a ccusage report into an
+// assistant message per model, at noon of the period's first day. Each
+// period becomes a session of the ccusage-import project.
+func ccusageImportPeriods(report []CCUsagePeriod, layout string, loc *time.Location) ([]importPeriod, error) {
+	periods := make([]importPeriod, 0, len(report))
+	for _, p := range report {
+		name := p.Date
+		if layout == "2006-01" {
+			name = p.Month
+		}
+		start, err := time.ParseInLocation(layout, name, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ccusage period %q: %w", name, err)
+		}
+		period := importPeriod{name: name, start: start, end: start.AddDate(0, 0, 1)}
+		if layout == "2006-01" {
+			period.end = start.AddDate(0, 1, 0)
+		}
+
+		breakdowns := p.ModelBreakdowns
+		// Reports without breakdowns only have the period's totals
+		if len(breakdowns) == 0 && p.TotalTokens > 0 {
+			model := "unknown"
+			if len(p.ModelsUsed) > 0 {
+				model = p.ModelsUsed[0]
+			}
+			breakdowns = []CCUsageModelBreakdown{{
+				ModelName: model, InputTokens: p.InputTokens, OutputTokens: p.OutputTokens,
+				CacheCreationTokens: p.CacheCreationTokens, CacheReadTokens: p.CacheReadTokens,
+			}}
+		}
+		sessionID := ccusageIDPrefix + name
+		for _, b := range breakdowns {
+			messageType := "message"
+			model := b.ModelName
+			period.entries = append(period.entries, IngestEntry{Project: ccusageImportProject, Entry: models.LogEntry{
+				UUID:      sessionID + ":" + model,
+				SessionID: sessionID,
+				UserType:  "external",
+				Type:      "assistant",
+				Message: models.LogMessage{Type: &messageType, Role: "assistant", Model: &model, Usage: &models.Usage{
+					InputTokens:              int(b.InputTokens),
+					OutputTokens:             int(b.OutputTokens),
+					CacheCreationInputTokens: int(b.CacheCreationTokens),
+					CacheReadInputTokens:     int(b.CacheReadTokens),
+				}},
+				Timestamp: start.Add(12 * time.Hour),
+				Provider:  ProviderClaude,
+			}})
+		}
+		periods = append(periods, period)
+	}
+	return periods, nil
+}
+
+// hasSyncedUsage reports whether Claude Code messages other than ones
+// imported from ccusage fall in [start, end)
+func (d *DiffSyncService) hasSyncedUsage(start, end time.Time) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`
+		SELECT COUNT(*) FROM messages
+		WHERE timestamp >= ? AND timestamp < ? AND COALESCE(provider, 'claude') = 'claude'
+			AND message_role = 'assistant' AND id NOT LIKE 'ccusage:%'
+	`, start, end).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check synced usage: %w", err)
+	}
+	return count > 0, nil
+}
+
+// deleteMessages removes the messages with ids and their tool calls, and
+// returns how many there were
+func deleteMessages(db *sql.DB, ids []string) (int, error) {
+	deleted := 0
+	for start := 0; start < len(ids); start += streamBatchSize {
+		batch := ids[start:min(start+streamBatchSize, len(ids))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		if _, err := db.Exec(`DELETE FROM tool_calls WHERE message_id IN (`+placeholders+`)`, args...); err != nil {
+			return deleted, fmt.Errorf("failed to delete tool calls: %w", err)
+		}
+		res, err := db.Exec(`DELETE FROM messages WHERE id IN (`+placeholders+`)`, args...)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete messages: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		deleted += int(n)
+	}
+	return deleted, nil
+}
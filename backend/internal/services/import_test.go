@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ccusageDailyReport = `{
+  "daily": [
+    {"date": "2025-03-01", "inputTokens": 1200, "outputTokens": 300, "cacheCreationTokens": 0, "cacheReadTokens": 5000,
+     "totalTokens": 6500, "totalCost": 0.1, "modelsUsed": ["claude-sonnet-4-20250514", "claude-opus-4-20250514"],
+     "modelBreakdowns": [
+       {"modelName": "claude-sonnet-4-20250514", "inputTokens": 1000, "outputTokens": 200, "cacheCreationTokens": 0, "cacheReadTokens": 5000, "cost": 0.05},
+       {"modelName": "claude-opus-4-20250514", "inputTokens": 200, "outputTokens": 100, "cacheCreationTokens": 0, "cacheReadTokens": 0, "cost": 0.05}]},
+    {"date": "2025-03-02", "inputTokens": 700, "outputTokens": 70, "cacheCreationTokens": 0, "cacheReadTokens": 0,
+     "totalTokens": 770, "totalCost": 0.01, "modelsUsed": ["claude-sonnet-4-20250514"],
+     "modelBreakdowns": [{"modelName": "claude-sonnet-4-20250514", "inputTokens": 700, "outputTokens": 70, "cacheCreationTokens": 0, "cacheReadTokens": 0, "cost": 0.01}]}
+  ],
+  "totals": {"inputTokens": 1900, "outputTokens": 370, "cacheCreationTokens": 0, "cacheReadTokens": 5000, "totalTokens": 7270, "totalCost": 0.11}
+}`
+
+func TestImportCCUsageReport(t *testing.T) {
+	db, _ := setupExportTest(t)
+	// March 2nd was synced from logs ccusage also read
+	march2 := time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC)
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'alpha', '/alpha', ?)`, march2); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	insertExportMessage(t, db, "m1", "s1", "assistant", "claude-sonnet-4-20250514", march2, 700, 70)
+
+	diffSync := NewDiffSyncService(db, NewTokenService(db), NewSessionService(db))
+	result, err := diffSync.Import(strings.NewReader(ccusageDailyReport), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Format != ImportCCUsageDaily || result.Received != 3 || result.Imported != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if len(result.Overlapping) != 1 || result.Overlapping[0] != "2025-03-02" {
+		t.Errorf("Expected March 2nd to be left out, got %v", result.Overlapping)
+	}
+
+	var project string
+	var input, cacheRead int
+	err = db.QueryRow(`
+		SELECT s.project_name, SUM(m.input_tokens), SUM(m.cache_read_input_tokens)
+		FROM messages m JOIN sessions s ON s.id = m.session_id
+		WHERE m.session_id = 'ccusage:2025-03-01' GROUP BY s.project_name`).Scan(&project, &input, &cacheRead)
+	if err != nil {
+		t.Fatalf("Failed to read imported day: %v", err)
+	}
+	if project != ccusageImportProject || input != 1200 || cacheRead != 5000 {
+		t.Errorf("Unexpected import of March 1st: %s, %d input, %d cache read", project, input, cacheRead)
+	}
+
+	// Importing the report again changes nothing
+	result, err = diffSync.Import(strings.NewReader(ccusageDailyReport), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Imported != 0 || result.Skipped != 2 {
+		t.Errorf("Expected a second import to skip everything, got %+v", result)
+	}
+
+	if _, err := diffSync.Import(strings.NewReader(`{"blocks": []}`), ImportOptions{}); err == nil {
+		t.Error("Expected an error for a blocks report")
+	}
+	if _, err := diffSync.Import(strings.NewReader(ccusageDailyReport), ImportOptions{OnConflict: "merge"}); err == nil {
+		t.Error("Expected an error for an unknown conflict strategy")
+	}
+}
+
+func TestImportMessageExport(t *testing.T) {
+	source, exports := setupExportTest(t)
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	if _, err := source.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'alpha', '/alpha', ?)`, start); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	insertExportMessage(t, source, "m1", "s1", "user", "", start, 0, 0)
+	insertExportMessage(t, source, "m2", "s1", "assistant", "claude-sonnet-4-20250514", start.Add(time.Minute), 1000, 100)
+	insertExportMessage(t, source, "m3", "s1", "assistant", "gpt-5-codex", start.Add(2*time.Minute), 2000, 200)
+	if _, err := source.Exec(`UPDATE messages SET provider = 'codex' WHERE id = 'm3'`); err != nil {
+		t.Fatal(err)
+	}
+
+	var export bytes.Buffer
+	w, err := NewRecordWriter(&export, ExportJSONL, MessageExportHeader(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = exports.StreamMessages(ExportQuery{Content: true}, func(m ExportedMessage) error {
+		return w.Write(m, m.Row(true))
+	})
+	if err != nil || w.Close() != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	target, _ := setupExportTest(t)
+	diffSync := NewDiffSyncService(target, NewTokenService(target), NewSessionService(target))
+	result, err := diffSync.Import(bytes.NewReader(export.Bytes()), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Format != ImportMessages || result.Received != 3 || result.Imported != 3 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	var provider, content string
+	var total int
+	if err := target.QueryRow(`SELECT provider, content FROM messages WHERE id = 'm3'`).Scan(&provider, &content); err != nil {
+		t.Fatalf("Failed to read imported message: %v", err)
+	}
+	if err := target.QueryRow(`SELECT total_tokens FROM sessions WHERE id = 's1'`).Scan(&total); err != nil {
+		t.Fatalf("Failed to read imported session: %v", err)
+	}
+	if provider != ProviderCodex || content != "hello" || total != 3300 {
+		t.Errorf("Expected the codex message and session totals to survive, got %s, %q, %d", provider, content, total)
+	}
+
+	// A later export of the same messages overwrites them with replace
+	if _, err := target.Exec(`UPDATE messages SET input_tokens = 1 WHERE id = 'm2'`); err != nil {
+		t.Fatal(err)
+	}
+	result, err = diffSync.Import(bytes.NewReader(export.Bytes()), ImportOptions{OnConflict: ImportReplace})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.Replaced != 3 || result.Imported != 0 {
+		t.Errorf("Expected 3 replaced messages, got %+v", result)
+	}
+	var input int
+	if err := target.QueryRow(`SELECT input_tokens FROM messages WHERE id = 'm2'`).Scan(&input); err != nil || input != 1000 {
+		t.Errorf("Expected the replaced message to have 1000 input tokens, got %d (%v)", input, err)
+	}
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrUnknownIndex is returned when applying an index the advisor does not know
+var ErrUnknownIndex = errors.New("unknown index")
+
+// IndexMinRows is the table size from which a missing index is recommended.
+// Below it a sequential scan is as fast as an index lookup and the index only
+// slows down ingest.
+const IndexMinRows = 10000
+
+// indexSpec describes an index a hot query benefits from
+type indexSpec struct {
+	name    string
+	table   string
+	columns []string
+}
+
+func (s indexSpec) statement() string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", s.name, s.table, strings.Join(s.columns, ", "))
+}
+
+// hotQuery is a query the dashboard or sync runs frequently
+type hotQuery struct {
+	name  string
+	table string
+	query string
+	args  func(db *sql.DB) []interface{}
+	// filter columns, used to judge whether existing indexes are used
+	columns []string
+	// index that serves the query; nil when the primary key already does
+	index *indexSpec
+}
+
+func sampleSessionID(db *sql.DB) []interface{} {
+	var id string
+	db.QueryRow(`SELECT id FROM sessions ORDER BY start_time DESC LIMIT 1`).Scan(&id)
+	return []interface{}{id}
+}
+
+func recentWindow(*sql.DB) []interface{} {
+	end := time.Now()
+	return []interface{}{end.Add(-5 * time.Hour), end}
+}
+
+var hotQueries = []hotQuery{
+	{
+		name:    "session_messages",
+		table:   "messages",
+		query:   `SELECT id, timestamp FROM messages WHERE session_id = ? ORDER BY timestamp DESC LIMIT 50`,
+		args:    sampleSessionID,
+		columns: []string{"session_id"},
+		index:   &indexSpec{"idx_messages_session_id", "messages", []string{"session_id"}},
+	},
+	{
+		name:    "window_usage",
+		table:   "messages",
+		query:   `SELECT SUM(input_tokens + output_tokens) FROM messages WHERE timestamp >= ? AND timestamp < ?`,
+		args:    recentWindow,
+		columns: []string{"timestamp"},
+		index:   &indexSpec{"idx_messages_timestamp", "messages", []string{"timestamp"}},
+	},
+	{
+		name:    "window_messages",
+		table:   "messages",
+		query:   `SELECT COUNT(*) FROM messages WHERE session_window_id = ?`,
+		args:    func(*sql.DB) []interface{} { return []interface{}{""} },
+		columns: []string{"session_window_id"},
+		index:   &indexSpec{"idx_messages_session_window_id", "messages", []string{"session_window_id"}},
+	},
+	{
+		name:    "rollup_refresh",
+		table:   "messages",
+		query:   "SELECT DISTINCT " + rollupBucket("timestamp") + " FROM messages WHERE created_at > ?",
+		args:    func(*sql.DB) []interface{} { return []interface{}{time.Now().Add(-time.Minute)} },
+		columns: []string{"created_at"},
+		index:   &indexSpec{"idx_messages_created_at", "messages", []string{"created_at"}},
+	},
+	{
+		name:    "message_dedup",
+		table:   "messages",
+		query:   `SELECT COUNT(*) FROM messages WHERE id = ?`,
+		args:    func(*sql.DB) []interface{} { return []interface{}{""} },
+		columns: []string{"id"},
+	},
+	{
+		name:    "recent_sessions",
+		table:   "sessions",
+		query:   `SELECT id FROM sessions ORDER BY start_time DESC LIMIT 20`,
+		args:    func(*sql.DB) []interface{} { return nil },
+		columns: []string{"start_time"},
+		index:   &indexSpec{"idx_sessions_start_time", "sessions", []string{"start_time"}},
+	},
+	{
+		name:    "project_sessions",
+		table:   "sessions",
+		query:   `SELECT id FROM sessions WHERE project_name = ?`,
+		args:    func(*sql.DB) []interface{} { return []interface{}{""} },
+		columns: []string{"project_name"},
+		index:   &indexSpec{"idx_sessions_project_name", "sessions", []string{"project_name"}},
+	},
+	{
+		name:    "window_lookup",
+		table:   "session_windows",
+		query:   `SELECT id FROM session_windows WHERE ? >= window_start AND ? < window_end`,
+		args:    func(*sql.DB) []interface{} { now := time.Now(); return []interface{}{now, now} },
+		columns: []string{"window_start", "window_end", "is_active"},
+		index:   &indexSpec{"idx_session_windows_times", "session_windows", []string{"window_start", "window_end"}},
+	},
+	{
+		name:    "file_state",
+		table:   "file_sync_state",
+		query:   `SELECT last_processed_line FROM file_sync_state WHERE file_path = ?`,
+		args:    func(*sql.DB) []interface{} { return []interface{}{""} },
+		columns: []string{"file_path", "sync_status", "last_sync_time"},
+	},
+}
+
+// QueryPlan is the EXPLAIN output for one hot query
+type QueryPlan struct {
+	Name      string `json:"name"`
+	Table     string `json:"table"`
+	Rows      int64  `json:"rows"`
+	UsesIndex bool   `json:"uses_index"`
+	Plan      string `json:"plan"`
+}
+
+// IndexInfo describes an index present in the database
+type IndexInfo struct {
+	Name    string   `json:"name"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+}
+
+// IndexRecommendation is a missing index that a hot query would use
+type IndexRecommendation struct {
+	Name        string   `json:"name"`
+	Table       string   `json:"table"`
+	Columns     []string `json:"columns"`
+	Query       string   `json:"query"`
+	Rows        int64    `json:"rows"`
+	Recommended bool     `json:"recommended"`
+	Statement   string   `json:"statement"`
+}
+
+// UnusedIndex is an index no hot query filters on; it costs ingest time and memory
+type UnusedIndex struct {
+	IndexInfo
+	Rows int64 `json:"rows"`
+}
+
+// IndexReport is the result of an index health check
+type IndexReport struct {
+	Tables  map[string]int64      `json:"tables"`
+	Queries []QueryPlan           `json:"queries"`
+	Missing []IndexRecommendation `json:"missing"`
+	Unused  []UnusedIndex         `json:"unused"`
+}
+
+// IndexAdvisor checks the hot queries against the data actually stored and
+// suggests index changes
+type IndexAdvisor struct {
+	db *sql.DB
+}
+
+func NewIndexAdvisor(db *sql.DB) *IndexAdvisor {
+	return &IndexAdvisor{db: db}
+}
+
+// Analyze explains each hot query and compares the indexes they need with
+// the indexes present
+func (a *IndexAdvisor) Analyze() (*IndexReport, error) {
+	indexes, err := a.listIndexes()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IndexReport{
+		Tables:  map[string]int64{},
+		Queries: []QueryPlan{},
+		Missing: []IndexRecommendation{},
+		Unused:  []UnusedIndex{},
+	}
+	used := map[string][]string{}
+
+	for _, hq := range hotQueries {
+		rows, ok, err := a.tableRows(hq.table, report.Tables)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		used[hq.table] = append(used[hq.table], hq.columns...)
+
+		plan, err := a.explain(hq.query, hq.args(a.db))
+		if err != nil {
+			return nil, fmt.Errorf("failed to explain %s: %w", hq.name, err)
+		}
+		report.Queries = append(report.Queries, QueryPlan{
+			Name:      hq.name,
+			Table:     hq.table,
+			Rows:      rows,
+			UsesIndex: strings.Contains(plan, "INDEX_SCAN"),
+			Plan:      plan,
+		})
+
+		if hq.index != nil && !hasIndex(indexes, hq.index.table, hq.index.columns) {
+			report.Missing = append(report.Missing, IndexRecommendation{
+				Name:        hq.index.name,
+				Table:       hq.index.table,
+				Columns:     hq.index.columns,
+				Query:       hq.name,
+				Rows:        rows,
+				Recommended: rows >= IndexMinRows,
+				Statement:   hq.index.statement(),
+			})
+		}
+	}
+
+	for _, index := range indexes {
+		columns, ok := used[index.Table]
+		if !ok || len(index.Columns) == 0 || containsFold(columns, index.Columns[0]) {
+			continue
+		}
+		report.Unused = append(report.Unused, UnusedIndex{IndexInfo: index, Rows: report.Tables[index.Table]})
+	}
+
+	return report, nil
+}
+
+// Apply creates the named indexes, or every recommended index when names is
+// empty. Only indexes the advisor knows about can be created.
+func (a *IndexAdvisor) Apply(names []string) ([]string, error) {
+	if len(names) == 0 {
+		report, err := a.Analyze()
+		if err != nil {
+			return nil, err
+		}
+		for _, missing := range report.Missing {
+			if missing.Recommended {
+				names = append(names, missing.Name)
+			}
+		}
+	}
+
+	specs := make([]*indexSpec, 0, len(names))
+	for _, name := range names {
+		spec := findIndexSpec(name)
+		if spec == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownIndex, name)
+		}
+		specs = append(specs, spec)
+	}
+
+	created := []string{}
+	for _, spec := range specs {
+		if _, err := a.db.Exec(spec.statement()); err != nil {
+			return created, fmt.Errorf("failed to create index %s: %w", spec.name, err)
+		}
+		created = append(created, spec.name)
+	}
+	return created, nil
+}
+
+func findIndexSpec(name string) *indexSpec {
+	for _, hq := range hotQueries {
+		if hq.index != nil && hq.index.name == name {
+			return hq.index
+		}
+	}
+	return nil
+}
+
+// tableRows counts rows once per table; ok is false when the table does not exist
+func (a *IndexAdvisor) tableRows(table string, counts map[string]int64) (int64, bool, error) {
+	if rows, ok := counts[table]; ok {
+		return rows, true, nil
+	}
+
+	var exists bool
+	err := a.db.QueryRow(`SELECT COUNT(*) > 0 FROM duckdb_tables() WHERE table_name = ?`, table).Scan(&exists)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+	if !exists {
+		return 0, false, nil
+	}
+
+	var rows int64
+	// table names come from hotQueries, never from user input
+	if err := a.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&rows); err != nil {
+		return 0, false, fmt.Errorf("failed to count rows in %s: %w", table, err)
+	}
+	counts[table] = rows
+	return rows, true, nil
+}
+
+func (a *IndexAdvisor) explain(query string, args []interface{}) (string, error) {
+	rows, err := a.db.Query("EXPLAIN "+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var plan strings.Builder
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return "", err
+		}
+		plan.WriteString(value)
+	}
+	return plan.String(), rows.Err()
+}
+
+var indexColumnsPattern = regexp.MustCompile(`\(([^)]*)\)`)
+
+// listIndexes returns secondary indexes; primary keys are not reported
+func (a *IndexAdvisor) listIndexes() ([]IndexInfo, error) {
+	rows, err := a.db.Query(`
+		SELECT index_name, table_name, COALESCE(sql, '')
+		FROM duckdb_indexes()
+		WHERE NOT is_primary
+		ORDER BY table_name, index_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+	defer rows.Close()
+
+	indexes := []IndexInfo{}
+	for rows.Next() {
+		var index IndexInfo
+		var statement string
+		if err := rows.Scan(&index.Name, &index.Table, &statement); err != nil {
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		if match := indexColumnsPattern.FindStringSubmatch(statement); match != nil {
+			for _, column := range strings.Split(match[1], ",") {
+				index.Columns = append(index.Columns, strings.Trim(strings.TrimSpace(column), `"`))
+			}
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+// hasIndex reports whether an index on table starts with the given columns
+func hasIndex(indexes []IndexInfo, table string, columns []string) bool {
+	for _, index := range indexes {
+		if index.Table != table || len(index.Columns) < len(columns) {
+			continue
+		}
+		match := true
+		for i, column := range columns {
+			if !strings.EqualFold(index.Columns[i], column) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
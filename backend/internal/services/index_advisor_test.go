@@ -0,0 +1,87 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIndexAdvisorReportsMissingAndUnused(t *testing.T) {
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+
+	if _, err := db.Exec(`CREATE INDEX idx_messages_message_role ON messages (message_role)`); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	_, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, timestamp, created_at)
+		SELECT 'msg-' || i, 'session-' || (i % 100), 'assistant', now(), now()
+		FROM range(?) t(i)
+	`, IndexMinRows)
+	if err != nil {
+		t.Fatalf("Failed to insert messages: %v", err)
+	}
+
+	advisor := NewIndexAdvisor(db)
+	report, err := advisor.Analyze()
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if report.Tables["messages"] != IndexMinRows {
+		t.Errorf("Expected %d message rows, got %d", IndexMinRows, report.Tables["messages"])
+	}
+	if len(report.Queries) == 0 {
+		t.Error("Expected query plans")
+	}
+
+	missing := map[string]IndexRecommendation{}
+	for _, m := range report.Missing {
+		missing[m.Name] = m
+	}
+	if !missing["idx_messages_session_id"].Recommended {
+		t.Errorf("Expected idx_messages_session_id to be recommended, got %+v", report.Missing)
+	}
+	if m, ok := missing["idx_sessions_project_name"]; !ok || m.Recommended {
+		t.Errorf("Expected idx_sessions_project_name missing but not recommended for an empty table, got %+v", m)
+	}
+
+	unused := map[string]bool{}
+	for _, u := range report.Unused {
+		unused[u.Name] = true
+	}
+	if !unused["idx_messages_message_role"] {
+		t.Errorf("Expected idx_messages_message_role to be unused, got %+v", report.Unused)
+	}
+	if unused["idx_file_sync_state_path"] {
+		t.Error("Expected idx_file_sync_state_path to be used by the file state lookup")
+	}
+
+	created, err := advisor.Apply(nil)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(created) == 0 {
+		t.Fatal("Expected recommended indexes to be created")
+	}
+
+	report, err = advisor.Analyze()
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	for _, m := range report.Missing {
+		if m.Recommended {
+			t.Errorf("Expected no recommendations after apply, got %s", m.Name)
+		}
+	}
+}
+
+func TestIndexAdvisorRejectsUnknownIndex(t *testing.T) {
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	_, err := NewIndexAdvisor(db).Apply([]string{"idx_messages_content; DROP TABLE messages"})
+	if !errors.Is(err, ErrUnknownIndex) {
+		t.Errorf("Expected ErrUnknownIndex, got %v", err)
+	}
+}
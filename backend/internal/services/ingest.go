@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+)
+
+// MaxIngestEntries is the most log entries accepted in one ingest request
+const MaxIngestEntries = 5000
+
+// IngestRequest is a batch of log entries pushed by a remote agent
+type IngestRequest struct {
+	// Host names the machine the entries were read on, for logging only
+	Host    string        `json:"host"`
+	Entries []IngestEntry `json:"entries"`
+}
+
+// IngestEntry is one parsed log line with the project directory it was read from
+type IngestEntry struct {
+	// Project is the name of the directory under ~/.claude/projects
+	Project string          `json:"project"`
+	Entry   models.LogEntry `json:"entry"`
+}
+
+// IngestResult counts what happened to the entries of an ingest request
+type IngestResult struct {
+	Received int `json:"received"`
+	Ingested int `json:"ingested"`
+	// Duplicates were already stored, e.g. by a batch the agent sent again
+	Duplicates int `json:"duplicates"`
+	// Skipped lack the session or timestamp needed to store them
+	Skipped int `json:"skipped"`
+	// Redactions counts the secrets removed from the content, by kind
+	Redactions map[string]int `json:"redactions,omitempty"`
+}
+
+// Ingest stores log entries pushed by a remote agent as messages of userID.
+// Entries already stored, by uuid or for entries without one by content
+// hash, count as duplicates, so an agent can safely send a batch again when
+// it did not see the response, and batches overlapping a local sync change
+// nothing.
+func (d *DiffSyncService) Ingest(entries []IngestEntry, userID string) (*IngestResult, error) {
+	result := &IngestResult{Received: len(entries)}
+
+	// Pick up windows and messages written since the previous pass
+	d.windowService.InvalidateCache()
+	d.knownIDs = nil
+
+	for i := range entries {
+		entry := &entries[i].Entry
+		if entry.SessionID == "" || entry.Timestamp.IsZero() {
+			result.Skipped++
+			continue
+		}
+		hash := logEntryHash(entry)
+		ingested, err := d.isIngested(logEntryID(entry, hash))
+		if err != nil {
+			return result, fmt.Errorf("failed to check log entry: %w", err)
+		}
+		if ingested {
+			result.Duplicates++
+			continue
+		}
+
+		d.queueLogEntry(entry, hash, entries[i].Project, userID)
+		result.Ingested++
+
+		if d.batch.full() {
+			if err := d.writes.Do(d.writeBatch); err != nil {
+				return result, fmt.Errorf("failed to write messages: %w", err)
+			}
+		}
+	}
+
+	if err := d.writes.Do(d.writeBatch); err != nil {
+		return result, fmt.Errorf("failed to write messages: %w", err)
+	}
+	if err := d.writes.Do(d.flushSessionTokens); err != nil {
+		return result, fmt.Errorf("failed to update session tokens: %w", err)
+	}
+	if err := d.writes.Do(d.flushWindowStats); err != nil {
+		return result, fmt.Errorf("failed to update window stats: %w", err)
+	}
+	result.Redactions = d.redactionCounts()
+	if err := d.writes.Do(d.flushRedactionCounts); err != nil {
+		logging.Component("sync").Warn("Failed to record redaction counts", "err", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestIngestIsIdempotent(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if err := NewUserService(db).Register([]User{{ID: "local", Name: "local"}, {ID: "bob", Name: "Bob"}}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	lines := []string{
+		`{"uuid":"r-1","sessionId":"remote","userType":"external","cwd":"/work/remote","timestamp":"2024-01-02T09:00:00Z","message":{"role":"user","content":"hi"}}`,
+		`{"uuid":"r-2","sessionId":"remote","userType":"external","cwd":"/work/remote","timestamp":"2024-01-02T09:01:00Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","content":"hello","usage":{"input_tokens":100,"output_tokens":50}}}`,
+		// Stored under its content hash
+		`{"sessionId":"remote","timestamp":"2024-01-02T09:02:00Z","message":{"role":"user","content":"no uuid"}}`,
+		`{"uuid":"r-3","timestamp":"2024-01-02T09:03:00Z","message":{"role":"user","content":"no session"}}`,
+	}
+	var entries []IngestEntry
+	for _, line := range lines {
+		entry := IngestEntry{Project: "-work-remote"}
+		if err := json.Unmarshal([]byte(line), &entry.Entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	ingest := func() *IngestResult {
+		diffSync := NewDiffSyncService(db, NewTokenService(db), NewSessionService(db))
+		result, err := diffSync.Ingest(entries, "bob")
+		if err != nil {
+			t.Fatalf("Ingest failed: %v", err)
+		}
+		return result
+	}
+
+	first := ingest()
+	if first.Received != 4 || first.Ingested != 3 || first.Duplicates != 0 || first.Skipped != 1 {
+		t.Errorf("Unexpected first result %+v", first)
+	}
+	// The agent resends a batch whose response it missed
+	second := ingest()
+	if second.Ingested != 0 || second.Duplicates != 3 {
+		t.Errorf("Expected a resent batch to be all duplicates, got %+v", second)
+	}
+
+	page, err := NewSessionService(db).QuerySessions(SessionQuery{User: "bob", Limit: 10})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if page.Total != 1 {
+		t.Fatalf("Expected the remote session for bob, got %+v", page.Sessions)
+	}
+	session := page.Sessions[0]
+	if session.ProjectName != "remote" || session.MessageCount != 1 || session.TotalTokens != 150 {
+		t.Errorf("Expected one assistant message counted once, got %+v", session)
+	}
+}
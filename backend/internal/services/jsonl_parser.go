@@ -1,7 +1,7 @@
 package services
 
 import (
-	"bufio"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 	
+	"claudeee-backend/internal/logging"
 	"claudeee-backend/internal/models"
 )
 
@@ -18,28 +19,44 @@ type JSONLParser struct {
 	tokenService   *TokenService
 	sessionService *SessionService
 	windowService  *SessionWindowService
+	stateManager   *FileSyncStateManager
+	batch          *messageBatch
+	contentPolicy  ContentPolicy
+	cipher         *ContentCipher
 }
 
 func NewJSONLParser(db *sql.DB, tokenService *TokenService, sessionService *SessionService) *JSONLParser {
 	windowService := NewSessionWindowService(db)
+	windowService.EnableCache()
 	return &JSONLParser{
 		db:             db,
 		tokenService:   tokenService,
 		sessionService: sessionService,
 		windowService:  windowService,
+		stateManager:   NewFileSyncStateManager(db),
+		batch:          newMessageBatch(),
+		contentPolicy:  DefaultContentPolicy(),
 	}
 }
 
+// SetContentPolicy controls how much message content is stored
+func (p *JSONLParser) SetContentPolicy(policy ContentPolicy) {
+	p.contentPolicy = policy
+}
+
+// SetContentCipher encrypts stored content; nil stores it in plain text
+func (p *JSONLParser) SetContentCipher(c *ContentCipher) {
+	p.cipher = c
+}
 
-func (p *JSONLParser) SyncAllLogs() error {
+// SyncAllLogs reads every project's logs, stopping between files when ctx is canceled
+func (p *JSONLParser) SyncAllLogs(ctx context.Context) error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return fmt.Errorf("failed to get home directory: %w", err)
 	}
 	
 	claudeDir := filepath.Join(homeDir, ".claude", "projects")
-	fmt.Printf("Looking for Claude projects in: %s\n", claudeDir)
-	
 	if _, err := os.Stat(claudeDir); os.IsNotExist(err) {
 		return fmt.Errorf("claude projects directory not found: %s", claudeDir)
 	}
@@ -49,14 +66,15 @@ func (p *JSONLParser) SyncAllLogs() error {
 		return fmt.Errorf("failed to read claude projects directory: %w", err)
 	}
 	
-	fmt.Printf("Found %d projects\n", len(entries))
-	
+	logger := logging.Component("sync")
 	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("sync canceled: %w", err)
+		}
 		if entry.IsDir() {
 			projectPath := filepath.Join(claudeDir, entry.Name())
-			fmt.Printf("Syncing project: %s\n", entry.Name())
-			if err := p.syncProjectLogs(projectPath, entry.Name()); err != nil {
-				fmt.Printf("Error syncing project %s: %v\n", entry.Name(), err)
+			if err := p.syncProjectLogs(ctx, projectPath, entry.Name()); err != nil {
+				logger.Error("Failed to sync project", "project", entry.Name(), "err", err)
 			}
 		}
 	}
@@ -64,69 +82,104 @@ func (p *JSONLParser) SyncAllLogs() error {
 	return nil
 }
 
-func (p *JSONLParser) syncProjectLogs(projectPath, projectName string) error {
-	files, err := filepath.Glob(filepath.Join(projectPath, "*.jsonl"))
+func (p *JSONLParser) syncProjectLogs(ctx context.Context, projectPath, projectName string) error {
+	files, err := listLogFiles(projectPath)
 	if err != nil {
-		return fmt.Errorf("failed to glob jsonl files: %w", err)
+		return fmt.Errorf("failed to list jsonl files: %w", err)
 	}
 	
-	fmt.Printf("Found %d JSONL files in %s\n", len(files), projectPath)
-	
+	logger := logging.Component("sync")
 	for _, file := range files {
-		fmt.Printf("Processing file: %s\n", file)
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("sync canceled: %w", err)
+		}
 		if err := p.parseJSONLFile(file, projectName); err != nil {
-			fmt.Printf("Error parsing file %s: %v\n", file, err)
+			logger.Error("Failed to parse file", "file", file, "err", err)
 		}
 	}
 	
 	return nil
 }
 
+// parseJSONLFile parses the lines appended to a file since the last sync.
+// Gzip-compressed logs are decompressed as they are read.
 func (p *JSONLParser) parseJSONLFile(filePath, projectName string) error {
-	file, err := os.Open(filePath)
+	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	lastState, err := p.stateManager.GetFileState(filePath)
+	if err != nil {
+		return err
 	}
-	defer file.Close()
-	
-	fileName := filepath.Base(filePath)
-	scanner := bufio.NewScanner(file)
 	
-	// Increase buffer size to handle very long lines (up to 10MB)
-	const maxCapacity = 10 * 1024 * 1024 // 10MB
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
+	reader, err := openJSONLAt(filePath, resumePosition(lastState, fileInfo.Size()))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
 	
-	lineCount := 0
+	fileName := filepath.Base(filePath)
+	start := reader.Position().Line
 	processedCount := 0
 	
-	for scanner.Scan() {
-		lineCount++
-		line := strings.TrimSpace(scanner.Text())
+	for {
+		raw, ok, err := reader.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		lineCount := reader.Position().Line
+		line := strings.TrimSpace(string(raw))
 		if line == "" {
 			continue
 		}
 		
 		var entry models.LogEntry
 		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			fmt.Printf("Error unmarshaling line %d in file %s: %v\n", lineCount, fileName, err)
-			fmt.Printf("Problematic JSON line: %s\n", line)
+			logging.Component("sync").Warn("Failed to decode log entry", "file", filePath, "line", lineCount, "err", err)
 			continue
 		}
 		
-		if err := p.processLogEntry(&entry, projectName); err != nil {
-			fmt.Printf("Error processing log entry %d in file %s (UUID: %s, SessionID: %s): %v\n", lineCount, fileName, entry.UUID, entry.SessionID, err)
-			fmt.Printf("Entry details: %+v\n", entry)
-			continue
-		}
+		p.queueLogEntry(&entry, projectName)
 		processedCount++
+		
+		if p.batch.full() {
+			if err := p.writeBatch(); err != nil {
+				return fmt.Errorf("failed to write messages from %s: %w", fileName, err)
+			}
+		}
 	}
 	
-	fmt.Printf("Processed %d/%d lines from %s\n", processedCount, lineCount, filePath)
-	return scanner.Err()
+	// Aggregates are updated once per file rather than after every line
+	if err := p.writeBatch(); err != nil {
+		return fmt.Errorf("failed to write messages from %s: %w", fileName, err)
+	}
+	
+	end := reader.Position()
+	now := time.Now()
+	logging.Component("sync").Debug("Synced file", "file", filePath, "project", projectName, "lines", processedCount, "read", end.Line-start)
+	return p.stateManager.UpdateFileState(&models.FileProcessingState{
+		FilePath:            filePath,
+		LastModified:        fileInfo.ModTime(),
+		FileSize:            fileInfo.Size(),
+		LastProcessedLine:   end.Line,
+		LastProcessedOffset: end.Offset,
+		ProcessedUntil:      &now,
+		SyncStatus:          "completed",
+	})
 }
 
+// processLogEntry stores a single log entry and updates its aggregates
 func (p *JSONLParser) processLogEntry(entry *models.LogEntry, projectName string) error {
+	p.queueLogEntry(entry, projectName)
+	return p.writeBatch()
+}
+
+// queueLogEntry adds a log entry to the batch of the current file
+func (p *JSONLParser) queueLogEntry(entry *models.LogEntry, projectName string) {
 	// Use cwd from log entry if available, otherwise fall back to project name conversion
 	var actualProjectPath, actualProjectName string
 	if entry.Cwd != "" {
@@ -137,10 +190,6 @@ func (p *JSONLParser) processLogEntry(entry *models.LogEntry, projectName string
 		actualProjectName = projectName
 	}
 	
-	if err := p.sessionService.CreateOrUpdateSession(entry.SessionID, actualProjectName, actualProjectPath, entry.Timestamp); err != nil {
-		return fmt.Errorf("failed to create/update session: %w", err)
-	}
-	
 	message := &models.Message{
 		ID:          entry.UUID,
 		SessionID:   entry.SessionID,
@@ -154,9 +203,9 @@ func (p *JSONLParser) processLogEntry(entry *models.LogEntry, projectName string
 		RequestID:   entry.RequestID,
 	}
 	
-	if entry.Message.Content != nil {
+	if entry.Message.Content != nil && p.contentPolicy.Mode != ContentPolicyMetadata {
 		contentStr := p.convertContentToString(entry.Message.Content)
-		message.Content = &contentStr
+		message.Content = p.cipher.Seal(p.contentPolicy.Apply(contentStr))
 	}
 	
 	if entry.Message.Usage != nil {
@@ -165,72 +214,37 @@ func (p *JSONLParser) processLogEntry(entry *models.LogEntry, projectName string
 		message.CacheReadInputTokens = entry.Message.Usage.CacheReadInputTokens
 		message.OutputTokens = entry.Message.Usage.OutputTokens
 		message.ServiceTier = &entry.Message.Usage.ServiceTier
+		message.Billing = logEntryBilling(entry)
 	}
 	
-	// Get or create appropriate session window for this message
-	window, err := p.windowService.GetOrCreateWindowForMessage(entry.Timestamp)
+	p.batch.add(message, actualProjectName, actualProjectPath, "")
+	p.batch.addToolBlocks(entry)
+	p.batch.addLimitEvent(entry)
+}
+
+// writeBatch stores the queued messages and recalculates the statistics of
+// the windows and sessions they belong to
+func (p *JSONLParser) writeBatch() error {
+	sessionIDs, windowIDs, err := p.batch.write(p.db, p.windowService)
 	if err != nil {
-		return fmt.Errorf("failed to get/create session window: %w", err)
+		return err
 	}
-	message.SessionWindowID = &window.ID
-	
-	if err := p.insertMessage(message); err != nil {
-		return fmt.Errorf("failed to insert message: %w", err)
-	}
-
-	// Update window statistics after message insertion
-	if err := p.windowService.UpdateWindowStats(window.ID); err != nil {
-		return fmt.Errorf("failed to update window stats: %w", err)
+	for windowID := range windowIDs {
+		if err := p.windowService.UpdateWindowStats(windowID); err != nil {
+			return fmt.Errorf("failed to update window stats: %w", err)
+		}
 	}
-	
-	if err := p.tokenService.UpdateSessionTokens(entry.SessionID); err != nil {
-		return fmt.Errorf("failed to update session tokens: %w", err)
+	for _, sessionID := range sessionIDs {
+		if err := p.tokenService.UpdateSessionTokens(sessionID); err != nil {
+			return fmt.Errorf("failed to update session tokens: %w", err)
+		}
 	}
-	
 	return nil
 }
 
+// insertMessage upserts a single message
 func (p *JSONLParser) insertMessage(message *models.Message) error {
-	// Use INSERT OR REPLACE to handle both insert and update cases
-	upsertQuery := `
-		INSERT OR REPLACE INTO messages (
-			id, session_id, session_window_id, parent_uuid, is_sidechain, user_type, message_type,
-			message_role, model, content, input_tokens, cache_creation_input_tokens,
-			cache_read_input_tokens, output_tokens, service_tier, request_id,
-			timestamp, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`
-	
-	_, err := p.db.Exec(upsertQuery,
-		message.ID,
-		message.SessionID,
-		message.SessionWindowID,
-		message.ParentUUID,
-		message.IsSidechain,
-		message.UserType,
-		message.MessageType,
-		message.MessageRole,
-		message.Model,
-		message.Content,
-		message.InputTokens,
-		message.CacheCreationInputTokens,
-		message.CacheReadInputTokens,
-		message.OutputTokens,
-		message.ServiceTier,
-		message.RequestID,
-		message.Timestamp,
-		time.Now(),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to upsert message: %w", err)
-	}
-	
-	// Log successful upsert for debugging
-	if message.MessageRole != nil && *message.MessageRole == "assistant" {
-		fmt.Printf("Upserted assistant message: ID=%s, SessionID=%s, Timestamp=%s, Tokens=%d\n", 
-			message.ID, message.SessionID, message.Timestamp.Format(time.RFC3339), message.InputTokens+message.OutputTokens)
-	}
-	return nil
+	return insertMessages(p.db, []*models.Message{message})
 }
 
 func (p *JSONLParser) convertProjectNameToPath(projectName string) string {
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -35,7 +36,9 @@ func setupTestDBForJSONL(t *testing.T) (*sql.DB, *TokenService, *SessionService)
 			status TEXT DEFAULT 'active',
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			is_active BOOLEAN DEFAULT TRUE,
-			generated_code TEXT
+			generated_code TEXT,
+			title TEXT,
+			provider TEXT DEFAULT 'claude'
 		);
 
 		CREATE TABLE IF NOT EXISTS messages (
@@ -54,6 +57,9 @@ func setupTestDBForJSONL(t *testing.T) (*sql.DB, *TokenService, *SessionService)
 			output_tokens INTEGER DEFAULT 0,
 			service_tier TEXT,
 			request_id TEXT,
+			content_hash TEXT,
+			billing TEXT,
+			provider TEXT DEFAULT 'claude',
 			timestamp TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (session_id) REFERENCES sessions(id)
@@ -583,7 +589,7 @@ func TestSyncProjectLogs(t *testing.T) {
 	}
 
 	// Sync the project logs
-	err = parser.syncProjectLogs(tmpDir, "test-project")
+	err = parser.syncProjectLogs(context.Background(), tmpDir, "test-project")
 	if err != nil {
 		t.Fatalf("Failed to sync project logs: %v", err)
 	}
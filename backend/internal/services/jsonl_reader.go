@@ -0,0 +1,232 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"claudeee-backend/internal/models"
+)
+
+// compressedLogSuffix marks a gzip-compressed JSONL log, such as an old
+// session a user archived to save disk. It is read like the plain file.
+const compressedLogSuffix = ".jsonl.gz"
+
+// isLogFile reports whether name is a JSONL log, plain or compressed
+func isLogFile(name string) bool {
+	return strings.HasSuffix(name, ".jsonl") || strings.HasSuffix(name, compressedLogSuffix)
+}
+
+// isCompressedLog reports whether path is a gzip-compressed JSONL log
+func isCompressedLog(path string) bool {
+	return strings.HasSuffix(path, compressedLogSuffix)
+}
+
+// listLogFiles returns the JSONL logs in dir and the directories below it,
+// where archived logs may be kept
+func listLogFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && isLogFile(entry.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// openLogFile opens path for reading its lines, decompressing it if it is
+// a compressed log
+func openLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isCompressedLog(path) {
+		return file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, file}, nil
+}
+
+// readPosition is where a previous pass stopped reading a JSONL file
+type readPosition struct {
+	// Offset is the byte just past the last complete line read
+	Offset int64 `json:"offset"`
+	// Line is the number of lines read up to Offset
+	Line int `json:"line"`
+}
+
+// resumePosition returns where to continue reading a file from its stored
+// state. A file that has shrunk was rewritten and is read from the start.
+// Offsets into compressed logs count decompressed bytes, so their size
+// says nothing about them.
+func resumePosition(state *models.FileProcessingState, size int64) readPosition {
+	if state == nil {
+		return readPosition{}
+	}
+	if state.LastProcessedOffset > size && !isCompressedLog(state.FilePath) {
+		return readPosition{}
+	}
+	return readPosition{Offset: state.LastProcessedOffset, Line: state.LastProcessedLine}
+}
+
+// jsonlReader reads complete lines from a JSONL file. Claude appends to
+// session files while they are open, so a final line without a newline may
+// still be being written; it is only returned once it parses as JSON.
+type jsonlReader struct {
+	file *os.File
+	r    *bufio.Reader
+	pos  readPosition
+}
+
+// openJSONLAt opens path and positions the reader at pos. It starts from the
+// beginning instead when the file no longer ends a line at pos, i.e. it was
+// rewritten rather than appended to. A position with only a line count, saved
+// before offsets were tracked, is reached by skipping lines.
+func openJSONLAt(path string, pos readPosition) (*jsonlReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	reader := &jsonlReader{file: file}
+
+	if isCompressedLog(path) {
+		var offset int64
+		reader.r, offset, err = decompressAt(file, pos.Offset)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if offset != pos.Offset {
+			pos = readPosition{}
+		}
+	} else {
+		if pos.Offset > 0 && !endsLineAt(file, pos.Offset) {
+			pos = readPosition{}
+		}
+		if _, err := file.Seek(pos.Offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to seek file: %w", err)
+		}
+		reader.r = bufio.NewReaderSize(file, 64*1024)
+	}
+	if pos.Offset > 0 {
+		reader.pos = pos
+		return reader, nil
+	}
+
+	// Legacy state only knows the line count; skip that many lines
+	for reader.pos.Line < pos.Line {
+		if _, ok, err := reader.Next(); err != nil || !ok {
+			reader.Close()
+			if err != nil {
+				return nil, err
+			}
+			return openJSONLAt(path, readPosition{})
+		}
+	}
+	return reader, nil
+}
+
+// endsLineAt reports whether the byte before offset is a newline, which holds
+// for every offset stored after reading a complete line of an appended file
+func endsLineAt(file *os.File, offset int64) bool {
+	b := make([]byte, 1)
+	if _, err := file.ReadAt(b, offset-1); err != nil {
+		return false
+	}
+	return b[0] == '\n'
+}
+
+// decompressAt returns a reader of the decompressed content of file after
+// offset, and the offset it starts at: 0 when offset does not end a line.
+// Gzip streams cannot seek, so the content before offset is read and dropped.
+func decompressAt(file *os.File, offset int64) (*bufio.Reader, int64, error) {
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	r := bufio.NewReaderSize(gz, 64*1024)
+	if offset == 0 {
+		return r, 0, nil
+	}
+	if _, err := r.Discard(int(offset - 1)); err == nil {
+		if b, err := r.ReadByte(); err == nil && b == '\n' {
+			return r, offset, nil
+		}
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("failed to seek file: %w", err)
+	}
+	return decompressAt(file, 0)
+}
+
+// Next returns the next complete line. ok is false at the end of the file.
+func (r *jsonlReader) Next() (line []byte, ok bool, err error) {
+	line, err = r.r.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		if len(line) == 0 || !json.Valid(line) {
+			// Nothing left, or a line still being written; read it next pass
+			return nil, false, nil
+		}
+	} else if err != nil {
+		return nil, false, fmt.Errorf("failed to read file: %w", err)
+	}
+	r.pos.Offset += int64(len(line))
+	r.pos.Line++
+	return line, true, nil
+}
+
+// Position returns the position after the last line returned by Next
+func (r *jsonlReader) Position() readPosition {
+	return r.pos
+}
+
+func (r *jsonlReader) Close() error {
+	return r.file.Close()
+}
+
+// LogPosition is where a reader outside the sync service, such as the remote
+// agent, stopped reading a JSONL file
+type LogPosition = readPosition
+
+// ReadLogEntries parses the log entries of path after from and calls fn with
+// each chunk and the position after it. It returns the position after the
+// last chunk fn accepted; the lines after it are read again next time.
+func ReadLogEntries(path string, from LogPosition, fn func(entries []models.LogEntry, end LogPosition) error) (LogPosition, error) {
+	parse := startParse(path, from, func() {})
+	defer parse.stop()
+
+	end := from
+	for chunk := range parse.chunks {
+		if !chunk.opened {
+			return from, chunk.err
+		}
+		if err := fn(chunk.entries, chunk.end); err != nil {
+			return end, err
+		}
+		end = chunk.end
+		if chunk.err != nil {
+			return end, chunk.err
+		}
+	}
+	return end, nil
+}
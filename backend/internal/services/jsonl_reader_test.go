@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claudeee-backend/internal/models"
+)
+
+func readAllLines(t *testing.T, path string, start readPosition) ([]string, readPosition) {
+	t.Helper()
+	reader, err := openJSONLAt(path, start)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	defer reader.Close()
+
+	var lines []string
+	for {
+		line, ok, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Failed to read line: %v", err)
+		}
+		if !ok {
+			return lines, reader.Position()
+		}
+		lines = append(lines, string(line))
+	}
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONLReaderResumesFromOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	appendFile(t, path, "{\"n\":1}\n{\"n\":2}\n")
+
+	lines, pos := readAllLines(t, path, readPosition{})
+	if len(lines) != 2 || pos.Line != 2 || pos.Offset != 16 {
+		t.Fatalf("Expected 2 lines ending at offset 16, got %d lines at %+v", len(lines), pos)
+	}
+
+	// A line still being written is left for the next pass
+	appendFile(t, path, "{\"n\":3}\n{\"n\":")
+	lines, pos = readAllLines(t, path, pos)
+	if len(lines) != 1 || lines[0] != "{\"n\":3}\n" {
+		t.Errorf("Expected only the appended complete line, got %q", lines)
+	}
+	if pos.Line != 3 || pos.Offset != 24 {
+		t.Errorf("Expected position after line 3, got %+v", pos)
+	}
+
+	appendFile(t, path, "4}\n")
+	lines, pos = readAllLines(t, path, pos)
+	if len(lines) != 1 || lines[0] != "{\"n\":4}\n" || pos.Line != 4 {
+		t.Errorf("Expected the finished line 4, got %q at %+v", lines, pos)
+	}
+
+	// Nothing new to read
+	lines, _ = readAllLines(t, path, pos)
+	if len(lines) != 0 {
+		t.Errorf("Expected no lines, got %q", lines)
+	}
+}
+
+func TestJSONLReaderRestartsRewrittenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	appendFile(t, path, "{\"n\":1}\n{\"n\":2}\n")
+	_, pos := readAllLines(t, path, readPosition{})
+
+	// Same length, but the stored offset is no longer at a line boundary
+	if err := os.WriteFile(path, []byte("{\"n\":10}\n{\"n\":2}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lines, end := readAllLines(t, path, pos)
+	if len(lines) != 2 || end.Line != 2 {
+		t.Errorf("Expected the rewritten file to be read from the start, got %q at %+v", lines, end)
+	}
+
+	// A file that shrank below the stored offset starts over too
+	state := &models.FileProcessingState{LastProcessedOffset: 100, LastProcessedLine: 5}
+	if got := resumePosition(state, 16); got != (readPosition{}) {
+		t.Errorf("Expected to restart a truncated file, got %+v", got)
+	}
+	if got := resumePosition(nil, 16); got != (readPosition{}) {
+		t.Errorf("Expected new files to start at 0, got %+v", got)
+	}
+}
+
+func TestJSONLReaderResumesLegacyLineState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	appendFile(t, path, "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n")
+
+	// State saved before offsets were tracked has only a line count
+	state := &models.FileProcessingState{LastProcessedLine: 2}
+	lines, pos := readAllLines(t, path, resumePosition(state, 24))
+	if len(lines) != 1 || lines[0] != "{\"n\":3}\n" {
+		t.Errorf("Expected only line 3, got %q", lines)
+	}
+	if pos.Line != 3 || pos.Offset != 24 {
+		t.Errorf("Expected position after line 3, got %+v", pos)
+	}
+}
+
+func writeGzip(t testing.TB, path, data string) {
+	t.Helper()
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	if _, err := gz.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONLReaderReadsCompressedLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl.gz")
+	writeGzip(t, path, "{\"n\":1}\n{\"n\":2}\n")
+
+	lines, pos := readAllLines(t, path, readPosition{})
+	if len(lines) != 2 || pos.Line != 2 || pos.Offset != 16 {
+		t.Fatalf("Expected 2 lines ending at decompressed offset 16, got %d lines at %+v", len(lines), pos)
+	}
+
+	// Offsets count decompressed bytes, so resuming past the file size works
+	writeGzip(t, path, "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n")
+	state := &models.FileProcessingState{FilePath: path, LastProcessedOffset: pos.Offset, LastProcessedLine: pos.Line}
+	lines, pos = readAllLines(t, path, resumePosition(state, 1))
+	if len(lines) != 1 || lines[0] != "{\"n\":3}\n" || pos.Line != 3 {
+		t.Errorf("Expected only the new line, got %q at %+v", lines, pos)
+	}
+
+	// A recompressed file with other content is read from the start
+	writeGzip(t, path, "{\"longer\":1}\n")
+	lines, _ = readAllLines(t, path, readPosition{Offset: 16, Line: 2})
+	if len(lines) != 1 || lines[0] != "{\"longer\":1}\n" {
+		t.Errorf("Expected the rewritten file from the start, got %q", lines)
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+const (
+	// LimitEventUsage is Claude's usage limit for the window being reached
+	LimitEventUsage = "usage_limit"
+	// LimitEventRateLimit is a 429 response from the API
+	LimitEventRateLimit = "rate_limit"
+)
+
+// maxLimitEventMessageLength bounds the error text kept with an event
+const maxLimitEventMessageLength = 500
+
+var (
+	// usageLimitResetPattern matches the notice of older Claude Code
+	// versions, which carries the reset as a Unix time
+	usageLimitResetPattern = regexp.MustCompile(`(?i)usage limit reached\|(\d+)`)
+	// resetClockPattern matches "resets 3pm" or "reset at 3:30pm (Asia/Tokyo)"
+	resetClockPattern = regexp.MustCompile(`(?i)resets?(?:\s+at)?\s+(\d{1,2})(?::(\d{2}))?\s*(am|pm)(?:\s*\(([^)]+)\))?`)
+)
+
+// LimitEvent is a usage or rate limit Claude Code reported in a session log
+type LimitEvent struct {
+	// ID is the UUID of the log entry
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id"`
+	Kind      string    `json:"kind"`
+	Timestamp time.Time `json:"timestamp"`
+	// ResetAt is when the limit lifts, if the notice said
+	ResetAt *time.Time `json:"reset_at"`
+	Message string     `json:"message"`
+}
+
+// LimitEventReport summarizes the limits hit
+type LimitEventReport struct {
+	Count int64 `json:"count"`
+	// LastHitAt is the last time a usage limit was reached
+	LastHitAt *time.Time   `json:"last_hit_at"`
+	Recent    []LimitEvent `json:"recent"`
+}
+
+// detectLimitEvent returns the limit an entry reports, if it is an API error
+// about one
+func detectLimitEvent(entry *models.LogEntry) (LimitEvent, bool) {
+	text := strings.TrimSpace(limitEventText(entry.Message.Content))
+	if !entry.IsAPIErrorMessage && !strings.HasPrefix(text, "API Error: 429") {
+		return LimitEvent{}, false
+	}
+
+	event := LimitEvent{ID: entry.UUID, SessionID: entry.SessionID, Timestamp: entry.Timestamp}
+	lower := strings.ToLower(text)
+	switch {
+	case strings.Contains(lower, "limit reached") || strings.Contains(lower, "usage limit"):
+		event.Kind = LimitEventUsage
+		if m := usageLimitResetPattern.FindStringSubmatch(text); m != nil {
+			if unix, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+				reset := time.Unix(unix, 0).UTC()
+				event.ResetAt = &reset
+			}
+			text = strings.TrimSpace(text[:strings.Index(text, "|")])
+		} else {
+			event.ResetAt = parseResetClock(text, entry.Timestamp)
+		}
+	case strings.Contains(text, "429") || strings.Contains(lower, "rate_limit"):
+		event.Kind = LimitEventRateLimit
+	default:
+		return LimitEvent{}, false
+	}
+	if runes := []rune(text); len(runes) > maxLimitEventMessageLength {
+		text = string(runes[:maxLimitEventMessageLength])
+	}
+	event.Message = text
+	return event, true
+}
+
+// limitEventText returns the text of a message's content
+func limitEventText(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var parts []string
+		for _, block := range c {
+			if m, ok := block.(map[string]interface{}); ok {
+				if text, ok := m["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// parseResetClock reads a reset time of day from a limit notice and returns
+// its first occurrence after at. Without a named zone the clock is taken to
+// be local, where Claude Code printed it.
+func parseResetClock(text string, at time.Time) *time.Time {
+	m := resetClockPattern.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	hour, _ := strconv.Atoi(m[1])
+	minute, _ := strconv.Atoi(m[2])
+	if hour < 1 || hour > 12 || minute > 59 {
+		return nil
+	}
+	if hour == 12 {
+		hour = 0
+	}
+	if strings.EqualFold(m[3], "pm") {
+		hour += 12
+	}
+	loc := time.Local
+	if m[4] != "" {
+		if named, err := time.LoadLocation(m[4]); err == nil {
+			loc = named
+		}
+	}
+
+	local := at.In(loc)
+	reset := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if !reset.After(at) {
+		reset = reset.AddDate(0, 0, 1)
+	}
+	reset = reset.UTC()
+	return &reset
+}
+
+// recordLimitEvents stores limit events within tx and moves the reset time of
+// the windows they happened in to the reset they reported
+func recordLimitEvents(tx *sql.Tx, events []LimitEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	for _, e := range events {
+		_, err := tx.Exec(`
+			INSERT INTO limit_events (id, session_id, kind, timestamp, reset_at, message)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING
+		`, e.ID, e.SessionID, e.Kind, e.Timestamp, e.ResetAt, e.Message)
+		if err != nil {
+			return fmt.Errorf("failed to record limit event %s: %w", e.ID, err)
+		}
+	}
+
+	return calibrateWindowResets(tx)
+}
+
+// calibrateWindowResets sets the reset time of each window in which a limit
+// was hit to the latest reset Claude reported during it
+func calibrateWindowResets(q sqlExecutor) error {
+	_, err := q.Exec(`
+		UPDATE session_windows SET reset_time = (
+			SELECT e.reset_at FROM limit_events e
+			WHERE e.reset_at IS NOT NULL AND e.reset_at > e.timestamp
+				AND e.timestamp >= session_windows.window_start AND e.timestamp < session_windows.window_end
+			ORDER BY e.timestamp DESC
+			LIMIT 1
+		)
+		WHERE EXISTS (
+			SELECT 1 FROM limit_events e
+			WHERE e.reset_at IS NOT NULL AND e.reset_at > e.timestamp
+				AND e.timestamp >= session_windows.window_start AND e.timestamp < session_windows.window_end
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to calibrate window resets: %w", err)
+	}
+	return nil
+}
+
+// GetLimitEvents returns how many limits were hit, when a usage limit was
+// last reached and the latest limit events
+func GetLimitEvents(db *sql.DB, limit int) (*LimitEventReport, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	report := &LimitEventReport{Recent: []LimitEvent{}}
+	var lastHit sql.NullTime
+	err := db.QueryRow(`
+		SELECT COUNT(*), MAX(CASE WHEN kind = ? THEN timestamp END) FROM limit_events
+	`, LimitEventUsage).Scan(&report.Count, &lastHit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize limit events: %w", err)
+	}
+	if lastHit.Valid {
+		report.LastHitAt = &lastHit.Time
+	}
+
+	rows, err := db.Query(`
+		SELECT id, session_id, kind, timestamp, reset_at, message
+		FROM limit_events
+		ORDER BY timestamp DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get limit events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e LimitEvent
+		var resetAt sql.NullTime
+		if err := rows.Scan(&e.ID, &e.SessionID, &e.Kind, &e.Timestamp, &resetAt, &e.Message); err != nil {
+			return nil, fmt.Errorf("failed to scan limit event: %w", err)
+		}
+		if resetAt.Valid {
+			e.ResetAt = &resetAt.Time
+		}
+		report.Recent = append(report.Recent, e)
+	}
+	return report, rows.Err()
+}
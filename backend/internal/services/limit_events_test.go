@@ -0,0 +1,94 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/models"
+)
+
+func TestDetectLimitEvent(t *testing.T) {
+	at := time.Date(2024, 1, 1, 10, 20, 0, 0, time.UTC)
+	entry := func(apiError bool, content interface{}) *models.LogEntry {
+		return &models.LogEntry{UUID: "e", SessionID: "s", Timestamp: at, IsAPIErrorMessage: apiError,
+			Message: models.LogMessage{Role: "assistant", Content: content}}
+	}
+	text := func(s string) interface{} {
+		return []interface{}{map[string]interface{}{"type": "text", "text": s}}
+	}
+	tests := []struct {
+		name    string
+		entry   *models.LogEntry
+		kind    string
+		resetAt time.Time
+	}{
+		{"unix reset", entry(true, text("Claude AI usage limit reached|1704117600")), LimitEventUsage, time.Unix(1704117600, 0)},
+		{"clock reset", entry(true, text("5-hour limit reached ∙ resets 3pm (Asia/Tokyo)")), LimitEventUsage, time.Date(2024, 1, 2, 6, 0, 0, 0, time.UTC)},
+		{"rate limit", entry(true, "API Error: 429 {\"type\":\"error\",\"error\":{\"type\":\"rate_limit_error\"}}"), LimitEventRateLimit, time.Time{}},
+		{"other API error", entry(true, text("API Error: 500 Internal server error")), "", time.Time{}},
+		{"ordinary message", entry(false, text("We hit the usage limit reached yesterday")), "", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, ok := detectLimitEvent(tt.entry)
+			if ok != (tt.kind != "") || event.Kind != tt.kind {
+				t.Fatalf("Expected kind %q, got %+v (%v)", tt.kind, event, ok)
+			}
+			if tt.resetAt.IsZero() != (event.ResetAt == nil) || event.ResetAt != nil && !event.ResetAt.Equal(tt.resetAt) {
+				t.Errorf("Expected reset at %v, got %v", tt.resetAt, event.ResetAt)
+			}
+			if ok && strings.Contains(event.Message, "|") {
+				t.Errorf("Expected the notice without its timestamp, got %q", event.Message)
+			}
+		})
+	}
+}
+
+func TestLimitEventsCalibrateWindowReset(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	diffSync := NewDiffSyncService(db, NewTokenService(db), NewSessionService(db))
+
+	path := filepath.Join(t.TempDir(), "-work-app", "s1.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{
+		`{"uuid":"1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:20:00Z","message":{"role":"user","content":"hi"}}`,
+		`{"uuid":"2","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T12:00:00Z","isApiErrorMessage":true,"message":{"role":"assistant","model":"<synthetic>","content":[{"type":"text","text":"Claude AI usage limit reached|1704117600"}]}}`,
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := diffSync.processFileFromLine(path, 0); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+
+	// The window opened at 10:20 would reset at 15:00; Claude said 14:00
+	var resetTime time.Time
+	if err := db.QueryRow(`SELECT reset_time FROM session_windows`).Scan(&resetTime); err != nil {
+		t.Fatalf("Failed to get window: %v", err)
+	}
+	if want := time.Unix(1704117600, 0); !resetTime.Equal(want) {
+		t.Errorf("Expected reset time %v, got %v", want.UTC(), resetTime)
+	}
+
+	report, err := GetLimitEvents(db, 10)
+	if err != nil {
+		t.Fatalf("GetLimitEvents failed: %v", err)
+	}
+	if report.Count != 1 || report.LastHitAt == nil || len(report.Recent) != 1 || report.Recent[0].ID != "2" {
+		t.Errorf("Expected the usage limit event, got %+v", report)
+	}
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+)
+
+// SetFlagMissingSources controls whether sessions whose log files were all
+// deleted are flagged with source_missing_at
+func (d *DiffSyncService) SetFlagMissingSources(enabled bool) {
+	d.flagMissingSources = enabled
+}
+
+// recordSessionSources remembers which sessions a log file holds, and clears
+// the flag of sessions whose file came back
+func (d *DiffSyncService) recordSessionSources(filePath string, sessionIDs map[string]struct{}) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for sessionID := range sessionIDs {
+		_, err := tx.Exec(`
+			INSERT INTO session_sources (session_id, file_path) VALUES (?, ?)
+			ON CONFLICT (session_id, file_path) DO NOTHING
+		`, sessionID, filePath)
+		if err != nil {
+			return fmt.Errorf("failed to record source of session %s: %w", sessionID, err)
+		}
+		_, err = tx.Exec(`UPDATE sessions SET source_missing_at = NULL WHERE id = ? AND source_missing_at IS NOT NULL`, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to clear missing source of session %s: %w", sessionID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit session sources: %w", err)
+	}
+	return nil
+}
+
+// reconcileLogFiles drops the sync state of log files that were deleted and
+// follows files that were renamed. Sessions left without any log file are
+// flagged when enabled. It returns the number of files removed.
+func (d *DiffSyncService) reconcileLogFiles(files []models.FileInfo) (int, error) {
+	moved, removed, err := d.stateManager.ReconcileFiles(files)
+	if err != nil {
+		return 0, err
+	}
+
+	logger := logging.Component("sync")
+	for from, to := range moved {
+		for _, table := range []string{"session_sources", "parse_errors"} {
+			if _, err := d.db.Exec(`UPDATE `+table+` SET file_path = ? WHERE file_path = ?`, to, from); err != nil {
+				return len(removed), fmt.Errorf("failed to move %s of %s: %w", table, from, err)
+			}
+		}
+		logger.Info("Log file was renamed", "from", from, "to", to)
+	}
+
+	now := time.Now()
+	for _, path := range removed {
+		flagged, err := d.forgetLogFile(path, now)
+		if err != nil {
+			return len(removed), err
+		}
+		logger.Info("Log file was deleted", "file", path, "flagged_sessions", flagged)
+	}
+	return len(removed), nil
+}
+
+// forgetLogFile removes what is kept about a deleted log file and returns how
+// many sessions were flagged because it was their last file
+func (d *DiffSyncService) forgetLogFile(path string, now time.Time) (int, error) {
+	rows, err := d.db.Query(`SELECT session_id FROM session_sources WHERE file_path = ?`, path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sessions of %s: %w", path, err)
+	}
+	var sessionIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan session id: %w", err)
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get sessions of %s: %w", path, err)
+	}
+	if len(sessionIDs) == 0 {
+		// Synced before sources were recorded; Claude names a session's file after it
+		sessionIDs = []string{strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+	}
+
+	for _, table := range []string{"session_sources", "parse_errors"} {
+		if _, err := d.db.Exec(`DELETE FROM `+table+` WHERE file_path = ?`, path); err != nil {
+			return 0, fmt.Errorf("failed to remove %s of %s: %w", table, path, err)
+		}
+	}
+	if !d.flagMissingSources {
+		return 0, nil
+	}
+
+	flagged := 0
+	for _, sessionID := range sessionIDs {
+		result, err := d.db.Exec(`
+			UPDATE sessions SET source_missing_at = ?
+			WHERE id = ? AND source_missing_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM session_sources WHERE session_id = ?)
+		`, now, sessionID, sessionID)
+		if err != nil {
+			return flagged, fmt.Errorf("failed to flag session %s: %w", sessionID, err)
+		}
+		n, _ := result.RowsAffected()
+		flagged += int(n)
+	}
+	return flagged, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncFollowsRotatedAndDeletedFiles(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "-work-app")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		// Write next to the target and rename over it, as log rotation does
+		tmp := filepath.Join(root, name+".tmp")
+		if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, filepath.Join(project, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	line := func(uuid, session string) string {
+		return `{"uuid":"` + uuid + `","sessionId":"` + session + `","cwd":"/work/app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"hi"}}` + "\n"
+	}
+	write("s1.jsonl", line("a", "s1"))
+	write("s2.jsonl", line("b", "s2"))
+
+	db, diffSync := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+	diffSync.SetLogSources(LogSourceConfig{Roots: []string{root}})
+	sync := func() {
+		t.Helper()
+		if _, err := diffSync.SyncAllLogs(context.Background()); err != nil {
+			t.Fatalf("SyncAllLogs failed: %v", err)
+		}
+	}
+	sync()
+
+	// A renamed file keeps its position under the new name
+	if err := os.Rename(filepath.Join(project, "s1.jsonl"), filepath.Join(project, "s1-old.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := diffSync.SyncAllLogs(context.Background())
+	if err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+	if stats.NewLines != 0 || stats.RemovedFiles != 0 {
+		t.Errorf("Expected the renamed file to be recognized, got %+v", stats)
+	}
+	if state, err := diffSync.stateManager.GetFileState(filepath.Join(project, "s1-old.jsonl")); err != nil || state == nil {
+		t.Errorf("Expected the state under the new name, got %v (%v)", state, err)
+	}
+
+	// A file replaced by one with the same length is still read from the start
+	write("s2.jsonl", line("c", "s2"))
+	sync()
+	var messages int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM messages WHERE session_id = 's2'`).Scan(&messages); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if messages != 2 {
+		t.Errorf("Expected the replaced file's message to be synced, got %d messages", messages)
+	}
+
+	// Deleting a session's only file flags the session
+	if err := os.Remove(filepath.Join(project, "s2.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = diffSync.SyncAllLogs(context.Background())
+	if err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+	if stats.RemovedFiles != 1 {
+		t.Errorf("Expected one removed file, got %+v", stats)
+	}
+	missing := func(session string) bool {
+		var at sql.NullTime
+		if err := db.QueryRow(`SELECT source_missing_at FROM sessions WHERE id = ?`, session).Scan(&at); err != nil {
+			t.Fatalf("Failed to get session: %v", err)
+		}
+		return at.Valid
+	}
+	if !missing("s2") || missing("s1") {
+		t.Errorf("Expected only s2 flagged, got s1 %v, s2 %v", missing("s1"), missing("s2"))
+	}
+
+	// The flag clears when the file comes back
+	write("s2.jsonl", line("b", "s2"))
+	sync()
+	if missing("s2") {
+		t.Error("Expected the restored file to clear the flag")
+	}
+}
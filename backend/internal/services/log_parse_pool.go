@@ -0,0 +1,218 @@
+package services
+
+import (
+	"runtime"
+	"strings"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+)
+
+const (
+	// parseChunkLines is the number of log entries parsed before they are
+	// handed to the writer
+	parseChunkLines = 500
+	// parseChunkBuffer is how many chunks a parser may get ahead of the writer
+	parseChunkBuffer = 2
+	// parseFilesAhead is how many parsed files per worker may wait for the
+	// writer, which bounds memory when many small files parse quickly
+	parseFilesAhead = 4
+)
+
+// DefaultSyncWorkers is the number of log files parsed at once when no
+// worker count is configured
+func DefaultSyncWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// logChunk is a run of parsed log entries from one file
+type logChunk struct {
+	entries []models.LogEntry
+	// lines holds the line number of each entry
+	lines []int
+	// parseErrors are the lines of the chunk that could not be parsed
+	parseErrors []lineError
+	// end is the position after the last line read for this chunk
+	end readPosition
+	// err is set on the last chunk when the file could not be opened or read
+	err error
+	// opened is false when the file could not be opened at all
+	opened bool
+}
+
+// fileParse is a file whose lines are parsed in the background while earlier
+// files are written
+type fileParse struct {
+	chunks chan logChunk
+	// done is closed by the reader of chunks when it stops early
+	done chan struct{}
+}
+
+// startParse begins parsing the Claude log filePath after start in a new
+// goroutine, which calls release when it exits
+func startParse(filePath string, start readPosition, release func()) *fileParse {
+	return startDecode(filePath, claudeDecoder{}, start, release)
+}
+
+// startDecode is startParse for a log decoded by decoder
+func startDecode(filePath string, decoder LogDecoder, start readPosition, release func()) *fileParse {
+	p := &fileParse{
+		chunks: make(chan logChunk, parseChunkBuffer),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer release()
+		parseLogChunks(filePath, decoder, start, p.chunks, p.done)
+	}()
+	return p
+}
+
+// stop releases the parser goroutine when the chunks are not read to the end
+func (p *fileParse) stop() {
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+}
+
+// parseLogChunks reads the log entries of a file after start and sends them
+// in chunks. It touches no shared state, so files are parsed concurrently;
+// the last chunk carries the end position and any read error.
+func parseLogChunks(filePath string, decoder LogDecoder, start readPosition, out chan<- logChunk, done <-chan struct{}) {
+	defer close(out)
+
+	send := func(chunk logChunk) bool {
+		select {
+		case out <- chunk:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	reader, err := openJSONLAt(filePath, start)
+	if err != nil {
+		send(logChunk{end: start, err: err})
+		return
+	}
+	defer reader.Close()
+	if context, ok := decoder.(LogContext); ok {
+		if err := observeUntil(filePath, reader.Position(), context); err != nil {
+			send(logChunk{end: start, err: err})
+			return
+		}
+	}
+
+	chunk := logChunk{opened: true}
+	// flush sends the chunk once it holds enough entries, or enough
+	// malformed lines that their quarantined text should not pile up
+	flush := func() bool {
+		if len(chunk.entries) < parseChunkLines && len(chunk.parseErrors) < parseChunkLines {
+			return true
+		}
+		chunk.end = reader.Position()
+		if !send(chunk) {
+			return false
+		}
+		chunk = logChunk{opened: true}
+		return true
+	}
+	for {
+		raw, ok, err := reader.Next()
+		if err != nil {
+			chunk.err = err
+			break
+		}
+		if !ok {
+			break
+		}
+		lineCount := reader.Position().Line
+		line := strings.TrimSpace(string(raw))
+		if line == "" {
+			continue
+		}
+
+		entries, err := decoder.Decode([]byte(line))
+		if err != nil {
+			logging.Component("sync").Warn("Failed to parse log line", "file", filePath, "line", lineCount, "err", err)
+			chunk.parseErrors = append(chunk.parseErrors, newLineError(lineCount, err, line))
+			if !flush() {
+				return
+			}
+			continue
+		}
+		for _, entry := range entries {
+			chunk.entries = append(chunk.entries, entry)
+			chunk.lines = append(chunk.lines, lineCount)
+		}
+
+		if !flush() {
+			return
+		}
+	}
+	chunk.end = reader.Position()
+	send(chunk)
+}
+
+// observeUntil passes the lines of filePath before pos to context
+func observeUntil(filePath string, pos readPosition, context LogContext) error {
+	if pos == (readPosition{}) {
+		return nil
+	}
+	reader, err := openJSONLAt(filePath, readPosition{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	for reader.Position().Line < pos.Line {
+		line, ok, err := reader.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		context.Observe(line)
+	}
+	return nil
+}
+
+// fileCheck is a discovered file with its stored state
+type fileCheck struct {
+	file models.FileInfo
+	// decoder reads the lines of the file's source
+	decoder   LogDecoder
+	lastState *models.FileProcessingState
+	needsSync bool
+	err       error
+	// parse is set for files that need syncing
+	parse *fileParse
+}
+
+// startParsers parses the files that need syncing, up to workers at a time,
+// and returns the checks in discovery order. Whoever receives a check must
+// read its chunks to the end or stop it.
+func startParsers(checks []*fileCheck, workers int) <-chan *fileCheck {
+	if workers < 1 {
+		workers = 1
+	}
+	slots := make(chan struct{}, workers)
+	release := func() { <-slots }
+	ordered := make(chan *fileCheck, workers*parseFilesAhead)
+	go func() {
+		defer close(ordered)
+		for _, check := range checks {
+			if check.err == nil && check.needsSync {
+				slots <- struct{}{}
+				decoder := check.decoder
+				if decoder == nil {
+					decoder = claudeDecoder{}
+				}
+				check.parse = startDecode(check.file.Path, decoder, resumePosition(check.lastState, check.file.Size), release)
+			}
+			ordered <- check
+		}
+	}()
+	return ordered
+}
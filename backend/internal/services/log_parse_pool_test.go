@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// writeProjectLogs writes files log files with lines messages each under
+// separate projects in root
+func writeProjectLogs(t testing.TB, root string, files, lines int) {
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for f := 0; f < files; f++ {
+		var b strings.Builder
+		for i := 0; i < lines; i++ {
+			fmt.Fprintf(&b, `{"uuid":"f%d-m%d","sessionId":"session-%d","userType":"external","cwd":"/work/p%d","timestamp":"%s","message":{"role":"assistant","model":"claude-sonnet-4-20250514","content":"m","usage":{"input_tokens":10,"output_tokens":5}}}`+"\n",
+				f, i, f, f, base.Add(time.Duration(f*lines+i)*time.Second).Format(time.RFC3339))
+		}
+		if f == 0 {
+			b.WriteString("not json\n")
+		}
+		path := filepath.Join(root, fmt.Sprintf("-work-p%d", f/2), fmt.Sprintf("s%d.jsonl", f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseLogChunks(t *testing.T) {
+	root := t.TempDir()
+	writeProjectLogs(t, root, 1, parseChunkLines+10)
+	path := filepath.Join(root, "-work-p0", "s0.jsonl")
+
+	var chunks []logChunk
+	for chunk := range startParse(path, readPosition{}, func() {}).chunks {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 2 || len(chunks[0].entries) != parseChunkLines || len(chunks[1].entries) != 10 {
+		t.Fatalf("Expected a full chunk and 10 entries, got %d chunks", len(chunks))
+	}
+	if len(chunks[1].parseErrors) != 1 || chunks[1].end.Line != parseChunkLines+11 || chunks[0].end.Line != parseChunkLines {
+		t.Errorf("Unexpected chunk ends: %+v %+v", chunks[0].end, chunks[1].end)
+	}
+	if bad := chunks[1].parseErrors[0]; bad.line != parseChunkLines+11 || bad.raw == "" || bad.err == "" {
+		t.Errorf("Expected the malformed last line with its text, got %+v", bad)
+	}
+
+	// A missing file ends with an unopened chunk
+	chunk := <-startParse(filepath.Join(root, "missing.jsonl"), readPosition{}, func() {}).chunks
+	if chunk.opened || chunk.err == nil {
+		t.Errorf("Expected an open error, got %+v", chunk)
+	}
+
+	// Stopping early releases the parser
+	released := make(chan struct{})
+	parse := startParse(path, readPosition{}, func() { close(released) })
+	<-parse.chunks
+	parse.stop()
+	select {
+	case <-released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Parser did not stop")
+	}
+}
+
+func TestSyncAllLogsWithWorkers(t *testing.T) {
+	root := t.TempDir()
+	writeProjectLogs(t, root, 4, 600)
+
+	for _, workers := range []int{1, 4} {
+		db, diffSyncService := setupTestDBForDiffSync(t)
+		addSessionWindowTables(t, db)
+		diffSyncService.SetLogSources(LogSourceConfig{Roots: []string{root}})
+		diffSyncService.SetWorkers(workers)
+
+		var files []string
+		diffSyncService.SetProgress(func(p SyncProgress) {
+			if p.Type == SyncProgressFile {
+				files = append(files, filepath.Base(p.File))
+			}
+		})
+		stats, err := diffSyncService.SyncAllLogs(context.Background())
+		if err != nil {
+			t.Fatalf("SyncAllLogs with %d workers failed: %v", workers, err)
+		}
+		if stats.ProcessedFiles != 4 || stats.NewLines != 4*600 || stats.ParseErrors != 1 {
+			t.Errorf("Unexpected stats with %d workers: %+v", workers, stats)
+		}
+		// Files are written in discovery order whatever the worker count
+		if strings.Join(files, ",") != "s0.jsonl,s1.jsonl,s2.jsonl,s3.jsonl" {
+			t.Errorf("Unexpected file order with %d workers: %v", workers, files)
+		}
+
+		var messages, tokens int
+		if err := db.QueryRow("SELECT COUNT(*), SUM(input_tokens + output_tokens) FROM messages").Scan(&messages, &tokens); err != nil {
+			t.Fatalf("Failed to count messages: %v", err)
+		}
+		if messages != 4*600 || tokens != 4*600*15 {
+			t.Errorf("Expected %d messages with %d tokens, got %d with %d", 4*600, 4*600*15, messages, tokens)
+		}
+
+		// A second pass adds nothing
+		stats, err = diffSyncService.SyncAllLogs(context.Background())
+		if err != nil || stats.NewLines != 0 {
+			t.Errorf("Expected no new lines, got %+v (%v)", stats, err)
+		}
+		db.Close()
+	}
+}
+
+func TestSyncCancellationKeepsProgress(t *testing.T) {
+	root := t.TempDir()
+	writeProjectLogs(t, root, 3, 20)
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+	diffSyncService.SetLogSources(LogSourceConfig{Roots: []string{root}})
+
+	// Cancel once the first file is written
+	ctx, cancel := context.WithCancel(context.Background())
+	diffSyncService.SetProgress(func(p SyncProgress) {
+		if p.Type == SyncProgressFile {
+			cancel()
+		}
+	})
+	stats, err := diffSyncService.SyncAllLogs(ctx)
+	if !errors.Is(err, context.Canceled) || stats.ProcessedFiles != 1 || stats.NewLines != 20 {
+		t.Fatalf("Expected a canceled pass after one file, got %+v (%v)", stats, err)
+	}
+
+	diffSyncService.SetProgress(nil)
+	if _, err := diffSyncService.SyncAllLogs(context.Background()); err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&count); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if count != 60 {
+		t.Errorf("Expected 60 messages after resuming, got %d", count)
+	}
+}
+
+func TestSyncParsedFileStopsAtChunkBoundary(t *testing.T) {
+	root := t.TempDir()
+	writeProjectLogs(t, root, 1, 10)
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+
+	path := filepath.Join(root, "-work-p0", "s0.jsonl")
+	first := <-startParse(path, readPosition{}, func() {}).chunks
+
+	// Cancel once the first chunk is applied; the last is never applied. The
+	// empty chunk is only taken after the first one is written.
+	ctx, cancel := context.WithCancel(context.Background())
+	parse := &fileParse{chunks: make(chan logChunk), done: make(chan struct{})}
+	go func() {
+		defer close(parse.chunks)
+		parse.chunks <- first
+		parse.chunks <- logChunk{opened: true, end: first.end}
+		cancel()
+		select {
+		case parse.chunks <- logChunk{opened: true, end: readPosition{Offset: first.end.Offset + 100, Line: 99}}:
+		case <-parse.done:
+		}
+	}()
+
+	file := models.FileInfo{Path: path, Size: first.end.Offset, ModTime: time.Now()}
+	lines, err := diffSyncService.syncParsedFile(ctx, file, readPosition{}, parse)
+	if !errors.Is(err, context.Canceled) || lines != 10 {
+		t.Fatalf("Expected 10 lines before the cancel, got %d (%v)", lines, err)
+	}
+
+	state, err := diffSyncService.stateManager.GetFileState(path)
+	if err != nil || state == nil {
+		t.Fatalf("Failed to get file state: %v", err)
+	}
+	if state.SyncStatus != "processing" || state.LastProcessedOffset != first.end.Offset {
+		t.Errorf("Expected progress saved after the first chunk, got %+v", state)
+	}
+}
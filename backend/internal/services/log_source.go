@@ -0,0 +1,133 @@
+package services
+
+import (
+	"encoding/json"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+)
+
+// Tools whose session logs are synced
+const (
+	ProviderClaude = "claude"
+	ProviderCodex  = "codex"
+	ProviderGemini = "gemini"
+)
+
+// LogSource is a tool whose session logs are synced into the sessions and
+// messages tables
+type LogSource interface {
+	// Provider names the tool; it is stored with its sessions and messages
+	Provider() string
+	// Discover lists the source's log files
+	Discover() ([]models.FileInfo, error)
+	// NewDecoder returns a decoder for the lines of the log file at path
+	NewDecoder(path string) LogDecoder
+}
+
+// LogDecoder turns the lines of one log file into log entries
+type LogDecoder interface {
+	// Decode returns the entries of a line, none for lines that hold no
+	// message. An error marks the line as malformed.
+	Decode(line []byte) ([]models.LogEntry, error)
+}
+
+// LogContext is implemented by decoders whose entries depend on earlier
+// lines of the file, such as a header naming the session. When a file is
+// read from a resume position, the lines before it go to Observe first.
+type LogContext interface {
+	Observe(line []byte)
+}
+
+// LogSources returns the sources of c: Claude's projects directories and,
+// when configured, Codex CLI's sessions directories and Gemini CLI's
+// telemetry
+func (c LogSourceConfig) LogSources() []LogSource {
+	sources := []LogSource{claudeLogSource{config: c}}
+	if len(c.CodexDirs) > 0 {
+		sources = append(sources, codexLogSource{dirs: c.CodexDirs})
+	}
+	if len(c.GeminiLogs) > 0 {
+		sources = append(sources, geminiLogSource{paths: c.GeminiLogs})
+	}
+	return sources
+}
+
+// claudeLogSource reads Claude Code's project logs
+type claudeLogSource struct {
+	config LogSourceConfig
+}
+
+func (s claudeLogSource) Provider() string { return ProviderClaude }
+
+func (s claudeLogSource) Discover() ([]models.FileInfo, error) {
+	return DiscoverLogFiles(s.config)
+}
+
+func (s claudeLogSource) NewDecoder(path string) LogDecoder {
+	return claudeDecoder{}
+}
+
+// claudeDecoder reads Claude Code's log lines, each a complete entry
+type claudeDecoder struct{}
+
+func (claudeDecoder) Decode(line []byte) ([]models.LogEntry, error) {
+	// First, try to parse as a basic JSON to check if it has required fields
+	var basicCheck map[string]interface{}
+	if err := json.Unmarshal(line, &basicCheck); err != nil {
+		return nil, err
+	}
+
+	// Check if this looks like a LogEntry (has sessionId and timestamp)
+	sessionId, hasSessionId := basicCheck["sessionId"]
+	timestamp, hasTimestamp := basicCheck["timestamp"]
+	if !hasSessionId || !hasTimestamp || sessionId == nil || timestamp == nil {
+		// Skip non-LogEntry entries (like summary entries)
+		return nil, nil
+	}
+
+	var entry models.LogEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return nil, err
+	}
+	return []models.LogEntry{entry}, nil
+}
+
+// sourceFor returns the source a discovered file belongs to; files found
+// any other way are Claude logs
+func (d *DiffSyncService) sourceFor(path string) LogSource {
+	if source, ok := d.fileSources[path]; ok {
+		return source
+	}
+	return claudeLogSource{config: d.sources}
+}
+
+// discoverLogFiles lists the files of every source and remembers which
+// source each belongs to. A missing Claude projects directory only fails the
+// pass when no other source has logs.
+func (d *DiffSyncService) discoverLogFiles() ([]models.FileInfo, error) {
+	d.fileSources = make(map[string]LogSource)
+	var files []models.FileInfo
+	var claudeErr error
+	for _, source := range d.sources.LogSources() {
+		found, err := source.Discover()
+		if err != nil {
+			if source.Provider() != ProviderClaude {
+				return nil, err
+			}
+			claudeErr = err
+			continue
+		}
+		for _, file := range found {
+			d.fileSources[file.Path] = source
+		}
+		files = append(files, found...)
+	}
+	if claudeErr != nil {
+		if len(files) == 0 {
+			return nil, claudeErr
+		}
+		logging.Component("sync").Warn("Syncing other tools' logs only", "err", claudeErr)
+	}
+	return files, nil
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrWatcherRunning is returned by Start when the watcher is already running
+var ErrWatcherRunning = errors.New("log watcher is already running")
+
+// WatcherStatus describes the log watcher for the API
+type WatcherStatus struct {
+	Running     bool       `json:"running"`
+	Roots       []string   `json:"roots"`
+	WatchedDirs int        `json:"watched_dirs"`
+	DebounceMs  int64      `json:"debounce_ms"`
+	LastEvent   *time.Time `json:"last_event"`
+	LastTrigger *time.Time `json:"last_trigger"`
+	Triggers    int        `json:"triggers"`
+}
+
+// LogWatcher queues a sync when JSONL files under the log roots are created
+// or written. Claude appends to a session file many times per response, so
+// events are debounced: a sync starts once writes have been quiet for the
+// debounce period. The sync itself is incremental, so only new lines are read.
+type LogWatcher struct {
+	sources  LogSourceConfig
+	syncJobs *SyncJobQueue
+	debounce time.Duration
+
+	mu          sync.Mutex
+	watcher     *fsnotify.Watcher
+	watched     map[string]bool
+	stop        chan struct{}
+	done        chan struct{}
+	lastEvent   time.Time
+	lastTrigger time.Time
+	triggers    int
+}
+
+// NewLogWatcher creates a stopped watcher for the given log sources
+func NewLogWatcher(sources LogSourceConfig, syncJobs *SyncJobQueue, debounce time.Duration) *LogWatcher {
+	return &LogWatcher{
+		sources:  sources,
+		syncJobs: syncJobs,
+		debounce: debounce,
+	}
+}
+
+// Start begins watching every log root and project directory
+func (w *LogWatcher) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watcher != nil {
+		return ErrWatcherRunning
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	w.watcher = watcher
+	w.watched = make(map[string]bool)
+	roots := append(append([]string(nil), w.sources.Roots...), w.sources.CodexDirs...)
+	for _, root := range roots {
+		if err := w.addTree(root); err != nil {
+			watcher.Close()
+			w.watcher = nil
+			return err
+		}
+	}
+	for _, path := range w.sources.GeminiLogs {
+		if err := w.addGeminiPath(path); err != nil {
+			watcher.Close()
+			w.watcher = nil
+			return err
+		}
+	}
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run(watcher, w.stop, w.done)
+	return nil
+}
+
+// Stop ends watching; it is a no-op when the watcher is not running
+func (w *LogWatcher) Stop() {
+	w.mu.Lock()
+	if w.watcher == nil {
+		w.mu.Unlock()
+		return
+	}
+	close(w.stop)
+	done := w.done
+	w.watcher.Close()
+	w.watcher = nil
+	w.mu.Unlock()
+	<-done
+}
+
+// Status reports whether the watcher runs and what it has done
+func (w *LogWatcher) Status() WatcherStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := WatcherStatus{
+		Running:     w.watcher != nil,
+		Roots:       append(append(append([]string(nil), w.sources.Roots...), w.sources.CodexDirs...), w.sources.GeminiLogs...),
+		WatchedDirs: len(w.watched),
+		DebounceMs:  w.debounce.Milliseconds(),
+		Triggers:    w.triggers,
+	}
+	if status.Roots == nil {
+		status.Roots = []string{}
+	}
+	if !status.Running {
+		status.WatchedDirs = 0
+	}
+	if !w.lastEvent.IsZero() {
+		t := w.lastEvent
+		status.LastEvent = &t
+	}
+	if !w.lastTrigger.IsZero() {
+		t := w.lastTrigger
+		status.LastTrigger = &t
+	}
+	return status
+}
+
+// addTree watches dir and its subdirectories. Claude project directories
+// excluded by the source filters are skipped. Caller holds w.mu.
+func (w *LogWatcher) addTree(root string) error {
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		// The root appears once Claude writes its first log; nothing to watch yet
+		return nil
+	}
+	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if filepath.Dir(path) == filepath.Clean(root) && !w.inToolDir(path) && !w.sources.Matches(d.Name()) {
+			return filepath.SkipDir
+		}
+		if w.watched[path] {
+			return nil
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		w.watched[path] = true
+		return nil
+	})
+}
+
+// addGeminiPath watches a Gemini telemetry directory like a root, or the
+// directory of a telemetry file without its subdirectories. Caller holds w.mu.
+func (w *LogWatcher) addGeminiPath(path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return w.addTree(path)
+	}
+	dir := filepath.Dir(path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) || w.watched[dir] {
+		return nil
+	}
+	if err := w.watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	w.watched[dir] = true
+	return nil
+}
+
+func (w *LogWatcher) run(watcher *fsnotify.Watcher, stop, done chan struct{}) {
+	defer close(done)
+
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !w.handleEvent(event) {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(w.debounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(w.debounce)
+			}
+			fire = timer.C
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logging.Component("watcher").Warn("Log watcher error", "err", err)
+
+		case <-fire:
+			fire = nil
+			w.syncJobs.Enqueue("watcher")
+			w.mu.Lock()
+			w.lastTrigger = time.Now()
+			w.triggers++
+			w.mu.Unlock()
+		}
+	}
+}
+
+// handleEvent starts watching new directories and reports whether the event
+// is a JSONL change that should trigger a sync
+func (w *LogWatcher) handleEvent(event fsnotify.Event) bool {
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			// Directories holding a Gemini telemetry file are watched alone
+			if !w.inToolDir(event.Name) && !w.inRoot(event.Name) {
+				return false
+			}
+			w.mu.Lock()
+			if w.watcher != nil {
+				if err := w.addTree(event.Name); err != nil {
+					logging.Component("watcher").Warn("Failed to watch new directory", "dir", event.Name, "err", err)
+				}
+			}
+			w.mu.Unlock()
+			return false
+		}
+	}
+
+	if !isLogFile(event.Name) && !w.isGeminiLog(event.Name) || !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return false
+	}
+	if !w.inIncludedProject(event.Name) {
+		return false
+	}
+
+	w.mu.Lock()
+	w.lastEvent = time.Now()
+	w.mu.Unlock()
+	return true
+}
+
+// inIncludedProject checks the project directory of a file against the
+// filters; Codex and Gemini logs have no project directories and are always
+// included
+func (w *LogWatcher) inIncludedProject(path string) bool {
+	if w.inToolDir(path) || w.isGeminiLog(path) {
+		return true
+	}
+	for _, root := range w.sources.Roots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		project, _, found := strings.Cut(filepath.ToSlash(rel), "/")
+		return found && w.sources.Matches(project)
+	}
+	return false
+}
+
+// inToolDir reports whether path is below one of the Codex sessions
+// directories or Gemini telemetry paths
+func (w *LogWatcher) inToolDir(path string) bool {
+	return below(path, w.sources.CodexDirs) || below(path, w.sources.GeminiLogs)
+}
+
+// inRoot reports whether path is below one of the Claude projects directories
+func (w *LogWatcher) inRoot(path string) bool {
+	return below(path, w.sources.Roots)
+}
+
+// isGeminiLog reports whether path is a configured Gemini telemetry file or
+// one found in a configured directory
+func (w *LogWatcher) isGeminiLog(path string) bool {
+	for _, log := range w.sources.GeminiLogs {
+		if path == log {
+			return true
+		}
+	}
+	return geminiLogNames[filepath.Base(path)] && below(path, w.sources.GeminiLogs)
+}
+
+// below reports whether path lies below one of dirs
+func below(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
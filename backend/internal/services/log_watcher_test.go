@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+func TestLogWatcherDebouncesWrites(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "-home-user-app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs atomic.Int32
+	queue := NewSyncJobQueue(func(context.Context) (*models.SyncStats, error) {
+		runs.Add(1)
+		return &models.SyncStats{}, nil
+	})
+	watcher := NewLogWatcher(LogSourceConfig{
+		Roots:           []string{root},
+		ExcludeProjects: []string{"*-scratch"},
+	}, queue, 200*time.Millisecond)
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+	if err := watcher.Start(); err != ErrWatcherRunning {
+		t.Errorf("Expected ErrWatcherRunning, got %v", err)
+	}
+
+	// A burst of appends to one file, in a project created after Start
+	newProject := filepath.Join(root, "-home-user-new")
+	if err := os.MkdirAll(newProject, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	path := filepath.Join(newProject, "session.jsonl")
+	for i := 0; i < 5; i++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("{}\n")
+		f.Close()
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Neither of these should trigger a sync
+	os.WriteFile(filepath.Join(root, "-home-user-app", "notes.txt"), []byte("x"), 0644)
+	os.MkdirAll(filepath.Join(root, "-tmp-scratch"), 0755)
+	time.Sleep(100 * time.Millisecond)
+	os.WriteFile(filepath.Join(root, "-tmp-scratch", "s.jsonl"), []byte("{}\n"), 0644)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for watcher.Status().Triggers == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	status := watcher.Status()
+	if status.Triggers != 1 {
+		t.Errorf("Expected 1 debounced trigger, got %d", status.Triggers)
+	}
+	if !status.Running || status.LastEvent == nil || status.LastTrigger == nil {
+		t.Errorf("Expected running watcher with event times, got %+v", status)
+	}
+	if jobs := queue.List(); len(jobs) != 1 || jobs[0].Trigger != "watcher" {
+		t.Errorf("Expected one watcher sync job, got %+v", jobs)
+	}
+
+	watcher.Stop()
+	if watcher.Status().Running {
+		t.Error("Expected watcher to be stopped")
+	}
+	watcher.Stop()
+}
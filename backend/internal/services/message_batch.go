@@ -0,0 +1,264 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// maxBatchMessages bounds how many parsed messages are held in memory before
+// they are written; most session files fit in a single batch
+const maxBatchMessages = 1000
+
+// sqlExecutor is a *sql.DB or a *sql.Tx, for statements that run on their
+// own or as part of a larger transaction
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// messageBatch collects the messages parsed from a file so they are written
+// together: each session is created or updated once, and the sessions,
+// windows, messages, usage keys, tool calls and limit events are written in
+// one transaction instead of several statements per line
+type messageBatch struct {
+	messages []*models.Message
+	ids      map[string]struct{}
+	// usageKeys maps messages reporting usage to the response they belong to
+	usageKeys map[string]usageKey
+	sessions  map[string]*batchSession
+	// order keeps sessions in the order they were first seen
+	order []string
+	// toolCalls and toolResults are written after the messages
+	toolCalls   []toolCall
+	toolResults []toolResult
+	// limitEvents are written after the messages and their windows
+	limitEvents []LimitEvent
+}
+
+type batchSession struct {
+	projectName string
+	projectPath string
+	userID      string
+	provider    string
+	start       time.Time
+}
+
+func newMessageBatch() *messageBatch {
+	return &messageBatch{
+		ids:       make(map[string]struct{}),
+		usageKeys: make(map[string]usageKey),
+		sessions:  make(map[string]*batchSession),
+	}
+}
+
+// add queues a message; the session keeps the project, user and provider of
+// its first message and the earliest timestamp
+func (b *messageBatch) add(message *models.Message, projectName, projectPath, userID string) {
+	b.messages = append(b.messages, message)
+	if message.ID != "" {
+		b.ids[message.ID] = struct{}{}
+	}
+
+	session, ok := b.sessions[message.SessionID]
+	if !ok {
+		b.sessions[message.SessionID] = &batchSession{
+			projectName: projectName,
+			projectPath: projectPath,
+			userID:      userID,
+			provider:    message.Provider,
+			start:       message.Timestamp,
+		}
+		b.order = append(b.order, message.SessionID)
+		return
+	}
+	if message.Timestamp.Before(session.start) {
+		session.start = message.Timestamp
+	}
+}
+
+// addUsageKey remembers which response the usage of a queued entry belongs to
+func (b *messageBatch) addUsageKey(message *models.Message, entry *models.LogEntry) {
+	if key, ok := logUsageKey(entry); ok && message.ID != "" {
+		b.usageKeys[message.ID] = key
+	}
+}
+
+// addToolBlocks queues the tool calls and results of a log entry
+func (b *messageBatch) addToolBlocks(entry *models.LogEntry) {
+	calls, results := extractToolBlocks(entry)
+	b.toolCalls = append(b.toolCalls, calls...)
+	b.toolResults = append(b.toolResults, results...)
+}
+
+// addLimitEvent queues the limit a log entry reports, if any
+func (b *messageBatch) addLimitEvent(entry *models.LogEntry) {
+	if event, ok := detectLimitEvent(entry); ok {
+		b.limitEvents = append(b.limitEvents, event)
+	}
+}
+
+// contains reports whether a message with this ID is waiting to be written
+func (b *messageBatch) contains(id string) bool {
+	_, ok := b.ids[id]
+	return ok
+}
+
+func (b *messageBatch) full() bool {
+	return len(b.messages) >= maxBatchMessages
+}
+
+func (b *messageBatch) reset() {
+	b.messages = nil
+	b.ids = make(map[string]struct{})
+	b.usageKeys = make(map[string]usageKey)
+	b.sessions = make(map[string]*batchSession)
+	b.order = nil
+	b.toolCalls = nil
+	b.toolResults = nil
+	b.limitEvents = nil
+}
+
+// write stores the batch in one transaction and empties it, so a failure
+// leaves none of it behind. It returns the sessions and windows whose
+// aggregates the caller should recalculate.
+func (b *messageBatch) write(db *sql.DB, windows *SessionWindowService) (sessionIDs []string, windowIDs map[string]struct{}, err error) {
+	defer b.reset()
+	if len(b.messages) == 0 {
+		return nil, nil, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	// Windows created in a rolled back transaction must not stay cached
+	defer func() {
+		if err != nil {
+			windows.InvalidateCache()
+		}
+	}()
+
+	for _, sessionID := range b.order {
+		session := b.sessions[sessionID]
+		if err := createOrUpdateUserSession(tx, sessionID, session.userID, session.projectName, session.projectPath, session.start); err != nil {
+			return nil, nil, fmt.Errorf("failed to create/update session: %w", err)
+		}
+		if session.provider != "" && session.provider != ProviderClaude {
+			if _, err := tx.Exec(`UPDATE sessions SET provider = ? WHERE id = ?`, session.provider, sessionID); err != nil {
+				return nil, nil, fmt.Errorf("failed to set provider of session %s: %w", sessionID, err)
+			}
+		}
+	}
+
+	windowIDs = make(map[string]struct{})
+	for _, message := range b.messages {
+		// Session windows track Claude's usage limit, which other tools do not count against
+		if message.Provider != "" && message.Provider != ProviderClaude {
+			continue
+		}
+		window, err := windows.getOrCreateWindowForMessage(tx, message.Timestamp)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get/create session window: %w", err)
+		}
+		message.SessionWindowID = &window.ID
+		windowIDs[window.ID] = struct{}{}
+	}
+
+	added, duplicates, err := suppressDuplicateUsage(tx, b.messages, b.usageKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeMessages(tx, b.messages); err != nil {
+		return nil, nil, err
+	}
+	if err := recordUsageKeys(tx, added, duplicates); err != nil {
+		return nil, nil, err
+	}
+	if _, err := writeToolCalls(tx, b.toolCalls, b.toolResults); err != nil {
+		return nil, nil, err
+	}
+	if err := recordLimitEvents(tx, b.limitEvents); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit batch: %w", err)
+	}
+	return b.order, windowIDs, nil
+}
+
+// insertMessages stores messages in a single transaction
+func insertMessages(db *sql.DB, messages []*models.Message) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := writeMessages(tx, messages); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit messages: %w", err)
+	}
+	return nil
+}
+
+// writeMessages stores messages within tx with a prepared statement. A
+// message already stored is left as it is, so writing an entry again never
+// changes the totals derived from it.
+func writeMessages(tx *sql.Tx, messages []*models.Message) error {
+	stmt, err := tx.Prepare(`
+		INSERT INTO messages (
+			id, session_id, session_window_id, parent_uuid, is_sidechain, user_type, message_type,
+			message_role, model, content, input_tokens, cache_creation_input_tokens,
+			cache_read_input_tokens, output_tokens, service_tier, request_id,
+			content_hash, billing, provider, timestamp, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare message insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, message := range messages {
+		provider := message.Provider
+		if provider == "" {
+			provider = ProviderClaude
+		}
+		_, err := stmt.Exec(
+			message.ID,
+			message.SessionID,
+			message.SessionWindowID,
+			message.ParentUUID,
+			message.IsSidechain,
+			message.UserType,
+			message.MessageType,
+			message.MessageRole,
+			message.Model,
+			message.Content,
+			message.InputTokens,
+			message.CacheCreationInputTokens,
+			message.CacheReadInputTokens,
+			message.OutputTokens,
+			message.ServiceTier,
+			message.RequestID,
+			message.ContentHash,
+			message.Billing,
+			provider,
+			message.Timestamp,
+			now,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert message %s: %w", message.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/models"
+)
+
+func TestMessageBatchWritesAllOrNothing(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	windows := NewSessionWindowService(db)
+	windows.EnableCache()
+
+	at := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	fill := func(b *messageBatch) {
+		requestID, apiID, role := "req-1", "msg_1", "assistant"
+		message := &models.Message{ID: "m1", SessionID: "s1", MessageRole: &role, InputTokens: 100, Timestamp: at}
+		b.add(message, "app", "/work/app", "")
+		b.addUsageKey(message, &models.LogEntry{RequestID: &requestID, Message: models.LogMessage{ID: &apiID, Usage: &models.Usage{}}})
+		b.toolCalls = append(b.toolCalls, toolCall{id: "tool-1", messageID: "m1", sessionID: "s1", name: "Bash", calledAt: at})
+		b.limitEvents = append(b.limitEvents, LimitEvent{ID: "m1", SessionID: "s1", Kind: "usage", Timestamp: at})
+	}
+	count := func(table string) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+			t.Fatalf("Failed to count %s: %v", table, err)
+		}
+		return n
+	}
+
+	// The last statement fails: nothing of the batch may be left
+	if _, err := db.Exec(`DROP INDEX idx_limit_events_timestamp; ALTER TABLE limit_events RENAME TO limit_events_away`); err != nil {
+		t.Fatal(err)
+	}
+	batch := newMessageBatch()
+	fill(batch)
+	if _, _, err := batch.write(db, windows); err == nil {
+		t.Fatal("Expected the batch to fail without the limit_events table")
+	}
+	for _, table := range []string{"sessions", "session_windows", "messages", "message_usage_keys", "tool_calls"} {
+		if n := count(table); n != 0 {
+			t.Errorf("Expected no rows in %s after a failed batch, got %d", table, n)
+		}
+	}
+	if window := windows.cache.find(at); window != nil {
+		t.Errorf("Expected the rolled back window not to stay cached, got %+v", window)
+	}
+
+	if _, err := db.Exec(`ALTER TABLE limit_events_away RENAME TO limit_events`); err != nil {
+		t.Fatal(err)
+	}
+	fill(batch)
+	sessionIDs, windowIDs, err := batch.write(db, windows)
+	if err != nil {
+		t.Fatalf("Failed to write batch: %v", err)
+	}
+	if len(sessionIDs) != 1 || len(windowIDs) != 1 {
+		t.Errorf("Expected one session and one window to update, got %v %v", sessionIDs, windowIDs)
+	}
+	for _, table := range []string{"sessions", "session_windows", "messages", "message_usage_keys", "tool_calls", "limit_events"} {
+		if n := count(table); n != 1 {
+			t.Errorf("Expected one row in %s, got %d", table, n)
+		}
+	}
+}
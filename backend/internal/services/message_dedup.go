@@ -0,0 +1,202 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// usageKey identifies one API response. Claude Code can log a response more
+// than once, on retries or once per streamed content block, and every entry
+// repeats the response's usage; only the first entry stored keeps it.
+type usageKey struct {
+	requestID    string
+	apiMessageID string
+}
+
+// DuplicateMessage is a log entry whose usage was not counted because it
+// repeated that of an earlier entry for the same request
+type DuplicateMessage struct {
+	MessageID                string    `json:"message_id"`
+	DuplicateOf              string    `json:"duplicate_of"`
+	RequestID                string    `json:"request_id"`
+	APIMessageID             string    `json:"api_message_id"`
+	SessionID                string    `json:"session_id"`
+	InputTokens              int       `json:"input_tokens"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	Timestamp                time.Time `json:"timestamp"`
+	DetectedAt               time.Time `json:"detected_at"`
+}
+
+// DuplicateReport summarizes the usage suppressed as duplicate
+type DuplicateReport struct {
+	Count int64 `json:"count"`
+	// SuppressedTokens is the usage the duplicates would have added
+	SuppressedTokens int64              `json:"suppressed_tokens"`
+	Recent           []DuplicateMessage `json:"recent"`
+}
+
+// logUsageKey returns the usage key of an entry that reports usage
+func logUsageKey(entry *models.LogEntry) (usageKey, bool) {
+	if entry.Message.Usage == nil || entry.RequestID == nil || entry.Message.ID == nil ||
+		*entry.RequestID == "" || *entry.Message.ID == "" {
+		return usageKey{}, false
+	}
+	return usageKey{requestID: *entry.RequestID, apiMessageID: *entry.Message.ID}, true
+}
+
+// suppressDuplicateUsage zeroes the tokens of batch messages whose usage key
+// belongs to another message, stored earlier or queued before them. It
+// returns the keys first seen in this batch and the duplicates found, for
+// recordUsageKeys once the messages are written.
+func suppressDuplicateUsage(q sqlExecutor, messages []*models.Message, keys map[string]usageKey) (map[usageKey]string, []DuplicateMessage, error) {
+	if len(keys) == 0 {
+		return nil, nil, nil
+	}
+
+	counted, err := loadUsageKeys(q, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	added := make(map[usageKey]string)
+	var duplicates []DuplicateMessage
+	now := time.Now()
+	for _, message := range messages {
+		key, ok := keys[message.ID]
+		if !ok {
+			continue
+		}
+		owner, ok := counted[key]
+		if !ok {
+			counted[key] = message.ID
+			added[key] = message.ID
+			continue
+		}
+		if owner == message.ID {
+			continue
+		}
+
+		duplicates = append(duplicates, DuplicateMessage{
+			MessageID:                message.ID,
+			DuplicateOf:              owner,
+			RequestID:                key.requestID,
+			APIMessageID:             key.apiMessageID,
+			SessionID:                message.SessionID,
+			InputTokens:              message.InputTokens,
+			CacheCreationInputTokens: message.CacheCreationInputTokens,
+			CacheReadInputTokens:     message.CacheReadInputTokens,
+			OutputTokens:             message.OutputTokens,
+			Timestamp:                message.Timestamp,
+			DetectedAt:               now,
+		})
+		message.InputTokens = 0
+		message.CacheCreationInputTokens = 0
+		message.CacheReadInputTokens = 0
+		message.OutputTokens = 0
+	}
+	return added, duplicates, nil
+}
+
+// loadUsageKeys returns the counted message of the stored keys among keys
+func loadUsageKeys(q sqlExecutor, keys map[string]usageKey) (map[usageKey]string, error) {
+	requestIDs := make(map[string]struct{})
+	for _, key := range keys {
+		requestIDs[key.requestID] = struct{}{}
+	}
+	args := make([]interface{}, 0, len(requestIDs))
+	for requestID := range requestIDs {
+		args = append(args, requestID)
+	}
+
+	rows, err := q.Query(`
+		SELECT request_id, api_message_id, message_id FROM message_usage_keys
+		WHERE request_id IN (?`+strings.Repeat(", ?", len(args)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage keys: %w", err)
+	}
+	defer rows.Close()
+
+	counted := make(map[usageKey]string)
+	for rows.Next() {
+		var key usageKey
+		var messageID string
+		if err := rows.Scan(&key.requestID, &key.apiMessageID, &messageID); err != nil {
+			return nil, fmt.Errorf("failed to scan usage key: %w", err)
+		}
+		counted[key] = messageID
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load usage keys: %w", err)
+	}
+	return counted, nil
+}
+
+// recordUsageKeys stores the keys and duplicates found by
+// suppressDuplicateUsage within the transaction writing their messages
+func recordUsageKeys(tx *sql.Tx, added map[usageKey]string, duplicates []DuplicateMessage) error {
+	for key, messageID := range added {
+		_, err := tx.Exec(`
+			INSERT INTO message_usage_keys (request_id, api_message_id, message_id) VALUES (?, ?, ?)
+			ON CONFLICT (request_id, api_message_id) DO NOTHING
+		`, key.requestID, key.apiMessageID, messageID)
+		if err != nil {
+			return fmt.Errorf("failed to record usage key of %s: %w", messageID, err)
+		}
+	}
+	for _, d := range duplicates {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO duplicate_messages (
+				message_id, duplicate_of, request_id, api_message_id, session_id, input_tokens,
+				cache_creation_input_tokens, cache_read_input_tokens, output_tokens, timestamp, detected_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, d.MessageID, d.DuplicateOf, d.RequestID, d.APIMessageID, d.SessionID, d.InputTokens,
+			d.CacheCreationInputTokens, d.CacheReadInputTokens, d.OutputTokens, d.Timestamp, d.DetectedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record duplicate %s: %w", d.MessageID, err)
+		}
+	}
+	return nil
+}
+
+// GetDuplicateReport returns how many entries were suppressed as duplicates,
+// the tokens they carried and the most recently detected
+func GetDuplicateReport(db *sql.DB, limit int) (*DuplicateReport, error) {
+	report := &DuplicateReport{Recent: []DuplicateMessage{}}
+	err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(input_tokens + cache_creation_input_tokens + cache_read_input_tokens + output_tokens), 0)
+		FROM duplicate_messages
+	`).Scan(&report.Count, &report.SuppressedTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count duplicates: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT message_id, duplicate_of, request_id, api_message_id, session_id, input_tokens,
+			cache_creation_input_tokens, cache_read_input_tokens, output_tokens, timestamp, detected_at
+		FROM duplicate_messages
+		ORDER BY detected_at DESC, timestamp DESC, message_id
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d DuplicateMessage
+		err := rows.Scan(&d.MessageID, &d.DuplicateOf, &d.RequestID, &d.APIMessageID, &d.SessionID, &d.InputTokens,
+			&d.CacheCreationInputTokens, &d.CacheReadInputTokens, &d.OutputTokens, &d.Timestamp, &d.DetectedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate: %w", err)
+		}
+		report.Recent = append(report.Recent, d)
+	}
+	return report, rows.Err()
+}
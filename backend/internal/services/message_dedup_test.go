@@ -0,0 +1,71 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestRepeatedResponsesAreCountedOnce(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	ingest := func(lines ...string) {
+		var entries []IngestEntry
+		for _, line := range lines {
+			entry := IngestEntry{Project: "-work-app"}
+			if err := json.Unmarshal([]byte(line), &entry.Entry); err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, entry)
+		}
+		diffSync := NewDiffSyncService(db, NewTokenService(db), NewSessionService(db))
+		if _, err := diffSync.Ingest(entries, ""); err != nil {
+			t.Fatalf("Ingest failed: %v", err)
+		}
+	}
+
+	// One response logged once per content block, then a distinct response
+	ingest(
+		`{"uuid":"a-1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-02T09:00:00Z","requestId":"req-1","message":{"id":"msg-1","role":"assistant","model":"claude-sonnet-4-20250514","content":"thinking","usage":{"input_tokens":100,"output_tokens":50}}}`,
+		`{"uuid":"a-2","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-02T09:00:01Z","requestId":"req-1","message":{"id":"msg-1","role":"assistant","model":"claude-sonnet-4-20250514","content":"answer","usage":{"input_tokens":100,"output_tokens":50}}}`,
+		`{"uuid":"a-3","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-02T09:01:00Z","requestId":"req-2","message":{"id":"msg-2","role":"assistant","model":"claude-sonnet-4-20250514","content":"more","usage":{"input_tokens":10,"output_tokens":5}}}`,
+	)
+	// A retry logged later under a new uuid
+	ingest(
+		`{"uuid":"a-4","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-02T09:00:02Z","requestId":"req-1","message":{"id":"msg-1","role":"assistant","model":"claude-sonnet-4-20250514","content":"answer","usage":{"input_tokens":100,"output_tokens":50}}}`,
+	)
+
+	var total int
+	if err := db.QueryRow(`SELECT total_tokens FROM sessions WHERE id = 's1'`).Scan(&total); err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if total != 165 {
+		t.Errorf("Expected req-1 counted once for 165 tokens, got %d", total)
+	}
+	var content sql.NullString
+	if err := db.QueryRow(`SELECT content FROM messages WHERE id = 'a-2'`).Scan(&content); err != nil || !content.Valid {
+		t.Errorf("Expected the duplicate entry to keep its content, got %v (%v)", content, err)
+	}
+
+	report, err := GetDuplicateReport(db, 10)
+	if err != nil {
+		t.Fatalf("GetDuplicateReport failed: %v", err)
+	}
+	if report.Count != 2 || report.SuppressedTokens != 300 || len(report.Recent) != 2 {
+		t.Fatalf("Expected two suppressed duplicates of 150 tokens, got %+v", report)
+	}
+	for _, d := range report.Recent {
+		if d.DuplicateOf != "a-1" || d.RequestID != "req-1" || d.APIMessageID != "msg-1" {
+			t.Errorf("Expected a duplicate of a-1, got %+v", d)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// messageIDFilter is a Bloom filter over ingested message UUIDs. It answers
+// "definitely new" without touching the database; a positive answer may be a
+// false positive and has to be confirmed with a point lookup.
+type messageIDFilter struct {
+	bits   []uint64
+	hashes uint64
+}
+
+// newMessageIDFilter sizes the filter for the expected number of IDs at
+// roughly a 1% false positive rate
+func newMessageIDFilter(expected int) *messageIDFilter {
+	if expected < 1024 {
+		expected = 1024
+	}
+	// m = -n ln(p) / (ln 2)^2 with p = 0.01, k = (m/n) ln 2
+	m := uint64(math.Ceil(-float64(expected) * math.Log(0.01) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(expected) * math.Ln2))
+	return &messageIDFilter{
+		bits:   make([]uint64, (m+63)/64),
+		hashes: k,
+	}
+}
+
+// positions derives the bit positions for id using double hashing
+func (f *messageIDFilter) positions(id string, fn func(uint64)) {
+	h := fnv.New64a()
+	h.Write([]byte(id))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31 | 1
+	size := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < f.hashes; i++ {
+		fn((h1 + i*h2) % size)
+	}
+}
+
+func (f *messageIDFilter) add(id string) {
+	f.positions(id, func(bit uint64) {
+		f.bits[bit/64] |= 1 << (bit % 64)
+	})
+}
+
+func (f *messageIDFilter) mayContain(id string) bool {
+	found := true
+	f.positions(id, func(bit uint64) {
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			found = false
+		}
+	})
+	return found
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMessageIDFilter(t *testing.T) {
+	filter := newMessageIDFilter(10000)
+	for i := 0; i < 10000; i++ {
+		filter.add(fmt.Sprintf("known-%d", i))
+	}
+
+	for i := 0; i < 10000; i++ {
+		if !filter.mayContain(fmt.Sprintf("known-%d", i)) {
+			t.Fatalf("Expected known-%d to be reported as present", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mayContain(fmt.Sprintf("unknown-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("Expected about 1%% false positives, got %d of 10000", falsePositives)
+	}
+}
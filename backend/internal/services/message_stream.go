@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// MaxMessagePageSize caps a single page of GetMessagesPage
+const MaxMessagePageSize = 1000
+
+// streamBatchSize is the number of rows StreamMessages holds in memory at once
+const streamBatchSize = 500
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// MessageCursor marks a position in the (timestamp, id) ordering of messages
+type MessageCursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+// Encode returns an opaque string form of the cursor for API clients
+func (c MessageCursor) Encode() string {
+	raw := c.Timestamp.UTC().Format(time.RFC3339Nano) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeMessageCursor parses a cursor produced by Encode
+func DecodeMessageCursor(s string) (*MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &MessageCursor{Timestamp: ts, ID: parts[1]}, nil
+}
+
+// MessageQuery filters messages for keyset pagination and streaming
+type MessageQuery struct {
+	SessionID string
+	// User keeps the messages of sessions synced from this user's logs
+	User  string
+	Since *time.Time
+	Until *time.Time
+	After *MessageCursor
+	Limit int
+}
+
+// MessagePage is one page of messages in timestamp order
+type MessagePage struct {
+	Messages   []models.Message `json:"messages"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	HasMore    bool             `json:"has_more"`
+}
+
+// GetMessagesPage returns messages after the query cursor. Unlike offset
+// pagination, the cost of a page does not grow with its position.
+func (s *SessionService) GetMessagesPage(q MessageQuery) (*MessagePage, error) {
+	if q.Limit < 1 || q.Limit > MaxMessagePageSize {
+		q.Limit = 100
+	}
+
+	var conditions []string
+	var args []interface{}
+	if q.SessionID != "" {
+		conditions = append(conditions, "session_id = ?")
+		args = append(args, q.SessionID)
+	}
+	if q.User != "" {
+		conditions = append(conditions, "session_id IN (SELECT id FROM sessions WHERE user_id = ?)")
+		args = append(args, q.User)
+	}
+	if q.Since != nil {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, *q.Since)
+	}
+	if q.Until != nil {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, *q.Until)
+	}
+	if q.After != nil {
+		conditions = append(conditions, "(timestamp > ? OR (timestamp = ? AND id > ?))")
+		args = append(args, q.After.Timestamp, q.After.Timestamp, q.After.ID)
+	}
+
+	query := `
+		SELECT
+			id, session_id, parent_uuid, is_sidechain, user_type, message_type,
+			message_role, model, content, input_tokens, cache_creation_input_tokens,
+			cache_read_input_tokens, output_tokens, service_tier, request_id,
+			COALESCE(provider, 'claude'), timestamp, created_at
+		FROM messages
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// Fetch one extra row to know whether another page follows
+	query += " ORDER BY timestamp ASC, id ASC LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+	defer rows.Close()
+
+	page := &MessagePage{Messages: []models.Message{}}
+	for rows.Next() {
+		var message models.Message
+		err := rows.Scan(
+			&message.ID,
+			&message.SessionID,
+			&message.ParentUUID,
+			&message.IsSidechain,
+			&message.UserType,
+			&message.MessageType,
+			&message.MessageRole,
+			&message.Model,
+			&message.Content,
+			&message.InputTokens,
+			&message.CacheCreationInputTokens,
+			&message.CacheReadInputTokens,
+			&message.OutputTokens,
+			&message.ServiceTier,
+			&message.RequestID,
+			&message.Provider,
+			&message.Timestamp,
+			&message.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		message.Content = s.cipher.Open(message.Content)
+		page.Messages = append(page.Messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	if len(page.Messages) > q.Limit {
+		page.Messages = page.Messages[:q.Limit]
+		page.HasMore = true
+		last := page.Messages[len(page.Messages)-1]
+		page.NextCursor = MessageCursor{Timestamp: last.Timestamp, ID: last.ID}.Encode()
+	}
+
+	return page, nil
+}
+
+// StreamMessages calls fn for every message matching the query in timestamp
+// order, reading in small batches so memory stays flat for any result size
+func (s *SessionService) StreamMessages(q MessageQuery, fn func(models.Message) error) error {
+	q.Limit = streamBatchSize
+	for {
+		page, err := s.GetMessagesPage(q)
+		if err != nil {
+			return err
+		}
+		for _, message := range page.Messages {
+			if err := fn(message); err != nil {
+				return err
+			}
+		}
+		if !page.HasMore {
+			return nil
+		}
+		last := page.Messages[len(page.Messages)-1]
+		q.After = &MessageCursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+}
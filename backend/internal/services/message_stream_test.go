@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+func TestGetMessagesPageWalksAllMessages(t *testing.T) {
+	db, _ := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		// Pairs of messages share a timestamp so the id tiebreaker matters
+		_, err := db.Exec(`INSERT INTO messages (id, session_id, timestamp) VALUES (?, ?, ?)`,
+			fmt.Sprintf("msg-%d", i), "session1", base.Add(time.Duration(i/2)*time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	service := NewSessionService(db)
+	q := MessageQuery{SessionID: "session1", Limit: 3}
+	var ids []string
+	pages := 0
+	for {
+		page, err := service.GetMessagesPage(q)
+		if err != nil {
+			t.Fatalf("GetMessagesPage failed: %v", err)
+		}
+		pages++
+		for _, message := range page.Messages {
+			ids = append(ids, message.ID)
+		}
+		if !page.HasMore {
+			break
+		}
+		cursor, err := DecodeMessageCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("Failed to decode cursor: %v", err)
+		}
+		q.After = cursor
+	}
+
+	if pages != 3 {
+		t.Errorf("Expected 3 pages, got %d", pages)
+	}
+	if len(ids) != 7 {
+		t.Fatalf("Expected 7 messages, got %d: %v", len(ids), ids)
+	}
+	for i, id := range ids {
+		if expected := fmt.Sprintf("msg-%d", i); id != expected {
+			t.Errorf("Expected message %d to be %s, got %s", i, expected, id)
+		}
+	}
+
+	streamed := 0
+	err := service.StreamMessages(MessageQuery{SessionID: "session1"}, func(models.Message) error {
+		streamed++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamMessages failed: %v", err)
+	}
+	if streamed != 7 {
+		t.Errorf("Expected 7 streamed messages, got %d", streamed)
+	}
+}
+
+func TestDecodeMessageCursorRejectsGarbage(t *testing.T) {
+	if _, err := DecodeMessageCursor("not a cursor"); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
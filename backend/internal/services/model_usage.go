@@ -0,0 +1,313 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Model families
+const (
+	ModelFamilyOpus   = "opus"
+	ModelFamilySonnet = "sonnet"
+	ModelFamilyHaiku  = "haiku"
+	ModelFamilyOther  = "other"
+)
+
+// ModelUsageSummary is the usage of one model, or of a whole model family
+type ModelUsageSummary struct {
+	Model                    string  `json:"model"`
+	Family                   string  `json:"family"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	TotalTokens              int64   `json:"total_tokens"`
+	Cost                     float64 `json:"cost"`
+	MessageCount             int     `json:"message_count"`
+	SessionCount             int     `json:"session_count"`
+	// CacheHitRatio is the share of prompt tokens read from the cache
+	CacheHitRatio float64 `json:"cache_hit_ratio"`
+	TokenShare    float64 `json:"token_share"`
+	CostShare     float64 `json:"cost_share"`
+}
+
+// ModelUsageReport breaks usage down by model version, by family and by the
+// tool that logged it (claude, codex or gemini)
+type ModelUsageReport struct {
+	Models    []ModelUsageSummary `json:"models"`
+	Families  []ModelUsageSummary `json:"families"`
+	Providers []ModelUsageSummary `json:"providers"`
+	Total     ModelUsageSummary   `json:"total"`
+}
+
+// ModelUsageQuery filters a model usage report. Zero times leave the range
+// open; empty strings include every project or user.
+type ModelUsageQuery struct {
+	Project string
+	User    string
+	Since   time.Time
+	Until   time.Time
+}
+
+// ModelUsageService aggregates assistant usage by model
+type ModelUsageService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+}
+
+func NewModelUsageService(db *sql.DB) *ModelUsageService {
+	return &ModelUsageService{db: db, pricing: NewPricingCalculator()}
+}
+
+// ModelFamily returns opus, sonnet or haiku for a model name, or other
+func ModelFamily(model string) string {
+	lower := strings.ToLower(model)
+	for _, family := range []string{ModelFamilyOpus, ModelFamilySonnet, ModelFamilyHaiku} {
+		if strings.Contains(lower, family) {
+			return family
+		}
+	}
+	return ModelFamilyOther
+}
+
+// GetModelUsage returns the usage of each model in the query's range, most
+// expensive first, along with family and overall totals
+func (s *ModelUsageService) GetModelUsage(q ModelUsageQuery) (*ModelUsageReport, error) {
+	where, args := rangeFilter(q.Project, q.User, q.Since, q.Until)
+	if where == "" {
+		where = "WHERE m.message_role = 'assistant'"
+	} else {
+		where += " AND m.message_role = 'assistant'"
+	}
+
+	rows, err := s.db.Query(`
+		SELECT
+			COALESCE(m.model, 'unknown'),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0),
+			COUNT(*),
+			COUNT(DISTINCT m.session_id)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+`
+		GROUP BY COALESCE(m.model, 'unknown')
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model usage: %w", err)
+	}
+	defer rows.Close()
+
+	report := &ModelUsageReport{Models: []ModelUsageSummary{}, Families: []ModelUsageSummary{}, Providers: []ModelUsageSummary{}}
+	for rows.Next() {
+		var usage ModelUsageSummary
+		if err := rows.Scan(&usage.Model, &usage.InputTokens, &usage.OutputTokens,
+			&usage.CacheCreationInputTokens, &usage.CacheReadInputTokens, &usage.MessageCount, &usage.SessionCount); err != nil {
+			return nil, fmt.Errorf("failed to scan model usage: %w", err)
+		}
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+		usage.Family = ModelFamily(usage.Model)
+		if usage.Model != "unknown" {
+			usage.Cost = s.pricing.CalculateCost(usage.Model, int(usage.InputTokens), int(usage.OutputTokens),
+				int(usage.CacheCreationInputTokens), int(usage.CacheReadInputTokens))
+		}
+		report.Models = append(report.Models, usage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read model usage: %w", err)
+	}
+
+	families, err := s.queryFamilySessions(where, args)
+	if err != nil {
+		return nil, err
+	}
+	byFamily := make(map[string]*ModelUsageSummary)
+	report.Total = ModelUsageSummary{Model: "total", SessionCount: families[""]}
+	for _, model := range report.Models {
+		family, ok := byFamily[model.Family]
+		if !ok {
+			family = &ModelUsageSummary{Model: model.Family, Family: model.Family, SessionCount: families[model.Family]}
+			byFamily[model.Family] = family
+		}
+		family.add(model)
+		report.Total.add(model)
+	}
+	for _, family := range byFamily {
+		report.Families = append(report.Families, *family)
+	}
+
+	if report.Providers, err = s.queryProviderUsage(where, args); err != nil {
+		return nil, err
+	}
+
+	report.Total.finish(report.Total)
+	for i := range report.Providers {
+		report.Providers[i].finish(report.Total)
+	}
+	for i := range report.Models {
+		report.Models[i].finish(report.Total)
+	}
+	for i := range report.Families {
+		report.Families[i].finish(report.Total)
+	}
+	sortModelUsage(report.Models)
+	sortModelUsage(report.Families)
+	sortModelUsage(report.Providers)
+	return report, nil
+}
+
+// queryProviderUsage sums the usage of each provider's models, each priced
+// at its own rates, under the provider's name
+func (s *ModelUsageService) queryProviderUsage(where string, args []interface{}) ([]ModelUsageSummary, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			COALESCE(m.provider, 'claude'),
+			COALESCE(m.model, 'unknown'),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0),
+			COUNT(*)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+`
+		GROUP BY COALESCE(m.provider, 'claude'), COALESCE(m.model, 'unknown')
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query provider usage: %w", err)
+	}
+	defer rows.Close()
+
+	byProvider := make(map[string]*ModelUsageSummary)
+	for rows.Next() {
+		var provider string
+		var usage ModelUsageSummary
+		if err := rows.Scan(&provider, &usage.Model, &usage.InputTokens, &usage.OutputTokens,
+			&usage.CacheCreationInputTokens, &usage.CacheReadInputTokens, &usage.MessageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan provider usage: %w", err)
+		}
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+		if usage.Model != "unknown" {
+			usage.Cost = s.pricing.CalculateCost(usage.Model, int(usage.InputTokens), int(usage.OutputTokens),
+				int(usage.CacheCreationInputTokens), int(usage.CacheReadInputTokens))
+		}
+		total, ok := byProvider[provider]
+		if !ok {
+			total = &ModelUsageSummary{Model: provider}
+			byProvider[provider] = total
+		}
+		total.add(usage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read provider usage: %w", err)
+	}
+
+	sessionRows, err := s.db.Query(`
+		SELECT COALESCE(m.provider, 'claude'), COUNT(DISTINCT m.session_id)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+`
+		GROUP BY COALESCE(m.provider, 'claude')
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query provider sessions: %w", err)
+	}
+	defer sessionRows.Close()
+	for sessionRows.Next() {
+		var provider string
+		var count int
+		if err := sessionRows.Scan(&provider, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan provider sessions: %w", err)
+		}
+		if total, ok := byProvider[provider]; ok {
+			total.SessionCount = count
+		}
+	}
+	if err := sessionRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read provider sessions: %w", err)
+	}
+
+	providers := []ModelUsageSummary{}
+	for _, total := range byProvider {
+		providers = append(providers, *total)
+	}
+	return providers, nil
+}
+
+// queryFamilySessions counts the sessions that used each model family, and
+// under "" those that used any model. Session counts cannot be summed since a
+// session may switch models.
+func (s *ModelUsageService) queryFamilySessions(where string, args []interface{}) (map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT
+			CASE
+				WHEN lower(m.model) LIKE '%opus%' THEN 'opus'
+				WHEN lower(m.model) LIKE '%sonnet%' THEN 'sonnet'
+				WHEN lower(m.model) LIKE '%haiku%' THEN 'haiku'
+				ELSE 'other'
+			END AS family,
+			COUNT(DISTINCT m.session_id)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+`
+		GROUP BY ROLLUP (family)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model family sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make(map[string]int)
+	for rows.Next() {
+		var family sql.NullString
+		var count int
+		if err := rows.Scan(&family, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan model family sessions: %w", err)
+		}
+		sessions[family.String] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read model family sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (u *ModelUsageSummary) add(other ModelUsageSummary) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+	u.TotalTokens += other.TotalTokens
+	u.Cost += other.Cost
+	u.MessageCount += other.MessageCount
+}
+
+// finish rounds the cost and fills in the ratios relative to total
+func (u *ModelUsageSummary) finish(total ModelUsageSummary) {
+	if prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens; prompt > 0 {
+		u.CacheHitRatio = float64(u.CacheReadInputTokens) / float64(prompt)
+	}
+	if total.TotalTokens > 0 {
+		u.TokenShare = float64(u.TotalTokens) / float64(total.TotalTokens)
+	}
+	if total.Cost > 0 {
+		u.CostShare = u.Cost / total.Cost
+	}
+	u.Cost = roundToDecimals(u.Cost, 6)
+}
+
+func sortModelUsage(usages []ModelUsageSummary) {
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Cost != usages[j].Cost {
+			return usages[i].Cost > usages[j].Cost
+		}
+		if usages[i].TotalTokens != usages[j].TotalTokens {
+			return usages[i].TotalTokens > usages[j].TotalTokens
+		}
+		return usages[i].Model < usages[j].Model
+	})
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestModelUsageBreakdown(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES
+			('s1', 'api', '/work/api', '2024-03-01 09:00:00'),
+			('s2', 'web', '/work/web', '2024-03-05 09:00:00');
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens,
+			cache_creation_input_tokens, cache_read_input_tokens, timestamp) VALUES
+			('u1', 's1', 'user', NULL, 0, 0, 0, 0, '2024-03-01 09:00:00'),
+			('a1', 's1', 'assistant', 'claude-opus-4-20250514', 1000, 500, 0, 3000, '2024-03-01 09:00:10'),
+			('a2', 's1', 'assistant', 'claude-sonnet-4-20250514', 3000, 1000, 1000, 0, '2024-03-01 09:01:10'),
+			('a3', 's2', 'assistant', 'claude-3-5-sonnet-20241022', 100, 50, 0, 0, '2024-03-05 09:00:10');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	usage := NewModelUsageService(db)
+	report, err := usage.GetModelUsage(ModelUsageQuery{})
+	if err != nil {
+		t.Fatalf("Failed to get model usage: %v", err)
+	}
+	if len(report.Models) != 3 || report.Models[0].Model != "claude-opus-4-20250514" {
+		t.Fatalf("Expected three models with opus first, got %+v", report.Models)
+	}
+	opus := report.Models[0]
+	if opus.Family != ModelFamilyOpus || opus.MessageCount != 1 || opus.SessionCount != 1 {
+		t.Errorf("Unexpected opus usage: %+v", opus)
+	}
+	if opus.CacheHitRatio != 0.75 {
+		t.Errorf("Expected a cache hit ratio of 0.75, got %f", opus.CacheHitRatio)
+	}
+
+	if len(report.Families) != 2 || report.Families[1].Model != ModelFamilySonnet {
+		t.Fatalf("Expected opus and sonnet families, got %+v", report.Families)
+	}
+	sonnet := report.Families[1]
+	if sonnet.TotalTokens != 4150 || sonnet.MessageCount != 2 || sonnet.SessionCount != 2 {
+		t.Errorf("Unexpected sonnet family totals: %+v", sonnet)
+	}
+	if report.Total.TotalTokens != 5650 || report.Total.MessageCount != 3 || report.Total.SessionCount != 2 {
+		t.Errorf("Unexpected totals: %+v", report.Total)
+	}
+	if share := report.Families[0].CostShare + report.Families[1].CostShare; share < 0.999999 || share > 1.000001 {
+		t.Errorf("Expected family cost shares to add up to 1, got %f", share)
+	}
+
+	// One project within a date range
+	report, err = usage.GetModelUsage(ModelUsageQuery{
+		Project: "api",
+		Since:   time.Date(2024, 3, 1, 9, 1, 0, 0, time.UTC),
+		Until:   time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Failed to get model usage: %v", err)
+	}
+	if len(report.Models) != 1 || report.Models[0].Model != "claude-sonnet-4-20250514" || report.Total.SessionCount != 1 {
+		t.Errorf("Expected only the filtered sonnet message, got %+v", report)
+	}
+
+	report, err = usage.GetModelUsage(ModelUsageQuery{Project: "missing"})
+	if err != nil || len(report.Models) != 0 || report.Total.TotalTokens != 0 {
+		t.Errorf("Expected an empty report, got %+v (%v)", report, err)
+	}
+}
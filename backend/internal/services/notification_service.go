@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/notify"
+)
+
+// NotificationCheckInterval is how often the NotificationService looks for
+// a window that has reset
+const NotificationCheckInterval = time.Minute
+
+// NotificationChannelSettings say when a channel notifies
+type NotificationChannelSettings struct {
+	// Thresholds are the window utilizations, e.g. 0.8 for 80%, notified once
+	// per window each; empty uses the warning and critical thresholds
+	Thresholds []float64
+	// WindowReset notifies when a window with usage ends
+	WindowReset bool
+}
+
+type notificationChannel struct {
+	name     string
+	notifier notify.Notifier
+	settings NotificationChannelSettings
+}
+
+// NotificationService sends notifications through its channels, such as the
+// desktop, when the current usage window reaches a threshold or resets
+type NotificationService struct {
+	usage func() (*models.TokenUsage, error)
+
+	mu                sync.Mutex
+	channels          []notificationChannel
+	defaultThresholds []float64
+	// window is the start of the window with usage last seen, and windowEnd
+	// when it resets; notified holds the thresholds notified in it
+	window    time.Time
+	windowEnd time.Time
+	notified  map[string]bool
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewNotificationService creates a service reading the current window with
+// usage, typically TokenService.GetCurrentTokenUsage
+func NewNotificationService(usage func() (*models.TokenUsage, error)) *NotificationService {
+	return &NotificationService{
+		usage:             usage,
+		defaultThresholds: []float64{0.8, 1},
+		notified:          make(map[string]bool),
+		stop:              make(chan struct{}),
+		done:              make(chan struct{}),
+	}
+}
+
+// AddChannel sends notifications through notifier with settings
+func (s *NotificationService) AddChannel(name string, notifier notify.Notifier, settings NotificationChannelSettings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels = append(s.channels, notificationChannel{name: name, notifier: notifier, settings: settings})
+}
+
+// Enabled reports whether any channel was added
+func (s *NotificationService) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.channels) > 0
+}
+
+// SetDefaultThresholds sets the thresholds of channels that configure none,
+// normally the warning and critical thresholds of the runtime settings
+func (s *NotificationService) SetDefaultThresholds(thresholds ...float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultThresholds = thresholds
+}
+
+// Start checks every NotificationCheckInterval until Stop, so a reset is
+// noticed even when nothing is synced
+func (s *NotificationService) Start() {
+	go s.run()
+}
+
+// Stop ends the background goroutine after its current check
+func (s *NotificationService) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+func (s *NotificationService) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(NotificationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.Check(time.Now())
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Check notifies the thresholds the current window reached since the last
+// check, and the reset of the window last seen once now is past its end
+func (s *NotificationService) Check(now time.Time) {
+	usage, err := s.usage()
+	if err != nil {
+		logging.Component("notifications").Warn("Failed to check window utilization", "err", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.windowEnd.IsZero() && !now.Before(s.windowEnd) {
+		for _, channel := range s.channels {
+			if channel.settings.WindowReset {
+				s.send(channel, "Usage window reset", "Your Claude usage window has reset; the full limit is available again.")
+			}
+		}
+		s.window, s.windowEnd = time.Time{}, time.Time{}
+		s.notified = make(map[string]bool)
+	}
+
+	// Without usage the current window is a placeholder starting now
+	if usage == nil || usage.TotalTokens == 0 || usage.UsageLimit <= 0 || !now.Before(usage.WindowEnd) {
+		return
+	}
+	if !usage.WindowStart.Equal(s.window) {
+		s.window = usage.WindowStart
+		s.notified = make(map[string]bool)
+	}
+	s.windowEnd = usage.WindowEnd
+
+	for _, channel := range s.channels {
+		thresholds := channel.settings.Thresholds
+		if len(thresholds) == 0 {
+			thresholds = s.defaultThresholds
+		}
+		// Only the highest threshold reached is shown, so a jump from 50% to
+		// 100% is one notification
+		highest := 0.0
+		for _, threshold := range thresholds {
+			key := channel.name + ":" + strconv.FormatFloat(threshold, 'g', -1, 64)
+			if threshold <= 0 || usage.UsageRate < threshold || s.notified[key] {
+				continue
+			}
+			s.notified[key] = true
+			if threshold > highest {
+				highest = threshold
+			}
+		}
+		if highest > 0 {
+			s.send(channel, fmt.Sprintf("Claude usage at %.0f%%", usage.UsageRate*100),
+				fmt.Sprintf("%d of %d tokens used in this window; it resets at %s.", usage.TotalTokens, usage.UsageLimit, usage.WindowEnd.Local().Format("15:04")))
+		}
+	}
+}
+
+// send shows a notification in the background so a slow notifier does not
+// hold up checks
+func (s *NotificationService) send(channel notificationChannel, title, message string) {
+	go func() {
+		if err := channel.notifier.Notify(context.Background(), title, message); err != nil {
+			logging.Component("notifications").Warn("Failed to send notification", "channel", channel.name, "err", err)
+		}
+	}()
+}
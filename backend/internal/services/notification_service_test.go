@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+type recordingNotifier chan string
+
+func (n recordingNotifier) Notify(ctx context.Context, title, message string) error {
+	n <- title
+	return nil
+}
+
+// expect waits for the titles the notifier is sent, and checks
+// nothing else follows
+func (n recordingNotifier) expect(t *testing.T, titles ...string) {
+	t.Helper()
+	for _, expected := range titles {
+		select {
+		case title := <-n:
+			if !strings.Contains(title, expected) {
+				t.Errorf("Expected a notification %q, got %q", expected, title)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected a notification %q", expected)
+		}
+	}
+	select {
+	case title := <-n:
+		t.Errorf("Unexpected notification %q", title)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestNotificationService(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	usage := &models.TokenUsage{UsageLimit: 1000, WindowStart: start, WindowEnd: start.Add(5 * time.Hour)}
+	service := NewNotificationService(func() (*models.TokenUsage, error) {
+		copied := *usage
+		return &copied, nil
+	})
+	notifier := make(recordingNotifier, 10)
+	service.AddChannel("desktop", notifier, NotificationChannelSettings{Thresholds: []float64{0.5, 0.8, 1}, WindowReset: true})
+
+	setUsage := func(tokens int) {
+		usage.TotalTokens = tokens
+		usage.UsageRate = float64(tokens) / float64(usage.UsageLimit)
+	}
+
+	setUsage(400)
+	service.Check(start.Add(time.Hour))
+	notifier.expect(t)
+
+	setUsage(600)
+	service.Check(start.Add(2 * time.Hour))
+	notifier.expect(t, "60%")
+	service.Check(start.Add(2 * time.Hour))
+	notifier.expect(t)
+
+	// Passing two thresholds at once is one notification
+	setUsage(1000)
+	service.Check(start.Add(3 * time.Hour))
+	notifier.expect(t, "100%")
+
+	// The window ends, and the next check sees the placeholder of a new one
+	usage.WindowStart, usage.WindowEnd = start.Add(5*time.Hour), start.Add(10*time.Hour)
+	setUsage(0)
+	service.Check(start.Add(5 * time.Hour))
+	notifier.expect(t, "reset")
+	service.Check(start.Add(6 * time.Hour))
+	notifier.expect(t)
+
+	// Thresholds are notified again in the next window
+	setUsage(500)
+	service.Check(start.Add(7 * time.Hour))
+	notifier.expect(t, "50%")
+}
+
+func TestNotificationServiceDefaultThresholds(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	service := NewNotificationService(func() (*models.TokenUsage, error) {
+		return &models.TokenUsage{TotalTokens: 700, UsageLimit: 1000, UsageRate: 0.7, WindowStart: start, WindowEnd: start.Add(5 * time.Hour)}, nil
+	})
+	notifier := make(recordingNotifier, 10)
+	service.AddChannel("desktop", notifier, NotificationChannelSettings{})
+
+	service.Check(start.Add(time.Hour))
+	notifier.expect(t)
+
+	service.SetDefaultThresholds(0.6, 0.9)
+	service.Check(start.Add(time.Hour))
+	notifier.expect(t, "70%")
+
+	// Without WindowReset the end of the window is silent
+	service.Check(start.Add(6 * time.Hour))
+	notifier.expect(t)
+}
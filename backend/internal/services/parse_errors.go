@@ -0,0 +1,169 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"claudeee-backend/internal/logging"
+)
+
+// maxParseErrorLineBytes bounds how much of a malformed line is kept
+const maxParseErrorLineBytes = 16 * 1024
+
+// MaxParseErrorPageSize is the most parse errors returned at once
+const MaxParseErrorPageSize = 1000
+
+// lineError is a log line that could not be parsed
+type lineError struct {
+	line int
+	err  string
+	raw  string
+}
+
+func newLineError(line int, err error, raw string) lineError {
+	return lineError{line: line, err: err.Error(), raw: truncateUTF8(raw, maxParseErrorLineBytes)}
+}
+
+// ParseError is a quarantined log line. RawContent follows the content
+// policy: it is redacted, truncated or left out like message content.
+type ParseError struct {
+	FilePath   string    `json:"file_path"`
+	LineNumber int       `json:"line_number"`
+	Error      string    `json:"error"`
+	RawContent *string   `json:"raw_content,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// ParseErrorQuery filters the quarantined lines; Limit defaults to 100
+type ParseErrorQuery struct {
+	// FilePath keeps the lines of files whose path contains it
+	FilePath string
+	Limit    int
+	Offset   int
+}
+
+// recordParseErrors quarantines the malformed lines of a chunk. Failing to
+// store them only loses the report, so the error is logged, not returned.
+func (d *DiffSyncService) recordParseErrors(filePath string, errs []lineError) {
+	if len(errs) == 0 {
+		return
+	}
+	err := d.writes.Do(func() error { return d.storeParseErrors(filePath, errs) })
+	if err != nil {
+		logging.Component("sync").Error("Failed to record parse errors", "file", filePath, "err", err)
+	}
+}
+
+func (d *DiffSyncService) storeParseErrors(filePath string, errs []lineError) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, e := range errs {
+		// The line may hold conversation content, so it is stored like it
+		raw := d.cipher.Seal(d.contentPolicy.Apply(d.redact(e.raw)))
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO parse_errors (file_path, line_number, error, raw_content, detected_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, filePath, e.line, e.err, raw, now)
+		if err != nil {
+			return fmt.Errorf("failed to record parse error at %s:%d: %w", filePath, e.line, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit parse errors: %w", err)
+	}
+	return nil
+}
+
+// GetParseErrors returns quarantined lines, most recently found first, and
+// how many match the query. Encrypted lines are opened with c.
+func GetParseErrors(db *sql.DB, q ParseErrorQuery, c *ContentCipher) ([]ParseError, int, error) {
+	if q.Limit <= 0 {
+		q.Limit = 100
+	}
+	where := ""
+	var args []interface{}
+	if q.FilePath != "" {
+		where = `WHERE file_path LIKE ? ESCAPE '\'`
+		args = append(args, likePattern(q.FilePath))
+	}
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM parse_errors `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count parse errors: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT file_path, line_number, error, raw_content, detected_at FROM parse_errors `+where+`
+		ORDER BY detected_at DESC, file_path, line_number
+		LIMIT ? OFFSET ?
+	`, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get parse errors: %w", err)
+	}
+	defer rows.Close()
+
+	errs := []ParseError{}
+	for rows.Next() {
+		var e ParseError
+		var raw sql.NullString
+		if err := rows.Scan(&e.FilePath, &e.LineNumber, &e.Error, &raw, &e.DetectedAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan parse error: %w", err)
+		}
+		if raw.Valid {
+			e.RawContent = c.Open(&raw.String)
+		}
+		errs = append(errs, e)
+	}
+	return errs, total, rows.Err()
+}
+
+// rewriteParseErrors passes the stored raw lines, of all errors or those
+// matching condition, through rewrite and writes back the ones it changes;
+// a nil line is cleared. It returns the number of lines changed.
+func rewriteParseErrors(db *sql.DB, condition string, args []interface{}, rewrite func(raw string) (*string, bool)) (int64, error) {
+	type lineKey struct {
+		file string
+		line int
+	}
+	where := "raw_content IS NOT NULL"
+	if condition != "" {
+		where += " AND " + condition
+	}
+	rows, err := db.Query(`SELECT file_path, line_number, raw_content FROM parse_errors WHERE `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read parse errors: %w", err)
+	}
+	rewritten := map[lineKey]*string{}
+	for rows.Next() {
+		var key lineKey
+		var raw string
+		if err := rows.Scan(&key.file, &key.line, &raw); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan parse error: %w", err)
+		}
+		if next, ok := rewrite(raw); ok {
+			rewritten[key] = next
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read parse errors: %w", err)
+	}
+
+	var changed int64
+	for key, raw := range rewritten {
+		_, err := db.Exec(`UPDATE parse_errors SET raw_content = ? WHERE file_path = ? AND line_number = ?`, raw, key.file, key.line)
+		if err != nil {
+			return changed, fmt.Errorf("failed to rewrite parse error at %s:%d: %w", key.file, key.line, err)
+		}
+		changed++
+	}
+	return changed, nil
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestMalformedLinesAreQuarantined(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	diffSync := NewDiffSyncService(db, NewTokenService(db), NewSessionService(db))
+	diffSync.SetRedactor(NewRedactor())
+
+	dir := t.TempDir()
+	write := func(name string, lines ...string) string {
+		path := filepath.Join(dir, "-work-app", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	full := write("full.jsonl",
+		`{"uuid":"1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"hi"}}`,
+		`{"uuid":"2","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:01:00Z","message":{"role":"user","content":"key sk-ant-REDACTED"`,
+		`{"uuid":"3","sessionId":"s1","timestamp":"yesterday","message":{"role":"user"}}`,
+	)
+	if _, _, err := diffSync.processFileFromLine(full, 0); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+	diffSync.SetContentPolicy(ContentPolicy{Mode: ContentPolicyMetadata})
+	metadata := write("metadata.jsonl", `{"broken`)
+	if _, _, err := diffSync.processFileFromLine(metadata, 0); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+	if diffSync.parseErrors != 3 {
+		t.Errorf("Expected 3 parse errors counted, got %d", diffSync.parseErrors)
+	}
+
+	errs, total, err := GetParseErrors(db, ParseErrorQuery{FilePath: "full.jsonl"}, nil)
+	if err != nil {
+		t.Fatalf("GetParseErrors failed: %v", err)
+	}
+	if total != 2 || len(errs) != 2 || errs[0].LineNumber != 2 || errs[1].LineNumber != 3 {
+		t.Fatalf("Expected lines 2 and 3 of full.jsonl, got %d: %+v", total, errs)
+	}
+	if raw := errs[0].RawContent; raw == nil || !strings.Contains(*raw, `"uuid":"2"`) || strings.Contains(*raw, "sk-ant-") {
+		t.Errorf("Expected the redacted line, got %v", raw)
+	}
+	if errs[1].Error == "" {
+		t.Error("Expected the decode error to be kept")
+	}
+
+	errs, total, err = GetParseErrors(db, ParseErrorQuery{FilePath: "metadata"}, nil)
+	if err != nil {
+		t.Fatalf("GetParseErrors failed: %v", err)
+	}
+	if total != 1 || errs[0].RawContent != nil {
+		t.Errorf("Expected the metadata policy to leave the line out, got %+v", errs)
+	}
+}
+
+// insertParseError stores a quarantined line as an earlier configuration
+// would have
+func insertParseError(t *testing.T, db *sql.DB, line int, raw string, detectedAt time.Time) {
+	t.Helper()
+	_, err := db.Exec(`
+		INSERT INTO parse_errors (file_path, line_number, error, raw_content, detected_at)
+		VALUES ('/logs/a.jsonl', ?, 'invalid JSON', ?, ?)
+	`, line, raw, detectedAt)
+	if err != nil {
+		t.Fatalf("Failed to insert parse error: %v", err)
+	}
+}
+
+// storedParseError returns the raw line stored for a quarantined line
+func storedParseError(t *testing.T, db *sql.DB, line int) *string {
+	t.Helper()
+	var raw sql.NullString
+	if err := db.QueryRow(`SELECT raw_content FROM parse_errors WHERE line_number = ?`, line).Scan(&raw); err != nil {
+		t.Fatalf("Failed to query parse error: %v", err)
+	}
+	if !raw.Valid {
+		return nil
+	}
+	return &raw.String
+}
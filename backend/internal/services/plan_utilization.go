@@ -0,0 +1,64 @@
+package services
+
+import (
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// PlanUtilization is the share of the plan's token limit used in the current
+// 5-hour window and, at the window's average pace, when the limit is reached
+type PlanUtilization struct {
+	Plan               string    `json:"plan"`
+	UsageLimit         int       `json:"usage_limit"`
+	UsedTokens         int       `json:"used_tokens"`
+	RemainingTokens    int       `json:"remaining_tokens"`
+	UtilizationPercent float64   `json:"utilization_percent"`
+	WindowStart        time.Time `json:"window_start"`
+	WindowEnd          time.Time `json:"window_end"`
+	// TokensPerMinute is the average burn rate since the window started
+	TokensPerMinute float64 `json:"tokens_per_minute"`
+	// The estimates are nil while nothing has been used in the window
+	EstimatedMinutesToLimit *float64   `json:"estimated_minutes_to_limit"`
+	EstimatedLimitAt        *time.Time `json:"estimated_limit_at"`
+	// LimitBeforeReset reports whether the limit is reached before the window ends
+	LimitBeforeReset bool `json:"limit_before_reset"`
+}
+
+// ComputePlanUtilization derives the utilization of plan from the current
+// window's token usage
+func ComputePlanUtilization(plan string, usage *models.TokenUsage, now time.Time) PlanUtilization {
+	u := PlanUtilization{
+		Plan:        plan,
+		UsageLimit:  usage.UsageLimit,
+		UsedTokens:  usage.TotalTokens,
+		WindowStart: usage.WindowStart,
+		WindowEnd:   usage.WindowEnd,
+	}
+	if u.UsageLimit > 0 {
+		u.UtilizationPercent = roundToDecimals(float64(u.UsedTokens)/float64(u.UsageLimit)*100, 2)
+	}
+	u.RemainingTokens = u.UsageLimit - u.UsedTokens
+	if u.RemainingTokens <= 0 {
+		u.RemainingTokens = 0
+		minutes := 0.0
+		u.EstimatedMinutesToLimit = &minutes
+		u.EstimatedLimitAt = &now
+		u.LimitBeforeReset = true
+		return u
+	}
+
+	elapsed := now.Sub(usage.WindowStart)
+	if u.UsedTokens == 0 || elapsed <= 0 {
+		return u
+	}
+	u.TokensPerMinute = roundToDecimals(float64(u.UsedTokens)/elapsed.Minutes(), 2)
+
+	minutes := float64(u.RemainingTokens) / (float64(u.UsedTokens) / elapsed.Minutes())
+	limitAt := now.Add(time.Duration(minutes * float64(time.Minute))).Truncate(time.Second)
+	minutes = roundToDecimals(minutes, 1)
+	u.EstimatedMinutesToLimit = &minutes
+	u.EstimatedLimitAt = &limitAt
+	u.LimitBeforeReset = limitAt.Before(usage.WindowEnd)
+	return u
+}
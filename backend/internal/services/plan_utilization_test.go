@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+func TestComputePlanUtilization(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	usage := &models.TokenUsage{
+		TotalTokens: 2000,
+		UsageLimit:  CLAUDE_PRO_LIMIT,
+		WindowStart: start,
+		WindowEnd:   start.Add(WINDOW_DURATION),
+	}
+
+	// 2000 tokens in 60 minutes leaves 5000 tokens for 150 more minutes
+	u := ComputePlanUtilization(PlanPro, usage, start.Add(time.Hour))
+	if u.UtilizationPercent != 28.57 || u.RemainingTokens != 5000 || u.TokensPerMinute != 33.33 {
+		t.Errorf("Unexpected utilization: %+v", u)
+	}
+	if u.EstimatedMinutesToLimit == nil || *u.EstimatedMinutesToLimit != 150 {
+		t.Fatalf("Expected 150 minutes to the limit, got %v", u.EstimatedMinutesToLimit)
+	}
+	if !u.EstimatedLimitAt.Equal(start.Add(210*time.Minute)) || !u.LimitBeforeReset {
+		t.Errorf("Expected the limit at 13:30, before the reset, got %v", u.EstimatedLimitAt)
+	}
+
+	// A slower pace reaches the limit after the window resets
+	usage.TotalTokens = 500
+	if u := ComputePlanUtilization(PlanPro, usage, start.Add(time.Hour)); u.LimitBeforeReset {
+		t.Errorf("Expected the limit after the reset, got %+v", u)
+	}
+
+	// An unused window has no estimate
+	usage.TotalTokens = 0
+	if u := ComputePlanUtilization(PlanPro, usage, start.Add(time.Hour)); u.EstimatedMinutesToLimit != nil || u.TokensPerMinute != 0 {
+		t.Errorf("Expected no estimate for an unused window, got %+v", u)
+	}
+
+	// An exhausted plan reports the limit as reached now
+	usage.TotalTokens = 8000
+	u = ComputePlanUtilization(PlanPro, usage, start.Add(time.Hour))
+	if u.RemainingTokens != 0 || u.EstimatedMinutesToLimit == nil || *u.EstimatedMinutesToLimit != 0 || u.UtilizationPercent <= 100 {
+		t.Errorf("Expected an exhausted plan, got %+v", u)
+	}
+}
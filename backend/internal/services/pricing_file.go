@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"github.com/fsnotify/fsnotify"
+)
+
+// PricingFileName is the pricing file looked for in the data directory
+const PricingFileName = "pricing.json"
+
+// Cache token prices relative to the input price, used when a pricing file
+// gives neither a cache price nor a multiplier
+const (
+	DefaultCacheCreationMultiplier = 1.25
+	DefaultCacheReadMultiplier     = 0.1
+)
+
+// PricingFile is the pricing.json format. Models are keyed like
+// SetPricingOverrides keys: exact names, glob patterns or families. A cache
+// price missing from a model is its input price times the model's multiplier,
+// the file's multiplier, or the default.
+type PricingFile struct {
+	CacheCreationMultiplier *float64                    `json:"cache_creation_multiplier"`
+	CacheReadMultiplier     *float64                    `json:"cache_read_multiplier"`
+	Models                  map[string]PricingFileModel `json:"models"`
+}
+
+// PricingFileModel is the USD price per million tokens of a model in pricing.json
+type PricingFileModel struct {
+	Input                   float64  `json:"input"`
+	Output                  float64  `json:"output"`
+	CacheCreation           *float64 `json:"cache_creation"`
+	CacheRead               *float64 `json:"cache_read"`
+	CacheCreationMultiplier *float64 `json:"cache_creation_multiplier"`
+	CacheReadMultiplier     *float64 `json:"cache_read_multiplier"`
+}
+
+// LoadPricingFile reads the prices of a pricing file, or none when it does
+// not exist
+func LoadPricingFile(file string) (map[string]ModelPrice, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	var pf PricingFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&pf); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	fileCreation := pricingMultiplier(pf.CacheCreationMultiplier, DefaultCacheCreationMultiplier)
+	fileRead := pricingMultiplier(pf.CacheReadMultiplier, DefaultCacheReadMultiplier)
+	prices := make(map[string]ModelPrice, len(pf.Models))
+	for model, m := range pf.Models {
+		if strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("invalid pricing in %s: empty model name", file)
+		}
+		if _, err := path.Match(strings.ToLower(model), ""); err != nil {
+			return nil, fmt.Errorf("invalid pricing pattern %q in %s: %w", model, file, err)
+		}
+		price := ModelPrice{
+			Input:         m.Input,
+			Output:        m.Output,
+			CacheCreation: m.Input * pricingMultiplier(m.CacheCreationMultiplier, fileCreation),
+			CacheRead:     m.Input * pricingMultiplier(m.CacheReadMultiplier, fileRead),
+		}
+		if m.CacheCreation != nil {
+			price.CacheCreation = *m.CacheCreation
+		}
+		if m.CacheRead != nil {
+			price.CacheRead = *m.CacheRead
+		}
+		if price.Input < 0 || price.Output < 0 || price.CacheCreation < 0 || price.CacheRead < 0 {
+			return nil, fmt.Errorf("invalid pricing for %q in %s: prices cannot be negative", model, file)
+		}
+		prices[model] = price
+	}
+	return prices, nil
+}
+
+func pricingMultiplier(value *float64, fallback float64) float64 {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+// PricingWatcher applies a pricing file on top of configured prices and
+// reapplies it whenever the file changes
+type PricingWatcher struct {
+	file string
+	base map[string]ModelPrice
+
+	mu       sync.Mutex
+	watcher  *fsnotify.Watcher
+	done     chan struct{}
+	onReload []func()
+}
+
+// NewPricingWatcher creates a watcher for file. Prices in the file win over
+// the base prices, which come from the configuration.
+func NewPricingWatcher(file string, base map[string]ModelPrice) *PricingWatcher {
+	return &PricingWatcher{file: file, base: base}
+}
+
+// OnReload registers fn to run after the file changed prices
+func (w *PricingWatcher) OnReload(fn func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onReload = append(w.onReload, fn)
+}
+
+// Load applies the base prices and the file's. On error the prices in
+// effect are kept.
+func (w *PricingWatcher) Load() error {
+	filePrices, err := LoadPricingFile(w.file)
+	if err != nil {
+		return err
+	}
+	prices := make(map[string]ModelPrice, len(w.base)+len(filePrices))
+	for model, price := range w.base {
+		prices[strings.ToLower(strings.TrimSpace(model))] = price
+	}
+	for model, price := range filePrices {
+		prices[strings.ToLower(strings.TrimSpace(model))] = price
+	}
+	SetPricingOverrides(prices)
+	return nil
+}
+
+// Start watches the file's directory, which sees the file created, replaced
+// or removed, and reloads prices after changes settle
+func (w *PricingWatcher) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watcher != nil {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(w.file)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(w.file), err)
+	}
+	w.watcher = watcher
+	w.done = make(chan struct{})
+	go w.run(watcher, w.done)
+	return nil
+}
+
+// Stop ends watching; it is a no-op when the watcher is not running
+func (w *PricingWatcher) Stop() {
+	w.mu.Lock()
+	if w.watcher == nil {
+		w.mu.Unlock()
+		return
+	}
+	w.watcher.Close()
+	w.watcher = nil
+	done := w.done
+	w.mu.Unlock()
+	<-done
+}
+
+// pricingReloadDelay lets an editor finish writing before the file is read
+const pricingReloadDelay = 200 * time.Millisecond
+
+func (w *PricingWatcher) run(watcher *fsnotify.Watcher, done chan struct{}) {
+	defer close(done)
+	logger := logging.Component("pricing")
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) == filepath.Clean(w.file) {
+				timer.Reset(pricingReloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("Pricing file watcher error", "err", err)
+		case <-timer.C:
+			if err := w.Load(); err != nil {
+				logger.Warn("Failed to reload pricing; keeping the previous prices", "file", w.file, "err", err)
+				continue
+			}
+			logger.Info("Reloaded pricing", "file", w.file)
+			w.mu.Lock()
+			callbacks := append([]func(){}, w.onReload...)
+			w.mu.Unlock()
+			for _, fn := range callbacks {
+				fn()
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPricingFileOverridesAndReloads(t *testing.T) {
+	defer SetPricingOverrides(nil)
+	file := filepath.Join(t.TempDir(), PricingFileName)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{
+		"cache_read_multiplier": 0.2,
+		"models": {
+			"claude-opus-5*": {"input": 20, "output": 100},
+			"claude-opus-5-mini*": {"input": 2, "output": 10, "cache_creation_multiplier": 2},
+			"sonnet": {"input": 4, "output": 16, "cache_read": 1}
+		}
+	}`)
+
+	watcher := NewPricingWatcher(file, map[string]ModelPrice{
+		"sonnet": {Input: 1, Output: 1},
+		"haiku":  {Input: 1, Output: 1},
+	})
+	if err := watcher.Load(); err != nil {
+		t.Fatalf("Failed to load pricing: %v", err)
+	}
+	pc := NewPricingCalculator()
+
+	// An unknown future model priced by pattern, cache prices derived from input
+	if cost := pc.CalculateCost("claude-opus-5-20260101", 1_000_000, 0, 1_000_000, 1_000_000); cost != 20+25+4 {
+		t.Errorf("Expected the opus-5 pattern price, got %v", cost)
+	}
+	// The more specific pattern wins
+	if cost := pc.CalculateCost("claude-opus-5-mini-20260101", 0, 1_000_000, 1_000_000, 0); cost != 10+4 {
+		t.Errorf("Expected the opus-5-mini pattern price, got %v", cost)
+	}
+	// The file wins over the configuration, which still applies elsewhere
+	if cost := pc.CalculateCost("claude-sonnet-4-20250514", 1_000_000, 0, 0, 1_000_000); cost != 5 {
+		t.Errorf("Expected the file's sonnet price, got %v", cost)
+	}
+	if cost := pc.CalculateCost("claude-3-5-haiku", 1_000_000, 0, 0, 0); cost != 1 {
+		t.Errorf("Expected the configured haiku price, got %v", cost)
+	}
+
+	reloaded := make(chan struct{}, 1)
+	watcher.OnReload(func() { reloaded <- struct{}{} })
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("Failed to watch pricing: %v", err)
+	}
+	defer watcher.Stop()
+
+	// A broken edit keeps the previous prices
+	write(`{"models": {"sonnet": {"input": -1}}}`)
+	time.Sleep(3 * pricingReloadDelay)
+	if cost := pc.CalculateCost("claude-sonnet-4-20250514", 1_000_000, 0, 0, 0); cost != 4 {
+		t.Errorf("Expected the previous price after an invalid edit, got %v", cost)
+	}
+
+	// Existing calculators see the new prices
+	write(`{"models": {"sonnet": {"input": 8, "output": 16}}}`)
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the pricing file to be reloaded")
+	}
+	if cost := pc.CalculateCost("claude-sonnet-4-20250514", 1_000_000, 0, 0, 0); cost != 8 {
+		t.Errorf("Expected the reloaded sonnet price, got %v", cost)
+	}
+	if cost := pc.CalculateCost("claude-opus-5-20260101", 1_000_000, 0, 0, 0); cost != 15 {
+		t.Errorf("Expected the removed pattern to fall back to the built-in price, got %v", cost)
+	}
+}
+
+func TestLoadPricingFileRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	if prices, err := LoadPricingFile(filepath.Join(dir, PricingFileName)); err != nil || prices != nil {
+		t.Errorf("Expected no prices without a file, got %v (%v)", prices, err)
+	}
+	for name, content := range map[string]string{
+		"unknown key":  `{"modelz": {}}`,
+		"bad pattern":  `{"models": {"claude-[": {"input": 1}}}`,
+		"negative":     `{"models": {"opus": {"input": 1, "cache_read": -1}}}`,
+		"invalid json": `{"models":`,
+	} {
+		file := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadPricingFile(file); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPricingRegistryURL is LiteLLM's model price list
+const DefaultPricingRegistryURL = "https://raw.githubusercontent.com/BerriAI/litellm/main/model_prices_and_context_window.json"
+
+// PricingRegistryCacheName is the file in the data directory that keeps the
+// last fetched registry prices for offline starts
+const PricingRegistryCacheName = "pricing-registry.json"
+
+// maxPricingRegistryBytes bounds the registry download
+const maxPricingRegistryBytes = 32 << 20
+
+var (
+	registryPricingMu sync.RWMutex
+	registryPricing   map[string]map[string]float64
+)
+
+// SetRegistryPricing sets prices fetched from a price registry, keyed by exact
+// model name. They replace the built-in prices of those models but yield to
+// SetPricingOverrides.
+func SetRegistryPricing(prices map[string]ModelPrice) {
+	registry := make(map[string]map[string]float64, len(prices))
+	for model, price := range prices {
+		registry[strings.ToLower(strings.TrimSpace(model))] = map[string]float64{
+			"input":          price.Input,
+			"output":         price.Output,
+			"cache_creation": price.CacheCreation,
+			"cache_read":     price.CacheRead,
+		}
+	}
+	registryPricingMu.Lock()
+	registryPricing = registry
+	registryPricingMu.Unlock()
+}
+
+// lookupRegistryPricing returns the registry price of a model
+func lookupRegistryPricing(model string) (map[string]float64, bool) {
+	registryPricingMu.RLock()
+	defer registryPricingMu.RUnlock()
+	pricing, ok := registryPricing[strings.ToLower(strings.TrimSpace(model))]
+	return pricing, ok
+}
+
+// liteLLMModel is the part of a LiteLLM registry entry that prices a model.
+// Costs are USD per token.
+type liteLLMModel struct {
+	Provider      string   `json:"litellm_provider"`
+	Mode          string   `json:"mode"`
+	Input         *float64 `json:"input_cost_per_token"`
+	Output        *float64 `json:"output_cost_per_token"`
+	CacheCreation *float64 `json:"cache_creation_input_token_cost"`
+	CacheRead     *float64 `json:"cache_read_input_token_cost"`
+}
+
+// ParseLiteLLMPrices returns the Anthropic model prices of a LiteLLM registry,
+// per million tokens. Entries for other providers, such as Bedrock or Vertex
+// AI resellers, are skipped.
+func ParseLiteLLMPrices(data []byte) (map[string]ModelPrice, error) {
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing registry: %w", err)
+	}
+
+	prices := make(map[string]ModelPrice)
+	for name, raw := range entries {
+		var entry liteLLMModel
+		// The registry documents itself in an entry of another shape
+		if json.Unmarshal(raw, &entry) != nil || entry.Provider != "anthropic" {
+			continue
+		}
+		if entry.Mode != "" && entry.Mode != "chat" {
+			continue
+		}
+		if entry.Input == nil || entry.Output == nil {
+			continue
+		}
+		name = strings.TrimPrefix(strings.ToLower(name), "anthropic/")
+		if !strings.HasPrefix(name, "claude") {
+			continue
+		}
+		price := ModelPrice{
+			Input:         *entry.Input * 1_000_000,
+			Output:        *entry.Output * 1_000_000,
+			CacheCreation: *entry.Input * 1_000_000 * DefaultCacheCreationMultiplier,
+			CacheRead:     *entry.Input * 1_000_000 * DefaultCacheReadMultiplier,
+		}
+		if entry.CacheCreation != nil {
+			price.CacheCreation = *entry.CacheCreation * 1_000_000
+		}
+		if entry.CacheRead != nil {
+			price.CacheRead = *entry.CacheRead * 1_000_000
+		}
+		if price.Input < 0 || price.Output < 0 || price.CacheCreation < 0 || price.CacheRead < 0 {
+			continue
+		}
+		prices[name] = price
+	}
+	if len(prices) == 0 {
+		return nil, errors.New("pricing registry lists no Anthropic models")
+	}
+	return prices, nil
+}
+
+// registryCache is the pricing-registry.json format
+type registryCache struct {
+	URL       string                   `json:"url"`
+	FetchedAt time.Time                `json:"fetched_at"`
+	Models    map[string]registryPrice `json:"models"`
+}
+
+type registryPrice struct {
+	Input         float64 `json:"input"`
+	Output        float64 `json:"output"`
+	CacheCreation float64 `json:"cache_creation"`
+	CacheRead     float64 `json:"cache_read"`
+}
+
+// PricingUpdater keeps registry prices current. Fetched prices are cached so
+// an offline start uses the last ones; without a cache the built-in prices
+// stay in effect.
+type PricingUpdater struct {
+	Client    *http.Client
+	URL       string
+	CachePath string
+}
+
+func NewPricingUpdater(url, cachePath string) *PricingUpdater {
+	return &PricingUpdater{
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		URL:       url,
+		CachePath: cachePath,
+	}
+}
+
+// LoadCache applies the cached prices and returns how many models they cover,
+// or 0 when nothing has been cached yet
+func (u *PricingUpdater) LoadCache() (int, error) {
+	data, err := os.ReadFile(u.CachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read pricing cache: %w", err)
+	}
+	var cache registryCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return 0, fmt.Errorf("failed to parse pricing cache %s: %w", u.CachePath, err)
+	}
+	prices := make(map[string]ModelPrice, len(cache.Models))
+	for model, price := range cache.Models {
+		prices[model] = ModelPrice(price)
+	}
+	SetRegistryPricing(prices)
+	return len(prices), nil
+}
+
+// Update fetches the registry, applies its prices and caches them, returning
+// how many models they cover. On error the prices in effect are kept.
+func (u *PricingUpdater) Update(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch pricing registry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to fetch pricing registry: server answered %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPricingRegistryBytes))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read pricing registry: %w", err)
+	}
+
+	prices, err := ParseLiteLLMPrices(data)
+	if err != nil {
+		return 0, err
+	}
+	SetRegistryPricing(prices)
+
+	cache := registryCache{URL: u.URL, FetchedAt: time.Now().UTC(), Models: make(map[string]registryPrice, len(prices))}
+	for model, price := range prices {
+		cache.Models[model] = registryPrice(price)
+	}
+	if err := writeRegistryCache(u.CachePath, cache); err != nil {
+		return len(prices), err
+	}
+	return len(prices), nil
+}
+
+// writeRegistryCache writes the cache through a temporary file so a crash
+// never leaves it half written
+func writeRegistryCache(path string, cache registryCache) error {
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pricing cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create pricing cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write pricing cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write pricing cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+const liteLLMSample = `{
+	"sample_spec": {"input_cost_per_token": "see docs", "litellm_provider": "one of the providers"},
+	"claude-opus-5-20260101": {
+		"litellm_provider": "anthropic", "mode": "chat",
+		"input_cost_per_token": 1e-05, "output_cost_per_token": 5e-05,
+		"cache_creation_input_token_cost": 1.25e-05, "cache_read_input_token_cost": 1e-06
+	},
+	"anthropic/claude-haiku-5": {
+		"litellm_provider": "anthropic", "mode": "chat",
+		"input_cost_per_token": 1e-06, "output_cost_per_token": 5e-06
+	},
+	"bedrock/anthropic.claude-opus-5": {
+		"litellm_provider": "bedrock", "input_cost_per_token": 1, "output_cost_per_token": 1
+	},
+	"gpt-9": {"litellm_provider": "openai", "input_cost_per_token": 1, "output_cost_per_token": 1}
+}`
+
+func TestPricingUpdaterFetchesAndCaches(t *testing.T) {
+	defer SetRegistryPricing(nil)
+	defer SetPricingOverrides(nil)
+
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(liteLLMSample))
+	}))
+	defer server.Close()
+
+	cachePath := filepath.Join(t.TempDir(), PricingRegistryCacheName)
+	updater := NewPricingUpdater(server.URL, cachePath)
+	if n, err := updater.LoadCache(); err != nil || n != 0 {
+		t.Errorf("Expected no cached prices yet, got %d (%v)", n, err)
+	}
+
+	n, err := updater.Update(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to update prices: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected the two Anthropic models, got %d", n)
+	}
+	pc := NewPricingCalculator()
+	if cost := pc.CalculateCost("claude-opus-5-20260101", 1_000_000, 1_000_000, 1_000_000, 1_000_000); cost != 10+50+12.5+1 {
+		t.Errorf("Expected the registry opus price, got %v", cost)
+	}
+	// Without cache prices in the registry the default multipliers apply
+	if cost := pc.CalculateCost("claude-haiku-5", 1_000_000, 0, 1_000_000, 1_000_000); cost != 1+1.25+0.1 {
+		t.Errorf("Expected the registry haiku price, got %v", cost)
+	}
+	// Models missing from the registry keep the built-in price
+	if cost := pc.CalculateCost("claude-sonnet-4-20250514", 1_000_000, 0, 0, 0); cost != 3 {
+		t.Errorf("Expected the built-in sonnet price, got %v", cost)
+	}
+	// Configured prices still win
+	SetPricingOverrides(map[string]ModelPrice{"opus": {Input: 1}})
+	if cost := pc.CalculateCost("claude-opus-5-20260101", 1_000_000, 0, 0, 0); cost != 1 {
+		t.Errorf("Expected the configured opus price, got %v", cost)
+	}
+	SetPricingOverrides(nil)
+
+	// Offline: the update fails and the cache restores the last prices
+	fail = true
+	SetRegistryPricing(nil)
+	if _, err := updater.Update(context.Background()); err == nil {
+		t.Error("Expected the update to fail")
+	}
+	if n, err := updater.LoadCache(); err != nil || n != 2 {
+		t.Fatalf("Expected two cached models, got %d (%v)", n, err)
+	}
+	if cost := pc.CalculateCost("claude-opus-5-20260101", 1_000_000, 0, 0, 0); cost != 10 {
+		t.Errorf("Expected the cached opus price, got %v", cost)
+	}
+}
+
+func TestParseLiteLLMPricesRejectsEmptyRegistries(t *testing.T) {
+	if _, err := ParseLiteLLMPrices([]byte(`{"gpt-9": {"litellm_provider": "openai"}}`)); err == nil {
+		t.Error("Expected a registry without Anthropic models to be rejected")
+	}
+	if _, err := ParseLiteLLMPrices([]byte(`<html>`)); err == nil {
+		t.Error("Expected invalid JSON to be rejected")
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrProjectNotFound is returned for a project without any sessions
+var ErrProjectNotFound = errors.New("project not found")
+
+// ProjectSummary is the token and cost total of one project
+type ProjectSummary struct {
+	ProjectName              string     `json:"project_name"`
+	InputTokens              int64      `json:"input_tokens"`
+	OutputTokens             int64      `json:"output_tokens"`
+	CacheCreationInputTokens int64      `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64      `json:"cache_read_input_tokens"`
+	TotalTokens              int64      `json:"total_tokens"`
+	Cost                     float64    `json:"cost"`
+	MessageCount             int        `json:"message_count"`
+	SessionCount             int        `json:"session_count"`
+	FirstActivity            *time.Time `json:"first_activity"`
+	LastActivity             *time.Time `json:"last_activity"`
+}
+
+// ModelUsage is the part of a project's usage made with one model
+type ModelUsage struct {
+	Model                    string  `json:"model"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	TotalTokens              int64   `json:"total_tokens"`
+	Cost                     float64 `json:"cost"`
+	MessageCount             int     `json:"message_count"`
+	// TokenShare is the model's share of the project's total tokens
+	TokenShare float64 `json:"token_share"`
+}
+
+// ProjectUsageDetail is a project total with its model mix
+type ProjectUsageDetail struct {
+	ProjectSummary
+	Models []ModelUsage `json:"models"`
+}
+
+// ProjectService aggregates usage by project for an optional time range
+type ProjectService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+}
+
+func NewProjectService(db *sql.DB) *ProjectService {
+	return &ProjectService{db: db, pricing: NewPricingCalculator()}
+}
+
+// GetProjects returns every project with activity in [since, until), most
+// expensive first. Zero times leave the range open; an empty user includes
+// every user.
+func (s *ProjectService) GetProjects(user string, since, until time.Time) ([]ProjectSummary, error) {
+	models, err := s.queryModelUsage("", user, since, until)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := s.queryActivity("", user, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	projects := make([]ProjectSummary, 0, len(activity))
+	for name, summary := range activity {
+		for _, model := range models[name] {
+			summary.add(model)
+		}
+		summary.Cost = roundToDecimals(summary.Cost, 6)
+		projects = append(projects, *summary)
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if projects[i].Cost != projects[j].Cost {
+			return projects[i].Cost > projects[j].Cost
+		}
+		return projects[i].ProjectName < projects[j].ProjectName
+	})
+	return projects, nil
+}
+
+// GetProjectUsage returns the usage of one project in [since, until) with its
+// model mix, limited to user's sessions when user is set. A project with sessions but no activity in the range has zero
+// totals.
+func (s *ProjectService) GetProjectUsage(name, user string, since, until time.Time) (*ProjectUsageDetail, error) {
+	var sessions int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE project_name = ? AND (? = '' OR user_id = ?)`, name, user, user).Scan(&sessions); err != nil {
+		return nil, fmt.Errorf("failed to look up project: %w", err)
+	}
+	if sessions == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+	}
+
+	models, err := s.queryModelUsage(name, user, since, until)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := s.queryActivity(name, user, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &ProjectUsageDetail{ProjectSummary: ProjectSummary{ProjectName: name}, Models: []ModelUsage{}}
+	if summary, ok := activity[name]; ok {
+		detail.ProjectSummary = *summary
+	}
+	for _, model := range models[name] {
+		detail.add(model)
+	}
+	for _, model := range models[name] {
+		if detail.TotalTokens > 0 {
+			model.TokenShare = float64(model.TotalTokens) / float64(detail.TotalTokens)
+		}
+		model.Cost = roundToDecimals(model.Cost, 6)
+		detail.Models = append(detail.Models, model)
+	}
+	detail.Cost = roundToDecimals(detail.Cost, 6)
+	sort.Slice(detail.Models, func(i, j int) bool { return detail.Models[i].TotalTokens > detail.Models[j].TotalTokens })
+	return detail, nil
+}
+
+func (p *ProjectSummary) add(model ModelUsage) {
+	p.InputTokens += model.InputTokens
+	p.OutputTokens += model.OutputTokens
+	p.CacheCreationInputTokens += model.CacheCreationInputTokens
+	p.CacheReadInputTokens += model.CacheReadInputTokens
+	p.TotalTokens += model.TotalTokens
+	p.Cost += model.Cost
+}
+
+// rangeFilter builds the conditions shared by the project queries
+func rangeFilter(project, user string, since, until time.Time) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if project != "" {
+		conditions = append(conditions, "s.project_name = ?")
+		args = append(args, project)
+	}
+	if user != "" {
+		conditions = append(conditions, "s.user_id = ?")
+		args = append(args, user)
+	}
+	if !since.IsZero() {
+		conditions = append(conditions, "m.timestamp >= ?")
+		args = append(args, since)
+	}
+	if !until.IsZero() {
+		conditions = append(conditions, "m.timestamp < ?")
+		args = append(args, until)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// queryModelUsage returns token totals per project and model
+func (s *ProjectService) queryModelUsage(project, user string, since, until time.Time) (map[string][]ModelUsage, error) {
+	where, args := rangeFilter(project, user, since, until)
+	rows, err := s.db.Query(`
+		SELECT
+			s.project_name,
+			COALESCE(m.model, 'unknown'),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0),
+			COUNT(*) FILTER (WHERE m.message_role = 'assistant')
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+`
+		GROUP BY s.project_name, COALESCE(m.model, 'unknown')
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string][]ModelUsage)
+	for rows.Next() {
+		var name string
+		var model ModelUsage
+		if err := rows.Scan(&name, &model.Model, &model.InputTokens, &model.OutputTokens,
+			&model.CacheCreationInputTokens, &model.CacheReadInputTokens, &model.MessageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan project usage: %w", err)
+		}
+		if model.InputTokens == 0 && model.OutputTokens == 0 && model.CacheCreationInputTokens == 0 &&
+			model.CacheReadInputTokens == 0 && model.MessageCount == 0 {
+			// User messages carry no model or tokens
+			continue
+		}
+		model.TotalTokens = model.InputTokens + model.OutputTokens
+		if model.Model != "unknown" {
+			model.Cost = s.pricing.CalculateCost(model.Model, int(model.InputTokens), int(model.OutputTokens),
+				int(model.CacheCreationInputTokens), int(model.CacheReadInputTokens))
+		}
+		usage[name] = append(usage[name], model)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read project usage: %w", err)
+	}
+	return usage, nil
+}
+
+// queryActivity returns message and session counts and the activity span per project
+func (s *ProjectService) queryActivity(project, user string, since, until time.Time) (map[string]*ProjectSummary, error) {
+	where, args := rangeFilter(project, user, since, until)
+	rows, err := s.db.Query(`
+		SELECT
+			s.project_name,
+			COUNT(*) FILTER (WHERE m.message_role = 'assistant'),
+			COUNT(DISTINCT m.session_id),
+			MIN(m.timestamp),
+			MAX(m.timestamp)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+`
+		GROUP BY s.project_name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project activity: %w", err)
+	}
+	defer rows.Close()
+
+	activity := make(map[string]*ProjectSummary)
+	for rows.Next() {
+		summary := &ProjectSummary{}
+		var first, last sql.NullTime
+		if err := rows.Scan(&summary.ProjectName, &summary.MessageCount, &summary.SessionCount, &first, &last); err != nil {
+			return nil, fmt.Errorf("failed to scan project activity: %w", err)
+		}
+		if first.Valid {
+			summary.FirstActivity = &first.Time
+		}
+		if last.Valid {
+			summary.LastActivity = &last.Time
+		}
+		activity[summary.ProjectName] = summary
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read project activity: %w", err)
+	}
+	return activity, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestProjectUsageBreakdown(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES
+			('s1', 'api', '/work/api', '2024-03-01 09:00:00'),
+			('s2', 'api', '/work/api', '2024-03-05 09:00:00'),
+			('s3', 'web', '/work/web', '2024-03-02 09:00:00'),
+			('s4', 'idle', '/work/idle', '2024-01-01 09:00:00');
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp) VALUES
+			('u1', 's1', 'user', NULL, 0, 0, '2024-03-01 09:00:00'),
+			('a1', 's1', 'assistant', 'claude-opus-4-20250514', 1000, 500, '2024-03-01 09:00:10'),
+			('a2', 's2', 'assistant', 'claude-sonnet-4-20250514', 3000, 1000, '2024-03-05 09:00:10'),
+			('a3', 's3', 'assistant', 'claude-sonnet-4-20250514', 100, 50, '2024-03-02 09:00:10');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	projects := NewProjectService(db)
+	list, err := projects.GetProjects("", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get projects: %v", err)
+	}
+	if len(list) != 2 || list[0].ProjectName != "api" || list[1].ProjectName != "web" {
+		t.Fatalf("Expected api then web, got %+v", list)
+	}
+	api := list[0]
+	if api.TotalTokens != 5500 || api.SessionCount != 2 || api.MessageCount != 2 {
+		t.Errorf("Unexpected api totals: %+v", api)
+	}
+	// 1000 opus input + 500 opus output + 3000 sonnet input + 1000 sonnet output
+	if expected := 0.015 + 0.0375 + 0.009 + 0.015; api.Cost < expected-1e-6 || api.Cost > expected+1e-6 {
+		t.Errorf("Expected api cost %f, got %f", expected, api.Cost)
+	}
+
+	// Only the first days of March
+	list, err = projects.GetProjects("", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to get projects: %v", err)
+	}
+	if len(list) != 2 || list[0].TotalTokens != 1500 || list[0].SessionCount != 1 {
+		t.Errorf("Expected api limited to s1, got %+v", list)
+	}
+
+	detail, err := projects.GetProjectUsage("api", "", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get project usage: %v", err)
+	}
+	if len(detail.Models) != 2 || detail.Models[0].Model != "claude-sonnet-4-20250514" {
+		t.Fatalf("Expected sonnet first in the model mix, got %+v", detail.Models)
+	}
+	if share := detail.Models[0].TokenShare; share < 0.727 || share > 0.728 {
+		t.Errorf("Expected sonnet share 4000/5500, got %f", share)
+	}
+
+	// Known project without activity in the range
+	detail, err = projects.GetProjectUsage("idle", "", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get idle project: %v", err)
+	}
+	if detail.TotalTokens != 0 || len(detail.Models) != 0 {
+		t.Errorf("Expected empty usage for idle, got %+v", detail)
+	}
+
+	if _, err := projects.GetProjectUsage("missing", "", time.Time{}, time.Time{}); !errors.Is(err, ErrProjectNotFound) {
+		t.Errorf("Expected ErrProjectNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultQueryCacheMaxAge bounds how long a result is served even without new
+// ingests, since some results (like the active window) depend on the clock
+const DefaultQueryCacheMaxAge = 30 * time.Second
+
+// QueryCache memoizes expensive query results between syncs. Entries are tagged
+// with the ingest generation they were computed in, so a completed sync
+// invalidates every cached result at once.
+type QueryCache struct {
+	mu         sync.Mutex
+	maxAge     time.Duration
+	generation uint64
+	lastIngest time.Time
+	entries    map[string]queryCacheEntry
+	now        func() time.Time
+}
+
+type queryCacheEntry struct {
+	value      interface{}
+	generation uint64
+	storedAt   time.Time
+}
+
+// NewQueryCache creates a cache whose entries expire after maxAge
+func NewQueryCache(maxAge time.Duration) *QueryCache {
+	return &QueryCache{
+		maxAge:     maxAge,
+		lastIngest: time.Now(),
+		entries:    make(map[string]queryCacheEntry),
+		now:        time.Now,
+	}
+}
+
+// GetOrLoad returns the cached value for key, calling load when it is missing or stale.
+// Errors are not cached.
+func (c *QueryCache) GetOrLoad(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	now := c.now()
+	c.mu.Unlock()
+
+	if ok && entry.generation == generation && now.Sub(entry.storedAt) < c.maxAge {
+		return entry.value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	// Don't store a result computed before an ingest that finished while loading
+	if c.generation == generation {
+		c.entries[key] = queryCacheEntry{value: value, generation: generation, storedAt: now}
+	}
+	c.mu.Unlock()
+
+	return value, nil
+}
+
+// MarkIngested records that new data was written, invalidating all cached results
+func (c *QueryCache) MarkIngested() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.lastIngest = c.now()
+	c.entries = make(map[string]queryCacheEntry)
+}
+
+// LastIngest returns when data was last written
+func (c *QueryCache) LastIngest() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lastIngest
+}
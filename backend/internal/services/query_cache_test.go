@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryCacheInvalidatedByIngest(t *testing.T) {
+	cache := NewQueryCache(time.Minute)
+
+	calls := 0
+	load := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		value, err := cache.GetOrLoad("usage", load)
+		if err != nil {
+			t.Fatalf("GetOrLoad failed: %v", err)
+		}
+		if value.(int) != 1 {
+			t.Errorf("Expected cached value 1, got %v", value)
+		}
+	}
+
+	cache.MarkIngested()
+
+	value, err := cache.GetOrLoad("usage", load)
+	if err != nil {
+		t.Fatalf("GetOrLoad failed: %v", err)
+	}
+	if value.(int) != 2 {
+		t.Errorf("Expected reloaded value 2 after ingest, got %v", value)
+	}
+}
+
+func TestQueryCacheExpires(t *testing.T) {
+	cache := NewQueryCache(30 * time.Second)
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	calls := 0
+	load := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	cache.GetOrLoad("usage", load)
+	now = now.Add(29 * time.Second)
+	cache.GetOrLoad("usage", load)
+	if calls != 1 {
+		t.Errorf("Expected 1 load before expiry, got %d", calls)
+	}
+
+	now = now.Add(time.Second)
+	cache.GetOrLoad("usage", load)
+	if calls != 2 {
+		t.Errorf("Expected 2 loads after expiry, got %d", calls)
+	}
+}
+
+func TestQueryCacheDoesNotCacheErrors(t *testing.T) {
+	cache := NewQueryCache(time.Minute)
+
+	calls := 0
+	_, err := cache.GetOrLoad("usage", func() (interface{}, error) {
+		calls++
+		return nil, errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+
+	value, err := cache.GetOrLoad("usage", func() (interface{}, error) {
+		calls++
+		return "ok", nil
+	})
+	if err != nil || value != "ok" {
+		t.Errorf("Expected ok after error, got %v (%v)", value, err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 loads, got %d", calls)
+	}
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// redactionRule replaces matches of re, or only its group-th submatch, with a
+// marker naming the kind of secret
+type redactionRule struct {
+	kind  string
+	re    *regexp.Regexp
+	group int
+}
+
+// Rules run in order; specific key formats come before the generic ones so
+// the report names the most precise kind
+var redactionRules = []redactionRule{
+	{kind: "private_key", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{kind: "anthropic_api_key", re: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{kind: "openai_api_key", re: regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{20,}`)},
+	{kind: "aws_access_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "github_token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{kind: "slack_token", re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{kind: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{kind: "bearer_token", re: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]{20,}=*)`), group: 1},
+	{kind: "credential", re: regexp.MustCompile(`(?i)\b(?:password|passwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token)["']?\s*[:=]\s*["']?([^\s"',;\[][^\s"',;]{7,})`), group: 1},
+	{kind: "email", re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+}
+
+// entropyCandidate matches long unbroken tokens that may be random secrets
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`)
+
+// minSecretEntropy is the Shannon entropy (bits per character) above which a
+// token is treated as random. Hex digests top out at 4 and are left alone.
+const minSecretEntropy = 4.3
+
+// highEntropyKind names redactions of random-looking tokens, which no rule
+// matches
+const highEntropyKind = "high_entropy"
+
+// Redactor removes secrets and personal data from message content before it
+// is stored
+type Redactor struct {
+	rules   []redactionRule
+	entropy bool
+}
+
+func NewRedactor() *Redactor {
+	return &Redactor{rules: redactionRules, entropy: true}
+}
+
+// CustomRedactionRule is a configured rule. Matches of Pattern, or of its
+// first group when it has one, are replaced by a marker naming Kind. A rule
+// of a built-in kind replaces that rule, or with no pattern disables it.
+type CustomRedactionRule struct {
+	Kind    string
+	Pattern string
+}
+
+var redactionKindPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// NewCustomRedactor returns a redactor running the custom rules before the
+// built-in ones they leave in place
+func NewCustomRedactor(custom []CustomRedactionRule) (*Redactor, error) {
+	r := &Redactor{entropy: true}
+	overridden := map[string]bool{}
+	for _, c := range custom {
+		if !redactionKindPattern.MatchString(c.Kind) {
+			return nil, fmt.Errorf("invalid redaction rule name %q: use lowercase letters, digits and underscores", c.Kind)
+		}
+		if overridden[c.Kind] {
+			return nil, fmt.Errorf("redaction rule %s is defined twice", c.Kind)
+		}
+		overridden[c.Kind] = true
+		if c.Pattern == "" {
+			if c.Kind == highEntropyKind {
+				r.entropy = false
+			}
+			continue
+		}
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of redaction rule %s: %w", c.Kind, err)
+		}
+		rule := redactionRule{kind: c.Kind, re: re}
+		if re.NumSubexp() > 0 {
+			rule.group = 1
+		}
+		r.rules = append(r.rules, rule)
+	}
+	for _, rule := range redactionRules {
+		if !overridden[rule.kind] {
+			r.rules = append(r.rules, rule)
+		}
+	}
+	return r, nil
+}
+
+func redactionMarker(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// Redact returns content with secrets replaced by [REDACTED:<kind>] markers,
+// and the number of replacements per kind (nil when nothing was redacted)
+func (r *Redactor) Redact(content string) (string, map[string]int) {
+	var counts map[string]int
+	count := func(kind string) {
+		if counts == nil {
+			counts = map[string]int{}
+		}
+		counts[kind]++
+	}
+
+	for _, rule := range r.rules {
+		content = replaceMatches(content, rule, count)
+	}
+
+	if r.entropy {
+		content = entropyCandidate.ReplaceAllStringFunc(content, func(token string) string {
+			if !looksRandom(token) {
+				return token
+			}
+			count(highEntropyKind)
+			return redactionMarker(highEntropyKind)
+		})
+	}
+
+	return content, counts
+}
+
+func replaceMatches(content string, rule redactionRule, count func(string)) string {
+	matches := rule.re.FindAllStringSubmatchIndex(content, -1)
+	if matches == nil {
+		return content
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*rule.group], m[2*rule.group+1]
+		if start < 0 {
+			continue
+		}
+		b.WriteString(content[last:start])
+		b.WriteString(redactionMarker(rule.kind))
+		last = end
+		count(rule.kind)
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
+
+// looksRandom reports whether token mixes character classes and has high entropy
+func looksRandom(token string) bool {
+	var lower, upper, digit bool
+	for _, c := range token {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+	if !lower || !upper || !digit {
+		return false
+	}
+	return shannonEntropy(token) >= minSecretEntropy
+}
+
+func shannonEntropy(s string) float64 {
+	freq := map[rune]int{}
+	for _, c := range s {
+		freq[c]++
+	}
+	n := float64(len(s))
+	entropy := 0.0
+	for _, count := range freq {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// RedactionCount is the number of redactions of one kind since the database was created
+type RedactionCount struct {
+	Kind      string    `json:"kind"`
+	Count     int64     `json:"count"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GetRedactionCounts returns cumulative redaction counts, most frequent first
+func GetRedactionCounts(db *sql.DB) ([]RedactionCount, error) {
+	rows, err := db.Query(`SELECT kind, count, updated_at FROM redaction_stats ORDER BY count DESC, kind`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redaction counts: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []RedactionCount{}
+	for rows.Next() {
+		var c RedactionCount
+		if err := rows.Scan(&c.Kind, &c.Count, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan redaction count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// redact applies the redactor, if any, and tallies the redactions for this pass
+func (d *DiffSyncService) redact(content string) string {
+	if d.redactor == nil {
+		return content
+	}
+	content, counts := d.redactor.Redact(content)
+	for kind, n := range counts {
+		d.redactions[kind] += n
+	}
+	return content
+}
+
+// redactionCounts copies this pass's redactions by kind, or returns nil when
+// there were none
+func (d *DiffSyncService) redactionCounts() map[string]int {
+	if len(d.redactions) == 0 {
+		return nil
+	}
+	counts := make(map[string]int, len(d.redactions))
+	for kind, n := range d.redactions {
+		counts[kind] = n
+	}
+	return counts
+}
+
+// flushRedactionCounts adds this pass's redactions to the cumulative report
+func (d *DiffSyncService) flushRedactionCounts() error {
+	now := time.Now()
+	for kind, n := range d.redactions {
+		_, err := d.db.Exec(`
+			INSERT INTO redaction_stats (kind, count, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT (kind) DO UPDATE SET count = redaction_stats.count + excluded.count, updated_at = excluded.updated_at
+		`, kind, n, now)
+		if err != nil {
+			return fmt.Errorf("kind %s: %w", kind, err)
+		}
+		delete(d.redactions, kind)
+	}
+	return nil
+}
+
+// StoredRedaction reports a pass over content already in the database
+type StoredRedaction struct {
+	Policy ContentPolicy `json:"policy"`
+	// StrippedMessages had content removed or truncated by the content policy
+	StrippedMessages int64 `json:"stripped_messages"`
+	// RedactedMessages, RedactedTitles and RedactedParseErrors had secrets
+	// replaced
+	RedactedMessages    int64          `json:"redacted_messages"`
+	RedactedTitles      int64          `json:"redacted_titles"`
+	RedactedParseErrors int64          `json:"redacted_parse_errors"`
+	Redactions          map[string]int `json:"redactions"`
+}
+
+// RedactStored brings messages stored under a laxer configuration in line
+// with the current one: it applies the content policy, then runs the
+// redactor, if any, over the content, session titles and quarantined log
+// lines left. Encrypted and compressed content is opened and sealed again;
+// without the key it is left alone.
+func (d *DiffSyncService) RedactStored() (*StoredRedaction, error) {
+	result := &StoredRedaction{Policy: d.contentPolicy, Redactions: map[string]int{}}
+	err := d.writes.Do(func() error {
+		var err error
+		result.StrippedMessages, err = StripContent(d.db, d.contentPolicy, d.cipher)
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+	if d.redactor == nil || d.contentPolicy.Mode == ContentPolicyMetadata {
+		return result, nil
+	}
+
+	result.RedactedMessages, err = d.redactColumn(`SELECT id, content FROM messages WHERE content IS NOT NULL AND id > ? ORDER BY id LIMIT 500`,
+		`UPDATE messages SET content = ? WHERE id = ?`)
+	if err != nil {
+		return result, fmt.Errorf("failed to redact messages: %w", err)
+	}
+	result.RedactedTitles, err = d.redactColumn(`SELECT id, title FROM sessions WHERE title IS NOT NULL AND id > ? ORDER BY id LIMIT 500`,
+		`UPDATE sessions SET title = ? WHERE id = ?`)
+	if err != nil {
+		return result, fmt.Errorf("failed to redact session titles: %w", err)
+	}
+	err = d.writes.Do(func() error {
+		var err error
+		result.RedactedParseErrors, err = rewriteParseErrors(d.db, "", nil, func(raw string) (*string, bool) {
+			clean, ok := d.redactStoredText(raw)
+			return &clean, ok
+		})
+		return err
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to redact parse errors: %w", err)
+	}
+
+	if counts := d.redactionCounts(); counts != nil {
+		result.Redactions = counts
+	}
+	if err := d.writes.Do(d.flushRedactionCounts); err != nil {
+		return result, fmt.Errorf("failed to record redaction counts: %w", err)
+	}
+	return result, nil
+}
+
+// redactColumn redacts the text selected in batches by id, and writes back
+// the rows that changed. It returns their number.
+func (d *DiffSyncService) redactColumn(query, update string) (int64, error) {
+	var changed int64
+	after := ""
+	for {
+		rows, err := d.db.Query(query, after)
+		if err != nil {
+			return changed, err
+		}
+		redacted := map[string]string{}
+		found := 0
+		for rows.Next() {
+			var id, text string
+			if err := rows.Scan(&id, &text); err != nil {
+				rows.Close()
+				return changed, err
+			}
+			found++
+			after = id
+
+			if clean, ok := d.redactStoredText(text); ok {
+				redacted[id] = clean
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return changed, err
+		}
+		if found == 0 {
+			return changed, nil
+		}
+
+		err = d.writes.Do(func() error {
+			for id, text := range redacted {
+				if _, err := d.db.Exec(update, text, id); err != nil {
+					return fmt.Errorf("%s: %w", id, err)
+				}
+				changed++
+			}
+			return nil
+		})
+		if err != nil {
+			return changed, err
+		}
+	}
+}
+
+// redactStoredText redacts stored text, compressed and encrypted again as it
+// was. It reports false when nothing changed or the text cannot be opened.
+func (d *DiffSyncService) redactStoredText(text string) (string, bool) {
+	stored, ok := d.cipher.decrypt(text)
+	if !ok {
+		return "", false
+	}
+	plain := decompressContent(stored)
+	clean := d.redact(plain)
+	if clean == plain {
+		return "", false
+	}
+	if IsCompressedContent(stored) {
+		clean = compressContent(clean)
+	}
+	if IsEncryptedContent(text) {
+		clean = *d.cipher.Seal(&clean)
+	}
+	return clean, true
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+)
+
+// MaxRetentionDays bounds content_retention_days
+const MaxRetentionDays = 36500
+
+// ErrNoRetention is returned by Prune when no retention period is set
+var ErrNoRetention = errors.New("no content retention period; set content_retention_days first")
+
+// pruneBatchSize is how many messages one write clears, keeping each write
+// short enough not to hold up a sync
+const pruneBatchSize = 1000
+
+// PruneResult reports a retention run
+type PruneResult struct {
+	RetentionDays int       `json:"retention_days"`
+	Cutoff        time.Time `json:"cutoff"`
+	Messages      int64     `json:"pruned_messages"`
+	ParseErrors   int64     `json:"pruned_parse_errors"`
+}
+
+// RetentionPruner removes the content of messages older than the retention
+// period. Message rows stay, so token counts, costs and rollups are kept
+// forever; only the prompt and response text goes.
+type RetentionPruner struct {
+	db       *sql.DB
+	writes   *WriteQueue
+	interval time.Duration
+
+	mu   sync.Mutex
+	days int
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewRetentionPruner creates a pruner that runs every interval once started;
+// it prunes nothing until SetRetentionDays gives it a positive period
+func NewRetentionPruner(db *sql.DB, writes *WriteQueue, interval time.Duration) *RetentionPruner {
+	return &RetentionPruner{
+		db:       db,
+		writes:   writes,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// SetRetentionDays changes the retention period; 0 keeps content forever
+func (p *RetentionPruner) SetRetentionDays(days int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.days = days
+}
+
+// RetentionDays returns the current retention period
+func (p *RetentionPruner) RetentionDays() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.days
+}
+
+// Prune clears the content of messages and the raw lines of parse errors
+// older than days, or than the configured period when days is 0
+func (p *RetentionPruner) Prune(days int) (*PruneResult, error) {
+	if days == 0 {
+		days = p.RetentionDays()
+	}
+	if days <= 0 {
+		return nil, ErrNoRetention
+	}
+	result := &PruneResult{
+		RetentionDays: days,
+		Cutoff:        time.Now().UTC().AddDate(0, 0, -days),
+	}
+
+	// Quarantined log lines may hold the same text
+	err := p.writes.Do(func() error {
+		res, err := p.db.Exec(`
+			UPDATE parse_errors SET raw_content = NULL
+			WHERE raw_content IS NOT NULL AND detected_at < ?
+		`, result.Cutoff)
+		if err != nil {
+			return fmt.Errorf("failed to prune parse error content: %w", err)
+		}
+		result.ParseErrors, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return result, err
+	}
+
+	for {
+		var pruned int64
+		err := p.writes.Do(func() error {
+			res, err := p.db.Exec(`
+				UPDATE messages SET content = NULL
+				WHERE id IN (
+					SELECT id FROM messages
+					WHERE content IS NOT NULL AND timestamp < ?
+					LIMIT ?
+				)
+			`, result.Cutoff, pruneBatchSize)
+			if err != nil {
+				return fmt.Errorf("failed to prune message content: %w", err)
+			}
+			pruned, err = res.RowsAffected()
+			return err
+		})
+		result.Messages += pruned
+		if err != nil {
+			return result, err
+		}
+		if pruned < pruneBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// Start launches the background pruning goroutine, which first runs
+// immediately
+func (p *RetentionPruner) Start() {
+	go p.run()
+}
+
+// Stop ends the background goroutine after its current run
+func (p *RetentionPruner) Stop() {
+	p.once.Do(func() { close(p.stop) })
+	<-p.done
+}
+
+func (p *RetentionPruner) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if p.RetentionDays() > 0 {
+			result, err := p.Prune(0)
+			if err != nil {
+				logging.Component("retention").Warn("Failed to prune message content", "err", err)
+			} else if result.Messages > 0 {
+				logging.Component("retention").Info("Pruned message content", "messages", result.Messages, "cutoff", result.Cutoff)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestRetentionPrunerKeepsTokens(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('s1', 'app', '/work/app', CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	// More old messages than one batch, and one recent message
+	now := time.Now().UTC()
+	if _, err := db.Exec(fmt.Sprintf(`
+		INSERT INTO messages (id, session_id, message_role, content, output_tokens, timestamp)
+		SELECT 'old-' || i, 's1', 'assistant', 'old reply', 10, CAST(? AS TIMESTAMP)
+		FROM range(%d) t(i)
+	`, pruneBatchSize+5), now.AddDate(0, 0, -100)); err != nil {
+		t.Fatalf("Failed to insert messages: %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, content, output_tokens, timestamp)
+		VALUES ('new', 's1', 'assistant', 'new reply', 10, ?)
+	`, now.AddDate(0, 0, -1)); err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+
+	insertParseError(t, db, 1, "old line", now.AddDate(0, 0, -100))
+	insertParseError(t, db, 2, "new line", now.AddDate(0, 0, -1))
+
+	pruner := NewRetentionPruner(db, nil, time.Hour)
+	if _, err := pruner.Prune(0); !errors.Is(err, ErrNoRetention) {
+		t.Errorf("Expected ErrNoRetention without a period, got %v", err)
+	}
+
+	pruner.SetRetentionDays(90)
+	result, err := pruner.Prune(0)
+	if err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+	if result.Messages != pruneBatchSize+5 || result.ParseErrors != 1 || result.RetentionDays != 90 {
+		t.Errorf("Expected %d messages and 1 parse error pruned at 90 days, got %+v", pruneBatchSize+5, result)
+	}
+	if raw := storedParseError(t, db, 1); raw != nil {
+		t.Errorf("Expected the old quarantined line cleared, got %q", *raw)
+	}
+	if raw := storedParseError(t, db, 2); raw == nil {
+		t.Error("Expected the recent quarantined line to be kept")
+	}
+
+	var withContent, tokens int
+	db.QueryRow(`SELECT COUNT(content), SUM(output_tokens) FROM messages`).Scan(&withContent, &tokens)
+	if withContent != 1 {
+		t.Errorf("Expected only the recent message to keep content, got %d", withContent)
+	}
+	if tokens != (pruneBatchSize+6)*10 {
+		t.Errorf("Expected token counts to be kept, got %d", tokens)
+	}
+
+	result, err = pruner.Prune(0)
+	if err != nil || result.Messages != 0 {
+		t.Errorf("Expected nothing left to prune, got %+v (%v)", result, err)
+	}
+	// A shorter period given to Prune overrides the configured one
+	result, err = pruner.Prune(1)
+	if err != nil || result.Messages != 1 {
+		t.Errorf("Expected the recent message pruned at 1 day, got %+v (%v)", result, err)
+	}
+}
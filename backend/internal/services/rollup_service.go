@@ -0,0 +1,505 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/logging"
+)
+
+// Rollup granularities
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// ErrInvalidGranularity is returned for a granularity other than day, week or month
+var ErrInvalidGranularity = errors.New("granularity must be day, week or month")
+
+// DailyUsage is the usage of one day in the reporting time zone
+type DailyUsage struct {
+	Day                      time.Time `json:"day"`
+	InputTokens              int64     `json:"input_tokens"`
+	OutputTokens             int64     `json:"output_tokens"`
+	CacheCreationInputTokens int64     `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64     `json:"cache_read_input_tokens"`
+	TotalTokens              int64     `json:"total_tokens"`
+	MessageCount             int       `json:"message_count"`
+	SessionCount             int       `json:"session_count"`
+}
+
+// ProjectUsage is a precomputed per-project usage total
+type ProjectUsage struct {
+	ProjectName              string     `json:"project_name"`
+	InputTokens              int64      `json:"input_tokens"`
+	OutputTokens             int64      `json:"output_tokens"`
+	CacheCreationInputTokens int64      `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64      `json:"cache_read_input_tokens"`
+	TotalTokens              int64      `json:"total_tokens"`
+	MessageCount             int        `json:"message_count"`
+	SessionCount             int        `json:"session_count"`
+	LastActivity             *time.Time `json:"last_activity"`
+}
+
+// ModelPeriodUsage is the usage of one model within a rollup period
+type ModelPeriodUsage struct {
+	Model                    string  `json:"model"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	TotalTokens              int64   `json:"total_tokens"`
+	Cost                     float64 `json:"cost"`
+	MessageCount             int     `json:"message_count"`
+	SessionCount             int     `json:"session_count"`
+}
+
+// UsagePeriod is the usage of one day, ISO week (starting Monday) or calendar
+// month in the reporting time zone, with its per-model breakdown. End is
+// exclusive.
+type UsagePeriod struct {
+	Start                    time.Time          `json:"start"`
+	End                      time.Time          `json:"end"`
+	InputTokens              int64              `json:"input_tokens"`
+	OutputTokens             int64              `json:"output_tokens"`
+	CacheCreationInputTokens int64              `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64              `json:"cache_read_input_tokens"`
+	TotalTokens              int64              `json:"total_tokens"`
+	Cost                     float64            `json:"cost"`
+	MessageCount             int                `json:"message_count"`
+	SessionCount             int                `json:"session_count"`
+	Models                   []ModelPeriodUsage `json:"models"`
+}
+
+// rollupBucket returns the usage_buckets bucket of a timestamp column. Quarter
+// hours fall on a single local day in every time zone, so days, weeks and
+// months of any zone are sums of whole buckets.
+func rollupBucket(column string) string {
+	return database.QuarterHour(column)
+}
+
+// RollupService maintains quarter-hour and per-project usage tables so
+// dashboard queries read precomputed rows instead of scanning messages.
+// Refreshes are incremental: only buckets and projects with messages
+// ingested since the last refresh are recomputed.
+type RollupService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+
+	mu       sync.RWMutex
+	location *time.Location
+}
+
+func NewRollupService(db *sql.DB) *RollupService {
+	return &RollupService{db: db, pricing: NewPricingCalculator(), location: time.UTC}
+}
+
+// SetLocation sets the time zone whose days, weeks and months usage is
+// reported in when the caller does not name one
+func (r *RollupService) SetLocation(loc *time.Location) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.location = loc
+}
+
+// Location returns the reporting time zone
+func (r *RollupService) Location() *time.Location {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.location
+}
+
+// Refresh recomputes rollups touched by messages ingested since the last refresh
+func (r *RollupService) Refresh() error {
+	watermark := time.Time{}
+	err := r.db.QueryRow(`SELECT watermark FROM rollup_state WHERE name = 'usage'`).Scan(&watermark)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read rollup watermark: %w", err)
+	}
+
+	var latest sql.NullTime
+	if err := r.db.QueryRow(`SELECT MAX(created_at) FROM messages`).Scan(&latest); err != nil {
+		return fmt.Errorf("failed to read latest ingest: %w", err)
+	}
+	if !latest.Valid || !latest.Time.After(watermark) {
+		return nil
+	}
+
+	_, err = r.db.Exec(`
+		INSERT OR REPLACE INTO usage_buckets (
+			bucket, model, input_tokens, output_tokens, cache_creation_input_tokens,
+			cache_read_input_tokens, total_tokens, message_count, updated_at
+		)
+		SELECT
+			`+rollupBucket("timestamp")+` AS bucket,
+			COALESCE(model, 'unknown'),
+			COALESCE(SUM(input_tokens), 0),
+			COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_creation_input_tokens), 0),
+			COALESCE(SUM(cache_read_input_tokens), 0),
+			COALESCE(SUM(input_tokens + output_tokens), 0),
+			COUNT(*),
+			CURRENT_TIMESTAMP
+		FROM messages
+		WHERE message_role = 'assistant' AND `+rollupBucket("timestamp")+` IN (
+			SELECT DISTINCT `+rollupBucket("timestamp")+` FROM messages WHERE created_at > ?
+		)
+		GROUP BY 1, 2
+	`, watermark)
+	if err != nil {
+		return fmt.Errorf("failed to refresh usage buckets: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+		INSERT OR REPLACE INTO project_usage_rollups (
+			project_name, input_tokens, output_tokens, cache_creation_input_tokens,
+			cache_read_input_tokens, total_tokens, message_count, session_count,
+			last_activity, updated_at
+		)
+		SELECT
+			s.project_name,
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0),
+			COALESCE(SUM(m.input_tokens + m.output_tokens), 0),
+			COUNT(*) FILTER (WHERE m.message_role = 'assistant'),
+			COUNT(DISTINCT m.session_id),
+			MAX(m.timestamp),
+			CURRENT_TIMESTAMP
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		WHERE s.project_name IN (
+			SELECT DISTINCT s2.project_name
+			FROM messages m2
+			JOIN sessions s2 ON m2.session_id = s2.id
+			WHERE m2.created_at > ?
+		)
+		GROUP BY s.project_name
+	`, watermark)
+	if err != nil {
+		return fmt.Errorf("failed to refresh project rollups: %w", err)
+	}
+
+	_, err = r.db.Exec(`INSERT OR REPLACE INTO rollup_state (name, watermark) VALUES ('usage', ?)`, latest.Time)
+	if err != nil {
+		return fmt.Errorf("failed to save rollup watermark: %w", err)
+	}
+	return nil
+}
+
+// Rebuild discards the watermark so the next Refresh recomputes everything
+func (r *RollupService) Rebuild() error {
+	for _, query := range []string{
+		`DELETE FROM rollup_state WHERE name = 'usage'`,
+		`DELETE FROM usage_buckets`,
+		`DELETE FROM project_usage_rollups`,
+	} {
+		if _, err := r.db.Exec(query); err != nil {
+			return fmt.Errorf("failed to reset rollups: %w", err)
+		}
+	}
+	return r.Refresh()
+}
+
+// GetDailyUsage returns the most recent days with usage in the reporting
+// time zone, newest first
+func (r *RollupService) GetDailyUsage(days int) ([]DailyUsage, error) {
+	loc := r.Location()
+	today := startOfDay(time.Now().In(loc))
+	return r.GetDailyUsageBetween(today.AddDate(0, 0, -days), today.AddDate(0, 0, 1), loc)
+}
+
+// GetDailyUsageBetween returns the days of loc with usage overlapping
+// [from, to), newest first
+func (r *RollupService) GetDailyUsageBetween(from, to time.Time, loc *time.Location) ([]DailyUsage, error) {
+	periods, err := r.GetUsageRollups(GranularityDay, from, to, loc)
+	if err != nil {
+		return nil, err
+	}
+	usage := make([]DailyUsage, 0, len(periods))
+	for _, p := range periods {
+		usage = append(usage, DailyUsage{
+			Day:                      p.Start,
+			InputTokens:              p.InputTokens,
+			OutputTokens:             p.OutputTokens,
+			CacheCreationInputTokens: p.CacheCreationInputTokens,
+			CacheReadInputTokens:     p.CacheReadInputTokens,
+			TotalTokens:              p.TotalTokens,
+			MessageCount:             p.MessageCount,
+			SessionCount:             p.SessionCount,
+		})
+	}
+	return usage, nil
+}
+
+// GetUsageRollups returns the usage of every day, week or month of loc
+// overlapping [from, to), newest first. The range is widened to whole days of
+// loc. Tokens and messages come from usage_buckets; sessions are counted from
+// messages so a session spanning several days is counted once per period.
+func (r *RollupService) GetUsageRollups(granularity string, from, to time.Time, loc *time.Location) ([]UsagePeriod, error) {
+	switch granularity {
+	case GranularityDay, GranularityWeek, GranularityMonth:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidGranularity, granularity)
+	}
+	from = startOfDay(from.In(loc))
+	if end := startOfDay(to.In(loc)); end.Before(to) {
+		to = end.AddDate(0, 0, 1)
+	} else {
+		to = end
+	}
+
+	rows, err := r.db.Query(`
+		SELECT bucket, model, input_tokens, output_tokens, cache_creation_input_tokens,
+			cache_read_input_tokens, total_tokens, message_count
+		FROM usage_buckets
+		WHERE bucket >= ? AND bucket < ?
+	`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage rollups: %w", err)
+	}
+	defer rows.Close()
+
+	periods := make(map[time.Time]*UsagePeriod)
+	models := make(map[time.Time]map[string]*ModelPeriodUsage)
+	for rows.Next() {
+		var bucket time.Time
+		var u ModelPeriodUsage
+		if err := rows.Scan(&bucket, &u.Model, &u.InputTokens, &u.OutputTokens, &u.CacheCreationInputTokens,
+			&u.CacheReadInputTokens, &u.TotalTokens, &u.MessageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan usage rollup: %w", err)
+		}
+
+		start := periodStart(granularity, bucket.In(loc))
+		p, ok := periods[start]
+		if !ok {
+			p = &UsagePeriod{Start: start, End: periodEnd(granularity, start), Models: []ModelPeriodUsage{}}
+			periods[start] = p
+			models[start] = make(map[string]*ModelPeriodUsage)
+		}
+		p.InputTokens += u.InputTokens
+		p.OutputTokens += u.OutputTokens
+		p.CacheCreationInputTokens += u.CacheCreationInputTokens
+		p.CacheReadInputTokens += u.CacheReadInputTokens
+		p.TotalTokens += u.TotalTokens
+		p.MessageCount += u.MessageCount
+
+		m, ok := models[start][u.Model]
+		if !ok {
+			m = &ModelPeriodUsage{Model: u.Model}
+			models[start][u.Model] = m
+		}
+		m.InputTokens += u.InputTokens
+		m.OutputTokens += u.OutputTokens
+		m.CacheCreationInputTokens += u.CacheCreationInputTokens
+		m.CacheReadInputTokens += u.CacheReadInputTokens
+		m.TotalTokens += u.TotalTokens
+		m.MessageCount += u.MessageCount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage rollups: %w", err)
+	}
+	rows.Close()
+
+	if err := r.countPeriodSessions(granularity, from, to, loc, periods, models); err != nil {
+		return nil, err
+	}
+
+	usage := make([]UsagePeriod, 0, len(periods))
+	for start, p := range periods {
+		for _, m := range models[start] {
+			// Unknown models have no price
+			if m.Model != "unknown" {
+				m.Cost = r.pricing.CalculateCost(m.Model, int(m.InputTokens), int(m.OutputTokens),
+					int(m.CacheCreationInputTokens), int(m.CacheReadInputTokens))
+			}
+			p.Cost += m.Cost
+			m.Cost = roundToDecimals(m.Cost, 6)
+			p.Models = append(p.Models, *m)
+		}
+		p.Cost = roundToDecimals(p.Cost, 6)
+		sort.Slice(p.Models, func(i, j int) bool { return p.Models[i].TotalTokens > p.Models[j].TotalTokens })
+		usage = append(usage, *p)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Start.After(usage[j].Start) })
+	return usage, nil
+}
+
+// countPeriodSessions fills in the distinct sessions per period and model
+func (r *RollupService) countPeriodSessions(granularity string, from, to time.Time, loc *time.Location,
+	periods map[time.Time]*UsagePeriod, models map[time.Time]map[string]*ModelPeriodUsage) error {
+	rows, err := r.db.Query(`
+		SELECT DISTINCT `+rollupBucket("timestamp")+`, COALESCE(model, 'unknown'), session_id
+		FROM messages
+		WHERE message_role = 'assistant' AND timestamp >= ? AND timestamp < ?
+	`, from.UTC(), to.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to count period sessions: %w", err)
+	}
+	defer rows.Close()
+
+	type periodModel struct {
+		start time.Time
+		model string
+	}
+	periodSessions := make(map[time.Time]map[string]bool)
+	modelSessions := make(map[periodModel]map[string]bool)
+	for rows.Next() {
+		var bucket time.Time
+		var model, session string
+		if err := rows.Scan(&bucket, &model, &session); err != nil {
+			return fmt.Errorf("failed to scan period sessions: %w", err)
+		}
+		start := periodStart(granularity, bucket.In(loc))
+		if periodSessions[start] == nil {
+			periodSessions[start] = make(map[string]bool)
+		}
+		periodSessions[start][session] = true
+		key := periodModel{start, model}
+		if modelSessions[key] == nil {
+			modelSessions[key] = make(map[string]bool)
+		}
+		modelSessions[key][session] = true
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read period sessions: %w", err)
+	}
+
+	// Periods of messages not yet rolled up are left out
+	for start, p := range periods {
+		p.SessionCount = len(periodSessions[start])
+		for model, m := range models[start] {
+			m.SessionCount = len(modelSessions[periodModel{start, model}])
+		}
+	}
+	return nil
+}
+
+// startOfDay returns midnight of t's day in t's location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// periodStart returns the start of the day, ISO week or month containing t,
+// in t's location
+func periodStart(granularity string, t time.Time) time.Time {
+	day := startOfDay(t)
+	switch granularity {
+	case GranularityWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case GranularityMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// periodEnd returns the exclusive end of the period starting at start
+func periodEnd(granularity string, start time.Time) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// GetProjectUsage returns usage per project, largest first
+func (r *RollupService) GetProjectUsage() ([]ProjectUsage, error) {
+	rows, err := r.db.Query(`
+		SELECT project_name, input_tokens, output_tokens, cache_creation_input_tokens,
+			cache_read_input_tokens, total_tokens, message_count, session_count, last_activity
+		FROM project_usage_rollups
+		ORDER BY total_tokens DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []ProjectUsage{}
+	for rows.Next() {
+		var project ProjectUsage
+		if err := rows.Scan(&project.ProjectName, &project.InputTokens, &project.OutputTokens, &project.CacheCreationInputTokens,
+			&project.CacheReadInputTokens, &project.TotalTokens, &project.MessageCount, &project.SessionCount, &project.LastActivity); err != nil {
+			return nil, fmt.Errorf("failed to scan project usage: %w", err)
+		}
+		usage = append(usage, project)
+	}
+	return usage, rows.Err()
+}
+
+// RollupRefresher refreshes rollups in the background shortly after each
+// sync. Notifications arriving during the delay collapse into one refresh.
+type RollupRefresher struct {
+	rollups *RollupService
+	writes  *WriteQueue
+	delay   time.Duration
+
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewRollupRefresher creates a refresher that waits delay after a notification
+func NewRollupRefresher(rollups *RollupService, writes *WriteQueue, delay time.Duration) *RollupRefresher {
+	return &RollupRefresher{
+		rollups: rollups,
+		writes:  writes,
+		delay:   delay,
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start launches the refresher goroutine and schedules an initial refresh
+func (r *RollupRefresher) Start() {
+	go r.run()
+	r.Notify()
+}
+
+// Notify schedules a refresh without blocking
+func (r *RollupRefresher) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Stop ends the refresher goroutine
+func (r *RollupRefresher) Stop() {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+}
+
+func (r *RollupRefresher) run() {
+	defer close(r.done)
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-r.notify:
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(r.delay):
+		}
+
+		if err := r.writes.Do(r.rollups.Refresh); err != nil {
+			logging.Component("rollups").Warn("Failed to refresh rollups", "err", err)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func setupRollupTest(t *testing.T) (*sql.DB, *RollupService) {
+	db, _ := setupTestDBForDiffSync(t)
+	return db, NewRollupService(db)
+}
+
+func insertRollupMessage(t *testing.T, db *sql.DB, id, sessionID string, timestamp, ingested time.Time, input, output int) {
+	_, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, input_tokens, output_tokens, timestamp, created_at)
+		VALUES (?, ?, 'assistant', ?, ?, ?, ?)
+	`, id, sessionID, input, output, timestamp, ingested)
+	if err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+}
+
+func TestRollupRefreshIsIncremental(t *testing.T) {
+	db, rollups := setupRollupTest(t)
+	defer db.Close()
+
+	for _, s := range [][2]string{{"s1", "alpha"}, {"s2", "beta"}} {
+		if _, err := db.Exec(`INSERT INTO sessions (id, project_name) VALUES (?, ?)`, s[0], s[1]); err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+	}
+
+	day1 := time.Now().UTC().Add(-48 * time.Hour)
+	day2 := time.Now().UTC().Add(-24 * time.Hour)
+	ingested := time.Now().UTC().Add(-time.Hour)
+	insertRollupMessage(t, db, "m1", "s1", day1, ingested, 100, 10)
+	insertRollupMessage(t, db, "m2", "s2", day1, ingested, 200, 20)
+
+	if err := rollups.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	daily, err := rollups.GetDailyUsage(30)
+	if err != nil {
+		t.Fatalf("GetDailyUsage failed: %v", err)
+	}
+	if len(daily) != 1 || daily[0].TotalTokens != 330 || daily[0].SessionCount != 2 {
+		t.Fatalf("Expected one day with 330 tokens over 2 sessions, got %+v", daily)
+	}
+
+	// Tamper with alpha's rollup; an incremental refresh touching only beta must leave it alone
+	if _, err := db.Exec(`UPDATE project_usage_rollups SET total_tokens = 1 WHERE project_name = 'alpha'`); err != nil {
+		t.Fatalf("Failed to update rollup: %v", err)
+	}
+	insertRollupMessage(t, db, "m3", "s2", day2, time.Now().UTC(), 50, 5)
+
+	if err := rollups.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	projects, err := rollups.GetProjectUsage()
+	if err != nil {
+		t.Fatalf("GetProjectUsage failed: %v", err)
+	}
+	totals := map[string]int64{}
+	for _, p := range projects {
+		totals[p.ProjectName] = p.TotalTokens
+	}
+	if totals["beta"] != 275 {
+		t.Errorf("Expected beta total 275, got %d", totals["beta"])
+	}
+	if totals["alpha"] != 1 {
+		t.Errorf("Expected alpha rollup untouched, got %d", totals["alpha"])
+	}
+
+	daily, err = rollups.GetDailyUsage(30)
+	if err != nil {
+		t.Fatalf("GetDailyUsage failed: %v", err)
+	}
+	if len(daily) != 2 || daily[0].TotalTokens != 55 {
+		t.Errorf("Expected newest day with 55 tokens, got %+v", daily)
+	}
+
+	if err := rollups.Rebuild(); err != nil {
+		t.Fatalf("Rebuild failed: %v", err)
+	}
+	projects, _ = rollups.GetProjectUsage()
+	for _, p := range projects {
+		if p.ProjectName == "alpha" && p.TotalTokens != 110 {
+			t.Errorf("Expected rebuilt alpha total 110, got %d", p.TotalTokens)
+		}
+	}
+}
+
+func TestRollupRefresherRunsAfterNotify(t *testing.T) {
+	db, rollups := setupRollupTest(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name) VALUES ('s1', 'alpha')`); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	insertRollupMessage(t, db, "m1", "s1", time.Now().UTC(), time.Now().UTC(), 10, 1)
+
+	refresher := NewRollupRefresher(rollups, nil, 10*time.Millisecond)
+	refresher.Start()
+	defer refresher.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		projects, err := rollups.GetProjectUsage()
+		if err != nil {
+			t.Fatalf("GetProjectUsage failed: %v", err)
+		}
+		if len(projects) == 1 && projects[0].TotalTokens == 11 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Expected refresher to build rollups after start")
+}
+
+func TestUsageRollupsByPeriod(t *testing.T) {
+	db, rollups := setupRollupTest(t)
+	defer db.Close()
+
+	// 2024-01-01 is a Monday; s1 spans two days of the same week
+	messages := []struct {
+		id, session, model, timestamp string
+		input, output                 int
+	}{
+		{"m1", "s1", "claude-sonnet-4-20250514", "2024-01-01 10:00:00", 1000, 100},
+		{"m2", "s1", "claude-sonnet-4-20250514", "2024-01-02 10:00:00", 2000, 200},
+		{"m3", "s2", "claude-opus-4-20250514", "2024-01-02 11:00:00", 500, 50},
+		{"m4", "s3", "claude-sonnet-4-20250514", "2024-02-05 09:00:00", 300, 30},
+	}
+	for _, m := range messages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp, created_at)
+			VALUES (?, ?, 'assistant', ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		`, m.id, m.session, m.model, m.input, m.output, m.timestamp)
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+	if err := rollups.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	days, err := rollups.GetUsageRollups(GranularityDay, from, to, time.UTC)
+	if err != nil {
+		t.Fatalf("GetUsageRollups failed: %v", err)
+	}
+	if len(days) != 3 || !days[0].Start.Equal(time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected 3 days, newest first, got %+v", days)
+	}
+	if jan2 := days[1]; len(jan2.Models) != 2 || jan2.SessionCount != 2 || jan2.TotalTokens != 2750 {
+		t.Errorf("Expected two models over two sessions on Jan 2, got %+v", jan2)
+	}
+
+	weeks, err := rollups.GetUsageRollups(GranularityWeek, from, to, time.UTC)
+	if err != nil {
+		t.Fatalf("GetUsageRollups failed: %v", err)
+	}
+	if len(weeks) != 2 {
+		t.Fatalf("Expected 2 weeks, got %+v", weeks)
+	}
+	week := weeks[1]
+	if !week.End.Equal(from.AddDate(0, 0, 7)) || week.MessageCount != 3 || week.SessionCount != 2 {
+		t.Errorf("Expected s1 counted once in the first week, got %+v", week)
+	}
+	if sonnet := week.Models[0]; sonnet.Model != "claude-sonnet-4-20250514" || sonnet.SessionCount != 1 || sonnet.Cost <= 0 {
+		t.Errorf("Unexpected sonnet usage: %+v", sonnet)
+	}
+
+	months, err := rollups.GetUsageRollups(GranularityMonth, from, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.UTC)
+	if err != nil {
+		t.Fatalf("GetUsageRollups failed: %v", err)
+	}
+	if len(months) != 1 || months[0].TotalTokens != 3850 || !months[0].End.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected January only, got %+v", months)
+	}
+
+	if _, err := rollups.GetUsageRollups("year", from, to, time.UTC); !errors.Is(err, ErrInvalidGranularity) {
+		t.Errorf("Expected ErrInvalidGranularity, got %v", err)
+	}
+}
+
+func TestUsageRollupsFollowTimeZone(t *testing.T) {
+	db, rollups := setupRollupTest(t)
+	defer db.Close()
+
+	// 18:20 and 18:40 UTC fall on either side of midnight in India (UTC+5:30)
+	for i, timestamp := range []string{"2024-01-01 18:20:00", "2024-01-01 18:40:00", "2024-01-02 02:00:00"} {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp, created_at)
+			VALUES (?, 's1', 'assistant', 'claude-sonnet-4-20250514', 100, 10, ?, CURRENT_TIMESTAMP)
+		`, fmt.Sprintf("m%d", i), timestamp)
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+	if err := rollups.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	tests := []struct {
+		zone string
+		want []int // messages per day, newest first
+	}{
+		{"UTC", []int{1, 2}},
+		{"Asia/Kolkata", []int{2, 1}},
+		{"America/Los_Angeles", []int{3}},
+	}
+	for _, tt := range tests {
+		loc, err := time.LoadLocation(tt.zone)
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", tt.zone, err)
+		}
+		from := time.Date(2023, 12, 31, 0, 0, 0, 0, loc)
+		days, err := rollups.GetDailyUsageBetween(from, from.AddDate(0, 0, 4), loc)
+		if err != nil {
+			t.Fatalf("GetDailyUsageBetween failed: %v", err)
+		}
+		var got []int
+		for _, day := range days {
+			got = append(got, day.MessageCount)
+			if day.Day.Location() != loc || day.Day.Hour() != 0 || day.SessionCount != 1 {
+				t.Errorf("Expected a day starting at midnight in %s with one session, got %+v", tt.zone, day)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Expected messages per day %v in %s, got %v", tt.want, tt.zone, got)
+		}
+	}
+}
+
+func TestUsageHeatmap(t *testing.T) {
+	db, rollups := setupRollupTest(t)
+	defer db.Close()
+
+	// Monday 2024-01-01 18:20 and 18:40 UTC are Monday 23:50 and Tuesday
+	// 00:10 in India (UTC+5:30); the third message is Tuesday 07:30 there
+	for i, timestamp := range []string{"2024-01-01 18:20:00", "2024-01-01 18:40:00", "2024-01-02 02:00:00"} {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp, created_at)
+			VALUES (?, 's1', 'assistant', 'claude-sonnet-4-20250514', 100, 10, ?, CURRENT_TIMESTAMP)
+		`, fmt.Sprintf("m%d", i), timestamp)
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+	if err := rollups.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, loc)
+	heatmap, err := rollups.GetUsageHeatmap(from, from.AddDate(0, 0, 7), loc)
+	if err != nil {
+		t.Fatalf("GetUsageHeatmap failed: %v", err)
+	}
+	for _, cell := range []struct{ day, hour int }{{1, 23}, {2, 0}, {2, 7}} {
+		if heatmap.Messages[cell.day][cell.hour] != 1 || heatmap.Tokens[cell.day][cell.hour] != 110 || heatmap.Cost[cell.day][cell.hour] <= 0 {
+			t.Errorf("Expected one message on weekday %d at %d:00, got %d messages, %d tokens, cost %v", cell.day, cell.hour,
+				heatmap.Messages[cell.day][cell.hour], heatmap.Tokens[cell.day][cell.hour], heatmap.Cost[cell.day][cell.hour])
+		}
+	}
+	if heatmap.Peak == nil || heatmap.Peak.TotalTokens != 110 || heatmap.Timezone != "Asia/Kolkata" {
+		t.Errorf("Expected a peak of 110 tokens, got %+v", heatmap.Peak)
+	}
+
+	empty, err := rollups.GetUsageHeatmap(from.AddDate(0, 1, 0), from.AddDate(0, 2, 0), loc)
+	if err != nil {
+		t.Fatalf("GetUsageHeatmap failed: %v", err)
+	}
+	if empty.Peak != nil {
+		t.Errorf("Expected no peak without usage, got %+v", empty.Peak)
+	}
+}
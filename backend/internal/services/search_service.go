@@ -0,0 +1,341 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Search limits
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+	maxSearchTerms     = 10
+	// snippetRunes is the length of the content excerpt around the first match
+	snippetRunes = 240
+)
+
+// ErrEmptySearch is returned for a query without any terms
+var ErrEmptySearch = errors.New("search query is empty")
+
+// SearchHighlight marks a match in a snippet, in code points from its start
+type SearchHighlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// MessageSearchHit is a message whose content contains every search term
+type MessageSearchHit struct {
+	MessageID   string            `json:"message_id"`
+	SessionID   string            `json:"session_id"`
+	ProjectName string            `json:"project_name"`
+	ProjectPath string            `json:"project_path"`
+	Role        *string           `json:"role"`
+	Model       *string           `json:"model"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Snippet     string            `json:"snippet"`
+	Highlights  []SearchHighlight `json:"highlights"`
+}
+
+// SessionSearchHit is a session whose id, project name or path contains every search term
+type SessionSearchHit struct {
+	SessionID   string    `json:"session_id"`
+	ProjectName string    `json:"project_name"`
+	ProjectPath string    `json:"project_path"`
+	StartTime   time.Time `json:"start_time"`
+}
+
+// SearchQuery is a search over message content and session names. Terms are
+// the whitespace-separated words of Text, matched case-insensitively.
+type SearchQuery struct {
+	Text    string
+	Project string
+	// User keeps the sessions and messages synced from this user's logs
+	User  string
+	Limit int
+	// Content includes message content; without it only sessions are searched
+	Content bool
+}
+
+// SearchResults are the newest matches of a search
+type SearchResults struct {
+	Query    string             `json:"query"`
+	Sessions []SessionSearchHit `json:"sessions"`
+	Messages []MessageSearchHit `json:"messages"`
+	// ContentSearched is false when message content was not searched
+	ContentSearched bool `json:"content_searched"`
+}
+
+// SearchService finds messages and sessions by text
+type SearchService struct {
+	db     *sql.DB
+	cipher *ContentCipher
+}
+
+func NewSearchService(db *sql.DB) *SearchService {
+	return &SearchService{db: db}
+}
+
+// SetContentCipher decrypts stored content so encrypted messages can be searched
+func (s *SearchService) SetContentCipher(c *ContentCipher) {
+	s.cipher = c
+}
+
+// Search returns up to q.Limit sessions and messages matching every term,
+// newest first
+func (s *SearchService) Search(q SearchQuery) (*SearchResults, error) {
+	terms := searchTerms(q.Text)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if q.Limit < 1 || q.Limit > MaxSearchLimit {
+		q.Limit = DefaultSearchLimit
+	}
+
+	results := &SearchResults{
+		Query:           strings.Join(terms, " "),
+		Sessions:        []SessionSearchHit{},
+		Messages:        []MessageSearchHit{},
+		ContentSearched: q.Content,
+	}
+	sessions, err := s.searchSessions(terms, q)
+	if err != nil {
+		return nil, err
+	}
+	results.Sessions = append(results.Sessions, sessions...)
+
+	if q.Content {
+		messages, err := s.searchMessages(terms, q)
+		if err != nil {
+			return nil, err
+		}
+		results.Messages = append(results.Messages, messages...)
+	}
+	return results, nil
+}
+
+// searchTerms splits a query into distinct lower-case terms
+func searchTerms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(strings.ToLower(text)) {
+		if !seen[term] && len(terms) < maxSearchTerms {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// likePattern matches a term anywhere, escaping LIKE wildcards
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+	return "%" + escaped + "%"
+}
+
+func (s *SearchService) searchSessions(terms []string, q SearchQuery) ([]SessionSearchHit, error) {
+	var conditions []string
+	var args []interface{}
+	for _, term := range terms {
+		conditions = append(conditions, `LOWER(s.id || ' ' || COALESCE(s.project_name, '') || ' ' || COALESCE(s.project_path, '')) LIKE ? ESCAPE '\'`)
+		args = append(args, likePattern(term))
+	}
+	if q.Project != "" {
+		conditions = append(conditions, "s.project_name = ?")
+		args = append(args, q.Project)
+	}
+	if q.User != "" {
+		conditions = append(conditions, "s.user_id = ?")
+		args = append(args, q.User)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT s.id, COALESCE(s.project_name, ''), COALESCE(s.project_path, ''), COALESCE(s.start_time, s.created_at)
+		FROM sessions s
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY COALESCE(s.start_time, s.created_at) DESC, s.id
+		LIMIT ?
+	`, append(args, q.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []SessionSearchHit
+	for rows.Next() {
+		var hit SessionSearchHit
+		if err := rows.Scan(&hit.SessionID, &hit.ProjectName, &hit.ProjectPath, &hit.StartTime); err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// searchMessages filters content in SQL. Encrypted and compressed content
+// can only be matched once opened, so with a cipher every message with
+// content is read, and without one every compressed message, newest first,
+// until enough matches are found. Plain content is matched and limited in
+// SQL.
+func (s *SearchService) searchMessages(terms []string, q SearchQuery) ([]MessageSearchHit, error) {
+	conditions := []string{"m.content IS NOT NULL"}
+	var args []interface{}
+	if q.Project != "" {
+		conditions = append(conditions, "s.project_name = ?")
+		args = append(args, q.Project)
+	}
+	if q.User != "" {
+		conditions = append(conditions, "s.user_id = ?")
+		args = append(args, q.User)
+	}
+	if s.cipher != nil {
+		return s.queryMessageHits(conditions, args, terms, q.Limit, false)
+	}
+
+	plainConditions := append([]string{"NOT starts_with(m.content, ?)"}, conditions...)
+	plainArgs := append([]interface{}{compressedContentPrefix}, args...)
+	for _, term := range terms {
+		plainConditions = append(plainConditions, `LOWER(m.content) LIKE ? ESCAPE '\'`)
+		plainArgs = append(plainArgs, likePattern(term))
+	}
+	hits, err := s.queryMessageHits(plainConditions, plainArgs, terms, q.Limit, true)
+	if err != nil {
+		return nil, err
+	}
+
+	compressed, err := s.queryMessageHits(append([]string{"starts_with(m.content, ?)"}, conditions...),
+		append([]interface{}{compressedContentPrefix}, args...), terms, q.Limit, false)
+	if err != nil {
+		return nil, err
+	}
+
+	hits = append(hits, compressed...)
+	sort.SliceStable(hits, func(i, j int) bool {
+		if !hits[i].Timestamp.Equal(hits[j].Timestamp) {
+			return hits[i].Timestamp.After(hits[j].Timestamp)
+		}
+		return hits[i].MessageID < hits[j].MessageID
+	})
+	if len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+// queryMessageHits reads matching messages newest first and stops once
+// limit of them contain every term. limitRows also caps the rows read in
+// SQL, for conditions that already match every term.
+func (s *SearchService) queryMessageHits(conditions []string, args []interface{}, terms []string, limit int, limitRows bool) ([]MessageSearchHit, error) {
+	query := `
+		SELECT m.id, m.session_id, COALESCE(s.project_name, ''), COALESCE(s.project_path, ''),
+			m.message_role, m.model, m.timestamp, m.content
+		FROM messages m
+		JOIN sessions s ON s.id = m.session_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY m.timestamp DESC, m.id`
+	if limitRows {
+		query += `
+		LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []MessageSearchHit
+	for len(hits) < limit && rows.Next() {
+		var hit MessageSearchHit
+		var content *string
+		if err := rows.Scan(&hit.MessageID, &hit.SessionID, &hit.ProjectName, &hit.ProjectPath,
+			&hit.Role, &hit.Model, &hit.Timestamp, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		content = s.cipher.Open(content)
+		snippet, highlights, ok := matchSnippet(*content, terms)
+		if !ok {
+			continue
+		}
+		hit.Snippet = snippet
+		hit.Highlights = highlights
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// matchSnippet checks that content contains every term and returns the
+// excerpt around the first match with all matches in it highlighted
+func matchSnippet(content string, terms []string) (string, []SearchHighlight, bool) {
+	text := []rune(content)
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	first := -1
+	for _, term := range terms {
+		i := indexRunes(lower, []rune(term), 0)
+		if i < 0 {
+			return "", nil, false
+		}
+		if first < 0 || i < first {
+			first = i
+		}
+	}
+
+	start := first - snippetRunes/3
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetRunes
+	if end > len(text) {
+		end = len(text)
+	}
+
+	window := lower[start:end]
+	covered := make([]bool, len(window))
+	for _, term := range terms {
+		needle := []rune(term)
+		for i := indexRunes(window, needle, 0); i >= 0; i = indexRunes(window, needle, i+len(needle)) {
+			for j := i; j < i+len(needle); j++ {
+				covered[j] = true
+			}
+		}
+	}
+	highlights := []SearchHighlight{}
+	for i := 0; i < len(covered); i++ {
+		if !covered[i] {
+			continue
+		}
+		j := i
+		for j < len(covered) && covered[j] {
+			j++
+		}
+		highlights = append(highlights, SearchHighlight{Start: i, End: j})
+		i = j
+	}
+	return string(text[start:end]), highlights, true
+}
+
+// indexRunes returns the index of needle in haystack at or after from, or -1
+func indexRunes(haystack, needle []rune, from int) int {
+	for i := from; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j, r := range needle {
+			if haystack[i+j] != r {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
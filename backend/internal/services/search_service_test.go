@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func setupSearchTest(t *testing.T, cipher *ContentCipher) *SearchService {
+	t.Helper()
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	sessions := [][3]string{{"s1", "auth-service", "/work/auth-service"}, {"s2", "billing", "/work/billing"}}
+	for _, session := range sessions {
+		if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES (?, ?, ?, ?)`,
+			session[0], session[1], session[2], time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)); err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+	}
+
+	messages := []struct {
+		id, session, content string
+		minute               int
+	}{
+		{"m1", "s1", "I refactored the Auth middleware to check tokens first.", 1},
+		{"m2", "s1", "The middleware now rejects expired tokens; auth tests pass.", 2},
+		{"m3", "s2", "Invoices use 100% of the auth budget_limit", 3},
+	}
+	for _, m := range messages {
+		content := m.content
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp)
+			VALUES (?, ?, 'assistant', ?, ?)
+		`, m.id, m.session, cipher.Seal(&content), time.Date(2024, 1, 1, 10, m.minute, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	search := NewSearchService(db)
+	search.SetContentCipher(cipher)
+	return search
+}
+
+func TestSearchMessages(t *testing.T) {
+	cipher, err := NewContentCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+
+	for name, c := range map[string]*ContentCipher{"plain": nil, "encrypted": cipher} {
+		t.Run(name, func(t *testing.T) {
+			search := setupSearchTest(t, c)
+
+			results, err := search.Search(SearchQuery{Text: "auth  MIDDLEWARE", Content: true})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(results.Messages) != 2 || results.Messages[0].MessageID != "m2" {
+				t.Fatalf("Expected m2 then m1, got %+v", results.Messages)
+			}
+			hit := results.Messages[1]
+			if hit.ProjectName != "auth-service" || len(hit.Highlights) != 2 {
+				t.Fatalf("Unexpected hit: %+v", hit)
+			}
+			if got := string([]rune(hit.Snippet)[hit.Highlights[0].Start:hit.Highlights[0].End]); got != "Auth" {
+				t.Errorf("Expected the first highlight on %q, got %q", "Auth", got)
+			}
+			if len(results.Sessions) != 0 {
+				t.Errorf("Expected no session matching both terms, got %+v", results.Sessions)
+			}
+
+			// LIKE wildcards are matched literally
+			results, err = search.Search(SearchQuery{Text: "100% budget_limit", Content: true})
+			if err != nil || len(results.Messages) != 1 || results.Messages[0].MessageID != "m3" {
+				t.Errorf("Expected only m3, got %+v (%v)", results, err)
+			}
+
+			results, err = search.Search(SearchQuery{Text: "auth", Project: "billing", Content: true})
+			if err != nil || len(results.Messages) != 1 || len(results.Sessions) != 0 {
+				t.Errorf("Expected only the billing message, got %+v (%v)", results, err)
+			}
+		})
+	}
+}
+
+func TestSearchWithoutContent(t *testing.T) {
+	search := setupSearchTest(t, nil)
+
+	results, err := search.Search(SearchQuery{Text: "auth"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if results.ContentSearched || len(results.Messages) != 0 {
+		t.Errorf("Expected content to be skipped, got %+v", results)
+	}
+	if len(results.Sessions) != 1 || results.Sessions[0].SessionID != "s1" {
+		t.Errorf("Expected the auth-service session, got %+v", results.Sessions)
+	}
+
+	if _, err := search.Search(SearchQuery{Text: "   "}); !errors.Is(err, ErrEmptySearch) {
+		t.Errorf("Expected ErrEmptySearch, got %v", err)
+	}
+}
+
+func TestSearchCompressedMessages(t *testing.T) {
+	search := setupSearchTest(t, nil)
+
+	// m4 and m5 are compressed and newer than the plain matches
+	for i, id := range []string{"m4", "m5"} {
+		content := compressContent("auth middleware " + strings.Repeat("padding ", 100))
+		if !IsCompressedContent(content) {
+			t.Fatal("Expected the content to be compressed")
+		}
+		_, err := search.db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp)
+			VALUES (?, 's1', 'assistant', ?, ?)
+		`, id, content, time.Date(2024, 1, 1, 11, i, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	results, err := search.Search(SearchQuery{Text: "auth middleware", Content: true, Limit: 3})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	var ids []string
+	for _, hit := range results.Messages {
+		ids = append(ids, hit.MessageID)
+	}
+	if strings.Join(ids, ",") != "m5,m4,m2" {
+		t.Errorf("Expected m5,m4,m2, got %v", ids)
+	}
+}
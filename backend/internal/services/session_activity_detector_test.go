@@ -29,7 +29,9 @@ func setupTestDBForActivity(t *testing.T) (*sql.DB, *SessionActivityDetector) {
 			total_tokens INTEGER DEFAULT 0,
 			message_count INTEGER DEFAULT 0,
 			status TEXT DEFAULT 'active',
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			title TEXT,
+			provider TEXT DEFAULT 'claude'
 		);
 
 		CREATE TABLE IF NOT EXISTS messages (
@@ -48,6 +50,9 @@ func setupTestDBForActivity(t *testing.T) (*sql.DB, *SessionActivityDetector) {
 			output_tokens INTEGER DEFAULT 0,
 			service_tier TEXT,
 			request_id TEXT,
+			content_hash TEXT,
+			billing TEXT,
+			provider TEXT DEFAULT 'claude',
 			timestamp TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
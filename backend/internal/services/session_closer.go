@@ -0,0 +1,120 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+)
+
+// Session statuses
+const (
+	SessionStatusActive    = "active"
+	SessionStatusCompleted = "completed"
+)
+
+// DefaultSessionIdleTimeout is how long a session goes without messages
+// before it is completed
+const DefaultSessionIdleTimeout = 30 * time.Minute
+
+var (
+	sessionIdleMu      sync.RWMutex
+	sessionIdleTimeout = DefaultSessionIdleTimeout
+)
+
+// SetSessionIdleTimeout sets how long a session may go without messages
+// before syncs and the SessionCloser complete it; 0 leaves sessions active
+func SetSessionIdleTimeout(d time.Duration) {
+	sessionIdleMu.Lock()
+	sessionIdleTimeout = d
+	sessionIdleMu.Unlock()
+}
+
+// currentSessionIdleTimeout returns the timeout set by SetSessionIdleTimeout
+func currentSessionIdleTimeout() time.Duration {
+	sessionIdleMu.RLock()
+	defer sessionIdleMu.RUnlock()
+	return sessionIdleTimeout
+}
+
+// CloseStaleSessions completes the active sessions whose last message is
+// older than the idle timeout at now, setting their end_time to it, and
+// returns how many it closed
+func CloseStaleSessions(db *sql.DB, now time.Time) (int64, error) {
+	idle := currentSessionIdleTimeout()
+	if idle <= 0 {
+		return 0, nil
+	}
+	result, err := db.Exec(`
+		UPDATE sessions SET
+			status = ?,
+			end_time = COALESCE((SELECT MAX(m.timestamp) FROM messages m WHERE m.session_id = sessions.id), end_time, start_time)
+		WHERE status = ?
+			AND COALESCE((SELECT MAX(m.timestamp) FROM messages m WHERE m.session_id = sessions.id), end_time, start_time) < ?
+	`, SessionStatusCompleted, SessionStatusActive, now.Add(-idle).UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to close stale sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// SessionCloser completes idle sessions in the background, so sessions
+// end even when no sync touches them
+type SessionCloser struct {
+	db       *sql.DB
+	writes   *WriteQueue
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewSessionCloser creates a closer that checks every interval once started
+func NewSessionCloser(db *sql.DB, writes *WriteQueue, interval time.Duration) *SessionCloser {
+	return &SessionCloser{
+		db:       db,
+		writes:   writes,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start closes idle sessions now and then every interval until Stop
+func (c *SessionCloser) Start() {
+	go c.run()
+}
+
+// Stop ends the background goroutine after its current run
+func (c *SessionCloser) Stop() {
+	c.once.Do(func() { close(c.stop) })
+	<-c.done
+}
+
+func (c *SessionCloser) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		var closed int64
+		err := c.writes.Do(func() error {
+			var err error
+			closed, err = CloseStaleSessions(c.db, time.Now())
+			return err
+		})
+		if err != nil {
+			logging.Component("sessions").Warn("Failed to close idle sessions", "err", err)
+		} else if closed > 0 {
+			logging.Component("sessions").Info("Closed idle sessions", "sessions", closed)
+		}
+		select {
+		case <-ticker.C:
+		case <-c.stop:
+			return
+		}
+	}
+}
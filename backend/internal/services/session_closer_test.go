@@ -0,0 +1,121 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func setupSessionCloserTest(t *testing.T) *sql.DB {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return db
+}
+
+func insertCloserSession(t *testing.T, db *sql.DB, id string, start time.Time, messages ...time.Time) {
+	t.Helper()
+	if _, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, status)
+		VALUES (?, 'app', '/work/app', ?, 'active')
+	`, id, start); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	for i, at := range messages {
+		if _, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, output_tokens, timestamp)
+			VALUES (?, ?, 'assistant', 10, ?)
+		`, id+"-"+string(rune('a'+i)), id, at); err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+}
+
+func sessionStatus(t *testing.T, db *sql.DB, id string) (string, sql.NullTime) {
+	t.Helper()
+	var status string
+	var end sql.NullTime
+	if err := db.QueryRow(`SELECT status, end_time FROM sessions WHERE id = ?`, id).Scan(&status, &end); err != nil {
+		t.Fatalf("Failed to get session %s: %v", id, err)
+	}
+	return status, end
+}
+
+func TestCloseStaleSessions(t *testing.T) {
+	db := setupSessionCloserTest(t)
+	defer SetSessionIdleTimeout(DefaultSessionIdleTimeout)
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	lastOld := now.Add(-2 * time.Hour)
+	insertCloserSession(t, db, "old", now.Add(-3*time.Hour), now.Add(-3*time.Hour), lastOld)
+	insertCloserSession(t, db, "recent", now.Add(-time.Hour), now.Add(-time.Hour), now.Add(-10*time.Minute))
+	insertCloserSession(t, db, "empty", now.Add(-time.Hour))
+
+	SetSessionIdleTimeout(0)
+	if closed, err := CloseStaleSessions(db, now); err != nil || closed != 0 {
+		t.Fatalf("Expected no sessions closed without a timeout, got %d, %v", closed, err)
+	}
+
+	SetSessionIdleTimeout(30 * time.Minute)
+	closed, err := CloseStaleSessions(db, now)
+	if err != nil {
+		t.Fatalf("Failed to close sessions: %v", err)
+	}
+	if closed != 2 {
+		t.Errorf("Expected 2 sessions closed, got %d", closed)
+	}
+
+	status, end := sessionStatus(t, db, "old")
+	if status != SessionStatusCompleted || !end.Valid || !end.Time.Equal(lastOld) {
+		t.Errorf("Expected old session completed at %v, got %s at %v", lastOld, status, end)
+	}
+	if status, _ := sessionStatus(t, db, "recent"); status != SessionStatusActive {
+		t.Errorf("Expected recent session to stay active, got %s", status)
+	}
+	// A session without messages ends when it started
+	status, end = sessionStatus(t, db, "empty")
+	if status != SessionStatusCompleted || !end.Valid || !end.Time.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Expected empty session completed at its start, got %s at %v", status, end)
+	}
+
+	if closed, err := CloseStaleSessions(db, now); err != nil || closed != 0 {
+		t.Errorf("Expected a second run to close nothing, got %d, %v", closed, err)
+	}
+}
+
+func TestUpdateSessionTokensStatus(t *testing.T) {
+	db := setupSessionCloserTest(t)
+	defer SetSessionIdleTimeout(DefaultSessionIdleTimeout)
+	SetSessionIdleTimeout(30 * time.Minute)
+	tokens := NewTokenService(db)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	insertCloserSession(t, db, "stale", now.Add(-3*time.Hour), now.Add(-2*time.Hour))
+	if err := tokens.UpdateSessionTokens("stale"); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	if status, end := sessionStatus(t, db, "stale"); status != SessionStatusCompleted || !end.Valid {
+		t.Errorf("Expected a synced stale session to be completed, got %s at %v", status, end)
+	}
+
+	// New messages reopen a completed session
+	if _, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, output_tokens, timestamp)
+		VALUES ('stale-new', 'stale', 'assistant', 10, ?)
+	`, now); err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+	if err := tokens.UpdateSessionTokens("stale"); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	if status, _ := sessionStatus(t, db, "stale"); status != SessionStatusActive {
+		t.Errorf("Expected new messages to reopen the session, got %s", status)
+	}
+}
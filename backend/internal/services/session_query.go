@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// ErrInvalidSessionQuery is returned for an unknown sort field
+var ErrInvalidSessionQuery = errors.New("invalid session query")
+
+// sessionSortColumns maps the sort fields of SessionQuery to columns
+var sessionSortColumns = map[string]string{
+	"start_time":    "COALESCE(s.start_time, s.created_at)",
+	"end_time":      "s.end_time",
+	"total_tokens":  "s.total_tokens",
+	"message_count": "s.message_count",
+	"project_name":  "s.project_name",
+}
+
+// DefaultSessionSort lists the newest sessions first
+const DefaultSessionSort = "-start_time"
+
+// SessionQuery filters, sorts and pages the session list. Empty fields do not
+// filter. Sort is a field of sessionSortColumns, prefixed with "-" for
+// descending order.
+type SessionQuery struct {
+	Project string
+	// User keeps the sessions synced from this user's logs
+	User string
+	// Model keeps sessions with at least one assistant message from the model
+	Model  string
+	Status string
+	// Provider keeps the sessions read from this tool's logs
+	Provider string
+	// Tags keeps sessions carrying every one of the tags
+	Tags []string
+	// From and To keep sessions active at some point in [From, To)
+	From   time.Time
+	To     time.Time
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// SessionPage is one page of sessions with the number of matching sessions
+type SessionPage struct {
+	Sessions []models.SessionSummary
+	Total    int
+}
+
+// QuerySessions returns the page of sessions matching q
+func (s *SessionService) QuerySessions(q SessionQuery) (*SessionPage, error) {
+	sort := q.Sort
+	if sort == "" {
+		sort = DefaultSessionSort
+	}
+	direction := "ASC"
+	if strings.HasPrefix(sort, "-") {
+		direction = "DESC"
+	}
+	column, ok := sessionSortColumns[strings.TrimPrefix(sort, "-")]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidSessionQuery, strings.TrimPrefix(sort, "-"))
+	}
+
+	var conditions []string
+	var args []interface{}
+	if q.Project != "" {
+		conditions = append(conditions, "s.project_name = ?")
+		args = append(args, q.Project)
+	}
+	if q.User != "" {
+		conditions = append(conditions, "s.user_id = ?")
+		args = append(args, q.User)
+	}
+	if q.Status != "" {
+		conditions = append(conditions, "s.status = ?")
+		args = append(args, q.Status)
+	}
+	if q.Provider != "" {
+		conditions = append(conditions, "COALESCE(s.provider, 'claude') = ?")
+		args = append(args, q.Provider)
+	}
+	if q.Model != "" {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM messages m
+			WHERE m.session_id = s.id AND m.message_role = 'assistant' AND m.model = ?
+		)`)
+		args = append(args, q.Model)
+	}
+	for _, tag := range q.Tags {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM session_tags t WHERE t.session_id = s.id AND t.tag = ?)")
+		args = append(args, tag)
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "COALESCE(s.end_time, s.start_time, s.created_at) >= ?")
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "COALESCE(s.start_time, s.created_at) < ?")
+		args = append(args, q.To)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	page := &SessionPage{}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sessions s `+where, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count sessions: %w", err)
+	}
+
+	// The id keeps pages stable when sort values tie
+	rows, err := s.db.Query(`
+		SELECT
+			s.id,
+			s.project_name,
+			s.project_path,
+			s.start_time,
+			s.end_time,
+			s.total_input_tokens,
+			s.total_output_tokens,
+			s.total_tokens,
+			s.message_count,
+			s.status,
+			s.created_at,
+			COALESCE(s.provider, 'claude')
+		FROM sessions s
+		`+where+`
+		ORDER BY `+column+` `+direction+` NULLS LAST, s.id
+		LIMIT ? OFFSET ?
+	`, append(args, q.Limit, q.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions, err := scanSessionSummaries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadAnnotations(sessions); err != nil {
+		return nil, err
+	}
+	page.Sessions = sessions
+	if page.Sessions == nil {
+		page.Sessions = []models.SessionSummary{}
+	}
+	return page, nil
+}
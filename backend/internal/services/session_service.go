@@ -13,6 +13,7 @@ import (
 type SessionService struct {
 	db               *sql.DB
 	activityDetector *SessionActivityDetector
+	cipher           *ContentCipher
 }
 
 func NewSessionService(db *sql.DB) *SessionService {
@@ -22,6 +23,11 @@ func NewSessionService(db *sql.DB) *SessionService {
 	}
 }
 
+// SetContentCipher decrypts stored content when reading messages
+func (s *SessionService) SetContentCipher(c *ContentCipher) {
+	s.cipher = c
+}
+
 func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 	// Simplified query without JOIN for better performance
 	query := `
@@ -36,7 +42,8 @@ func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 			s.total_tokens,
 			s.message_count,
 			s.status,
-			s.created_at
+			s.created_at,
+			COALESCE(s.provider, 'claude')
 		FROM sessions s
 		ORDER BY s.start_time DESC
 	`
@@ -47,6 +54,11 @@ func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 	}
 	defer rows.Close()
 	
+	return scanSessionSummaries(rows)
+}
+
+// scanSessionSummaries reads list rows of the sessions table
+func scanSessionSummaries(rows *sql.Rows) ([]models.SessionSummary, error) {
 	var sessions []models.SessionSummary
 	
 	for rows.Next() {
@@ -65,6 +77,7 @@ func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 			&session.MessageCount,
 			&session.Status,
 			&session.CreatedAt,
+			&session.Provider,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan session: %w", err)
@@ -86,14 +99,14 @@ func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 			session.Duration = &duration
 		}
 		
-		// Skip generated code extraction for performance in GetAllSessions
+		// Skip generated code extraction for performance in list views
 		// This can be added later on-demand per session
 		session.GeneratedCode = nil
 		
 		sessions = append(sessions, session)
 	}
 	
-	return sessions, nil
+	return sessions, rows.Err()
 }
 
 func (s *SessionService) GetSessionByID(sessionID string) (*models.SessionSummary, error) {
@@ -110,13 +123,14 @@ func (s *SessionService) GetSessionByID(sessionID string) (*models.SessionSummar
 			s.message_count,
 			s.status,
 			s.created_at,
+			COALESCE(s.provider, 'claude'),
 			MAX(m.timestamp) as last_activity
 		FROM sessions s
 		LEFT JOIN messages m ON s.id = m.session_id
 		WHERE s.id = ?
 		GROUP BY s.id, s.project_name, s.project_path, s.start_time, s.end_time, 
 				 s.total_input_tokens, s.total_output_tokens, s.total_tokens, 
-				 s.message_count, s.status, s.created_at
+				 s.message_count, s.status, s.created_at, s.provider
 	`
 	
 	var session models.SessionSummary
@@ -135,6 +149,7 @@ func (s *SessionService) GetSessionByID(sessionID string) (*models.SessionSummar
 		&session.MessageCount,
 		&session.Status,
 		&session.CreatedAt,
+		&session.Provider,
 		&lastActivity,
 	)
 	if err != nil {
@@ -176,7 +191,12 @@ func (s *SessionService) GetSessionByID(sessionID string) (*models.SessionSummar
 	}
 	session.GeneratedCode = generatedCode
 	
-	return &session, nil
+	sessions := []models.SessionSummary{session}
+	if err := s.loadAnnotations(sessions); err != nil {
+		return nil, err
+	}
+	
+	return &sessions[0], nil
 }
 
 func (s *SessionService) GetSessionMessages(sessionID string) ([]models.Message, error) {
@@ -185,7 +205,7 @@ func (s *SessionService) GetSessionMessages(sessionID string) ([]models.Message,
 			id, session_id, parent_uuid, is_sidechain, user_type, message_type,
 			message_role, model, content, input_tokens, cache_creation_input_tokens,
 			cache_read_input_tokens, output_tokens, service_tier, request_id,
-			timestamp, created_at
+			COALESCE(provider, 'claude'), timestamp, created_at
 		FROM messages 
 		WHERE session_id = ?
 		ORDER BY timestamp ASC
@@ -217,12 +237,14 @@ func (s *SessionService) GetSessionMessages(sessionID string) ([]models.Message,
 			&message.OutputTokens,
 			&message.ServiceTier,
 			&message.RequestID,
+			&message.Provider,
 			&message.Timestamp,
 			&message.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan message: %w", err)
 		}
+		message.Content = s.cipher.Open(message.Content)
 		
 		messages = append(messages, message)
 	}
@@ -267,7 +289,7 @@ func (s *SessionService) GetSessionMessagesPaginated(sessionID string, page, pag
 			id, session_id, parent_uuid, is_sidechain, user_type, message_type,
 			message_role, model, content, input_tokens, cache_creation_input_tokens,
 			cache_read_input_tokens, output_tokens, service_tier, request_id,
-			timestamp, created_at
+			COALESCE(provider, 'claude'), timestamp, created_at
 		FROM messages 
 		WHERE session_id = ?
 		ORDER BY timestamp ASC
@@ -300,12 +322,14 @@ func (s *SessionService) GetSessionMessagesPaginated(sessionID string, page, pag
 			&message.OutputTokens,
 			&message.ServiceTier,
 			&message.RequestID,
+			&message.Provider,
 			&message.Timestamp,
 			&message.CreatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan message: %w", err)
 		}
+		message.Content = s.cipher.Open(message.Content)
 		
 		messages = append(messages, message)
 	}
@@ -322,10 +346,21 @@ func (s *SessionService) GetSessionMessagesPaginated(sessionID string, page, pag
 }
 
 func (s *SessionService) CreateOrUpdateSession(sessionID, projectName, projectPath string, messageTime ...time.Time) error {
+	return s.CreateOrUpdateUserSession(sessionID, "", projectName, projectPath, messageTime...)
+}
+
+// CreateOrUpdateUserSession is CreateOrUpdateSession for a session owned by
+// userID; the owner of an existing session is left unchanged
+func (s *SessionService) CreateOrUpdateUserSession(sessionID, userID, projectName, projectPath string, messageTime ...time.Time) error {
+	return createOrUpdateUserSession(s.db, sessionID, userID, projectName, projectPath, messageTime...)
+}
+
+// createOrUpdateUserSession is CreateOrUpdateUserSession within q
+func createOrUpdateUserSession(q sqlExecutor, sessionID, userID, projectName, projectPath string, messageTime ...time.Time) error {
 	// Check if session exists
 	var exists bool
 	checkQuery := `SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ?)`
-	err := s.db.QueryRow(checkQuery, sessionID).Scan(&exists)
+	err := q.QueryRow(checkQuery, sessionID).Scan(&exists)
 	if err != nil {
 		return fmt.Errorf("failed to check session existence: %w", err)
 	}
@@ -338,7 +373,7 @@ func (s *SessionService) CreateOrUpdateSession(sessionID, projectName, projectPa
 				SET start_time = ? 
 				WHERE id = ? AND start_time > ?
 			`
-			_, err = s.db.Exec(updateQuery, messageTime[0], sessionID, messageTime[0])
+			_, err = q.Exec(updateQuery, messageTime[0], sessionID, messageTime[0])
 			if err != nil {
 				return fmt.Errorf("failed to update session start time: %w", err)
 			}
@@ -353,7 +388,7 @@ func (s *SessionService) CreateOrUpdateSession(sessionID, projectName, projectPa
 			// Get the earliest message timestamp for this session to use as start time
 			var firstMessageTime sql.NullTime
 			timeQuery := `SELECT MIN(timestamp) FROM messages WHERE session_id = ?`
-			err = s.db.QueryRow(timeQuery, sessionID).Scan(&firstMessageTime)
+			err = q.QueryRow(timeQuery, sessionID).Scan(&firstMessageTime)
 			
 			// If no messages found yet, use current time as fallback
 			startTime = time.Now()
@@ -363,11 +398,18 @@ func (s *SessionService) CreateOrUpdateSession(sessionID, projectName, projectPa
 		}
 		
 		// Insert new session
-		insertQuery := `
-			INSERT INTO sessions (id, project_name, project_path, start_time, status)
-			VALUES (?, ?, ?, ?, 'active')
-		`
-		_, err = s.db.Exec(insertQuery, sessionID, projectName, projectPath, startTime)
+		if userID != "" {
+			_, err = q.Exec(`
+				INSERT INTO sessions (id, project_name, project_path, start_time, status, user_id)
+				VALUES (?, ?, ?, ?, 'active', ?)
+			`, sessionID, projectName, projectPath, startTime, userID)
+		} else {
+			insertQuery := `
+				INSERT INTO sessions (id, project_name, project_path, start_time, status)
+				VALUES (?, ?, ?, ?, 'active')
+			`
+			_, err = q.Exec(insertQuery, sessionID, projectName, projectPath, startTime)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to create session: %w", err)
 		}
@@ -407,7 +449,7 @@ func (s *SessionService) extractGeneratedCode(sessionID string) ([]string, error
 		}
 		
 		if content.Valid {
-			extractedCode := extractCodeFromContent(content.String)
+			extractedCode := extractCodeFromContent(*s.cipher.Open(&content.String))
 			if len(extractedCode) > 0 {
 				codeBlocks = append(codeBlocks, extractedCode...)
 			}
@@ -417,8 +459,9 @@ func (s *SessionService) extractGeneratedCode(sessionID string) ([]string, error
 	return codeBlocks, nil
 }
 
-// GetSessionActivityReport returns detailed activity analysis for a session
-func (s *SessionService) GetSessionActivityReport(sessionID string) (map[string]interface{}, error) {
+// GetSessionActivityReport returns detailed activity analysis for a session,
+// with its timeline split at pauses longer than idleGap and hours of loc
+func (s *SessionService) GetSessionActivityReport(sessionID string, idleGap time.Duration, loc *time.Location) (map[string]interface{}, error) {
 	// Get session details
 	session, err := s.GetSessionByID(sessionID)
 	if err != nil {
@@ -433,6 +476,12 @@ func (s *SessionService) GetSessionActivityReport(sessionID string) (map[string]
 
 	// Generate detailed report
 	report := s.activityDetector.GetSessionActivityReport(sessionID, session.Session, lastActivity)
+
+	timeline, err := s.GetSessionTimeline(sessionID, idleGap, loc)
+	if err != nil {
+		return nil, err
+	}
+	report["timeline"] = timeline
 	return report, nil
 }
 
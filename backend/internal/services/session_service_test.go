@@ -2,6 +2,7 @@ package services
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -31,7 +32,24 @@ func setupTestDBForSession(t *testing.T) *sql.DB {
 			status TEXT DEFAULT 'active',
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			is_active BOOLEAN DEFAULT TRUE,
-			generated_code TEXT
+			generated_code TEXT,
+			notes TEXT,
+			title TEXT,
+			source_missing_at TIMESTAMP,
+			provider TEXT DEFAULT 'claude'
+		);
+
+		CREATE TABLE IF NOT EXISTS session_notes (
+			session_id TEXT PRIMARY KEY,
+			notes TEXT NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS session_tags (
+			session_id TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (session_id, tag)
 		);
 
 		CREATE TABLE IF NOT EXISTS messages (
@@ -50,6 +68,9 @@ func setupTestDBForSession(t *testing.T) *sql.DB {
 			output_tokens INTEGER DEFAULT 0,
 			service_tier TEXT,
 			request_id TEXT,
+			content_hash TEXT,
+			billing TEXT,
+			provider TEXT DEFAULT 'claude',
 			timestamp TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (session_id) REFERENCES sessions(id)
@@ -664,4 +685,73 @@ func TestGetSessionByID_NotFound(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for non-existent session, got nil")
 	}
-}
\ No newline at end of file
+}
+func TestQuerySessions(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	base := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		project := "alpha"
+		if i%2 == 1 {
+			project = "beta"
+		}
+		start := base.Add(time.Duration(i) * 24 * time.Hour)
+		_, err := db.Exec(`
+			INSERT INTO sessions (id, project_name, project_path, start_time, end_time, total_tokens, status)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, fmt.Sprintf("s%d", i), project, "/"+project, start, start.Add(time.Hour), (5-i)*100, "completed")
+		if err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+	}
+	if _, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, model, timestamp)
+		VALUES ('m1', 's3', 'assistant', 'claude-opus-4-20250514', ?)
+	`, base); err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+
+	service := NewSessionService(db)
+	page, err := service.QuerySessions(SessionQuery{Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if page.Total != 5 || len(page.Sessions) != 2 || page.Sessions[0].ID != "s2" {
+		t.Errorf("Expected the third and fourth newest sessions of 5, got %d %+v", page.Total, page.Sessions)
+	}
+
+	page, err = service.QuerySessions(SessionQuery{Project: "alpha", Sort: "total_tokens", Limit: 10})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if page.Total != 3 || page.Sessions[0].ID != "s4" || page.Sessions[2].ID != "s0" {
+		t.Errorf("Expected alpha sessions by ascending tokens, got %+v", page.Sessions)
+	}
+
+	page, err = service.QuerySessions(SessionQuery{Model: "claude-opus-4-20250514", Limit: 10})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if page.Total != 1 || page.Sessions[0].ID != "s3" {
+		t.Errorf("Expected only s3 for the model filter, got %+v", page.Sessions)
+	}
+
+	// s1 ends at 11:00 on Jan 2; s2 starts on Jan 3
+	page, err = service.QuerySessions(SessionQuery{From: base.Add(24*time.Hour + 30*time.Minute), To: base.Add(48 * time.Hour), Limit: 10})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if page.Total != 1 || page.Sessions[0].ID != "s1" {
+		t.Errorf("Expected only s1 in the range, got %+v", page.Sessions)
+	}
+
+	page, err = service.QuerySessions(SessionQuery{Status: "active", Limit: 10})
+	if err != nil || page.Total != 0 || page.Sessions == nil {
+		t.Errorf("Expected an empty page, got %+v (%v)", page, err)
+	}
+
+	if _, err := service.QuerySessions(SessionQuery{Sort: "id; DROP TABLE sessions", Limit: 10}); !errors.Is(err, ErrInvalidSessionQuery) {
+		t.Errorf("Expected ErrInvalidSessionQuery, got %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// ErrInvalidTag is returned for an empty, overlong or malformed tag
+var ErrInvalidTag = errors.New("invalid tag")
+
+// MaxTagLength bounds a tag in bytes
+const MaxTagLength = 64
+
+// NormalizeTags trims tags and drops duplicates, keeping their order. Tags
+// cannot contain commas, which separate tags in the ?tag= filter.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "":
+			return nil, fmt.Errorf("%w: tags cannot be empty", ErrInvalidTag)
+		case len(tag) > MaxTagLength:
+			return nil, fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidTag, tag, MaxTagLength)
+		case strings.ContainsAny(tag, ",\n\r\t"):
+			return nil, fmt.Errorf("%w: %q contains a comma or control character", ErrInvalidTag, tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// SessionUpdate changes a session's annotations. Nil fields are left as they are.
+type SessionUpdate struct {
+	// Tags replaces the session's tags
+	Tags *[]string
+	// Notes replaces the session's notes; an empty string clears them
+	Notes *string
+}
+
+// AddSessionTags tags a session; tags it already has are kept once
+func (s *SessionService) AddSessionTags(sessionID string, tags []string) error {
+	tags, err := NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+	if err := s.requireSession(sessionID); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := s.db.Exec(`
+			INSERT INTO session_tags (session_id, tag, created_at) VALUES (?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, sessionID, tag, time.Now().UTC()); err != nil {
+			return fmt.Errorf("failed to tag session: %w", err)
+		}
+	}
+	return nil
+}
+
+// RemoveSessionTag removes a tag from a session; removing a tag it does not
+// have is not an error
+func (s *SessionService) RemoveSessionTag(sessionID, tag string) error {
+	if err := s.requireSession(sessionID); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM session_tags WHERE session_id = ? AND tag = ?`, sessionID, tag); err != nil {
+		return fmt.Errorf("failed to untag session: %w", err)
+	}
+	return nil
+}
+
+// UpdateSession applies an update to a session's tags and notes
+func (s *SessionService) UpdateSession(sessionID string, update SessionUpdate) error {
+	var tags []string
+	if update.Tags != nil {
+		var err error
+		if tags, err = NormalizeTags(*update.Tags); err != nil {
+			return err
+		}
+	}
+	if err := s.requireSession(sessionID); err != nil {
+		return err
+	}
+
+	if update.Notes != nil {
+		var err error
+		if strings.TrimSpace(*update.Notes) == "" {
+			_, err = s.db.Exec(`DELETE FROM session_notes WHERE session_id = ?`, sessionID)
+		} else {
+			_, err = s.db.Exec(`
+				INSERT OR REPLACE INTO session_notes (session_id, notes, updated_at) VALUES (?, ?, ?)
+			`, sessionID, *update.Notes, time.Now())
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update session notes: %w", err)
+		}
+	}
+	if update.Tags != nil {
+		if _, err := s.db.Exec(`DELETE FROM session_tags WHERE session_id = ?`, sessionID); err != nil {
+			return fmt.Errorf("failed to replace session tags: %w", err)
+		}
+		return s.AddSessionTags(sessionID, tags)
+	}
+	return nil
+}
+
+func (s *SessionService) requireSession(sessionID string) error {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sessions WHERE id = ?)`, sessionID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up session: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+	return nil
+}
+
+// loadAnnotations fills in the titles, tags and notes of sessions and when
+// their log files went missing
+func (s *SessionService) loadAnnotations(sessions []models.SessionSummary) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	index := make(map[string]int, len(sessions))
+	placeholders := make([]string, len(sessions))
+	args := make([]interface{}, len(sessions))
+	for i := range sessions {
+		sessions[i].Tags = []string{}
+		index[sessions[i].ID] = i
+		placeholders[i] = "?"
+		args[i] = sessions[i].ID
+	}
+	in := "(" + strings.Join(placeholders, ", ") + ")"
+
+	rows, err := s.db.Query(`SELECT session_id, tag FROM session_tags WHERE session_id IN `+in+` ORDER BY tag`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get session tags: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return fmt.Errorf("failed to scan session tag: %w", err)
+		}
+		sessions[index[id]].Tags = append(sessions[index[id]].Tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read session tags: %w", err)
+	}
+
+	notes, err := s.db.Query(`
+		SELECT s.id, s.title, n.notes, s.source_missing_at
+		FROM sessions s
+		LEFT JOIN session_notes n ON n.session_id = s.id
+		WHERE (s.title IS NOT NULL OR n.notes IS NOT NULL OR s.source_missing_at IS NOT NULL) AND s.id IN `+in, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get session notes: %w", err)
+	}
+	defer notes.Close()
+	for notes.Next() {
+		var id string
+		var title, note sql.NullString
+		var missing sql.NullTime
+		if err := notes.Scan(&id, &title, &note, &missing); err != nil {
+			return fmt.Errorf("failed to scan session notes: %w", err)
+		}
+		if title.Valid {
+			sessions[index[id]].Title = s.cipher.Open(&title.String)
+		}
+		if note.Valid {
+			sessions[index[id]].Notes = &note.String
+		}
+		if missing.Valid {
+			sessions[index[id]].SourceMissingAt = &missing.Time
+		}
+	}
+	return notes.Err()
+}
+
+// TagSummary is the token and cost total of the sessions carrying a tag
+type TagSummary struct {
+	Tag                      string  `json:"tag"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	TotalTokens              int64   `json:"total_tokens"`
+	Cost                     float64 `json:"cost"`
+	MessageCount             int     `json:"message_count"`
+	SessionCount             int     `json:"session_count"`
+}
+
+// TagService aggregates usage by session tag. A session with several tags
+// counts toward each, so tag totals can add up to more than the overall total.
+type TagService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+}
+
+func NewTagService(db *sql.DB) *TagService {
+	return &TagService{db: db, pricing: NewPricingCalculator()}
+}
+
+// GetTags returns every tag in use with the usage of its sessions in
+// [since, until), most expensive first. Zero times leave the range open; an
+// empty user includes every user.
+func (s *TagService) GetTags(user string, since, until time.Time) ([]TagSummary, error) {
+	where, args := rangeFilter("", user, since, until)
+	rows, err := s.db.Query(`
+		SELECT
+			t.tag,
+			COALESCE(m.model, 'unknown'),
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0),
+			COUNT(*) FILTER (WHERE m.message_role = 'assistant')
+		FROM session_tags t
+		JOIN sessions s ON t.session_id = s.id
+		JOIN messages m ON m.session_id = s.id
+		`+where+`
+		GROUP BY t.tag, COALESCE(m.model, 'unknown')
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag usage: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[string]*TagSummary)
+	for rows.Next() {
+		var tag string
+		var model ModelUsage
+		if err := rows.Scan(&tag, &model.Model, &model.InputTokens, &model.OutputTokens,
+			&model.CacheCreationInputTokens, &model.CacheReadInputTokens, &model.MessageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan tag usage: %w", err)
+		}
+		summary, ok := tags[tag]
+		if !ok {
+			summary = &TagSummary{Tag: tag}
+			tags[tag] = summary
+		}
+		summary.InputTokens += model.InputTokens
+		summary.OutputTokens += model.OutputTokens
+		summary.CacheCreationInputTokens += model.CacheCreationInputTokens
+		summary.CacheReadInputTokens += model.CacheReadInputTokens
+		summary.TotalTokens += model.InputTokens + model.OutputTokens
+		summary.MessageCount += model.MessageCount
+		if model.Model != "unknown" {
+			summary.Cost += s.pricing.CalculateCost(model.Model, int(model.InputTokens), int(model.OutputTokens),
+				int(model.CacheCreationInputTokens), int(model.CacheReadInputTokens))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tag usage: %w", err)
+	}
+
+	// Tags of sessions without activity in the range are listed with zero totals
+	userFilter, userArgs := rangeFilter("", user, time.Time{}, time.Time{})
+	tagRows, err := s.db.Query(`
+		SELECT DISTINCT t.tag
+		FROM session_tags t
+		JOIN sessions s ON t.session_id = s.id
+		`+userFilter, userArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var tag string
+		if err := tagRows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if _, ok := tags[tag]; !ok {
+			tags[tag] = &TagSummary{Tag: tag}
+		}
+	}
+	if err := tagRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+
+	active, err := s.activeSessions(where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]TagSummary, 0, len(tags))
+	for tag, summary := range tags {
+		summary.SessionCount = active[tag]
+		summary.Cost = roundToDecimals(summary.Cost, 6)
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Cost != summaries[j].Cost {
+			return summaries[i].Cost > summaries[j].Cost
+		}
+		return summaries[i].Tag < summaries[j].Tag
+	})
+	return summaries, nil
+}
+
+// activeSessions counts the sessions per tag with messages matching where
+func (s *TagService) activeSessions(where string, args []interface{}) (map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT t.tag, COUNT(DISTINCT s.id)
+		FROM session_tags t
+		JOIN sessions s ON t.session_id = s.id
+		JOIN messages m ON m.session_id = s.id
+		`+where+`
+		GROUP BY t.tag
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tagged sessions: %w", err)
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var tag string
+		var count int
+		if err := rows.Scan(&tag, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan tagged sessions: %w", err)
+		}
+		counts[tag] = count
+	}
+	return counts, rows.Err()
+}
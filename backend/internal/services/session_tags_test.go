@@ -0,0 +1,118 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestSessionTagsAndNotes(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) VALUES
+			('s1', 'api', '/work/api', '2024-03-01 09:00:00'),
+			('s2', 'api', '/work/api', '2024-03-05 09:00:00'),
+			('s3', 'web', '/work/web', '2024-03-02 09:00:00');
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp) VALUES
+			('a1', 's1', 'assistant', 'claude-opus-4-20250514', 1000, 500, '2024-03-01 09:00:10'),
+			('a2', 's2', 'assistant', 'claude-sonnet-4-20250514', 3000, 1000, '2024-03-05 09:00:10'),
+			('a3', 's3', 'assistant', 'claude-sonnet-4-20250514', 100, 50, '2024-03-02 09:00:10');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	sessions := NewSessionService(db)
+	if err := sessions.AddSessionTags("s1", []string{" billable-client-X ", "experiment", "experiment"}); err != nil {
+		t.Fatalf("Failed to tag session: %v", err)
+	}
+	if err := sessions.AddSessionTags("s2", []string{"billable-client-X"}); err != nil {
+		t.Fatalf("Failed to tag session: %v", err)
+	}
+	notes := "Spike for the Q2 proposal"
+	if err := sessions.UpdateSession("s3", SessionUpdate{Notes: &notes, Tags: &[]string{"experiment"}}); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	s1, err := sessions.GetSessionByID("s1")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if len(s1.Tags) != 2 || s1.Tags[0] != "billable-client-X" || s1.Tags[1] != "experiment" || s1.Notes != nil {
+		t.Errorf("Expected two tags and no notes, got %v %v", s1.Tags, s1.Notes)
+	}
+
+	page, err := sessions.QuerySessions(SessionQuery{Tags: []string{"experiment"}, Sort: "start_time", Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to query sessions: %v", err)
+	}
+	if page.Total != 2 || page.Sessions[0].ID != "s1" || page.Sessions[1].ID != "s3" {
+		t.Fatalf("Expected s1 and s3 tagged experiment, got %+v", page.Sessions)
+	}
+	if page.Sessions[1].Notes == nil || *page.Sessions[1].Notes != notes {
+		t.Errorf("Expected s3's notes in the list, got %v", page.Sessions[1].Notes)
+	}
+	page, err = sessions.QuerySessions(SessionQuery{Tags: []string{"experiment", "billable-client-X"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to query sessions: %v", err)
+	}
+	if page.Total != 1 || page.Sessions[0].ID != "s1" {
+		t.Errorf("Expected only s1 to carry both tags, got %+v", page.Sessions)
+	}
+
+	tags, err := NewTagService(db).GetTags("", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	if len(tags) != 2 || tags[0].Tag != "billable-client-X" || tags[1].Tag != "experiment" {
+		t.Fatalf("Expected billable-client-X then experiment, got %+v", tags)
+	}
+	// 1000 opus input + 500 opus output + 3000 sonnet input + 1000 sonnet output
+	if expected := 0.015 + 0.0375 + 0.009 + 0.015; tags[0].Cost < expected-1e-6 || tags[0].Cost > expected+1e-6 {
+		t.Errorf("Expected billable cost %f, got %f", expected, tags[0].Cost)
+	}
+	if tags[0].SessionCount != 2 || tags[0].TotalTokens != 5500 || tags[1].TotalTokens != 1650 {
+		t.Errorf("Unexpected tag totals: %+v", tags)
+	}
+
+	// Replacing tags and clearing notes
+	empty := ""
+	if err := sessions.UpdateSession("s3", SessionUpdate{Notes: &empty, Tags: &[]string{}}); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	if err := sessions.RemoveSessionTag("s1", "experiment"); err != nil {
+		t.Fatalf("Failed to untag session: %v", err)
+	}
+	s3, err := sessions.GetSessionByID("s3")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if len(s3.Tags) != 0 || s3.Notes != nil {
+		t.Errorf("Expected s3 cleared, got %v %v", s3.Tags, s3.Notes)
+	}
+	tags, err = NewTagService(db).GetTags("", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get tags: %v", err)
+	}
+	if len(tags) != 1 {
+		t.Errorf("Expected experiment to be gone, got %+v", tags)
+	}
+
+	if err := sessions.AddSessionTags("s1", []string{"a,b"}); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("Expected ErrInvalidTag, got %v", err)
+	}
+	if err := sessions.AddSessionTags("missing", []string{"x"}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultIdleGap is the pause between messages that ends a burst of activity
+const DefaultIdleGap = 5 * time.Minute
+
+// ActivityBurst is a stretch of a session without an idle gap
+type ActivityBurst struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationMinutes float64   `json:"duration_minutes"`
+	MessageCount    int       `json:"message_count"`
+	ToolCalls       int       `json:"tool_calls"`
+	TotalTokens     int64     `json:"total_tokens"`
+	// ToolCallsPerMinute counts bursts shorter than a minute as one minute
+	ToolCallsPerMinute float64 `json:"tool_calls_per_minute"`
+}
+
+// IdleGap is a pause between bursts
+type IdleGap struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationMinutes float64   `json:"duration_minutes"`
+}
+
+// HourlyActivity is a session's usage in one hour
+type HourlyActivity struct {
+	Hour         time.Time `json:"hour"`
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	TotalTokens  int64     `json:"total_tokens"`
+	MessageCount int       `json:"message_count"`
+	ToolCalls    int       `json:"tool_calls"`
+}
+
+// SessionTimeline is a session's activity over time: bursts separated by
+// idle gaps, and tokens and tool calls per hour
+type SessionTimeline struct {
+	Start          *time.Time `json:"start"`
+	End            *time.Time `json:"end"`
+	IdleGapMinutes float64    `json:"idle_gap_minutes"`
+	ActiveMinutes  float64    `json:"active_minutes"`
+	IdleMinutes    float64    `json:"idle_minutes"`
+	// ToolCallsPerActiveMinute is the tool call density of the bursts
+	ToolCallsPerActiveMinute float64          `json:"tool_calls_per_active_minute"`
+	Bursts                   []ActivityBurst  `json:"bursts"`
+	Gaps                     []IdleGap        `json:"gaps"`
+	Hours                    []HourlyActivity `json:"hours"`
+}
+
+type timelineMessage struct {
+	at            time.Time
+	input, output int64
+	assistant     bool
+}
+
+// GetSessionTimeline splits a session into bursts wherever messages are more
+// than idleGap apart and adds up its usage per hour of loc. Tokens count
+// assistant messages, as session totals do.
+func (s *SessionService) GetSessionTimeline(sessionID string, idleGap time.Duration, loc *time.Location) (*SessionTimeline, error) {
+	rows, err := s.db.Query(`
+		SELECT timestamp, COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), COALESCE(message_role, '') = 'assistant'
+		FROM messages
+		WHERE session_id = ?
+		ORDER BY timestamp
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session messages: %w", err)
+	}
+	var messages []timelineMessage
+	for rows.Next() {
+		var m timelineMessage
+		if err := rows.Scan(&m.at, &m.input, &m.output, &m.assistant); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan session message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read session messages: %w", err)
+	}
+
+	calls, err := s.sessionToolCallTimes(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return buildSessionTimeline(messages, calls, idleGap, loc), nil
+}
+
+// sessionToolCallTimes returns when each of a session's tools was called, in order
+func (s *SessionService) sessionToolCallTimes(sessionID string) ([]time.Time, error) {
+	rows, err := s.db.Query(`SELECT called_at FROM tool_calls WHERE session_id = ? ORDER BY called_at`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []time.Time
+	for rows.Next() {
+		var at sql.NullTime
+		if err := rows.Scan(&at); err != nil {
+			return nil, fmt.Errorf("failed to scan tool call: %w", err)
+		}
+		if at.Valid {
+			calls = append(calls, at.Time)
+		}
+	}
+	return calls, rows.Err()
+}
+
+func buildSessionTimeline(messages []timelineMessage, calls []time.Time, idleGap time.Duration, loc *time.Location) *SessionTimeline {
+	timeline := &SessionTimeline{
+		IdleGapMinutes: idleGap.Minutes(),
+		Bursts:         []ActivityBurst{},
+		Gaps:           []IdleGap{},
+		Hours:          []HourlyActivity{},
+	}
+	if len(messages) == 0 {
+		return timeline
+	}
+	start, end := messages[0].at, messages[len(messages)-1].at
+	timeline.Start, timeline.End = &start, &end
+
+	hours := make(map[time.Time]*HourlyActivity)
+	hourOf := func(t time.Time) *HourlyActivity {
+		local := t.In(loc)
+		hour := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, loc)
+		h, ok := hours[hour]
+		if !ok {
+			h = &HourlyActivity{Hour: hour}
+			hours[hour] = h
+		}
+		return h
+	}
+
+	burst := &ActivityBurst{Start: start, End: start}
+	closeBurst := func() {
+		timeline.Bursts = append(timeline.Bursts, *burst)
+	}
+	for i, m := range messages {
+		if i > 0 && m.at.Sub(burst.End) > idleGap {
+			closeBurst()
+			timeline.Gaps = append(timeline.Gaps, IdleGap{
+				Start:           burst.End,
+				End:             m.at,
+				DurationMinutes: roundToDecimals(m.at.Sub(burst.End).Minutes(), 2),
+			})
+			burst = &ActivityBurst{Start: m.at}
+		}
+		burst.End = m.at
+		burst.MessageCount++
+		h := hourOf(m.at)
+		h.MessageCount++
+		if m.assistant {
+			burst.TotalTokens += m.input + m.output
+			h.InputTokens += m.input
+			h.OutputTokens += m.output
+			h.TotalTokens += m.input + m.output
+		}
+	}
+	closeBurst()
+
+	// Each call belongs to the last burst starting at or before it
+	for _, at := range calls {
+		hourOf(at).ToolCalls++
+		i := sort.Search(len(timeline.Bursts), func(i int) bool { return timeline.Bursts[i].Start.After(at) }) - 1
+		if i >= 0 {
+			timeline.Bursts[i].ToolCalls++
+		}
+	}
+
+	var activeMinutes float64
+	var toolCalls int
+	for i := range timeline.Bursts {
+		b := &timeline.Bursts[i]
+		minutes := b.End.Sub(b.Start).Minutes()
+		b.DurationMinutes = roundToDecimals(minutes, 2)
+		activeMinutes += minutes
+		toolCalls += b.ToolCalls
+		b.ToolCallsPerMinute = roundToDecimals(float64(b.ToolCalls)/max(minutes, 1), 2)
+	}
+	for _, g := range timeline.Gaps {
+		timeline.IdleMinutes += g.DurationMinutes
+	}
+	timeline.ActiveMinutes = roundToDecimals(activeMinutes, 2)
+	timeline.IdleMinutes = roundToDecimals(timeline.IdleMinutes, 2)
+	timeline.ToolCallsPerActiveMinute = roundToDecimals(float64(toolCalls)/max(activeMinutes, 1), 2)
+
+	for _, h := range hours {
+		timeline.Hours = append(timeline.Hours, *h)
+	}
+	sort.Slice(timeline.Hours, func(i, j int) bool { return timeline.Hours[i].Hour.Before(timeline.Hours[j].Hour) })
+	return timeline
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildSessionTimeline(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	messages := []timelineMessage{
+		{at: at(9, 0)},
+		{at: at(9, 2), input: 100, output: 50, assistant: true},
+		{at: at(9, 10), input: 10, output: 5, assistant: true},
+		// 40 minutes idle
+		{at: at(9, 50)},
+		{at: at(10, 5), input: 200, output: 100, assistant: true},
+	}
+	calls := []time.Time{at(9, 2), at(9, 3), at(9, 10), at(10, 5)}
+
+	timeline := buildSessionTimeline(messages, calls, 10*time.Minute, time.UTC)
+	if len(timeline.Bursts) != 3 || len(timeline.Gaps) != 2 {
+		t.Fatalf("Expected 3 bursts and 2 gaps, got %+v", timeline)
+	}
+	first := timeline.Bursts[0]
+	if first.MessageCount != 3 || first.ToolCalls != 3 || first.TotalTokens != 165 || first.DurationMinutes != 10 || first.ToolCallsPerMinute != 0.3 {
+		t.Errorf("Unexpected first burst %+v", first)
+	}
+	if timeline.Gaps[0].DurationMinutes != 40 || timeline.ActiveMinutes != 10 || timeline.IdleMinutes != 55 {
+		t.Errorf("Expected 40 then 15 idle minutes, got %+v", timeline)
+	}
+	if len(timeline.Hours) != 2 || timeline.Hours[0].TotalTokens != 165 || timeline.Hours[0].MessageCount != 4 ||
+		timeline.Hours[1].TotalTokens != 300 || timeline.Hours[1].ToolCalls != 1 {
+		t.Errorf("Unexpected hours %+v", timeline.Hours)
+	}
+
+	// Hours follow the zone, here half an hour off UTC
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+	if hours := buildSessionTimeline(messages, nil, 10*time.Minute, kolkata).Hours; len(hours) != 2 || hours[0].Hour.Minute() != 0 || hours[0].MessageCount != 3 {
+		t.Errorf("Expected hours of India, got %+v", hours)
+	}
+
+	if empty := buildSessionTimeline(nil, nil, DefaultIdleGap, time.UTC); empty.Start != nil || len(empty.Bursts) != 0 {
+		t.Errorf("Expected an empty timeline, got %+v", empty)
+	}
+}
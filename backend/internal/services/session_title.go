@@ -0,0 +1,153 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxSessionTitleLength bounds a session title in characters
+const MaxSessionTitleLength = 80
+
+// sessionTitleCandidates is how many of a session's first user messages are
+// looked at for a prompt; the others are tool results and command output
+const sessionTitleCandidates = 20
+
+var (
+	commandNamePattern = regexp.MustCompile(`<command-name>([^<]*)</command-name>`)
+	commandArgsPattern = regexp.MustCompile(`<command-args>([^<]*)</command-args>`)
+	markupPattern      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// SessionTitle turns the stored content of a user message into a one-line
+// title, or returns "" when the message is not something the user typed,
+// such as tool results, command output or an interruption notice. Slash
+// commands are titled by the command and its arguments.
+func SessionTitle(content string) string {
+	text := strings.TrimSpace(content)
+	if strings.HasPrefix(text, "[") {
+		// Content blocks; tool results have no text blocks. Anything else in
+		// brackets is a notice like "[Request interrupted by user]".
+		var blocks []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if json.Unmarshal([]byte(text), &blocks) != nil {
+			return ""
+		}
+		var parts []string
+		for _, block := range blocks {
+			if block.Type == "text" {
+				parts = append(parts, block.Text)
+			}
+		}
+		text = strings.TrimSpace(strings.Join(parts, "\n"))
+	}
+
+	if name := commandNamePattern.FindStringSubmatch(text); name != nil {
+		text = name[1]
+		if args := commandArgsPattern.FindStringSubmatch(content); args != nil {
+			text += " " + args[1]
+		}
+	} else if strings.HasPrefix(text, "Caveat:") || strings.HasPrefix(text, "<local-command") {
+		return ""
+	}
+	text = strings.Join(strings.Fields(markupPattern.ReplaceAllString(text, " ")), " ")
+
+	runes := []rune(text)
+	if len(runes) <= MaxSessionTitleLength {
+		return text
+	}
+	// Cut after the last word that fits
+	cut := string(runes[:MaxSessionTitleLength-1])
+	if runes[MaxSessionTitleLength-1] != ' ' {
+		if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
+
+// UpdateSessionTitle titles an untitled session after its first prompt.
+// Sessions whose prompts are not stored, as under the metadata content
+// policy, stay untitled.
+func (s *SessionService) UpdateSessionTitle(sessionID string) error {
+	var title sql.NullString
+	if err := s.db.QueryRow(`SELECT title FROM sessions WHERE id = ?`, sessionID).Scan(&title); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to get session title: %w", err)
+	}
+	if title.Valid {
+		return nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT content FROM messages
+		WHERE session_id = ? AND message_role = 'user' AND NOT is_sidechain AND content IS NOT NULL
+		ORDER BY timestamp, id
+		LIMIT ?
+	`, sessionID, sessionTitleCandidates)
+	if err != nil {
+		return fmt.Errorf("failed to get session prompts: %w", err)
+	}
+	var derived string
+	for rows.Next() && derived == "" {
+		var content sql.NullString
+		if err := rows.Scan(&content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan session prompt: %w", err)
+		}
+		if content.Valid {
+			derived = SessionTitle(*s.cipher.Open(&content.String))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read session prompts: %w", err)
+	}
+	if derived == "" {
+		return nil
+	}
+
+	if _, err := s.db.Exec(`UPDATE sessions SET title = ? WHERE id = ? AND title IS NULL`, *s.cipher.Seal(&derived), sessionID); err != nil {
+		return fmt.Errorf("failed to set session title: %w", err)
+	}
+	return nil
+}
+
+// BackfillSessionTitles titles the sessions synced before titles existed and
+// returns how many were titled
+func (s *SessionService) BackfillSessionTitles() (int, error) {
+	rows, err := s.db.Query(`SELECT id FROM sessions WHERE title IS NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find untitled sessions: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan session: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find untitled sessions: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := s.UpdateSessionTitle(id); err != nil {
+			return 0, err
+		}
+	}
+	var remaining int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE title IS NULL`).Scan(&remaining); err != nil {
+		return 0, fmt.Errorf("failed to count untitled sessions: %w", err)
+	}
+	return len(ids) - remaining, nil
+}
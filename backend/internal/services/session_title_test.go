@@ -0,0 +1,104 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestSessionTitle(t *testing.T) {
+	long := strings.Repeat("refactor the parser ", 10)
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain prompt", "  Fix the\n\nflaky   tests  ", "Fix the flaky tests"},
+		{"text blocks", `[{"type":"text","text":"Why does\nsync hang?"},{"type":"image","source":{}}]`, "Why does sync hang?"},
+		{"tool results", `[{"type":"tool_result","tool_use_id":"toolu_1","content":"ok"}]`, ""},
+		{"interruption", "[Request interrupted by user]", ""},
+		{"caveat", "Caveat: The messages below were generated by the user while running local commands.", ""},
+		{"command output", "<local-command-stdout>done</local-command-stdout>", ""},
+		{"slash command", "<command-message>review is running…</command-message>\n<command-name>/review</command-name>\n<command-args>PR 42</command-args>", "/review PR 42"},
+		{"encrypted without key", EncryptedContentPlaceholder, ""},
+		{"long prompt", long, "refactor the parser refactor the parser refactor the parser refactor the parser…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SessionTitle(tt.content); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+	if got := SessionTitle(strings.Repeat("é", 200)); len([]rune(got)) != MaxSessionTitleLength {
+		t.Errorf("Expected %d characters, got %d", MaxSessionTitleLength, len([]rune(got)))
+	}
+}
+
+func TestSessionsAreTitledOnSync(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	sessionService := NewSessionService(db)
+	diffSyncService := NewDiffSyncService(db, NewTokenService(db), sessionService)
+
+	lines := []string{
+		`{"uuid":"c1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Caveat: The messages below were generated by the user while running local commands."}}`,
+		`{"uuid":"u1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:01Z","message":{"role":"user","content":"Add a dark mode toggle"}}`,
+		`{"uuid":"u2","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:05:00Z","message":{"role":"user","content":"Now make it the default"}}`,
+		`{"uuid":"a1","sessionId":"s2","cwd":"/work/app","timestamp":"2024-01-01T11:00:00Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":5},"content":[{"type":"text","text":"Hi"}]}}`,
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "-work-app"), 0755); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+	path := filepath.Join(root, "-work-app", "s1.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+	if _, _, err := diffSyncService.processFileFrom(path, readPosition{}); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+
+	s1, err := sessionService.GetSessionByID("s1")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if s1.Title == nil || *s1.Title != "Add a dark mode toggle" {
+		t.Errorf("Expected the first prompt as title, got %v", s1.Title)
+	}
+	s2, err := sessionService.GetSessionByID("s2")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if s2.Title != nil {
+		t.Errorf("Expected a session without prompts to stay untitled, got %q", *s2.Title)
+	}
+
+	// Sessions synced before titles existed are titled by the backfill
+	if _, err := db.Exec(`UPDATE sessions SET title = NULL`); err != nil {
+		t.Fatalf("Failed to clear titles: %v", err)
+	}
+	if n, err := sessionService.BackfillSessionTitles(); err != nil || n != 1 {
+		t.Errorf("Expected one session titled, got %d (%v)", n, err)
+	}
+
+	// The metadata policy removes titles with the content they came from
+	if _, err := StripContent(db, ContentPolicy{Mode: ContentPolicyMetadata}, nil); err != nil {
+		t.Fatalf("Failed to strip content: %v", err)
+	}
+	var titled int
+	db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE title IS NOT NULL`).Scan(&titled)
+	if titled != 0 {
+		t.Errorf("Expected titles to be stripped, got %d", titled)
+	}
+}
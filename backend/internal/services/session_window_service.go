@@ -9,7 +9,8 @@ import (
 )
 
 type SessionWindowService struct {
-	db *sql.DB
+	db    *sql.DB
+	cache *windowCache
 }
 
 type SessionWindow struct {
@@ -71,8 +72,13 @@ func (s *SessionWindowService) GetCurrentActiveWindow() (*SessionWindow, error)
 	return &window, nil
 }
 
-// findWindowForTime finds an existing window that contains the given time
-func (s *SessionWindowService) findWindowForTime(messageTime time.Time) (*SessionWindow, error) {
+// findWindowForTime finds an existing window that contains the given time,
+// looking in q so that windows created by an open transaction are found
+func (s *SessionWindowService) findWindowForTime(q sqlExecutor, messageTime time.Time) (*SessionWindow, error) {
+	if s.cache != nil {
+		return s.cachedWindowForTime(messageTime)
+	}
+
 	query := `
 		SELECT 
 			id, window_start, window_end, reset_time,
@@ -86,7 +92,7 @@ func (s *SessionWindowService) findWindowForTime(messageTime time.Time) (*Sessio
 	`
 	
 	var window SessionWindow
-	err := s.db.QueryRow(query, messageTime, messageTime).Scan(
+	err := q.QueryRow(query, messageTime, messageTime).Scan(
 		&window.ID,
 		&window.WindowStart,
 		&window.WindowEnd,
@@ -113,6 +119,8 @@ func (s *SessionWindowService) findWindowForTime(messageTime time.Time) (*Sessio
 
 // RecalculateAllWindows recreates all session windows based on the specification
 func (s *SessionWindowService) RecalculateAllWindows() error {
+	s.InvalidateCache()
+	
 	// 1. 既存のSessionWindowを全てクリア
 	_, err := s.db.Exec("DELETE FROM session_windows")
 	if err != nil {
@@ -131,9 +139,8 @@ func (s *SessionWindowService) RecalculateAllWindows() error {
 			break
 		}
 		
-		// 3. そのメッセージの時刻から5時間のSessionWindowを作成（分単位切り捨て）
-		windowStart := s.truncateToMinute(oldestMessage.Timestamp)
-		windowEnd := windowStart.Add(WINDOW_DURATION)
+		// 3. そのメッセージの時刻からウィンドウポリシーに従ってSessionWindowを作成
+		windowStart, windowEnd := currentWindowPolicy().bounds(oldestMessage.Timestamp)
 		
 		window := &SessionWindow{
 			ID:          uuid.New().String(),
@@ -144,7 +151,7 @@ func (s *SessionWindowService) RecalculateAllWindows() error {
 		}
 		
 		// 4. SessionWindowをデータベースに挿入
-		err = s.insertWindow(window)
+		err = s.insertWindow(s.db, window)
 		if err != nil {
 			return fmt.Errorf("failed to insert window: %w", err)
 		}
@@ -162,7 +169,8 @@ func (s *SessionWindowService) RecalculateAllWindows() error {
 		}
 	}
 	
-	return nil
+	// 7. 記録されたリミットのリセット時刻でreset_timeを補正
+	return calibrateWindowResets(s.db)
 }
 
 // getOldestUnassignedMessage gets the oldest message not assigned to any session window
@@ -170,7 +178,7 @@ func (s *SessionWindowService) getOldestUnassignedMessage() (*Message, error) {
 	query := `
 		SELECT id, session_id, timestamp
 		FROM messages 
-		WHERE session_window_id IS NULL
+		WHERE session_window_id IS NULL AND COALESCE(provider, 'claude') = 'claude'
 		ORDER BY timestamp ASC
 		LIMIT 1
 	`
@@ -192,41 +200,36 @@ func (s *SessionWindowService) getOldestUnassignedMessage() (*Message, error) {
 	return &message, nil
 }
 
-// truncateToMinute truncates time to minute precision (removes seconds and nanoseconds)
-func (s *SessionWindowService) truncateToMinute(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
-}
-
-// truncateToHour truncates time to hour precision (removes minutes, seconds and nanoseconds)
-func (s *SessionWindowService) truncateToHour(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
-}
-
-// insertWindow inserts a session window into the database
-func (s *SessionWindowService) insertWindow(window *SessionWindow) error {
+// insertWindow inserts a session window with q. A caller whose transaction
+// rolls back must invalidate the cache.
+func (s *SessionWindowService) insertWindow(q sqlExecutor, window *SessionWindow) error {
 	query := `
 		INSERT INTO session_windows (
 			id, window_start, window_end, reset_time, is_active
 		) VALUES (?, ?, ?, ?, ?)
 	`
 	
-	_, err := s.db.Exec(query,
+	_, err := q.Exec(query,
 		window.ID,
 		window.WindowStart,
 		window.WindowEnd,
 		window.ResetTime,
 		window.IsActive,
 	)
+	if err == nil && s.cache != nil {
+		s.cache.add(window)
+	}
 	
 	return err
 }
 
-// assignMessagesToWindow assigns all messages in the time range to the given window
+// assignMessagesToWindow assigns all Claude messages in the time range to the given window
 func (s *SessionWindowService) assignMessagesToWindow(windowID string, windowStart, windowEnd time.Time) error {
 	query := `
 		UPDATE messages 
 		SET session_window_id = ? 
 		WHERE timestamp >= ? AND timestamp < ? AND session_window_id IS NULL
+			AND COALESCE(provider, 'claude') = 'claude'
 	`
 	
 	_, err := s.db.Exec(query, windowID, windowStart, windowEnd)
@@ -246,8 +249,13 @@ type Message struct {
 
 // GetOrCreateWindowForMessage gets the appropriate window for a message, creating if necessary
 func (s *SessionWindowService) GetOrCreateWindowForMessage(messageTime time.Time) (*SessionWindow, error) {
+	return s.getOrCreateWindowForMessage(s.db, messageTime)
+}
+
+// getOrCreateWindowForMessage is GetOrCreateWindowForMessage within q
+func (s *SessionWindowService) getOrCreateWindowForMessage(q sqlExecutor, messageTime time.Time) (*SessionWindow, error) {
 	// このメッセージの時間に適合する既存のウィンドウがあるかチェック
-	existingWindow, err := s.findWindowForTime(messageTime)
+	existingWindow, err := s.findWindowForTime(q, messageTime)
 	if err != nil {
 		return nil, err
 	}
@@ -257,13 +265,11 @@ func (s *SessionWindowService) GetOrCreateWindowForMessage(messageTime time.Time
 	}
 	
 	// 適合するウィンドウがない場合、このメッセージ時間を基準にウィンドウを作成
-	windowStart := s.truncateToMinute(messageTime)
-	tempWindowEnd := windowStart.Add(WINDOW_DURATION)
-	// WindowEndも分単位を切り捨てて時間単位にする（例：10:20 -> 10:00）
-	windowEnd := s.truncateToHour(tempWindowEnd)
+	// 既定ではWindowEndを時間単位に切り捨てる（例：10:20 -> 10:00）
+	windowStart, windowEnd := currentWindowPolicy().bounds(messageTime)
 	
 	// 同じ時間範囲のウィンドウが既に存在するかチェック（競合状態回避）
-	existingWindow, err = s.findWindowForTime(windowStart)
+	existingWindow, err = s.findWindowForTime(q, windowStart)
 	if err != nil {
 		return nil, err
 	}
@@ -283,7 +289,7 @@ func (s *SessionWindowService) GetOrCreateWindowForMessage(messageTime time.Time
 		IsActive:    true,
 	}
 	
-	err = s.insertWindow(window)
+	err = s.insertWindow(q, window)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert window: %w", err)
 	}
@@ -304,35 +310,36 @@ func (s *SessionWindowService) UpdateWindowStats(windowID string) error {
 		return fmt.Errorf("failed to get window time range: %w", err)
 	}
 	
-	// Calculate stats directly from messages table using time range (more reliable)
+	// Calculate stats directly from messages table using time range (more reliable);
+	// other tools' messages do not count against Claude's limit
 	query := `
 		UPDATE session_windows 
 		SET 
 			total_input_tokens = (
 				SELECT COALESCE(SUM(input_tokens), 0) 
 				FROM messages 
-				WHERE timestamp >= ? AND timestamp < ?
+				WHERE timestamp >= ? AND timestamp < ? AND COALESCE(provider, 'claude') = 'claude'
 			),
 			total_output_tokens = (
 				SELECT COALESCE(SUM(output_tokens), 0) 
 				FROM messages 
-				WHERE timestamp >= ? AND timestamp < ?
+				WHERE timestamp >= ? AND timestamp < ? AND COALESCE(provider, 'claude') = 'claude'
 			),
 			total_tokens = (
 				SELECT COALESCE(SUM(input_tokens + output_tokens), 0) 
 				FROM messages 
-				WHERE timestamp >= ? AND timestamp < ?
+				WHERE timestamp >= ? AND timestamp < ? AND COALESCE(provider, 'claude') = 'claude'
 			),
 			message_count = (
 				SELECT COUNT(*) 
 				FROM messages 
-				WHERE timestamp >= ? AND timestamp < ? 
+				WHERE timestamp >= ? AND timestamp < ? AND COALESCE(provider, 'claude') = 'claude'
 				AND message_role = 'assistant'
 			),
 			session_count = (
 				SELECT COUNT(DISTINCT session_id) 
 				FROM messages 
-				WHERE timestamp >= ? AND timestamp < ?
+				WHERE timestamp >= ? AND timestamp < ? AND COALESCE(provider, 'claude') = 'claude'
 			),
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/config"
+	"claudeee-backend/internal/logging"
+)
+
+// Plan names accepted by the runtime configuration
+const (
+	PlanPro   = "pro"
+	PlanMax5  = "max5"
+	PlanMax20 = "max20"
+	// PlanCustom uses the token limit from the plan_token_limit setting
+	PlanCustom = "custom"
+)
+
+// ErrInvalidSettings wraps validation failures for runtime settings
+var ErrInvalidSettings = errors.New("invalid settings")
+
+// RuntimeSettings are the settings that can be changed while the server runs
+type RuntimeSettings struct {
+	Plan                string  `json:"plan"`
+	PlanTokenLimit      int     `json:"plan_token_limit"`
+	Timezone            string  `json:"timezone"`
+	WarningThreshold    float64 `json:"warning_threshold"`
+	CriticalThreshold   float64 `json:"critical_threshold"`
+	SyncIntervalMinutes int     `json:"sync_interval_minutes"`
+	ContentPolicy       string  `json:"content_policy"`
+	ContentMaxKB        int     `json:"content_max_kb"`
+	// ContentCompression stores message content compressed with zstd
+	ContentCompression bool `json:"content_compression"`
+	// ContentRetentionDays is how long message content is kept; 0 keeps it
+	// forever
+	ContentRetentionDays int  `json:"content_retention_days"`
+	RedactSecrets        bool `json:"redact_secrets"`
+	// PrivacyMode forbids storing message content. It is set at startup and
+	// cannot be changed through Update.
+	PrivacyMode bool `json:"privacy_mode"`
+}
+
+// SettingsUpdate is a partial update; nil fields are left unchanged
+type SettingsUpdate struct {
+	Plan                 *string  `json:"plan"`
+	PlanTokenLimit       *int     `json:"plan_token_limit"`
+	Timezone             *string  `json:"timezone"`
+	WarningThreshold     *float64 `json:"warning_threshold"`
+	CriticalThreshold    *float64 `json:"critical_threshold"`
+	SyncIntervalMinutes  *int     `json:"sync_interval_minutes"`
+	ContentPolicy        *string  `json:"content_policy"`
+	ContentMaxKB         *int     `json:"content_max_kb"`
+	ContentCompression   *bool    `json:"content_compression"`
+	ContentRetentionDays *int     `json:"content_retention_days"`
+	RedactSecrets        *bool    `json:"redact_secrets"`
+}
+
+// DefaultRuntimeSettings returns the built-in defaults
+func DefaultRuntimeSettings() RuntimeSettings {
+	return RuntimeSettings{
+		Plan:                PlanPro,
+		Timezone:            "UTC",
+		WarningThreshold:    0.8,
+		CriticalThreshold:   1.0,
+		SyncIntervalMinutes: 5,
+		ContentPolicy:       DefaultContentPolicy().Mode,
+		ContentMaxKB:        DefaultContentPolicy().MaxKB,
+		RedactSecrets:       true,
+	}
+}
+
+// UsageLimit returns the per-window token limit of the configured plan
+func (r RuntimeSettings) UsageLimit() int {
+	if r.Plan == PlanCustom {
+		return r.PlanTokenLimit
+	}
+	limit, _ := PlanUsageLimit(r.Plan)
+	return limit
+}
+
+// ContentStoragePolicy returns the content policy described by the settings
+func (r RuntimeSettings) ContentStoragePolicy() ContentPolicy {
+	return ContentPolicy{Mode: r.ContentPolicy, MaxKB: r.ContentMaxKB, Compress: r.ContentCompression}
+}
+
+// SettingsService stores runtime settings in the database and notifies
+// subscribers when they change so new values apply without a restart.
+// Only the fields an update set are stored; every other setting follows the
+// defaults, so later changes to the configuration still take effect.
+type SettingsService struct {
+	db     *sql.DB
+	writes *WriteQueue
+
+	mu          sync.RWMutex
+	defaults    RuntimeSettings
+	overrides   SettingsUpdate
+	current     RuntimeSettings
+	subscribers []func(RuntimeSettings)
+}
+
+// NewSettingsService creates a service starting from the given defaults
+func NewSettingsService(db *sql.DB, defaults RuntimeSettings) *SettingsService {
+	return &SettingsService{
+		db:       db,
+		defaults: defaults,
+		current:  defaults,
+	}
+}
+
+// SetWriteQueue routes settings writes through the shared writer
+func (s *SettingsService) SetWriteQueue(writes *WriteQueue) {
+	s.writes = writes
+}
+
+// Load reads the stored values
+func (s *SettingsService) Load() error {
+	overrides, err := s.storedOverrides()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loaded := s.resolve(overrides, SettingsUpdate{})
+	if err := loaded.Validate(); err != nil {
+		logging.Component("settings").Warn("Ignoring stored settings", "err", err)
+		return nil
+	}
+	s.overrides = overrides
+	s.current = loaded
+	return nil
+}
+
+// storedOverrides reads the settings set through Update. Servers before
+// overrides stored every setting under 'runtime'; of those, only values that
+// differ from the defaults are kept.
+func (s *SettingsService) storedOverrides() (SettingsUpdate, error) {
+	var overrides SettingsUpdate
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE key = 'runtime_overrides'`).Scan(&value)
+	if err == nil {
+		if err := json.Unmarshal([]byte(value), &overrides); err != nil {
+			return overrides, fmt.Errorf("failed to parse stored settings: %w", err)
+		}
+		return overrides, nil
+	}
+	if err != sql.ErrNoRows {
+		return overrides, fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	err = s.db.QueryRow(`SELECT value FROM settings WHERE key = 'runtime'`).Scan(&value)
+	if err == sql.ErrNoRows {
+		return overrides, nil
+	}
+	if err != nil {
+		return overrides, fmt.Errorf("failed to load settings: %w", err)
+	}
+	// Decode over the defaults so settings added later keep their default value
+	legacy := s.defaults
+	if err := json.Unmarshal([]byte(value), &legacy); err != nil {
+		return overrides, fmt.Errorf("failed to parse stored settings: %w", err)
+	}
+	return overridesFrom(legacy, s.defaults), nil
+}
+
+// resolve applies the stored overrides and then update to the defaults
+func (s *SettingsService) resolve(overrides, update SettingsUpdate) RuntimeSettings {
+	settings := update.apply(overrides.apply(s.defaults))
+	// Privacy mode comes from the startup configuration, never from storage,
+	// so a content policy stored before it was turned on does not apply
+	if settings.PrivacyMode && update.ContentPolicy == nil {
+		settings.ContentPolicy = ContentPolicyMetadata
+	}
+	return settings
+}
+
+// Get returns the current settings
+func (s *SettingsService) Get() RuntimeSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Subscribe registers fn to be called with the new settings after every
+// update. fn is also called immediately with the current settings.
+func (s *SettingsService) Subscribe(fn func(RuntimeSettings)) {
+	s.mu.Lock()
+	s.subscribers = append(s.subscribers, fn)
+	current := s.current
+	s.mu.Unlock()
+
+	fn(current)
+}
+
+// Update validates and applies a partial update, persists it, and notifies subscribers
+func (s *SettingsService) Update(update SettingsUpdate) (RuntimeSettings, error) {
+	s.mu.Lock()
+	next := s.resolve(s.overrides, update)
+	if err := next.Validate(); err != nil {
+		s.mu.Unlock()
+		return s.Get(), err
+	}
+
+	overrides := s.overrides.merge(update)
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		s.mu.Unlock()
+		return s.Get(), fmt.Errorf("failed to encode settings: %w", err)
+	}
+	err = s.writes.Do(func() error {
+		_, err := s.db.Exec(`
+			INSERT OR REPLACE INTO settings (key, value, updated_at)
+			VALUES ('runtime_overrides', ?, ?)
+		`, string(data), time.Now())
+		if err != nil {
+			return err
+		}
+		_, err = s.db.Exec(`DELETE FROM settings WHERE key = 'runtime'`)
+		return err
+	})
+	if err != nil {
+		s.mu.Unlock()
+		return s.Get(), fmt.Errorf("failed to save settings: %w", err)
+	}
+
+	s.overrides = overrides
+	s.current = next
+	subscribers := append([]func(RuntimeSettings){}, s.subscribers...)
+	s.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(next)
+	}
+	return next, nil
+}
+
+// apply returns settings with the fields u sets replaced
+func (u SettingsUpdate) apply(settings RuntimeSettings) RuntimeSettings {
+	if u.Plan != nil {
+		settings.Plan = *u.Plan
+	}
+	if u.PlanTokenLimit != nil {
+		settings.PlanTokenLimit = *u.PlanTokenLimit
+	}
+	if u.Timezone != nil {
+		settings.Timezone = *u.Timezone
+	}
+	if u.WarningThreshold != nil {
+		settings.WarningThreshold = *u.WarningThreshold
+	}
+	if u.CriticalThreshold != nil {
+		settings.CriticalThreshold = *u.CriticalThreshold
+	}
+	if u.SyncIntervalMinutes != nil {
+		settings.SyncIntervalMinutes = *u.SyncIntervalMinutes
+	}
+	if u.ContentPolicy != nil {
+		settings.ContentPolicy = config.ContentPolicyMode(*u.ContentPolicy)
+	}
+	if u.ContentMaxKB != nil {
+		settings.ContentMaxKB = *u.ContentMaxKB
+	}
+	if u.ContentCompression != nil {
+		settings.ContentCompression = *u.ContentCompression
+	}
+	if u.ContentRetentionDays != nil {
+		settings.ContentRetentionDays = *u.ContentRetentionDays
+	}
+	if u.RedactSecrets != nil {
+		settings.RedactSecrets = *u.RedactSecrets
+	}
+	return settings
+}
+
+// merge returns u with the fields update sets replaced
+func (u SettingsUpdate) merge(update SettingsUpdate) SettingsUpdate {
+	return SettingsUpdate{
+		Plan:                 override(update.Plan, u.Plan),
+		PlanTokenLimit:       override(update.PlanTokenLimit, u.PlanTokenLimit),
+		Timezone:             override(update.Timezone, u.Timezone),
+		WarningThreshold:     override(update.WarningThreshold, u.WarningThreshold),
+		CriticalThreshold:    override(update.CriticalThreshold, u.CriticalThreshold),
+		SyncIntervalMinutes:  override(update.SyncIntervalMinutes, u.SyncIntervalMinutes),
+		ContentPolicy:        override(update.ContentPolicy, u.ContentPolicy),
+		ContentMaxKB:         override(update.ContentMaxKB, u.ContentMaxKB),
+		ContentCompression:   override(update.ContentCompression, u.ContentCompression),
+		ContentRetentionDays: override(update.ContentRetentionDays, u.ContentRetentionDays),
+		RedactSecrets:        override(update.RedactSecrets, u.RedactSecrets),
+	}
+}
+
+// overridesFrom returns the fields of settings that differ from defaults
+func overridesFrom(settings, defaults RuntimeSettings) SettingsUpdate {
+	return SettingsUpdate{
+		Plan:                 differs(settings.Plan, defaults.Plan),
+		PlanTokenLimit:       differs(settings.PlanTokenLimit, defaults.PlanTokenLimit),
+		Timezone:             differs(settings.Timezone, defaults.Timezone),
+		WarningThreshold:     differs(settings.WarningThreshold, defaults.WarningThreshold),
+		CriticalThreshold:    differs(settings.CriticalThreshold, defaults.CriticalThreshold),
+		SyncIntervalMinutes:  differs(settings.SyncIntervalMinutes, defaults.SyncIntervalMinutes),
+		ContentPolicy:        differs(settings.ContentPolicy, defaults.ContentPolicy),
+		ContentMaxKB:         differs(settings.ContentMaxKB, defaults.ContentMaxKB),
+		ContentCompression:   differs(settings.ContentCompression, defaults.ContentCompression),
+		ContentRetentionDays: differs(settings.ContentRetentionDays, defaults.ContentRetentionDays),
+		RedactSecrets:        differs(settings.RedactSecrets, defaults.RedactSecrets),
+	}
+}
+
+func override[T any](value, fallback *T) *T {
+	if value != nil {
+		return value
+	}
+	return fallback
+}
+
+func differs[T comparable](value, base T) *T {
+	if value == base {
+		return nil
+	}
+	return &value
+}
+
+// Validate checks that every setting is within its allowed range
+func (r RuntimeSettings) Validate() error {
+	if r.PlanTokenLimit < 0 {
+		return fmt.Errorf("%w: plan_token_limit must not be negative", ErrInvalidSettings)
+	}
+	if r.Plan == PlanCustom {
+		if r.PlanTokenLimit == 0 {
+			return fmt.Errorf("%w: the custom plan requires plan_token_limit", ErrInvalidSettings)
+		}
+	} else if _, ok := PlanUsageLimit(r.Plan); !ok {
+		return fmt.Errorf("%w: unknown plan %q", ErrInvalidSettings, r.Plan)
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil || r.Timezone == "" {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSettings, r.Timezone)
+	}
+	if r.WarningThreshold <= 0 || r.WarningThreshold > 1 {
+		return fmt.Errorf("%w: warning_threshold must be between 0 and 1", ErrInvalidSettings)
+	}
+	if r.CriticalThreshold < r.WarningThreshold || r.CriticalThreshold > 2 {
+		return fmt.Errorf("%w: critical_threshold must be between warning_threshold and 2", ErrInvalidSettings)
+	}
+	if r.SyncIntervalMinutes < 0 || r.SyncIntervalMinutes > 24*60 {
+		return fmt.Errorf("%w: sync_interval_minutes must be between 0 and 1440", ErrInvalidSettings)
+	}
+	if r.ContentRetentionDays < 0 || r.ContentRetentionDays > MaxRetentionDays {
+		return fmt.Errorf("%w: content_retention_days must be between 0 and %d", ErrInvalidSettings, MaxRetentionDays)
+	}
+	if err := r.ContentStoragePolicy().Validate(); err != nil {
+		return err
+	}
+	if r.PrivacyMode && r.ContentPolicy != ContentPolicyMetadata {
+		return fmt.Errorf("%w: content_policy is fixed to metadata in privacy mode", ErrInvalidSettings)
+	}
+	return nil
+}
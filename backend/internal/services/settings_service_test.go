@@ -0,0 +1,202 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"claudeee-backend/internal/database"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func setupSettingsService(t *testing.T) (*sql.DB, *SettingsService) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	service := NewSettingsService(db, DefaultRuntimeSettings())
+	if err := service.Load(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+	return db, service
+}
+
+func TestSettingsService_UpdateAppliesAndPersists(t *testing.T) {
+	db, service := setupSettingsService(t)
+	defer db.Close()
+
+	tokenService := NewTokenService(db)
+	service.Subscribe(func(settings RuntimeSettings) {
+		tokenService.SetPlan(settings.Plan)
+	})
+
+	plan := PlanMax5
+	interval := 15
+	updated, err := service.Update(SettingsUpdate{Plan: &plan, SyncIntervalMinutes: &interval})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Plan != PlanMax5 || updated.SyncIntervalMinutes != 15 {
+		t.Errorf("Unexpected settings after update: %+v", updated)
+	}
+	if updated.Timezone != "UTC" {
+		t.Errorf("Expected untouched timezone to keep its value, got %s", updated.Timezone)
+	}
+	if tokenService.getUsageLimit() != CLAUDE_MAX5_LIMIT {
+		t.Errorf("Expected subscriber to apply plan limit %d, got %d", CLAUDE_MAX5_LIMIT, tokenService.getUsageLimit())
+	}
+
+	reloaded := NewSettingsService(db, DefaultRuntimeSettings())
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to reload settings: %v", err)
+	}
+	if reloaded.Get().Plan != PlanMax5 {
+		t.Errorf("Expected persisted plan %s, got %s", PlanMax5, reloaded.Get().Plan)
+	}
+}
+
+func TestSettingsService_RejectsInvalidValues(t *testing.T) {
+	db, service := setupSettingsService(t)
+	defer db.Close()
+
+	badPlan := "enterprise"
+	customPlan := PlanCustom
+	badTimezone := "Mars/Olympus"
+	badThreshold := 1.5
+	negativeInterval := -1
+
+	testCases := []struct {
+		name   string
+		update SettingsUpdate
+	}{
+		{"plan", SettingsUpdate{Plan: &badPlan}},
+		{"custom plan without limit", SettingsUpdate{Plan: &customPlan}},
+		{"timezone", SettingsUpdate{Timezone: &badTimezone}},
+		{"threshold", SettingsUpdate{WarningThreshold: &badThreshold}},
+		{"interval", SettingsUpdate{SyncIntervalMinutes: &negativeInterval}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := service.Update(tc.update); !errors.Is(err, ErrInvalidSettings) {
+				t.Errorf("Expected ErrInvalidSettings, got %v", err)
+			}
+		})
+	}
+
+	if service.Get() != DefaultRuntimeSettings() {
+		t.Errorf("Settings changed after rejected updates: %+v", service.Get())
+	}
+}
+
+func TestSettingsService_PrivacyModeLocksContentPolicy(t *testing.T) {
+	db, service := setupSettingsService(t)
+	defer db.Close()
+
+	// Content was stored before the instance was switched to privacy mode
+	full := ContentPolicyFull
+	if _, err := service.Update(SettingsUpdate{ContentPolicy: &full}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	defaults := DefaultRuntimeSettings()
+	defaults.PrivacyMode = true
+	defaults.ContentPolicy = ContentPolicyMetadata
+	private := NewSettingsService(db, defaults)
+	if err := private.Load(); err != nil {
+		t.Fatalf("Failed to load settings: %v", err)
+	}
+
+	settings := private.Get()
+	if !settings.PrivacyMode || settings.ContentPolicy != ContentPolicyMetadata {
+		t.Errorf("Expected privacy mode with metadata policy, got %+v", settings)
+	}
+
+	if _, err := private.Update(SettingsUpdate{ContentPolicy: &full}); !errors.Is(err, ErrInvalidSettings) {
+		t.Errorf("Expected ErrInvalidSettings, got %v", err)
+	}
+}
+
+func TestSettingsService_CustomPlanLimit(t *testing.T) {
+	db, service := setupSettingsService(t)
+	defer db.Close()
+
+	tokenService := NewTokenService(db)
+	service.Subscribe(func(settings RuntimeSettings) {
+		if err := tokenService.SetPlanLimit(settings.Plan, settings.UsageLimit()); err != nil {
+			t.Errorf("Failed to apply plan: %v", err)
+		}
+	})
+
+	plan := PlanCustom
+	limit := 50000
+	if _, err := service.Update(SettingsUpdate{Plan: &plan, PlanTokenLimit: &limit}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if tokenService.CurrentPlan() != PlanCustom || tokenService.getUsageLimit() != 50000 {
+		t.Errorf("Expected custom plan with 50000 tokens, got %s %d", tokenService.CurrentPlan(), tokenService.getUsageLimit())
+	}
+
+	// Built-in plans ignore the custom limit
+	plan = PlanMax20
+	if _, err := service.Update(SettingsUpdate{Plan: &plan}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if tokenService.getUsageLimit() != CLAUDE_MAX20_LIMIT {
+		t.Errorf("Expected max20 limit %d, got %d", CLAUDE_MAX20_LIMIT, tokenService.getUsageLimit())
+	}
+}
+
+func TestSettingsService_DefaultsApplyToFieldsNotUpdated(t *testing.T) {
+	db, service := setupSettingsService(t)
+	defer db.Close()
+
+	plan := PlanMax5
+	if _, err := service.Update(SettingsUpdate{Plan: &plan}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	// The configuration changed between restarts
+	defaults := DefaultRuntimeSettings()
+	defaults.Plan = PlanMax20
+	defaults.Timezone = "Asia/Tokyo"
+	reloaded := NewSettingsService(db, defaults)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to reload settings: %v", err)
+	}
+	if settings := reloaded.Get(); settings.Plan != PlanMax5 || settings.Timezone != "Asia/Tokyo" {
+		t.Errorf("Expected the updated plan and the configured timezone, got %+v", settings)
+	}
+
+	// Of settings stored whole by earlier versions, only values that differ
+	// from the defaults are kept
+	legacy := defaults
+	legacy.SyncIntervalMinutes = 30
+	data, _ := json.Marshal(legacy)
+	db.Exec(`DELETE FROM settings`)
+	db.Exec(`INSERT INTO settings (key, value) VALUES ('runtime', ?)`, string(data))
+	reloaded = NewSettingsService(db, defaults)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to reload settings: %v", err)
+	}
+	if settings := reloaded.Get(); settings.SyncIntervalMinutes != 30 || settings.Timezone != "Asia/Tokyo" {
+		t.Errorf("Expected the stored interval and the configured timezone, got %+v", settings)
+	}
+	timezone := "Europe/Paris"
+	if _, err := reloaded.Update(SettingsUpdate{Timezone: &timezone}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	defaults.Plan = PlanMax5
+	reloaded = NewSettingsService(db, defaults)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Failed to reload settings: %v", err)
+	}
+	if settings := reloaded.Get(); settings.SyncIntervalMinutes != 30 || settings.Timezone != timezone || settings.Plan != PlanMax5 {
+		t.Errorf("Expected the stored interval and timezone and the configured plan, got %+v", settings)
+	}
+}
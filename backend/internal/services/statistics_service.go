@@ -0,0 +1,134 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultPercentileLookbacks are the periods percentiles are reported over
+// unless others are asked for
+var DefaultPercentileLookbacks = []int{7, 30, 90}
+
+// Percentiles summarizes the distribution of token totals
+type Percentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   int64   `json:"max"`
+}
+
+// PercentilePeriod is the distribution of tokens per window and per session
+// over the days before the report
+type PercentilePeriod struct {
+	Days  int       `json:"days"`
+	Since time.Time `json:"since"`
+	// Windows counts completed session windows only, since the active one
+	// is still growing. Windows are shared by all users, so a report for one
+	// user leaves them out.
+	Windows  *Percentiles `json:"windows,omitempty"`
+	Sessions Percentiles  `json:"sessions"`
+}
+
+// PercentileReport is the token percentiles of each lookback period
+type PercentileReport struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Periods     []PercentilePeriod `json:"periods"`
+}
+
+// StatisticsService computes usage distributions from windows and sessions
+type StatisticsService struct {
+	db *sql.DB
+}
+
+func NewStatisticsService(db *sql.DB) *StatisticsService {
+	return &StatisticsService{db: db}
+}
+
+// GetPercentiles returns the p50, p90 and p99 tokens per window and per
+// session started in each of the last lookbacks days before now. When user is
+// set, only their sessions are counted and windows, which are shared by all
+// users, are left out.
+func (s *StatisticsService) GetPercentiles(lookbacks []int, user string, now time.Time) (*PercentileReport, error) {
+	if len(lookbacks) == 0 {
+		lookbacks = DefaultPercentileLookbacks
+	}
+
+	report := &PercentileReport{GeneratedAt: now, Periods: []PercentilePeriod{}}
+	for _, days := range lookbacks {
+		since := now.AddDate(0, 0, -days)
+		period := PercentilePeriod{Days: days, Since: since}
+		if user == "" {
+			windows, err := s.queryTotals(`
+				SELECT total_tokens FROM session_windows
+				WHERE window_start >= ? AND window_end <= ? AND total_tokens > 0
+			`, since.UTC(), now.UTC())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get window totals: %w", err)
+			}
+			windowPercentiles := computePercentiles(windows)
+			period.Windows = &windowPercentiles
+		}
+
+		query := `SELECT total_tokens FROM sessions WHERE start_time >= ? AND total_tokens > 0`
+		args := []interface{}{since.UTC()}
+		if user != "" {
+			query += ` AND user_id = ?`
+			args = append(args, user)
+		}
+		sessions, err := s.queryTotals(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session totals: %w", err)
+		}
+
+		period.Sessions = computePercentiles(sessions)
+		report.Periods = append(report.Periods, period)
+	}
+	return report, nil
+}
+
+func (s *StatisticsService) queryTotals(query string, args ...interface{}) ([]int64, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []int64
+	for rows.Next() {
+		var total int64
+		if err := rows.Scan(&total); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+// computePercentiles sorts values and interpolates between the closest ranks,
+// as quantile_cont does
+func computePercentiles(values []int64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return Percentiles{
+		Count: len(values),
+		P50:   percentile(values, 0.5),
+		P90:   percentile(values, 0.9),
+		P99:   percentile(values, 0.99),
+		Max:   values[len(values)-1],
+	}
+}
+
+// percentile returns the p quantile of sorted
+func percentile(sorted []int64, p float64) float64 {
+	rank := p * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	value := float64(sorted[lower]) + (rank-float64(lower))*float64(sorted[upper]-sorted[lower])
+	return roundToDecimals(value, 2)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestComputePercentiles(t *testing.T) {
+	values := make([]int64, 0, 100)
+	for i := 100; i >= 1; i-- {
+		values = append(values, int64(i))
+	}
+	p := computePercentiles(values)
+	if p.Count != 100 || p.P50 != 50.5 || p.P90 != 90.1 || p.P99 != 99.01 || p.Max != 100 {
+		t.Errorf("Unexpected percentiles %+v", p)
+	}
+	if p := computePercentiles([]int64{7}); p.P50 != 7 || p.P99 != 7 {
+		t.Errorf("Expected a single value for every percentile, got %+v", p)
+	}
+	if p := computePercentiles(nil); p != (Percentiles{}) {
+		t.Errorf("Expected zero percentiles without values, got %+v", p)
+	}
+}
+
+func TestStatisticsPercentilesByLookback(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	now := time.Date(2024, 3, 31, 12, 0, 0, 0, time.UTC)
+	// One window and session every 10 days, growing by 1000 tokens each
+	for i := 0; i < 9; i++ {
+		start := now.AddDate(0, 0, -10*i-1)
+		tokens := 1000 * (i + 1)
+		if _, err := db.Exec(`
+			INSERT INTO session_windows (id, window_start, window_end, reset_time, total_tokens)
+			VALUES (?, ?, ?, ?, ?)
+		`, fmt.Sprintf("w%d", i), start, start.Add(5*time.Hour), start.Add(5*time.Hour), tokens); err != nil {
+			t.Fatalf("Failed to insert window: %v", err)
+		}
+		user := "alice"
+		if i%2 == 1 {
+			user = "bob"
+		}
+		if _, err := db.Exec(`
+			INSERT INTO sessions (id, project_name, project_path, start_time, total_tokens, user_id)
+			VALUES (?, 'app', '/app', ?, ?, ?)
+		`, fmt.Sprintf("s%d", i), start, tokens, user); err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+	}
+	// The active window is not counted
+	if _, err := db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, total_tokens)
+		VALUES ('active', ?, ?, ?, 50)
+	`, now.Add(-time.Hour), now.Add(4*time.Hour), now.Add(4*time.Hour)); err != nil {
+		t.Fatalf("Failed to insert window: %v", err)
+	}
+
+	stats := NewStatisticsService(db)
+	report, err := stats.GetPercentiles(nil, "", now)
+	if err != nil {
+		t.Fatalf("GetPercentiles failed: %v", err)
+	}
+	if len(report.Periods) != 3 {
+		t.Fatalf("Expected the default lookbacks, got %+v", report.Periods)
+	}
+	for i, want := range []struct {
+		days, count int
+		p50         float64
+	}{{7, 1, 1000}, {30, 3, 2000}, {90, 9, 5000}} {
+		period := report.Periods[i]
+		if period.Days != want.days || period.Windows == nil || period.Windows.Count != want.count || period.Windows.P50 != want.p50 {
+			t.Errorf("Expected %d windows with p50 %v over %d days, got %+v", want.count, want.p50, want.days, period)
+		}
+		if period.Windows != nil && period.Sessions != *period.Windows {
+			t.Errorf("Expected sessions like windows over %d days, got %+v", want.days, period.Sessions)
+		}
+	}
+
+	report, err = stats.GetPercentiles([]int{90}, "bob", now)
+	if err != nil {
+		t.Fatalf("GetPercentiles failed: %v", err)
+	}
+	if sessions := report.Periods[0].Sessions; sessions.Count != 4 || sessions.Max != 8000 {
+		t.Errorf("Expected bob's four sessions, got %+v", sessions)
+	}
+	if report.Periods[0].Windows != nil {
+		t.Errorf("Expected no shared windows in bob's report, got %+v", report.Periods[0].Windows)
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// Statusline is a one-line summary of current usage for status bars, shell
+// prompts and menu-bar apps
+type Statusline struct {
+	// Utilization is the share of the window's token limit used, e.g. 0.62
+	Utilization float64 `json:"utilization"`
+	TotalTokens int     `json:"total_tokens"`
+	UsageLimit  int     `json:"usage_limit"`
+	// ResetsAt is when the current window resets; null without usage in one
+	ResetsAt  *time.Time `json:"resets_at"`
+	TodayCost float64    `json:"today_cost"`
+	Currency  string     `json:"currency"`
+	Timezone  string     `json:"timezone"`
+	// Text is the summary line, e.g. "⚡ 62% | resets 14:00 | $3.20 today"
+	Text string `json:"text"`
+}
+
+// StatuslineService builds status lines. Today's cost is cached until the
+// next sync so the line can be polled every few seconds.
+type StatuslineService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+	cache   *QueryCache
+
+	mu  sync.RWMutex
+	loc *time.Location
+}
+
+func NewStatuslineService(db *sql.DB) *StatuslineService {
+	return &StatuslineService{
+		db:      db,
+		pricing: NewPricingCalculator(),
+		cache:   NewQueryCache(DefaultQueryCacheMaxAge),
+		loc:     time.UTC,
+	}
+}
+
+// SetLocation sets the time zone days and reset times follow
+func (s *StatuslineService) SetLocation(loc *time.Location) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loc = loc
+}
+
+// Location returns the time zone set with SetLocation
+func (s *StatuslineService) Location() *time.Location {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.loc
+}
+
+// Invalidate drops the cached costs, e.g. after a sync or a price change
+func (s *StatuslineService) Invalidate() {
+	s.cache.MarkIngested()
+}
+
+// Statusline summarizes usage, the window of the current token usage and
+// the cost of the day of loc containing now, limited to user's sessions
+// when user is set
+func (s *StatuslineService) Statusline(usage *models.TokenUsage, user string, now time.Time, loc *time.Location) (*Statusline, error) {
+	local := now.In(loc)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	key := fmt.Sprintf("day-cost:%s:%s", user, dayStart.Format(time.RFC3339))
+	cost, err := s.cache.GetOrLoad(key, func() (interface{}, error) {
+		return s.cost(user, dayStart, dayStart.AddDate(0, 0, 1))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	line := &Statusline{
+		TodayCost: cost.(float64),
+		Currency:  "USD",
+		Timezone:  loc.String(),
+	}
+	// Without usage the current window is a placeholder starting now
+	if usage != nil && usage.TotalTokens > 0 && now.Before(usage.WindowEnd) {
+		line.Utilization = roundToDecimals(usage.UsageRate, 4)
+		line.TotalTokens = usage.TotalTokens
+		line.UsageLimit = usage.UsageLimit
+		resetsAt := usage.WindowEnd.In(loc)
+		line.ResetsAt = &resetsAt
+	} else if usage != nil {
+		line.UsageLimit = usage.UsageLimit
+	}
+	line.Text = formatStatusline(line)
+	return line, nil
+}
+
+// formatStatusline renders the summary line of line
+func formatStatusline(line *Statusline) string {
+	parts := []string{fmt.Sprintf("⚡ %.0f%%", line.Utilization*100)}
+	if line.ResetsAt != nil {
+		parts = append(parts, "resets "+line.ResetsAt.Format("15:04"))
+	}
+	parts = append(parts, fmt.Sprintf("$%.2f today", line.TodayCost))
+	return strings.Join(parts, " | ")
+}
+
+// cost returns the cost of the assistant messages between from and to at
+// API prices
+func (s *StatuslineService) cost(user string, from, to time.Time) (float64, error) {
+	where, args := rangeFilter("", user, from.UTC(), to.UTC())
+	rows, err := s.db.Query(`
+		SELECT
+			m.model,
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		`+where+` AND m.message_role = 'assistant' AND m.model IS NOT NULL
+		GROUP BY m.model
+	`, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query today's cost: %w", err)
+	}
+	defer rows.Close()
+
+	var total float64
+	for rows.Next() {
+		var model string
+		var input, output, cacheCreation, cacheRead int
+		if err := rows.Scan(&model, &input, &output, &cacheCreation, &cacheRead); err != nil {
+			return 0, fmt.Errorf("failed to scan today's cost: %w", err)
+		}
+		total += s.pricing.CalculateCost(model, input, output, cacheCreation, cacheRead)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read today's cost: %w", err)
+	}
+	return roundToDecimals(total, 6), nil
+}
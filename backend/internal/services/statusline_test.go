@@ -0,0 +1,82 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/models"
+)
+
+func TestStatusline(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// $3 of sonnet input today in Tokyo, and $3 the day before
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, user_id, project_name, project_path, start_time) VALUES
+			('s1', 'alice', 'api', '/work/api', '2024-03-01 09:00:00');
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp) VALUES
+			('m1', 's1', 'assistant', 'claude-sonnet-4-20250514', 1000000, 0, '2024-03-01 16:00:00'),
+			('m2', 's1', 'assistant', 'claude-sonnet-4-20250514', 1000000, 0, '2024-03-01 14:00:00');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Time zone data is not available: %v", err)
+	}
+	service := NewStatuslineService(db)
+	now := time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)
+	usage := &models.TokenUsage{
+		TotalTokens: 620,
+		UsageLimit:  1000,
+		UsageRate:   0.62,
+		WindowStart: now.Add(-time.Hour),
+		WindowEnd:   now.Add(4 * time.Hour),
+	}
+
+	line, err := service.Statusline(usage, "", now, tokyo)
+	if err != nil {
+		t.Fatalf("Statusline failed: %v", err)
+	}
+	if line.Text != "⚡ 62% | resets 06:00 | $3.00 today" {
+		t.Errorf("Unexpected status line %q", line.Text)
+	}
+	if line.TodayCost != 3 || line.ResetsAt == nil || line.Timezone != "Asia/Tokyo" {
+		t.Errorf("Unexpected status line %+v", line)
+	}
+
+	// The cost is cached until Invalidate
+	if _, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens, timestamp)
+		VALUES ('m3', 's1', 'assistant', 'claude-sonnet-4-20250514', 1000000, 0, '2024-03-01 16:30:00')
+	`); err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+	if line, _ := service.Statusline(usage, "", now, tokyo); line.TodayCost != 3 {
+		t.Errorf("Expected the cached $3, got %v", line.TodayCost)
+	}
+	service.Invalidate()
+	if line, _ := service.Statusline(usage, "", now, tokyo); line.TodayCost != 6 {
+		t.Errorf("Expected $6 after Invalidate, got %v", line.TodayCost)
+	}
+
+	// A window without usage has no reset time, and other users cost nothing
+	line, err = service.Statusline(&models.TokenUsage{UsageLimit: 1000, WindowStart: now, WindowEnd: now.Add(5 * time.Hour)}, "bob", now, tokyo)
+	if err != nil {
+		t.Fatalf("Statusline failed: %v", err)
+	}
+	if line.Text != "⚡ 0% | $0.00 today" || line.ResetsAt != nil {
+		t.Errorf("Unexpected status line %+v", line)
+	}
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// Sync job states
+const (
+	SyncJobQueued    = "queued"
+	SyncJobRunning   = "running"
+	SyncJobCompleted = "completed"
+	SyncJobFailed    = "failed"
+)
+
+// ErrSyncJobNotFound is returned for unknown or expired job IDs
+var ErrSyncJobNotFound = errors.New("sync job not found")
+
+// syncJobHistory is the number of finished jobs kept for status lookups
+const syncJobHistory = 20
+
+// maxSyncJobFileErrors caps the file errors kept on a job
+const maxSyncJobFileErrors = 50
+
+// SyncJob is a snapshot of a queued or finished log synchronization
+type SyncJob struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	Trigger    string            `json:"trigger"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Stats      *models.SyncStats `json:"stats,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	// Progress holds the running totals, updated while the job runs
+	Progress *SyncJobProgress `json:"progress,omitempty"`
+	// FileErrors lists files that failed; the job can still complete
+	FileErrors []SyncJobFileError `json:"file_errors,omitempty"`
+}
+
+// SyncJobProgress is how far a running job has got
+type SyncJobProgress struct {
+	FilesFound     int    `json:"files_found"`
+	FilesDone      int    `json:"files_done"`
+	LinesProcessed int    `json:"lines_processed"`
+	Errors         int    `json:"errors"`
+	Project        string `json:"project,omitempty"`
+}
+
+// SyncJobFileError is a file that could not be synced
+type SyncJobFileError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+type syncJobEntry struct {
+	job  SyncJob
+	done chan struct{}
+}
+
+// SyncJobQueue runs log synchronization in the background, one pass at a
+// time. While a pass is running, further requests collapse into a single
+// queued follow-up pass so repeated triggers never stack overlapping scans.
+type SyncJobQueue struct {
+	run func(ctx context.Context) (*models.SyncStats, error)
+	// ctx is passed to every pass and canceled by Shutdown
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	mu       sync.Mutex
+	started  []func(SyncJob)
+	finished []func(SyncJob)
+	running  *syncJobEntry
+	pending  *syncJobEntry
+	jobs     map[string]*syncJobEntry
+	order    []string
+}
+
+// NewSyncJobQueue creates a queue that performs a pass by calling run. run
+// should stop early, keeping its progress, when ctx is canceled.
+func NewSyncJobQueue(run func(ctx context.Context) (*models.SyncStats, error)) *SyncJobQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SyncJobQueue{
+		run:    run,
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*syncJobEntry),
+	}
+}
+
+// Shutdown cancels the running pass and waits until it has stopped or ctx is
+// done. Jobs queued afterwards fail right away.
+func (q *SyncJobQueue) Shutdown(ctx context.Context) error {
+	q.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Enqueue requests a sync pass. If one is already waiting to run, that job is
+// returned instead and created is false.
+func (q *SyncJobQueue) Enqueue(trigger string) (job SyncJob, created bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.pending != nil {
+		return q.pending.job, false
+	}
+
+	entry := &syncJobEntry{
+		job: SyncJob{
+			ID:        uuid.New().String(),
+			Status:    SyncJobQueued,
+			Trigger:   trigger,
+			CreatedAt: time.Now(),
+		},
+		done: make(chan struct{}),
+	}
+	q.remember(entry)
+
+	if q.running == nil {
+		q.running = entry
+		q.workers.Add(1)
+		go q.worker()
+	} else {
+		q.pending = entry
+	}
+	return entry.job, true
+}
+
+// worker runs jobs until no follow-up pass is pending
+func (q *SyncJobQueue) worker() {
+	defer q.workers.Done()
+	for {
+		q.mu.Lock()
+		entry := q.running
+		started := time.Now()
+		entry.job.Status = SyncJobRunning
+		entry.job.StartedAt = &started
+		running := entry.job
+		startListeners := q.started
+		q.mu.Unlock()
+
+		for _, fn := range startListeners {
+			fn(running)
+		}
+
+		var stats *models.SyncStats
+		var err error
+		if q.ctx.Err() != nil {
+			err = fmt.Errorf("sync canceled: %w", q.ctx.Err())
+		} else {
+			stats, err = q.run(withSyncJobID(q.ctx, running.ID))
+		}
+
+		q.mu.Lock()
+		finished := time.Now()
+		entry.job.FinishedAt = &finished
+		entry.job.Stats = stats
+		if stats != nil {
+			entry.job.Progress = &SyncJobProgress{
+				FilesFound:     stats.TotalFiles,
+				FilesDone:      stats.ProcessedFiles + stats.SkippedFiles + stats.FailedFiles,
+				LinesProcessed: stats.NewLines,
+				Errors:         stats.FailedFiles + stats.ParseErrors,
+			}
+		}
+		if err != nil {
+			entry.job.Status = SyncJobFailed
+			entry.job.Error = err.Error()
+		} else {
+			entry.job.Status = SyncJobCompleted
+		}
+		close(entry.done)
+		job := entry.job
+		listeners := q.finished
+
+		q.running = q.pending
+		q.pending = nil
+		next := q.running
+		q.mu.Unlock()
+
+		for _, fn := range listeners {
+			fn(job)
+		}
+
+		if next == nil {
+			return
+		}
+	}
+}
+
+// RecordProgress updates the running job from a sync progress event
+func (q *SyncJobQueue) RecordProgress(p SyncProgress) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.running == nil || q.running.job.Status != SyncJobRunning {
+		return
+	}
+	job := &q.running.job
+	job.Progress = &SyncJobProgress{
+		FilesFound:     p.FilesFound,
+		FilesDone:      p.FilesDone,
+		LinesProcessed: p.LinesProcessed,
+		Errors:         p.Errors,
+		Project:        p.Project,
+	}
+	if p.FileStatus == SyncFileError && len(job.FileErrors) < maxSyncJobFileErrors {
+		job.FileErrors = append(job.FileErrors, SyncJobFileError{File: p.File, Error: p.Error})
+	}
+}
+
+// OnStarted registers fn to be called when a job starts running, before the
+// pass begins. fn runs on the worker goroutine and should return quickly.
+func (q *SyncJobQueue) OnStarted(fn func(SyncJob)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.started = append(q.started, fn)
+}
+
+// OnFinished registers fn to be called after each job completes or fails.
+// fn runs on the worker goroutine and should return quickly.
+func (q *SyncJobQueue) OnFinished(fn func(SyncJob)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.finished = append(q.finished, fn)
+}
+
+// remember records a job for lookups, dropping the oldest finished ones
+func (q *SyncJobQueue) remember(entry *syncJobEntry) {
+	q.jobs[entry.job.ID] = entry
+	q.order = append(q.order, entry.job.ID)
+	for len(q.order) > syncJobHistory {
+		oldest := q.jobs[q.order[0]]
+		if oldest == q.running || oldest == q.pending {
+			break
+		}
+		delete(q.jobs, q.order[0])
+		q.order = q.order[1:]
+	}
+}
+
+// Get returns the current state of a job
+func (q *SyncJobQueue) Get(id string) (SyncJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.jobs[id]
+	if !ok {
+		return SyncJob{}, false
+	}
+	return entry.job, true
+}
+
+// List returns known jobs, newest first
+func (q *SyncJobQueue) List() []SyncJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]SyncJob, 0, len(q.order))
+	for i := len(q.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, q.jobs[q.order[i]].job)
+	}
+	return jobs
+}
+
+// Wait blocks until the job finishes or ctx is done
+func (q *SyncJobQueue) Wait(ctx context.Context, id string) (SyncJob, error) {
+	q.mu.Lock()
+	entry, ok := q.jobs[id]
+	q.mu.Unlock()
+	if !ok {
+		return SyncJob{}, ErrSyncJobNotFound
+	}
+
+	select {
+	case <-entry.done:
+	case <-ctx.Done():
+		return SyncJob{}, ctx.Err()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return entry.job, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+func TestSyncJobQueueCoalescesRequests(t *testing.T) {
+	release := make(chan struct{})
+	var runs atomic.Int32
+	queue := NewSyncJobQueue(func(context.Context) (*models.SyncStats, error) {
+		runs.Add(1)
+		<-release
+		return &models.SyncStats{NewLines: 1}, nil
+	})
+
+	first, created := queue.Enqueue("test")
+	if !created {
+		t.Fatal("Expected first job to be created")
+	}
+
+	// While the first pass runs, every further request shares one follow-up job
+	second, created := queue.Enqueue("test")
+	if !created {
+		t.Fatal("Expected follow-up job to be created")
+	}
+	third, created := queue.Enqueue("test")
+	if created || third.ID != second.ID {
+		t.Errorf("Expected repeated request to reuse job %s, got %s", second.ID, third.ID)
+	}
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, id := range []string{first.ID, second.ID} {
+		job, err := queue.Wait(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to wait for job: %v", err)
+		}
+		if job.Status != SyncJobCompleted {
+			t.Errorf("Expected job %s to complete, got %s", id, job.Status)
+		}
+		if job.Stats == nil || job.Stats.NewLines != 1 {
+			t.Errorf("Expected stats on job %s", id)
+		}
+	}
+
+	if runs.Load() != 2 {
+		t.Errorf("Expected 2 sync passes, got %d", runs.Load())
+	}
+	if jobs := queue.List(); len(jobs) != 2 || jobs[0].ID != second.ID {
+		t.Errorf("Expected 2 jobs newest first, got %+v", jobs)
+	}
+}
+
+func TestSyncJobQueueRecordsFailures(t *testing.T) {
+	queue := NewSyncJobQueue(func(context.Context) (*models.SyncStats, error) {
+		return nil, errors.New("projects directory missing")
+	})
+
+	job, _ := queue.Enqueue("test")
+	job, err := queue.Wait(context.Background(), job.ID)
+	if err != nil {
+		t.Fatalf("Failed to wait for job: %v", err)
+	}
+	if job.Status != SyncJobFailed || job.Error != "projects directory missing" {
+		t.Errorf("Expected failed job with error, got %+v", job)
+	}
+
+	if _, err := queue.Wait(context.Background(), "missing"); !errors.Is(err, ErrSyncJobNotFound) {
+		t.Errorf("Expected ErrSyncJobNotFound, got %v", err)
+	}
+}
+
+func TestSyncJobQueueRecordsProgress(t *testing.T) {
+	var queue *SyncJobQueue
+	checked := make(chan SyncJob, 1)
+	queue = NewSyncJobQueue(func(context.Context) (*models.SyncStats, error) {
+		queue.RecordProgress(SyncProgress{Type: SyncProgressFile, File: "a.jsonl", FileStatus: SyncFileError, Error: "boom", FilesFound: 3, FilesDone: 1, Errors: 1})
+		queue.RecordProgress(SyncProgress{Type: SyncProgressFile, File: "b.jsonl", FileStatus: SyncFileProcessed, FilesFound: 3, FilesDone: 2, LinesProcessed: 7, Errors: 1})
+		jobs := queue.List()
+		checked <- jobs[0]
+		return &models.SyncStats{TotalFiles: 3, ProcessedFiles: 2, FailedFiles: 1, NewLines: 9}, nil
+	})
+
+	// Progress outside a running job is ignored
+	queue.RecordProgress(SyncProgress{FilesFound: 99})
+
+	job, _ := queue.Enqueue("test")
+	running := <-checked
+	if running.Status != SyncJobRunning || running.Progress == nil || running.Progress.FilesDone != 2 || running.Progress.LinesProcessed != 7 {
+		t.Errorf("Expected running progress, got %+v", running.Progress)
+	}
+	if len(running.FileErrors) != 1 || running.FileErrors[0].File != "a.jsonl" || running.FileErrors[0].Error != "boom" {
+		t.Errorf("Expected the failed file, got %+v", running.FileErrors)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := queue.Wait(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to wait for job: %v", err)
+	}
+	if job.Status != SyncJobCompleted || job.Progress.FilesDone != 3 || job.Progress.LinesProcessed != 9 || job.Progress.Errors != 1 || len(job.FileErrors) != 1 {
+		t.Errorf("Expected final totals with the file error kept, got %+v %+v", job.Progress, job.FileErrors)
+	}
+}
+
+func TestSyncJobQueueShutdownCancelsPass(t *testing.T) {
+	started := make(chan struct{})
+	queue := NewSyncJobQueue(func(ctx context.Context) (*models.SyncStats, error) {
+		close(started)
+		<-ctx.Done()
+		return &models.SyncStats{NewLines: 3}, ctx.Err()
+	})
+
+	job, _ := queue.Enqueue("test")
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := queue.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	job, _ = queue.Get(job.ID)
+	if job.Status != SyncJobFailed || job.Stats == nil || job.Stats.NewLines != 3 {
+		t.Errorf("Expected the canceled pass to keep its stats, got %+v", job)
+	}
+
+	// Jobs queued after shutdown fail without running
+	late, _ := queue.Enqueue("test")
+	late, err := queue.Wait(ctx, late.ID)
+	if err != nil || late.Status != SyncJobFailed || late.Error != "sync canceled: context canceled" {
+		t.Errorf("Expected a canceled job, got %+v (%v)", late, err)
+	}
+}
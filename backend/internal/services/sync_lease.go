@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+)
+
+// syncLeaseTTL is how long a lease lasts unless its holder renews it, so a
+// process that dies mid-sync blocks the others for at most this long
+const syncLeaseTTL = 2 * time.Minute
+
+// ErrSyncInProgress is returned when another process is syncing into the
+// same database
+var ErrSyncInProgress = errors.New("another sync is in progress")
+
+// SyncLease is the claim of the process syncing into a database. Instances
+// sharing a database, or a command line sync next to a server, take turns.
+type SyncLease struct {
+	Holder     string    `json:"holder"`
+	JobID      string    `json:"job_id,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SyncInProgressError names the process holding the lease
+type SyncInProgressError struct {
+	Lease SyncLease
+}
+
+func (e *SyncInProgressError) Error() string {
+	if e.Lease.JobID != "" {
+		return fmt.Sprintf("%s (job %s on %s)", ErrSyncInProgress, e.Lease.JobID, e.Lease.Holder)
+	}
+	return fmt.Sprintf("%s (on %s)", ErrSyncInProgress, e.Lease.Holder)
+}
+
+func (e *SyncInProgressError) Unwrap() error { return ErrSyncInProgress }
+
+var (
+	syncLeaseHolderOnce sync.Once
+	syncLeaseHolderName string
+)
+
+// syncLeaseHolder identifies this process to the others
+func syncLeaseHolder() string {
+	syncLeaseHolderOnce.Do(func() {
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		syncLeaseHolderName = fmt.Sprintf("%s:%d", host, os.Getpid())
+	})
+	return syncLeaseHolderName
+}
+
+// ActiveSyncLease returns the unexpired lease of another process, or nil
+// when this process may sync
+func ActiveSyncLease(db *sql.DB) (*SyncLease, error) {
+	lease, err := readSyncLease(db)
+	if err != nil || lease == nil {
+		return nil, err
+	}
+	if lease.Holder == syncLeaseHolder() || !lease.ExpiresAt.After(time.Now().UTC()) {
+		return nil, nil
+	}
+	return lease, nil
+}
+
+func readSyncLease(db *sql.DB) (*SyncLease, error) {
+	var lease SyncLease
+	var jobID sql.NullString
+	err := db.QueryRow(`SELECT holder, job_id, acquired_at, expires_at FROM sync_lease WHERE id = 1`).
+		Scan(&lease.Holder, &jobID, &lease.AcquiredAt, &lease.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync lease: %w", err)
+	}
+	lease.JobID = jobID.String
+	return &lease, nil
+}
+
+// acquireSyncLease claims the lease for a pass and keeps renewing it until
+// the returned release function is called. It fails with a
+// *SyncInProgressError while another process holds an unexpired lease.
+func acquireSyncLease(db *sql.DB, writes *WriteQueue, jobID string) (release func(), err error) {
+	holder := syncLeaseHolder()
+	var current *SyncLease
+	err = writes.Do(func() error {
+		now := time.Now().UTC()
+		if _, err := db.Exec(`DELETE FROM sync_lease WHERE id = 1 AND (expires_at <= ? OR holder = ?)`, now, holder); err != nil {
+			return fmt.Errorf("failed to clear expired sync lease: %w", err)
+		}
+		_, err := db.Exec(`
+			INSERT INTO sync_lease (id, holder, job_id, acquired_at, expires_at)
+			VALUES (1, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING
+		`, holder, nullableString(jobID), now, now.Add(syncLeaseTTL))
+		if err != nil {
+			return fmt.Errorf("failed to acquire sync lease: %w", err)
+		}
+		current, err = readSyncLease(db)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && current.Holder != holder {
+		return nil, &SyncInProgressError{Lease: *current}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(syncLeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := writes.Do(func() error {
+					_, err := db.Exec(`UPDATE sync_lease SET expires_at = ? WHERE id = 1 AND holder = ?`,
+						time.Now().UTC().Add(syncLeaseTTL), holder)
+					return err
+				})
+				if err != nil {
+					logging.Component("sync").Warn("Failed to renew sync lease", "err", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			err := writes.Do(func() error {
+				_, err := db.Exec(`DELETE FROM sync_lease WHERE id = 1 AND holder = ?`, holder)
+				return err
+			})
+			if err != nil {
+				logging.Component("sync").Warn("Failed to release sync lease", "err", err)
+			}
+		})
+	}, nil
+}
+
+type syncJobIDKey struct{}
+
+// withSyncJobID records the job a pass runs for, so its lease names it
+func withSyncJobID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, syncJobIDKey{}, id)
+}
+
+// syncJobID returns the job a pass runs for, or "" outside the job queue
+func syncJobID(ctx context.Context) string {
+	id, _ := ctx.Value(syncJobIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestSyncLease(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "-work-app")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	line := `{"uuid":"u-1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"assistant","content":"hi","usage":{"input_tokens":10,"output_tokens":5}}}`
+	if err := os.WriteFile(filepath.Join(project, "s1.jsonl"), []byte(line+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	diffSync := NewDiffSyncService(db, NewTokenService(db), NewSessionService(db))
+	diffSync.SetLogSources(LogSourceConfig{Roots: []string{root}})
+
+	// Another instance is syncing into the same database
+	now := time.Now().UTC()
+	if _, err := db.Exec(`INSERT INTO sync_lease (id, holder, job_id, acquired_at, expires_at) VALUES (1, 'other-host:1', 'job-1', ?, ?)`,
+		now, now.Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to insert lease: %v", err)
+	}
+	lease, err := ActiveSyncLease(db)
+	if err != nil || lease == nil || lease.JobID != "job-1" {
+		t.Fatalf("Expected the other instance's lease, got %+v, %v", lease, err)
+	}
+	_, err = diffSync.SyncAllLogs(context.Background())
+	var inProgress *SyncInProgressError
+	if !errors.Is(err, ErrSyncInProgress) || !errors.As(err, &inProgress) || inProgress.Lease.Holder != "other-host:1" {
+		t.Fatalf("Expected the sync to be refused, got %v", err)
+	}
+	var messages int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&messages); err != nil || messages != 0 {
+		t.Errorf("Expected nothing synced, got %d messages, %v", messages, err)
+	}
+
+	// Its lease runs out without being renewed
+	if _, err := db.Exec(`UPDATE sync_lease SET expires_at = ? WHERE id = 1`, now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if lease, err := ActiveSyncLease(db); err != nil || lease != nil {
+		t.Errorf("Expected an expired lease to be ignored, got %+v, %v", lease, err)
+	}
+	if _, err := diffSync.SyncAllLogs(withSyncJobID(context.Background(), "job-2")); err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&messages); err != nil || messages != 1 {
+		t.Errorf("Expected 1 message synced, got %d, %v", messages, err)
+	}
+	var leases int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sync_lease`).Scan(&leases); err != nil || leases != 0 {
+		t.Errorf("Expected the lease to be released, got %d, %v", leases, err)
+	}
+}
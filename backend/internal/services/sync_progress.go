@@ -0,0 +1,165 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// Sync progress event types
+const (
+	// SyncProgressStarted is sent when a sync job starts running
+	SyncProgressStarted = "started"
+	// SyncProgressDiscovered is sent once the log files have been listed
+	SyncProgressDiscovered = "discovered"
+	// SyncProgressProject is sent before the files of a project are checked
+	SyncProgressProject = "project"
+	// SyncProgressFile is sent after each file is processed, skipped or fails
+	SyncProgressFile = "file"
+	// SyncProgressFinished is sent when the job completes or fails
+	SyncProgressFinished = "finished"
+)
+
+// File outcomes reported by SyncProgressFile events
+const (
+	SyncFileProcessed = "processed"
+	SyncFileSkipped   = "skipped"
+	SyncFileError     = "error"
+)
+
+// syncProgressBuffer is how many events a slow subscriber may fall behind
+// before it is dropped
+const syncProgressBuffer = 64
+
+// SyncProgress is one step of a running sync. The counters are running
+// totals for the whole pass.
+type SyncProgress struct {
+	Type    string `json:"type"`
+	JobID   string `json:"job_id,omitempty"`
+	Project string `json:"project,omitempty"`
+	// ProjectFiles is the number of files in Project, for project events
+	ProjectFiles int    `json:"project_files,omitempty"`
+	File         string `json:"file,omitempty"`
+	FileStatus   string `json:"file_status,omitempty"`
+	// FileLines is the number of lines read from File
+	FileLines      int       `json:"file_lines,omitempty"`
+	FilesFound     int       `json:"files_found"`
+	FilesDone      int       `json:"files_done"`
+	LinesProcessed int       `json:"lines_processed"`
+	Errors         int       `json:"errors"`
+	Error          string    `json:"error,omitempty"`
+	Status         string    `json:"status,omitempty"`
+	Time           time.Time `json:"time"`
+}
+
+// SyncProgressBroker fans sync progress out to subscribers, such as SSE
+// clients. Publishing never blocks the sync.
+type SyncProgressBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan SyncProgress]struct{}
+	jobID       string
+	// last is the latest event of the running job, sent to new subscribers
+	last   *SyncProgress
+	closed bool
+}
+
+func NewSyncProgressBroker() *SyncProgressBroker {
+	return &SyncProgressBroker{subscribers: make(map[chan SyncProgress]struct{})}
+}
+
+// Subscribe returns a channel of progress events and the latest event of the
+// running job, if any. The channel is closed when cancel is called or when
+// the subscriber falls too far behind.
+func (b *SyncProgressBroker) Subscribe() (<-chan SyncProgress, *SyncProgress, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan SyncProgress, syncProgressBuffer)
+	if b.closed {
+		close(events)
+		return events, nil, func() {}
+	}
+	b.subscribers[events] = struct{}{}
+	var current *SyncProgress
+	if b.last != nil {
+		last := *b.last
+		current = &last
+	}
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[events]; ok {
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+	return events, current, cancel
+}
+
+// Close ends every subscription and refuses new ones, so streams finish when
+// the server shuts down
+func (b *SyncProgressBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for events := range b.subscribers {
+		delete(b.subscribers, events)
+		close(events)
+	}
+}
+
+// Subscribers returns the number of subscribers
+func (b *SyncProgressBroker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Publish sends an event of the running job to every subscriber
+func (b *SyncProgressBroker) Publish(p SyncProgress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p.JobID = b.jobID
+	if p.Time.IsZero() {
+		p.Time = time.Now().UTC()
+	}
+	b.last = &p
+	for events := range b.subscribers {
+		select {
+		case events <- p:
+		default:
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// JobStarted publishes the start of a job; register it with
+// SyncJobQueue.OnStarted
+func (b *SyncProgressBroker) JobStarted(job SyncJob) {
+	b.mu.Lock()
+	b.jobID = job.ID
+	b.mu.Unlock()
+	b.Publish(SyncProgress{Type: SyncProgressStarted, Status: job.Status})
+}
+
+// JobFinished publishes the outcome of a job; register it with
+// SyncJobQueue.OnFinished
+func (b *SyncProgressBroker) JobFinished(job SyncJob) {
+	p := SyncProgress{Type: SyncProgressFinished, Status: job.Status, Error: job.Error}
+	if job.Stats != nil {
+		p.FilesFound = job.Stats.TotalFiles
+		p.FilesDone = job.Stats.ProcessedFiles + job.Stats.SkippedFiles + job.Stats.FailedFiles
+		p.LinesProcessed = job.Stats.NewLines
+		p.Errors = job.Stats.FailedFiles + job.Stats.ParseErrors
+	}
+	b.mu.Lock()
+	b.jobID = job.ID
+	b.mu.Unlock()
+	b.Publish(p)
+
+	b.mu.Lock()
+	b.jobID = ""
+	b.last = nil
+	b.mu.Unlock()
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"claudeee-backend/internal/models"
+)
+
+func TestSyncProgressBroker(t *testing.T) {
+	broker := NewSyncProgressBroker()
+	events, current, cancel := broker.Subscribe()
+	defer cancel()
+	if current != nil {
+		t.Errorf("Expected no current event while idle, got %+v", current)
+	}
+
+	broker.JobStarted(SyncJob{ID: "job-1", Status: SyncJobRunning})
+	broker.Publish(SyncProgress{Type: SyncProgressFile, File: "a.jsonl", FilesFound: 2, FilesDone: 1})
+	if e := <-events; e.Type != SyncProgressStarted || e.JobID != "job-1" {
+		t.Errorf("Expected started event for job-1, got %+v", e)
+	}
+	if e := <-events; e.Type != SyncProgressFile || e.JobID != "job-1" || e.Time.IsZero() {
+		t.Errorf("Expected stamped file event, got %+v", e)
+	}
+
+	// Late subscribers see where the running job is
+	_, current, cancelLate := broker.Subscribe()
+	cancelLate()
+	if current == nil || current.File != "a.jsonl" {
+		t.Errorf("Expected the latest file event, got %+v", current)
+	}
+
+	broker.JobFinished(SyncJob{ID: "job-1", Status: SyncJobCompleted, Stats: &models.SyncStats{TotalFiles: 2, ProcessedFiles: 1, FailedFiles: 1, ParseErrors: 3}})
+	e := <-events
+	if e.Type != SyncProgressFinished || e.FilesDone != 2 || e.Errors != 4 || e.Status != SyncJobCompleted {
+		t.Errorf("Unexpected finished event: %+v", e)
+	}
+	if _, current, cancel := broker.Subscribe(); current != nil {
+		t.Errorf("Expected no current event after the job, got %+v", current)
+		cancel()
+	} else {
+		cancel()
+	}
+
+	// A subscriber that stops reading is dropped instead of blocking the sync
+	for i := 0; i <= syncProgressBuffer; i++ {
+		broker.Publish(SyncProgress{Type: SyncProgressFile})
+	}
+	drained := 0
+	for range events {
+		drained++
+	}
+	if drained != syncProgressBuffer || broker.Subscribers() != 0 {
+		t.Errorf("Expected the slow subscriber to be dropped after %d events, got %d (%d left)", syncProgressBuffer, drained, broker.Subscribers())
+	}
+}
+
+func TestSyncAllLogsReportsProgress(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+	addSessionWindowTables(t, db)
+
+	root := t.TempDir()
+	line := func(id string) string {
+		return `{"uuid":"` + id + `","sessionId":"session1","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:00:00Z","message":{"role":"assistant","content":"a","usage":{"input_tokens":10,"output_tokens":5}}}` + "\n"
+	}
+	files := map[string]string{
+		"-work-alpha/one.jsonl":  line("m1") + "not json\n",
+		"-work-alpha/two.jsonl":  line("m2"),
+		"-work-beta/three.jsonl": line("m3"),
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	diffSyncService.SetLogSources(LogSourceConfig{Roots: []string{root}})
+
+	var events []SyncProgress
+	diffSyncService.SetProgress(func(p SyncProgress) { events = append(events, p) })
+	if _, err := diffSyncService.SyncAllLogs(context.Background()); err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	want := []string{SyncProgressDiscovered, SyncProgressProject, SyncProgressFile, SyncProgressFile, SyncProgressProject, SyncProgressFile}
+	if len(types) != len(want) {
+		t.Fatalf("Expected events %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("Expected events %v, got %v", want, types)
+		}
+	}
+	if events[0].FilesFound != 3 || events[1].Project != "-work-alpha" || events[1].ProjectFiles != 2 || events[4].Project != "-work-beta" {
+		t.Errorf("Unexpected discovery and project events: %+v", events[:5])
+	}
+	last := events[len(events)-1]
+	if last.FileStatus != SyncFileProcessed || last.FilesDone != 3 || last.Errors != 1 {
+		t.Errorf("Unexpected last file event: %+v", last)
+	}
+}
+
+func TestSyncProgressBrokerClose(t *testing.T) {
+	broker := NewSyncProgressBroker()
+	events, _, cancel := broker.Subscribe()
+	defer cancel()
+	broker.Close()
+	if _, ok := <-events; ok {
+		t.Error("Expected Close to end the subscription")
+	}
+	late, _, _ := broker.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("Expected subscriptions after Close to be ended")
+	}
+	broker.Publish(SyncProgress{Type: SyncProgressFile})
+}
@@ -0,0 +1,66 @@
+package services
+
+import "fmt"
+
+// syncedTables hold what a sync derives from the logs, referencing tables
+// before those they reference. Sessions are cleared separately.
+var syncedTables = []string{
+	"tool_calls",
+	"limit_events",
+	"duplicate_messages",
+	"message_usage_keys",
+	"parse_errors",
+	"session_sources",
+	"redaction_stats",
+	"file_sync_state",
+	"messages",
+	"session_windows",
+}
+
+// ResetSyncedData removes everything read from the logs: sessions, messages,
+// windows and the sync state of every file, so the next pass rebuilds them
+// from scratch. Tags, notes, budgets, tasks and other data entered through
+// the API are kept, and the next pass assigns sessions to users again from
+// their log roots. Sessions whose log files were deleted, or that only an
+// agent pushed, are gone afterwards. It fails while another process syncs.
+//
+// Everything but the sessions is cleared in one transaction. DuckDB checks
+// foreign keys against committed rows, so sessions can only be deleted once
+// the deletion of their messages is committed; if that second step fails,
+// the sessions are left without messages and the next pass recomputes their
+// totals.
+func (d *DiffSyncService) ResetSyncedData() error {
+	release, err := acquireSyncLease(d.db, d.writes, "")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = d.writes.Do(func() error {
+		tx, err := d.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		for _, table := range syncedTables {
+			if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+				return fmt.Errorf("failed to clear %s: %w", table, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit reset: %w", err)
+		}
+
+		if _, err := d.db.Exec(`DELETE FROM sessions`); err != nil {
+			return fmt.Errorf("failed to clear sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	d.windowService.InvalidateCache()
+	d.knownIDs = nil
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestResyncKeepsTotals(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "-work-app")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lines := []string{
+		`{"uuid":"u-1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"hi"}}`,
+		`{"uuid":"u-2","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:01:00Z","message":{"role":"assistant","content":"hello","usage":{"input_tokens":100,"output_tokens":50}}}`,
+		`{"sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:02:00Z","message":{"role":"assistant","content":"no uuid","usage":{"input_tokens":10,"output_tokens":5}}}`,
+	}
+	logFile := filepath.Join(project, "s1.jsonl")
+	if err := os.WriteFile(logFile, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	sessions := NewSessionService(db)
+	diffSync := NewDiffSyncService(db, NewTokenService(db), sessions)
+	diffSync.SetLogSources(LogSourceConfig{Roots: []string{root}})
+	sync := func() {
+		t.Helper()
+		if _, err := diffSync.SyncAllLogs(context.Background()); err != nil {
+			t.Fatalf("SyncAllLogs failed: %v", err)
+		}
+	}
+	type totals struct{ messages, sessionTokens, windowTokens int }
+	current := func() totals {
+		t.Helper()
+		var got totals
+		if err := db.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM messages), COALESCE((SELECT total_tokens FROM sessions WHERE id = 's1'), 0),
+				(SELECT COALESCE(SUM(total_tokens), 0) FROM session_windows)
+		`).Scan(&got.messages, &got.sessionTokens, &got.windowTokens); err != nil {
+			t.Fatalf("Failed to read totals: %v", err)
+		}
+		return got
+	}
+
+	sync()
+	want := totals{messages: 3, sessionTokens: 165, windowTokens: 165}
+	if got := current(); got != want {
+		t.Fatalf("Expected %+v after the first sync, got %+v", want, got)
+	}
+	var hash sql.NullString
+	if err := db.QueryRow(`SELECT content_hash FROM messages WHERE id = 'u-1'`).Scan(&hash); err != nil || len(hash.String) != 64 {
+		t.Errorf("Expected a content hash, got %q, %v", hash.String, err)
+	}
+
+	// Reading the whole file again, as after its state is lost, adds nothing
+	if err := diffSync.stateManager.ResetFileState(logFile); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := diffSync.SyncAllLogs(context.Background())
+	if err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+	if stats.DuplicateLines != 3 {
+		t.Errorf("Expected every line to be a duplicate, got %+v", stats)
+	}
+	if got := current(); got != want {
+		t.Errorf("Expected %+v after reading the file again, got %+v", want, got)
+	}
+
+	// An agent pushing the same lines overlaps the local sync
+	var entries []IngestEntry
+	for _, line := range lines {
+		entry := IngestEntry{Project: "-work-app"}
+		if err := json.Unmarshal([]byte(line), &entry.Entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	result, err := diffSync.Ingest(entries, "")
+	if err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if result.Duplicates != 3 || result.Ingested != 0 {
+		t.Errorf("Expected the pushed lines to be duplicates, got %+v", result)
+	}
+	if got := current(); got != want {
+		t.Errorf("Expected %+v after an overlapping push, got %+v", want, got)
+	}
+
+	// A forced re-sync rebuilds the same totals and keeps tags and notes
+	notes := "keep me"
+	if err := sessions.UpdateSession("s1", SessionUpdate{Notes: &notes, Tags: &[]string{"keep"}}); err != nil {
+		t.Fatalf("Failed to annotate session: %v", err)
+	}
+	if err := diffSync.ResetSyncedData(); err != nil {
+		t.Fatalf("ResetSyncedData failed: %v", err)
+	}
+	if got := current(); got.messages != 0 {
+		t.Errorf("Expected no messages after a reset, got %+v", got)
+	}
+	sync()
+	if got := current(); got != want {
+		t.Errorf("Expected %+v after a forced re-sync, got %+v", want, got)
+	}
+	var tags int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM session_tags WHERE session_id = 's1'`).Scan(&tags); err != nil || tags != 1 {
+		t.Errorf("Expected the session's tag to be kept, got %d, %v", tags, err)
+	}
+	session, err := sessions.GetSessionByID("s1")
+	if err != nil || session.Notes == nil || *session.Notes != notes {
+		t.Errorf("Expected the session's notes to be kept, got %+v, %v", session, err)
+	}
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerNoInterval is returned by Start while the sync interval is 0
+var ErrSchedulerNoInterval = errors.New("sync interval is 0; set sync_interval_minutes first")
+
+// SchedulerStatus describes the sync scheduler for the API
+type SchedulerStatus struct {
+	Running         bool       `json:"running"`
+	Paused          bool       `json:"paused"`
+	IntervalMinutes int        `json:"interval_minutes"`
+	NextRun         *time.Time `json:"next_run"`
+	LastRun         *time.Time `json:"last_run"`
+	// LastJob is the sync job of the last run, once it has finished
+	LastJob *SyncJob `json:"last_job"`
+	Runs    int      `json:"runs"`
+}
+
+// SyncScheduler queues a sync every interval while the server runs, so the
+// dashboard stays current even when the log watcher is off. It runs whenever
+// the interval is positive unless it was paused through Stop.
+type SyncScheduler struct {
+	syncJobs *SyncJobQueue
+
+	mu        sync.Mutex
+	interval  time.Duration
+	paused    bool
+	stop      chan struct{}
+	done      chan struct{}
+	nextRun   time.Time
+	lastRun   time.Time
+	lastJobID string
+	lastJob   *SyncJob
+	runs      int
+}
+
+// NewSyncScheduler creates a scheduler that is idle until SetInterval gives it
+// a positive interval
+func NewSyncScheduler(syncJobs *SyncJobQueue) *SyncScheduler {
+	return &SyncScheduler{syncJobs: syncJobs}
+}
+
+// SetInterval changes the time between runs, restarting the countdown. An
+// interval of 0 stops scheduling until a positive one is set.
+func (s *SyncScheduler) SetInterval(interval time.Duration) {
+	s.mu.Lock()
+	if interval == s.interval && (s.stop != nil || s.paused) {
+		s.mu.Unlock()
+		return
+	}
+	s.interval = interval
+	done := s.halt()
+	if !s.paused && interval > 0 {
+		s.launch()
+	}
+	s.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// Start resumes scheduling after Stop
+func (s *SyncScheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.interval <= 0 {
+		return ErrSchedulerNoInterval
+	}
+	s.paused = false
+	if s.stop == nil {
+		s.launch()
+	}
+	return nil
+}
+
+// Stop pauses scheduling until Start; syncs already queued still run
+func (s *SyncScheduler) Stop() {
+	s.mu.Lock()
+	s.paused = true
+	done := s.halt()
+	s.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// JobFinished records the outcome of the scheduler's last job; register it
+// with SyncJobQueue.OnFinished
+func (s *SyncScheduler) JobFinished(job SyncJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job.ID == s.lastJobID {
+		s.lastJob = &job
+	}
+}
+
+// Status reports whether the scheduler runs and how its last run went
+func (s *SyncScheduler) Status() SchedulerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := SchedulerStatus{
+		Running:         s.stop != nil,
+		Paused:          s.paused,
+		IntervalMinutes: int(s.interval / time.Minute),
+		LastJob:         s.lastJob,
+		Runs:            s.runs,
+	}
+	if status.Running {
+		t := s.nextRun
+		status.NextRun = &t
+	}
+	if !s.lastRun.IsZero() {
+		t := s.lastRun
+		status.LastRun = &t
+	}
+	return status
+}
+
+// launch starts the run loop. Caller holds s.mu.
+func (s *SyncScheduler) launch() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.nextRun = time.Now().Add(s.interval)
+	go s.run(s.interval, s.stop, s.done)
+}
+
+// halt signals the run loop to end and returns a channel closed once it has.
+// Caller holds s.mu and waits on the channel after releasing it.
+func (s *SyncScheduler) halt() chan struct{} {
+	if s.stop == nil {
+		return nil
+	}
+	close(s.stop)
+	done := s.done
+	s.stop, s.done = nil, nil
+	return done
+}
+
+func (s *SyncScheduler) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			job, _ := s.syncJobs.Enqueue("scheduler")
+			s.mu.Lock()
+			s.lastRun = time.Now()
+			s.lastJobID = job.ID
+			s.runs++
+			s.nextRun = s.lastRun.Add(interval)
+			s.mu.Unlock()
+			timer.Reset(interval)
+		}
+	}
+}
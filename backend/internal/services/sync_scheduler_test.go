@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+func TestSyncSchedulerRunsEveryInterval(t *testing.T) {
+	var runs atomic.Int32
+	queue := NewSyncJobQueue(func(context.Context) (*models.SyncStats, error) {
+		runs.Add(1)
+		return &models.SyncStats{}, nil
+	})
+	defer queue.Shutdown(context.Background())
+	scheduler := NewSyncScheduler(queue)
+	queue.OnFinished(scheduler.JobFinished)
+	defer scheduler.Stop()
+
+	if err := scheduler.Start(); !errors.Is(err, ErrSchedulerNoInterval) {
+		t.Errorf("Expected ErrSchedulerNoInterval without an interval, got %v", err)
+	}
+	if scheduler.Status().Running {
+		t.Error("Expected the scheduler to be idle without an interval")
+	}
+
+	scheduler.SetInterval(100 * time.Millisecond)
+	if status := scheduler.Status(); !status.Running || status.NextRun == nil {
+		t.Fatalf("Expected a running scheduler with a next run, got %+v", status)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for (scheduler.Status().Runs < 2 || scheduler.Status().LastJob == nil) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	status := scheduler.Status()
+	if status.Runs < 2 || status.LastRun == nil {
+		t.Fatalf("Expected repeated runs, got %+v", status)
+	}
+	if status.LastJob == nil || status.LastJob.Trigger != "scheduler" || status.LastJob.Status != SyncJobCompleted {
+		t.Errorf("Expected the last scheduled job to have completed, got %+v", status.LastJob)
+	}
+
+	// Paused, it stays paused when the interval changes
+	scheduler.Stop()
+	scheduler.SetInterval(50 * time.Millisecond)
+	before := scheduler.Status().Runs
+	time.Sleep(200 * time.Millisecond)
+	if status := scheduler.Status(); status.Running || !status.Paused || status.Runs != before {
+		t.Errorf("Expected a paused scheduler, got %+v", status)
+	}
+
+	if err := scheduler.Start(); err != nil {
+		t.Fatalf("Failed to resume scheduler: %v", err)
+	}
+	if status := scheduler.Status(); !status.Running || status.Paused {
+		t.Errorf("Expected a running scheduler after Start, got %+v", status)
+	}
+
+	// An interval of 0 turns it off
+	scheduler.SetInterval(0)
+	if status := scheduler.Status(); status.Running || status.NextRun != nil {
+		t.Errorf("Expected no scheduling at interval 0, got %+v", status)
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+)
+
+var (
+	// ErrTaskNotQueued is returned when running a task that is not queued
+	ErrTaskNotQueued = errors.New("task is not queued")
+	// ErrTaskNotRunning is returned when cancelling a task that is not running
+	ErrTaskNotRunning = errors.New("task is not running")
+	// ErrInsufficientCapacity is returned when a task's estimate exceeds the
+	// tokens left in the current window
+	ErrInsufficientCapacity = errors.New("not enough tokens left in the current window")
+)
+
+// maxTaskErrorLength bounds the error text kept with a failed run
+const maxTaskErrorLength = 2000
+
+// TaskRunner runs prompt in dir and returns the Claude Code session it started
+type TaskRunner func(ctx context.Context, dir, prompt string) (sessionID string, err error)
+
+// ClaudeRunner runs a prompt with `command -p --output-format json -- <prompt>`
+// and reads the session ID from its result. The "--" keeps a prompt starting
+// with "-" from being read as a flag.
+func ClaudeRunner(command string) TaskRunner {
+	return func(ctx context.Context, dir, prompt string) (string, error) {
+		cmd := exec.CommandContext(ctx, command, "-p", "--output-format", "json", "--", prompt)
+		cmd.Dir = dir
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		runErr := cmd.Run()
+
+		var result struct {
+			SessionID string `json:"session_id"`
+			IsError   bool   `json:"is_error"`
+			Result    string `json:"result"`
+		}
+		parseErr := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &result)
+		if runErr != nil {
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				return result.SessionID, fmt.Errorf("%w: %s", runErr, message)
+			}
+			return result.SessionID, runErr
+		}
+		if parseErr != nil {
+			return "", fmt.Errorf("failed to read %s output: %w", command, parseErr)
+		}
+		if result.IsError {
+			return result.SessionID, errors.New(result.Result)
+		}
+		return result.SessionID, nil
+	}
+}
+
+// TaskExecutor runs queued tasks with Claude Code in their project
+// directories while the current window has room for their estimate
+type TaskExecutor struct {
+	tasks  *TaskService
+	usage  func() (*models.TokenUsage, error)
+	run    TaskRunner
+	writes *WriteQueue
+
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+}
+
+// NewTaskExecutor runs tasks with run; usage returns the current window's
+// token usage
+func NewTaskExecutor(tasks *TaskService, usage func() (*models.TokenUsage, error), run TaskRunner, writes *WriteQueue) *TaskExecutor {
+	ctx, stop := context.WithCancel(context.Background())
+	return &TaskExecutor{
+		tasks:   tasks,
+		usage:   usage,
+		run:     run,
+		writes:  writes,
+		ctx:     ctx,
+		stop:    stop,
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+// RecoverInterrupted fails the tasks left running when the server stopped,
+// since their runs are gone
+func (e *TaskExecutor) RecoverInterrupted() error {
+	return e.writes.Do(func() error {
+		_, err := e.tasks.db.Exec(`
+			UPDATE tasks SET status = ?, error = ?, finished_at = ?, updated_at = ?
+			WHERE status = ?
+		`, TaskStatusFailed, "interrupted by a server restart", time.Now().UTC(), time.Now().UTC(), TaskStatusRunning)
+		if err != nil {
+			return fmt.Errorf("failed to recover interrupted tasks: %w", err)
+		}
+		return nil
+	})
+}
+
+// Start runs a queued task in the background and returns it as running. The
+// task needs a prompt and an existing project directory, and its estimate
+// must fit in the tokens left in the current window.
+func (e *TaskExecutor) Start(id string) (*Task, error) {
+	task, err := e.tasks.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status != TaskStatusQueued {
+		return nil, fmt.Errorf("%w: it is %s", ErrTaskNotQueued, task.Status)
+	}
+	if strings.TrimSpace(task.Prompt) == "" {
+		return nil, fmt.Errorf("%w: a prompt is required to run it", ErrInvalidTask)
+	}
+	if info, err := os.Stat(task.ProjectPath); task.ProjectPath == "" || err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w: project_path %q is not a directory", ErrInvalidTask, task.ProjectPath)
+	}
+
+	usage, err := e.usage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token usage: %w", err)
+	}
+	if remaining := int64(usage.UsageLimit - usage.TotalTokens); task.EstimatedTokens > remaining {
+		return nil, fmt.Errorf("%w: the task needs %d, %d are left until %s", ErrInsufficientCapacity,
+			task.EstimatedTokens, max(remaining, 0), usage.WindowEnd.Format(time.RFC3339))
+	}
+
+	now := time.Now().UTC()
+	err = e.writes.Do(func() error {
+		result, err := e.tasks.db.Exec(`
+			UPDATE tasks SET status = ?, session_id = NULL, error = NULL, started_at = ?, finished_at = NULL, updated_at = ?
+			WHERE id = ? AND status = ?
+		`, TaskStatusRunning, now, now, id, TaskStatusQueued)
+		if err != nil {
+			return fmt.Errorf("failed to start task: %w", err)
+		}
+		// Another request started it first
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			return ErrTaskNotQueued
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+	e.mu.Lock()
+	e.running[id] = cancel
+	e.mu.Unlock()
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer cancel()
+		sessionID, err := e.run(ctx, task.ProjectPath, task.Prompt)
+		e.mu.Lock()
+		delete(e.running, id)
+		e.mu.Unlock()
+		e.finish(ctx, id, sessionID, err)
+	}()
+
+	task.Status = TaskStatusRunning
+	task.SessionID = ""
+	task.Error = ""
+	task.StartedAt = &now
+	task.FinishedAt = nil
+	task.UpdatedAt = now
+	return task, nil
+}
+
+// finish records how a run ended
+func (e *TaskExecutor) finish(ctx context.Context, id, sessionID string, runErr error) {
+	status, message := TaskStatusCompleted, ""
+	switch {
+	case ctx.Err() != nil:
+		status, message = TaskStatusCancelled, "cancelled"
+		if e.ctx.Err() != nil {
+			status, message = TaskStatusFailed, "interrupted by a server shutdown"
+		}
+	case runErr != nil:
+		status, message = TaskStatusFailed, runErr.Error()
+		if runes := []rune(message); len(runes) > maxTaskErrorLength {
+			message = string(runes[:maxTaskErrorLength])
+		}
+	}
+
+	now := time.Now().UTC()
+	err := e.writes.Do(func() error {
+		_, err := e.tasks.db.Exec(`
+			UPDATE tasks SET status = ?, session_id = ?, error = ?, finished_at = ?, updated_at = ?
+			WHERE id = ?
+		`, status, nullableString(sessionID), nullableString(message), now, now, id)
+		return err
+	})
+	if err != nil {
+		logging.Component("tasks").Error("Failed to record task run", "task", id, "err", err)
+		return
+	}
+	logging.Component("tasks").Info("Task run finished", "task", id, "status", status, "session", sessionID)
+}
+
+// Cancel stops a running task
+func (e *TaskExecutor) Cancel(id string) error {
+	e.mu.Lock()
+	cancel, ok := e.running[id]
+	e.mu.Unlock()
+	if !ok {
+		return ErrTaskNotRunning
+	}
+	cancel()
+	return nil
+}
+
+// Stop cancels the running tasks and waits for their runs to be recorded
+func (e *TaskExecutor) Stop() {
+	e.stop()
+	e.wg.Wait()
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// waitForTask polls until the task leaves the running status
+func waitForTask(t *testing.T, tasks *TaskService, id string) *Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		task, err := tasks.Get(id)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if task.Status != TaskStatusRunning {
+			return task
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Task %s is still running", id)
+	return nil
+}
+
+func TestTaskExecutor(t *testing.T) {
+	tasks := setupTaskTest(t)
+	dir := t.TempDir()
+	usage := func() (*models.TokenUsage, error) {
+		return &models.TokenUsage{UsageLimit: 1000, TotalTokens: 600}, nil
+	}
+	release := make(chan struct{})
+	var ranIn, ranPrompt string
+	run := func(ctx context.Context, dir, prompt string) (string, error) {
+		if prompt == "block" {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		ranIn, ranPrompt = dir, prompt
+		return "session-1", nil
+	}
+	executor := NewTaskExecutor(tasks, usage, run, nil)
+	defer executor.Stop()
+
+	big, _ := tasks.Create(Task{Title: "big", Prompt: "refactor", ProjectPath: dir, EstimatedTokens: 500})
+	if _, err := executor.Start(big.ID); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("Expected ErrInsufficientCapacity, got %v", err)
+	}
+	noDir, _ := tasks.Create(Task{Title: "no dir", Prompt: "x", ProjectPath: filepath.Join(dir, "missing")})
+	if _, err := executor.Start(noDir.ID); !errors.Is(err, ErrInvalidTask) {
+		t.Errorf("Expected ErrInvalidTask for a missing directory, got %v", err)
+	}
+
+	small, _ := tasks.Create(Task{Title: "small", Prompt: "fix typo", ProjectPath: dir, EstimatedTokens: 100})
+	started, err := executor.Start(small.ID)
+	if err != nil || started.Status != TaskStatusRunning || started.StartedAt == nil {
+		t.Fatalf("Expected the task to start, got %+v (%v)", started, err)
+	}
+	done := waitForTask(t, tasks, small.ID)
+	if done.Status != TaskStatusCompleted || done.SessionID != "session-1" || done.FinishedAt == nil || ranIn != dir || ranPrompt != "fix typo" {
+		t.Errorf("Expected a completed run linked to its session, got %+v", done)
+	}
+	if _, err := executor.Start(small.ID); !errors.Is(err, ErrTaskNotQueued) {
+		t.Errorf("Expected ErrTaskNotQueued for a completed task, got %v", err)
+	}
+
+	blocked, _ := tasks.Create(Task{Title: "blocked", Prompt: "block", ProjectPath: dir})
+	if _, err := executor.Start(blocked.ID); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := executor.Cancel(blocked.ID); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if cancelled := waitForTask(t, tasks, blocked.ID); cancelled.Status != TaskStatusCancelled {
+		t.Errorf("Expected the task to be cancelled, got %+v", cancelled)
+	}
+	if err := executor.Cancel(blocked.ID); !errors.Is(err, ErrTaskNotRunning) {
+		t.Errorf("Expected ErrTaskNotRunning, got %v", err)
+	}
+}
+
+func TestClaudeRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of claude")
+	}
+	dir := t.TempDir()
+	claude := filepath.Join(dir, "claude")
+	script := `#!/bin/sh
+[ "$1" = "-p" ] && [ "$4" = "--" ] || exit 2
+echo '{"type":"result","is_error":false,"result":"done","session_id":"'"$(basename "$PWD")"'"}'
+`
+	if err := os.WriteFile(claude, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Join(dir, "proj")
+	if err := os.Mkdir(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	sessionID, err := ClaudeRunner(claude)(context.Background(), project, "hello")
+	if err != nil || sessionID != "proj" {
+		t.Errorf("Expected the session ID from the output, got %q (%v)", sessionID, err)
+	}
+	// A prompt that looks like a flag still reaches Claude as the prompt
+	if sessionID, err := ClaudeRunner(claude)(context.Background(), project, "--help"); err != nil || sessionID != "proj" {
+		t.Errorf("Expected a prompt starting with - to run, got %q (%v)", sessionID, err)
+	}
+	if _, err := ClaudeRunner(filepath.Join(dir, "missing"))(context.Background(), project, "hello"); err == nil {
+		t.Error("Expected an error for a missing command")
+	}
+}
@@ -0,0 +1,315 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/models"
+	"github.com/google/uuid"
+)
+
+// Task priorities, scheduled in this order
+const (
+	TaskPriorityHigh   = "high"
+	TaskPriorityMedium = "medium"
+	TaskPriorityLow    = "low"
+)
+
+// Task statuses
+const (
+	// TaskStatusQueued tasks wait to be scheduled
+	TaskStatusQueued    = "queued"
+	TaskStatusRunning   = "running"
+	TaskStatusCompleted = "completed"
+	TaskStatusFailed    = "failed"
+	TaskStatusCancelled = "cancelled"
+)
+
+var taskPriorityRank = map[string]int{TaskPriorityHigh: 0, TaskPriorityMedium: 1, TaskPriorityLow: 2}
+
+var taskStatuses = []string{TaskStatusQueued, TaskStatusRunning, TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled}
+
+var (
+	// ErrInvalidTask is returned for a task that fails validation
+	ErrInvalidTask = errors.New("invalid task")
+	// ErrTaskNotFound is returned for an unknown task id
+	ErrTaskNotFound = errors.New("task not found")
+)
+
+// Task is work to run with Claude Code, with the tokens it is expected to use
+type Task struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Prompt string `json:"prompt"`
+	// ProjectPath is the directory the task runs in
+	ProjectPath     string `json:"project_path"`
+	EstimatedTokens int64  `json:"estimated_tokens"`
+	Priority        string `json:"priority"`
+	Status          string `json:"status"`
+	// SessionID is the Claude Code session the last run started
+	SessionID  string     `json:"session_id"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	// Error is why the last run failed
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsValidTaskStatus reports whether status is a known task status
+func IsValidTaskStatus(status string) bool {
+	for _, s := range taskStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate fills in the default priority and status and checks the fields
+func (t *Task) Validate() error {
+	t.Title = strings.TrimSpace(t.Title)
+	if t.Title == "" {
+		return fmt.Errorf("%w: title is required", ErrInvalidTask)
+	}
+	if t.EstimatedTokens < 0 {
+		return fmt.Errorf("%w: estimated_tokens must not be negative", ErrInvalidTask)
+	}
+	if t.Priority == "" {
+		t.Priority = TaskPriorityMedium
+	}
+	if _, ok := taskPriorityRank[t.Priority]; !ok {
+		return fmt.Errorf("%w: priority must be %s, %s or %s", ErrInvalidTask, TaskPriorityHigh, TaskPriorityMedium, TaskPriorityLow)
+	}
+	if t.Status == "" {
+		t.Status = TaskStatusQueued
+	}
+	if !IsValidTaskStatus(t.Status) {
+		return fmt.Errorf("%w: status must be one of %s", ErrInvalidTask, strings.Join(taskStatuses, ", "))
+	}
+	return nil
+}
+
+// ScheduledTask is a queued task with the window it is expected to fit in
+type ScheduledTask struct {
+	Task
+	// Window is 0 for the current window, 1 for the one opening at the
+	// next reset and so on
+	Window int `json:"window"`
+	// StartAfter is when a later window opens at the earliest; nil for
+	// tasks that can run now
+	StartAfter *time.Time `json:"start_after"`
+}
+
+// TaskSchedule splits the queued tasks into those that fit in the remaining
+// capacity of the current window and those that wait for a reset
+type TaskSchedule struct {
+	UsageLimit      int       `json:"usage_limit"`
+	UsedTokens      int       `json:"used_tokens"`
+	RemainingTokens int       `json:"remaining_tokens"`
+	ResetTime       time.Time `json:"reset_time"`
+	// ScheduledTokens is the estimated usage of the tasks run now
+	ScheduledTokens int64           `json:"scheduled_tokens"`
+	RunNow          []ScheduledTask `json:"run_now"`
+	Deferred        []ScheduledTask `json:"deferred"`
+	// TooLarge are estimated to need more than a whole window
+	TooLarge []Task `json:"too_large"`
+}
+
+// TaskService manages tasks and schedules them against window capacity
+type TaskService struct {
+	db *sql.DB
+}
+
+func NewTaskService(db *sql.DB) *TaskService {
+	return &TaskService{db: db}
+}
+
+// nullableString stores an empty string as NULL
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// Create validates and stores a new task
+func (s *TaskService) Create(t Task) (*Task, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	t.ID = uuid.New().String()
+	t.CreatedAt = now
+	t.UpdatedAt = now
+	_, err := s.db.Exec(`
+		INSERT INTO tasks (id, title, prompt, project_path, estimated_tokens, priority, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.ID, t.Title, nullableString(t.Prompt), nullableString(t.ProjectPath), t.EstimatedTokens, t.Priority, t.Status, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+	return &t, nil
+}
+
+// Update replaces every field of an existing task but those of its last run
+func (s *TaskService) Update(id string, t Task) (*Task, error) {
+	existing, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	t.ID = id
+	t.SessionID = existing.SessionID
+	t.StartedAt = existing.StartedAt
+	t.FinishedAt = existing.FinishedAt
+	t.Error = existing.Error
+	t.CreatedAt = existing.CreatedAt
+	t.UpdatedAt = time.Now().UTC()
+	_, err = s.db.Exec(`
+		UPDATE tasks
+		SET title = ?, prompt = ?, project_path = ?, estimated_tokens = ?, priority = ?, status = ?, updated_at = ?
+		WHERE id = ?
+	`, t.Title, nullableString(t.Prompt), nullableString(t.ProjectPath), t.EstimatedTokens, t.Priority, t.Status, t.UpdatedAt, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+	return &t, nil
+}
+
+// Delete removes a task
+func (s *TaskService) Delete(id string) error {
+	result, err := s.db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
+
+const taskColumns = `id, title, COALESCE(prompt, ''), COALESCE(project_path, ''), COALESCE(estimated_tokens, 0), priority, status,
+	COALESCE(session_id, ''), started_at, finished_at, COALESCE(error, ''), created_at, updated_at`
+
+func scanTask(scan func(...interface{}) error) (*Task, error) {
+	var t Task
+	var startedAt, finishedAt sql.NullTime
+	if err := scan(&t.ID, &t.Title, &t.Prompt, &t.ProjectPath, &t.EstimatedTokens, &t.Priority, &t.Status,
+		&t.SessionID, &startedAt, &finishedAt, &t.Error, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if startedAt.Valid {
+		t.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		t.FinishedAt = &finishedAt.Time
+	}
+	return &t, nil
+}
+
+// Get returns one task
+func (s *TaskService) Get(id string) (*Task, error) {
+	t, err := scanTask(s.db.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = ?`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+	return t, nil
+}
+
+// List returns the tasks with status, or every task when it is empty, by
+// priority and then in order of creation
+func (s *TaskService) List(status string) ([]Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	rows, err := s.db.Query(query+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		t, err := scanTask(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		tasks = append(tasks, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return taskPriorityRank[tasks[i].Priority] < taskPriorityRank[tasks[j].Priority]
+	})
+	return tasks, nil
+}
+
+// Schedule fits the queued tasks into usage, the current window's token
+// usage, and the windows after it
+func (s *TaskService) Schedule(usage *models.TokenUsage) (*TaskSchedule, error) {
+	tasks, err := s.List(TaskStatusQueued)
+	if err != nil {
+		return nil, err
+	}
+	return scheduleTasks(tasks, usage, currentWindowPolicy().Duration), nil
+}
+
+// scheduleTasks places tasks, already in priority order, in the first window
+// with room for their estimate: the current window's remaining tokens, then
+// whole windows of window length after each reset. A small task can so run
+// before a larger one of the same or higher priority that has to wait.
+func scheduleTasks(tasks []Task, usage *models.TokenUsage, window time.Duration) *TaskSchedule {
+	schedule := &TaskSchedule{
+		UsageLimit:      usage.UsageLimit,
+		UsedTokens:      usage.TotalTokens,
+		RemainingTokens: usage.UsageLimit - usage.TotalTokens,
+		ResetTime:       usage.WindowEnd,
+		RunNow:          []ScheduledTask{},
+		Deferred:        []ScheduledTask{},
+		TooLarge:        []Task{},
+	}
+	if schedule.RemainingTokens < 0 {
+		schedule.RemainingTokens = 0
+	}
+
+	// remaining[i] is the capacity left in window i
+	remaining := []int64{int64(schedule.RemainingTokens)}
+	for _, t := range tasks {
+		if t.EstimatedTokens > int64(usage.UsageLimit) {
+			schedule.TooLarge = append(schedule.TooLarge, t)
+			continue
+		}
+		i := 0
+		for ; i < len(remaining) && remaining[i] < t.EstimatedTokens; i++ {
+		}
+		if i == len(remaining) {
+			remaining = append(remaining, int64(usage.UsageLimit))
+		}
+		remaining[i] -= t.EstimatedTokens
+
+		scheduled := ScheduledTask{Task: t, Window: i}
+		if i == 0 {
+			schedule.ScheduledTokens += t.EstimatedTokens
+			schedule.RunNow = append(schedule.RunNow, scheduled)
+			continue
+		}
+		startAfter := usage.WindowEnd.Add(time.Duration(i-1) * window)
+		scheduled.StartAfter = &startAfter
+		schedule.Deferred = append(schedule.Deferred, scheduled)
+	}
+	return schedule
+}
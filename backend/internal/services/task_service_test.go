@@ -0,0 +1,102 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/models"
+)
+
+func setupTaskTest(t *testing.T) *TaskService {
+	t.Helper()
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return NewTaskService(db)
+}
+
+func TestTaskCRUD(t *testing.T) {
+	tasks := setupTaskTest(t)
+
+	if _, err := tasks.Create(Task{Title: "x", Priority: "urgent"}); !errors.Is(err, ErrInvalidTask) {
+		t.Errorf("Expected ErrInvalidTask for an unknown priority, got %v", err)
+	}
+	low, err := tasks.Create(Task{Title: "docs", Priority: TaskPriorityLow, EstimatedTokens: 1000})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	high, err := tasks.Create(Task{Title: " fix bug ", Prompt: "Fix the bug", ProjectPath: "/app", Priority: TaskPriorityHigh})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if high.Title != "fix bug" || high.Status != TaskStatusQueued {
+		t.Errorf("Expected a trimmed, queued task, got %+v", high)
+	}
+
+	list, err := tasks.List("")
+	if err != nil || len(list) != 2 || list[0].ID != high.ID || list[1].ID != low.ID {
+		t.Fatalf("Expected the high priority task first, got %+v (%v)", list, err)
+	}
+
+	low.Status = TaskStatusCompleted
+	if _, err := tasks.Update(low.ID, *low); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if queued, err := tasks.List(TaskStatusQueued); err != nil || len(queued) != 1 || queued[0].ID != high.ID {
+		t.Errorf("Expected only the queued task, got %+v (%v)", queued, err)
+	}
+	if _, err := tasks.Update("missing", *low); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+
+	if err := tasks.Delete(low.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := tasks.Get(low.ID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected the task to be deleted, got %v", err)
+	}
+}
+
+func TestScheduleTasks(t *testing.T) {
+	windowEnd := time.Date(2024, 1, 1, 15, 0, 0, 0, time.UTC)
+	usage := &models.TokenUsage{UsageLimit: 1000, TotalTokens: 700, WindowEnd: windowEnd}
+	task := func(id string, tokens int64) Task {
+		return Task{ID: id, EstimatedTokens: tokens}
+	}
+
+	schedule := scheduleTasks([]Task{task("a", 400), task("b", 200), task("c", 900), task("d", 700), task("e", 5000)}, usage, 5*time.Hour)
+	if schedule.RemainingTokens != 300 || schedule.ScheduledTokens != 200 {
+		t.Errorf("Expected 300 tokens left and 200 scheduled, got %+v", schedule)
+	}
+	if len(schedule.RunNow) != 1 || schedule.RunNow[0].ID != "b" || schedule.RunNow[0].StartAfter != nil {
+		t.Errorf("Expected the small task to run now, got %+v", schedule.RunNow)
+	}
+
+	// a takes 400 of the next window, c the one after it, and d fits in
+	// neither
+	want := []struct {
+		id     string
+		window int
+	}{{"a", 1}, {"c", 2}, {"d", 3}}
+	if len(schedule.Deferred) != len(want) {
+		t.Fatalf("Expected %d deferred tasks, got %+v", len(want), schedule.Deferred)
+	}
+	for i, w := range want {
+		got := schedule.Deferred[i]
+		if got.ID != w.id || got.Window != w.window || got.StartAfter == nil ||
+			!got.StartAfter.Equal(windowEnd.Add(time.Duration(w.window-1)*5*time.Hour)) {
+			t.Errorf("Expected %s in window %d, got %+v", w.id, w.window, got)
+		}
+	}
+	if len(schedule.TooLarge) != 1 || schedule.TooLarge[0].ID != "e" {
+		t.Errorf("Expected the task over the limit to be too large, got %+v", schedule.TooLarge)
+	}
+}
@@ -3,21 +3,28 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 	"time"
 	
+	"claudeee-backend/internal/logging"
 	"claudeee-backend/internal/models"
 )
 
 type TokenService struct {
 	db               *sql.DB
 	pricingCalculator *PricingCalculator
+	usageLimit       atomic.Int64
+	plan             atomic.Value
 }
 
 func NewTokenService(db *sql.DB) *TokenService {
-	return &TokenService{
+	s := &TokenService{
 		db:               db,
 		pricingCalculator: NewPricingCalculator(),
 	}
+	s.usageLimit.Store(CLAUDE_PRO_LIMIT)
+	s.plan.Store(PlanPro)
+	return s
 }
 
 const (
@@ -46,7 +53,7 @@ func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
 			UsageLimit:     s.getUsageLimit(),
 			UsageRate:      0,
 			WindowStart:    now,
-			WindowEnd:      now.Add(WINDOW_DURATION),
+			WindowEnd:      now.Add(currentWindowPolicy().Duration),
 			ActiveSessions: 0,
 			TotalCost:      0.0,
 			TotalMessages:  0,
@@ -63,7 +70,7 @@ func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
 			UsageLimit:     s.getUsageLimit(),
 			UsageRate:      0,
 			WindowStart:    now,
-			WindowEnd:      now.Add(WINDOW_DURATION),
+			WindowEnd:      now.Add(currentWindowPolicy().Duration),
 			ActiveSessions: 0,
 			TotalCost:      0.0,
 			TotalMessages:  0,
@@ -78,7 +85,7 @@ func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
 	totalCost, err := s.calculateWindowCost(currentWindow.ID)
 	if err != nil {
 		// エラーが発生した場合はログに記録してコストを0にする
-		fmt.Printf("Warning: failed to calculate window cost: %v\n", err)
+		logging.Component("tokens").Warn("Failed to calculate window cost", "window", currentWindow.ID, "err", err)
 		totalCost = 0.0
 	}
 	
@@ -97,7 +104,45 @@ func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
 }
 
 func (s *TokenService) getUsageLimit() int {
-	return CLAUDE_PRO_LIMIT
+	return int(s.usageLimit.Load())
+}
+
+// SetPlan switches the usage limit to the given plan
+func (s *TokenService) SetPlan(plan string) error {
+	limit, ok := PlanUsageLimit(plan)
+	if !ok {
+		return fmt.Errorf("unknown plan: %s", plan)
+	}
+	return s.SetPlanLimit(plan, limit)
+}
+
+// SetPlanLimit switches to a plan with an explicit token limit, as used by
+// the custom plan
+func (s *TokenService) SetPlanLimit(plan string, limit int) error {
+	if limit <= 0 {
+		return fmt.Errorf("invalid token limit for plan %s: %d", plan, limit)
+	}
+	s.usageLimit.Store(int64(limit))
+	s.plan.Store(plan)
+	return nil
+}
+
+// CurrentPlan returns the plan the usage limit is based on
+func (s *TokenService) CurrentPlan() string {
+	return s.plan.Load().(string)
+}
+
+// PlanUsageLimit returns the per-window token limit for a plan
+func PlanUsageLimit(plan string) (int, bool) {
+	switch plan {
+	case PlanPro:
+		return CLAUDE_PRO_LIMIT, true
+	case PlanMax5:
+		return CLAUDE_MAX5_LIMIT, true
+	case PlanMax20:
+		return CLAUDE_MAX20_LIMIT, true
+	}
+	return 0, false
 }
 
 // roundToNextHour は時刻を次の正時（0分）に切り上げます
@@ -165,7 +210,7 @@ func (s *TokenService) GetTokenUsageBySession(sessionID string) (*models.TokenUs
 
 func (s *TokenService) GetActiveSessionsInWindow() ([]models.Session, error) {
 	now := time.Now()
-	windowStart := now.Add(-WINDOW_DURATION)
+	windowStart := now.Add(-currentWindowPolicy().Duration)
 	
 	query := `
 		SELECT DISTINCT
@@ -245,11 +290,24 @@ func (s *TokenService) UpdateSessionTokens(sessionID string) error {
 			),
 			end_time = (
 				SELECT MAX(timestamp) FROM messages WHERE session_id = ?
-			)
-		WHERE id = ?
-	`
+			)`
+	args := []interface{}{sessionID, sessionID, sessionID, sessionID, sessionID}
+	
+	// Complete sessions whose last message is already past the idle timeout,
+	// and reopen completed ones that got new messages
+	if idle := currentSessionIdleTimeout(); idle > 0 {
+		query += `,
+			status = CASE
+				WHEN (SELECT MAX(timestamp) FROM messages WHERE session_id = ?) < ? THEN ?
+				ELSE ?
+			END`
+		args = append(args, sessionID, time.Now().Add(-idle).UTC(), SessionStatusCompleted, SessionStatusActive)
+	}
+	query += `
+		WHERE id = ?`
+	args = append(args, sessionID)
 	
-	_, err := s.db.Exec(query, sessionID, sessionID, sessionID, sessionID, sessionID, sessionID)
+	_, err := s.db.Exec(query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update session tokens: %w", err)
 	}
@@ -27,7 +27,8 @@ func setupTestDB(t *testing.T) *sql.DB {
 			total_tokens INTEGER DEFAULT 0,
 			message_count INTEGER DEFAULT 0,
 			status TEXT DEFAULT 'active',
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			title TEXT
 		);
 
 		CREATE TABLE IF NOT EXISTS messages (
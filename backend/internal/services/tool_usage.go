@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"claudeee-backend/internal/models"
+)
+
+// Tool call statuses
+const (
+	ToolCallPending = "pending"
+	ToolCallSuccess = "success"
+	ToolCallError   = "error"
+)
+
+// toolCall is a tool_use block of an assistant message
+type toolCall struct {
+	id        string
+	messageID string
+	sessionID string
+	name      string
+	inputSize int
+	calledAt  time.Time
+}
+
+// toolResult is a tool_result block that answers an earlier tool_use
+type toolResult struct {
+	toolUseID   string
+	messageID   string
+	completedAt time.Time
+	isError     bool
+}
+
+// extractToolBlocks returns the tool_use and tool_result blocks of a log
+// entry. Tool names and input sizes are metadata, so they are recorded under
+// every content policy.
+func extractToolBlocks(entry *models.LogEntry) ([]toolCall, []toolResult) {
+	blocks, ok := entry.Message.Content.([]interface{})
+	if !ok || entry.UUID == "" {
+		return nil, nil
+	}
+
+	var calls []toolCall
+	var results []toolResult
+	for _, item := range blocks {
+		block, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch block["type"] {
+		case "tool_use":
+			id, _ := block["id"].(string)
+			name, _ := block["name"].(string)
+			if id == "" || name == "" {
+				continue
+			}
+			inputSize := 0
+			if input, ok := block["input"]; ok {
+				if data, err := json.Marshal(input); err == nil {
+					inputSize = len(data)
+				}
+			}
+			calls = append(calls, toolCall{
+				id:        id,
+				messageID: entry.UUID,
+				sessionID: entry.SessionID,
+				name:      name,
+				inputSize: inputSize,
+				calledAt:  entry.Timestamp,
+			})
+		case "tool_result":
+			id, _ := block["tool_use_id"].(string)
+			if id == "" {
+				continue
+			}
+			isError, _ := block["is_error"].(bool)
+			results = append(results, toolResult{
+				toolUseID:   id,
+				messageID:   entry.UUID,
+				completedAt: entry.Timestamp,
+				isError:     isError,
+			})
+		}
+	}
+	return calls, results
+}
+
+// insertToolCalls records tool calls and completes the ones whose results
+// arrived, in a single transaction. It returns the number of new calls.
+func insertToolCalls(db *sql.DB, calls []toolCall, results []toolResult) (int64, error) {
+	if len(calls) == 0 && len(results) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	inserted, err := writeToolCalls(tx, calls, results)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit tool calls: %w", err)
+	}
+	return inserted, nil
+}
+
+// writeToolCalls is insertToolCalls within tx. Calls that are already stored
+// are kept.
+func writeToolCalls(tx *sql.Tx, calls []toolCall, results []toolResult) (int64, error) {
+	var inserted int64
+	for _, call := range calls {
+		result, err := tx.Exec(`
+			INSERT INTO tool_calls (id, message_id, session_id, tool_name, input_size, called_at, status)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING
+		`, call.id, call.messageID, call.sessionID, call.name, call.inputSize, call.calledAt, ToolCallPending)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert tool call %s: %w", call.id, err)
+		}
+		n, _ := result.RowsAffected()
+		inserted += n
+	}
+
+	for _, result := range results {
+		var calledAt time.Time
+		err := tx.QueryRow(`SELECT called_at FROM tool_calls WHERE id = ?`, result.toolUseID).Scan(&calledAt)
+		if err == sql.ErrNoRows {
+			// The call was made before tool tracking existed
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to look up tool call %s: %w", result.toolUseID, err)
+		}
+
+		status := ToolCallSuccess
+		if result.isError {
+			status = ToolCallError
+		}
+		duration := result.completedAt.Sub(calledAt).Milliseconds()
+		if duration < 0 {
+			duration = 0
+		}
+		_, err = tx.Exec(`
+			UPDATE tool_calls SET result_message_id = ?, completed_at = ?, duration_ms = ?, status = ?
+			WHERE id = ?
+		`, result.messageID, result.completedAt, duration, status, result.toolUseID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to complete tool call %s: %w", result.toolUseID, err)
+		}
+	}
+	return inserted, nil
+}
+
+// ToolUsage aggregates the calls of one tool. Tokens and cost are those of the
+// assistant messages that made the calls, split evenly between the calls of a
+// message.
+type ToolUsage struct {
+	ToolName                 string  `json:"tool_name"`
+	Calls                    int     `json:"calls"`
+	Succeeded                int     `json:"succeeded"`
+	Failed                   int     `json:"failed"`
+	Pending                  int     `json:"pending"`
+	AvgDurationMs            float64 `json:"avg_duration_ms"`
+	TotalInputBytes          int64   `json:"total_input_bytes"`
+	InputTokens              int64   `json:"input_tokens"`
+	OutputTokens             int64   `json:"output_tokens"`
+	CacheCreationInputTokens int64   `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64   `json:"cache_read_input_tokens"`
+	TotalTokens              int64   `json:"total_tokens"`
+	Cost                     float64 `json:"cost"`
+	CostShare                float64 `json:"cost_share"`
+}
+
+// ToolUsageService reports tool usage from the tool_calls table
+type ToolUsageService struct {
+	db      *sql.DB
+	pricing *PricingCalculator
+}
+
+func NewToolUsageService(db *sql.DB) *ToolUsageService {
+	return &ToolUsageService{db: db, pricing: NewPricingCalculator()}
+}
+
+// GetToolUsage returns per-tool aggregates for calls made in [since, until),
+// most expensive first. Zero times leave the range open; an empty user
+// includes every user.
+func (s *ToolUsageService) GetToolUsage(user string, since, until time.Time) ([]ToolUsage, error) {
+	var conditions []string
+	var args []interface{}
+	if user != "" {
+		conditions = append(conditions, "m.session_id IN (SELECT id FROM sessions WHERE user_id = ?)")
+		args = append(args, user)
+	}
+	if !since.IsZero() {
+		conditions = append(conditions, "t.called_at >= ?")
+		args = append(args, since)
+	}
+	if !until.IsZero() {
+		conditions = append(conditions, "t.called_at < ?")
+		args = append(args, until)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := s.db.Query(`
+		WITH per_message AS (
+			SELECT message_id, COUNT(*) AS calls FROM tool_calls GROUP BY message_id
+		)
+		SELECT
+			t.tool_name,
+			COALESCE(m.model, ''),
+			COUNT(*),
+			COUNT(*) FILTER (WHERE t.status = 'success'),
+			COUNT(*) FILTER (WHERE t.status = 'error'),
+			COALESCE(SUM(t.duration_ms), 0),
+			COUNT(t.duration_ms),
+			COALESCE(SUM(t.input_size), 0),
+			COALESCE(SUM(CAST(m.input_tokens AS DOUBLE PRECISION) / p.calls), 0),
+			COALESCE(SUM(CAST(m.output_tokens AS DOUBLE PRECISION) / p.calls), 0),
+			COALESCE(SUM(CAST(m.cache_creation_input_tokens AS DOUBLE PRECISION) / p.calls), 0),
+			COALESCE(SUM(CAST(m.cache_read_input_tokens AS DOUBLE PRECISION) / p.calls), 0)
+		FROM tool_calls t
+		JOIN per_message p ON p.message_id = t.message_id
+		LEFT JOIN messages m ON m.id = t.message_id
+		`+where+`
+		GROUP BY t.tool_name, COALESCE(m.model, '')
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tool usage: %w", err)
+	}
+	defer rows.Close()
+
+	byTool := make(map[string]*ToolUsage)
+	durations := make(map[string][2]int64)
+	var totalCost float64
+	for rows.Next() {
+		var name, model string
+		var calls, succeeded, failed int
+		var durationSum, durationCount, inputBytes int64
+		var input, output, cacheCreation, cacheRead float64
+		if err := rows.Scan(&name, &model, &calls, &succeeded, &failed, &durationSum, &durationCount,
+			&inputBytes, &input, &output, &cacheCreation, &cacheRead); err != nil {
+			return nil, fmt.Errorf("failed to scan tool usage: %w", err)
+		}
+
+		usage, ok := byTool[name]
+		if !ok {
+			usage = &ToolUsage{ToolName: name}
+			byTool[name] = usage
+		}
+		usage.Calls += calls
+		usage.Succeeded += succeeded
+		usage.Failed += failed
+		usage.TotalInputBytes += inputBytes
+		usage.InputTokens += int64(input + 0.5)
+		usage.OutputTokens += int64(output + 0.5)
+		usage.CacheCreationInputTokens += int64(cacheCreation + 0.5)
+		usage.CacheReadInputTokens += int64(cacheRead + 0.5)
+		if model != "" {
+			cost := s.pricing.CalculateCost(model, int(input+0.5), int(output+0.5), int(cacheCreation+0.5), int(cacheRead+0.5))
+			usage.Cost += cost
+			totalCost += cost
+		}
+		d := durations[name]
+		durations[name] = [2]int64{d[0] + durationSum, d[1] + durationCount}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tool usage: %w", err)
+	}
+
+	usages := make([]ToolUsage, 0, len(byTool))
+	for name, usage := range byTool {
+		usage.Pending = usage.Calls - usage.Succeeded - usage.Failed
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+		if d := durations[name]; d[1] > 0 {
+			usage.AvgDurationMs = float64(d[0]) / float64(d[1])
+		}
+		usage.Cost = roundToDecimals(usage.Cost, 6)
+		if totalCost > 0 {
+			usage.CostShare = usage.Cost / totalCost
+		}
+		usages = append(usages, *usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Cost != usages[j].Cost {
+			return usages[i].Cost > usages[j].Cost
+		}
+		return usages[i].Calls > usages[j].Calls
+	})
+	return usages, nil
+}
+
+// BackfillToolCalls re-reads every log file and records the tool calls of
+// messages synced before tool tracking existed. It returns the number of
+// calls added.
+func (d *DiffSyncService) BackfillToolCalls() (int64, error) {
+	files, err := d.discoverJSONLFiles()
+	if err != nil {
+		return 0, fmt.Errorf("failed to discover JSONL files: %w", err)
+	}
+
+	var added int64
+	for _, file := range files {
+		n, err := d.backfillToolCallsFromFile(file.Path)
+		added += n
+		if err != nil {
+			return added, fmt.Errorf("failed to backfill %s: %w", file.Path, err)
+		}
+	}
+	return added, nil
+}
+
+func (d *DiffSyncService) backfillToolCallsFromFile(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	const maxCapacity = 10 * 1024 * 1024 // 10MB
+	scanner.Buffer(make([]byte, maxCapacity), maxCapacity)
+
+	var calls []toolCall
+	var results []toolResult
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry models.LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		entryCalls, entryResults := extractToolBlocks(&entry)
+		calls = append(calls, entryCalls...)
+		results = append(results, entryResults...)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	var added int64
+	err = d.writes.Do(func() error {
+		n, err := insertToolCalls(d.db, calls, results)
+		added = n
+		return err
+	})
+	return added, err
+}
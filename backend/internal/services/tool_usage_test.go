@@ -0,0 +1,97 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestToolUsageFromSyncedLogs(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	diffSyncService := NewDiffSyncService(db, NewTokenService(db), NewSessionService(db))
+
+	// One assistant message calls two tools; its tokens are split between them
+	lines := []string{
+		`{"uuid":"a1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":1000,"output_tokens":200},"content":[{"type":"text","text":"Looking"},{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"go test ./..."}},{"type":"tool_use","id":"toolu_2","name":"Read","input":{"file_path":"/work/app/main.go"}}]}}`,
+		`{"uuid":"u1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:03Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"FAIL","is_error":true},{"type":"tool_result","tool_use_id":"toolu_2","content":"package main"}]}}`,
+		`{"uuid":"a2","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:01:00Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":500,"output_tokens":100},"content":[{"type":"tool_use","id":"toolu_3","name":"Bash","input":{"command":"go vet"}}]}}`,
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "-work-app"), 0755); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+	path := filepath.Join(root, "-work-app", "s1.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+	if _, _, err := diffSyncService.processFileFrom(path, readPosition{}); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+
+	var status string
+	var duration int64
+	if err := db.QueryRow("SELECT status, duration_ms FROM tool_calls WHERE id = 'toolu_1'").Scan(&status, &duration); err != nil {
+		t.Fatalf("Failed to read tool call: %v", err)
+	}
+	if status != ToolCallError || duration != 3000 {
+		t.Errorf("Expected failed call taking 3000ms, got %s %d", status, duration)
+	}
+
+	tools, err := NewToolUsageService(db).GetToolUsage("", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get tool usage: %v", err)
+	}
+	if len(tools) != 2 || tools[0].ToolName != "Bash" {
+		t.Fatalf("Expected Bash then Read, got %+v", tools)
+	}
+	bash, read := tools[0], tools[1]
+	if bash.Calls != 2 || bash.Failed != 1 || bash.Pending != 1 || bash.AvgDurationMs != 3000 {
+		t.Errorf("Unexpected Bash calls: %+v", bash)
+	}
+	if bash.InputTokens != 1000 || bash.OutputTokens != 200 {
+		t.Errorf("Expected half of a1 plus all of a2 for Bash, got %d in / %d out", bash.InputTokens, bash.OutputTokens)
+	}
+	if read.Calls != 1 || read.Succeeded != 1 || read.InputTokens != 500 || read.TotalInputBytes == 0 {
+		t.Errorf("Unexpected Read calls: %+v", read)
+	}
+	if bash.Cost <= read.Cost || bash.CostShare+read.CostShare < 0.999 {
+		t.Errorf("Expected Bash to cost more and shares to add up, got %+v and %+v", bash, read)
+	}
+
+	// Calls before the range are left out
+	tools, err = NewToolUsageService(db).GetToolUsage("", time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC), time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get tool usage: %v", err)
+	}
+	if len(tools) != 1 || tools[0].Calls != 1 {
+		t.Errorf("Expected only the later Bash call, got %+v", tools)
+	}
+
+	// Backfill restores calls missing from the table and keeps the others
+	if _, err := db.Exec("DELETE FROM tool_calls WHERE id = 'toolu_2'"); err != nil {
+		t.Fatalf("Failed to delete tool call: %v", err)
+	}
+	diffSyncService.SetLogSources(LogSourceConfig{Roots: []string{root}})
+	added, err := diffSyncService.BackfillToolCalls()
+	if err != nil {
+		t.Fatalf("Failed to backfill: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("Expected 1 restored call, got %d", added)
+	}
+	if err := db.QueryRow("SELECT status FROM tool_calls WHERE id = 'toolu_2'").Scan(&status); err != nil || status != ToolCallSuccess {
+		t.Errorf("Expected restored call to be completed, got %q (%v)", status, err)
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"claudeee-backend/internal/models"
+)
+
+// ErrSessionNotFound is returned for a session that does not exist
+var ErrSessionNotFound = errors.New("session not found")
+
+// Transcript message kinds
+const (
+	// MessageKindPrompt is a user message typed by the user
+	MessageKindPrompt = "prompt"
+	// MessageKindToolResult is a user message carrying tool results
+	MessageKindToolResult = "tool_result"
+	// MessageKindResponse is an assistant message without tool calls
+	MessageKindResponse = "response"
+	// MessageKindToolUse is an assistant message calling tools
+	MessageKindToolUse = "tool_use"
+)
+
+// Transcript page sizes
+const (
+	DefaultTranscriptPageSize = 20
+	MaxTranscriptPageSize     = 100
+)
+
+// TranscriptToolCall is a tool call made by, or answered in, a message
+type TranscriptToolCall struct {
+	ID         string `json:"id"`
+	ToolName   string `json:"tool_name"`
+	Status     string `json:"status"`
+	DurationMs *int64 `json:"duration_ms"`
+	InputSize  int    `json:"input_size"`
+}
+
+// TranscriptMessage is a message in conversation order
+type TranscriptMessage struct {
+	models.Message
+	Kind string `json:"kind"`
+	// Branch marks a second or later reply to the same parent, as when a
+	// prompt is edited and sent again
+	Branch      bool                 `json:"branch"`
+	ToolCalls   []TranscriptToolCall `json:"tool_calls,omitempty"`
+	ToolResults []TranscriptToolCall `json:"tool_results,omitempty"`
+	// Sidechain holds the messages of the subagents started from this message
+	Sidechain []TranscriptMessage `json:"sidechain,omitempty"`
+}
+
+// Transcript is one page of a session's conversation. Pages count main
+// conversation messages; sidechains come nested in the message they start from.
+type Transcript struct {
+	SessionID   string              `json:"session_id"`
+	Messages    []TranscriptMessage `json:"messages"`
+	Total       int                 `json:"total"`
+	Page        int                 `json:"page"`
+	PageSize    int                 `json:"page_size"`
+	TotalPages  int                 `json:"total_pages"`
+	HasNext     bool                `json:"has_next"`
+	HasPrevious bool                `json:"has_previous"`
+}
+
+// GetTranscript returns a page of a session's conversation. Messages follow
+// the parent_uuid thread, depth first with replies in time order, so a
+// conversation reads top to bottom even when it branched.
+func (s *SessionService) GetTranscript(sessionID string, page, pageSize int) (*Transcript, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > MaxTranscriptPageSize {
+		pageSize = DefaultTranscriptPageSize
+	}
+
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sessions WHERE id = ?)`, sessionID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+
+	messages, err := s.GetSessionMessages(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	calls, results, err := s.sessionToolCalls(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var main, side []*TranscriptMessage
+	for _, message := range messages {
+		tm := &TranscriptMessage{Message: message, ToolCalls: calls[message.ID], ToolResults: results[message.ID]}
+		tm.Kind = messageKind(tm)
+		if message.IsSidechain {
+			side = append(side, tm)
+		} else {
+			main = append(main, tm)
+		}
+	}
+
+	var thread []*TranscriptMessage
+	for _, group := range threadMessages(main) {
+		thread = append(thread, group...)
+	}
+	// A subagent's first message has no parent in the main thread, so it is
+	// nested under the last main message before it, normally the Task call
+	// that started it
+	for _, group := range threadMessages(side) {
+		host := sidechainHost(main, group[0])
+		if host == nil {
+			thread = append(thread, group...)
+			continue
+		}
+		for _, message := range group {
+			host.Sidechain = append(host.Sidechain, *message)
+		}
+	}
+
+	transcript := &Transcript{
+		SessionID: sessionID,
+		Messages:  []TranscriptMessage{},
+		Total:     len(thread),
+		Page:      page,
+		PageSize:  pageSize,
+	}
+	transcript.TotalPages = (transcript.Total + pageSize - 1) / pageSize
+	transcript.HasNext = page < transcript.TotalPages
+	transcript.HasPrevious = page > 1
+	for i := (page - 1) * pageSize; i < len(thread) && i < page*pageSize; i++ {
+		transcript.Messages = append(transcript.Messages, *thread[i])
+	}
+	return transcript, nil
+}
+
+// sessionToolCalls returns a session's tool calls by the message that made
+// them and by the message that answered them
+func (s *SessionService) sessionToolCalls(sessionID string) (map[string][]TranscriptToolCall, map[string][]TranscriptToolCall, error) {
+	rows, err := s.db.Query(`
+		SELECT id, message_id, result_message_id, tool_name, status, duration_ms, input_size
+		FROM tool_calls
+		WHERE session_id = ?
+		ORDER BY called_at, id
+	`, sessionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	calls := make(map[string][]TranscriptToolCall)
+	results := make(map[string][]TranscriptToolCall)
+	for rows.Next() {
+		var call TranscriptToolCall
+		var messageID string
+		var resultMessageID sql.NullString
+		var duration sql.NullInt64
+		if err := rows.Scan(&call.ID, &messageID, &resultMessageID, &call.ToolName, &call.Status, &duration, &call.InputSize); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan tool call: %w", err)
+		}
+		if duration.Valid {
+			call.DurationMs = &duration.Int64
+		}
+		calls[messageID] = append(calls[messageID], call)
+		if resultMessageID.Valid {
+			results[resultMessageID.String] = append(results[resultMessageID.String], call)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read tool calls: %w", err)
+	}
+	return calls, results, nil
+}
+
+func messageKind(m *TranscriptMessage) string {
+	switch {
+	case len(m.ToolResults) > 0:
+		return MessageKindToolResult
+	case len(m.ToolCalls) > 0:
+		return MessageKindToolUse
+	}
+	role := ""
+	if m.MessageRole != nil {
+		role = *m.MessageRole
+	}
+	switch role {
+	case "user":
+		return MessageKindPrompt
+	case "assistant":
+		return MessageKindResponse
+	}
+	if m.MessageType != nil && *m.MessageType != "" {
+		return *m.MessageType
+	}
+	return "other"
+}
+
+// threadMessages orders messages, given in time order, depth first along
+// parent_uuid. It returns one group per root: a message without a parent or
+// whose parent is not among messages.
+func threadMessages(messages []*TranscriptMessage) [][]*TranscriptMessage {
+	byID := make(map[string]bool, len(messages))
+	for _, m := range messages {
+		byID[m.ID] = true
+	}
+	children := make(map[string][]*TranscriptMessage)
+	var roots []*TranscriptMessage
+	for _, m := range messages {
+		if m.ParentUUID != nil && byID[*m.ParentUUID] && *m.ParentUUID != m.ID {
+			children[*m.ParentUUID] = append(children[*m.ParentUUID], m)
+		} else {
+			roots = append(roots, m)
+		}
+	}
+
+	var groups [][]*TranscriptMessage
+	visited := make(map[string]bool, len(messages))
+	for _, root := range roots {
+		var group []*TranscriptMessage
+		stack := []*TranscriptMessage{root}
+		for len(stack) > 0 {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if visited[m.ID] {
+				continue
+			}
+			visited[m.ID] = true
+			group = append(group, m)
+			replies := children[m.ID]
+			for i := len(replies) - 1; i >= 0; i-- {
+				replies[i].Branch = i > 0
+				stack = append(stack, replies[i])
+			}
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// sidechainHost returns the last main message, in time order, no later than
+// the start of a sidechain
+func sidechainHost(main []*TranscriptMessage, start *TranscriptMessage) *TranscriptMessage {
+	var host *TranscriptMessage
+	for _, m := range main {
+		if m.Timestamp.After(start.Timestamp) {
+			break
+		}
+		host = m
+	}
+	return host
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestGetTranscriptThreadsMessages(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	sessionService := NewSessionService(db)
+	diffSyncService := NewDiffSyncService(db, NewTokenService(db), sessionService)
+
+	// u1 is answered twice: the prompt was edited and resent as u2, which
+	// starts a subagent whose messages are logged as a sidechain
+	lines := []string{
+		`{"uuid":"u1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Fix the tests"}}`,
+		`{"uuid":"a1","parentUuid":"u1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:00:05Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":5},"content":[{"type":"text","text":"Which tests?"}]}}`,
+		`{"uuid":"u2","parentUuid":"a1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:01:00Z","message":{"role":"user","content":"The Go tests"}}`,
+		`{"uuid":"a2","parentUuid":"u2","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:01:05Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":5},"content":[{"type":"tool_use","id":"toolu_1","name":"Task","input":{"prompt":"run go test"}}]}}`,
+		`{"uuid":"x1","isSidechain":true,"sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:01:06Z","message":{"role":"user","content":"run go test"}}`,
+		`{"uuid":"x2","parentUuid":"x1","isSidechain":true,"sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:01:20Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":5},"content":[{"type":"text","text":"All pass"}]}}`,
+		`{"uuid":"r1","parentUuid":"a2","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:01:30Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"All pass"}]}}`,
+		`{"uuid":"a3","parentUuid":"u1","sessionId":"s1","cwd":"/work/app","timestamp":"2024-01-01T10:02:00Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":5},"content":[{"type":"text","text":"Done"}]}}`,
+	}
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "-work-app"), 0755); err != nil {
+		t.Fatalf("Failed to create project directory: %v", err)
+	}
+	path := filepath.Join(root, "-work-app", "s1.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log: %v", err)
+	}
+	if _, _, err := diffSyncService.processFileFrom(path, readPosition{}); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+
+	transcript, err := sessionService.GetTranscript("s1", 1, 20)
+	if err != nil {
+		t.Fatalf("Failed to get transcript: %v", err)
+	}
+	var order []string
+	for _, m := range transcript.Messages {
+		order = append(order, m.ID)
+	}
+	if got := strings.Join(order, " "); got != "u1 a1 u2 a2 r1 a3" {
+		t.Fatalf("Expected the thread depth first, got %s", got)
+	}
+	if transcript.Total != 6 || transcript.TotalPages != 1 {
+		t.Errorf("Expected six main messages on one page, got %+v", transcript)
+	}
+
+	byID := make(map[string]TranscriptMessage)
+	for _, m := range transcript.Messages {
+		byID[m.ID] = m
+	}
+	if !byID["a3"].Branch || byID["a1"].Branch {
+		t.Error("Expected only the second reply to u1 to be a branch")
+	}
+	if byID["u1"].Kind != MessageKindPrompt || byID["a1"].Kind != MessageKindResponse {
+		t.Errorf("Unexpected kinds: %s %s", byID["u1"].Kind, byID["a1"].Kind)
+	}
+	a2 := byID["a2"]
+	if a2.Kind != MessageKindToolUse || len(a2.ToolCalls) != 1 || a2.ToolCalls[0].ToolName != "Task" {
+		t.Errorf("Expected a2 to call Task, got %+v", a2)
+	}
+	if len(a2.Sidechain) != 2 || a2.Sidechain[0].ID != "x1" || a2.Sidechain[1].ID != "x2" {
+		t.Errorf("Expected the subagent nested under a2, got %+v", a2.Sidechain)
+	}
+	r1 := byID["r1"]
+	if r1.Kind != MessageKindToolResult || len(r1.ToolResults) != 1 || r1.ToolResults[0].ID != "toolu_1" {
+		t.Errorf("Expected r1 to answer toolu_1, got %+v", r1)
+	}
+
+	page, err := sessionService.GetTranscript("s1", 2, 4)
+	if err != nil {
+		t.Fatalf("Failed to get transcript page: %v", err)
+	}
+	if len(page.Messages) != 2 || page.Messages[0].ID != "r1" || page.HasNext || !page.HasPrevious {
+		t.Errorf("Expected r1 and a3 on the last page, got %+v", page)
+	}
+
+	if _, err := sessionService.GetTranscript("missing", 1, 20); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// HeatmapCell is the usage of one hour of one day of the week
+type HeatmapCell struct {
+	// Weekday is 0 for Sunday through 6 for Saturday
+	Weekday      int     `json:"weekday"`
+	Hour         int     `json:"hour"`
+	TotalTokens  int64   `json:"total_tokens"`
+	MessageCount int64   `json:"message_count"`
+	Cost         float64 `json:"cost"`
+}
+
+// UsageHeatmap is assistant usage by day of the week and hour of the day.
+// Rows are weekdays from Sunday, columns hours from midnight.
+type UsageHeatmap struct {
+	Timezone string         `json:"timezone"`
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Tokens   [7][24]int64   `json:"tokens"`
+	Messages [7][24]int64   `json:"messages"`
+	Cost     [7][24]float64 `json:"cost"`
+	// Peak is the hour with the most tokens; nil without usage
+	Peak *HeatmapCell `json:"peak"`
+}
+
+// GetUsageHeatmap adds up the usage in [from, to) by the weekday and hour of
+// loc it happened in. Usage comes from the quarter-hour rollups, so zones
+// with half-hour offsets are bucketed correctly.
+func (r *RollupService) GetUsageHeatmap(from, to time.Time, loc *time.Location) (*UsageHeatmap, error) {
+	rows, err := r.db.Query(`
+		SELECT bucket, model, input_tokens, output_tokens, cache_creation_input_tokens,
+			cache_read_input_tokens, total_tokens, message_count
+		FROM usage_buckets
+		WHERE bucket >= ? AND bucket < ?
+	`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage heatmap: %w", err)
+	}
+	defer rows.Close()
+
+	type cellModel struct {
+		weekday, hour int
+		model         string
+	}
+	heatmap := &UsageHeatmap{Timezone: loc.String(), From: from, To: to}
+	models := make(map[cellModel]*ModelPeriodUsage)
+	for rows.Next() {
+		var bucket time.Time
+		var u ModelPeriodUsage
+		if err := rows.Scan(&bucket, &u.Model, &u.InputTokens, &u.OutputTokens, &u.CacheCreationInputTokens,
+			&u.CacheReadInputTokens, &u.TotalTokens, &u.MessageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan usage heatmap: %w", err)
+		}
+		local := bucket.In(loc)
+		day, hour := int(local.Weekday()), local.Hour()
+		heatmap.Tokens[day][hour] += u.TotalTokens
+		heatmap.Messages[day][hour] += int64(u.MessageCount)
+
+		key := cellModel{day, hour, u.Model}
+		m, ok := models[key]
+		if !ok {
+			m = &ModelPeriodUsage{Model: u.Model}
+			models[key] = m
+		}
+		m.InputTokens += u.InputTokens
+		m.OutputTokens += u.OutputTokens
+		m.CacheCreationInputTokens += u.CacheCreationInputTokens
+		m.CacheReadInputTokens += u.CacheReadInputTokens
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage heatmap: %w", err)
+	}
+
+	for key, m := range models {
+		// Unknown models have no price
+		if m.Model != "unknown" {
+			heatmap.Cost[key.weekday][key.hour] += r.pricing.CalculateCost(m.Model, int(m.InputTokens), int(m.OutputTokens),
+				int(m.CacheCreationInputTokens), int(m.CacheReadInputTokens))
+		}
+	}
+	for day := range heatmap.Cost {
+		for hour := range heatmap.Cost[day] {
+			heatmap.Cost[day][hour] = roundToDecimals(heatmap.Cost[day][hour], 6)
+			if tokens := heatmap.Tokens[day][hour]; tokens > 0 && (heatmap.Peak == nil || tokens > heatmap.Peak.TotalTokens) {
+				heatmap.Peak = &HeatmapCell{Weekday: day, Hour: hour, TotalTokens: tokens,
+					MessageCount: heatmap.Messages[day][hour], Cost: heatmap.Cost[day][hour]}
+			}
+		}
+	}
+	return heatmap, nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnknownUser is returned for a user ID that is not registered
+var ErrUnknownUser = errors.New("unknown user")
+
+// User is a person whose Claude logs are synced
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserSummary is a user with the totals of their sessions
+type UserSummary struct {
+	User
+	SessionCount int        `json:"session_count"`
+	MessageCount int        `json:"message_count"`
+	TotalTokens  int64      `json:"total_tokens"`
+	LastActivity *time.Time `json:"last_activity"`
+}
+
+// UserService keeps the users table in step with the configuration
+type UserService struct {
+	db *sql.DB
+}
+
+func NewUserService(db *sql.DB) *UserService {
+	return &UserService{db: db}
+}
+
+// Register stores users and gives sessions synced before users existed to
+// the first, the owner of the default log directories
+func (s *UserService) Register(users []User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, u := range users {
+		_, err := tx.Exec(`
+			INSERT INTO users (id, name) VALUES (?, ?)
+			ON CONFLICT (id) DO UPDATE SET name = excluded.name
+		`, u.ID, u.Name)
+		if err != nil {
+			return fmt.Errorf("failed to register user %s: %w", u.ID, err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE sessions SET user_id = ? WHERE user_id IS NULL`, users[0].ID); err != nil {
+		return fmt.Errorf("failed to assign sessions to %s: %w", users[0].ID, err)
+	}
+	return tx.Commit()
+}
+
+// GetUsers returns every registered user with their usage, by name
+func (s *UserService) GetUsers() ([]UserSummary, error) {
+	rows, err := s.db.Query(`
+		SELECT u.id, u.name,
+			COUNT(DISTINCT s.id),
+			COUNT(m.id) FILTER (WHERE m.message_role = 'assistant'),
+			COALESCE(SUM(m.input_tokens + m.output_tokens), 0),
+			MAX(m.timestamp)
+		FROM users u
+		LEFT JOIN sessions s ON s.user_id = u.id
+		LEFT JOIN messages m ON m.session_id = s.id
+		GROUP BY u.id, u.name
+		ORDER BY u.name, u.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []UserSummary{}
+	for rows.Next() {
+		var u UserSummary
+		var lastActivity sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Name, &u.SessionCount, &u.MessageCount, &u.TotalTokens, &lastActivity); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		if lastActivity.Valid {
+			u.LastActivity = &lastActivity.Time
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// CheckUser returns ErrUnknownUser when id is not registered
+func (s *UserService) CheckUser(id string) error {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrUnknownUser, id)
+	}
+	return nil
+}
+
+// OwnsSession reports whether a session belongs to the user
+func (s *UserService) OwnsSession(userID, sessionID string) (bool, error) {
+	var owns bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sessions WHERE id = ? AND user_id = ?)`, sessionID, userID).Scan(&owns)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up session owner: %w", err)
+	}
+	return owns, nil
+}
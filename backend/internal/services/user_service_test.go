@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+)
+
+func TestSyncAttributesSessionsToUsers(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	// A session synced before users existed goes to the default user
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('old', 'legacy', '/legacy', '2023-12-01 09:00:00')`); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+	users := NewUserService(db)
+	if err := users.Register([]User{{ID: "alice", Name: "Alice"}, {ID: "bob", Name: "Bob"}}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	aliceRoot, bobRoot := t.TempDir(), t.TempDir()
+	writeProjectLogs(t, aliceRoot, 2, 3)
+	bobLog := `{"uuid":"bob-1","sessionId":"bob-session","userType":"external","cwd":"/work/bob","timestamp":"2024-01-02T09:00:00Z","message":{"role":"assistant","model":"claude-sonnet-4-20250514","content":"m","usage":{"input_tokens":100,"output_tokens":50}}}` + "\n"
+	if err := os.MkdirAll(filepath.Join(bobRoot, "-work-bob"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bobRoot, "-work-bob", "b.jsonl"), []byte(bobLog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	diffSync := NewDiffSyncService(db, NewTokenService(db), NewSessionService(db))
+	diffSync.SetLogSources(LogSourceConfig{
+		Roots:       []string{aliceRoot, bobRoot},
+		RootUsers:   map[string]string{bobRoot: "bob"},
+		DefaultUser: "alice",
+	})
+	if _, err := diffSync.SyncAllLogs(context.Background()); err != nil {
+		t.Fatalf("SyncAllLogs failed: %v", err)
+	}
+
+	sessions := NewSessionService(db)
+	page, err := sessions.QuerySessions(SessionQuery{User: "bob", Limit: 10})
+	if err != nil {
+		t.Fatalf("QuerySessions failed: %v", err)
+	}
+	if page.Total != 1 || page.Sessions[0].ID != "bob-session" {
+		t.Errorf("Expected only bob-session for bob, got %+v", page.Sessions)
+	}
+	if page, _ = sessions.QuerySessions(SessionQuery{User: "alice", Limit: 10}); page.Total != 3 {
+		t.Errorf("Expected alice's 2 synced sessions and the old one, got %d", page.Total)
+	}
+
+	summaries, err := users.GetUsers()
+	if err != nil {
+		t.Fatalf("GetUsers failed: %v", err)
+	}
+	if len(summaries) != 2 || summaries[0].ID != "alice" || summaries[1].SessionCount != 1 || summaries[1].TotalTokens != 150 {
+		t.Errorf("Unexpected users %+v", summaries)
+	}
+	if summaries[0].MessageCount != 6 || summaries[0].LastActivity == nil {
+		t.Errorf("Expected 6 messages for alice, got %+v", summaries[0])
+	}
+
+	if owns, err := users.OwnsSession("alice", "bob-session"); err != nil || owns {
+		t.Errorf("Expected alice not to own bob-session (%v)", err)
+	}
+	if owns, _ := users.OwnsSession("bob", "bob-session"); !owns {
+		t.Error("Expected bob to own bob-session")
+	}
+	if err := users.CheckUser("carol"); err == nil {
+		t.Error("Expected carol to be unknown")
+	}
+
+	// Message, project and tool filters follow the session owner
+	messages, err := sessions.GetMessagesPage(MessageQuery{User: "bob", Limit: 10})
+	if err != nil {
+		t.Fatalf("GetMessagesPage failed: %v", err)
+	}
+	if len(messages.Messages) != 1 || messages.Messages[0].ID != "bob-1" {
+		t.Errorf("Expected bob's message, got %+v", messages.Messages)
+	}
+	projects, err := NewProjectService(db).GetProjects("bob", time.Time{}, time.Time{})
+	if err != nil || len(projects) != 1 || projects[0].ProjectName != "bob" {
+		t.Errorf("Expected bob's project, got %+v (%v)", projects, err)
+	}
+}
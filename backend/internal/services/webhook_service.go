@@ -0,0 +1,488 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"claudeee-backend/internal/logging"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// Webhook event types
+const (
+	// WebhookEventWindowThreshold fires once per session window and threshold
+	WebhookEventWindowThreshold = "window.threshold"
+	// WebhookEventBudgetExceeded fires when a budget reaches 100% in a period
+	WebhookEventBudgetExceeded = "budget.exceeded"
+	// WebhookEventSyncFailed fires for every failed sync job
+	WebhookEventSyncFailed = "sync.failed"
+)
+
+// WebhookEventTypes lists every event a webhook can subscribe to
+var WebhookEventTypes = []string{WebhookEventWindowThreshold, WebhookEventBudgetExceeded, WebhookEventSyncFailed}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Delivery history limits
+const (
+	DefaultWebhookDeliveryLimit = 50
+	MaxWebhookDeliveryLimit     = 500
+)
+
+var (
+	// ErrInvalidWebhook is returned for a webhook that fails validation
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrWebhookNotFound is returned for an unknown webhook id
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// Webhook sends the events it subscribes to to URL, signed with Secret
+type Webhook struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Secret is only returned when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the URL and event types
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if len(w.Events) == 0 {
+		return fmt.Errorf("%w: at least one event is required", ErrInvalidWebhook)
+	}
+	seen := make(map[string]bool)
+	var events []string
+	for _, event := range w.Events {
+		known := false
+		for _, t := range WebhookEventTypes {
+			known = known || t == event
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	w.Events = events
+	return nil
+}
+
+func (w *Webhook) subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is the record of one event sent to one webhook
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	WebhookID      string     `json:"webhook_id"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus *int       `json:"response_status"`
+	Error          *string    `json:"error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+}
+
+// WebhookEvent is an event to send to subscribed webhooks. Events with a Key
+// are sent to each webhook at most once.
+type WebhookEvent struct {
+	Type       string
+	Key        string
+	OccurredAt time.Time
+	Data       interface{}
+}
+
+// webhookPayload is the JSON body of a delivery
+type webhookPayload struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookService stores webhooks and delivers events to them in the background
+type WebhookService struct {
+	db     *sql.DB
+	writes *WriteQueue
+	sender *webhook.Sender
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inflight sync.WaitGroup
+}
+
+func NewWebhookService(db *sql.DB, sender *webhook.Sender) *WebhookService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookService{db: db, sender: sender, ctx: ctx, cancel: cancel}
+}
+
+// SetWriteQueue routes webhook and delivery writes through the shared writer
+func (s *WebhookService) SetWriteQueue(writes *WriteQueue) {
+	s.writes = writes
+}
+
+// Wait blocks until every delivery started so far has finished
+func (s *WebhookService) Wait() {
+	s.inflight.Wait()
+}
+
+// Close abandons pending retries and waits for deliveries to stop
+func (s *WebhookService) Close() {
+	s.cancel()
+	s.inflight.Wait()
+}
+
+// Create validates and stores a webhook, generating a secret when none is given
+func (s *WebhookService) Create(w Webhook) (*Webhook, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	if w.Secret == "" {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			return nil, err
+		}
+		w.Secret = secret
+	}
+	now := time.Now().UTC()
+	w.ID = uuid.New().String()
+	w.CreatedAt = now
+	w.UpdatedAt = now
+	err := s.writes.Do(func() error {
+		_, err := s.db.Exec(`
+			INSERT INTO webhooks (id, url, secret, events, enabled, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, w.ID, w.URL, w.Secret, strings.Join(w.Events, ","), w.Enabled, now, now)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return &w, nil
+}
+
+// Update replaces a webhook's URL, events and enabled state. An empty secret
+// keeps the current one.
+func (s *WebhookService) Update(id string, w Webhook) (*Webhook, error) {
+	existing, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	if w.Secret == "" {
+		w.Secret = existing.Secret
+	}
+	w.ID = id
+	w.CreatedAt = existing.CreatedAt
+	w.UpdatedAt = time.Now().UTC()
+	err = s.writes.Do(func() error {
+		_, err := s.db.Exec(`
+			UPDATE webhooks SET url = ?, secret = ?, events = ?, enabled = ?, updated_at = ?
+			WHERE id = ?
+		`, w.URL, w.Secret, strings.Join(w.Events, ","), w.Enabled, w.UpdatedAt, id)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	w.Secret = ""
+	return &w, nil
+}
+
+// Delete removes a webhook and its delivery history
+func (s *WebhookService) Delete(id string) error {
+	if _, err := s.get(id); err != nil {
+		return err
+	}
+	err := s.writes.Do(func() error {
+		if _, err := s.db.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+			return err
+		}
+		_, err := s.db.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+const webhookColumns = `id, url, secret, events, COALESCE(enabled, true), created_at, updated_at`
+
+func scanWebhook(scan func(...interface{}) error) (*Webhook, error) {
+	var w Webhook
+	var events string
+	if err := scan(&w.ID, &w.URL, &w.Secret, &events, &w.Enabled, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	w.Events = strings.Split(events, ",")
+	return &w, nil
+}
+
+// get returns a webhook including its secret
+func (s *WebhookService) get(id string) (*Webhook, error) {
+	w, err := scanWebhook(s.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return w, nil
+}
+
+// Get returns a webhook without its secret
+func (s *WebhookService) Get(id string) (*Webhook, error) {
+	w, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	w.Secret = ""
+	return w, nil
+}
+
+// list returns every webhook including secrets
+func (s *WebhookService) list() ([]Webhook, error) {
+	rows, err := s.db.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, rows.Err()
+}
+
+// List returns every webhook without secrets
+func (s *WebhookService) List() ([]Webhook, error) {
+	webhooks, err := s.list()
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, err
+}
+
+// Deliveries returns a webhook's most recent deliveries, newest first
+func (s *WebhookService) Deliveries(webhookID string, limit int) ([]WebhookDelivery, error) {
+	if limit < 1 || limit > MaxWebhookDeliveryLimit {
+		limit = DefaultWebhookDeliveryLimit
+	}
+	rows, err := s.db.Query(`
+		SELECT id, webhook_id, event, status, COALESCE(attempts, 0), response_status, error, created_at, completed_at
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY created_at DESC, id
+		LIMIT ?
+	`, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		var completed sql.NullTime
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Status, &d.Attempts, &d.ResponseStatus, &d.Error, &d.CreatedAt, &completed); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		if completed.Valid {
+			d.CompletedAt = &completed.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Dispatch queues event for every enabled webhook subscribed to it and sends
+// it in the background. It must not be called from inside the write queue.
+func (s *WebhookService) Dispatch(event WebhookEvent) error {
+	webhooks, err := s.list()
+	if err != nil {
+		return err
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+
+	for _, w := range webhooks {
+		if !w.Enabled || !w.subscribes(event.Type) {
+			continue
+		}
+		delivery, created, err := s.queueDelivery(w, event)
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+		s.inflight.Add(1)
+		go func() {
+			defer s.inflight.Done()
+			s.finishDelivery(delivery.ID, s.sender.Send(s.ctx, delivery))
+		}()
+	}
+	return nil
+}
+
+// queueDelivery records a pending delivery of event to w, unless an event with
+// the same key was already sent to it
+func (s *WebhookService) queueDelivery(w Webhook, event WebhookEvent) (webhook.Delivery, bool, error) {
+	delivery := webhook.Delivery{
+		ID:     uuid.New().String(),
+		Event:  event.Type,
+		URL:    w.URL,
+		Secret: w.Secret,
+	}
+	body, err := json.Marshal(webhookPayload{ID: delivery.ID, Type: event.Type, OccurredAt: event.OccurredAt, Data: event.Data})
+	if err != nil {
+		return delivery, false, fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	delivery.Body = body
+
+	var key interface{}
+	if event.Key != "" {
+		key = event.Key
+	}
+	created := false
+	err = s.writes.Do(func() error {
+		if event.Key != "" {
+			var sent int
+			err := s.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ? AND event_key = ?`,
+				w.ID, event.Key).Scan(&sent)
+			if err != nil || sent > 0 {
+				return err
+			}
+		}
+		_, err := s.db.Exec(`
+			INSERT INTO webhook_deliveries (id, webhook_id, event, event_key, status, attempts, created_at)
+			VALUES (?, ?, ?, ?, ?, 0, ?)
+		`, delivery.ID, w.ID, event.Type, key, WebhookDeliveryPending, time.Now().UTC())
+		created = err == nil
+		return err
+	})
+	if err != nil {
+		return delivery, false, fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return delivery, created, nil
+}
+
+func (s *WebhookService) finishDelivery(id string, result webhook.Result) {
+	status := WebhookDeliveryDelivered
+	var responseStatus, errorText interface{}
+	if result.StatusCode != 0 {
+		responseStatus = result.StatusCode
+	}
+	if result.Err != nil {
+		status = WebhookDeliveryFailed
+		errorText = result.Err.Error()
+	}
+	err := s.writes.Do(func() error {
+		_, err := s.db.Exec(`
+			UPDATE webhook_deliveries
+			SET status = ?, attempts = ?, response_status = ?, error = ?, completed_at = ?
+			WHERE id = ?
+		`, status, result.Attempts, responseStatus, errorText, time.Now().UTC(), id)
+		return err
+	})
+	if err != nil {
+		logging.Component("webhooks").Warn("Failed to record webhook delivery", "delivery", id, "err", err)
+	}
+}
+
+// WindowThresholdEvents returns a window.threshold event for each threshold
+// the current session window has reached. The keys make each crossing fire
+// once per window.
+func WindowThresholdEvents(usage *models.TokenUsage, thresholds []float64) []WebhookEvent {
+	if usage == nil || usage.UsageLimit <= 0 || usage.WindowStart.IsZero() {
+		return nil
+	}
+	var events []WebhookEvent
+	for _, threshold := range thresholds {
+		if threshold <= 0 || usage.UsageRate < threshold {
+			continue
+		}
+		events = append(events, WebhookEvent{
+			Type: WebhookEventWindowThreshold,
+			Key: fmt.Sprintf("%s:%s:%s", WebhookEventWindowThreshold,
+				usage.WindowStart.UTC().Format(time.RFC3339), strconv.FormatFloat(threshold, 'g', -1, 64)),
+			Data: map[string]interface{}{
+				"threshold":    threshold,
+				"utilization":  usage.UsageRate,
+				"total_tokens": usage.TotalTokens,
+				"usage_limit":  usage.UsageLimit,
+				"window_start": usage.WindowStart,
+				"window_end":   usage.WindowEnd,
+				"total_cost":   usage.TotalCost,
+			},
+		})
+	}
+	return events
+}
+
+// BudgetExceededEvent returns the budget.exceeded event for an alert at or
+// above 100% of its budget
+func BudgetExceededEvent(alert BudgetAlert) (WebhookEvent, bool) {
+	if alert.Threshold < 1 {
+		return WebhookEvent{}, false
+	}
+	return WebhookEvent{
+		Type:       WebhookEventBudgetExceeded,
+		Key:        WebhookEventBudgetExceeded + ":" + alert.ID,
+		OccurredAt: alert.TriggeredAt,
+		Data:       alert,
+	}, true
+}
+
+// SyncFailedEvent returns the sync.failed event for a failed sync job
+func SyncFailedEvent(job SyncJob) WebhookEvent {
+	occurred := time.Now().UTC()
+	if job.FinishedAt != nil {
+		occurred = job.FinishedAt.UTC()
+	}
+	return WebhookEvent{
+		Type:       WebhookEventSyncFailed,
+		Key:        WebhookEventSyncFailed + ":" + job.ID,
+		OccurredAt: occurred,
+		Data:       job,
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"claudeee-backend/internal/database"
+	"claudeee-backend/internal/models"
+	"claudeee-backend/internal/webhook"
+)
+
+func setupWebhookTest(t *testing.T) *WebhookService {
+	t.Helper()
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	sender := webhook.NewSender()
+	sender.InitialBackoff = time.Millisecond
+	sender.MaxAttempts = 2
+	webhooks := NewWebhookService(db, sender)
+	t.Cleanup(webhooks.Close)
+	return webhooks
+}
+
+func TestWebhookValidation(t *testing.T) {
+	webhooks := setupWebhookTest(t)
+
+	for name, w := range map[string]Webhook{
+		"scheme": {URL: "ftp://example.com", Events: []string{WebhookEventSyncFailed}},
+		"events": {URL: "https://example.com/hook"},
+		"event":  {URL: "https://example.com/hook", Events: []string{"session.started"}},
+	} {
+		if _, err := webhooks.Create(w); !errors.Is(err, ErrInvalidWebhook) {
+			t.Errorf("%s: expected ErrInvalidWebhook, got %v", name, err)
+		}
+	}
+
+	created, err := webhooks.Create(Webhook{URL: "https://example.com/hook", Events: []string{WebhookEventSyncFailed, WebhookEventSyncFailed}})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.Secret == "" || len(created.Events) != 1 {
+		t.Errorf("Expected a generated secret and one event, got %+v", created)
+	}
+	listed, err := webhooks.List()
+	if err != nil || len(listed) != 1 || listed[0].Secret != "" {
+		t.Errorf("Expected one webhook without its secret, got %+v (%v)", listed, err)
+	}
+	if _, err := webhooks.Update("missing", *created); !errors.Is(err, ErrWebhookNotFound) {
+		t.Errorf("Expected ErrWebhookNotFound, got %v", err)
+	}
+}
+
+func TestWebhookDispatch(t *testing.T) {
+	webhooks := setupWebhookTest(t)
+
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(body, &payload)
+		mu.Lock()
+		received = append(received, payload.Type)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	hook, err := webhooks.Create(Webhook{URL: server.URL, Events: []string{WebhookEventWindowThreshold}, Enabled: true})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := webhooks.Create(Webhook{URL: server.URL, Events: []string{WebhookEventWindowThreshold}}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	usage := &models.TokenUsage{TotalTokens: 8500, UsageLimit: 10000, UsageRate: 0.85,
+		WindowStart: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)}
+	events := WindowThresholdEvents(usage, []float64{0.8, 1})
+	if len(events) != 1 {
+		t.Fatalf("Expected only the 80%% event, got %+v", events)
+	}
+	// The same crossing in the same window is only delivered once
+	for i := 0; i < 2; i++ {
+		if err := webhooks.Dispatch(events[0]); err != nil {
+			t.Fatalf("Dispatch failed: %v", err)
+		}
+	}
+	if err := webhooks.Dispatch(SyncFailedEvent(SyncJob{ID: "j1"})); err != nil {
+		t.Fatalf("Dispatch failed: %v", err)
+	}
+	webhooks.Wait()
+
+	if len(received) != 1 || received[0] != WebhookEventWindowThreshold {
+		t.Errorf("Expected one window.threshold delivery to the enabled webhook, got %v", received)
+	}
+	deliveries, err := webhooks.Deliveries(hook.ID, 0)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Expected one delivery, got %+v (%v)", deliveries, err)
+	}
+	if d := deliveries[0]; d.Status != WebhookDeliveryDelivered || d.Attempts != 1 || d.ResponseStatus == nil || *d.ResponseStatus != 200 {
+		t.Errorf("Unexpected delivery: %+v", d)
+	}
+}
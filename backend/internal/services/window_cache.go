@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// windowCache keeps session window time ranges in memory so that assigning
+// messages to windows during a large import does not query the database for
+// every message. Windows are sorted by start time. Cached statistics are not
+// kept up to date; callers on the sync path only rely on the ID and range.
+type windowCache struct {
+	mu      sync.RWMutex
+	loaded  bool
+	windows []*SessionWindow
+}
+
+// find returns the latest-starting window containing t, mirroring findWindowForTime
+func (c *windowCache) find(t time.Time) *SessionWindow {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// First window starting after t; candidates are all before it
+	i := sort.Search(len(c.windows), func(i int) bool {
+		return c.windows[i].WindowStart.After(t)
+	})
+	for j := i - 1; j >= 0; j-- {
+		if t.Before(c.windows[j].WindowEnd) {
+			window := *c.windows[j]
+			return &window
+		}
+	}
+	return nil
+}
+
+// add records a newly inserted window
+func (c *windowCache) add(window *SessionWindow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.loaded {
+		return
+	}
+	cached := *window
+	i := sort.Search(len(c.windows), func(i int) bool {
+		return c.windows[i].WindowStart.After(cached.WindowStart)
+	})
+	c.windows = append(c.windows, nil)
+	copy(c.windows[i+1:], c.windows[i:])
+	c.windows[i] = &cached
+}
+
+// invalidate drops the cached windows; they are reloaded on the next lookup
+func (c *windowCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.loaded = false
+	c.windows = nil
+}
+
+// EnableCache keeps existing windows in memory for GetOrCreateWindowForMessage.
+// Use it for bulk imports where this service is the only writer of windows.
+func (s *SessionWindowService) EnableCache() {
+	if s.cache == nil {
+		s.cache = &windowCache{}
+	}
+}
+
+// InvalidateCache forces cached windows to be reloaded from the database
+func (s *SessionWindowService) InvalidateCache() {
+	if s.cache != nil {
+		s.cache.invalidate()
+	}
+}
+
+// cachedWindowForTime looks up a window in the cache, loading it on first use
+func (s *SessionWindowService) cachedWindowForTime(messageTime time.Time) (*SessionWindow, error) {
+	s.cache.mu.RLock()
+	loaded := s.cache.loaded
+	s.cache.mu.RUnlock()
+
+	if !loaded {
+		if err := s.loadCache(); err != nil {
+			return nil, err
+		}
+	}
+	return s.cache.find(messageTime), nil
+}
+
+func (s *SessionWindowService) loadCache() error {
+	rows, err := s.db.Query(`
+		SELECT id, window_start, window_end, reset_time, is_active
+		FROM session_windows
+		ORDER BY window_start ASC
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load session windows: %w", err)
+	}
+	defer rows.Close()
+
+	var windows []*SessionWindow
+	for rows.Next() {
+		var window SessionWindow
+		if err := rows.Scan(&window.ID, &window.WindowStart, &window.WindowEnd, &window.ResetTime, &window.IsActive); err != nil {
+			return fmt.Errorf("failed to scan window: %w", err)
+		}
+		windows = append(windows, &window)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load session windows: %w", err)
+	}
+
+	s.cache.mu.Lock()
+	s.cache.windows = windows
+	s.cache.loaded = true
+	s.cache.mu.Unlock()
+	return nil
+}
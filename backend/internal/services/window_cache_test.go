@@ -0,0 +1,89 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func TestWindowCacheFind(t *testing.T) {
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	cache := &windowCache{loaded: true}
+	cache.add(&SessionWindow{ID: "late", WindowStart: base.Add(6 * time.Hour), WindowEnd: base.Add(11 * time.Hour)})
+	cache.add(&SessionWindow{ID: "early", WindowStart: base, WindowEnd: base.Add(5 * time.Hour)})
+
+	tests := []struct {
+		at       time.Time
+		expected string
+	}{
+		{base, "early"},
+		{base.Add(4 * time.Hour), "early"},
+		{base.Add(5 * time.Hour), ""},
+		{base.Add(7 * time.Hour), "late"},
+		{base.Add(-time.Minute), ""},
+	}
+
+	for _, tt := range tests {
+		window := cache.find(tt.at)
+		got := ""
+		if window != nil {
+			got = window.ID
+		}
+		if got != tt.expected {
+			t.Errorf("At %v: expected window %q, got %q", tt.at, tt.expected, got)
+		}
+	}
+}
+
+func TestGetOrCreateWindowForMessageWithCache(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE messages (id TEXT PRIMARY KEY)`); err != nil {
+		t.Fatalf("Failed to create messages table: %v", err)
+	}
+	addSessionWindowTables(t, db)
+
+	service := NewSessionWindowService(db)
+	service.EnableCache()
+
+	base := time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)
+	first, err := service.GetOrCreateWindowForMessage(base)
+	if err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+
+	second, err := service.GetOrCreateWindowForMessage(base.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get window: %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("Expected cached window %s, got %s", first.ID, second.ID)
+	}
+
+	// Windows written by someone else are only seen after invalidation
+	if _, err := db.Exec(`DELETE FROM session_windows`); err != nil {
+		t.Fatalf("Failed to delete windows: %v", err)
+	}
+	service.InvalidateCache()
+
+	third, err := service.GetOrCreateWindowForMessage(base)
+	if err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+	if third.ID == first.ID {
+		t.Error("Expected a new window after invalidation")
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM session_windows`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count windows: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 window, got %d", count)
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// WindowAnchor decides where a session window starts
+type WindowAnchor string
+
+const (
+	// WindowAnchorFirstMessage starts a window at the first message sent
+	// after the previous one ended, to the minute, and resets it on the hour
+	// the window length later, as Claude's usage limits do
+	WindowAnchorFirstMessage WindowAnchor = "first_message"
+	// WindowAnchorClock uses fixed windows starting at multiples of the
+	// window length from midnight UTC; the last one of a day ends at midnight
+	WindowAnchorClock WindowAnchor = "clock"
+)
+
+// WindowPolicy is the length and anchoring of session windows
+type WindowPolicy struct {
+	Duration time.Duration
+	Anchor   WindowAnchor
+}
+
+// DefaultWindowPolicy returns 5-hour windows anchored to their first message
+func DefaultWindowPolicy() WindowPolicy {
+	return WindowPolicy{Duration: WINDOW_DURATION, Anchor: WindowAnchorFirstMessage}
+}
+
+// bounds returns the window a message at t opens when no window contains it
+func (p WindowPolicy) bounds(t time.Time) (start, end time.Time) {
+	if p.Anchor == WindowAnchorClock {
+		t = t.UTC()
+		midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		start = midnight.Add(t.Sub(midnight) / p.Duration * p.Duration)
+		end = start.Add(p.Duration)
+		if next := midnight.AddDate(0, 0, 1); end.After(next) {
+			end = next
+		}
+		return start, end
+	}
+	start = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+	end = start.Add(p.Duration)
+	return start, time.Date(end.Year(), end.Month(), end.Day(), end.Hour(), 0, 0, 0, end.Location())
+}
+
+var (
+	windowPolicyMu sync.RWMutex
+	windowPolicy   = DefaultWindowPolicy()
+)
+
+// SetWindowPolicy sets how every session window service, including those
+// already created, opens new windows. Stored windows keep their bounds until
+// they are recalculated.
+func SetWindowPolicy(p WindowPolicy) {
+	windowPolicyMu.Lock()
+	windowPolicy = p
+	windowPolicyMu.Unlock()
+}
+
+// currentWindowPolicy returns the policy set by SetWindowPolicy
+func currentWindowPolicy() WindowPolicy {
+	windowPolicyMu.RLock()
+	defer windowPolicyMu.RUnlock()
+	return windowPolicy
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowPolicyBounds(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 30, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		policy     WindowPolicy
+		message    time.Time
+		start, end time.Time
+	}{
+		{"first message resets on the hour", DefaultWindowPolicy(), at(1, 10, 20), at(1, 10, 20).Truncate(time.Minute), at(1, 15, 0).Truncate(time.Hour)},
+		{"first message with 3 hours", WindowPolicy{Duration: 3 * time.Hour, Anchor: WindowAnchorFirstMessage}, at(1, 23, 5), at(1, 23, 5).Truncate(time.Minute), at(2, 2, 0).Truncate(time.Hour)},
+		{"clock", WindowPolicy{Duration: 5 * time.Hour, Anchor: WindowAnchorClock}, at(1, 12, 40), at(1, 10, 0).Truncate(time.Hour), at(1, 15, 0).Truncate(time.Hour)},
+		{"clock ends at midnight", WindowPolicy{Duration: 5 * time.Hour, Anchor: WindowAnchorClock}, at(1, 22, 0), at(1, 20, 0).Truncate(time.Hour), at(2, 0, 0).Truncate(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.policy.bounds(tt.message)
+			if !start.Equal(tt.start) || !end.Equal(tt.end) {
+				t.Errorf("Expected %v to %v, got %v to %v", tt.start, tt.end, start, end)
+			}
+		})
+	}
+
+	// Services already created follow a new policy
+	defer SetWindowPolicy(DefaultWindowPolicy())
+	SetWindowPolicy(WindowPolicy{Duration: 2 * time.Hour, Anchor: WindowAnchorClock})
+	if start, end := currentWindowPolicy().bounds(at(1, 3, 0)); !start.Equal(at(1, 2, 0).Truncate(time.Hour)) || end.Sub(start) != 2*time.Hour {
+		t.Errorf("Expected the set policy, got %v to %v", start, end)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrWriteQueueClosed is returned for writes submitted after Close
+var ErrWriteQueueClosed = errors.New("write queue is closed")
+
+// WriteQueue funnels database writes through a single goroutine so that the
+// HTTP-triggered sync and background jobs never write concurrently, which
+// DuckDB reports as transaction conflicts. A nil *WriteQueue runs writes
+// directly on the caller's goroutine.
+type WriteQueue struct {
+	jobs      chan writeJob
+	done      chan struct{}
+	closeOnce sync.Once
+	mu        sync.RWMutex
+	closed    bool
+}
+
+type writeJob struct {
+	fn     func() error
+	result chan error
+}
+
+// NewWriteQueue starts the writer goroutine. buffer is the number of writes
+// that may wait in the queue before Do blocks on submission.
+func NewWriteQueue(buffer int) *WriteQueue {
+	q := &WriteQueue{
+		jobs: make(chan writeJob, buffer),
+		done: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *WriteQueue) run() {
+	defer close(q.done)
+	for job := range q.jobs {
+		job.result <- job.fn()
+	}
+}
+
+// Do runs fn on the writer goroutine and waits for its result. fn must not
+// call Do itself, since the writer is busy running it.
+func (q *WriteQueue) Do(fn func() error) error {
+	if q == nil {
+		return fn()
+	}
+
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return ErrWriteQueueClosed
+	}
+	job := writeJob{fn: fn, result: make(chan error, 1)}
+	q.jobs <- job
+	q.mu.RUnlock()
+
+	return <-job.result
+}
+
+// Close stops accepting writes and waits for queued writes to finish
+func (q *WriteQueue) Close() {
+	if q == nil {
+		return
+	}
+	q.closeOnce.Do(func() {
+		q.mu.Lock()
+		q.closed = true
+		close(q.jobs)
+		q.mu.Unlock()
+	})
+	<-q.done
+}
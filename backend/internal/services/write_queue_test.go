@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestWriteQueueSerializesWrites(t *testing.T) {
+	queue := NewWriteQueue(4)
+	defer queue.Close()
+
+	var mu sync.Mutex
+	running, maxRunning, total := 0, 0, 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queue.Do(func() error {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+
+				mu.Lock()
+				running--
+				total++
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning != 1 {
+		t.Errorf("Expected writes to run one at a time, got %d concurrently", maxRunning)
+	}
+	if total != 50 {
+		t.Errorf("Expected 50 writes, got %d", total)
+	}
+}
+
+func TestWriteQueueReturnsErrors(t *testing.T) {
+	queue := NewWriteQueue(0)
+
+	expected := errors.New("write failed")
+	if err := queue.Do(func() error { return expected }); err != expected {
+		t.Errorf("Expected %v, got %v", expected, err)
+	}
+
+	queue.Close()
+	if err := queue.Do(func() error { return nil }); !errors.Is(err, ErrWriteQueueClosed) {
+		t.Errorf("Expected ErrWriteQueueClosed after Close, got %v", err)
+	}
+}
+
+func TestNilWriteQueueRunsDirectly(t *testing.T) {
+	var queue *WriteQueue
+
+	called := false
+	if err := queue.Do(func() error { called = true; return nil }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !called {
+		t.Error("Expected nil queue to run the write")
+	}
+}
@@ -0,0 +1,112 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Headers identifying a delivery
+const (
+	EventHeader    = "X-Claudeee-Event"
+	DeliveryHeader = "X-Claudeee-Delivery"
+)
+
+// Delivery defaults
+const (
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = 2 * time.Second
+	DefaultMaxBackoff     = 2 * time.Minute
+	DefaultTimeout        = 10 * time.Second
+)
+
+// Delivery is one event to send to one endpoint
+type Delivery struct {
+	ID     string
+	Event  string
+	URL    string
+	Secret string
+	Body   []byte
+}
+
+// Result is the outcome of the last attempt of a delivery
+type Result struct {
+	Attempts   int
+	StatusCode int
+	Err        error
+}
+
+// Sender posts signed deliveries, retrying network errors, 429s and 5xx
+// responses with exponential backoff
+type Sender struct {
+	Client         *http.Client
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+func NewSender() *Sender {
+	return &Sender{
+		Client:         &http.Client{Timeout: DefaultTimeout},
+		MaxAttempts:    DefaultMaxAttempts,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     DefaultMaxBackoff,
+	}
+}
+
+// Send delivers d, giving up after MaxAttempts or when ctx is done. Each
+// attempt is signed with its own timestamp so retries pass Verify.
+func (s *Sender) Send(ctx context.Context, d Delivery) Result {
+	var result Result
+	backoff := s.InitialBackoff
+	for result.Attempts < s.MaxAttempts {
+		if result.Attempts > 0 {
+			select {
+			case <-ctx.Done():
+				return result
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+		}
+
+		result.Attempts++
+		var retry bool
+		result.StatusCode, retry, result.Err = s.attempt(ctx, d)
+		if result.Err == nil || !retry {
+			return result
+		}
+	}
+	return result
+}
+
+// attempt posts d once and reports whether a failure is worth retrying
+func (s *Sender) attempt(ctx context.Context, d Delivery) (int, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claudeee-webhook")
+	req.Header.Set(EventHeader, d.Event)
+	req.Header.Set(DeliveryHeader, d.ID)
+	SignRequest(req, d.Secret, d.Body, time.Now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, ctx.Err() == nil, fmt.Errorf("failed to deliver webhook: %w", err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return resp.StatusCode, retry, fmt.Errorf("webhook endpoint returned %d", resp.StatusCode)
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testSender() *Sender {
+	s := NewSender()
+	s.InitialBackoff = time.Millisecond
+	s.MaxBackoff = 4 * time.Millisecond
+	s.MaxAttempts = 3
+	return s
+}
+
+func TestSendRetriesServerErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("whsec_test", r.Header.Get(SignatureHeader), body, DefaultTolerance, time.Now()); err != nil {
+			t.Errorf("Expected a valid signature, got %v", err)
+		}
+		if r.Header.Get(EventHeader) != "sync.failed" || r.Header.Get(DeliveryHeader) != "d1" {
+			t.Errorf("Unexpected headers: %v", r.Header)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	result := testSender().Send(context.Background(), Delivery{
+		ID: "d1", Event: "sync.failed", URL: server.URL, Secret: "whsec_test", Body: []byte(`{}`),
+	})
+	if result.Err != nil || result.Attempts != 3 || result.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected success on the third attempt, got %+v", result)
+	}
+}
+
+func TestSendDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	result := testSender().Send(context.Background(), Delivery{ID: "d1", URL: server.URL, Body: []byte(`{}`)})
+	if result.Err == nil || result.Attempts != 1 || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected one failed attempt, got %+v", result)
+	}
+}
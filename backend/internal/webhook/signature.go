@@ -0,0 +1,100 @@
+// Package webhook holds the pieces of outbound webhook delivery. Deliveries
+// are signed so receivers can check that an event came from claudeee and is
+// not a replay of an old one.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers added to every signed delivery
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>"
+	SignatureHeader = "X-Claudeee-Signature"
+	// TimestampHeader repeats the signed timestamp for receivers that only log it
+	TimestampHeader = "X-Claudeee-Timestamp"
+)
+
+// DefaultTolerance is how old a delivery may be before Verify rejects it
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// ErrInvalidSignature is returned when the signature does not match the body
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleSignature is returned when the signed timestamp is outside the tolerance
+	ErrStaleSignature = errors.New("webhook signature timestamp outside tolerance")
+)
+
+// NewSecret generates a random per-subscription signing secret
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body sent at ts. The timestamp
+// is part of the signed message, so it cannot be changed to replay a delivery.
+func Sign(secret string, body []byte, ts time.Time) string {
+	unix := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + unix + ",v1=" + computeMAC(secret, unix, body)
+}
+
+// SignRequest sets the signature headers on an outgoing delivery
+func SignRequest(req *http.Request, secret string, body []byte, ts time.Time) {
+	req.Header.Set(SignatureHeader, Sign(secret, body, ts))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+}
+
+// Verify checks a signature header against body. Deliveries signed more than
+// tolerance before or after now are rejected to prevent replays.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var unix string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	ts, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleSignature
+	}
+
+	expected := computeMAC(secret, unix, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func computeMAC(secret, unix string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
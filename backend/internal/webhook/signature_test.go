@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret failed: %v", err)
+	}
+	body := []byte(`{"event":"window.threshold","utilization":0.8}`)
+	sentAt := time.Unix(1700000000, 0)
+	header := Sign(secret, body, sentAt)
+
+	if !strings.HasPrefix(header, "t=1700000000,v1=") {
+		t.Errorf("Unexpected header format %q", header)
+	}
+	if err := Verify(secret, header, body, DefaultTolerance, sentAt.Add(time.Minute)); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		err    error
+	}{
+		{"tampered body", secret, header, []byte(`{"event":"window.threshold","utilization":1}`), sentAt, ErrInvalidSignature},
+		{"other secret", "whsec_other", header, body, sentAt, ErrInvalidSignature},
+		{"replayed later", secret, header, body, sentAt.Add(10 * time.Minute), ErrStaleSignature},
+		{"moved timestamp", secret, strings.Replace(header, "t=1700000000", "t=1700000600", 1), body, sentAt.Add(10 * time.Minute), ErrInvalidSignature},
+		{"malformed", secret, "garbage", body, sentAt, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, tt.header, tt.body, DefaultTolerance, tt.now); !errors.Is(err, tt.err) {
+				t.Errorf("Expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/hook", nil)
+	SignRequest(req, "whsec_test", []byte("{}"), time.Unix(1700000000, 0))
+
+	if req.Header.Get(TimestampHeader) != "1700000000" {
+		t.Errorf("Expected timestamp header, got %q", req.Header.Get(TimestampHeader))
+	}
+	if err := Verify("whsec_test", req.Header.Get(SignatureHeader), []byte("{}"), DefaultTolerance, time.Unix(1700000000, 0)); err != nil {
+		t.Errorf("Expected signed request to verify, got %v", err)
+	}
+}
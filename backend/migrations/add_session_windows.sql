@@ -17,7 +17,6 @@ CREATE TABLE IF NOT EXISTS session_windows (
 -- インデックス作成
 CREATE INDEX IF NOT EXISTS idx_session_windows_times ON session_windows(window_start, window_end);
 CREATE INDEX IF NOT EXISTS idx_session_windows_active ON session_windows(is_active);
-CREATE INDEX IF NOT EXISTS idx_session_windows_reset_time ON session_windows(reset_time);
 
 -- メッセージテーブルにsession_window_idカラムを追加
 ALTER TABLE messages ADD COLUMN IF NOT EXISTS session_window_id TEXT;
@@ -4,6 +4,7 @@ const { spawn } = require('child_process');
 const path = require('path');
 const fs = require('fs');
 const os = require('os');
+const AutostartManager = require('../scripts/autostart');
 
 // ASCII Art Logo
 const LOGO = `
@@ -163,6 +164,8 @@ function parseArgs() {
   let command = 'start';
   let backendPort = 8080;
   let frontendPort = 3000;
+  let profile = process.env.CLAUDEEE_PROFILE || '';
+  const positional = [];
   
   for (let i = 0; i < args.length; i++) {
     const arg = args[i];
@@ -173,12 +176,19 @@ function parseArgs() {
     } else if (arg === '--frontend-port' || arg === '-fp') {
       frontendPort = parseInt(args[i + 1]) || 3000;
       i++;
+    } else if (arg === '--profile' || arg === '-p') {
+      profile = args[i + 1] || '';
+      i++;
     } else if (!arg.startsWith('-')) {
-      command = arg;
+      positional.push(arg);
     }
   }
   
-  return { command, backendPort, frontendPort };
+  if (positional.length > 0) {
+    command = positional[0];
+  }
+  
+  return { command, subcommand: positional[1], backendPort, frontendPort, profile };
 }
 
 // Start backend server with custom port
@@ -204,6 +214,52 @@ function startBackend(port = 8080, frontendPort = 3000) {
   return backendProcess;
 }
 
+// Data directory of the active profile; the default profile lives in ~/.claudeee
+function profileDataDir() {
+  const base = path.join(os.homedir(), '.claudeee');
+  const profile = process.env.CLAUDEEE_PROFILE;
+  if (!profile || profile === 'default') {
+    return base;
+  }
+  return path.join(base, 'profiles', profile);
+}
+
+// Path of the discovery file written by the backend once it has bound a port
+function serverInfoPath() {
+  return path.join(profileDataDir(), 'server.json');
+}
+
+// Read the backend discovery file, ignoring files written before `since`
+function readServerInfo(since = 0) {
+  try {
+    const info = JSON.parse(fs.readFileSync(serverInfoPath(), 'utf8'));
+    if (Date.parse(info.started_at) >= since) {
+      return info;
+    }
+  } catch (err) {
+    // File missing or incomplete
+  }
+  return null;
+}
+
+// Wait for the backend to publish its address; it may fall back to another port
+// when the requested one is already in use
+async function waitForBackendPort(requestedPort, since, timeoutMs = 15000) {
+  const deadline = Date.now() + timeoutMs;
+  while (Date.now() < deadline) {
+    const info = readServerInfo(since);
+    if (info && info.port) {
+      if (info.port !== requestedPort) {
+        log.warning(`Port ${requestedPort} is in use, backend is listening on ${info.port}`);
+      }
+      return info.port;
+    }
+    await new Promise((resolve) => setTimeout(resolve, 200));
+  }
+  log.warning('Backend did not report its address, assuming the requested port');
+  return requestedPort;
+}
+
 // Start frontend server with custom port
 function startFrontend(port = 3000, backendPort = 8080) {
   log.info(`Starting frontend server on http://localhost:${port}`);
@@ -314,7 +370,16 @@ function startFrontend(port = 3000, backendPort = 8080) {
 
 // Main CLI function
 async function main() {
-  const { command, backendPort, frontendPort } = parseArgs();
+  const { command, subcommand, backendPort, frontendPort, profile } = parseArgs();
+  
+  if (profile) {
+    if (!/^[A-Za-z0-9][A-Za-z0-9_-]*$/.test(profile)) {
+      log.error(`Invalid profile name: ${profile}`);
+      process.exit(1);
+    }
+    // Spawned backend processes inherit the profile through the environment
+    process.env.CLAUDEEE_PROFILE = profile;
+  }
   
   log.logo();
   
@@ -355,8 +420,10 @@ async function main() {
         }
         
         // Start both services
+        const launchedAt = Date.now() - 1000;
         const backendProcess = startBackend(backendPort, frontendPort);
-        const frontendProcess = startFrontend(frontendPort, backendPort);
+        const actualBackendPort = await waitForBackendPort(backendPort, launchedAt);
+        const frontendProcess = startFrontend(frontendPort, actualBackendPort);
         
         // Handle process cleanup
         const cleanup = () => {
@@ -374,7 +441,7 @@ async function main() {
         process.on('SIGTERM', cleanup);
         
         log.success('claudeee is running!');
-        log.info(`Backend:  http://localhost:${backendPort}`);
+        log.info(`Backend:  http://localhost:${actualBackendPort}`);
         if (frontendProcess) {
           log.info(`Frontend: http://localhost:${frontendPort}`);
         }
@@ -446,6 +513,43 @@ async function main() {
         
         break;
         
+      case 'autostart': {
+        const startArgs = ['start'];
+        if (backendPort !== 8080) startArgs.push('--backend-port', backendPort.toString());
+        if (frontendPort !== 3000) startArgs.push('--frontend-port', frontendPort.toString());
+        if (profile) startArgs.push('--profile', profile);
+        const autostart = new AutostartManager(__filename, startArgs);
+        
+        switch (subcommand) {
+          case 'enable': {
+            const entryPath = autostart.enable();
+            log.success('claudeee will start automatically at login');
+            log.info(`Login item: ${entryPath}`);
+            break;
+          }
+          case 'disable':
+            if (autostart.disable()) {
+              log.success('Autostart disabled');
+            } else {
+              log.info('Autostart was not enabled');
+            }
+            break;
+          case 'status':
+          case undefined:
+            if (autostart.isEnabled()) {
+              log.success(`Autostart is enabled (${autostart.getEntryPath()})`);
+            } else {
+              log.info('Autostart is disabled');
+            }
+            break;
+          default:
+            log.error(`Unknown autostart command: ${subcommand}`);
+            log.info('Usage: claudeee autostart enable|disable|status');
+            process.exit(1);
+        }
+        break;
+      }
+        
       case 'help':
       case '--help':
       case '-h':
@@ -456,18 +560,22 @@ Commands:
   start, run    Start claudeee (default)
   dev           Start in development mode
   build         Build the application
+  autostart     Start claudeee at login (enable|disable|status)
   help          Show this help message
 
 Options:
   --backend-port, -bp   Backend server port (default: 8080)
   --frontend-port, -fp  Frontend server port (default: 3000)
+  --profile, -p         Use a named profile with its own database and settings
 
 Examples:
   npx claudeee                           # Start with default ports
   npx claudeee --backend-port 8081       # Start with custom backend port
   npx claudeee -bp 8081 -fp 3001         # Start with custom ports
+  npx claudeee --profile work            # Start with the "work" profile
   npx claudeee dev --backend-port 8081   # Development mode with custom backend port
   npx claudeee build                     # Build the application
+  claudeee autostart enable              # Start claudeee automatically at login
 
 For more information, visit: https://github.com/claudeee/claudeee
         `);
@@ -0,0 +1,96 @@
+"use client"
+
+import { useState, useEffect, FormEvent } from "react"
+import { useRouter, useSearchParams } from "next/navigation"
+import { Button } from "@/components/ui/button"
+import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
+import { Input } from "@/components/ui/input"
+import { Label } from "@/components/ui/label"
+import { api, AuthStatus } from "@/lib/api"
+import { useI18n } from "@/hooks/use-i18n"
+
+export default function LoginPage() {
+  const { t } = useI18n()
+  const router = useRouter()
+  const searchParams = useSearchParams()
+  const next = searchParams.get('next') || '/'
+
+  const [status, setStatus] = useState<AuthStatus | null>(null)
+  const [username, setUsername] = useState('')
+  const [password, setPassword] = useState('')
+  const [error, setError] = useState<string | null>(null)
+  const [submitting, setSubmitting] = useState(false)
+
+  useEffect(() => {
+    api.auth.status()
+      .then((result) => {
+        if (result.authenticated) {
+          router.replace(next)
+          return
+        }
+        setStatus(result)
+      })
+      .catch((err) => setError(err instanceof Error ? err.message : 'Unknown error'))
+  }, [router, next])
+
+  const handleSubmit = async (event: FormEvent) => {
+    event.preventDefault()
+    setSubmitting(true)
+    setError(null)
+    try {
+      await api.auth.login(username, password)
+      router.replace(next)
+    } catch {
+      setError(t('login.failed'))
+    } finally {
+      setSubmitting(false)
+    }
+  }
+
+  return (
+    <div className="container mx-auto max-w-md p-6">
+      <Card>
+        <CardHeader>
+          <CardTitle>{t('login.title')}</CardTitle>
+          <CardDescription>{t('login.description')}</CardDescription>
+        </CardHeader>
+        <CardContent>
+          {status?.mode === 'oidc' && (
+            <Button className="w-full" onClick={() => { window.location.href = api.auth.oidcLoginURL() }}>
+              {t('login.sso')}
+            </Button>
+          )}
+          {status?.mode === 'basic' && (
+            <form onSubmit={handleSubmit} className="space-y-4">
+              <div className="space-y-2">
+                <Label htmlFor="username">{t('login.username')}</Label>
+                <Input
+                  id="username"
+                  autoComplete="username"
+                  value={username}
+                  onChange={(e) => setUsername(e.target.value)}
+                  required
+                />
+              </div>
+              <div className="space-y-2">
+                <Label htmlFor="password">{t('login.password')}</Label>
+                <Input
+                  id="password"
+                  type="password"
+                  autoComplete="current-password"
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                  required
+                />
+              </div>
+              <Button type="submit" className="w-full" disabled={submitting}>
+                {t('login.submit')}
+              </Button>
+            </form>
+          )}
+          {error && <p className="mt-4 text-sm text-red-600">{error}</p>}
+        </CardContent>
+      </Card>
+    </div>
+  )
+}
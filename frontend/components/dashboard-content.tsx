@@ -5,7 +5,8 @@ import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs"
 import { TokenUsageCard } from "@/components/token-usage-card"
 import { SessionList } from "@/components/session-list"
 import { ProjectOverview } from "@/components/project-overview"
-import { useTokenUsage, useSessions, useSyncLogs, useAvailableTokens } from "@/hooks/use-api"
+import { ProviderSpendCard } from "@/components/provider-spend-card"
+import { useTokenUsage, useSessions, useSyncLogs, useAvailableTokens, useLiveUpdates, useProviderSpend } from "@/hooks/use-api"
 import { useI18n } from "@/hooks/use-i18n"
 import { Settings, getSettings, PLAN_LIMITS } from "@/lib/settings"
 import { Session } from "@/lib/api"
@@ -17,6 +18,8 @@ export default function Dashboard() {
   const [isRefreshing, setIsRefreshing] = useState(false)
   const [settings] = useState<Settings>(() => getSettings())
   const { data: availableTokens, refetch: refetchAvailable } = useAvailableTokens(settings.plan)
+  const { data: providerSpend, refetch: refetchProviderSpend } = useProviderSpend()
+  const { connected: liveConnected, lastUpdate } = useLiveUpdates()
   const { t } = useI18n()
 
   const refreshData = useCallback(async () => {
@@ -28,17 +31,30 @@ export default function Dashboard() {
       await Promise.all([
         refetchTokens(),
         refetchSessions(),
-        refetchAvailable()
+        refetchAvailable(),
+        refetchProviderSpend()
       ])
     } catch (error) {
       console.error('Error refreshing data:', error)
     } finally {
       setIsRefreshing(false)
     }
-  }, [syncLogs, refetchTokens, refetchSessions, refetchAvailable])
+  }, [syncLogs, refetchTokens, refetchSessions, refetchAvailable, refetchProviderSpend])
 
-  // 自動更新機能（設定可能な間隔）
+  // Token usage arrives with each live update; refresh the rest alongside it
   useEffect(() => {
+    if (lastUpdate?.type === 'sync') {
+      refetchSessions()
+      refetchAvailable()
+      refetchProviderSpend()
+    }
+  }, [lastUpdate]) // eslint-disable-line react-hooks/exhaustive-deps
+
+  // 自動更新機能（設定可能な間隔）。ライブ更新の接続中はサーバーから届くので不要
+  useEffect(() => {
+    if (liveConnected) {
+      return
+    }
     const interval = setInterval(() => {
       if (!isRefreshing) {
         refreshData()
@@ -46,7 +62,7 @@ export default function Dashboard() {
     }, settings.autoRefreshInterval * 1000) // 設定値を秒からミリ秒に変換
 
     return () => clearInterval(interval)
-  }, [isRefreshing, settings.autoRefreshInterval, refreshData])
+  }, [isRefreshing, settings.autoRefreshInterval, refreshData, liveConnected])
 
   const convertSessionsToProjects = (sessions: Session[]) => {
     const projectMap = new Map()
@@ -127,6 +143,9 @@ export default function Dashboard() {
           />
         ) : null}
 
+        {/* Spend of Claude Code, Codex CLI and Gemini CLI side by side */}
+        <ProviderSpendCard providers={providerSpend} />
+
         {/* Main Content */}
         <Tabs defaultValue="overview" className="space-y-4">
           <TabsList>
@@ -0,0 +1,47 @@
+import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card"
+import { ModelUsageSummary } from "@/lib/api"
+import { useI18n } from "@/hooks/use-i18n"
+
+const PROVIDER_NAMES: Record<string, string> = {
+  claude: "Claude Code",
+  codex: "Codex CLI",
+  gemini: "Gemini CLI",
+}
+
+interface ProviderSpendCardProps {
+  providers: ModelUsageSummary[]
+}
+
+// ProviderSpendCard compares the spend of each tool side by side; it is
+// hidden while only one tool has logged usage
+export function ProviderSpendCard({ providers }: ProviderSpendCardProps) {
+  const { t } = useI18n()
+
+  if (providers.length < 2) {
+    return null
+  }
+
+  return (
+    <Card>
+      <CardHeader className="pb-2">
+        <CardTitle className="text-sm font-medium">{t('providerSpend.title')}</CardTitle>
+      </CardHeader>
+      <CardContent>
+        <div className="grid gap-4 md:grid-cols-3">
+          {providers.map((provider) => (
+            <div key={provider.model} className="space-y-1 rounded-lg border p-3">
+              <div className="text-sm font-medium">{PROVIDER_NAMES[provider.model] ?? provider.model}</div>
+              <div className="text-2xl font-bold text-green-600">${provider.cost.toFixed(2)}</div>
+              <div className="text-xs text-muted-foreground">
+                {Math.round(provider.cost_share * 100)}% {t('providerSpend.share')}
+              </div>
+              <div className="text-xs text-muted-foreground">
+                {provider.total_tokens.toLocaleString()} {t('tokenUsage.tokens')} · {provider.message_count.toLocaleString()} {t('providerSpend.messages')} · {provider.session_count.toLocaleString()} {t('providerSpend.sessions')}
+              </div>
+            </div>
+          ))}
+        </div>
+      </CardContent>
+    </Card>
+  )
+}
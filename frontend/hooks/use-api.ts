@@ -1,7 +1,7 @@
 "use client"
 
 import { useState, useEffect } from 'react'
-import { api, TokenUsage, Session } from '@/lib/api'
+import { api, TokenUsage, Session, LiveUpdate, ModelUsageSummary } from '@/lib/api'
 
 export function useTokenUsage() {
   const [data, setData] = useState<TokenUsage | null>(null)
@@ -26,9 +26,27 @@ export function useTokenUsage() {
     fetchData()
   }, [])
 
+  // The server pushes fresh usage after every sync
+  useEffect(() => {
+    return api.live.subscribe((update) => setData(update.token_usage))
+  }, [])
+
   return { data, loading, error, refetch: fetchData }
 }
 
+// useLiveUpdates reports whether the live update connection is open and the
+// latest update it delivered
+export function useLiveUpdates() {
+  const [connected, setConnected] = useState(false)
+  const [lastUpdate, setLastUpdate] = useState<LiveUpdate | null>(null)
+
+  useEffect(() => {
+    return api.live.subscribe(setLastUpdate, setConnected)
+  }, [])
+
+  return { connected, lastUpdate }
+}
+
 export function useSessions() {
   const [data, setData] = useState<Session[]>([])
   const [loading, setLoading] = useState(true)
@@ -86,6 +104,36 @@ export function useAvailableTokens(plan: string = 'pro') {
   return { data, loading, error, refetch: fetchData }
 }
 
+// useProviderSpend returns this month's usage of each tool: Claude Code,
+// Codex CLI and Gemini CLI
+export function useProviderSpend() {
+  const [data, setData] = useState<ModelUsageSummary[]>([])
+  const [loading, setLoading] = useState(true)
+  const [error, setError] = useState<string | null>(null)
+
+  const fetchData = async () => {
+    try {
+      setLoading(true)
+      setError(null)
+      const now = new Date()
+      const monthStart = new Date(now.getFullYear(), now.getMonth(), 1)
+      const report = await api.usage.byModel(monthStart.toISOString(), now.toISOString())
+      setData(report.providers ?? [])
+    } catch (err) {
+      console.error('Error fetching provider spend:', err)
+      setError(err instanceof Error ? err.message : 'Unknown error')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  useEffect(() => {
+    fetchData()
+  }, [])
+
+  return { data, loading, error, refetch: fetchData }
+}
+
 export function useSyncLogs() {
   const [loading, setLoading] = useState(false)
   const [error, setError] = useState<string | null>(null)
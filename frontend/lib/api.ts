@@ -1,6 +1,31 @@
 const API_BASE_URL = process.env.NEXT_PUBLIC_API_URL ? 
-  `${process.env.NEXT_PUBLIC_API_URL}/api` : 
-  'http://localhost:8080/api'
+  `${process.env.NEXT_PUBLIC_API_URL}/api/v1` : 
+  'http://localhost:8080/api/v1'
+
+export type SessionSortField = 'start_time' | 'end_time' | 'total_tokens' | 'message_count' | 'project_name'
+
+export interface SessionQuery {
+  limit?: number
+  offset?: number
+  sort?: SessionSortField | `-${SessionSortField}`
+  project?: string
+  user?: string
+  model?: string
+  status?: string
+  // Sessions carrying every tag; several tags are separated by commas
+  tag?: string
+  from?: string
+  to?: string
+}
+
+export interface SessionPage {
+  sessions: Session[]
+  count: number
+  total: number
+  limit: number
+  offset: number
+  has_more: boolean
+}
 
 export interface TokenUsage {
   total_tokens: number
@@ -31,6 +56,66 @@ export interface Session {
   is_active: boolean
   last_activity: string
   generated_code: string[]
+  // The first prompt on one line; null until a prompt is synced or for viewers
+  title: string | null
+  tags: string[]
+  notes: string | null
+  // Set when every log file of the session was deleted
+  source_missing_at: string | null
+  // The tool that logged the session
+  provider: 'claude' | 'codex' | 'gemini'
+}
+
+export interface ActivityBurst {
+  start: string
+  end: string
+  duration_minutes: number
+  message_count: number
+  tool_calls: number
+  total_tokens: number
+  tool_calls_per_minute: number
+}
+
+export interface IdleGap {
+  start: string
+  end: string
+  duration_minutes: number
+}
+
+export interface HourlyActivity {
+  hour: string
+  input_tokens: number
+  output_tokens: number
+  total_tokens: number
+  message_count: number
+  tool_calls: number
+}
+
+export interface SessionTimeline {
+  start: string | null
+  end: string | null
+  idle_gap_minutes: number
+  active_minutes: number
+  idle_minutes: number
+  tool_calls_per_active_minute: number
+  bursts: ActivityBurst[]
+  gaps: IdleGap[]
+  hours: HourlyActivity[]
+}
+
+export interface SessionActivityReport {
+  session_id: string
+  is_active: boolean
+  total_score: number
+  inactive_reason: string
+  last_activity: string
+  timeline: SessionTimeline
+}
+
+export interface SessionUpdate {
+  tags?: string[]
+  // An empty string clears the notes
+  notes?: string
 }
 
 export interface Message {
@@ -69,6 +154,687 @@ export interface SessionDetail {
   token_usage: TokenUsage
 }
 
+export interface TranscriptToolCall {
+  id: string
+  tool_name: string
+  status: string
+  duration_ms: number | null
+  input_size: number
+}
+
+export interface TranscriptMessage extends Message {
+  kind: 'prompt' | 'response' | 'tool_use' | 'tool_result' | string
+  branch: boolean
+  tool_calls?: TranscriptToolCall[]
+  tool_results?: TranscriptToolCall[]
+  sidechain?: TranscriptMessage[]
+}
+
+export interface SessionTranscript {
+  session_id: string
+  messages: TranscriptMessage[]
+  total: number
+  page: number
+  page_size: number
+  total_pages: number
+  has_next: boolean
+  has_previous: boolean
+}
+
+export interface RuntimeConfig {
+  plan: 'pro' | 'max5' | 'max20' | 'custom'
+  plan_token_limit: number
+  timezone: string
+  warning_threshold: number
+  critical_threshold: number
+  sync_interval_minutes: number
+  content_policy: 'full' | 'truncated' | 'metadata'
+  content_max_kb: number
+  content_compression: boolean
+  content_retention_days: number
+  redact_secrets: boolean
+  readonly privacy_mode: boolean
+}
+
+export interface PlanUtilization {
+  plan: string
+  usage_limit: number
+  used_tokens: number
+  remaining_tokens: number
+  utilization_percent: number
+  window_start: string
+  window_end: string
+  tokens_per_minute: number
+  estimated_minutes_to_limit: number | null
+  estimated_limit_at: string | null
+  limit_before_reset: boolean
+}
+
+export interface UsageForecast {
+  usage_limit: number
+  used_tokens: number
+  remaining_tokens: number
+  window_start: string
+  window_end: string
+  lookback_minutes: number
+  tokens_per_minute: number
+  average_tokens_per_minute: number
+  projected_window_tokens: number
+  minutes_to_limit: number | null
+  limit_at: string | null
+  limit_before_reset: boolean
+}
+
+export interface CostProjection {
+  method: 'linear' | 'exponential'
+  window_days: number
+  daily_rate: number
+  remaining_cost: number
+  projected_cost: number
+}
+
+export interface CostForecastBreakdown {
+  name: string
+  month_to_date: number
+  projections: CostProjection[]
+}
+
+export interface Percentiles {
+  count: number
+  p50: number
+  p90: number
+  p99: number
+  max: number
+}
+
+export interface PercentilePeriod {
+  days: number
+  since: string
+  // Absent when the report is limited to one user
+  windows?: Percentiles
+  sessions: Percentiles
+}
+
+export interface PercentileReport {
+  generated_at: string
+  periods: PercentilePeriod[]
+}
+
+export interface CostForecast {
+  currency: string
+  timezone: string
+  month_start: string
+  month_end: string
+  days_remaining: number
+  month_to_date: number
+  projections: CostProjection[]
+  models: CostForecastBreakdown[]
+  projects: CostForecastBreakdown[]
+}
+
+export interface BillingCost {
+  messages: number
+  tokens: number
+  // At API prices; subscription usage is covered by the plan fee
+  cost: number
+}
+
+export interface BillingBreakdown {
+  default_billing: 'subscription' | 'api'
+  plan: string
+  plan_fee: number
+  subscribers: number
+  subscription: BillingCost
+  api: BillingCost
+  // Plan fees plus API usage
+  billed_cost: number
+}
+
+export interface MonthCosts {
+  currency: string
+  timezone: string
+  month_start: string
+  month_end: string
+  current_month_cost: number
+  billing?: BillingBreakdown
+}
+
+export interface SearchHighlight {
+  start: number
+  end: number
+}
+
+export interface MessageSearchHit {
+  message_id: string
+  session_id: string
+  project_name: string
+  project_path: string
+  role: string | null
+  model: string | null
+  timestamp: string
+  snippet: string
+  highlights: SearchHighlight[]
+}
+
+export interface SessionSearchHit {
+  session_id: string
+  project_name: string
+  project_path: string
+  start_time: string
+}
+
+export interface SearchResults {
+  query: string
+  sessions: SessionSearchHit[]
+  messages: MessageSearchHit[]
+  content_searched: boolean
+}
+
+export type ExportFormat = 'csv' | 'json' | 'jsonl'
+
+export interface ExportOptions {
+  format?: ExportFormat
+  project?: string
+  from?: string
+  to?: string
+  content?: boolean
+}
+
+export interface WatcherStatus {
+  running: boolean
+  roots: string[]
+  watched_dirs: number
+  debounce_ms: number
+  last_event: string | null
+  last_trigger: string | null
+  triggers: number
+}
+
+export interface SchedulerStatus {
+  running: boolean
+  paused: boolean
+  interval_minutes: number
+  next_run: string | null
+  last_run: string | null
+  last_job: SyncJob | null
+  runs: number
+}
+
+// A log line sync could not parse; raw_content is only sent to admins and
+// follows the content policy
+export interface SyncParseError {
+  file_path: string
+  line_number: number
+  error: string
+  raw_content?: string
+  detected_at: string
+}
+
+export interface SyncParseErrorQuery {
+  file?: string
+  limit?: number
+  offset?: number
+}
+
+export interface SyncParseErrorPage {
+  errors: SyncParseError[]
+  count: number
+  total: number
+}
+
+export interface SyncJob {
+  id: string
+  status: 'queued' | 'running' | 'completed' | 'failed'
+  trigger: string
+  created_at: string
+  started_at?: string
+  finished_at?: string
+  stats?: {
+    total_files: number
+    processed_files: number
+    skipped_files: number
+    failed_files: number
+    removed_files: number
+    new_lines: number
+    duplicate_lines: number
+    parse_errors: number
+    redactions: number
+    redactions_by_kind?: Record<string, number>
+  }
+  error?: string
+  // Running totals, updated while the job runs
+  progress?: {
+    files_found: number
+    files_done: number
+    lines_processed: number
+    errors: number
+    project?: string
+  }
+  // Files that failed; the job can still complete
+  file_errors?: { file: string; error: string }[]
+}
+
+export type SyncProgressType = 'started' | 'discovered' | 'project' | 'file' | 'finished'
+
+// One step of a running sync from GET /sync-logs/stream; counters are totals for the pass
+export interface SyncProgress {
+  type: SyncProgressType
+  job_id?: string
+  project?: string
+  project_files?: number
+  file?: string
+  file_status?: 'processed' | 'skipped' | 'error'
+  file_lines?: number
+  files_found: number
+  files_done: number
+  lines_processed: number
+  errors: number
+  error?: string
+  status?: SyncJob['status']
+  time: string
+}
+
+export interface UsageTotals {
+  input_tokens: number
+  output_tokens: number
+  cache_creation_input_tokens: number
+  cache_read_input_tokens: number
+  total_tokens: number
+  message_count: number
+  session_count: number
+}
+
+export interface DailyUsage extends UsageTotals {
+  day: string
+}
+
+export type UsageGranularity = 'day' | 'week' | 'month'
+
+export interface ModelPeriodUsage extends UsageTotals {
+  model: string
+  cost: number
+}
+
+export interface UsagePeriod extends UsageTotals {
+  start: string
+  end: string
+  cost: number
+  models: ModelPeriodUsage[]
+}
+
+export interface DailyUsageReport {
+  days: DailyUsage[]
+  count: number
+  granularity: UsageGranularity
+  timezone: string
+  from: string
+  to: string
+  periods: UsagePeriod[]
+}
+
+export interface HeatmapCell {
+  weekday: number
+  hour: number
+  total_tokens: number
+  message_count: number
+  cost: number
+}
+
+// Rows are weekdays from Sunday, columns hours from midnight
+export interface UsageHeatmap {
+  timezone: string
+  from: string
+  to: string
+  tokens: number[][]
+  messages: number[][]
+  cost: number[][]
+  peak: HeatmapCell | null
+}
+
+export interface ProjectUsage extends UsageTotals {
+  project_name: string
+  last_activity: string | null
+}
+
+export interface ModelUsageSummary extends UsageTotals {
+  model: string
+  family: 'opus' | 'sonnet' | 'haiku' | 'other' | ''
+  cost: number
+  cache_hit_ratio: number
+  token_share: number
+  cost_share: number
+}
+
+export interface ModelUsageReport {
+  models: ModelUsageSummary[]
+  families: ModelUsageSummary[]
+  // One entry per tool, its name in model
+  providers: ModelUsageSummary[]
+  total: ModelUsageSummary
+}
+
+export interface UserSummary {
+  id: string
+  name: string
+  session_count: number
+  message_count: number
+  total_tokens: number
+  last_activity: string | null
+}
+
+export interface ProjectSummary {
+  project_name: string
+  input_tokens: number
+  output_tokens: number
+  cache_creation_input_tokens: number
+  cache_read_input_tokens: number
+  total_tokens: number
+  cost: number
+  message_count: number
+  session_count: number
+  first_activity: string | null
+  last_activity: string | null
+}
+
+export interface TagSummary {
+  tag: string
+  input_tokens: number
+  output_tokens: number
+  cache_creation_input_tokens: number
+  cache_read_input_tokens: number
+  total_tokens: number
+  cost: number
+  message_count: number
+  session_count: number
+}
+
+export interface ModelUsage {
+  model: string
+  input_tokens: number
+  output_tokens: number
+  cache_creation_input_tokens: number
+  cache_read_input_tokens: number
+  total_tokens: number
+  cost: number
+  message_count: number
+  token_share: number
+}
+
+export interface ProjectUsageDetail extends ProjectSummary {
+  models: ModelUsage[]
+}
+
+export type TaskPriority = 'high' | 'medium' | 'low'
+export type TaskStatus = 'queued' | 'running' | 'completed' | 'failed' | 'cancelled'
+
+export interface TaskInput {
+  title: string
+  prompt?: string
+  project_path?: string
+  estimated_tokens?: number
+  priority?: TaskPriority
+  status?: TaskStatus
+}
+
+export interface Task extends Required<TaskInput> {
+  id: string
+  session_id: string
+  started_at: string | null
+  finished_at: string | null
+  error: string
+  created_at: string
+  updated_at: string
+}
+
+export interface ScheduledTask extends Task {
+  window: number
+  start_after: string | null
+}
+
+export interface TaskSchedule {
+  usage_limit: number
+  used_tokens: number
+  remaining_tokens: number
+  reset_time: string
+  scheduled_tokens: number
+  run_now: ScheduledTask[]
+  deferred: ScheduledTask[]
+  too_large: Task[]
+}
+
+export type BudgetPeriod = 'monthly' | 'window'
+export type BudgetMetric = 'cost' | 'tokens'
+
+export interface BudgetInput {
+  name: string
+  project?: string
+  period: BudgetPeriod
+  metric: BudgetMetric
+  amount: number
+  thresholds?: number[]
+  enabled?: boolean
+}
+
+export interface Budget extends Required<BudgetInput> {
+  id: string
+  created_at: string
+  updated_at: string
+}
+
+// A budget's spending in its current period; the period is null for a window budget outside a session window
+export interface BudgetStatus extends Budget {
+  period_start: string | null
+  period_end: string | null
+  spent: number
+  utilization: number
+  exceeded: boolean
+}
+
+export type WebhookEventType = 'window.threshold' | 'budget.exceeded' | 'sync.failed'
+
+export interface WebhookInput {
+  url: string
+  events: WebhookEventType[]
+  // Generated when omitted on create; kept when omitted on update
+  secret?: string
+  enabled?: boolean
+}
+
+export interface Webhook {
+  id: string
+  url: string
+  events: WebhookEventType[]
+  enabled: boolean
+  // Only present in the response to creating the webhook
+  secret?: string
+  created_at: string
+  updated_at: string
+}
+
+export interface WebhookDelivery {
+  id: string
+  webhook_id: string
+  event: WebhookEventType
+  status: 'pending' | 'delivered' | 'failed'
+  attempts: number
+  response_status: number | null
+  error: string | null
+  created_at: string
+  completed_at: string | null
+}
+
+export interface BudgetAlert {
+  id: string
+  budget_id: string
+  budget_name: string
+  project: string
+  metric: BudgetMetric
+  threshold: number
+  amount: number
+  spent: number
+  period_start: string
+  period_end: string
+  triggered_at: string
+}
+
+export interface ToolUsage {
+  tool_name: string
+  calls: number
+  succeeded: number
+  failed: number
+  pending: number
+  avg_duration_ms: number
+  total_input_bytes: number
+  input_tokens: number
+  output_tokens: number
+  cache_creation_input_tokens: number
+  cache_read_input_tokens: number
+  total_tokens: number
+  cost: number
+  cost_share: number
+}
+
+export interface ToolUsageReport {
+  tools: ToolUsage[]
+  total_calls: number
+  total_cost: number
+}
+
+export interface AuthStatus {
+  mode: 'none' | 'basic' | 'oidc'
+  authenticated: boolean
+  user: string
+  // viewers see usage data but no message content
+  role: 'admin' | 'viewer' | ''
+}
+
+export interface AuditEntry {
+  accessed_at: string
+  user: string
+  method: string
+  path: string
+  status: number
+  client_ip: string
+  user_agent: string
+  duration_ms: number
+}
+
+export interface AuditLogQuery {
+  user?: string
+  path?: string
+  since?: string
+  limit?: number
+  offset?: number
+}
+
+export interface AuditLogPage {
+  enabled: boolean
+  entries: AuditEntry[]
+  count: number
+  total: number
+  dropped: number
+}
+
+export interface PruneResult {
+  retention_days: number
+  cutoff: string
+  pruned_messages: number
+  pruned_parse_errors: number
+}
+
+// A log entry whose usage repeated that of duplicate_of, the entry counted
+// for the same request and API message
+export interface DuplicateMessage {
+  message_id: string
+  duplicate_of: string
+  request_id: string
+  api_message_id: string
+  session_id: string
+  input_tokens: number
+  cache_creation_input_tokens: number
+  cache_read_input_tokens: number
+  output_tokens: number
+  timestamp: string
+  detected_at: string
+}
+
+export interface DuplicateReport {
+  count: number
+  suppressed_tokens: number
+  recent: DuplicateMessage[]
+}
+
+// A stored total that differs from what its messages add up to
+export interface AggregateDiscrepancy {
+  table: 'sessions' | 'session_windows'
+  id: string
+  field: string
+  stored: number
+  expected: number
+}
+
+export interface AggregateReport {
+  dry_run: boolean
+  sessions_checked: number
+  windows_checked: number
+  sessions_fixed: number
+  windows_fixed: number
+  discrepancy_count: number
+  // At most 500 are listed
+  discrepancies: AggregateDiscrepancy[]
+}
+
+export interface LimitEvent {
+  // UUID of the log entry
+  id: string
+  session_id: string
+  kind: 'usage_limit' | 'rate_limit'
+  timestamp: string
+  // When the limit lifts, if the notice said
+  reset_at: string | null
+  message: string
+}
+
+export interface LimitEventReport {
+  count: number
+  // The last time a usage limit was reached
+  last_hit_at: string | null
+  recent: LimitEvent[]
+}
+
+export interface DatabaseBackup {
+  name: string
+  created_at: string
+  tables: { name: string; rows: number }[]
+  path: string
+  size_bytes: number
+}
+
+export interface SessionWindow {
+  id: string
+  window_start: string
+  window_end: string
+  reset_time: string
+  total_input_tokens: number
+  total_output_tokens: number
+  total_tokens: number
+  message_count: number
+  session_count: number
+  is_active: boolean
+}
+
+export interface LiveUpdate {
+  type: 'snapshot' | 'sync'
+  sync_job_id?: string
+  token_usage: TokenUsage
+  session_window: SessionWindow | null
+  cost: {
+    window_cost: number
+    currency: string
+  }
+  sent_at: string
+}
+
 export interface ApiResponse<T> {
   data?: T
   error?: string
@@ -90,9 +856,15 @@ class ApiClient {
         'Content-Type': 'application/json',
         ...options.headers,
       },
+      // Send the session cookie when authentication is enabled
+      credentials: 'include',
       ...options,
     })
 
+    if (response.status === 401 && typeof window !== 'undefined' && !window.location.pathname.startsWith('/login')) {
+      window.location.href = `/login?next=${encodeURIComponent(window.location.pathname + window.location.search)}`
+    }
+
     if (!response.ok) {
       throw new Error(`HTTP error! status: ${response.status}`)
     }
@@ -108,6 +880,22 @@ class ApiClient {
     return this.request<{ sessions: Session[], count: number }>('/claude/sessions/recent')
   }
 
+  async querySessions(query: SessionQuery = {}): Promise<SessionPage> {
+    const params = new URLSearchParams()
+    for (const [key, value] of Object.entries(query)) {
+      if (value !== undefined && value !== '') params.set(key, String(value))
+    }
+    const search = params.toString()
+    return this.request(`/sessions${search ? `?${search}` : ''}`)
+  }
+
+  async search(q: string, project?: string, limit?: number): Promise<SearchResults> {
+    const params = new URLSearchParams({ q })
+    if (project) params.set('project', project)
+    if (limit) params.set('limit', String(limit))
+    return this.request(`/search?${params.toString()}`)
+  }
+
   async getSessionDetail(sessionId: string, page?: number, pageSize?: number): Promise<SessionDetail> {
     let url = `/sessions/${sessionId}`
     if (page !== undefined || pageSize !== undefined) {
@@ -119,55 +907,538 @@ class ApiClient {
     return this.request<SessionDetail>(url)
   }
 
-  async getAvailableTokens(plan: string = 'pro'): Promise<{
+  async getSessionActivity(sessionId: string, gapMinutes?: number, tz?: string): Promise<SessionActivityReport> {
+    const params = new URLSearchParams()
+    if (gapMinutes !== undefined) params.set('gap_minutes', gapMinutes.toString())
+    if (tz) params.set('tz', tz)
+    const search = params.toString()
+    return this.request(`/sessions/${encodeURIComponent(sessionId)}/activity${search ? `?${search}` : ''}`)
+  }
+
+  async getSessionTranscript(sessionId: string, page?: number, pageSize?: number): Promise<SessionTranscript> {
+    const params = new URLSearchParams()
+    if (page !== undefined) params.append('page', page.toString())
+    if (pageSize !== undefined) params.append('page_size', pageSize.toString())
+    const search = params.toString()
+    return this.request<SessionTranscript>(`/sessions/${sessionId}/messages${search ? `?${search}` : ''}`)
+  }
+
+  // Without a plan the server's configured plan is used
+  async getAvailableTokens(plan?: string): Promise<{
     available_tokens: number
     plan: string
     usage_limit: number
     used_tokens: number
+    forecast?: UsageForecast
   }> {
-    return this.request(`/claude/available-tokens?plan=${plan}`)
+    return this.request(`/claude/available-tokens${plan ? `?plan=${encodeURIComponent(plan)}` : ''}`)
   }
 
-  async getCurrentMonthCosts(): Promise<{
-    current_month_cost: number
-    currency: string
-    note: string
-  }> {
-    return this.request('/costs/current-month')
+  async getPlanUtilization(): Promise<PlanUtilization> {
+    return this.request('/plan/utilization')
   }
 
-  async getTasks(): Promise<{
-    tasks: unknown[]
-    count: number
-    note: string
-  }> {
-    return this.request('/tasks')
+  async getForecast(plan?: string): Promise<{ plan: string; forecast: UsageForecast }> {
+    return this.request(`/forecast${plan ? `?plan=${encodeURIComponent(plan)}` : ''}`)
+  }
+
+  async getPercentiles(days?: number[]): Promise<PercentileReport> {
+    return this.request(`/stats/percentiles${days?.length ? `?days=${days.join(',')}` : ''}`)
+  }
+
+  async getCurrentMonthCosts(breakdown?: 'billing', tz?: string): Promise<MonthCosts> {
+    const params = new URLSearchParams()
+    if (breakdown) params.set('breakdown', breakdown)
+    if (tz) params.set('tz', tz)
+    const qs = params.toString()
+    return this.request(`/costs/current-month${qs ? `?${qs}` : ''}`)
+  }
+
+  async getCostForecast(tz?: string): Promise<CostForecast> {
+    return this.request(`/costs/forecast${tz ? `?tz=${encodeURIComponent(tz)}` : ''}`)
+  }
+
+  async getTasks(status?: TaskStatus): Promise<{ tasks: Task[]; count: number }> {
+    return this.request(`/tasks${status ? `?status=${status}` : ''}`)
+  }
+
+  async getTask(id: string): Promise<Task> {
+    return this.request(`/tasks/${encodeURIComponent(id)}`)
+  }
+
+  async createTask(task: TaskInput): Promise<Task> {
+    return this.request('/tasks', {
+      method: 'POST',
+      body: JSON.stringify(task),
+    })
+  }
+
+  async updateTask(id: string, task: TaskInput): Promise<Task> {
+    return this.request(`/tasks/${encodeURIComponent(id)}`, {
+      method: 'PUT',
+      body: JSON.stringify(task),
+    })
+  }
+
+  async deleteTask(id: string): Promise<{ message: string; id: string }> {
+    return this.request(`/tasks/${encodeURIComponent(id)}`, { method: 'DELETE' })
+  }
+
+  async runTask(id: string): Promise<Task> {
+    return this.request(`/tasks/${encodeURIComponent(id)}/run`, { method: 'POST' })
+  }
+
+  async cancelTask(id: string): Promise<{ message: string; id: string }> {
+    return this.request(`/tasks/${encodeURIComponent(id)}/cancel`, { method: 'POST' })
+  }
+
+  async getTaskSchedule(): Promise<TaskSchedule> {
+    return this.request('/tasks/schedule')
   }
 
-  async syncLogs(): Promise<{ message: string }> {
+  async syncLogs(): Promise<{ message: string; job: SyncJob }> {
     return this.request('/sync-logs', { method: 'POST' })
   }
 
+  async getSyncJob(id: string): Promise<SyncJob> {
+    return this.request<SyncJob>(`/sync-jobs/${id}`)
+  }
+
+  async getSyncErrors(query: SyncParseErrorQuery = {}): Promise<SyncParseErrorPage> {
+    const params = new URLSearchParams()
+    Object.entries(query).forEach(([key, value]) => {
+      if (value !== undefined && value !== '') {
+        params.set(key, String(value))
+      }
+    })
+    const qs = params.toString()
+    return this.request(`/sync/errors${qs ? `?${qs}` : ''}`)
+  }
+
+  // Queue a sync and poll until it finishes; the endpoint returns immediately.
+  // onPoll receives each status, including the running job's progress.
+  async syncLogsAndWait(pollIntervalMs = 500, onPoll?: (job: SyncJob) => void): Promise<SyncJob> {
+    let { job } = await this.syncLogs()
+    while (job.status === 'queued' || job.status === 'running') {
+      await new Promise((resolve) => setTimeout(resolve, pollIntervalMs))
+      job = await this.getSyncJob(job.id)
+      onPoll?.(job)
+    }
+    if (job.status === 'failed') {
+      throw new Error(job.error || 'Sync failed')
+    }
+    return job
+  }
+
+  async getWatcherStatus(): Promise<WatcherStatus> {
+    return this.request<WatcherStatus>('/watcher')
+  }
+
+  async startWatcher(): Promise<WatcherStatus> {
+    return this.request<WatcherStatus>('/watcher/start', { method: 'POST' })
+  }
+
+  async stopWatcher(): Promise<WatcherStatus> {
+    return this.request<WatcherStatus>('/watcher/stop', { method: 'POST' })
+  }
+
+  async getSchedulerStatus(): Promise<SchedulerStatus> {
+    return this.request<SchedulerStatus>('/scheduler')
+  }
+
+  async startScheduler(): Promise<SchedulerStatus> {
+    return this.request<SchedulerStatus>('/scheduler/start', { method: 'POST' })
+  }
+
+  async stopScheduler(): Promise<SchedulerStatus> {
+    return this.request<SchedulerStatus>('/scheduler/stop', { method: 'POST' })
+  }
+
+  async getDailyUsage(days = 30): Promise<DailyUsageReport> {
+    return this.request(`/usage/daily?days=${days}`)
+  }
+
+  async getUsageRollups(granularity: UsageGranularity, from?: string, to?: string, tz?: string): Promise<DailyUsageReport> {
+    const params = new URLSearchParams({ granularity })
+    if (from) params.set('from', from)
+    if (to) params.set('to', to)
+    if (tz) params.set('tz', tz)
+    return this.request(`/usage/daily?${params.toString()}`)
+  }
+
+  async getUsageHeatmap(days = 30, tz?: string): Promise<UsageHeatmap> {
+    const params = new URLSearchParams({ days: String(days) })
+    if (tz) params.set('tz', tz)
+    return this.request(`/usage/heatmap?${params.toString()}`)
+  }
+
+  async getProjectUsage(): Promise<{ projects: ProjectUsage[]; count: number }> {
+    return this.request('/usage/projects')
+  }
+
+  async getModelUsage(from?: string, to?: string, project?: string): Promise<ModelUsageReport> {
+    const params = new URLSearchParams()
+    if (from) params.set('from', from)
+    if (to) params.set('to', to)
+    if (project) params.set('project', project)
+    const query = params.toString()
+    return this.request(`/usage/by-model${query ? `?${query}` : ''}`)
+  }
+
+  async getToolUsage(since?: string, until?: string): Promise<ToolUsageReport> {
+    return this.request(`/tool-usage${timeRangeQuery(since, until)}`)
+  }
+
+  async getProjects(since?: string, until?: string, user?: string): Promise<{ projects: ProjectSummary[]; count: number }> {
+    return this.request(`/projects${timeRangeQuery(since, until, user)}`)
+  }
+
+  async updateSession(id: string, update: SessionUpdate): Promise<Session> {
+    return this.request(`/sessions/${encodeURIComponent(id)}`, {
+      method: 'PATCH',
+      body: JSON.stringify(update),
+    })
+  }
+
+  async addSessionTags(id: string, tags: string[]): Promise<Session> {
+    return this.request(`/sessions/${encodeURIComponent(id)}/tags`, {
+      method: 'POST',
+      body: JSON.stringify({ tags }),
+    })
+  }
+
+  async removeSessionTag(id: string, tag: string): Promise<Session> {
+    return this.request(`/sessions/${encodeURIComponent(id)}/tags/${encodeURIComponent(tag)}`, { method: 'DELETE' })
+  }
+
+  async getTags(since?: string, until?: string, user?: string): Promise<{ tags: TagSummary[]; count: number }> {
+    return this.request(`/tags${timeRangeQuery(since, until, user)}`)
+  }
+
+  async getUsers(): Promise<{ users: UserSummary[]; count: number }> {
+    return this.request('/users')
+  }
+
+  async getProjectDetail(name: string, since?: string, until?: string): Promise<ProjectUsageDetail> {
+    return this.request(`/projects/${encodeURIComponent(name)}/usage${timeRangeQuery(since, until)}`)
+  }
+
+  async getBudgets(): Promise<{ budgets: BudgetStatus[]; count: number }> {
+    return this.request('/budgets')
+  }
+
+  async getBudget(id: string): Promise<BudgetStatus> {
+    return this.request(`/budgets/${encodeURIComponent(id)}`)
+  }
+
+  async createBudget(budget: BudgetInput): Promise<Budget> {
+    return this.request('/budgets', {
+      method: 'POST',
+      body: JSON.stringify(budget),
+    })
+  }
+
+  async updateBudget(id: string, budget: BudgetInput): Promise<Budget> {
+    return this.request(`/budgets/${encodeURIComponent(id)}`, {
+      method: 'PUT',
+      body: JSON.stringify(budget),
+    })
+  }
+
+  async deleteBudget(id: string): Promise<{ message: string; id: string }> {
+    return this.request(`/budgets/${encodeURIComponent(id)}`, { method: 'DELETE' })
+  }
+
+  async getAlerts(budgetId?: string, since?: string, limit?: number): Promise<{ alerts: BudgetAlert[]; count: number }> {
+    const params = new URLSearchParams()
+    if (budgetId) params.set('budget_id', budgetId)
+    if (since) params.set('since', since)
+    if (limit) params.set('limit', String(limit))
+    const qs = params.toString()
+    return this.request(`/alerts${qs ? `?${qs}` : ''}`)
+  }
+
+  async getWebhooks(): Promise<{ webhooks: Webhook[]; count: number; event_types: WebhookEventType[] }> {
+    return this.request('/webhooks')
+  }
+
+  async createWebhook(webhook: WebhookInput): Promise<Webhook> {
+    return this.request('/webhooks', {
+      method: 'POST',
+      body: JSON.stringify(webhook),
+    })
+  }
+
+  async updateWebhook(id: string, webhook: WebhookInput): Promise<Webhook> {
+    return this.request(`/webhooks/${encodeURIComponent(id)}`, {
+      method: 'PUT',
+      body: JSON.stringify(webhook),
+    })
+  }
+
+  async deleteWebhook(id: string): Promise<{ message: string; id: string }> {
+    return this.request(`/webhooks/${encodeURIComponent(id)}`, { method: 'DELETE' })
+  }
+
+  async getWebhookDeliveries(id: string, limit?: number): Promise<{ deliveries: WebhookDelivery[]; count: number }> {
+    return this.request(`/webhooks/${encodeURIComponent(id)}/deliveries${limit ? `?limit=${limit}` : ''}`)
+  }
+
+  async getAuthStatus(): Promise<AuthStatus> {
+    return this.request('/auth/status')
+  }
+
+  async login(username: string, password: string): Promise<{ user: string }> {
+    return this.request('/auth/login', {
+      method: 'POST',
+      body: JSON.stringify({ username, password }),
+    })
+  }
+
+  async logout(): Promise<{ message: string }> {
+    return this.request('/auth/logout', { method: 'POST' })
+  }
+
+  // Receive dashboard updates over WebSocket after every sync. Reconnects
+  // until the returned function is called.
+  subscribeLiveUpdates(onUpdate: (update: LiveUpdate) => void, onConnectionChange?: (connected: boolean) => void): () => void {
+    const url = this.baseURL.replace(/^http/, 'ws') + '/ws'
+    let socket: WebSocket | null = null
+    let retryTimer: ReturnType<typeof setTimeout> | undefined
+    let retryDelay = 1000
+    let closed = false
+
+    const connect = () => {
+      socket = new WebSocket(url)
+      socket.onopen = () => {
+        retryDelay = 1000
+        onConnectionChange?.(true)
+      }
+      socket.onmessage = (event) => {
+        onUpdate(JSON.parse(event.data) as LiveUpdate)
+      }
+      socket.onclose = () => {
+        onConnectionChange?.(false)
+        if (!closed) {
+          retryTimer = setTimeout(connect, retryDelay)
+          retryDelay = Math.min(retryDelay * 2, 30000)
+        }
+      }
+    }
+    connect()
+
+    return () => {
+      closed = true
+      clearTimeout(retryTimer)
+      socket?.close()
+    }
+  }
+
+  // Receive sync progress as Server-Sent Events. The browser reconnects on
+  // its own; the returned function closes the stream.
+  subscribeSyncProgress(onProgress: (progress: SyncProgress) => void): () => void {
+    const source = new EventSource(`${this.baseURL}/sync-logs/stream`, { withCredentials: true })
+    const types: SyncProgressType[] = ['started', 'discovered', 'project', 'file', 'finished']
+    for (const type of types) {
+      source.addEventListener(type, (event) => {
+        onProgress(JSON.parse((event as MessageEvent).data) as SyncProgress)
+      })
+    }
+    return () => source.close()
+  }
+
+  // Browser navigation target for provider login; not fetched
+  oidcLoginURL(): string {
+    return `${this.baseURL}/auth/oidc/login`
+  }
+
+  async getAuditLog(query: AuditLogQuery = {}): Promise<AuditLogPage> {
+    const params = new URLSearchParams()
+    Object.entries(query).forEach(([key, value]) => {
+      if (value !== undefined && value !== '') {
+        params.set(key, String(value))
+      }
+    })
+    const qs = params.toString()
+    return this.request(`/admin/audit${qs ? `?${qs}` : ''}`)
+  }
+
+  // Clears content older than `days`, or than content_retention_days when omitted
+  async pruneContent(days?: number): Promise<PruneResult> {
+    return this.request(`/admin/prune${days ? `?days=${days}` : ''}`, { method: 'POST' })
+  }
+
+  async getDuplicateReport(limit?: number): Promise<DuplicateReport> {
+    return this.request(`/admin/duplicates${limit ? `?limit=${limit}` : ''}`)
+  }
+
+  // Rebuilds session and window totals from messages; dryRun only reports the discrepancies
+  async recomputeAggregates(dryRun?: boolean): Promise<AggregateReport> {
+    return this.request(`/admin/recompute${dryRun ? '?dry_run=true' : ''}`, { method: 'POST' })
+  }
+
+  async getLimitEvents(limit?: number): Promise<LimitEventReport> {
+    return this.request(`/limit-events${limit ? `?limit=${limit}` : ''}`)
+  }
+
+  async createBackup(): Promise<{ backup: DatabaseBackup; warning?: string }> {
+    return this.request('/admin/backup', { method: 'POST' })
+  }
+
+  async getBackups(): Promise<{ directory: string; backups: DatabaseBackup[]; count: number }> {
+    return this.request('/admin/backups')
+  }
+
+  async getConfig(): Promise<RuntimeConfig> {
+    return this.request('/config')
+  }
+
+  async updateConfig(update: Partial<RuntimeConfig>): Promise<RuntimeConfig> {
+    return this.request('/config', {
+      method: 'PATCH',
+      body: JSON.stringify(update),
+    })
+  }
+
+}
+
+// timeRangeQuery builds the ?since=&until= query string; both accept RFC3339 times or YYYY-MM-DD dates
+function timeRangeQuery(since?: string, until?: string, user?: string): string {
+  const params = new URLSearchParams()
+  if (since) params.set('since', since)
+  if (until) params.set('until', until)
+  if (user) params.set('user', user)
+  const query = params.toString()
+  return query ? `?${query}` : ''
+}
+
+// exportUrl returns the download URL of a session or message export
+export function exportUrl(kind: 'sessions' | 'messages', options: ExportOptions = {}): string {
+  const params = new URLSearchParams()
+  for (const [key, value] of Object.entries(options)) {
+    if (value !== undefined && value !== '' && value !== false) params.set(key, String(value))
+  }
+  const query = params.toString()
+  return `${API_BASE_URL}/export/${kind}${query ? `?${query}` : ''}`
 }
 
 export const apiClient = new ApiClient(API_BASE_URL)
 
 export const api = {
+  search: (q: string, project?: string, limit?: number) => apiClient.search(q, project, limit),
   tokenUsage: {
     getCurrent: () => apiClient.getTokenUsage(),
     getAvailable: (plan?: string) => apiClient.getAvailableTokens(plan),
+    getPlanUtilization: () => apiClient.getPlanUtilization(),
+    getForecast: (plan?: string) => apiClient.getForecast(plan),
   },
   sessions: {
     getAll: () => apiClient.getSessions(),
+    query: (query?: SessionQuery) => apiClient.querySessions(query),
     getById: (id: string, page?: number, pageSize?: number) => apiClient.getSessionDetail(id, page, pageSize),
+    getTranscript: (id: string, page?: number, pageSize?: number) => apiClient.getSessionTranscript(id, page, pageSize),
+    getActivity: (id: string, gapMinutes?: number, tz?: string) => apiClient.getSessionActivity(id, gapMinutes, tz),
+    update: (id: string, update: SessionUpdate) => apiClient.updateSession(id, update),
+    addTags: (id: string, tags: string[]) => apiClient.addSessionTags(id, tags),
+    removeTag: (id: string, tag: string) => apiClient.removeSessionTag(id, tag),
+  },
+  tags: {
+    getAll: (since?: string, until?: string, user?: string) => apiClient.getTags(since, until, user),
+  },
+  usage: {
+    daily: (days?: number) => apiClient.getDailyUsage(days),
+    rollups: (granularity: UsageGranularity, from?: string, to?: string, tz?: string) => apiClient.getUsageRollups(granularity, from, to, tz),
+    heatmap: (days?: number, tz?: string) => apiClient.getUsageHeatmap(days, tz),
+    projects: () => apiClient.getProjectUsage(),
+    byModel: (from?: string, to?: string, project?: string) => apiClient.getModelUsage(from, to, project),
+    tools: (since?: string, until?: string) => apiClient.getToolUsage(since, until),
+  },
+  users: {
+    getAll: () => apiClient.getUsers(),
+  },
+  projects: {
+    getAll: (since?: string, until?: string, user?: string) => apiClient.getProjects(since, until, user),
+    getUsage: (name: string, since?: string, until?: string) => apiClient.getProjectDetail(name, since, until),
+  },
+  budgets: {
+    getAll: () => apiClient.getBudgets(),
+    getById: (id: string) => apiClient.getBudget(id),
+    create: (budget: BudgetInput) => apiClient.createBudget(budget),
+    update: (id: string, budget: BudgetInput) => apiClient.updateBudget(id, budget),
+    delete: (id: string) => apiClient.deleteBudget(id),
+    alerts: (budgetId?: string, since?: string, limit?: number) => apiClient.getAlerts(budgetId, since, limit),
+  },
+  webhooks: {
+    getAll: () => apiClient.getWebhooks(),
+    create: (webhook: WebhookInput) => apiClient.createWebhook(webhook),
+    update: (id: string, webhook: WebhookInput) => apiClient.updateWebhook(id, webhook),
+    delete: (id: string) => apiClient.deleteWebhook(id),
+    deliveries: (id: string, limit?: number) => apiClient.getWebhookDeliveries(id, limit),
   },
   costs: {
-    getCurrentMonth: () => apiClient.getCurrentMonthCosts(),
+    getCurrentMonth: (breakdown?: 'billing', tz?: string) => apiClient.getCurrentMonthCosts(breakdown, tz),
+    getForecast: (tz?: string) => apiClient.getCostForecast(tz),
+  },
+  stats: {
+    percentiles: (days?: number[]) => apiClient.getPercentiles(days),
   },
   tasks: {
-    getAll: () => apiClient.getTasks(),
+    getAll: (status?: TaskStatus) => apiClient.getTasks(status),
+    getById: (id: string) => apiClient.getTask(id),
+    create: (task: TaskInput) => apiClient.createTask(task),
+    update: (id: string, task: TaskInput) => apiClient.updateTask(id, task),
+    delete: (id: string) => apiClient.deleteTask(id),
+    run: (id: string) => apiClient.runTask(id),
+    cancel: (id: string) => apiClient.cancelTask(id),
+    schedule: () => apiClient.getTaskSchedule(),
   },
   sync: {
-    logs: () => apiClient.syncLogs(),
+    logs: () => apiClient.syncLogsAndWait(),
+    job: (id: string) => apiClient.getSyncJob(id),
+    errors: (query?: SyncParseErrorQuery) => apiClient.getSyncErrors(query),
+    progress: (onProgress: (progress: SyncProgress) => void) => apiClient.subscribeSyncProgress(onProgress),
+  },
+  live: {
+    subscribe: (onUpdate: (update: LiveUpdate) => void, onConnectionChange?: (connected: boolean) => void) =>
+      apiClient.subscribeLiveUpdates(onUpdate, onConnectionChange),
+  },
+  watcher: {
+    status: () => apiClient.getWatcherStatus(),
+    start: () => apiClient.startWatcher(),
+    stop: () => apiClient.stopWatcher(),
+  },
+  scheduler: {
+    status: () => apiClient.getSchedulerStatus(),
+    start: () => apiClient.startScheduler(),
+    stop: () => apiClient.stopScheduler(),
+  },
+  auth: {
+    status: () => apiClient.getAuthStatus(),
+    login: (username: string, password: string) => apiClient.login(username, password),
+    logout: () => apiClient.logout(),
+    oidcLoginURL: () => apiClient.oidcLoginURL(),
+  },
+  audit: {
+    list: (query?: AuditLogQuery) => apiClient.getAuditLog(query),
+  },
+  retention: {
+    prune: (days?: number) => apiClient.pruneContent(days),
+  },
+  duplicates: {
+    report: (limit?: number) => apiClient.getDuplicateReport(limit),
+  },
+  aggregates: {
+    recompute: (dryRun?: boolean) => apiClient.recomputeAggregates(dryRun),
+  },
+  limits: {
+    events: (limit?: number) => apiClient.getLimitEvents(limit),
+  },
+  backups: {
+    create: () => apiClient.createBackup(),
+    list: () => apiClient.getBackups(),
+  },
+  config: {
+    get: () => apiClient.getConfig(),
+    update: (update: Partial<RuntimeConfig>) => apiClient.updateConfig(update),
   },
 }
\ No newline at end of file
@@ -49,6 +49,12 @@ export const translations: Record<Language, Translations> = {
       hours: '時間',
       minutes: '分',
     },
+    providerSpend: {
+      title: 'ツール別の利用額（今月）',
+      messages: 'メッセージ',
+      sessions: 'セッション',
+      share: '利用額の割合',
+    },
     session: {
       status: 'ステータス',
       sessionId: 'セッションID',
@@ -115,6 +121,15 @@ export const translations: Record<Language, Translations> = {
       cancel: 'キャンセル',
       save: '保存',
     },
+    login: {
+      title: 'ログイン',
+      description: 'このClaudeeeインスタンスを表示するにはログインが必要です。',
+      username: 'ユーザー名',
+      password: 'パスワード',
+      submit: 'ログイン',
+      sso: 'SSOでログイン',
+      failed: 'ユーザー名またはパスワードが正しくありません',
+    },
     footer: {
       description: 'Claude Code使用状況の監視とタスクスケジューリングを行うWebアプリケーション',
       sponsor: 'スポンサー',
@@ -187,6 +202,12 @@ export const translations: Record<Language, Translations> = {
       hours: 'hours',
       minutes: 'minutes',
     },
+    providerSpend: {
+      title: 'Spend by Tool (This Month)',
+      messages: 'messages',
+      sessions: 'sessions',
+      share: 'of spend',
+    },
     session: {
       status: 'Status',
       sessionId: 'Session ID',
@@ -253,6 +274,15 @@ export const translations: Record<Language, Translations> = {
       cancel: 'Cancel',
       save: 'Save',
     },
+    login: {
+      title: 'Sign in',
+      description: 'Sign in to view this Claudeee instance.',
+      username: 'Username',
+      password: 'Password',
+      submit: 'Sign in',
+      sso: 'Sign in with SSO',
+      failed: 'Invalid username or password',
+    },
     footer: {
       description: 'Web application for monitoring Claude Code usage and task scheduling',
       sponsor: 'Sponsor',
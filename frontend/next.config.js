@@ -1,5 +1,30 @@
+const fs = require('fs')
+const os = require('os')
+const path = require('path')
+
+// Fall back to the address published by a running backend, which may have
+// moved off port 8080 when it was already taken
+function discoverApiUrl() {
+  if (process.env.NEXT_PUBLIC_API_URL) {
+    return process.env.NEXT_PUBLIC_API_URL
+  }
+  const profile = process.env.CLAUDEEE_PROFILE
+  const dataDir = profile && profile !== 'default'
+    ? path.join(os.homedir(), '.claudeee', 'profiles', profile)
+    : path.join(os.homedir(), '.claudeee')
+  try {
+    const info = JSON.parse(fs.readFileSync(path.join(dataDir, 'server.json'), 'utf8'))
+    return info.url
+  } catch (err) {
+    return undefined
+  }
+}
+
+const discoveredApiUrl = discoverApiUrl()
+
 /** @type {import('next').NextConfig} */
 const nextConfig = {
+  ...(discoveredApiUrl ? { env: { NEXT_PUBLIC_API_URL: discoveredApiUrl } } : {}),
   output: 'standalone',
   serverExternalPackages: [],
   // Disable strict mode for production builds to avoid double rendering issues
@@ -0,0 +1,199 @@
+#!/usr/bin/env node
+
+const os = require('os');
+const path = require('path');
+const fs = require('fs');
+const { spawnSync } = require('child_process');
+
+/**
+ * Login-item / autostart management
+ * Registers claudeee with the OS so the monitor starts at login:
+ *   - macOS: LaunchAgent plist in ~/Library/LaunchAgents
+ *   - Linux: systemd user unit in ~/.config/systemd/user
+ *   - Windows: command script in the user's Startup folder
+ */
+
+const LABEL = 'com.claudeee.monitor';
+const SERVICE_NAME = 'claudeee.service';
+
+class AutostartManager {
+  /**
+   * @param {string} cliPath - Absolute path to bin/claudeee.js
+   * @param {string[]} startArgs - Arguments passed to the CLI at login (e.g. ['start', '-bp', '8081'])
+   */
+  constructor(cliPath, startArgs = ['start']) {
+    this.platform = process.platform;
+    this.cliPath = cliPath;
+    this.startArgs = startArgs;
+    this.logDir = path.join(os.homedir(), '.claudeee', 'logs');
+  }
+
+  /**
+   * Get the file that registers the login item on this platform
+   * @returns {string} Path to the plist, unit, or startup script
+   */
+  getEntryPath() {
+    switch (this.platform) {
+      case 'darwin':
+        return path.join(os.homedir(), 'Library', 'LaunchAgents', `${LABEL}.plist`);
+      case 'linux':
+        return path.join(process.env.XDG_CONFIG_HOME || path.join(os.homedir(), '.config'), 'systemd', 'user', SERVICE_NAME);
+      case 'win32':
+        return path.join(process.env.APPDATA || path.join(os.homedir(), 'AppData', 'Roaming'),
+          'Microsoft', 'Windows', 'Start Menu', 'Programs', 'Startup', 'claudeee.cmd');
+      default:
+        throw new Error(`Autostart is not supported on ${this.platform}`);
+    }
+  }
+
+  /**
+   * Check whether autostart is currently configured
+   * @returns {boolean}
+   */
+  isEnabled() {
+    return fs.existsSync(this.getEntryPath());
+  }
+
+  /**
+   * Register claudeee to start at login
+   * @returns {string} Path of the created entry
+   */
+  enable() {
+    const entryPath = this.getEntryPath();
+    fs.mkdirSync(path.dirname(entryPath), { recursive: true });
+    fs.mkdirSync(this.logDir, { recursive: true });
+
+    switch (this.platform) {
+      case 'darwin':
+        if (this.isEnabled()) {
+          this.run('launchctl', ['unload', entryPath]);
+        }
+        fs.writeFileSync(entryPath, this.launchAgentPlist());
+        this.run('launchctl', ['load', '-w', entryPath]);
+        break;
+      case 'linux':
+        fs.writeFileSync(entryPath, this.systemdUnit());
+        this.run('systemctl', ['--user', 'daemon-reload']);
+        this.run('systemctl', ['--user', 'enable', SERVICE_NAME]);
+        break;
+      case 'win32':
+        fs.writeFileSync(entryPath, this.windowsStartupScript());
+        break;
+    }
+
+    return entryPath;
+  }
+
+  /**
+   * Remove the login item
+   * @returns {boolean} Whether an entry was removed
+   */
+  disable() {
+    const entryPath = this.getEntryPath();
+    if (!fs.existsSync(entryPath)) {
+      return false;
+    }
+
+    switch (this.platform) {
+      case 'darwin':
+        this.run('launchctl', ['unload', '-w', entryPath]);
+        break;
+      case 'linux':
+        this.run('systemctl', ['--user', 'disable', SERVICE_NAME]);
+        break;
+    }
+
+    fs.unlinkSync(entryPath);
+
+    if (this.platform === 'linux') {
+      this.run('systemctl', ['--user', 'daemon-reload']);
+    }
+    return true;
+  }
+
+  /**
+   * Command line used to start claudeee at login
+   * @returns {string[]}
+   */
+  getCommand() {
+    return [process.execPath, this.cliPath, ...this.startArgs];
+  }
+
+  launchAgentPlist() {
+    const args = this.getCommand()
+      .map((arg) => `    <string>${escapeXml(arg)}</string>`)
+      .join('\n');
+
+    return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>${LABEL}</string>
+  <key>ProgramArguments</key>
+  <array>
+${args}
+  </array>
+  <key>EnvironmentVariables</key>
+  <dict>
+    <key>PATH</key>
+    <string>${escapeXml(process.env.PATH || '/usr/bin:/bin:/usr/local/bin')}</string>
+  </dict>
+  <key>RunAtLoad</key>
+  <true/>
+  <key>StandardOutPath</key>
+  <string>${escapeXml(path.join(this.logDir, 'autostart.log'))}</string>
+  <key>StandardErrorPath</key>
+  <string>${escapeXml(path.join(this.logDir, 'autostart.log'))}</string>
+</dict>
+</plist>
+`;
+  }
+
+  systemdUnit() {
+    const command = this.getCommand().map(quoteSystemdArg).join(' ');
+
+    return `[Unit]
+Description=Claudeee - Claude Code usage monitor
+After=network.target
+
+[Service]
+Type=simple
+ExecStart=${command}
+Environment=PATH=${process.env.PATH || '/usr/bin:/bin:/usr/local/bin'}
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`;
+  }
+
+  windowsStartupScript() {
+    const command = this.getCommand().map((arg) => `"${arg}"`).join(' ');
+    return `@echo off\r\nstart "claudeee" /min ${command}\r\n`;
+  }
+
+  run(command, args) {
+    const result = spawnSync(command, args, { stdio: 'ignore' });
+    if (result.error) {
+      throw new Error(`Failed to run ${command}: ${result.error.message}`);
+    }
+    if (result.status !== 0) {
+      throw new Error(`${command} ${args.join(' ')} exited with code ${result.status}`);
+    }
+  }
+}
+
+function escapeXml(value) {
+  return String(value)
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;')
+    .replace(/"/g, '&quot;');
+}
+
+function quoteSystemdArg(arg) {
+  return /[\s"\\]/.test(arg) ? `"${arg.replace(/(["\\])/g, '\\$1')}"` : arg;
+}
+
+module.exports = AutostartManager;